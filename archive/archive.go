@@ -0,0 +1,256 @@
+// Package archive writes a snapshot of any FS to a tar or zip archive.
+//
+// Archives are written from a separate package to avoid importing the standard library's "os" package, which archive/tar and archive/zip depend on, into hackpadfs.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/bufferpool"
+	"github.com/hack-pad/hackpadfs/internal/fserrors"
+	"github.com/hack-pad/hackpadfs/internal/fspath"
+)
+
+// Format is an archive file format
+type Format int
+
+// Supported archive formats
+const (
+	Tar Format = iota + 1
+	Zip
+	TarGzip // TarGzip is a gzip compressed tar archive, like a .tar.gz or .tgz file
+)
+
+func (f Format) String() string {
+	switch f {
+	case Tar:
+		return "tar"
+	case Zip:
+		return "zip"
+	case TarGzip:
+		return "tar.gz"
+	default:
+		return "unknown"
+	}
+}
+
+// entryWriter adds files to an archive
+type entryWriter interface {
+	writeDir(name string, info hackpadfs.FileInfo) error
+	writeSymlink(name string, info hackpadfs.FileInfo, target string) error
+	writeFile(name string, info hackpadfs.FileInfo, contents io.Reader) error
+	Close() error
+}
+
+// Options contain options for WriteWithOptions()
+type Options struct {
+	// Filter skips archiving the paths it returns false for. See hackpadfs.WalkDirOptions.
+	Filter hackpadfs.Filter
+	// Reproducible writes the same archive bytes whenever the archived files have the same names, contents, permissions, and symlink targets, so archives built from an FS have stable digests for caching and verification.
+	// Entries are written in sorted order, with every modified time set to ModTime and without owners, access times, or change times.
+	// Gzip headers never include a file name or time, so TarGzip archives are reproducible too.
+	Reproducible bool
+	// ModTime is the modified time of every entry when Reproducible is set. Defaults to 1980-01-01 00:00:00 UTC, the earliest time a zip archive can hold.
+	ModTime time.Time
+}
+
+// defaultReproducibleModTime is the default Options.ModTime
+var defaultReproducibleModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Write writes the directories, regular files, and symlinks at and under 'root' in 'fs' to 'w' as an archive in the given format. Other file types are skipped.
+// Names in the archive are relative to 'root', so extracting the archive recreates the contents of 'root'. If 'root' is a file, the archive contains only that file.
+// Symlinks are archived as links, not followed. Does not close 'w'.
+func Write(w io.Writer, fs hackpadfs.FS, root string, format Format) error {
+	return WriteWithOptions(w, fs, root, format, Options{})
+}
+
+// WriteWithOptions is like Write(), but with additional options. See Options for details.
+func WriteWithOptions(w io.Writer, fs hackpadfs.FS, root string, format Format, options Options) (retErr error) {
+	defer func() { retErr = fserrors.WithMessage(retErr, format.String()) }()
+
+	var archive entryWriter
+	switch format {
+	case Tar:
+		archive = &tarWriter{Writer: tar.NewWriter(w)}
+	case Zip:
+		archive = &zipWriter{zip.NewWriter(w)}
+	case TarGzip:
+		gzipWriter := gzip.NewWriter(w)
+		archive = &tarWriter{Writer: tar.NewWriter(gzipWriter), gzip: gzipWriter}
+	default:
+		return errors.New("unsupported archive format")
+	}
+	modTime := options.ModTime
+	if modTime.IsZero() {
+		modTime = defaultReproducibleModTime
+	}
+	var entries []walkedEntry // only collected when Reproducible is set, to sort them before writing
+	err := hackpadfs.WalkDirWithOptions(fs, root, func(name string, dirEntry hackpadfs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		archiveName := fspath.Rel(root, name)
+		if archiveName == "." {
+			if dirEntry.IsDir() {
+				return nil
+			}
+			archiveName = path.Base(name)
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+		if options.Reproducible {
+			entries = append(entries, walkedEntry{
+				name:        name,
+				archiveName: archiveName,
+				info:        &reproducibleInfo{FileInfo: info, modTime: modTime},
+			})
+			return nil
+		}
+		return writeEntry(archive, fs, name, archiveName, info)
+	}, hackpadfs.WalkDirOptions{Filter: options.Filter})
+	if err != nil {
+		return err
+	}
+	// a directory's name is a prefix of its contents' names, so sorting still writes directories first
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].archiveName < entries[b].archiveName
+	})
+	for _, entry := range entries {
+		if err := writeEntry(archive, fs, entry.name, entry.archiveName, entry.info); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// walkedEntry is a file to write to an archive, found while walking its FS
+type walkedEntry struct {
+	name        string
+	archiveName string
+	info        hackpadfs.FileInfo
+}
+
+// writeEntry writes the file 'name' in 'fs' to 'archive' as 'archiveName'. Skips file types other than directories, regular files, and symlinks.
+func writeEntry(archive entryWriter, fs hackpadfs.FS, name, archiveName string, info hackpadfs.FileInfo) error {
+	switch {
+	case info.IsDir():
+		return archive.writeDir(archiveName, info)
+	case info.Mode().IsRegular():
+		f, err := fs.Open(name)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		return archive.writeFile(archiveName, info, f)
+	case info.Mode()&hackpadfs.ModeSymlink != 0:
+		target, err := hackpadfs.Readlink(fs, name)
+		if err != nil {
+			return err
+		}
+		return archive.writeSymlink(archiveName, info, fspath.RelLink(name, target)) // archives store targets relative to the link, like the os package
+	default:
+		return nil
+	}
+}
+
+// reproducibleInfo replaces a file's modified time and hides its system-specific info, like owner IDs, from archive headers
+type reproducibleInfo struct {
+	hackpadfs.FileInfo
+	modTime time.Time
+}
+
+func (i *reproducibleInfo) ModTime() time.Time {
+	return i.modTime
+}
+
+func (i *reproducibleInfo) Sys() interface{} {
+	return nil
+}
+
+type tarWriter struct {
+	*tar.Writer
+	gzip *gzip.Writer // gzip compresses the archive, if not nil
+}
+
+func (t *tarWriter) Close() error {
+	err := t.Writer.Close()
+	if t.gzip != nil {
+		if gzipErr := t.gzip.Close(); err == nil {
+			err = gzipErr
+		}
+	}
+	return err
+}
+
+func (t *tarWriter) writeDir(name string, info hackpadfs.FileInfo) error {
+	return t.writeHeader(name+"/", info, "")
+}
+
+func (t *tarWriter) writeSymlink(name string, info hackpadfs.FileInfo, target string) error {
+	return t.writeHeader(name, info, target)
+}
+
+func (t *tarWriter) writeFile(name string, info hackpadfs.FileInfo, contents io.Reader) error {
+	if err := t.writeHeader(name, info, ""); err != nil {
+		return err
+	}
+	_, err := bufferpool.Copies.Copy(t.Writer, contents)
+	return fserrors.WithMessage(err, "copying file")
+}
+
+func (t *tarWriter) writeHeader(name string, info hackpadfs.FileInfo, link string) error {
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	return t.WriteHeader(header)
+}
+
+type zipWriter struct {
+	*zip.Writer
+}
+
+func (z *zipWriter) writeDir(name string, info hackpadfs.FileInfo) error {
+	_, err := z.create(name+"/", info, zip.Store)
+	return err
+}
+
+// writeSymlink stores the link's target as its contents, the same convention as the zip and unzip tools
+func (z *zipWriter) writeSymlink(name string, info hackpadfs.FileInfo, target string) error {
+	w, err := z.create(name, info, zip.Store)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, target)
+	return err
+}
+
+func (z *zipWriter) writeFile(name string, info hackpadfs.FileInfo, contents io.Reader) error {
+	w, err := z.create(name, info, zip.Deflate)
+	if err != nil {
+		return err
+	}
+	_, err = bufferpool.Copies.Copy(w, contents)
+	return fserrors.WithMessage(err, "copying file")
+}
+
+func (z *zipWriter) create(name string, info hackpadfs.FileInfo, method uint16) (io.Writer, error) {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	header.Name = name
+	header.Method = method
+	return z.CreateHeader(header)
+}
@@ -0,0 +1,217 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	osfs "github.com/hack-pad/hackpadfs/os"
+)
+
+// entry is an archived file, with its contents or symlink target
+type entry struct {
+	Mode     hackpadfs.FileMode
+	Contents string
+}
+
+func readTar(tb testing.TB, r io.Reader) map[string]entry {
+	tb.Helper()
+	entries := make(map[string]entry)
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		contents, err := io.ReadAll(archive)
+		assert.NoError(tb, err)
+		if header.Typeflag == tar.TypeSymlink {
+			contents = []byte(header.Linkname)
+		}
+		entries[header.Name] = entry{Mode: header.FileInfo().Mode(), Contents: string(contents)}
+	}
+}
+
+func readZip(tb testing.TB, b []byte) map[string]entry {
+	tb.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	entries := make(map[string]entry)
+	for _, file := range archive.File {
+		f, err := file.Open()
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		contents, err := io.ReadAll(f)
+		assert.NoError(tb, err)
+		assert.NoError(tb, f.Close())
+		entries[file.Name] = entry{Mode: file.Mode(), Contents: string(contents)}
+	}
+	return entries
+}
+
+func makeFS(tb testing.TB) hackpadfs.FS {
+	tb.Helper()
+	fs, err := osfs.NewDirFS(tb.TempDir())
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	requireNoError := func(err error) {
+		tb.Helper()
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+	}
+	requireNoError(hackpadfs.MkdirAll(fs, "root/dir/empty", 0750))
+	requireNoError(hackpadfs.WriteFullFile(fs, "root/dir/foo", []byte("foo"), 0640))
+	requireNoError(hackpadfs.Symlink(fs, "root/dir/foo", "root/link"))
+	requireNoError(hackpadfs.WriteFullFile(fs, "outside", []byte("outside"), 0600))
+	return fs
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	expected := map[string]entry{
+		"dir/":       {Mode: hackpadfs.ModeDir | 0750},
+		"dir/empty/": {Mode: hackpadfs.ModeDir | 0750},
+		"dir/foo":    {Mode: 0640, Contents: "foo"},
+		"link":       {Mode: hackpadfs.ModeSymlink | 0777, Contents: "dir/foo"}, // relative to the link
+	}
+
+	t.Run("tar", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		assert.NoError(t, Write(&buf, fs, "root", Tar))
+		assert.Equal(t, expected, readTar(t, &buf))
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		assert.NoError(t, Write(&buf, fs, "root", Zip))
+		assert.Equal(t, expected, readZip(t, buf.Bytes()))
+	})
+
+	t.Run("tar.gz", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		assert.NoError(t, Write(&buf, fs, "root", TarGzip))
+		gzipReader, err := gzip.NewReader(&buf)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, expected, readTar(t, gzipReader))
+	})
+}
+
+func TestWriteWithOptionsReproducible(t *testing.T) {
+	t.Parallel()
+	// makeFS creates the same files in a different order and at a different time for each 'variant'
+	makeFS := func(t *testing.T, variant int) hackpadfs.FS {
+		t.Helper()
+		fs, err := mem.NewFS()
+		assert.NoError(t, err)
+		names := []string{"b", "a", "dir/c"}
+		if variant == 1 {
+			names = []string{"dir/c", "a", "b"}
+		}
+		assert.NoError(t, fs.Mkdir("dir", 0700))
+		for _, name := range names {
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, name, []byte(name), 0600))
+			modTime := time.Now().Add(time.Duration(variant) * time.Hour)
+			assert.NoError(t, hackpadfs.Chtimes(fs, name, modTime, modTime))
+		}
+		return fs
+	}
+
+	for _, format := range []Format{Tar, Zip, TarGzip} {
+		format := format // enable parallel sub-tests
+		t.Run(format.String(), func(t *testing.T) {
+			t.Parallel()
+			var archives [2]bytes.Buffer
+			for variant := range archives {
+				err := WriteWithOptions(&archives[variant], makeFS(t, variant), ".", format, Options{Reproducible: true})
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, archives[0].Bytes(), archives[1].Bytes())
+		})
+	}
+
+	t.Run("mod time", func(t *testing.T) {
+		t.Parallel()
+		modTime := time.Date(2020, time.February, 3, 4, 5, 6, 0, time.UTC)
+		var buf bytes.Buffer
+		assert.NoError(t, WriteWithOptions(&buf, makeFS(t, 0), ".", Tar, Options{Reproducible: true, ModTime: modTime}))
+		archive := tar.NewReader(&buf)
+		var names []string
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			names = append(names, header.Name)
+			assert.Equal(t, modTime, header.ModTime.UTC())
+			assert.Equal(t, 0, header.Uid)
+			assert.Equal(t, "", header.Uname)
+		}
+		assert.Equal(t, []string{"a", "b", "dir/", "dir/c"}, names)
+	})
+}
+
+func TestWriteFile(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Mkdir("dir", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/foo", []byte("foo"), 0600))
+
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, fs, "dir/foo", Tar))
+	assert.Equal(t, map[string]entry{
+		"foo": {Mode: 0600, Contents: "foo"},
+	}, readTar(t, &buf))
+}
+
+func TestWriteErrors(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, Write(&buf, fs, "missing", Zip))
+	assert.Error(t, Write(&buf, fs, ".", Format(0)))
+}
+
+func TestWriteWithOptionsFilter(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, fs.MkdirAll("root/skip", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "root/skip/bar", []byte("bar"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "root/foo", []byte("foo"), 0600))
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteWithOptions(&buf, fs, "root", Tar, Options{
+		Filter: func(name string, _ hackpadfs.DirEntry) (bool, error) {
+			return name != "root/skip", nil
+		},
+	}))
+	assert.Equal(t, map[string]entry{
+		"foo": {Mode: 0600, Contents: "foo"},
+	}, readTar(t, &buf))
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"io"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// file wraps a writable file, recording each mutation made through its handle
+type file struct {
+	hackpadfs.File
+	fs   *FS
+	name string
+	flag int
+}
+
+// offset returns the best-effort position of the next Write
+func (f *file) offset() int64 {
+	if f.flag&hackpadfs.FlagAppend != 0 {
+		info, err := f.File.Stat()
+		if err != nil {
+			return 0
+		}
+		return info.Size()
+	}
+	offset, err := hackpadfs.SeekFile(f.File, 0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+	return offset
+}
+
+func (f *file) ReadAt(p []byte, off int64) (n int, err error) {
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+func (f *file) Write(p []byte) (n int, err error) {
+	offset := f.offset()
+	n, err = hackpadfs.WriteFile(f.File, p)
+	f.fs.record(Entry{Op: "write", Path: f.name, Offset: offset, Size: int64(n), Data: p[:n]}, err)
+	return n, err
+}
+
+func (f *file) WriteAt(p []byte, off int64) (n int, err error) {
+	n, err = hackpadfs.WriteAtFile(f.File, p, off)
+	f.fs.record(Entry{Op: "write", Path: f.name, Offset: off, Size: int64(n), Data: p[:n]}, err)
+	return n, err
+}
+
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDirFile(f.File, n)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+func (f *file) Sync() error {
+	return hackpadfs.SyncFile(f.File)
+}
+
+func (f *file) Truncate(size int64) error {
+	err := hackpadfs.TruncateFile(f.File, size)
+	f.fs.record(Entry{Op: "truncate", Path: f.name, Size: size}, err)
+	return err
+}
+
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	err := hackpadfs.ChmodFile(f.File, mode)
+	f.fs.record(Entry{Op: "chmod", Path: f.name, Mode: mode}, err)
+	return err
+}
+
+func (f *file) Chown(uid, gid int) error {
+	err := hackpadfs.ChownFile(f.File, uid, gid)
+	f.fs.record(Entry{Op: "chown", Path: f.name, UID: uid, GID: gid}, err)
+	return err
+}
+
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	err := hackpadfs.ChtimesFile(f.File, atime, mtime)
+	f.fs.record(Entry{Op: "chtimes", Path: f.name, ATime: atime, MTime: mtime}, err)
+	return err
+}
@@ -0,0 +1,211 @@
+// Package audit contains a file system wrapper which records every mutating operation to an append-only log.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.WriteFileFS
+	} = &FS{}
+)
+
+// Entry is a single mutating operation recorded in the log. Entries are written as one JSON object per line.
+type Entry struct {
+	Time    time.Time          `json:"time"`
+	Op      string             `json:"op"`
+	Path    string             `json:"path"`
+	NewPath string             `json:"newPath,omitempty"` // NewPath is the destination of a rename
+	Flag    int                `json:"flag,omitempty"`
+	Mode    hackpadfs.FileMode `json:"mode,omitempty"`
+	Size    int64              `json:"size,omitempty"`   // Size is the number of bytes written or the new size of a truncated file
+	Offset  int64              `json:"offset,omitempty"` // Offset is where a write started in the file
+	UID     int                `json:"uid,omitempty"`
+	GID     int                `json:"gid,omitempty"`
+	ATime   time.Time          `json:"atime"`
+	MTime   time.Time          `json:"mtime"`
+	Data    []byte             `json:"data,omitempty"` // Data holds written bytes if Options.IncludeData is set
+	Err     string             `json:"err,omitempty"`  // Err is the operation's error message, if it failed
+}
+
+// FS wraps a source FS, recording each mutating operation to a log.
+type FS struct {
+	sourceFS hackpadfs.FS
+	options  Options
+
+	logMu  sync.Mutex
+	log    *json.Encoder
+	logErr error
+}
+
+// Options contain options for creating an FS
+type Options struct {
+	// IncludeData records the contents of every write in the log, enabling Replay() to fully reconstruct the FS.
+	IncludeData bool
+	// Now returns the current time for log entries. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewFS returns a new FS wrapping 'source' and appending log entries to 'log'.
+func NewFS(source hackpadfs.FS, log io.Writer, options Options) (*FS, error) {
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+	return &FS{
+		sourceFS: source,
+		options:  options,
+		log:      json.NewEncoder(log),
+	}, nil
+}
+
+// OpenLog opens the file 'name' in 'fs' for use as an append-only log, creating it if necessary.
+func OpenLog(fs hackpadfs.FS, name string) (io.WriteCloser, error) {
+	f, err := hackpadfs.OpenFile(fs, name, hackpadfs.FlagWriteOnly|hackpadfs.FlagAppend|hackpadfs.FlagCreate, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w, ok := f.(io.WriteCloser)
+	if !ok {
+		_ = f.Close()
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	return w, nil
+}
+
+// LogErr returns the first error encountered while writing to the log, if any.
+// Operations continue to run if logging fails, so callers with strict auditing requirements should check LogErr regularly.
+func (fs *FS) LogErr() error {
+	fs.logMu.Lock()
+	defer fs.logMu.Unlock()
+	return fs.logErr
+}
+
+func (fs *FS) record(entry Entry, err error) {
+	entry.Time = fs.options.Now()
+	if err != nil {
+		entry.Err = err.Error()
+	}
+	if !fs.options.IncludeData {
+		entry.Data = nil
+	}
+	fs.logMu.Lock()
+	defer fs.logMu.Unlock()
+	if encodeErr := fs.log.Encode(entry); encodeErr != nil && fs.logErr == nil {
+		fs.logErr = encodeErr
+	}
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	f, err := hackpadfs.OpenFile(fs.sourceFS, name, flag, perm)
+	if !hackpadfs.ParseFlags(flag).Modifies() {
+		return f, err
+	}
+	fs.record(Entry{Op: "open", Path: name, Flag: flag, Mode: perm}, err)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, fs: fs, name: name, flag: flag}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	err := hackpadfs.Mkdir(fs.sourceFS, name, perm)
+	fs.record(Entry{Op: "mkdir", Path: name, Mode: perm}, err)
+	return err
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	err := hackpadfs.MkdirAll(fs.sourceFS, path, perm)
+	fs.record(Entry{Op: "mkdirall", Path: path, Mode: perm}, err)
+	return err
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	err := hackpadfs.Remove(fs.sourceFS, name)
+	fs.record(Entry{Op: "remove", Path: name}, err)
+	return err
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	err := hackpadfs.RemoveAll(fs.sourceFS, name)
+	fs.record(Entry{Op: "removeall", Path: name}, err)
+	return err
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	err := hackpadfs.Rename(fs.sourceFS, oldname, newname)
+	fs.record(Entry{Op: "rename", Path: oldname, NewPath: newname}, err)
+	return err
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Stat(fs.sourceFS, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	err := hackpadfs.Chmod(fs.sourceFS, name, mode)
+	fs.record(Entry{Op: "chmod", Path: name, Mode: mode}, err)
+	return err
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	err := hackpadfs.Chown(fs.sourceFS, name, uid, gid)
+	fs.record(Entry{Op: "chown", Path: name, UID: uid, GID: gid}, err)
+	return err
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	err := hackpadfs.Chtimes(fs.sourceFS, name, atime, mtime)
+	fs.record(Entry{Op: "chtimes", Path: name, ATime: atime, MTime: mtime}, err)
+	return err
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDir(fs.sourceFS, name)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	return hackpadfs.ReadFile(fs.sourceFS, name)
+}
+
+// WriteFile implements hackpadfs.WriteFileFS
+func (fs *FS) WriteFile(name string, data []byte, perm hackpadfs.FileMode) error {
+	err := hackpadfs.WriteFullFile(fs.sourceFS, name, data, perm)
+	fs.record(Entry{Op: "writefile", Path: name, Mode: perm, Size: int64(len(data)), Data: data}, err)
+	return err
+}
@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newFS(tb testing.TB, log *bytes.Buffer, options Options) *FS {
+	tb.Helper()
+	source, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	fs, err := NewFS(source, log, options)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "audit",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			return newFS(tb, new(bytes.Buffer), Options{IncludeData: true})
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestLog(t *testing.T) {
+	t.Parallel()
+	var log bytes.Buffer
+	fs := newFS(t, &log, Options{})
+	assert.NoError(t, fs.Mkdir("foo", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("bar"), 0600))
+	_, err := hackpadfs.ReadFile(fs, "foo/bar")
+	assert.NoError(t, err)
+	assert.Error(t, fs.Remove("baz"))
+
+	var ops []string
+	decoder := json.NewDecoder(&log)
+	for decoder.More() {
+		var entry Entry
+		assert.NoError(t, decoder.Decode(&entry))
+		assert.Equal(t, 0, len(entry.Data))
+		ops = append(ops, entry.Op+" "+entry.Path)
+		if entry.Op == "remove" {
+			assert.Equal(t, true, entry.Err != "")
+		}
+	}
+	assert.Equal(t, []string{
+		"mkdir foo",
+		"writefile foo/bar",
+		"remove baz",
+	}, ops)
+	assert.NoError(t, fs.LogErr())
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+	var log bytes.Buffer
+	fs := newFS(t, &log, Options{IncludeData: true})
+	assert.NoError(t, hackpadfs.MkdirAll(fs, "foo/bar", 0700))
+	f, err := hackpadfs.Create(fs, "foo/bar/baz")
+	if assert.NoError(t, err) {
+		_, err = hackpadfs.WriteFile(f, []byte("hello world"))
+		assert.NoError(t, err)
+		_, err = hackpadfs.WriteAtFile(f, []byte("there"), 6)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+	}
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/biff", []byte("biff"), 0600))
+	assert.NoError(t, fs.Rename("foo/biff", "foo/boo"))
+	assert.NoError(t, fs.Chmod("foo/boo", 0400))
+	assert.Error(t, fs.Mkdir("foo", 0700))
+
+	dest, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, Replay(&log, dest))
+
+	contents, err := hackpadfs.ReadFile(dest, "foo/bar/baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello there", string(contents))
+	contents, err = hackpadfs.ReadFile(dest, "foo/boo")
+	assert.NoError(t, err)
+	assert.Equal(t, "biff", string(contents))
+	info, err := hackpadfs.Stat(dest, "foo/boo")
+	if assert.NoError(t, err) {
+		assert.Equal(t, hackpadfs.FileMode(0400), info.Mode())
+	}
+	_, err = hackpadfs.Stat(dest, "foo/biff")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
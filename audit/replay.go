@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// Replay reads log entries from 'r' and applies them, in order, to 'dest'. Entries recording failed operations are skipped.
+//
+// Fully reconstructing a file system requires the log be written with Options.IncludeData enabled.
+func Replay(r io.Reader, dest hackpadfs.FS) error {
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var entry Entry
+		err := decoder.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("audit: failed to decode log entry %d: %w", line, err)
+		}
+		if entry.Err != "" {
+			continue
+		}
+		if err := apply(entry, dest); err != nil {
+			return fmt.Errorf("audit: failed to replay log entry %d: %w", line, err)
+		}
+	}
+}
+
+func apply(entry Entry, dest hackpadfs.FS) error {
+	switch entry.Op {
+	case "open":
+		f, err := hackpadfs.OpenFile(dest, entry.Path, entry.Flag, entry.Mode)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	case "write":
+		f, err := hackpadfs.OpenFile(dest, entry.Path, hackpadfs.FlagWriteOnly, 0)
+		if err != nil {
+			return err
+		}
+		_, err = hackpadfs.WriteAtFile(f, entry.Data, entry.Offset)
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+		return err
+	case "truncate":
+		f, err := hackpadfs.OpenFile(dest, entry.Path, hackpadfs.FlagWriteOnly, 0)
+		if err != nil {
+			return err
+		}
+		err = hackpadfs.TruncateFile(f, entry.Size)
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+		return err
+	case "writefile":
+		return hackpadfs.WriteFullFile(dest, entry.Path, entry.Data, entry.Mode)
+	case "mkdir":
+		return hackpadfs.Mkdir(dest, entry.Path, entry.Mode)
+	case "mkdirall":
+		return hackpadfs.MkdirAll(dest, entry.Path, entry.Mode)
+	case "remove":
+		return hackpadfs.Remove(dest, entry.Path)
+	case "removeall":
+		return hackpadfs.RemoveAll(dest, entry.Path)
+	case "rename":
+		return hackpadfs.Rename(dest, entry.Path, entry.NewPath)
+	case "chmod":
+		return hackpadfs.Chmod(dest, entry.Path, entry.Mode)
+	case "chown":
+		return hackpadfs.Chown(dest, entry.Path, entry.UID, entry.GID)
+	case "chtimes":
+		return hackpadfs.Chtimes(dest, entry.Path, entry.ATime, entry.MTime)
+	default:
+		return fmt.Errorf("unrecognized op %q", entry.Op)
+	}
+}
@@ -0,0 +1,319 @@
+// Package blockdev exposes a single large file in any FS as a fixed-size block device, for databases and other page-oriented storage.
+package blockdev
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// DefaultPageSize is the default size of cached pages, matching the default page size of SQLite and bbolt
+const DefaultPageSize = 4096
+
+// Options contain options for opening a Device
+type Options struct {
+	// Size is the device's size in bytes. Smaller files are grown to Size. Defaults to the file's current size.
+	Size int64
+	// PageSize is the size of cached pages in bytes. Defaults to DefaultPageSize.
+	PageSize int
+	// CachePages is the maximum number of pages kept in memory. Writes to cached pages are buffered until they're evicted or Sync() is called.
+	// Defaults to 0, which disables the cache so every read and write goes to the file.
+	CachePages int
+	// ReadOnly opens the file read-only. Writes fail with hackpadfs.ErrPermission.
+	ReadOnly bool
+}
+
+// Device is a fixed-size file, read and written in place at arbitrary offsets. A Device is safe for concurrent use.
+type Device struct {
+	name    string
+	file    hackpadfs.File
+	options Options
+
+	mu     sync.Mutex
+	size   int64
+	pages  map[int64]*page // pages holds cached pages by index
+	lru    *list.List      // lru orders cached pages from most to least recently used
+	closed bool
+}
+
+type page struct {
+	index int64
+	data  []byte
+	dirty bool
+	elem  *list.Element
+}
+
+// Open opens the file 'name' in 'fs' as a Device, creating it if it doesn't exist and isn't read-only.
+// Fails with hackpadfs.ErrNotImplemented if the file doesn't support ReadAt(), or WriteAt() unless it's read-only.
+func Open(fs hackpadfs.FS, name string, options Options) (*Device, error) {
+	if options.PageSize <= 0 {
+		options.PageSize = DefaultPageSize
+	}
+	if options.Size < 0 || options.CachePages < 0 {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	flag := hackpadfs.FlagReadWrite | hackpadfs.FlagCreate
+	if options.ReadOnly {
+		flag = hackpadfs.FlagReadOnly
+	}
+	f, err := hackpadfs.OpenFile(fs, name, flag, 0600)
+	if err != nil {
+		return nil, err
+	}
+	d, err := newDevice(name, f, options)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func newDevice(name string, file hackpadfs.File, options Options) (*Device, error) {
+	_, readerAt := file.(hackpadfs.ReaderAtFile)
+	_, writerAt := file.(hackpadfs.WriterAtFile)
+	if !readerAt || (!writerAt && !options.ReadOnly) {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if options.Size > size {
+		if options.ReadOnly {
+			return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+		}
+		if err := hackpadfs.TruncateFile(file, options.Size); err != nil {
+			return nil, err
+		}
+	}
+	if options.Size > 0 {
+		size = options.Size
+	}
+	return &Device{
+		name:    name,
+		file:    file,
+		options: options,
+		size:    size,
+		pages:   make(map[int64]*page),
+		lru:     list.New(),
+	}, nil
+}
+
+// Size returns the size of the device in bytes
+func (d *Device) Size() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.size
+}
+
+// ReadAt implements io.ReaderAt. Reads past the end of the device return io.EOF.
+func (d *Device) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: "readat", Path: d.name, Err: hackpadfs.ErrInvalid}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if off >= d.size {
+		return 0, io.EOF
+	}
+	var eof error
+	if end := off + int64(len(p)); end > d.size {
+		p = p[:d.size-off]
+		eof = io.EOF
+	}
+	if d.options.CachePages == 0 {
+		n, err := hackpadfs.ReadAtFile(d.file, p, off)
+		if err == io.EOF && n == len(p) {
+			err = nil
+		}
+		if err != nil {
+			return n, d.wrapErr("readat", err)
+		}
+		return n, eof
+	}
+
+	n := 0
+	for n < len(p) {
+		pg, pageOffset, err := d.page(off + int64(n))
+		if err != nil {
+			return n, d.wrapErr("readat", err)
+		}
+		n += copy(p[n:], pg.data[pageOffset:])
+	}
+	return n, eof
+}
+
+// WriteAt implements io.WriterAt. Writes past the end of the device fail with hackpadfs.ErrNoSpace, without writing anything.
+func (d *Device) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: "writeat", Path: d.name, Err: hackpadfs.ErrInvalid}
+	}
+	if d.options.ReadOnly {
+		return 0, &hackpadfs.PathError{Op: "writeat", Path: d.name, Err: hackpadfs.ErrPermission}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if off+int64(len(p)) > d.size {
+		return 0, &hackpadfs.PathError{Op: "writeat", Path: d.name, Err: hackpadfs.ErrNoSpace}
+	}
+	if d.options.CachePages == 0 {
+		n, err := hackpadfs.WriteAtFile(d.file, p, off)
+		return n, d.wrapErr("writeat", err)
+	}
+
+	n := 0
+	for n < len(p) {
+		pg, pageOffset, err := d.page(off + int64(n))
+		if err != nil {
+			return n, d.wrapErr("writeat", err)
+		}
+		n += copy(pg.data[pageOffset:], p[n:])
+		pg.dirty = true
+	}
+	return n, nil
+}
+
+// Truncate resizes the device to 'size' bytes. Shrinking the device writes any buffered changes first.
+func (d *Device) Truncate(size int64) error {
+	if size < 0 {
+		return &hackpadfs.PathError{Op: "truncate", Path: d.name, Err: hackpadfs.ErrInvalid}
+	}
+	if d.options.ReadOnly {
+		return &hackpadfs.PathError{Op: "truncate", Path: d.name, Err: hackpadfs.ErrPermission}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if size < d.size {
+		if err := d.flush(); err != nil {
+			return err
+		}
+		d.pages = make(map[int64]*page)
+		d.lru.Init()
+	}
+	if err := hackpadfs.TruncateFile(d.file, size); err != nil {
+		return err
+	}
+	d.size = size
+	return nil
+}
+
+// Sync writes any changes buffered in the cache, then syncs the file to storage if supported
+func (d *Device) Sync() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.flush(); err != nil {
+		return err
+	}
+	err := hackpadfs.SyncFile(d.file)
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		return nil
+	}
+	return err
+}
+
+// Close syncs and closes the device
+func (d *Device) Close() error {
+	d.mu.Lock()
+	closed := d.closed
+	d.closed = true
+	d.mu.Unlock()
+	if closed {
+		return &hackpadfs.PathError{Op: "close", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	syncErr := d.Sync()
+	err := d.file.Close()
+	if syncErr != nil {
+		return syncErr
+	}
+	return err
+}
+
+// page returns the cached page containing 'off' and the offset of 'off' within it, reading it from the file if needed. Must be called with d.mu held.
+func (d *Device) page(off int64) (*page, int, error) {
+	pageSize := int64(d.options.PageSize)
+	index := off / pageSize
+	pageOffset := int(off % pageSize)
+	if pg, ok := d.pages[index]; ok {
+		d.lru.MoveToFront(pg.elem)
+		return pg, pageOffset, nil
+	}
+
+	pg := &page{index: index, data: make([]byte, pageSize)}
+	start := index * pageSize
+	readSize := pageSize
+	if remaining := d.size - start; remaining < readSize {
+		readSize = remaining
+	}
+	if _, err := hackpadfs.ReadAtFile(d.file, pg.data[:readSize], start); err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	for d.lru.Len() >= d.options.CachePages {
+		if err := d.evict(); err != nil {
+			return nil, 0, err
+		}
+	}
+	pg.elem = d.lru.PushFront(pg)
+	d.pages[index] = pg
+	return pg, pageOffset, nil
+}
+
+// evict removes the least recently used page, writing it first if it's dirty. Must be called with d.mu held.
+func (d *Device) evict() error {
+	pg := d.lru.Back().Value.(*page)
+	if err := d.writePage(pg); err != nil {
+		return err
+	}
+	d.lru.Remove(pg.elem)
+	delete(d.pages, pg.index)
+	return nil
+}
+
+// flush writes every dirty page, in file order. Must be called with d.mu held.
+func (d *Device) flush() error {
+	var dirty []*page
+	for _, pg := range d.pages {
+		if pg.dirty {
+			dirty = append(dirty, pg)
+		}
+	}
+	sort.Slice(dirty, func(a, b int) bool {
+		return dirty[a].index < dirty[b].index
+	})
+	for _, pg := range dirty {
+		if err := d.writePage(pg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePage writes 'pg' to the file if it's dirty. Must be called with d.mu held.
+func (d *Device) writePage(pg *page) error {
+	if !pg.dirty {
+		return nil
+	}
+	start := pg.index * int64(d.options.PageSize)
+	data := pg.data
+	if remaining := d.size - start; remaining < int64(len(data)) {
+		data = data[:remaining]
+	}
+	if _, err := hackpadfs.WriteAtFile(d.file, data, start); err != nil {
+		return d.wrapErr("writeat", err)
+	}
+	pg.dirty = false
+	return nil
+}
+
+// wrapErr returns 'err' as a PathError for the device, unless it already is one
+func (d *Device) wrapErr(op string, err error) error {
+	var pathErr *hackpadfs.PathError
+	if err == nil || errors.As(err, &pathErr) {
+		return err
+	}
+	return &hackpadfs.PathError{Op: op, Path: d.name, Err: err}
+}
@@ -0,0 +1,147 @@
+package blockdev
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newFS(tb testing.TB) *mem.FS {
+	tb.Helper()
+	fs, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func openDevice(tb testing.TB, fs hackpadfs.FS, options Options) *Device {
+	tb.Helper()
+	d, err := Open(fs, "disk", options)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	tb.Cleanup(func() {
+		_ = d.Close()
+	})
+	return d
+}
+
+func TestReadWrite(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		cachePages  int
+	}{
+		{description: "uncached", cachePages: 0},
+		{description: "one cached page", cachePages: 1},
+		{description: "many cached pages", cachePages: 16},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			fs := newFS(t)
+			d := openDevice(t, fs, Options{Size: 100, PageSize: 8, CachePages: tc.cachePages})
+			assert.Equal(t, int64(100), d.Size())
+
+			n, err := d.WriteAt([]byte("hello, block device"), 5)
+			assert.NoError(t, err)
+			assert.Equal(t, 19, n)
+
+			buf := make([]byte, 12)
+			n, err = d.ReadAt(buf, 12)
+			assert.NoError(t, err)
+			assert.Equal(t, 12, n)
+			assert.Equal(t, "block device", string(buf))
+
+			n, err = d.ReadAt(buf, 95)
+			assert.Equal(t, io.EOF, err)
+			assert.Equal(t, 5, n)
+			_, err = d.ReadAt(buf, 100)
+			assert.Equal(t, io.EOF, err)
+
+			_, err = d.WriteAt([]byte("too far"), 95)
+			assert.ErrorIs(t, hackpadfs.ErrNoSpace, err)
+			_, err = d.WriteAt([]byte("negative"), -1)
+			assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+
+			assert.NoError(t, d.Sync())
+			contents, err := hackpadfs.ReadFile(fs, "disk")
+			assert.NoError(t, err)
+			expected := make([]byte, 100)
+			copy(expected[5:], "hello, block device")
+			assert.Equal(t, expected, contents)
+		})
+	}
+}
+
+func TestCacheBuffersWrites(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t)
+	d := openDevice(t, fs, Options{Size: 32, PageSize: 8, CachePages: 2})
+	readFile := func() []byte {
+		t.Helper()
+		contents, err := hackpadfs.ReadFile(fs, "disk")
+		assert.NoError(t, err)
+		return contents
+	}
+
+	_, err := d.WriteAt([]byte("a"), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, make([]byte, 32), readFile())
+
+	// touching 2 more pages evicts the first, writing it
+	_, err = d.WriteAt([]byte("b"), 8)
+	assert.NoError(t, err)
+	_, err = d.WriteAt([]byte("c"), 16)
+	assert.NoError(t, err)
+	contents := readFile()
+	assert.Equal(t, []byte("a\x00"), contents[:2])
+	assert.Equal(t, byte(0), contents[8])
+
+	assert.NoError(t, d.Sync())
+	contents = readFile()
+	assert.Equal(t, []byte{'a', 'b', 'c'}, []byte{contents[0], contents[8], contents[16]})
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t)
+	d := openDevice(t, fs, Options{Size: 16, PageSize: 8, CachePages: 4})
+	_, err := d.WriteAt(bytes.Repeat([]byte("x"), 16), 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, d.Truncate(4))
+	assert.Equal(t, int64(4), d.Size())
+	assert.NoError(t, d.Truncate(12))
+	buf := make([]byte, 12)
+	n, err := d.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, n)
+	assert.Equal(t, "xxxx\x00\x00\x00\x00\x00\x00\x00\x00", string(buf))
+}
+
+func TestOpenExisting(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "disk", []byte("existing"), 0600))
+
+	d := openDevice(t, fs, Options{ReadOnly: true, CachePages: 1})
+	assert.Equal(t, int64(len("existing")), d.Size())
+	buf := make([]byte, 8)
+	_, err := d.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "existing", string(buf))
+	_, err = d.WriteAt([]byte("x"), 0)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, d.Truncate(0))
+
+	_, err = Open(fs, "disk", Options{ReadOnly: true, Size: 100})
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	_, err = Open(fs, "missing", Options{ReadOnly: true})
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
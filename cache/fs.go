@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/bufferpool"
 	"github.com/hack-pad/hackpadfs/internal/pathlock"
 )
 
@@ -97,14 +98,23 @@ func (fs *ReadOnlyFS) copyFile(name string, f hackpadfs.File, info hackpadfs.Fil
 	if err != nil {
 		return err
 	}
-	defer func() { _ = destFile.Close() }()
-
 	destFileWriter, ok := destFile.(io.Writer)
 	if !ok {
+		_ = destFile.Close()
 		return &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrPermission}
 	}
-	buf := make([]byte, 512)
-	_, err = io.CopyBuffer(destFileWriter, f, buf)
+	_, err = bufferpool.Copies.Copy(destFileWriter, f)
+	if closeErr := destFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// keep the source's modified time, so cached files match their directory entries
+	err = hackpadfs.Chtimes(fs.cacheFS, name, info.ModTime(), info.ModTime())
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		err = nil
+	}
 	return err
 }
 
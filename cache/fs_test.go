@@ -1,7 +1,9 @@
 package cache_test
 
 import (
+	"io"
 	"testing"
+	"time"
 
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/cache"
@@ -34,4 +36,60 @@ func TestFS(t *testing.T) {
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestReadOnlyFSReadDirBatches(t *testing.T) {
+	t.Parallel()
+	sourceFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(sourceFS, name, nil, 0600))
+	}
+	cacheFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := cache.NewReadOnlyFS(sourceFS, cacheFS, cache.ReadOnlyOptions{})
+	assert.NoError(t, err)
+
+	dir, err := fs.Open(".")
+	assert.NoError(t, err)
+	var names []string
+	for i := 0; i < 3; i++ { // stop early if EOF never comes
+		entries, err := hackpadfs.ReadDirFile(dir, 2)
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			break
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+	entries, err := hackpadfs.ReadDirFile(dir, -1) // reading everything after EOF returns no entries
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+	assert.NoError(t, dir.Close())
+}
+
+func TestReadOnlyFSKeepsModTime(t *testing.T) {
+	t.Parallel()
+	sourceFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	modTime := time.Now().Add(-time.Hour).Round(time.Second)
+	assert.NoError(t, hackpadfs.WriteFullFile(sourceFS, "file", []byte("file"), 0600))
+	assert.NoError(t, sourceFS.Chtimes("file", modTime, modTime))
+	cacheFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := cache.NewReadOnlyFS(sourceFS, cacheFS, cache.ReadOnlyOptions{})
+	assert.NoError(t, err)
+
+	f, err := fs.Open("file")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	info, err := hackpadfs.Stat(cacheFS, "file")
+	if assert.NoError(t, err) {
+		assert.Equal(t, modTime, info.ModTime())
+	}
 }
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	gofs "io/fs"
+	"path"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// PrefetchFS wraps a ReadOnlyFS, loading the files likely to be read next into its cache in the background each time a file is opened.
+// Useful when a program reads many small files in sequence from a slow source, like a toolchain loaded from a tar archive or S3.
+type PrefetchFS struct {
+	fs      *ReadOnlyFS
+	options PrefetchOptions
+
+	triggered sync.Map // triggered holds the names of opened files which already started a prefetch
+	fetched   sync.Map // fetched holds the names of files already opened or prefetched
+	workers   chan struct{}
+	wg        sync.WaitGroup
+}
+
+// PrefetchOptions contain options for creating a PrefetchFS
+type PrefetchOptions struct {
+	// Siblings prefetches the other files in an opened file's directory.
+	Siblings bool
+	// Rules prefetch files matching glob patterns when a matching file is opened.
+	Rules []PrefetchRule
+	// Workers is the number of files to prefetch at a time. Defaults to 4.
+	Workers int
+	// OnError is called with each error from prefetching a file.
+	OnError func(err error)
+}
+
+// PrefetchRule prefetches the files matching Fetch when a file matching Open is opened.
+// Patterns use the syntax of path.Match, and Fetch patterns are expanded like io/fs.Glob.
+type PrefetchRule struct {
+	Open  string
+	Fetch []string
+}
+
+// NewPrefetchFS returns a new PrefetchFS wrapping 'fs'
+func NewPrefetchFS(fs *ReadOnlyFS, options PrefetchOptions) (*PrefetchFS, error) {
+	if options.Workers <= 0 {
+		options.Workers = 4
+	}
+	for _, rule := range options.Rules {
+		for _, pattern := range append([]string{rule.Open}, rule.Fetch...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &PrefetchFS{
+		fs:      fs,
+		options: options,
+		workers: make(chan struct{}, options.Workers),
+	}, nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *PrefetchFS) Open(name string) (hackpadfs.File, error) {
+	f, err := fs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fs.fetched.Store(name, true)
+	if _, triggered := fs.triggered.LoadOrStore(name, true); !triggered {
+		fs.wg.Add(1)
+		go func() {
+			defer fs.wg.Done()
+			fs.prefetch(name)
+		}()
+	}
+	return f, nil
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *PrefetchFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return fs.fs.Stat(name)
+}
+
+// Wait blocks until all started prefetches are complete
+func (fs *PrefetchFS) Wait() {
+	fs.wg.Wait()
+}
+
+// prefetch loads the files related to the opened file 'name' into the cache
+func (fs *PrefetchFS) prefetch(name string) {
+	info, err := fs.fs.Stat(name)
+	if err != nil || info.IsDir() {
+		return
+	}
+	var names []string
+	if fs.options.Siblings {
+		entries, err := hackpadfs.ReadDir(fs.fs.sourceFS, path.Dir(name))
+		if err != nil {
+			fs.reportErr(err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				names = append(names, path.Join(path.Dir(name), entry.Name()))
+			}
+		}
+	}
+	for _, rule := range fs.options.Rules {
+		if match, _ := path.Match(rule.Open, name); !match {
+			continue
+		}
+		for _, pattern := range rule.Fetch {
+			matches, err := gofs.Glob(fs.fs.sourceFS, pattern)
+			if err != nil {
+				fs.reportErr(err)
+			}
+			names = append(names, matches...)
+		}
+	}
+	for _, name := range names {
+		if _, fetched := fs.fetched.LoadOrStore(name, true); fetched {
+			continue
+		}
+		fs.workers <- struct{}{}
+		fs.wg.Add(1)
+		go func(name string) {
+			defer fs.wg.Done()
+			defer func() { <-fs.workers }()
+			fs.fetch(name)
+		}(name)
+	}
+}
+
+// fetch loads the file 'name' into the cache, if its data is retained
+func (fs *PrefetchFS) fetch(name string) {
+	info, err := fs.fs.Stat(name)
+	if err != nil {
+		fs.reportErr(err)
+		return
+	}
+	if !info.Mode().IsRegular() || !fs.fs.options.RetainData(name, info) {
+		return
+	}
+	f, err := fs.fs.Open(name) // copies the file into the cache
+	if err != nil {
+		fs.reportErr(err)
+		return
+	}
+	_ = f.Close()
+}
+
+func (fs *PrefetchFS) reportErr(err error) {
+	if fs.options.OnError != nil {
+		fs.options.OnError(err)
+	}
+}
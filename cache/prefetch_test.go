@@ -0,0 +1,147 @@
+package cache_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/cache"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestPrefetchFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "cache prefetch",
+		Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+			sourceFS, err := mem.NewFS()
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			cacheFS, err := mem.NewFS()
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			readOnlyFS, err := cache.NewReadOnlyFS(sourceFS, cacheFS, cache.ReadOnlyOptions{})
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			fs, err := cache.NewPrefetchFS(readOnlyFS, cache.PrefetchOptions{Siblings: true})
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			tb.Cleanup(fs.Wait)
+			return sourceFS, func() hackpadfs.FS {
+				return fs
+			}
+		}),
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func newPrefetchFS(tb testing.TB, readOnlyOptions cache.ReadOnlyOptions, options cache.PrefetchOptions) (*cache.PrefetchFS, *mem.FS) {
+	tb.Helper()
+	sourceFS, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	for _, name := range []string{"bin/tool", "lib/a", "lib/b", "lib/sub/c", "other/x"} {
+		assert.NoError(tb, hackpadfs.MkdirAll(sourceFS, path.Dir(name), 0700))
+		assert.NoError(tb, hackpadfs.WriteFullFile(sourceFS, name, []byte(name), 0600))
+	}
+	cacheFS, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	readOnlyFS, err := cache.NewReadOnlyFS(sourceFS, cacheFS, readOnlyOptions)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	fs, err := cache.NewPrefetchFS(readOnlyFS, options)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs, cacheFS
+}
+
+func assertCached(tb testing.TB, cacheFS hackpadfs.FS, expected map[string]bool) {
+	tb.Helper()
+	for name, cached := range expected {
+		contents, err := hackpadfs.ReadFile(cacheFS, name)
+		if cached {
+			assert.NoError(tb, err)
+			assert.Equal(tb, name, string(contents))
+		} else {
+			assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		}
+	}
+}
+
+func TestPrefetchSiblings(t *testing.T) {
+	t.Parallel()
+	fs, cacheFS := newPrefetchFS(t, cache.ReadOnlyOptions{}, cache.PrefetchOptions{Siblings: true})
+	f, err := fs.Open("lib/a")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	fs.Wait()
+	assertCached(t, cacheFS, map[string]bool{
+		"lib/a":     true,
+		"lib/b":     true,
+		"lib/sub/c": false,
+		"bin/tool":  false,
+	})
+}
+
+func TestPrefetchRules(t *testing.T) {
+	t.Parallel()
+	fs, cacheFS := newPrefetchFS(t, cache.ReadOnlyOptions{}, cache.PrefetchOptions{
+		Rules: []cache.PrefetchRule{
+			{Open: "bin/*", Fetch: []string{"lib/*", "lib/*/*"}},
+			{Open: "other/*", Fetch: []string{"bin/*"}},
+		},
+	})
+	f, err := fs.Open("bin/tool")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	fs.Wait()
+	assertCached(t, cacheFS, map[string]bool{
+		"bin/tool":  true,
+		"lib/a":     true,
+		"lib/b":     true,
+		"lib/sub/c": true,
+		"other/x":   false,
+	})
+}
+
+func TestPrefetchRetainData(t *testing.T) {
+	t.Parallel()
+	fs, cacheFS := newPrefetchFS(t, cache.ReadOnlyOptions{
+		RetainData: func(name string, info hackpadfs.FileInfo) bool {
+			return name != "lib/b"
+		},
+	}, cache.PrefetchOptions{Siblings: true})
+	f, err := fs.Open("lib/a")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	fs.Wait()
+	assertCached(t, cacheFS, map[string]bool{
+		"lib/a": true,
+		"lib/b": false,
+	})
+}
+
+func TestPrefetchBadPattern(t *testing.T) {
+	t.Parallel()
+	sourceFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	readOnlyFS, err := cache.NewReadOnlyFS(sourceFS, sourceFS, cache.ReadOnlyOptions{})
+	assert.NoError(t, err)
+	_, err = cache.NewPrefetchFS(readOnlyFS, cache.PrefetchOptions{
+		Rules: []cache.PrefetchRule{{Open: "*", Fetch: []string{"["}}},
+	})
+	assert.ErrorIs(t, path.ErrBadPattern, err)
+}
@@ -0,0 +1,242 @@
+// Package casefold contains a file system wrapper which looks up paths case-insensitively, like the default file systems on Windows and macOS.
+package casefold
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+	} = &FS{}
+)
+
+// FS wraps a case-sensitive source FS, matching each path element case-insensitively against the existing files.
+// New files keep the case they were created with. Opening or creating a path which matches an existing file uses the existing file.
+//
+// If a directory contains several names which only differ by case, an exact match is used if one exists. Otherwise the first name in byte order is used.
+//
+// Directory listings are cached to speed up lookups. Changes made directly to the source FS, rather than through this FS, may not be seen until the cache is cleared with ClearCache().
+type FS struct {
+	sourceFS hackpadfs.FS
+
+	dirsMu      sync.Mutex
+	dirs        map[string]map[string][]string // dirs maps a source directory path to its folded names, then all source names matching that folded name, sorted
+	dirsVersion uint64                         // dirsVersion increments when cached listings are invalidated, so listings read before then aren't cached
+}
+
+// NewFS returns a new FS wrapping 'source'.
+func NewFS(source hackpadfs.FS) (*FS, error) {
+	return &FS{
+		sourceFS: source,
+		dirs:     make(map[string]map[string][]string),
+	}, nil
+}
+
+// fold returns a canonical form of 'name', such that fold(a) == fold(b) if and only if strings.EqualFold(a, b)
+func fold(name string) string {
+	return strings.Map(func(r rune) rune {
+		min := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < min {
+				min = f
+			}
+		}
+		return min
+	}, name)
+}
+
+// resolve returns the source path matching 'name'. Path elements which don't exist keep their case from 'name'.
+func (fs *FS) resolve(name string) string {
+	if name == "." || !hackpadfs.ValidPath(name) {
+		return name // let the source FS handle these
+	}
+	resolved := "."
+	remaining := name
+	for remaining != "" {
+		var elem string
+		elem, remaining, _ = strings.Cut(remaining, "/")
+		actual, ok := fs.lookup(resolved, elem)
+		if !ok {
+			return path.Join(resolved, elem, remaining)
+		}
+		resolved = path.Join(resolved, actual)
+	}
+	return resolved
+}
+
+// lookup returns the source name in 'dir' matching 'elem'
+func (fs *FS) lookup(dir, elem string) (string, bool) {
+	fs.dirsMu.Lock()
+	index, ok := fs.dirs[dir]
+	version := fs.dirsVersion
+	fs.dirsMu.Unlock()
+	if !ok {
+		// read the directory without holding dirsMu, so slow listings don't block lookups in other directories
+		entries, err := hackpadfs.ReadDir(fs.sourceFS, dir)
+		if err != nil {
+			return "", false
+		}
+		index = make(map[string][]string, len(entries))
+		for _, entry := range entries {
+			folded := fold(entry.Name())
+			index[folded] = append(index[folded], entry.Name())
+		}
+		for _, names := range index {
+			sort.Strings(names)
+		}
+		fs.dirsMu.Lock()
+		if fs.dirsVersion == version {
+			fs.dirs[dir] = index
+		}
+		fs.dirsMu.Unlock()
+	}
+	names := index[fold(elem)]
+	if len(names) == 0 {
+		return "", false
+	}
+	for _, name := range names {
+		if name == elem {
+			return name, true
+		}
+	}
+	return names[0], true
+}
+
+// invalidate removes the cached listing for the directory containing 'name', since 'name' was created or removed
+func (fs *FS) invalidate(name string) {
+	fs.dirsMu.Lock()
+	delete(fs.dirs, path.Dir(name))
+	fs.dirsVersion++
+	fs.dirsMu.Unlock()
+}
+
+// invalidateTree removes the cached listings for 'name', its parent directory, and everything inside it
+func (fs *FS) invalidateTree(name string) {
+	fs.dirsMu.Lock()
+	defer fs.dirsMu.Unlock()
+	delete(fs.dirs, path.Dir(name))
+	fs.dirsVersion++
+	prefix := name + "/"
+	for dir := range fs.dirs {
+		if dir == name || strings.HasPrefix(dir, prefix) {
+			delete(fs.dirs, dir)
+		}
+	}
+}
+
+// ClearCache discards all cached directory listings. Use it after changing the source FS directly.
+func (fs *FS) ClearCache() {
+	fs.dirsMu.Lock()
+	fs.dirs = make(map[string]map[string][]string)
+	fs.dirsVersion++
+	fs.dirsMu.Unlock()
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.sourceFS.Open(fs.resolve(name))
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	resolved := fs.resolve(name)
+	f, err := hackpadfs.OpenFile(fs.sourceFS, resolved, flag, perm)
+	if err == nil && flag&hackpadfs.FlagCreate != 0 {
+		fs.invalidate(resolved)
+	}
+	return f, err
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	resolved := fs.resolve(name)
+	err := hackpadfs.Mkdir(fs.sourceFS, resolved, perm)
+	if err == nil {
+		fs.invalidate(resolved)
+	}
+	return err
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(name string, perm hackpadfs.FileMode) error {
+	resolved := fs.resolve(name)
+	err := hackpadfs.MkdirAll(fs.sourceFS, resolved, perm)
+	if err == nil {
+		for dir := resolved; dir != "."; dir = path.Dir(dir) {
+			fs.invalidate(dir) // any missing parent may have been created
+		}
+	}
+	return err
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	resolved := fs.resolve(name)
+	err := hackpadfs.Remove(fs.sourceFS, resolved)
+	if err == nil {
+		fs.invalidateTree(resolved)
+	}
+	return err
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	resolved := fs.resolve(name)
+	err := hackpadfs.RemoveAll(fs.sourceFS, resolved)
+	fs.invalidateTree(resolved) // may have partially succeeded
+	return err
+}
+
+// Rename implements hackpadfs.RenameFS
+// Renaming a file to a name which only differs by case changes the file's case.
+func (fs *FS) Rename(oldname, newname string) error {
+	oldResolved := fs.resolve(oldname)
+	newResolved := fs.resolve(newname)
+	if newResolved == oldResolved && hackpadfs.ValidPath(newname) && newname != "." {
+		newResolved = path.Join(path.Dir(newResolved), path.Base(newname))
+	}
+	err := hackpadfs.Rename(fs.sourceFS, oldResolved, newResolved)
+	if err == nil {
+		fs.invalidateTree(oldResolved)
+		fs.invalidateTree(newResolved)
+	}
+	return err
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Stat(fs.sourceFS, fs.resolve(name))
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return hackpadfs.Chmod(fs.sourceFS, fs.resolve(name), mode)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return hackpadfs.Chtimes(fs.sourceFS, fs.resolve(name), atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDir(fs.sourceFS, fs.resolve(name))
+}
@@ -0,0 +1,223 @@
+package casefold
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newFS(tb testing.TB) (*FS, *mem.FS) {
+	tb.Helper()
+	source, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	fs, err := NewFS(source)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs, source
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "casefold",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, _ := newFS(tb)
+			return fs
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestFold(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		a, b string
+	}{
+		{"foo", "FOO"},
+		{"Straße", "STRAßE"},
+		{"k", "K"}, // Kelvin sign
+		{"ǅ", "ǆ"},
+	} {
+		assert.Equal(t, fold(tc.a), fold(tc.b))
+	}
+	assert.Equal(t, false, fold("foo") == fold("bar"))
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+	fs, _ := newFS(t)
+	assert.NoError(t, hackpadfs.MkdirAll(fs, "Foo/Bar", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "Foo/Bar/Baz.txt", []byte("baz"), 0600))
+
+	contents, err := hackpadfs.ReadFile(fs, "foo/BAR/baz.TXT")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(contents))
+
+	// writing to a different case reuses the existing file
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "FOO/bar/BAZ.txt", []byte("new baz"), 0600))
+	entries, err := hackpadfs.ReadDir(fs, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "Baz.txt", entries[0].Name())
+
+	info, err := hackpadfs.Stat(fs, "FOO")
+	assert.NoError(t, err)
+	assert.Equal(t, "Foo", info.Name())
+
+	_, err = hackpadfs.Stat(fs, "foo/missing/baz.txt")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestCollisions(t *testing.T) {
+	t.Parallel()
+	fs, source := newFS(t)
+	for _, name := range []string{"b", "B", "a", "A"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(source, name, []byte(name), 0600))
+	}
+
+	for _, tc := range []struct {
+		name   string
+		expect string
+	}{
+		{name: "a", expect: "a"},
+		{name: "A", expect: "A"},
+		{name: "b", expect: "b"},
+		{name: "B", expect: "B"},
+	} {
+		contents, err := hackpadfs.ReadFile(fs, tc.name)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expect, string(contents))
+	}
+
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "Cc", []byte("Cc"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "cC", []byte("cC"), 0600))
+	fs.ClearCache()
+	for i := 0; i < 10; i++ {
+		contents, err := hackpadfs.ReadFile(fs, "cc")
+		assert.NoError(t, err)
+		assert.Equal(t, "Cc", string(contents))
+	}
+}
+
+func TestRename(t *testing.T) {
+	t.Parallel()
+
+	t.Run("change case", func(t *testing.T) {
+		t.Parallel()
+		fs, source := newFS(t)
+		assert.NoError(t, hackpadfs.Mkdir(fs, "Dir", 0700))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "Dir/file", []byte("file"), 0600))
+
+		assert.NoError(t, hackpadfs.Rename(fs, "dir/FILE", "DIR/File"))
+		entries, err := hackpadfs.ReadDir(source, "Dir")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(entries))
+		assert.Equal(t, "File", entries[0].Name())
+
+		assert.NoError(t, hackpadfs.Rename(fs, "dir", "DIR"))
+		_, err = hackpadfs.Stat(source, "DIR/File")
+		assert.NoError(t, err)
+	})
+
+	t.Run("move", func(t *testing.T) {
+		t.Parallel()
+		fs, source := newFS(t)
+		assert.NoError(t, hackpadfs.MkdirAll(fs, "Old/Sub", 0700))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "Old/Sub/file", []byte("file"), 0600))
+		_, err := hackpadfs.Stat(fs, "old/sub/file")
+		assert.NoError(t, err)
+
+		assert.NoError(t, hackpadfs.Rename(fs, "OLD", "New"))
+		_, err = hackpadfs.Stat(fs, "old/sub/file")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		contents, err := hackpadfs.ReadFile(fs, "NEW/SUB/FILE")
+		assert.NoError(t, err)
+		assert.Equal(t, "file", string(contents))
+		_, err = hackpadfs.Stat(source, "New/Sub/file")
+		assert.NoError(t, err)
+	})
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	t.Parallel()
+	fs, _ := newFS(t)
+	_, err := hackpadfs.Stat(fs, "FILE")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "File", []byte("file"), 0600))
+	_, err = hackpadfs.Stat(fs, "FILE")
+	assert.NoError(t, err)
+
+	assert.NoError(t, hackpadfs.Remove(fs, "file"))
+	_, err = hackpadfs.Stat(fs, "File")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	assert.NoError(t, hackpadfs.MkdirAll(fs, "A/B/C", 0700))
+	_, err = hackpadfs.Stat(fs, "a/b/c")
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.RemoveAll(fs, "a"))
+	_, err = hackpadfs.Stat(fs, "A")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	assert.NoError(t, hackpadfs.MkdirAll(fs, "a/b", 0700))
+	_, err = hackpadfs.Stat(fs, "A/B")
+	assert.NoError(t, err)
+}
+
+// slowReadDirFS blocks the first read of the directory "slow" until 'release' is closed
+type slowReadDirFS struct {
+	*mem.FS
+	mu      sync.Mutex
+	reads   int
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (fs *slowReadDirFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	entries, err := hackpadfs.ReadDir(fs.FS, name)
+	if name == "slow" {
+		fs.mu.Lock()
+		fs.reads++
+		first := fs.reads == 1
+		fs.mu.Unlock()
+		if first {
+			close(fs.reading)
+			<-fs.release
+		}
+	}
+	return entries, err
+}
+
+func TestLookupDoesNotBlockOtherDirs(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(memFS, "slow", 0700))
+	assert.NoError(t, hackpadfs.Mkdir(memFS, "fast", 0700))
+	source := &slowReadDirFS{FS: memFS, reading: make(chan struct{}), release: make(chan struct{})}
+	fs, err := NewFS(source)
+	assert.NoError(t, err)
+
+	slowErr := make(chan error)
+	go func() {
+		_, err := hackpadfs.Stat(fs, "SLOW/file")
+		slowErr <- err
+	}()
+	<-source.reading
+	_, err = hackpadfs.Stat(fs, "FAST")
+	assert.NoError(t, err)
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "slow/File", nil, 0600)) // invalidates the listing being read
+	close(source.release)
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, <-slowErr)
+	_, err = hackpadfs.Stat(fs, "slow/FILE")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,148 @@
+// Command hackpadfs inspects and modifies any supported file system from the command line.
+//
+// Usage:
+//
+//	hackpadfs ls LOCATION
+//	hackpadfs tree LOCATION
+//	hackpadfs cat LOCATION...
+//	hackpadfs cp SRC_LOCATION DEST_LOCATION
+//	hackpadfs rm [-r] LOCATION...
+//	hackpadfs du LOCATION
+//	hackpadfs sync [-n] LOCATION_A LOCATION_B
+//
+// A location is a file system URL, optionally followed by '#' and a path inside it, like "os:/tmp#foo/bar". See fsutil.OpenLocation().
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fsutil"
+	_ "github.com/hack-pad/hackpadfs/mem" // registers "mem:" URLs
+	_ "github.com/hack-pad/hackpadfs/os"  // registers "os:" URLs
+)
+
+const usage = `Usage: hackpadfs COMMAND [OPTIONS] LOCATION...
+
+Commands:
+  ls LOCATION                   list a directory
+  tree LOCATION                 print a file tree
+  cat LOCATION...               print file contents
+  cp SRC_LOCATION DEST_LOCATION copy a file or directory tree
+  rm [-r] LOCATION...           remove files, or directory trees with -r
+  du LOCATION                   print the total size of a file tree
+  sync [-n] LOCATION_A LOCATION_B
+                                sync two file trees, or print the plan with -n
+
+A location is a file system URL, optionally followed by '#' and a path inside it, like os:/tmp#foo/bar.
+`
+
+// errUsage indicates the command line arguments are invalid
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command in 'args' and returns its exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	err := runCommand(args, stdout, stderr)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		fmt.Fprint(stderr, usage)
+		return 2
+	default:
+		fmt.Fprintln(stderr, "hackpadfs:", err)
+		return 1
+	}
+}
+
+func runCommand(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	command, args := args[0], args[1:]
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var recursive, dryRun bool
+	switch command {
+	case "rm":
+		flags.BoolVar(&recursive, "r", false, "remove directories and their contents")
+	case "sync":
+		flags.BoolVar(&dryRun, "n", false, "print planned actions without making changes")
+	}
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	args = flags.Args()
+
+	switch command {
+	case "ls", "tree", "du":
+		if len(args) != 1 {
+			return errUsage
+		}
+		fs, name, err := fsutil.OpenLocation(args[0])
+		if err != nil {
+			return err
+		}
+		switch command {
+		case "ls":
+			return fsutil.List(stdout, fs, name)
+		case "tree":
+			return fsutil.Tree(stdout, fs, name)
+		default:
+			return fsutil.DiskUsage(stdout, fs, name)
+		}
+	case "cat", "rm":
+		if len(args) == 0 {
+			return errUsage
+		}
+		for _, location := range args {
+			fs, name, err := fsutil.OpenLocation(location)
+			if err == nil {
+				if command == "cat" {
+					err = fsutil.Cat(stdout, fs, name)
+				} else {
+					err = fsutil.Remove(fs, name, recursive)
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "cp", "sync":
+		if len(args) != 2 {
+			return errUsage
+		}
+		fsA, nameA, err := fsutil.OpenLocation(args[0])
+		if err != nil {
+			return err
+		}
+		fsB, nameB, err := fsutil.OpenLocation(args[1])
+		if err != nil {
+			return err
+		}
+		if command == "cp" {
+			return fsutil.Copy(fsB, nameB, fsA, nameA)
+		}
+		subA, err := hackpadfs.Sub(fsA, nameA)
+		if err != nil {
+			return err
+		}
+		subB, err := hackpadfs.Sub(fsB, nameB)
+		if err != nil {
+			return err
+		}
+		return fsutil.Sync(context.Background(), stdout, subA, subB, dryRun)
+	default:
+		return errUsage
+	}
+}
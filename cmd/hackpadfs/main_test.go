@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func runTest(t *testing.T, args ...string) (exitCode int, stdout, stderr string) {
+	t.Helper()
+	var stdoutBuf, stderrBuf bytes.Buffer
+	exitCode = run(args, &stdoutBuf, &stderrBuf)
+	return exitCode, stdoutBuf.String(), stderrBuf.String()
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "src", "sub"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "src", "sub", "foo"), []byte("foo"), 0600))
+	location := "os:" + filepath.ToSlash(dir)
+
+	exitCode, stdout, _ := runTest(t, "cat", location+"#src/sub/foo")
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "foo", stdout)
+
+	exitCode, stdout, _ = runTest(t, "tree", location+"#src")
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "src/\n└── sub/\n    └── foo\n", stdout)
+
+	exitCode, _, _ = runTest(t, "cp", location+"#src", location+"#dest")
+	assert.Equal(t, 0, exitCode)
+	contents, err := os.ReadFile(filepath.Join(dir, "dest", "sub", "foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(contents))
+
+	exitCode, stdout, _ = runTest(t, "ls", location)
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, true, strings.Contains(stdout, " dest/\n"))
+
+	exitCode, stdout, _ = runTest(t, "du", location+"#dest")
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, true, strings.HasPrefix(stdout, "3 bytes\t1 files\t2 dirs\t"))
+
+	exitCode, _, stderr := runTest(t, "rm", location+"#dest")
+	assert.Equal(t, 1, exitCode)
+	assert.Equal(t, true, strings.HasPrefix(stderr, "hackpadfs: "))
+	exitCode, _, _ = runTest(t, "rm", "-r", location+"#dest")
+	assert.Equal(t, 0, exitCode)
+	_, err = os.Stat(filepath.Join(dir, "dest"))
+	assert.Equal(t, true, os.IsNotExist(err))
+
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "mirror"), 0700))
+	exitCode, stdout, _ = runTest(t, "sync", "-n", location+"#src", location+"#mirror")
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, true, strings.Contains(stdout, "copy sub/foo on B\n"))
+	_, err = os.Stat(filepath.Join(dir, "mirror", "sub"))
+	assert.Equal(t, true, os.IsNotExist(err))
+}
+
+func TestRunUsage(t *testing.T) {
+	t.Parallel()
+	for _, args := range [][]string{
+		nil,
+		{"unknown"},
+		{"ls"},
+		{"cp", "mem:"},
+		{"ls", "-r", "mem:"},
+	} {
+		exitCode, _, stderr := runTest(t, args...)
+		assert.Equal(t, 2, exitCode)
+		assert.Equal(t, true, strings.Contains(stderr, "Usage: hackpadfs"))
+	}
+}
@@ -0,0 +1,286 @@
+package hackpadfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// sniffLen is the maximum number of bytes used to detect a content type, the same as net/http
+const sniffLen = 512
+
+// DetectContentType returns the MIME type of the file 'name', like "text/html; charset=utf-8".
+// See DetectContentTypeFile() for details.
+func DetectContentType(fs FS, name string) (string, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+	return DetectContentTypeFile(file)
+}
+
+// DetectContentTypeFile returns the MIME type of 'file', sniffed from its first 512 bytes with the same algorithm as net/http's DetectContentType().
+// Always returns a valid MIME type, falling back to "application/octet-stream".
+//
+// Reads with ReadAt() if 'file' supports it, so an open file's offset is left unchanged.
+// Otherwise, reads from the current offset and then seeks back to the beginning of 'file', if possible.
+func DetectContentTypeFile(file File) (string, error) {
+	buf := make([]byte, sniffLen)
+	n, err := ReadAtFile(file, buf, 0)
+	if errors.Is(err, ErrNotImplemented) {
+		n, err = io.ReadFull(file, buf)
+		if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+			_, err = SeekFile(file, 0, io.SeekStart)
+			if errors.Is(err, ErrNotImplemented) {
+				err = nil
+			}
+		}
+	} else if err == io.EOF {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return sniffContentType(buf[:n]), nil
+}
+
+// sniffContentType implements the algorithm described at https://mimesniff.spec.whatwg.org/, matching net/http's DetectContentType()
+func sniffContentType(data []byte) string {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	firstNonWS := 0
+	for ; firstNonWS < len(data) && isWhitespace(data[firstNonWS]); firstNonWS++ {
+	}
+	for _, sig := range sniffSignatures {
+		if contentType := sig.match(data, firstNonWS); contentType != "" {
+			return contentType
+		}
+	}
+	return "application/octet-stream"
+}
+
+func isWhitespace(b byte) bool {
+	switch b {
+	case '\t', '\n', '\x0c', '\r', ' ':
+		return true
+	default:
+		return false
+	}
+}
+
+type sniffSignature interface {
+	// match returns the MIME type of 'data' if it matches, an empty string otherwise
+	match(data []byte, firstNonWS int) string
+}
+
+// sniffSignatures are checked in order, so more specific signatures must come first
+var sniffSignatures = []sniffSignature{
+	htmlSig("<!DOCTYPE HTML"),
+	htmlSig("<HTML"),
+	htmlSig("<HEAD"),
+	htmlSig("<SCRIPT"),
+	htmlSig("<IFRAME"),
+	htmlSig("<H1"),
+	htmlSig("<DIV"),
+	htmlSig("<FONT"),
+	htmlSig("<TABLE"),
+	htmlSig("<A"),
+	htmlSig("<STYLE"),
+	htmlSig("<TITLE"),
+	htmlSig("<B"),
+	htmlSig("<BODY"),
+	htmlSig("<BR"),
+	htmlSig("<P"),
+	htmlSig("<!--"),
+	&maskedSig{
+		mask:        []byte("\xFF\xFF\xFF\xFF\xFF"),
+		pattern:     []byte("<?xml"),
+		skipWS:      true,
+		contentType: "text/xml; charset=utf-8",
+	},
+	&exactSig{[]byte("%PDF-"), "application/pdf"},
+	&exactSig{[]byte("%!PS-Adobe-"), "application/postscript"},
+
+	// byte order marks
+	&maskedSig{
+		mask:        []byte("\xFF\xFF\x00\x00"),
+		pattern:     []byte("\xFE\xFF\x00\x00"),
+		contentType: "text/plain; charset=utf-16be",
+	},
+	&maskedSig{
+		mask:        []byte("\xFF\xFF\x00\x00"),
+		pattern:     []byte("\xFF\xFE\x00\x00"),
+		contentType: "text/plain; charset=utf-16le",
+	},
+	&exactSig{[]byte("\xEF\xBB\xBF"), "text/plain; charset=utf-8"},
+
+	// images
+	&exactSig{[]byte("\x00\x00\x01\x00"), "image/x-icon"},
+	&exactSig{[]byte("\x00\x00\x02\x00"), "image/x-icon"},
+	&exactSig{[]byte("BM"), "image/bmp"},
+	&exactSig{[]byte("GIF87a"), "image/gif"},
+	&exactSig{[]byte("GIF89a"), "image/gif"},
+	&maskedSig{
+		mask:        []byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF\xFF\xFF"),
+		pattern:     []byte("RIFF\x00\x00\x00\x00WEBPVP"),
+		contentType: "image/webp",
+	},
+	&exactSig{[]byte("\x89PNG\x0D\x0A\x1A\x0A"), "image/png"},
+	&exactSig{[]byte("\xFF\xD8\xFF"), "image/jpeg"},
+
+	// audio and video
+	&maskedSig{
+		mask:        []byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF"),
+		pattern:     []byte("FORM\x00\x00\x00\x00AIFF"),
+		contentType: "audio/aiff",
+	},
+	&maskedSig{
+		mask:        []byte("\xFF\xFF\xFF"),
+		pattern:     []byte("ID3"),
+		contentType: "audio/mpeg",
+	},
+	&maskedSig{
+		mask:        []byte("\xFF\xFF\xFF\xFF\xFF"),
+		pattern:     []byte("OggS\x00"),
+		contentType: "application/ogg",
+	},
+	&maskedSig{
+		mask:        []byte("\xFF\xFF\xFF\xFF\xFF\xFF\xFF\xFF"),
+		pattern:     []byte("MThd\x00\x00\x00\x06"),
+		contentType: "audio/midi",
+	},
+	&maskedSig{
+		mask:        []byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF"),
+		pattern:     []byte("RIFF\x00\x00\x00\x00AVI "),
+		contentType: "video/avi",
+	},
+	&maskedSig{
+		mask:        []byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF"),
+		pattern:     []byte("RIFF\x00\x00\x00\x00WAVE"),
+		contentType: "audio/wave",
+	},
+	mp4Sig{},
+	&exactSig{[]byte("\x1A\x45\xDF\xA3"), "video/webm"},
+
+	// fonts
+	&maskedSig{
+		// 34 bytes of anything, followed by "LP"
+		mask:        []byte("\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\xFF\xFF"),
+		pattern:     []byte("\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00LP"),
+		contentType: "application/vnd.ms-fontobject",
+	},
+	&exactSig{[]byte("\x00\x01\x00\x00"), "font/ttf"},
+	&exactSig{[]byte("OTTO"), "font/otf"},
+	&exactSig{[]byte("ttcf"), "font/collection"},
+	&exactSig{[]byte("wOFF"), "font/woff"},
+	&exactSig{[]byte("wOF2"), "font/woff2"},
+
+	// archives
+	&exactSig{[]byte("\x1F\x8B\x08"), "application/x-gzip"},
+	&exactSig{[]byte("PK\x03\x04"), "application/zip"},
+	&exactSig{[]byte("Rar!\x1A\x07\x00"), "application/x-rar-compressed"},
+	&exactSig{[]byte("Rar!\x1A\x07\x01\x00"), "application/x-rar-compressed"},
+	&exactSig{[]byte("\x00\x61\x73\x6D"), "application/wasm"},
+
+	textSig{}, // must be last
+}
+
+type exactSig struct {
+	sig         []byte
+	contentType string
+}
+
+func (e *exactSig) match(data []byte, firstNonWS int) string {
+	if bytes.HasPrefix(data, e.sig) {
+		return e.contentType
+	}
+	return ""
+}
+
+type maskedSig struct {
+	mask, pattern []byte
+	skipWS        bool
+	contentType   string
+}
+
+func (m *maskedSig) match(data []byte, firstNonWS int) string {
+	if m.skipWS {
+		data = data[firstNonWS:]
+	}
+	if len(m.pattern) != len(m.mask) || len(data) < len(m.pattern) {
+		return ""
+	}
+	for i, patternByte := range m.pattern {
+		if data[i]&m.mask[i] != patternByte {
+			return ""
+		}
+	}
+	return m.contentType
+}
+
+// htmlSig matches a case-insensitive HTML tag, followed by a space or '>'. The signature must be upper case.
+type htmlSig []byte
+
+func (h htmlSig) match(data []byte, firstNonWS int) string {
+	data = data[firstNonWS:]
+	if len(data) < len(h)+1 {
+		return ""
+	}
+	for i, sigByte := range h {
+		dataByte := data[i]
+		if 'A' <= sigByte && sigByte <= 'Z' {
+			dataByte &= 0xDF // upper case
+		}
+		if sigByte != dataByte {
+			return ""
+		}
+	}
+	if terminator := data[len(h)]; terminator != ' ' && terminator != '>' {
+		return ""
+	}
+	return "text/html; charset=utf-8"
+}
+
+// mp4Sig matches an ISO base media file's "ftyp" box with an "mp4" brand
+type mp4Sig struct{}
+
+func (mp4Sig) match(data []byte, firstNonWS int) string {
+	if len(data) < 12 {
+		return ""
+	}
+	boxSize := int(binary.BigEndian.Uint32(data[:4]))
+	if len(data) < boxSize || boxSize%4 != 0 {
+		return ""
+	}
+	if !bytes.Equal(data[4:8], []byte("ftyp")) {
+		return ""
+	}
+	for brand := 8; brand < boxSize; brand += 4 {
+		if brand == 12 {
+			continue // skip the minor version
+		}
+		if bytes.Equal(data[brand:brand+3], []byte("mp4")) {
+			return "video/mp4"
+		}
+	}
+	return ""
+}
+
+// textSig matches data without any binary control characters
+type textSig struct{}
+
+func (textSig) match(data []byte, firstNonWS int) string {
+	for _, b := range data[firstNonWS:] {
+		switch {
+		case b <= 0x08,
+			b == 0x0B,
+			0x0E <= b && b <= 0x1A,
+			0x1C <= b && b <= 0x1F:
+			return ""
+		}
+	}
+	return "text/plain; charset=utf-8"
+}
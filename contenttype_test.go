@@ -0,0 +1,94 @@
+package hackpadfs_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestDetectContentType(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		contents    string
+		expected    string
+	}{
+		{description: "empty", contents: "", expected: "text/plain; charset=utf-8"},
+		{description: "text", contents: "hello world", expected: "text/plain; charset=utf-8"},
+		{description: "binary", contents: "\x00\x01\x02", expected: "application/octet-stream"},
+		{description: "html", contents: "\n  <!doctype html><html>", expected: "text/html; charset=utf-8"},
+		{description: "html tag needs terminator", contents: "<pre>", expected: "text/plain; charset=utf-8"},
+		{description: "html comment", contents: "<!-- hi -->", expected: "text/html; charset=utf-8"},
+		{description: "xml", contents: "  <?xml version=\"1.0\"?>", expected: "text/xml; charset=utf-8"},
+		{description: "pdf", contents: "%PDF-1.7", expected: "application/pdf"},
+		{description: "utf-16be", contents: "\xFE\xFF\x00h", expected: "text/plain; charset=utf-16be"},
+		{description: "utf-8 bom", contents: "\xEF\xBB\xBFhi", expected: "text/plain; charset=utf-8"},
+		{description: "png", contents: "\x89PNG\x0D\x0A\x1A\x0A\x00\x00", expected: "image/png"},
+		{description: "jpeg", contents: "\xFF\xD8\xFF\xE0", expected: "image/jpeg"},
+		{description: "webp", contents: "RIFF\x10\x00\x00\x00WEBPVP8 ", expected: "image/webp"},
+		{description: "wave", contents: "RIFF\x10\x00\x00\x00WAVEfmt ", expected: "audio/wave"},
+		{description: "mp4", contents: "\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isommp41", expected: "video/mp4"},
+		{description: "font object", contents: strings.Repeat("\x01", 34) + "LP", expected: "application/vnd.ms-fontobject"},
+		{description: "gzip", contents: "\x1F\x8B\x08\x00", expected: "application/x-gzip"},
+		{description: "zip", contents: "PK\x03\x04", expected: "application/zip"},
+		{description: "wasm", contents: "\x00asm\x01\x00\x00\x00", expected: "application/wasm"},
+		{description: "binary after sniff length", contents: strings.Repeat("a", 512) + "\x00", expected: "text/plain; charset=utf-8"},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			fs, err := mem.NewFS()
+			requireNoError(t, err)
+			requireNoError(t, hackpadfs.WriteFullFile(fs, "file", []byte(tc.contents), 0600))
+
+			contentType, err := hackpadfs.DetectContentType(fs, "file")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, contentType)
+			assert.Equal(t, http.DetectContentType([]byte(tc.contents)), contentType)
+		})
+	}
+}
+
+func TestDetectContentTypeFile(t *testing.T) {
+	t.Parallel()
+	const contents = "<html></html>"
+	fs, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "file", []byte(contents), 0600))
+
+	t.Run("read at", func(t *testing.T) {
+		t.Parallel()
+		file, err := fs.Open("file")
+		requireNoError(t, err)
+		defer func() { _ = file.Close() }()
+
+		contentType, err := hackpadfs.DetectContentTypeFile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "text/html; charset=utf-8", contentType)
+		b, err := io.ReadAll(file)
+		assert.NoError(t, err)
+		assert.Equal(t, contents, string(b))
+	})
+
+	t.Run("read only", func(t *testing.T) {
+		t.Parallel()
+		file, err := fs.Open("file")
+		requireNoError(t, err)
+		defer func() { _ = file.Close() }()
+
+		contentType, err := hackpadfs.DetectContentTypeFile(struct{ hackpadfs.File }{file})
+		assert.NoError(t, err)
+		assert.Equal(t, "text/html; charset=utf-8", contentType)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		t.Parallel()
+		_, err := hackpadfs.DetectContentType(fs, "missing")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+}
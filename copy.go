@@ -0,0 +1,127 @@
+package hackpadfs
+
+import (
+	"errors"
+	"io"
+	"path"
+	"time"
+
+	"github.com/hack-pad/hackpadfs/internal/bufferpool"
+	"github.com/hack-pad/hackpadfs/internal/fspath"
+)
+
+// CopyFile copies the contents and permissions of the regular file 'srcName' in 'src' to 'destName' in 'dest', replacing any existing file.
+//
+// Contents are copied like io.Copy(), so files implementing io.WriterTo or io.ReaderFrom can optimize the transfer. Otherwise, a buffer is borrowed from a pool shared by all copies, which caps memory use when many copies run at once.
+// For example, keyvalue files hand their contents to another keyvalue file as a single blob, without converting to a byte slice,
+// and os.FS files copy between each other inside the kernel when the OS supports it, e.g. with copy_file_range or sendfile on Linux.
+func CopyFile(dest FS, destName string, src FS, srcName string) error {
+	srcFile, err := src.Open(srcName)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = srcFile.Close() }()
+	info, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return &PathError{Op: "copy", Path: srcName, Err: ErrInvalid}
+	}
+
+	perm := info.Mode().Perm()
+	destFile, err := OpenFile(dest, destName, FlagWriteOnly|FlagCreate|FlagTruncate, perm)
+	if err != nil {
+		return err
+	}
+	if writer, ok := destFile.(io.Writer); ok {
+		_, err = bufferpool.Copies.Copy(writer, srcFile)
+	} else {
+		err = &PathError{Op: "write", Path: destName, Err: ErrNotImplemented}
+	}
+	closeErr := destFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// an existing file keeps its old permissions when truncated
+	return ignoreNotImplemented(Chmod(dest, destName, perm))
+}
+
+// CopyOptions contain options for CopyFSWithOptions()
+type CopyOptions struct {
+	// PreserveTimes copies modified times to the destination with Chtimes(), if supported. Access times are set to the modified time.
+	PreserveTimes bool
+	// Filter skips copying the paths in 'src' it returns false for. Skipped directories aren't created or walked. See WalkDirOptions.
+	Filter Filter
+}
+
+// CopyFS recursively copies the file tree rooted at 'srcRoot' in 'src' to 'destRoot' in 'dest'.
+// Directories are created as needed with the same permissions and regular files are copied with CopyFile(). Other file types, like symlinks, are skipped.
+func CopyFS(dest FS, destRoot string, src FS, srcRoot string) error {
+	return CopyFSWithOptions(dest, destRoot, src, srcRoot, CopyOptions{})
+}
+
+// CopyFSWithOptions is like CopyFS(), but with additional options. See CopyOptions for details.
+func CopyFSWithOptions(dest FS, destRoot string, src FS, srcRoot string, options CopyOptions) error {
+	type copiedDir struct {
+		name    string
+		modTime time.Time
+	}
+	var dirs []copiedDir
+	err := WalkDirWithOptions(src, srcRoot, func(name string, dirEntry DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		destName := path.Join(destRoot, fspath.Rel(srcRoot, name))
+		switch {
+		case dirEntry.IsDir():
+			info, err := dirEntry.Info()
+			if err != nil {
+				return err
+			}
+			err = MkdirAll(dest, destName, info.Mode().Perm())
+			if err != nil && !errors.Is(err, ErrExist) {
+				return err
+			}
+			dirs = append(dirs, copiedDir{name: destName, modTime: info.ModTime()})
+			return nil
+		case dirEntry.Type().IsRegular():
+			err := CopyFile(dest, destName, src, name)
+			if err == nil && options.PreserveTimes {
+				var info FileInfo
+				info, err = dirEntry.Info()
+				if err == nil {
+					err = ignoreNotImplemented(Chtimes(dest, destName, info.ModTime(), info.ModTime()))
+				}
+			}
+			return err
+		default:
+			return nil
+		}
+	}, WalkDirOptions{Filter: options.Filter})
+	if err != nil {
+		return err
+	}
+
+	if !options.PreserveTimes {
+		return nil
+	}
+	// update directories last, since copying into a directory changes its modified time
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+		if err := ignoreNotImplemented(Chtimes(dest, dir.name, dir.modTime, dir.modTime)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ignoreNotImplemented(err error) error {
+	if errors.Is(err, ErrNotImplemented) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package hackpadfs_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestCopyFS(t *testing.T) {
+	t.Parallel()
+	src, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, src.MkdirAll("foo/bar", 0750))
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "foo/baz", []byte("baz"), 0640))
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "foo/bar/biff", []byte("biff"), 0600))
+
+	dest, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(dest, "baz", []byte("old contents"), 0666))
+	assert.NoError(t, hackpadfs.CopyFS(dest, ".", src, "foo"))
+
+	contents, err := hackpadfs.ReadFile(dest, "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(contents))
+	info, err := hackpadfs.Stat(dest, "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0640), info.Mode())
+	contents, err = hackpadfs.ReadFile(dest, "bar/biff")
+	assert.NoError(t, err)
+	assert.Equal(t, "biff", string(contents))
+	info, err = hackpadfs.Stat(dest, "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.ModeDir|0750, info.Mode())
+}
+
+func TestCopyFileIndependent(t *testing.T) {
+	t.Parallel()
+	src, err := mem.NewFS()
+	assert.NoError(t, err)
+	dest, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "foo", []byte("foo"), 0600))
+	assert.NoError(t, hackpadfs.CopyFile(dest, "bar", src, "foo"))
+
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "foo", []byte("changed"), 0600))
+	contents, err := hackpadfs.ReadFile(dest, "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(contents))
+}
+
+func TestCopyFileNotRegular(t *testing.T) {
+	t.Parallel()
+	src, err := mem.NewFS()
+	assert.NoError(t, err)
+	dest, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, src.Mkdir("foo", 0700))
+	err = hackpadfs.CopyFile(dest, "foo", src, "foo")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
+
+func TestCopyFSPreserveTimes(t *testing.T) {
+	t.Parallel()
+	src, err := mem.NewFS()
+	assert.NoError(t, err)
+	modTime := time.Now().Add(-time.Hour).Round(time.Second)
+	assert.NoError(t, src.MkdirAll("foo/bar", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "foo/bar/baz", []byte("baz"), 0600))
+	for _, name := range []string{"foo/bar/baz", "foo/bar", "foo"} {
+		assert.NoError(t, src.Chtimes(name, modTime, modTime))
+	}
+
+	dest, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.CopyFSWithOptions(dest, "copy", src, "foo", hackpadfs.CopyOptions{PreserveTimes: true}))
+	for _, name := range []string{"copy/bar/baz", "copy/bar", "copy"} {
+		info, err := hackpadfs.Stat(dest, name)
+		assert.NoError(t, err)
+		assert.Equal(t, modTime, info.ModTime())
+	}
+
+	assert.NoError(t, hackpadfs.CopyFS(dest, "nopreserve", src, "foo"))
+	info, err := hackpadfs.Stat(dest, "nopreserve/bar/baz")
+	assert.NoError(t, err)
+	assert.NotEqual(t, modTime, info.ModTime())
+}
+
+func TestCopyFSFilter(t *testing.T) {
+	t.Parallel()
+	src, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, src.MkdirAll("foo/skip", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "foo/skip/bar", []byte("bar"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "foo/baz", []byte("baz"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "foo/baz.tmp", []byte("baz"), 0600))
+
+	dest, err := mem.NewFS()
+	assert.NoError(t, err)
+	var filtered []string
+	assert.NoError(t, hackpadfs.CopyFSWithOptions(dest, ".", src, "foo", hackpadfs.CopyOptions{
+		Filter: func(name string, dirEntry hackpadfs.DirEntry) (bool, error) {
+			filtered = append(filtered, name)
+			return name != "foo/skip" && !strings.HasSuffix(name, ".tmp"), nil
+		},
+	}))
+	assert.Equal(t, []string{"foo", "foo/baz", "foo/baz.tmp", "foo/skip"}, filtered)
+	entries, err := hackpadfs.ReadDir(dest, ".")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "baz", entries[0].Name())
+	}
+
+	filterErr := errors.New("some error")
+	err = hackpadfs.CopyFSWithOptions(dest, ".", src, "foo", hackpadfs.CopyOptions{
+		Filter: func(string, hackpadfs.DirEntry) (bool, error) {
+			return false, filterErr
+		},
+	})
+	assert.ErrorIs(t, filterErr, err)
+}
@@ -0,0 +1,89 @@
+package devfs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// charDevice is the default mode of a Device
+const charDevice = hackpadfs.ModeDevice | hackpadfs.ModeCharDevice | 0666
+
+// Device is a virtual file with behavior implemented by callbacks.
+type Device struct {
+	// Mode is the file's type and permission bits. Defaults to a character device readable and writable by everyone, like /dev/null.
+	Mode hackpadfs.FileMode
+	// Open returns a new handle each time the Device is opened. Required.
+	// Reads and writes to the opened file are passed to the handle. If the handle is also an io.Seeker or io.Closer, seeks and closes are passed to it too.
+	Open func() (io.ReadWriter, error)
+}
+
+// discard reads nothing with a Reader and discards every write
+type discard struct {
+	io.Reader
+}
+
+func (d discard) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// Null returns a Device like /dev/null. Reads return io.EOF and writes are discarded.
+func Null() Device {
+	return Device{
+		Open: func() (io.ReadWriter, error) {
+			return discard{Reader: bytes.NewReader(nil)}, nil
+		},
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// Zero returns a Device like /dev/zero. Reads return zeros and writes are discarded.
+func Zero() Device {
+	return Device{
+		Open: func() (io.ReadWriter, error) {
+			return discard{Reader: zeroReader{}}, nil
+		},
+	}
+}
+
+// Random returns a Device like /dev/random. Reads return cryptographically secure random bytes and writes are discarded.
+func Random() Device {
+	return Device{
+		Open: func() (io.ReadWriter, error) {
+			return discard{Reader: rand.Reader}, nil
+		},
+	}
+}
+
+// readOnly fails every write with a permission error
+type readOnly struct {
+	io.ReadSeeker
+}
+
+func (readOnly) Write(p []byte) (int, error) {
+	return 0, hackpadfs.ErrPermission
+}
+
+// Contents returns a read-only Device which calls 'contents' each time it's opened, like the files in /proc. Reads return the contents from the time it was opened.
+func Contents(contents func() ([]byte, error)) Device {
+	return Device{
+		Mode: 0444,
+		Open: func() (io.ReadWriter, error) {
+			b, err := contents()
+			if err != nil {
+				return nil, err
+			}
+			return readOnly{ReadSeeker: bytes.NewReader(b)}, nil
+		},
+	}
+}
@@ -0,0 +1,143 @@
+package devfs
+
+import (
+	"io"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+type fileInfo struct {
+	name    string
+	mode    hackpadfs.FileMode
+	modTime time.Time
+}
+
+func (f *fileInfo) Name() string {
+	return f.name
+}
+
+func (f *fileInfo) Size() int64 {
+	return 0
+}
+
+func (f *fileInfo) Mode() hackpadfs.FileMode {
+	return f.mode
+}
+
+func (f *fileInfo) ModTime() time.Time {
+	return f.modTime
+}
+
+func (f *fileInfo) IsDir() bool {
+	return f.mode.IsDir()
+}
+
+func (f *fileInfo) Sys() interface{} {
+	return nil
+}
+
+var (
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.SeekerFile
+	} = &file{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.DirReaderFile
+	} = &dir{}
+)
+
+// file is an open Device, passing reads and writes to the Device's handle
+type file struct {
+	name   string
+	info   hackpadfs.FileInfo
+	flag   int
+	handle io.ReadWriter
+}
+
+// wrapErr returns 'err' as a PathError, except for io.EOF which callers compare directly
+func (f *file) wrapErr(op string, err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return &hackpadfs.PathError{Op: op, Path: f.name, Err: err}
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if !hackpadfs.ParseFlags(f.flag).Read {
+		return 0, &hackpadfs.PathError{Op: "read", Path: f.name, Err: hackpadfs.ErrPermission}
+	}
+	n, err := f.handle.Read(p)
+	return n, f.wrapErr("read", err)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if !hackpadfs.ParseFlags(f.flag).Write {
+		return 0, &hackpadfs.PathError{Op: "write", Path: f.name, Err: hackpadfs.ErrPermission}
+	}
+	n, err := f.handle.Write(p)
+	return n, f.wrapErr("write", err)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := f.handle.(io.Seeker)
+	if !ok {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrNotImplemented}
+	}
+	n, err := seeker.Seek(offset, whence)
+	return n, f.wrapErr("seek", err)
+}
+
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *file) Close() error {
+	if closer, ok := f.handle.(io.Closer); ok {
+		return f.wrapErr("close", closer.Close())
+	}
+	return nil
+}
+
+// dir is an open directory of Devices
+type dir struct {
+	fs     *FS
+	name   string
+	info   hackpadfs.FileInfo
+	offset int
+}
+
+func (d *dir) Read(p []byte) (int, error) {
+	return 0, &hackpadfs.PathError{Op: "read", Path: d.name, Err: hackpadfs.ErrIsDir}
+}
+
+func (d *dir) Stat() (hackpadfs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	entries, err := d.fs.ReadDir(d.name)
+	if err != nil {
+		return nil, err
+	}
+	if d.offset > len(entries) {
+		d.offset = len(entries)
+	}
+	entries = entries[d.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
@@ -0,0 +1,218 @@
+// Package devfs contains a file system of virtual device files, like /dev/null or /proc/self/status, implemented by callbacks.
+// Mount it into a mount.FS to place devices alongside real files, or use RegisterValues() to expose live application state as a read-only, procfs-style tree.
+package devfs
+
+import (
+	"errors"
+	gofs "io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.StatFS
+		hackpadfs.ReadDirFS
+	} = &FS{}
+)
+
+// dirMode is the mode of the directories containing devices, which are read-only
+const dirMode = hackpadfs.ModeDir | 0555
+
+// FS is a read-only tree of Devices. Directories are created implicitly to hold each registered Device.
+type FS struct {
+	mu      sync.Mutex
+	devices map[string]registeredDevice
+}
+
+type registeredDevice struct {
+	Device
+	modTime time.Time
+}
+
+// NewFS returns a new, empty FS. Add devices with Register().
+func NewFS() (*FS, error) {
+	return &FS{
+		devices: make(map[string]registeredDevice),
+	}, nil
+}
+
+// Register adds 'device' to the FS at 'name'. Fails if 'name' or one of its parent directories is already in use.
+func (fs *FS) Register(name string, device Device) error {
+	if !hackpadfs.ValidPath(name) || name == "." {
+		return &hackpadfs.PathError{Op: "register", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	if device.Open == nil {
+		return &hackpadfs.PathError{Op: "register", Path: name, Err: errors.New("device Open func is required")}
+	}
+	if device.Mode == 0 {
+		device.Mode = charDevice
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, isFile, exists := fs.lookup(name); exists {
+		if isFile {
+			return &hackpadfs.PathError{Op: "register", Path: name, Err: hackpadfs.ErrExist}
+		}
+		return &hackpadfs.PathError{Op: "register", Path: name, Err: hackpadfs.ErrIsDir}
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if _, exists := fs.devices[dir]; exists {
+			return &hackpadfs.PathError{Op: "register", Path: name, Err: hackpadfs.ErrNotDir}
+		}
+	}
+	fs.devices[name] = registeredDevice{Device: device, modTime: time.Now()}
+	return nil
+}
+
+// Unregister removes the Device at 'name'. Files already open remain usable.
+func (fs *FS) Unregister(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, exists := fs.devices[name]; !exists {
+		return &hackpadfs.PathError{Op: "unregister", Path: name, Err: hackpadfs.ErrNotExist}
+	}
+	delete(fs.devices, name)
+	return nil
+}
+
+// lookup returns the device at 'name' and true if it's a device, or false if it's a directory. Must be called with fs.mu held.
+func (fs *FS) lookup(name string) (_ registeredDevice, isFile, exists bool) {
+	if device, ok := fs.devices[name]; ok {
+		return device, true, true
+	}
+	if name == "." {
+		return registeredDevice{}, false, true
+	}
+	prefix := name + "/"
+	for devicePath := range fs.devices {
+		if strings.HasPrefix(devicePath, prefix) {
+			return registeredDevice{}, false, true
+		}
+	}
+	return registeredDevice{}, false, false
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS. New files can't be created, but existing devices can be opened for writing, ignoring hackpadfs.FlagTruncate.
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, device, err := fs.stat(name)
+	if err != nil {
+		if flag&hackpadfs.FlagCreate != 0 && errors.Is(err, hackpadfs.ErrNotExist) {
+			err = hackpadfs.ErrPermission
+		}
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+	}
+	flags := hackpadfs.ParseFlags(flag)
+	if flag&hackpadfs.FlagCreate != 0 && flag&hackpadfs.FlagExclusive != 0 {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrExist}
+	}
+	if info.IsDir() {
+		if flags.Modifies() {
+			return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrIsDir}
+		}
+		return &dir{fs: fs, name: name, info: info}, nil
+	}
+	if flags.Modifies() && info.Mode()&0222 == 0 {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrPermission}
+	}
+	handle, err := device.Open()
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &file{name: name, info: info, flag: flag, handle: handle}, nil
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, _, err := fs.stat(name)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+func (fs *FS) stat(name string) (hackpadfs.FileInfo, registeredDevice, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	device, isFile, exists := fs.lookup(name)
+	switch {
+	case !exists:
+		return nil, registeredDevice{}, hackpadfs.ErrNotExist
+	case isFile:
+		return &fileInfo{name: path.Base(name), mode: device.Mode, modTime: device.modTime}, device, nil
+	default:
+		return &fileInfo{name: path.Base(name), mode: dirMode, modTime: fs.dirModTime(name)}, registeredDevice{}, nil
+	}
+}
+
+// dirModTime returns the latest registration time of the devices under 'dir'. Must be called with fs.mu held.
+func (fs *FS) dirModTime(dir string) time.Time {
+	var modTime time.Time
+	for devicePath, device := range fs.devices {
+		if isUnder(dir, devicePath) && device.modTime.After(modTime) {
+			modTime = device.modTime
+		}
+	}
+	return modTime
+}
+
+// isUnder returns true if 'name' is inside the directory 'dir'
+func isUnder(dir, name string) bool {
+	return dir == "." || strings.HasPrefix(name, dir+"/")
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	info, err := fs.Stat(name)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: errors.Unwrap(err)}
+	}
+	if !info.IsDir() {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrNotDir}
+	}
+
+	fs.mu.Lock()
+	childNames := make(map[string]bool)
+	for devicePath := range fs.devices {
+		if isUnder(name, devicePath) {
+			rel := devicePath
+			if name != "." {
+				rel = devicePath[len(name)+1:]
+			}
+			childNames[strings.SplitN(rel, "/", 2)[0]] = true
+		}
+	}
+	fs.mu.Unlock()
+
+	entries := make([]hackpadfs.DirEntry, 0, len(childNames))
+	for childName := range childNames {
+		info, err := fs.Stat(path.Join(name, childName))
+		if err != nil {
+			continue // unregistered since listing
+		}
+		entries = append(entries, gofs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Name() < entries[b].Name()
+	})
+	return entries, nil
+}
@@ -0,0 +1,214 @@
+package devfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	"github.com/hack-pad/hackpadfs/mount"
+)
+
+func makeFS(tb testing.TB) *FS {
+	tb.Helper()
+	fs, err := NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func TestNull(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.NoError(t, fs.Register("null", Null()))
+
+	f, err := fs.OpenFile("null", hackpadfs.FlagReadWrite, 0)
+	assert.NoError(t, err)
+	n, err := hackpadfs.WriteFile(f, []byte("foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	_, err = f.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	assert.NoError(t, f.Close())
+
+	info, err := fs.Stat("null")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.ModeDevice|hackpadfs.ModeCharDevice|0666, info.Mode())
+	assert.Equal(t, "null", info.Name())
+}
+
+func TestZero(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.NoError(t, fs.Register("zero", Zero()))
+
+	f, err := fs.Open("zero")
+	assert.NoError(t, err)
+	buf := []byte("foo")
+	_, err = io.ReadFull(f, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0}, buf)
+
+	_, err = hackpadfs.WriteFile(f, []byte("foo"))
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err) // opened read-only
+	assert.NoError(t, f.Close())
+}
+
+func TestRandom(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.NoError(t, fs.Register("random", Random()))
+
+	f, err := fs.Open("random")
+	assert.NoError(t, err)
+	buf := make([]byte, 32)
+	_, err = io.ReadFull(f, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, false, bytes.Equal(make([]byte, 32), buf))
+	assert.NoError(t, f.Close())
+}
+
+func TestContents(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	calls := 0
+	assert.NoError(t, fs.Register("self/status", Contents(func() ([]byte, error) {
+		calls++
+		if calls > 2 {
+			return nil, errors.New("some error")
+		}
+		return []byte{byte('0' + calls)}, nil
+	})))
+
+	contents, err := hackpadfs.ReadFile(fs, "self/status")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(contents))
+	contents, err = hackpadfs.ReadFile(fs, "self/status")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(contents))
+	_, err = hackpadfs.ReadFile(fs, "self/status")
+	assert.Error(t, err)
+
+	_, err = fs.OpenFile("self/status", hackpadfs.FlagWriteOnly, 0)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	info, err := fs.Stat("self/status")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0444), info.Mode())
+}
+
+func TestReadDir(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.NoError(t, fs.Register("null", Null()))
+	assert.NoError(t, fs.Register("proc/self/status", Contents(func() ([]byte, error) { return nil, nil })))
+	assert.NoError(t, fs.Register("proc/uptime", Contents(func() ([]byte, error) { return nil, nil })))
+
+	entryNames := func(entries []hackpadfs.DirEntry) []string {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	entries, err := fs.ReadDir(".")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"null", "proc"}, entryNames(entries))
+	assert.Equal(t, true, entries[1].IsDir())
+
+	entries, err = hackpadfs.ReadDir(fs, "proc")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"self", "uptime"}, entryNames(entries))
+
+	f, err := fs.Open("proc")
+	assert.NoError(t, err)
+	entries, err = hackpadfs.ReadDirFile(f, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"self"}, entryNames(entries))
+	entries, err = hackpadfs.ReadDirFile(f, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"uptime"}, entryNames(entries))
+	_, err = hackpadfs.ReadDirFile(f, 1)
+	assert.Equal(t, io.EOF, err)
+	assert.NoError(t, f.Close())
+
+	_, err = fs.ReadDir("null")
+	assert.ErrorIs(t, hackpadfs.ErrNotDir, err)
+	_, err = fs.ReadDir("missing")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.NoError(t, fs.Register("foo/bar", Null()))
+
+	assert.ErrorIs(t, hackpadfs.ErrExist, fs.Register("foo/bar", Null()))
+	assert.ErrorIs(t, hackpadfs.ErrIsDir, fs.Register("foo", Null()))
+	assert.ErrorIs(t, hackpadfs.ErrNotDir, fs.Register("foo/bar/baz", Null()))
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, fs.Register(".", Null()))
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, fs.Register("/foo", Null()))
+	assert.Error(t, fs.Register("baz", Device{}))
+
+	f, err := fs.Open("foo/bar")
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Unregister("foo/bar"))
+	_, err = fs.Stat("foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	_, err = f.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err) // still usable after unregistering
+	assert.NoError(t, f.Close())
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, fs.Unregister("foo/bar"))
+}
+
+func TestOpenFile(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.NoError(t, fs.Register("dev/null", Null()))
+
+	_, err := fs.OpenFile("dev/new", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate, 0600)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	_, err = fs.OpenFile("dev/null", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagExclusive, 0600)
+	assert.ErrorIs(t, hackpadfs.ErrExist, err)
+	_, err = fs.OpenFile("dev", hackpadfs.FlagWriteOnly, 0)
+	assert.ErrorIs(t, hackpadfs.ErrIsDir, err)
+	_, err = fs.Open("dev/missing")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	f, err := fs.Open("dev")
+	assert.NoError(t, err)
+	_, err = f.Read(make([]byte, 1))
+	assert.ErrorIs(t, hackpadfs.ErrIsDir, err)
+	assert.NoError(t, f.Close())
+}
+
+func TestMount(t *testing.T) {
+	t.Parallel()
+	devFS := makeFS(t)
+	assert.NoError(t, devFS.Register("null", Null()))
+	assert.NoError(t, devFS.Register("zero", Zero()))
+
+	root, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, root.Mkdir("dev", 0700))
+	fs, err := mount.NewFS(root)
+	assert.NoError(t, err)
+	assert.NoError(t, fs.AddMount("dev", devFS))
+
+	f, err := hackpadfs.OpenFile(fs, "dev/null", hackpadfs.FlagWriteOnly, 0)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("foo"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	entries, err := hackpadfs.ReadDir(fs, "dev")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	info, err := hackpadfs.Stat(fs, "dev/zero")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.ModeDevice|hackpadfs.ModeCharDevice|0666, info.Mode())
+}
@@ -0,0 +1,76 @@
+package devfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// Value returns a read-only Device which renders the result of 'value' each time it's opened, like the files in /proc.
+//
+// Strings and byte slices are rendered as-is, errors and fmt.Stringers with their Error() and String() methods, and anything else as indented JSON.
+func Value(value func() interface{}) Device {
+	return Contents(func() ([]byte, error) {
+		return render(value())
+	})
+}
+
+func render(value interface{}) ([]byte, error) {
+	switch value := value.(type) {
+	case string:
+		return []byte(value), nil
+	case []byte:
+		return value, nil
+	case error:
+		return []byte(value.Error()), nil
+	case fmt.Stringer:
+		return []byte(value.String()), nil
+	default:
+		b, err := json.MarshalIndent(value, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	}
+}
+
+// RegisterValues registers a read-only Device for each value in 'tree', inside directory 'dir'. Use "." for the root directory.
+//
+// Each key in 'tree' is a file name. Values of type map[string]interface{} become subdirectories, Devices are registered as-is, and funcs of type func() interface{} are called each time their file is opened.
+// Any other value is rendered each time its file is opened, so pointers to live application state show its current value. See Value() for rendering details.
+//
+// Values are registered in sorted order and registration stops at the first error, leaving earlier values registered.
+func (fs *FS) RegisterValues(dir string, tree map[string]interface{}) error {
+	if !hackpadfs.ValidPath(dir) {
+		return &hackpadfs.PathError{Op: "register", Path: dir, Err: hackpadfs.ErrInvalid}
+	}
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		filePath := path.Join(dir, name)
+		if !hackpadfs.ValidPath(name) || path.Base(name) != name || name == "." {
+			return &hackpadfs.PathError{Op: "register", Path: filePath, Err: hackpadfs.ErrInvalid}
+		}
+		var err error
+		switch value := tree[name].(type) {
+		case map[string]interface{}:
+			err = fs.RegisterValues(filePath, value)
+		case Device:
+			err = fs.Register(filePath, value)
+		case func() interface{}:
+			err = fs.Register(filePath, Value(value))
+		default:
+			err = fs.Register(filePath, Value(func() interface{} { return value }))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package devfs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		value       interface{}
+		expect      string
+	}{
+		{"string", "foo", "foo"},
+		{"bytes", []byte("foo"), "foo"},
+		{"error", errors.New("some error"), "some error"},
+		{"stringer", 2 * time.Second, "2s"},
+		{"struct", struct{ Foo int }{Foo: 1}, "{\n    \"Foo\": 1\n}\n"},
+		{"nil", nil, "null\n"},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			b, err := render(tc.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expect, string(b))
+		})
+	}
+
+	_, err := render(func() {})
+	assert.Error(t, err)
+}
+
+func TestRegisterValues(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	type stats struct {
+		Requests int
+	}
+	live := &stats{}
+	calls := 0
+	assert.NoError(t, fs.RegisterValues("proc", map[string]interface{}{
+		"version": "1.0",
+		"stats":   live,
+		"self": map[string]interface{}{
+			"calls": func() interface{} {
+				calls++
+				return calls
+			},
+		},
+		"null": Null(),
+	}))
+
+	readString := func(name string) string {
+		t.Helper()
+		contents, err := hackpadfs.ReadFile(fs, name)
+		assert.NoError(t, err)
+		return string(contents)
+	}
+	assert.Equal(t, "1.0", readString("proc/version"))
+	assert.Equal(t, "{\n    \"Requests\": 0\n}\n", readString("proc/stats"))
+	live.Requests = 5
+	assert.Equal(t, "{\n    \"Requests\": 5\n}\n", readString("proc/stats"))
+	assert.Equal(t, "1\n", readString("proc/self/calls"))
+	assert.Equal(t, "2\n", readString("proc/self/calls"))
+	assert.Equal(t, "", readString("proc/null"))
+
+	info, err := fs.Stat("proc/version")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0444), info.Mode())
+	info, err = fs.Stat("proc/null")
+	assert.NoError(t, err)
+	assert.Equal(t, charDevice, info.Mode())
+}
+
+func TestRegisterValuesErrors(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, fs.RegisterValues("/proc", nil))
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, fs.RegisterValues(".", map[string]interface{}{
+		"foo/bar": 1,
+	}))
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, fs.RegisterValues("proc", map[string]interface{}{
+		"..": 1,
+	}))
+
+	assert.NoError(t, fs.RegisterValues(".", map[string]interface{}{"foo": 1}))
+	assert.ErrorIs(t, hackpadfs.ErrExist, fs.RegisterValues(".", map[string]interface{}{"foo": 2}))
+}
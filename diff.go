@@ -0,0 +1,247 @@
+package hackpadfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ChangeType is the kind of difference found by Diff()
+type ChangeType int
+
+// Change types
+const (
+	ChangeAdded    ChangeType = iota + 1 // exists only in 'b'
+	ChangeRemoved                        // exists only in 'a'
+	ChangeModified                       // exists in both, but differs
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// ChangeReason is a bit set of reasons a path was modified
+type ChangeReason uint
+
+// Change reasons
+const (
+	ReasonType     ChangeReason = 1 << iota // file type differs, e.g. a file replaced by a directory
+	ReasonSize                              // file size differs
+	ReasonMode                              // permission bits differ
+	ReasonModTime                           // modified time differs
+	ReasonContents                          // file contents differ, only checked if DiffOptions.CompareContents is set
+)
+
+// Has returns true if 'reason' is set in r
+func (r ChangeReason) Has(reason ChangeReason) bool {
+	return r&reason == reason
+}
+
+func (r ChangeReason) String() string {
+	var reasons []string
+	for _, reason := range []struct {
+		reason ChangeReason
+		name   string
+	}{
+		{ReasonType, "type"},
+		{ReasonSize, "size"},
+		{ReasonMode, "mode"},
+		{ReasonModTime, "modtime"},
+		{ReasonContents, "contents"},
+	} {
+		if r.Has(reason.reason) {
+			reasons = append(reasons, reason.name)
+		}
+	}
+	return strings.Join(reasons, ",")
+}
+
+// Change describes a path which differs between two file systems
+type Change struct {
+	Path    string
+	Type    ChangeType
+	Reasons ChangeReason // Reasons is set for ChangeModified only
+	A, B    FileInfo     // A and B are nil if the path does not exist on that side
+}
+
+// DiffOptions contain options for DiffWithOptions()
+type DiffOptions struct {
+	// CompareContents compares files byte-for-byte when their sizes match.
+	// Files whose FileInfos both report a checksum with the same algorithm, like keyvalue.Hash(), are compared by checksum instead of reading them.
+	CompareContents bool
+	// IgnoreModTime skips comparing modified times, which are often unreliable across different file system implementations
+	IgnoreModTime bool
+}
+
+// Diff compares the trees rooted at 'root' in 'a' and 'b', returning changes needed to turn 'a' into 'b' sorted by path.
+// Files are compared by type, size, permissions, and modified time. Directories are compared by type and permissions.
+// A missing root is treated as empty.
+func Diff(a, b FS, root string) ([]Change, error) {
+	return DiffWithOptions(a, b, root, DiffOptions{})
+}
+
+// DiffWithOptions is like Diff(), but with additional options. See DiffOptions for details.
+func DiffWithOptions(a, b FS, root string, options DiffOptions) ([]Change, error) {
+	aInfos, err := walkInfos(a, root)
+	if err != nil {
+		return nil, err
+	}
+	bInfos, err := walkInfos(b, root)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(aInfos)+len(bInfos))
+	for name := range aInfos {
+		names = append(names, name)
+	}
+	for name := range bInfos {
+		if _, ok := aInfos[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []Change
+	for _, name := range names {
+		aInfo, bInfo := aInfos[name], bInfos[name]
+		change := Change{Path: name, A: aInfo, B: bInfo}
+		switch {
+		case aInfo == nil:
+			change.Type = ChangeAdded
+		case bInfo == nil:
+			change.Type = ChangeRemoved
+		default:
+			change.Type = ChangeModified
+			change.Reasons, err = diffInfo(a, b, name, aInfo, bInfo, options)
+			if err != nil {
+				return nil, err
+			}
+			if change.Reasons == 0 {
+				continue
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func walkInfos(fs FS, root string) (map[string]FileInfo, error) {
+	infos := make(map[string]FileInfo)
+	err := WalkDir(fs, root, func(name string, dirEntry DirEntry, err error) error {
+		if name == root {
+			if errors.Is(err, ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+		infos[name] = info
+		return nil
+	})
+	return infos, err
+}
+
+func diffInfo(a, b FS, name string, aInfo, bInfo FileInfo, options DiffOptions) (ChangeReason, error) {
+	if aInfo.Mode().Type() != bInfo.Mode().Type() {
+		return ReasonType, nil
+	}
+	var reasons ChangeReason
+	if aInfo.Mode().Perm() != bInfo.Mode().Perm() {
+		reasons |= ReasonMode
+	}
+	if aInfo.IsDir() {
+		return reasons, nil
+	}
+	if aInfo.Size() != bInfo.Size() {
+		reasons |= ReasonSize
+	}
+	if !options.IgnoreModTime && !aInfo.ModTime().Equal(bInfo.ModTime()) {
+		reasons |= ReasonModTime
+	}
+	if options.CompareContents && !reasons.Has(ReasonSize) {
+		equal, ok := equalHashes(aInfo, bInfo)
+		if !ok {
+			var err error
+			equal, err = equalContents(a, b, name)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if !equal {
+			reasons |= ReasonContents
+		}
+	}
+	return reasons, nil
+}
+
+// equalHashes compares the checksums reported by 'aInfo' and 'bInfo'. Returns false for 'ok' if either checksum is unknown or they use different algorithms.
+func equalHashes(aInfo, bInfo FileInfo) (equal, ok bool) {
+	type hashInfo interface {
+		Hash() (algo string, sum []byte, ok bool)
+	}
+	aHash, aOk := aInfo.(hashInfo)
+	bHash, bOk := bInfo.(hashInfo)
+	if !aOk || !bOk {
+		return false, false
+	}
+	aAlgo, aSum, aOk := aHash.Hash()
+	bAlgo, bSum, bOk := bHash.Hash()
+	if !aOk || !bOk || aAlgo != bAlgo {
+		return false, false
+	}
+	return bytes.Equal(aSum, bSum), true
+}
+
+func equalContents(a, b FS, name string) (bool, error) {
+	aFile, err := a.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = aFile.Close() }()
+	bFile, err := b.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = bFile.Close() }()
+
+	const bufSize = 32 * 1024
+	aBuf, bBuf := make([]byte, bufSize), make([]byte, bufSize)
+	for {
+		aN, aErr := io.ReadFull(aFile, aBuf)
+		bN, bErr := io.ReadFull(bFile, bBuf)
+		if !bytes.Equal(aBuf[:aN], bBuf[:bN]) {
+			return false, nil
+		}
+		aDone, bDone := isReadDone(aErr), isReadDone(bErr)
+		switch {
+		case aErr != nil && !aDone:
+			return false, aErr
+		case bErr != nil && !bDone:
+			return false, bErr
+		case aDone || bDone:
+			return aDone == bDone, nil
+		}
+	}
+}
+
+func isReadDone(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
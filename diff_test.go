@@ -0,0 +1,130 @@
+package hackpadfs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+type quickChange struct {
+	Path    string
+	Type    hackpadfs.ChangeType
+	Reasons hackpadfs.ChangeReason
+}
+
+func asQuickChanges(changes []hackpadfs.Change) []quickChange {
+	var quickChanges []quickChange
+	for _, change := range changes {
+		quickChanges = append(quickChanges, quickChange{Path: change.Path, Type: change.Type, Reasons: change.Reasons})
+	}
+	return quickChanges
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	modTime := time.Now()
+	setup := func(t *testing.T) (*mem.FS, *mem.FS) {
+		t.Helper()
+		a, err := mem.NewFS()
+		assert.NoError(t, err)
+		b, err := mem.NewFS()
+		assert.NoError(t, err)
+		for _, fs := range []*mem.FS{a, b} {
+			assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/same", []byte("same"), 0600))
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/contents", []byte("aaa"), 0600))
+			assert.NoError(t, fs.Chtimes("foo/same", modTime, modTime))
+			assert.NoError(t, fs.Chtimes("foo/contents", modTime, modTime))
+		}
+		assert.NoError(t, hackpadfs.WriteFullFile(a, "foo/removed", nil, 0600))
+		assert.NoError(t, hackpadfs.WriteFullFile(b, "foo/bar/added", nil, 0600))
+		assert.NoError(t, hackpadfs.WriteFullFile(b, "foo/contents", []byte("bbb"), 0600))
+		assert.NoError(t, b.Chtimes("foo/contents", modTime, modTime))
+		assert.NoError(t, b.Chmod("foo/bar", 0755))
+		assert.NoError(t, hackpadfs.WriteFullFile(a, "baz", nil, 0600))
+		assert.NoError(t, b.Mkdir("baz", 0700))
+		return a, b
+	}
+
+	t.Run("default", func(t *testing.T) {
+		t.Parallel()
+		a, b := setup(t)
+		changes, err := hackpadfs.Diff(a, b, ".")
+		assert.NoError(t, err)
+		assert.Equal(t, []quickChange{
+			{Path: "baz", Type: hackpadfs.ChangeModified, Reasons: hackpadfs.ReasonType},
+			{Path: "foo/bar", Type: hackpadfs.ChangeModified, Reasons: hackpadfs.ReasonMode},
+			{Path: "foo/bar/added", Type: hackpadfs.ChangeAdded},
+			{Path: "foo/removed", Type: hackpadfs.ChangeRemoved},
+		}, asQuickChanges(changes))
+	})
+
+	t.Run("compare contents", func(t *testing.T) {
+		t.Parallel()
+		a, b := setup(t)
+		changes, err := hackpadfs.DiffWithOptions(a, b, "foo", hackpadfs.DiffOptions{CompareContents: true})
+		assert.NoError(t, err)
+		assert.Equal(t, []quickChange{
+			{Path: "foo/bar", Type: hackpadfs.ChangeModified, Reasons: hackpadfs.ReasonMode},
+			{Path: "foo/bar/added", Type: hackpadfs.ChangeAdded},
+			{Path: "foo/contents", Type: hackpadfs.ChangeModified, Reasons: hackpadfs.ReasonContents},
+			{Path: "foo/removed", Type: hackpadfs.ChangeRemoved},
+		}, asQuickChanges(changes))
+	})
+
+	t.Run("missing root", func(t *testing.T) {
+		t.Parallel()
+		a, b := setup(t)
+		changes, err := hackpadfs.Diff(a, b, "foo/bar/added")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(changes))
+		changes, err = hackpadfs.Diff(a, b, "foo/bar")
+		assert.NoError(t, err)
+		assert.Equal(t, []quickChange{
+			{Path: "foo/bar/added", Type: hackpadfs.ChangeAdded},
+		}, asQuickChanges(changes))
+	})
+}
+
+// sameHashStore reports the same checksum for every regular file, no matter its contents
+type sameHashStore struct {
+	keyvalue.Store
+}
+
+func (s *sameHashStore) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
+	record, err := s.Store.Get(ctx, path)
+	if err != nil || !record.Mode().IsRegular() {
+		return record, err
+	}
+	return keyvalue.WithHash(record, "same", []byte("same")), nil
+}
+
+func TestDiffCompareContentsHash(t *testing.T) {
+	t.Parallel()
+	a, err := keyvalue.NewFS(&sameHashStore{Store: mem.NewStore()})
+	assert.NoError(t, err)
+	b, err := keyvalue.NewFS(&sameHashStore{Store: mem.NewStore()})
+	assert.NoError(t, err)
+	c, err := mem.NewFS()
+	assert.NoError(t, err)
+	modTime := time.Now()
+	for fs, contents := range map[hackpadfs.FS]string{a: "aaa", b: "bbb", c: "ccc"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte(contents), 0600))
+		assert.NoError(t, hackpadfs.Chtimes(fs, "foo", modTime, modTime))
+	}
+
+	changes, err := hackpadfs.DiffWithOptions(a, b, ".", hackpadfs.DiffOptions{CompareContents: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(changes)) // compared by checksum, without reading contents
+
+	changes, err = hackpadfs.DiffWithOptions(a, c, ".", hackpadfs.DiffOptions{CompareContents: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []quickChange{
+		{Path: "foo", Type: hackpadfs.ChangeModified, Reasons: hackpadfs.ReasonContents},
+	}, asQuickChanges(changes))
+}
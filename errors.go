@@ -18,6 +18,13 @@ var (
 	ErrNotEmpty       = syscall.ENOTEMPTY
 	ErrNotImplemented = syscall.ENOSYS
 
+	ErrNoSpace      = syscall.ENOSPC       // ErrNoSpace is returned when a write exceeds the available storage or quota
+	ErrTooManyLinks = syscall.ELOOP        // ErrTooManyLinks is returned when resolving a path follows too many symlinks, e.g. a symlink loop
+	ErrReadOnly     = syscall.EROFS        // ErrReadOnly is returned when modifying a read-only file system
+	ErrCrossDevice  = syscall.EXDEV        // ErrCrossDevice is returned when an operation, like Rename, is not supported between two different file systems
+	ErrNameTooLong  = syscall.ENAMETOOLONG // ErrNameTooLong is returned when a path, a path element, or the path's depth exceeds a file system's limits
+	ErrFileTooLarge = syscall.EFBIG        // ErrFileTooLarge is returned when a file's contents exceed a size limit, like in ReadFileLimit
+
 	SkipDir = fs.SkipDir
 )
 
@@ -42,3 +49,54 @@ func (e *LinkError) Error() string {
 func (e *LinkError) Unwrap() error {
 	return e.Err
 }
+
+// ErrorContext carries debugging details about where an error came from in a stack of file systems. Retrieve it with errors.As().
+//
+// WithErrorContext() places ErrorContext inside a PathError or LinkError, so existing code still sees a standard error with an unchanged message.
+type ErrorContext struct {
+	Backend    string // Backend names the file system implementation, e.g. "indexeddb" or "s3"
+	MountPoint string // MountPoint is the path the failing file system is mounted at, if any
+	Code       string // Code is the underlying store's error code, e.g. an S3 error code or an IndexedDB DOMException name
+	Err        error
+}
+
+func (e *ErrorContext) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap supports errors.Unwrap().
+func (e *ErrorContext) Unwrap() error {
+	return e.Err
+}
+
+// WithErrorContext attaches 'context' to 'err'. Any fields already set by an inner ErrorContext are preserved.
+// If 'err' is a PathError or LinkError, the context wraps its inner error instead.
+func WithErrorContext(err error, context ErrorContext) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *PathError:
+		errCopy := *e
+		errCopy.Err = WithErrorContext(errCopy.Err, context)
+		return &errCopy
+	case *LinkError:
+		errCopy := *e
+		errCopy.Err = WithErrorContext(errCopy.Err, context)
+		return &errCopy
+	case *ErrorContext:
+		errCopy := *e
+		if errCopy.Backend == "" {
+			errCopy.Backend = context.Backend
+		}
+		if errCopy.MountPoint == "" {
+			errCopy.MountPoint = context.MountPoint
+		}
+		if errCopy.Code == "" {
+			errCopy.Code = context.Code
+		}
+		return &errCopy
+	default:
+		context.Err = err
+		return &context
+	}
+}
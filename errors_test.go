@@ -0,0 +1,31 @@
+package hackpadfs
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestWithErrorContext(t *testing.T) {
+	t.Parallel()
+	assert.NoError(t, WithErrorContext(nil, ErrorContext{Backend: "mem"}))
+
+	err := WithErrorContext(&PathError{Op: "open", Path: "foo", Err: ErrNotExist}, ErrorContext{Backend: "s3", Code: "NoSuchKey"})
+	err = WithErrorContext(err, ErrorContext{Backend: "mount", MountPoint: "bar"})
+	assert.Equal(t, "open foo: file does not exist", err.Error())
+	assert.IsType(t, &PathError{}, err)
+	assert.ErrorIs(t, ErrNotExist, err)
+	var errContext *ErrorContext
+	if assert.ErrorAs(t, &errContext, err) {
+		assert.Equal(t, "s3", errContext.Backend)
+		assert.Equal(t, "bar", errContext.MountPoint)
+		assert.Equal(t, "NoSuchKey", errContext.Code)
+	}
+
+	err = WithErrorContext(&LinkError{Op: "rename", Old: "foo", New: "bar", Err: ErrCrossDevice}, ErrorContext{MountPoint: "bar"})
+	assert.IsType(t, &LinkError{}, err)
+	assert.ErrorIs(t, ErrCrossDevice, err)
+	if assert.ErrorAs(t, &errContext, err) {
+		assert.Equal(t, "bar", errContext.MountPoint)
+	}
+}
@@ -2,12 +2,23 @@
 package s3
 
 import (
+	"context"
+	gofs "io/fs"
+	"path"
 	"time"
 
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/keyvalue"
 )
 
+var (
+	_ hackpadfs.ReadDirPagedFS = &FS{}
+	_ hackpadfs.PingFS         = &FS{}
+)
+
+// readDirPageSize is the number of entries in each page from ReadDirN, the most S3 lists in one request
+const readDirPageSize = 1000
+
 // FS is an S3-based file system, storing files and metadata in an object storage bucket.
 type FS struct {
 	kv    *keyvalue.FS
@@ -21,6 +32,18 @@ type Options struct {
 	AccessKeyID     string
 	SecretAccessKey string
 	Insecure        bool
+	// BufferWrites uploads file contents on Sync() or Close() instead of on every write. See keyvalue.Options for details.
+	BufferWrites bool
+	// Notifications receives the bucket's object notifications, like s3:ObjectCreated:* and s3:ObjectRemoved:* events delivered by SQS or minio's ListenBucketNotification.
+	// When set, FS.Watch reports the changes they describe, including this FS's own changes once their notifications arrive.
+	// Objects which aren't files in this FS are ignored. Close the channel to stop watching.
+	Notifications <-chan ObjectNotification
+}
+
+// ObjectNotification describes a change to an object in the bucket
+type ObjectNotification struct {
+	Key     string // Key is the object's decoded key
+	Removed bool   // Removed is true if the object was deleted, false if it was created or overwritten
 }
 
 // NewFS returns a new FS.
@@ -29,7 +52,9 @@ func NewFS(options Options) (*FS, error) {
 	if err != nil {
 		return nil, err
 	}
-	kv, err := keyvalue.NewFS(store)
+	kv, err := keyvalue.NewFSWithOptions(store, keyvalue.Options{
+		BufferWrites: options.BufferWrites,
+	})
 	return &FS{
 		kv:    kv,
 		store: store,
@@ -66,6 +91,27 @@ func (fs *FS) Rename(oldname, newname string) error {
 	return fs.kv.Rename(oldname, newname)
 }
 
+// DiskUsage implements hackpadfs.UsageFS
+func (fs *FS) DiskUsage(root string) (files, dirs, bytes int64, err error) {
+	return fs.kv.DiskUsage(root)
+}
+
+// SameFile implements hackpadfs.SameFileFS
+func (fs *FS) SameFile(fi1, fi2 hackpadfs.FileInfo) bool {
+	return fs.kv.SameFile(fi1, fi2)
+}
+
+// Watch implements hackpadfs.WatchFS
+// Requires Options.Notifications, otherwise fails with hackpadfs.ErrNotImplemented.
+func (fs *FS) Watch(ctx context.Context, name string) (<-chan hackpadfs.Event, error) {
+	return fs.kv.Watch(ctx, name)
+}
+
+// Ping implements hackpadfs.PingFS, checking the bucket exists and is accessible with a HeadBucket request
+func (fs *FS) Ping(ctx context.Context) error {
+	return fs.kv.Ping(ctx)
+}
+
 // Stat implements hackpadfs.StatFS
 func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
 	return fs.kv.Stat(name)
@@ -80,3 +126,33 @@ func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
 func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
 	return fs.kv.Chtimes(name, atime, mtime)
 }
+
+// ApplyMetadata implements hackpadfs.ApplyMetadataFS
+func (fs *FS) ApplyMetadata(metadata map[string]hackpadfs.Metadata) error {
+	return fs.kv.ApplyMetadata(metadata)
+}
+
+// ReadDirN implements hackpadfs.ReadDirPagedFS, listing a page of objects at a time instead of the whole directory.
+// Entries are ordered by their object keys, which may differ from their names' order.
+func (fs *FS) ReadDirN(name, token string) ([]hackpadfs.DirEntry, string, error) {
+	info, err := fs.kv.Stat(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if !info.IsDir() {
+		return nil, "", &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrNotDir}
+	}
+	names, nextToken, err := fs.store.listDirPage(context.Background(), name, token, readDirPageSize)
+	if err != nil {
+		return nil, "", &hackpadfs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	entries := make([]hackpadfs.DirEntry, 0, len(names))
+	for _, entryName := range names {
+		info, err := fs.kv.Stat(path.Join(name, entryName))
+		if err != nil {
+			return nil, "", err
+		}
+		entries = append(entries, gofs.FileInfoToDirEntry(info))
+	}
+	return entries, nextToken, nil
+}
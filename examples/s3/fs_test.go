@@ -2,15 +2,22 @@ package s3
 
 import (
 	"context"
+	"crypto/md5"
 	"fmt"
+	"net/url"
 	"os"
+	"sort"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 	"unicode"
 
+	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/storetest"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	minioServer "github.com/minio/minio/cmd"
@@ -85,6 +92,11 @@ func cleanTestName(tb testing.TB) string {
 var testNumber uint64
 
 func makeFS(tb testing.TB) *FS {
+	return makeFSWithOptions(tb, Options{})
+}
+
+// makeFSWithOptions returns an FS for a new bucket, using 'options' with the bucket and test server filled in
+func makeFSWithOptions(tb testing.TB, options Options) *FS {
 	bucketName := fmt.Sprintf("%s-%d", cleanTestName(tb), atomic.AddUint64(&testNumber, 1))
 
 	ctx := context.Background()
@@ -93,13 +105,12 @@ func makeFS(tb testing.TB) *FS {
 		tb.Fatal(err)
 	}
 
-	fs, err := NewFS(Options{
-		Endpoint:        testDBHost,
-		BucketName:      bucketName,
-		Insecure:        true,
-		AccessKeyID:     testDBAccessKeyID,
-		SecretAccessKey: testDBSecretKey,
-	})
+	options.Endpoint = testDBHost
+	options.BucketName = bucketName
+	options.Insecure = true
+	options.AccessKeyID = testDBAccessKeyID
+	options.SecretAccessKey = testDBSecretKey
+	fs, err := NewFS(options)
 	if err != nil {
 		tb.Fatal(err)
 	}
@@ -118,10 +129,143 @@ func TestFS(t *testing.T) {
 		TestFS: func(tb testing.TB) fstest.SetupFS {
 			return makeFS(tb)
 		},
+		LogFSOnFailure: true,
 		ShouldSkip: func(facets fstest.Facets) bool {
-			return facets.Name == "TestFS/s3_FS/fs.Rename/non-empty_directory" // FIXME: Somehow the new renamed parent dir does not exist post-move.
+			switch facets.Name {
+			case "TestFS/s3_FS/fs.Rename/open_file": // Open files download their contents on first read, by which point the object was moved.
+				return true
+			default:
+				return false
+			}
 		},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+	storetest.Store(t, func(tb testing.TB) keyvalue.Store {
+		return makeFS(tb).store
+	})
+}
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("bar"), 0600))
+	info, err := fs.Stat("foo")
+	assert.NoError(t, err)
+	algo, sum, ok := keyvalue.Hash(info)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "md5", algo)
+	expectedSum := md5.Sum([]byte("bar"))
+	assert.Equal(t, expectedSum[:], sum)
+}
+
+func TestReadDirN(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, name, nil, 0600))
+	}
+	assert.NoError(t, fs.MkdirAll("d/sub", 0700))
+	assert.NoError(t, fs.Mkdir("e", 0700))
+
+	var names []string
+	token := ""
+	for {
+		pageNames, nextToken, err := fs.store.listDirPage(context.Background(), ".", token, 2)
+		assert.NoError(t, err)
+		names = append(names, pageNames...)
+		if nextToken == "" {
+			break
+		}
+		token = nextToken
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, names)
+
+	entries, nextToken, err := hackpadfs.ReadDirPaged(fs, "d", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "", nextToken)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, "sub", entries[0].Name())
+		assert.Equal(t, true, entries[0].IsDir())
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifications := make(chan ObjectNotification)
+	fs := makeFSWithOptions(t, Options{Notifications: notifications})
+	bucketNotifications := minioClient.ListenBucketNotification(ctx, fs.store.options.BucketName, "", "", []string{
+		"s3:ObjectCreated:*",
+		"s3:ObjectRemoved:*",
+	})
+	go func() {
+		defer close(notifications)
+		for info := range bucketNotifications {
+			for _, record := range info.Records {
+				key, err := url.QueryUnescape(record.S3.Object.Key)
+				if err != nil {
+					continue
+				}
+				notifications <- ObjectNotification{
+					Key:     key,
+					Removed: strings.HasPrefix(record.EventName, "s3:ObjectRemoved:"),
+				}
+			}
+		}
+	}()
+	events, err := fs.Watch(ctx, "foo")
+	assert.NoError(t, err)
+	time.Sleep(500 * time.Millisecond) // wait for the bucket listener to connect
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foobar", nil, 0600))
+	assert.NoError(t, fs.Mkdir("foo", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("bar"), 0600))
+	assert.NoError(t, fs.Remove("foo/bar"))
+	var received []hackpadfs.Event
+	timeout := time.After(10 * time.Second)
+	for len(received) < 4 {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-timeout:
+			t.Fatal("Timed out waiting for events. Received:", received)
+		}
+	}
+	assert.Equal(t, []hackpadfs.Event{
+		{Name: "foo", Op: hackpadfs.EventWrite},
+		{Name: "foo/bar", Op: hackpadfs.EventWrite},
+		{Name: "foo/bar", Op: hackpadfs.EventWrite},
+		{Name: "foo/bar", Op: hackpadfs.EventRemove},
+	}, received)
+}
+
+func TestWatchNotImplemented(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	_, err := fs.Watch(context.Background(), ".")
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+}
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.NoError(t, fs.Ping(context.Background()))
+
+	missing, err := newStore(Options{
+		Endpoint:        testDBHost,
+		BucketName:      "missing-" + cleanTestName(t),
+		AccessKeyID:     testDBAccessKeyID,
+		SecretAccessKey: testDBSecretKey,
+		Insecure:        true,
+	})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, missing.Ping(context.Background()))
 }
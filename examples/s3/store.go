@@ -3,6 +3,8 @@ package s3
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -21,6 +23,10 @@ import (
 var (
 	_ interface {
 		keyvalue.Store
+		keyvalue.MetadataStore
+		keyvalue.UsageStore
+		keyvalue.WatchableStore
+		keyvalue.PingStore
 	} = &store{}
 )
 
@@ -28,6 +34,7 @@ const (
 	// for some reason these keys must be Header-cased
 	modeMetadataKey    = "Mode"
 	modTimeMetadataKey = "Modtime"
+	idMetadataKey      = "Id"
 	modTimeFormat      = time.RFC3339Nano
 
 	rootPath    = "files"
@@ -37,9 +44,12 @@ const (
 	octalSize = 8 // formats file mode, for convenient human-readable metadata
 )
 
+// store is a keyvalue.Store keeping each file in an S3 object.
+// It isn't a keyvalue.VersionedStore, since this client can't make conditional uploads to compare and set an object's ETag atomically.
 type store struct {
-	options Options
-	client  *minio.Client
+	options  Options
+	client   *minio.Client
+	watchers keyvalue.Watchers
 }
 
 func newStore(options Options) (*store, error) {
@@ -50,10 +60,14 @@ func newStore(options Options) (*store, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &store{
+	s := &store{
 		options: options,
 		client:  client,
-	}, nil
+	}
+	if options.Notifications != nil {
+		go s.receiveNotifications(options.Notifications)
+	}
+	return s, nil
 }
 
 func (s *store) fileToObjectKey(p string, isDir bool) string {
@@ -81,11 +95,55 @@ func (s *store) objectKeyToFile(p string) string {
 	}
 }
 
+// isFileKey returns true if 'key' is the object key of a file or directory
+func isFileKey(key string) bool {
+	if !strings.HasPrefix(key, rootPath+"/") || strings.HasSuffix(key, "/") {
+		return false
+	}
+	name := path.Base(key)
+	return name == dirMetaName || strings.HasPrefix(name, filePrefix)
+}
+
+func (s *store) receiveNotifications(notifications <-chan ObjectNotification) {
+	for notification := range notifications {
+		if isFileKey(notification.Key) {
+			s.watchers.Send(keyvalue.Event{
+				Path:    s.objectKeyToFile(notification.Key),
+				Deleted: notification.Removed,
+			})
+		}
+	}
+}
+
+// Watch implements keyvalue.WatchableStore, reporting changes from Options.Notifications
+func (s *store) Watch(ctx context.Context, prefix string) (<-chan keyvalue.Event, error) {
+	if s.options.Notifications == nil {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	return s.watchers.Watch(ctx, prefix)
+}
+
+// Ping implements keyvalue.PingStore with a HeadBucket request, failing with hackpadfs.ErrNotExist if the bucket is missing
+func (s *store) Ping(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.options.BucketName)
+	if err != nil {
+		return s.wrapS3Err(err)
+	}
+	if !exists {
+		return hackpadfs.WithErrorContext(hackpadfs.ErrNotExist, hackpadfs.ErrorContext{Backend: "s3", Code: "NoSuchBucket"})
+	}
+	return nil
+}
+
 func (s *store) wrapS3Err(err error) error {
-	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
-		return hackpadfs.ErrNotExist
+	code := minio.ToErrorResponse(err).Code
+	switch code {
+	case "":
+		return err
+	case "NoSuchKey":
+		err = hackpadfs.ErrNotExist
 	}
-	return err
+	return hackpadfs.WithErrorContext(err, hackpadfs.ErrorContext{Backend: "s3", Code: code})
 }
 
 func (s *store) stat(ctx context.Context, key string) (minio.ObjectInfo, error) {
@@ -132,7 +190,23 @@ func (s *store) Get(ctx context.Context, name string) (keyvalue.FileRecord, erro
 	} else {
 		getData = s.getDataFunc(key)
 	}
-	return keyvalue.NewBaseFileRecord(info.Size, modTime, mode, nil, getData, getDirNames), nil
+	var record keyvalue.FileRecord = keyvalue.NewBaseFileRecord(info.Size, modTime, mode, nil, getData, getDirNames)
+	if id, ok := info.UserMetadata[idMetadataKey]; ok {
+		record = keyvalue.WithID(record, id)
+	}
+	if sum, ok := etagMD5(info.ETag); ok && mode.IsRegular() {
+		record = keyvalue.WithHash(record, "md5", sum)
+	}
+	return record, nil
+}
+
+// etagMD5 returns the MD5 checksum in 'etag'. Only objects uploaded in a single part without server-side encryption have an MD5 ETag, multipart ETags end in "-<parts>".
+func etagMD5(etag string) ([]byte, bool) {
+	sum, err := hex.DecodeString(etag)
+	if err != nil || len(sum) != md5.Size {
+		return nil, false
+	}
+	return sum, true
 }
 
 func (s *store) getDirNamesFunc(key string) func() ([]string, error) {
@@ -155,6 +229,63 @@ func (s *store) getDirNamesFunc(key string) func() ([]string, error) {
 	}
 }
 
+// listDirPage returns up to 'pageSize' entry names in directory 'name', listed after the object key 'startAfter'.
+// Returns the last listed key to continue from, or "" after the last page.
+func (s *store) listDirPage(ctx context.Context, name, startAfter string, pageSize int) (names []string, lastKey string, err error) {
+	dirKey := s.fileToObjectKey(name, true)
+	prefix, _ := path.Split(dirKey)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // stops listing once the page is full
+	infoChan := s.client.ListObjects(ctx, s.options.BucketName, minio.ListObjectsOptions{
+		Prefix:     prefix,
+		StartAfter: startAfter,
+		MaxKeys:    pageSize + 1,
+	})
+	for info := range infoChan {
+		if info.Err != nil {
+			return nil, "", s.wrapS3Err(info.Err)
+		}
+		if info.Key == dirKey || info.Key == startAfter { // subdirectories are listed as their key prefix, which may be listed again after starting from it
+			continue
+		}
+		if len(names) == pageSize {
+			return names, lastKey, nil
+		}
+		names = append(names, path.Base(s.objectKeyToFile(info.Key)))
+		lastKey = info.Key
+	}
+	return names, "", nil
+}
+
+// DiskUsage implements keyvalue.UsageStore, totaling object sizes from one recursive listing instead of reading each file's metadata.
+// Every object besides directories counts as a file, including symlinks.
+func (s *store) DiskUsage(ctx context.Context, name string) (files, dirs, bytes int64, err error) {
+	record, err := s.Get(ctx, name)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if !record.Mode().IsDir() {
+		return 1, 0, record.Size(), nil
+	}
+	prefix, _ := path.Split(s.fileToObjectKey(name, true))
+	infoChan := s.client.ListObjects(ctx, s.options.BucketName, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+	for info := range infoChan {
+		if info.Err != nil {
+			return 0, 0, 0, s.wrapS3Err(info.Err)
+		}
+		if path.Base(info.Key) == dirMetaName {
+			dirs++
+		} else {
+			files++
+			bytes += info.Size
+		}
+	}
+	return files, dirs, bytes, nil
+}
+
 func (s *store) getDataFunc(key string) func() (blob.Blob, error) {
 	return func() (_ blob.Blob, returnedErr error) {
 		obj, err := s.client.GetObject(context.Background(), s.options.BucketName, key, minio.GetObjectOptions{})
@@ -192,12 +323,8 @@ func (s *store) Set(ctx context.Context, name string, record keyvalue.FileRecord
 			return err
 		}
 		key := s.fileToObjectKey(name, getRecord.Mode().IsDir())
-		if getRecord.Mode().IsDir() {
-			key = path.Dir(key) // remove directory recursively
-		}
 		return s.client.RemoveObject(ctx, s.options.BucketName, key, minio.RemoveObjectOptions{})
 	}
-
 	key := s.fileToObjectKey(name, record.Mode().IsDir())
 
 	if !record.Mode().IsDir() {
@@ -210,18 +337,43 @@ func (s *store) Set(ctx context.Context, name string, record keyvalue.FileRecord
 			return hackpadfs.ErrIsDir
 		}
 	}
-	b, err := record.Data()
-	if err != nil {
-		return err
+	var data []byte
+	if !record.Mode().IsDir() { // directory objects only hold metadata
+		b, err := record.Data()
+		if err != nil {
+			return err
+		}
+		data = b.Bytes()
 	}
-	data := b.Bytes()
-	length := b.Len()
 	opts := minio.PutObjectOptions{
-		UserMetadata: map[string]string{
-			modeMetadataKey:    strconv.FormatUint(uint64(record.Mode()), octalSize),
-			modTimeMetadataKey: record.ModTime().Format(modTimeFormat),
-		},
+		UserMetadata: recordMetadata(record),
+	}
+	_, err := s.client.PutObject(ctx, s.options.BucketName, key, bytes.NewReader(data), int64(len(data)), opts)
+	return s.wrapS3Err(err)
+}
+
+// SetMetadata replaces the metadata of an existing object with a server-side copy, avoiding a re-upload of its contents.
+func (s *store) SetMetadata(ctx context.Context, name string, record keyvalue.FileRecord) error {
+	key := s.fileToObjectKey(name, record.Mode().IsDir())
+	_, err := s.client.CopyObject(ctx, minio.CopyDestOptions{
+		Bucket:          s.options.BucketName,
+		Object:          key,
+		UserMetadata:    recordMetadata(record),
+		ReplaceMetadata: true,
+	}, minio.CopySrcOptions{
+		Bucket: s.options.BucketName,
+		Object: key,
+	})
+	return s.wrapS3Err(err)
+}
+
+func recordMetadata(record keyvalue.FileRecord) map[string]string {
+	metadata := map[string]string{
+		modeMetadataKey:    strconv.FormatUint(uint64(record.Mode()), octalSize),
+		modTimeMetadataKey: record.ModTime().Format(modTimeFormat),
+	}
+	if id := keyvalue.RecordID(record); id != "" {
+		metadata[idMetadataKey] = id
 	}
-	_, err = s.client.PutObject(ctx, s.options.BucketName, key, bytes.NewReader(data), int64(length), opts)
-	return err
+	return metadata
 }
@@ -77,6 +77,12 @@ type DirReaderFile interface {
 	ReadDir(n int) ([]DirEntry, error)
 }
 
+// DirNameReaderFile is a File that supports ReadDirNames() operations. Reading only names can be much faster than ReadDir(), since entries do not need a Stat().
+type DirNameReaderFile interface {
+	File
+	ReadDirNames(n int) ([]string, error)
+}
+
 // SeekerFile is a File that supports Seek() operations.
 type SeekerFile interface {
 	File
@@ -113,6 +119,18 @@ type ChtimeserFile interface {
 	Chtimes(atime time.Time, mtime time.Time) error
 }
 
+// FdFile is a File with an underlying OS file descriptor, like *os.File. Mirrors os.File.Fd().
+type FdFile interface {
+	File
+	Fd() uintptr
+}
+
+// SyscallConnFile is a File which provides raw access to its underlying OS file, like *os.File. Mirrors os.File.SyscallConn().
+type SyscallConnFile interface {
+	File
+	SyscallConn() (syscall.RawConn, error)
+}
+
 // ChmodFile runs file.Chmod() is available, fails with a not implemented error otherwise.
 func ChmodFile(file File, mode FileMode) error {
 	if file, ok := file.(ChmoderFile); ok {
@@ -197,6 +215,19 @@ func ReadDirFile(file File, n int) ([]DirEntry, error) {
 	return nil, &PathError{Op: "readdir", Path: info.Name(), Err: ErrNotImplemented}
 }
 
+// ReadDirNamesFile runs file.ReadDirNames() if available, falls back to file.ReadDir() if available, fails with a not implemented error otherwise.
+func ReadDirNamesFile(file File, n int) ([]string, error) {
+	if file, ok := file.(DirNameReaderFile); ok {
+		return file.ReadDirNames(n)
+	}
+	entries, err := ReadDirFile(file, n)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, err
+}
+
 // SeekFile runs file.Seek() is available, fails with a not implemented error otherwise.
 func SeekFile(file File, offset int64, whence int) (int64, error) {
 	if file, ok := file.(SeekerFile); ok {
@@ -232,3 +263,28 @@ func TruncateFile(file File, size int64) error {
 	}
 	return &PathError{Op: "truncate", Path: info.Name(), Err: ErrNotImplemented}
 }
+
+// FileFd returns file.Fd() if available, fails with a not implemented error otherwise.
+// Integrations like file locking use the descriptor directly, so the file must stay open while it's in use.
+func FileFd(file File) (uintptr, error) {
+	if file, ok := file.(FdFile); ok {
+		return file.Fd(), nil
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return 0, &PathError{Op: "fd", Path: info.Name(), Err: ErrNotImplemented}
+}
+
+// FileSyscallConn runs file.SyscallConn() if available, fails with a not implemented error otherwise.
+func FileSyscallConn(file File) (syscall.RawConn, error) {
+	if file, ok := file.(SyscallConnFile); ok {
+		return file.SyscallConn()
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return nil, &PathError{Op: "syscallconn", Path: info.Name(), Err: ErrNotImplemented}
+}
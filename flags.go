@@ -0,0 +1,85 @@
+package hackpadfs
+
+// Flags is a decomposed set of OpenFile() flags. Use ParseFlags() to build one from the bit-wise OR'd Flag... constants.
+type Flags struct {
+	Read      bool // Read is true if the file may be read, i.e. FlagReadOnly or FlagReadWrite
+	Write     bool // Write is true if the file may be written, i.e. FlagWriteOnly or FlagReadWrite
+	Append    bool
+	Create    bool
+	Exclusive bool
+	Sync      bool
+	Truncate  bool
+}
+
+// ParseFlags decomposes 'flag' into Flags. Unrecognized flags are ignored. Call Validate() to check for illegal combinations.
+func ParseFlags(flag int) Flags {
+	f := Flags{
+		Append:    flag&FlagAppend != 0,
+		Create:    flag&FlagCreate != 0,
+		Exclusive: flag&FlagExclusive != 0,
+		Sync:      flag&FlagSync != 0,
+		Truncate:  flag&FlagTruncate != 0,
+	}
+	switch {
+	case flag&FlagReadWrite != 0:
+		f.Read, f.Write = true, true
+	case flag&FlagWriteOnly != 0:
+		f.Write = true
+	default:
+		// FlagReadOnly = 0
+		f.Read = true
+	}
+	if flag&FlagReadWrite != 0 && flag&FlagWriteOnly != 0 {
+		f.Read, f.Write = false, false // exactly one of Read/Write flags must be specified
+	}
+	return f
+}
+
+// Flag recombines f into an OpenFile() flag
+func (f Flags) Flag() int {
+	var flag int
+	switch {
+	case f.Read && f.Write:
+		flag = FlagReadWrite
+	case f.Write:
+		flag = FlagWriteOnly
+	default:
+		flag = FlagReadOnly
+	}
+	for _, option := range []struct {
+		set  bool
+		flag int
+	}{
+		{f.Append, FlagAppend},
+		{f.Create, FlagCreate},
+		{f.Exclusive, FlagExclusive},
+		{f.Sync, FlagSync},
+		{f.Truncate, FlagTruncate},
+	} {
+		if option.set {
+			flag |= option.flag
+		}
+	}
+	return flag
+}
+
+// Modifies returns true if opening a file with f may change or create the file
+func (f Flags) Modifies() bool {
+	return f.Write || f.Append || f.Create || f.Truncate
+}
+
+// Validate returns ErrInvalid if f is an illegal combination of flags, where neither Read nor Write is set.
+//
+// Exclusive without Create is ignored, as is Sync for implementations which persist every write.
+// Truncate without Write is allowed, like os.OpenFile() allows O_RDONLY|O_TRUNC.
+func (f Flags) Validate() error {
+	if !f.Read && !f.Write {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// ValidateFlags returns ErrInvalid if 'flag' is an illegal combination of OpenFile() flags. See Flags.Validate() for details.
+func ValidateFlags(flag int) error {
+	return ParseFlags(flag).Validate()
+}
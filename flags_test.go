@@ -0,0 +1,55 @@
+package hackpadfs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestParseFlags(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		flag        int
+		expect      Flags
+		expectValid bool
+	}{
+		{
+			flag:        FlagReadOnly,
+			expect:      Flags{Read: true},
+			expectValid: true,
+		},
+		{
+			flag:        FlagWriteOnly | FlagCreate | FlagTruncate,
+			expect:      Flags{Write: true, Create: true, Truncate: true},
+			expectValid: true,
+		},
+		{
+			flag:        FlagReadWrite | FlagAppend | FlagExclusive | FlagSync,
+			expect:      Flags{Read: true, Write: true, Append: true, Exclusive: true, Sync: true},
+			expectValid: true,
+		},
+		{
+			flag:   FlagReadWrite | FlagWriteOnly,
+			expect: Flags{},
+		},
+		{
+			flag:        FlagReadOnly | FlagTruncate,
+			expect:      Flags{Read: true, Truncate: true},
+			expectValid: true,
+		},
+	} {
+		tc := tc
+		t.Run(fmt.Sprintf("%#x", tc.flag), func(t *testing.T) {
+			t.Parallel()
+			flags := ParseFlags(tc.flag)
+			assert.Equal(t, tc.expect, flags)
+			if tc.expectValid {
+				assert.NoError(t, ValidateFlags(tc.flag))
+				assert.Equal(t, tc.flag, flags.Flag())
+			} else {
+				assert.ErrorIs(t, ErrInvalid, ValidateFlags(tc.flag))
+			}
+		})
+	}
+}
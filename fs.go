@@ -2,10 +2,14 @@
 package hackpadfs
 
 import (
+	"context"
 	"errors"
 	gofs "io/fs"
 	gopath "path"
+	"sort"
 	"time"
+
+	"github.com/hack-pad/hackpadfs/internal/fspath"
 )
 
 // FS provides access to a file system and its files.
@@ -92,12 +96,26 @@ type ChtimesFS interface {
 	Chtimes(name string, atime time.Time, mtime time.Time) error
 }
 
+// ApplyMetadataFS is an FS that can change many files' metadata at once, like in a single transaction. Should match the behavior of ApplyMetadata().
+type ApplyMetadataFS interface {
+	FS
+	ApplyMetadata(metadata map[string]Metadata) error
+}
+
 // ReadDirFS is an FS that can read a directory and return its DirEntry's. Should match the behavior of os.ReadDir().
 type ReadDirFS interface {
 	FS
 	ReadDir(name string) ([]DirEntry, error)
 }
 
+// ReadDirPagedFS is an FS that can read a directory one page at a time, for backends which list with continuation tokens like S3.
+type ReadDirPagedFS interface {
+	FS
+	// ReadDirN returns the page of directory entries following 'token' and the token for the next page. Pass an empty 'token' to read the first page.
+	// Returns an empty 'nextToken' after the last page. Tokens are opaque and entries are returned in an order chosen by the FS, consistent between pages.
+	ReadDirN(name, token string) (entries []DirEntry, nextToken string, err error)
+}
+
 // ReadFileFS is an FS that can read an entire file in one pass. Should match the behavior of os.ReadFile().
 type ReadFileFS interface {
 	FS
@@ -110,12 +128,54 @@ type WriteFileFS interface {
 	WriteFile(name string, data []byte, perm FileMode) error
 }
 
-// SymlinkFS is an FS that can create symlinks. Should match the behavior of os.Symlink().
+// SymlinkFS is an FS that can create symlinks. Should match the behavior of os.Symlink(), except 'oldname' is a path in the FS, not relative to the directory of 'newname'.
 type SymlinkFS interface {
 	FS
 	Symlink(oldname, newname string) error
 }
 
+// ReadlinkFS is an FS that can read the destination of symlinks. Should match the behavior of os.Readlink(), except the destination is a path in the FS, like the 'oldname' passed to Symlink().
+type ReadlinkFS interface {
+	FS
+	Readlink(name string) (string, error)
+}
+
+// SameFileFS is an FS that can report whether two FileInfo's describe the same file, i.e. the same device and inode. Should match the behavior of os.SameFile().
+type SameFileFS interface {
+	FS
+	SameFile(fi1, fi2 FileInfo) bool
+}
+
+// QuotaFS is an FS that can report how much storage it uses and how much it may use.
+type QuotaFS interface {
+	FS
+	Usage(ctx context.Context) (StorageUsage, error)
+}
+
+// PingFS is an FS that can check its backend is reachable and working, like a remote store's connection. Should match the behavior of Ping().
+type PingFS interface {
+	FS
+	Ping(ctx context.Context) error
+}
+
+// UsageFS is an FS that can total the files under a directory itself, like a backend which sums its stored sizes server-side. Should match the behavior of DiskUsage().
+type UsageFS interface {
+	FS
+	DiskUsage(root string) (files, dirs, bytes int64, err error)
+}
+
+// WatchFS is an FS that can report changes to its files, including changes made by other clients sharing the same storage. Should match the behavior of Watch().
+type WatchFS interface {
+	FS
+	Watch(ctx context.Context, name string) (<-chan Event, error)
+}
+
+// CloserFS is an FS which holds resources until it's closed, like database handles or background goroutines.
+type CloserFS interface {
+	FS
+	Close() error
+}
+
 // MountFS is an FS that meshes one or more FS's together.
 // Returns the FS for a file located at 'name' and its 'subPath' inside that FS.
 type MountFS interface {
@@ -136,6 +196,42 @@ func WalkDir(fs FS, root string, fn WalkDirFunc) error {
 	return gofs.WalkDir(fs, root, fn)
 }
 
+// Filter decides if a walk visits 'name'. Return false to skip 'name', along with everything inside it if it's a directory.
+type Filter func(name string, dirEntry DirEntry) (bool, error)
+
+// WalkDirOptions contain options for WalkDirWithOptions()
+type WalkDirOptions struct {
+	// Filter skips the paths it returns false for, without calling the WalkDirFunc. Filter errors are passed to the WalkDirFunc like other walk errors.
+	Filter Filter
+}
+
+// WalkDirWithOptions is like WalkDir(), but with additional options. See WalkDirOptions for details.
+func WalkDirWithOptions(fs FS, root string, fn WalkDirFunc, options WalkDirOptions) error {
+	if options.Filter != nil {
+		fn = filterWalkDirFunc(options.Filter, fn)
+	}
+	return WalkDir(fs, root, fn)
+}
+
+func filterWalkDirFunc(filter Filter, fn WalkDirFunc) WalkDirFunc {
+	return func(name string, dirEntry DirEntry, err error) error {
+		if err != nil {
+			return fn(name, dirEntry, err)
+		}
+		visit, err := filter(name, dirEntry)
+		switch {
+		case err != nil:
+			return fn(name, dirEntry, err)
+		case visit:
+			return fn(name, dirEntry, nil)
+		case dirEntry.IsDir():
+			return SkipDir
+		default:
+			return nil
+		}
+	}
+}
+
 // Sub attempts to call an optimized fs.Sub() if available. Falls back to a small MountFS implementation.
 func Sub(fs FS, dir string) (FS, error) {
 	if fs, ok := fs.(SubFS); ok {
@@ -186,16 +282,24 @@ func Mkdir(fs FS, name string, perm FileMode) error {
 	return &PathError{Op: "mkdir", Path: name, Err: ErrNotImplemented}
 }
 
-// MkdirAll attempts to call an optimized fs.MkdirAll(), falls back to multiple fs.Mkdir() calls.
+// MkdirAll attempts to call an optimized fs.MkdirAll(), falls back to multiple fs.Mkdir() calls, unless Policy.NoMkdirAllFallback is set in CurrentPolicy().
 func MkdirAll(fs FS, path string, perm FileMode) error {
+	return CurrentPolicy().MkdirAll(fs, path, perm)
+}
+
+// MkdirAll is like hackpadfs.MkdirAll(), but uses Policy 'p' instead of CurrentPolicy()
+func (p Policy) MkdirAll(fs FS, path string, perm FileMode) error {
 	if fs, ok := fs.(MkdirAllFS); ok {
 		return fs.MkdirAll(path, perm)
 	}
 	if fs, ok := fs.(MountFS); ok {
 		mountFS, subPath := fs.Mount(path)
-		err := MkdirAll(mountFS, subPath, perm)
+		err := p.MkdirAll(mountFS, subPath, perm)
 		return stripErrPathPrefix(err, path, subPath)
 	}
+	if p.NoMkdirAllFallback {
+		return &PathError{Op: "mkdirall", Path: path, Err: ErrNotImplemented}
+	}
 	if !ValidPath(path) {
 		return &PathError{Op: "mkdirall", Path: path, Err: ErrInvalid}
 	}
@@ -233,16 +337,24 @@ func Remove(fs FS, name string) error {
 	return &PathError{Op: "remove", Path: name, Err: ErrNotImplemented}
 }
 
-// RemoveAll attempts to call an optimized fs.RemoveAll(), falls back to removing files and directories recursively.
+// RemoveAll attempts to call an optimized fs.RemoveAll(), falls back to removing files and directories recursively, unless Policy.NoRemoveAllFallback is set in CurrentPolicy().
 func RemoveAll(fs FS, path string) error {
+	return CurrentPolicy().RemoveAll(fs, path)
+}
+
+// RemoveAll is like hackpadfs.RemoveAll(), but uses Policy 'p' instead of CurrentPolicy()
+func (p Policy) RemoveAll(fs FS, path string) error {
 	if fs, ok := fs.(RemoveAllFS); ok {
 		return fs.RemoveAll(path)
 	}
 	if fs, ok := fs.(MountFS); ok {
 		mountFS, subPath := fs.Mount(path)
-		err := RemoveAll(mountFS, subPath)
+		err := p.RemoveAll(mountFS, subPath)
 		return stripErrPathPrefix(err, path, subPath)
 	}
+	if p.NoRemoveAllFallback {
+		return &PathError{Op: "removeall", Path: path, Err: ErrNotImplemented}
+	}
 
 	if !ValidPath(path) {
 		return &PathError{Op: "removeall", Path: path, Err: ErrInvalid}
@@ -335,16 +447,24 @@ func LstatOrStat(fs FS, name string) (FileInfo, error) {
 	return info, err
 }
 
-// Chmod attempts to call an optimized fs.Chmod(), falls back to opening the file and running file.Chmod().
+// Chmod attempts to call an optimized fs.Chmod(), falls back to opening the file and running file.Chmod(), unless Policy.NoChmodFallback is set in CurrentPolicy().
 func Chmod(fs FS, name string, mode FileMode) error {
+	return CurrentPolicy().Chmod(fs, name, mode)
+}
+
+// Chmod is like hackpadfs.Chmod(), but uses Policy 'p' instead of CurrentPolicy()
+func (p Policy) Chmod(fs FS, name string, mode FileMode) error {
 	if fs, ok := fs.(ChmodFS); ok {
 		return fs.Chmod(name, mode)
 	}
 	if fs, ok := fs.(MountFS); ok {
 		mountFS, subPath := fs.Mount(name)
-		err := Chmod(mountFS, subPath, mode)
+		err := p.Chmod(mountFS, subPath, mode)
 		return stripErrPathPrefix(err, name, subPath)
 	}
+	if p.NoChmodFallback {
+		return &PathError{Op: "chmod", Path: name, Err: ErrNotImplemented}
+	}
 	file, err := fs.Open(name)
 	if err != nil {
 		return &PathError{Op: "chmod", Path: name, Err: err}
@@ -353,16 +473,24 @@ func Chmod(fs FS, name string, mode FileMode) error {
 	return ChmodFile(file, mode)
 }
 
-// Chown attempts to call an optimized fs.Chown(), falls back to opening the file and running file.Chown().
+// Chown attempts to call an optimized fs.Chown(), falls back to opening the file and running file.Chown(), unless Policy.NoChownFallback is set in CurrentPolicy().
 func Chown(fs FS, name string, uid, gid int) error {
+	return CurrentPolicy().Chown(fs, name, uid, gid)
+}
+
+// Chown is like hackpadfs.Chown(), but uses Policy 'p' instead of CurrentPolicy()
+func (p Policy) Chown(fs FS, name string, uid, gid int) error {
 	if fs, ok := fs.(ChownFS); ok {
 		return fs.Chown(name, uid, gid)
 	}
 	if fs, ok := fs.(MountFS); ok {
 		mountFS, subPath := fs.Mount(name)
-		err := Chown(mountFS, subPath, uid, gid)
+		err := p.Chown(mountFS, subPath, uid, gid)
 		return stripErrPathPrefix(err, name, subPath)
 	}
+	if p.NoChownFallback {
+		return &PathError{Op: "chown", Path: name, Err: ErrNotImplemented}
+	}
 	file, err := fs.Open(name)
 	if err != nil {
 		return &PathError{Op: "chown", Path: name, Err: err}
@@ -371,16 +499,24 @@ func Chown(fs FS, name string, uid, gid int) error {
 	return ChownFile(file, uid, gid)
 }
 
-// Chtimes attempts to call an optimized fs.Chtimes(), falls back to opening the file and running file.Chtimes().
+// Chtimes attempts to call an optimized fs.Chtimes(), falls back to opening the file and running file.Chtimes(), unless Policy.NoChtimesFallback is set in CurrentPolicy().
 func Chtimes(fs FS, name string, atime time.Time, mtime time.Time) error {
+	return CurrentPolicy().Chtimes(fs, name, atime, mtime)
+}
+
+// Chtimes is like hackpadfs.Chtimes(), but uses Policy 'p' instead of CurrentPolicy()
+func (p Policy) Chtimes(fs FS, name string, atime time.Time, mtime time.Time) error {
 	if fs, ok := fs.(ChtimesFS); ok {
 		return fs.Chtimes(name, atime, mtime)
 	}
 	if fs, ok := fs.(MountFS); ok {
 		mountFS, subPath := fs.Mount(name)
-		err := Chtimes(mountFS, subPath, atime, mtime)
+		err := p.Chtimes(mountFS, subPath, atime, mtime)
 		return stripErrPathPrefix(err, name, subPath)
 	}
+	if p.NoChtimesFallback {
+		return &PathError{Op: "chtimes", Path: name, Err: ErrNotImplemented}
+	}
 	file, err := fs.Open(name)
 	if err != nil {
 		return &PathError{Op: "chtimes", Path: name, Err: err}
@@ -402,6 +538,37 @@ func ReadDir(fs FS, name string) ([]DirEntry, error) {
 	return gofs.ReadDir(fs, name)
 }
 
+// readDirPageSize is the number of entries in each page from ReadDirPaged() when falling back to ReadDir()
+const readDirPageSize = 1000
+
+// ReadDirPaged attempts to call an optimized fs.ReadDirN(), falls back to ReadDir() and returns a page of its entries.
+// Pass an empty 'token' to read the first page, then each returned 'nextToken' to read the next, until 'nextToken' is empty.
+//
+// The fallback reads the whole directory for every page, but only returns entries named after 'token', so pages stay consistent when entries are added or removed in between.
+func ReadDirPaged(fs FS, name, token string) (entries []DirEntry, nextToken string, err error) {
+	if fs, ok := fs.(ReadDirPagedFS); ok {
+		return fs.ReadDirN(name, token)
+	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(name)
+		entries, nextToken, err := ReadDirPaged(mountFS, subPath, token)
+		return entries, nextToken, stripErrPathPrefix(err, name, subPath)
+	}
+	entries, err = ReadDir(fs, name)
+	if err != nil {
+		return nil, "", err
+	}
+	start := sort.Search(len(entries), func(i int) bool {
+		return entries[i].Name() > token
+	})
+	entries = entries[start:]
+	if len(entries) > readDirPageSize {
+		entries = entries[:readDirPageSize]
+		nextToken = entries[len(entries)-1].Name()
+	}
+	return entries, nextToken, nil
+}
+
 // ReadFile attempts to call an optimized fs.ReadFile(), falls back to io/fs.ReadFile().
 func ReadFile(fs FS, name string) ([]byte, error) {
 	if fs, ok := fs.(ReadFileFS); ok {
@@ -437,10 +604,109 @@ func WriteFullFile(fs FS, name string, data []byte, perm FileMode) error {
 	return err
 }
 
-// Symlink creates a symlink. Fails with a not implemented error if it's not a SymlinkFS.
+// Symlink creates a symlink at 'newname'. Fails with a not implemented error if it's not a SymlinkFS or a MountFS of one.
+// On a MountFS, 'oldname' must be inside the same mount as 'newname', or it fails with ErrInvalid.
 func Symlink(fs FS, oldname, newname string) error {
 	if fs, ok := fs.(SymlinkFS); ok {
 		return fs.Symlink(oldname, newname)
 	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(newname)
+		mountPoint := mountPointOf(newname, subPath)
+		if !fspath.Contains(mountPoint, oldname) {
+			return &LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrInvalid}
+		}
+		err := Symlink(mountFS, fspath.Rel(mountPoint, oldname), subPath)
+		return stripErrPathPrefix(err, newname, subPath)
+	}
 	return &LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrNotImplemented}
 }
+
+// Readlink returns the destination of a symlink. Fails with a not implemented error if it's not a ReadlinkFS.
+func Readlink(fs FS, name string) (string, error) {
+	if fs, ok := fs.(ReadlinkFS); ok {
+		return fs.Readlink(name)
+	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(name)
+		target, err := Readlink(mountFS, subPath)
+		if err != nil {
+			return "", stripErrPathPrefix(err, name, subPath)
+		}
+		return fspath.Join(mountPointOf(name, subPath), target), nil
+	}
+	return "", &PathError{Op: "readlink", Path: name, Err: ErrNotImplemented}
+}
+
+// SameFile attempts to call an optimized fs.SameFile(), falls back to false if 'fs' can't identify files.
+func SameFile(fs FS, fi1, fi2 FileInfo) bool {
+	if fs, ok := fs.(SameFileFS); ok {
+		return fs.SameFile(fi1, fi2)
+	}
+	return false
+}
+
+// Close releases the resources held by 'fs', if it's a CloserFS. Does nothing for other FS's, so it's safe to call on any FS during shutdown.
+// An FS must not be used after it's closed, though open files may continue to work until they're closed.
+func Close(fs FS) error {
+	if fs, ok := fs.(CloserFS); ok {
+		return fs.Close()
+	}
+	return nil
+}
+
+// Ping checks the backend of 'fs' is reachable and working, if it's a PingFS, so services can report an unhealthy FS before its first file operation fails.
+// Returns nil for other FS's, since they have no remote backend to check.
+func Ping(ctx context.Context, fs FS) error {
+	if fs, ok := fs.(PingFS); ok {
+		return fs.Ping(ctx)
+	}
+	return nil
+}
+
+// StorageUsage describes the storage used by an FS in bytes.
+type StorageUsage struct {
+	Used  int64
+	Quota int64 // Quota is the maximum number of bytes available, or 0 if unknown
+}
+
+// Usage returns the storage usage and quota of 'fs'. Fails with a not implemented error if it's not a QuotaFS.
+// A MountFS isn't asked for the FS mounted at its root, since that mount's quota doesn't cover the others. A MountFS should implement QuotaFS itself to report its usage.
+func Usage(ctx context.Context, fs FS) (StorageUsage, error) {
+	if fs, ok := fs.(QuotaFS); ok {
+		return fs.Usage(ctx)
+	}
+	return StorageUsage{}, &PathError{Op: "usage", Path: ".", Err: ErrNotImplemented}
+}
+
+// DiskUsage returns the number of files and directories at and under 'root', including 'root' itself, and the total size of its regular files in bytes.
+// Uses an optimized fs.DiskUsage() if available, otherwise walks 'root' with WalkDir() and reads each file's FileInfo.
+// Symlinks are counted as files, but not followed or included in 'bytes'.
+func DiskUsage(fs FS, root string) (files, dirs, bytes int64, err error) {
+	if fs, ok := fs.(UsageFS); ok {
+		return fs.DiskUsage(root)
+	}
+	err = WalkDir(fs, root, func(name string, dirEntry DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if dirEntry.IsDir() {
+			dirs++
+			return nil
+		}
+		files++
+		if !dirEntry.Type().IsRegular() {
+			return nil
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+		bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return files, dirs, bytes, nil
+}
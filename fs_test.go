@@ -1,13 +1,14 @@
 package hackpadfs_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
-	"github.com/hack-pad/hackpadfs/internal/mounttest"
 	"github.com/hack-pad/hackpadfs/mem"
 	"github.com/hack-pad/hackpadfs/mount"
 )
@@ -21,11 +22,12 @@ func TestFS(t *testing.T) {
 			requireNoError(tb, err)
 			fs, err := mount.NewFS(memRoot)
 			requireNoError(tb, err)
-			return mounttest.NewFS(fs)
+			return hackpadfs.FullFS(fs)
 		},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
+	fstest.StdFS(t, options)
 }
 
 func requireNoError(tb testing.TB, err error) {
@@ -133,3 +135,170 @@ func TestRemoveAll(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Zero(t, dir)
 }
+
+type quotaFS struct {
+	*simplerFS
+	usage hackpadfs.StorageUsage
+}
+
+func (fs *quotaFS) Usage(ctx context.Context) (hackpadfs.StorageUsage, error) {
+	return fs.usage, nil
+}
+
+func TestUsage(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	_, err := hackpadfs.Usage(ctx, makeSimplerFS(t))
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+	var pathErr *hackpadfs.PathError
+	if assert.ErrorAs(t, &pathErr, err) {
+		assert.Equal(t, "usage", pathErr.Op)
+		assert.Equal(t, ".", pathErr.Path)
+	}
+
+	expected := hackpadfs.StorageUsage{Used: 1, Quota: 2}
+	usage, err := hackpadfs.Usage(ctx, &quotaFS{simplerFS: makeSimplerFS(t), usage: expected})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, usage)
+}
+
+type pingFS struct {
+	*simplerFS
+	err error
+}
+
+func (fs *pingFS) Ping(ctx context.Context) error {
+	return fs.err
+}
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	assert.NoError(t, hackpadfs.Ping(ctx, makeSimplerFS(t)))
+	assert.NoError(t, hackpadfs.Ping(ctx, &pingFS{simplerFS: makeSimplerFS(t)}))
+
+	someErr := errors.New("some error")
+	assert.Equal(t, someErr, hackpadfs.Ping(ctx, &pingFS{simplerFS: makeSimplerFS(t), err: someErr}))
+	assert.Equal(t, someErr, hackpadfs.Ping(ctx, hackpadfs.FullFS(&pingFS{simplerFS: makeSimplerFS(t), err: someErr})))
+}
+
+type closerFS struct {
+	*simplerFS
+	closes int
+	err    error
+}
+
+func (fs *closerFS) Close() error {
+	fs.closes++
+	return fs.err
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+	assert.NoError(t, hackpadfs.Close(makeSimplerFS(t)))
+
+	fs := &closerFS{simplerFS: makeSimplerFS(t)}
+	assert.NoError(t, hackpadfs.Close(fs))
+	assert.Equal(t, 1, fs.closes)
+
+	someErr := errors.New("some error")
+	assert.Equal(t, someErr, hackpadfs.Close(&closerFS{simplerFS: makeSimplerFS(t), err: someErr}))
+	assert.Equal(t, someErr, hackpadfs.Close(hackpadfs.FullFS(&closerFS{simplerFS: makeSimplerFS(t), err: someErr})))
+}
+
+func TestReadDirPaged(t *testing.T) {
+	t.Parallel()
+	fs := makeSimplerFS(t)
+	const fileCount = 2500
+	var expectedNames []string
+	for i := 0; i < fileCount; i++ {
+		name := fmt.Sprintf("%04d", i)
+		requireNoError(t, hackpadfs.WriteFullFile(fs, name, nil, 0600))
+		expectedNames = append(expectedNames, name)
+	}
+
+	var (
+		names []string
+		pages int
+		token string
+	)
+	for {
+		entries, nextToken, err := hackpadfs.ReadDirPaged(fs, ".", token)
+		requireNoError(t, err)
+		pages++
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if nextToken == "" {
+			break
+		}
+		token = nextToken
+	}
+	assert.Equal(t, 3, pages)
+	assert.Equal(t, expectedNames, names)
+
+	_, _, err := hackpadfs.ReadDirPaged(fs, "missing", "")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+type pagedFS struct {
+	*simplerFS
+	tokens []string
+}
+
+func (fs *pagedFS) ReadDirN(name, token string) ([]hackpadfs.DirEntry, string, error) {
+	fs.tokens = append(fs.tokens, token)
+	return nil, "", nil
+}
+
+func TestReadDirPagedFS(t *testing.T) {
+	t.Parallel()
+	fs := &pagedFS{simplerFS: makeSimplerFS(t)}
+	_, _, err := hackpadfs.ReadDirPaged(fs, ".", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, fs.tokens)
+}
+
+func TestDiskUsage(t *testing.T) {
+	t.Parallel()
+	fs := makeSimplerFS(t)
+	requireNoError(t, hackpadfs.MkdirAll(fs, "foo/bar", 0700))
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "foo/baz", []byte("baz"), 0600))
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "foo/bar/biff", []byte("biff"), 0600))
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "boo", []byte("boo"), 0600))
+
+	files, dirs, bytes, err := hackpadfs.DiskUsage(fs, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, [3]int64{3, 3, 10}, [3]int64{files, dirs, bytes})
+
+	files, dirs, bytes, err = hackpadfs.DiskUsage(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, [3]int64{2, 2, 7}, [3]int64{files, dirs, bytes})
+
+	files, dirs, bytes, err = hackpadfs.DiskUsage(fs, "boo")
+	assert.NoError(t, err)
+	assert.Equal(t, [3]int64{1, 0, 3}, [3]int64{files, dirs, bytes})
+
+	_, _, _, err = hackpadfs.DiskUsage(fs, "missing")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+type usageFS struct {
+	*simplerFS
+	roots []string
+}
+
+func (fs *usageFS) DiskUsage(root string) (files, dirs, bytes int64, err error) {
+	fs.roots = append(fs.roots, root)
+	return 1, 2, 3, nil
+}
+
+func TestDiskUsageFS(t *testing.T) {
+	t.Parallel()
+	fs := &usageFS{simplerFS: makeSimplerFS(t)}
+	files, dirs, bytes, err := hackpadfs.DiskUsage(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, [3]int64{1, 2, 3}, [3]int64{files, dirs, bytes})
+	assert.Equal(t, []string{"foo"}, fs.roots)
+}
@@ -0,0 +1,170 @@
+package fsmatch
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/fspath"
+)
+
+// GitIgnore is the name of git's ignore files
+const GitIgnore = ".gitignore"
+
+// Ignore matches paths against ignore files found in the tree being matched, with the same rules as git's .gitignore files:
+//   - Blank lines and lines starting with "#" are skipped
+//   - A leading "!" re-includes paths ignored by earlier patterns, although paths inside ignored directories stay ignored
+//   - A trailing "/" only matches directories
+//   - Patterns containing a "/" before the end match relative to their ignore file's directory. Others match names at any depth below it.
+//   - "**" matches zero or more directories
+//
+// Ignore files in subdirectories take precedence over their parents. Files are read once, when first needed.
+type Ignore struct {
+	fs       hackpadfs.FS
+	root     string
+	fileName string
+
+	mu    sync.Mutex
+	rules map[string][]ignoreRule // ignore file rules by directory, relative to root
+}
+
+type ignoreRule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// NewIgnore returns an Ignore reading files named 'fileName' in and under 'root' in 'fs', like GitIgnore.
+func NewIgnore(fs hackpadfs.FS, root, fileName string) *Ignore {
+	return &Ignore{
+		fs:       fs,
+		root:     root,
+		fileName: fileName,
+		rules:    make(map[string][]ignoreRule),
+	}
+}
+
+// Ignored returns true if 'name' is ignored by the ignore files in its parent directories, or if one of its parent directories is ignored.
+// 'name' is a path in the FS under the Ignore's root.
+func (i *Ignore) Ignored(name string, isDir bool) (bool, error) {
+	rel := fspath.Rel(i.root, name)
+	if rel == "." {
+		return false, nil
+	}
+	segments := splitName(rel)
+	for end := 1; end < len(segments); end++ {
+		ignored, err := i.ignored(segments[:end], true)
+		if err != nil || ignored {
+			return ignored, err
+		}
+	}
+	return i.ignored(segments, isDir)
+}
+
+// ignored returns true if the last rule matching 'segments', from any ignore file in its parent directories, ignores it
+func (i *Ignore) ignored(segments []string, isDir bool) (bool, error) {
+	ignored := false
+	for end := 0; end < len(segments); end++ {
+		dir := path.Join(append([]string{"."}, segments[:end]...)...)
+		rules, err := i.loadRules(dir)
+		if err != nil {
+			return false, err
+		}
+		for _, rule := range rules {
+			if (!rule.dirOnly || isDir) && rule.match(segments[end:]) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored, nil
+}
+
+func (r ignoreRule) match(name []string) bool {
+	if !matchSegments(r.segments, name) {
+		return false
+	}
+	if last := len(r.segments) - 1; last > 0 && r.segments[last] == "**" {
+		// a trailing "/**" matches everything inside a directory, but not the directory itself
+		return !matchSegments(r.segments[:last], name)
+	}
+	return true
+}
+
+// Filter implements hackpadfs.Filter, skipping ignored paths. Use it in options like hackpadfs.CopyOptions.Filter.
+func (i *Ignore) Filter(name string, dirEntry hackpadfs.DirEntry) (bool, error) {
+	ignored, err := i.Ignored(name, dirEntry.IsDir())
+	return !ignored, err
+}
+
+// loadRules returns the rules of the ignore file in 'dir', relative to the Ignore's root
+func (i *Ignore) loadRules(dir string) ([]ignoreRule, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if rules, loaded := i.rules[dir]; loaded {
+		return rules, nil
+	}
+	contents, err := hackpadfs.ReadFile(i.fs, path.Join(i.root, dir, i.fileName))
+	if err != nil && !errors.Is(err, hackpadfs.ErrNotExist) && !errors.Is(err, hackpadfs.ErrNotDir) {
+		return nil, err
+	}
+	rules, err := parseIgnore(contents)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "parse", Path: path.Join(i.root, dir, i.fileName), Err: err}
+	}
+	i.rules[dir] = rules
+	return rules, nil
+}
+
+// parseIgnore parses the lines of an ignore file into rules
+func parseIgnore(contents []byte) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := trimTrailingSpaces(strings.TrimSuffix(scanner.Text(), "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		if !anchored {
+			line = "**/" + line
+		}
+		segments, err := splitPattern(strings.ReplaceAll(line, "[!", "[^"))
+		if err != nil {
+			continue // skip malformed patterns, like git
+		}
+		rule.segments = segments
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// trimTrailingSpaces removes trailing spaces from 'line', unless they're escaped with a backslash
+func trimTrailingSpaces(line string) string {
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' {
+		if end > 1 && line[end-2] == '\\' {
+			break
+		}
+		end--
+	}
+	return line[:end]
+}
@@ -0,0 +1,134 @@
+package fsmatch
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func makeIgnoreFS(tb testing.TB) *mem.FS {
+	tb.Helper()
+	fs, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	requireNoError := func(err error) {
+		tb.Helper()
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+	}
+	requireNoError(fs.MkdirAll("repo/build", 0700))
+	requireNoError(fs.MkdirAll("repo/src/gen", 0700))
+	requireNoError(fs.MkdirAll("repo/logs/keep", 0700))
+	files := map[string]string{
+		"repo/.gitignore": "# comment\n" +
+			"*.log\n" +
+			"!important.log\n" +
+			"/build/\n" +
+			"logs/**\n" +
+			"!logs/keep/\n" +
+			"tmp\n" +
+			"[\n",
+		"repo/src/.gitignore":  "gen/\n!debug.log\n",
+		"repo/main.go":         "",
+		"repo/debug.log":       "",
+		"repo/important.log":   "",
+		"repo/build/out":       "",
+		"repo/src/main.go":     "",
+		"repo/src/debug.log":   "",
+		"repo/src/tmp":         "",
+		"repo/src/gen/gen.go":  "",
+		"repo/logs/a.txt":      "",
+		"repo/logs/keep/b.txt": "",
+		"repo/src/build":       "",
+	}
+	for name, contents := range files {
+		requireNoError(hackpadfs.WriteFullFile(fs, name, []byte(contents), 0600))
+	}
+	return fs
+}
+
+func TestIgnore(t *testing.T) {
+	t.Parallel()
+	fs := makeIgnoreFS(t)
+	ignore := NewIgnore(fs, "repo", GitIgnore)
+	for _, tc := range []struct {
+		name    string
+		isDir   bool
+		ignored bool
+	}{
+		{"repo", true, false},
+		{"repo/main.go", false, false},
+		{"repo/debug.log", false, true},
+		{"repo/important.log", false, false},
+		{"repo/build", true, true},
+		{"repo/build/out", false, true},
+		{"repo/src/build", false, false}, // anchored and directory-only
+		{"repo/src/tmp", false, true},
+		{"repo/src/debug.log", false, false}, // re-included by src/.gitignore
+		{"repo/src/gen", true, true},
+		{"repo/src/gen/gen.go", false, true},
+		{"repo/logs", true, false},
+		{"repo/logs/a.txt", false, true},
+		{"repo/logs/keep", true, false},
+		{"repo/logs/keep/b.txt", false, true}, // still matches logs/**, like git
+	} {
+		ignored, err := ignore.Ignored(tc.name, tc.isDir)
+		assert.NoError(t, err)
+		if ignored != tc.ignored {
+			t.Errorf("Ignored(%q) = %v, expected %v", tc.name, ignored, tc.ignored)
+		}
+	}
+}
+
+func TestIgnoreFilter(t *testing.T) {
+	t.Parallel()
+	fs := makeIgnoreFS(t)
+	var names []string
+	err := hackpadfs.WalkDirWithOptions(fs, "repo", func(name string, _ hackpadfs.DirEntry, err error) error {
+		names = append(names, name)
+		return err
+	}, hackpadfs.WalkDirOptions{Filter: NewIgnore(fs, "repo", GitIgnore).Filter})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"repo",
+		"repo/.gitignore",
+		"repo/important.log",
+		"repo/logs",
+		"repo/logs/keep",
+		"repo/main.go",
+		"repo/src",
+		"repo/src/.gitignore",
+		"repo/src/build",
+		"repo/src/debug.log",
+		"repo/src/main.go",
+	}, names)
+
+	dest, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.CopyFSWithOptions(dest, ".", fs, "repo", hackpadfs.CopyOptions{
+		Filter: NewIgnore(fs, "repo", GitIgnore).Filter,
+	}))
+	_, err = hackpadfs.Stat(dest, "src/main.go")
+	assert.NoError(t, err)
+	_, err = hackpadfs.Stat(dest, "build")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestMatcherFilter(t *testing.T) {
+	t.Parallel()
+	fs := makeIgnoreFS(t)
+	m, err := Compile("**/*.go", "!src/gen")
+	assert.NoError(t, err)
+	dest, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.CopyFSWithOptions(dest, ".", fs, "repo", hackpadfs.CopyOptions{
+		Filter: m.Filter("repo"),
+	}))
+	matches, err := Glob(dest, "**")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"build", "logs", "logs/keep", "main.go", "src", "src/main.go"}, matches)
+}
@@ -0,0 +1,146 @@
+// Package fsmatch matches FS paths against shell-style glob patterns, pruning directories which can't contain matches while walking.
+//
+// Patterns use path.Match syntax for each path segment, plus:
+//   - "**" as a whole segment matches zero or more segments, like "src/**/*.go"
+//   - "{a,b}" matches either alternative, like "*.{js,ts}". Braces may be nested.
+//   - a leading "!" excludes paths matching the rest of the pattern, along with everything inside them
+package fsmatch
+
+import (
+	"strings"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/fspath"
+)
+
+// Matcher matches paths included by at least one pattern and not excluded by any "!" pattern
+type Matcher struct {
+	includes []*pattern
+	excludes []*pattern
+}
+
+// Compile returns a Matcher for 'patterns'. Patterns starting with "!" are exclusions. If only exclusions are given, every other path is included.
+// Fails with path.ErrBadPattern if a pattern is malformed.
+func Compile(patterns ...string) (*Matcher, error) {
+	var m Matcher
+	for _, p := range patterns {
+		exclude := strings.HasPrefix(p, "!")
+		compiled, err := compilePattern(strings.TrimPrefix(p, "!"))
+		if err != nil {
+			return nil, &hackpadfs.PathError{Op: "compile", Path: p, Err: err}
+		}
+		if exclude {
+			m.excludes = append(m.excludes, compiled)
+		} else {
+			m.includes = append(m.includes, compiled)
+		}
+	}
+	if len(m.includes) == 0 {
+		m.includes = append(m.includes, &pattern{alternatives: [][]string{{"**"}}})
+	}
+	return &m, nil
+}
+
+// Match returns true if 'name' matches an included pattern, and neither 'name' nor its parent directories match an excluded pattern. 'name' must be a valid FS path.
+func (m *Matcher) Match(name string) bool {
+	segments := splitName(name)
+	if !m.included(segments) {
+		return false
+	}
+	if len(segments) == 0 {
+		return !m.excluded(segments)
+	}
+	for i := len(segments); i > 0; i-- {
+		if m.excluded(segments[:i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Matcher) included(segments []string) bool {
+	for _, p := range m.includes {
+		if p.match(segments) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Matcher) excluded(segments []string) bool {
+	for _, p := range m.excludes {
+		if p.match(segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipDir returns true if neither 'dir' nor anything inside it can match. Walks can skip these directories entirely.
+// Assumes the parent directories of 'dir' were not skipped.
+func (m *Matcher) SkipDir(dir string) bool {
+	segments := splitName(dir)
+	if m.excluded(segments) {
+		return true
+	}
+	for _, p := range m.includes {
+		if p.matchPrefix(segments) {
+			return false
+		}
+	}
+	return true
+}
+
+// WalkDirFunc returns a hackpadfs.WalkDirFunc for walking 'root', which calls 'fn' only for paths matching 'm' and skips directories which can't contain matches.
+// Paths are matched relative to 'root', but 'fn' receives the same paths as hackpadfs.WalkDir(). Errors are always passed to 'fn'.
+func (m *Matcher) WalkDirFunc(root string, fn hackpadfs.WalkDirFunc) hackpadfs.WalkDirFunc {
+	return func(name string, dirEntry hackpadfs.DirEntry, err error) error {
+		if err != nil {
+			return fn(name, dirEntry, err)
+		}
+		rel := fspath.Rel(root, name)
+		if dirEntry.IsDir() && m.SkipDir(rel) {
+			return hackpadfs.SkipDir
+		}
+		if !m.Match(rel) {
+			return nil
+		}
+		return fn(name, dirEntry, err)
+	}
+}
+
+// Filter returns a hackpadfs.Filter for walking 'root', which skips paths not matching 'm' relative to 'root'. Use it in options like hackpadfs.CopyOptions.Filter.
+// Unlike WalkDirFunc(), directories which don't match but may contain matches are still visited, so copies can create them.
+func (m *Matcher) Filter(root string) hackpadfs.Filter {
+	return func(name string, dirEntry hackpadfs.DirEntry) (bool, error) {
+		rel := fspath.Rel(root, name)
+		if dirEntry.IsDir() {
+			return !m.SkipDir(rel), nil
+		}
+		return m.Match(rel), nil
+	}
+}
+
+// WalkDir walks the file tree rooted at 'root' like hackpadfs.WalkDir(), calling 'fn' only for paths matching 'm' relative to 'root'.
+func WalkDir(fs hackpadfs.FS, root string, m *Matcher, fn hackpadfs.WalkDirFunc) error {
+	return hackpadfs.WalkDir(fs, root, m.WalkDirFunc(root, fn))
+}
+
+// Glob returns the sorted paths in 'fs' matching 'patterns', excluding the root ".". See Compile() for pattern syntax.
+func Glob(fs hackpadfs.FS, patterns ...string) ([]string, error) {
+	m, err := Compile(patterns...)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	err = WalkDir(fs, ".", m, func(name string, _ hackpadfs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." {
+			matches = append(matches, name)
+		}
+		return nil
+	})
+	return matches, err
+}
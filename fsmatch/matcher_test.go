@@ -0,0 +1,103 @@
+package fsmatch
+
+import (
+	"path"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestMatcher(t *testing.T) {
+	t.Parallel()
+	m, err := Compile("**/*.go", "!vendor", "!**/*_test.go")
+	assert.NoError(t, err)
+	assert.Equal(t, true, m.Match("main.go"))
+	assert.Equal(t, true, m.Match("cmd/main.go"))
+	assert.Equal(t, false, m.Match("main_test.go"))
+	assert.Equal(t, false, m.Match("vendor/lib.go"))
+	assert.Equal(t, false, m.Match("README.md"))
+
+	assert.Equal(t, false, m.SkipDir("."))
+	assert.Equal(t, false, m.SkipDir("cmd"))
+	assert.Equal(t, true, m.SkipDir("vendor"))
+
+	m, err = Compile("!node_modules")
+	assert.NoError(t, err)
+	assert.Equal(t, true, m.Match("."))
+	assert.Equal(t, true, m.Match("index.js"))
+	assert.Equal(t, false, m.Match("node_modules/foo/index.js"))
+
+	m, err = Compile("src/*.ts")
+	assert.NoError(t, err)
+	assert.Equal(t, true, m.SkipDir("lib"))
+	assert.Equal(t, true, m.SkipDir("src/sub"))
+
+	_, err = Compile("ok", "!{bad")
+	assert.ErrorIs(t, path.ErrBadPattern, err)
+}
+
+// countingFS counts calls to ReadDir()
+type countingFS struct {
+	*mem.FS
+	readDirs []string
+}
+
+func (fs *countingFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	fs.readDirs = append(fs.readDirs, name)
+	return hackpadfs.ReadDir(fs.FS, name)
+}
+
+func makeFS(tb testing.TB) *countingFS {
+	tb.Helper()
+	memFS, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	for _, dir := range []string{"src/sub", "vendor/lib", "docs"} {
+		if !assert.NoError(tb, memFS.MkdirAll(dir, 0700)) {
+			tb.FailNow()
+		}
+	}
+	for _, name := range []string{"main.go", "src/a.go", "src/a_test.go", "src/sub/b.go", "vendor/lib/c.go", "docs/index.md"} {
+		if !assert.NoError(tb, hackpadfs.WriteFullFile(memFS, name, nil, 0600)) {
+			tb.FailNow()
+		}
+	}
+	return &countingFS{FS: memFS}
+}
+
+func TestGlob(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	matches, err := Glob(fs, "**/*.go", "!vendor", "!**/*_test.go")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main.go", "src/a.go", "src/sub/b.go"}, matches)
+	assert.Equal(t, []string{".", "docs", "src", "src/sub"}, fs.readDirs)
+
+	fs.readDirs = nil
+	matches, err = Glob(fs, "src/*.{go,md}")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"src/a.go", "src/a_test.go"}, matches)
+	assert.Equal(t, []string{".", "src"}, fs.readDirs) // pruned docs, vendor, and src/sub
+}
+
+func TestWalkDir(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	m, err := Compile("*.go")
+	assert.NoError(t, err)
+	var names []string
+	err = WalkDir(fs, "src", m, func(name string, _ hackpadfs.DirEntry, err error) error {
+		names = append(names, name)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"src/a.go", "src/a_test.go"}, names)
+
+	err = WalkDir(fs, "missing", m, func(name string, _ hackpadfs.DirEntry, err error) error {
+		return err
+	})
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
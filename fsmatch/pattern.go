@@ -0,0 +1,174 @@
+package fsmatch
+
+import (
+	"path"
+	"strings"
+)
+
+// pattern is a compiled glob, expanded into one or more alternatives of path segments
+type pattern struct {
+	alternatives [][]string
+}
+
+// compilePattern expands braces in 'p' and validates each path segment
+func compilePattern(p string) (*pattern, error) {
+	expanded, err := expandBraces(p)
+	if err != nil {
+		return nil, err
+	}
+	var compiled pattern
+	for _, alternative := range expanded {
+		segments, err := splitPattern(alternative)
+		if err != nil {
+			return nil, err
+		}
+		compiled.alternatives = append(compiled.alternatives, segments)
+	}
+	return &compiled, nil
+}
+
+// splitPattern splits 'p' into path segments, collapsing repeated "**" segments
+func splitPattern(p string) ([]string, error) {
+	if p == "" {
+		return nil, path.ErrBadPattern
+	}
+	if p == "." {
+		return nil, nil
+	}
+	var segments []string
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, path.ErrBadPattern
+		}
+		if segment == "**" {
+			if len(segments) > 0 && segments[len(segments)-1] == "**" {
+				continue
+			}
+		} else if _, err := path.Match(segment, ""); err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// expandBraces returns every alternative of 'p' described by its brace expressions, like "{a,b}.go" to "a.go" and "b.go". Braces may be nested.
+func expandBraces(p string) ([]string, error) {
+	start, end, commas, err := findBraces(p)
+	if err != nil || start == -1 {
+		return []string{p}, err
+	}
+	prefix, suffix := p[:start], p[end+1:]
+	var expanded []string
+	last := start
+	for _, comma := range append(commas, end) {
+		alternatives, err := expandBraces(prefix + p[last+1:comma] + suffix)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, alternatives...)
+		last = comma
+	}
+	return expanded, nil
+}
+
+// findBraces returns the indexes of the first top-level brace expression in 'p' and the commas separating its alternatives. Returns -1 if there isn't one.
+// Braces inside character classes or escaped with a backslash are ignored.
+func findBraces(p string) (start, end int, commas []int, err error) {
+	start = -1
+	depth := 0
+	inClass := false
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case c == '\\':
+			i++ // skip escaped character
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+		case c == '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+			if depth == 0 {
+				return start, i, commas, nil
+			}
+		case c == ',' && depth == 1:
+			commas = append(commas, i)
+		}
+	}
+	if depth > 0 {
+		return -1, -1, nil, path.ErrBadPattern
+	}
+	return -1, -1, nil, nil
+}
+
+// splitName splits a valid FS path into segments. The root "." has no segments.
+func splitName(name string) []string {
+	if name == "." {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+// match returns true if 'name' matches any alternative
+func (p *pattern) match(name []string) bool {
+	for _, segments := range p.alternatives {
+		if matchSegments(segments, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPrefix returns true if 'dir', or any path inside it, may match an alternative
+func (p *pattern) matchPrefix(dir []string) bool {
+	for _, segments := range p.alternatives {
+		if matchSegmentsPrefix(segments, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 || !matchSegment(pattern[0], name[0]) {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func matchSegmentsPrefix(pattern, dir []string) bool {
+	for len(pattern) > 0 {
+		if len(dir) == 0 || pattern[0] == "**" {
+			return true
+		}
+		if !matchSegment(pattern[0], dir[0]) {
+			return false
+		}
+		pattern, dir = pattern[1:], dir[1:]
+	}
+	return len(dir) == 0
+}
+
+func matchSegment(pattern, name string) bool {
+	matched, _ := path.Match(pattern, name) // validated in splitPattern()
+	return matched
+}
@@ -0,0 +1,105 @@
+package fsmatch
+
+import (
+	"path"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestExpandBraces(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		pattern string
+		expect  []string
+	}{
+		{"foo", []string{"foo"}},
+		{"*.{js,ts}", []string{"*.js", "*.ts"}},
+		{"{a,b}/{c,d}", []string{"a/c", "a/d", "b/c", "b/d"}},
+		{"{a,b{c,d}}e", []string{"ae", "bce", "bde"}},
+		{"{,x}y", []string{"y", "xy"}},
+		{`\{a,b}`, []string{`\{a,b}`}},
+		{"[{]a", []string{"[{]a"}},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.pattern, func(t *testing.T) {
+			t.Parallel()
+			expanded, err := expandBraces(tc.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expect, expanded)
+		})
+	}
+
+	_, err := expandBraces("{a,b")
+	assert.ErrorIs(t, path.ErrBadPattern, err)
+}
+
+func TestPatternMatch(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"foo", "foo", true},
+		{"foo", "foo/bar", false},
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/hackpadfs/main.go", true},
+		{"src/**", "src", true},
+		{"src/**", "src/a/b", true},
+		{"src/**", "other/a", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/**/b", "a/x/b", true},
+		{"a/**/b", "a/x/y/c", false},
+		{"*.{js,ts}", "index.ts", true},
+		{"*.{js,ts}", "index.go", false},
+		{"?[ab]", "xa", true},
+		{".", ".", true},
+		{"**", ".", true},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.pattern+" "+tc.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := compilePattern(tc.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.match, p.match(splitName(tc.name)))
+		})
+	}
+}
+
+func TestPatternMatchPrefix(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		pattern string
+		dir     string
+		match   bool
+	}{
+		{"src/*.go", ".", true},
+		{"src/*.go", "src", true},
+		{"src/*.go", "vendor", false},
+		{"src/*.go", "src/sub", false},
+		{"**/*.go", "any/dir", true},
+		{"a/**/b", "a/x/y", true},
+		{"{a,b}/c", "b", true},
+		{"{a,b}/c", "d", false},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.pattern+" "+tc.dir, func(t *testing.T) {
+			t.Parallel()
+			p, err := compilePattern(tc.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.match, p.matchPrefix(splitName(tc.dir)))
+		})
+	}
+}
+
+func TestCompilePatternErrors(t *testing.T) {
+	t.Parallel()
+	for _, p := range []string{"", "/foo", "foo/", "a//b", "../foo", "[", "{a,[}"} {
+		_, err := compilePattern(p)
+		assert.ErrorIs(t, path.ErrBadPattern, err)
+	}
+}
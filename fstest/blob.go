@@ -0,0 +1,121 @@
+package fstest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+// blobChunkSize is the number of bytes grown or set by each operation in BenchmarkBlob, similar to a small file write
+const blobChunkSize = 512
+
+// BlobOptions contains required and optional settings for benchmarking a blob.Blob implementation with BenchmarkBlob.
+type BlobOptions struct {
+	// Name of this benchmark run. Required.
+	Name string
+	// NewBlob returns a new Blob containing a copy of 'data'. Required.
+	NewBlob func(tb testing.TB, data []byte) blob.Blob
+	// Sizes are the Blob lengths in bytes to run each benchmark at. Defaults to 4 KiB, 64 KiB, and 1 MiB.
+	Sizes []int
+}
+
+func setupBlobOptions(options *BlobOptions) error {
+	if options.Name == "" {
+		return errors.New("Blob benchmark name is required")
+	}
+	if options.NewBlob == nil {
+		return errors.New("NewBlob func is required")
+	}
+	if len(options.Sizes) == 0 {
+		options.Sizes = []int{4 << 10, 64 << 10, 1 << 20}
+	}
+	return nil
+}
+
+// BenchmarkBlob runs benchmarks of Grow, Set, View, and Truncate at each of options.Sizes.
+// Operations run through the blob package's helpers, like blob.Grow(), so Blobs without an optimized operation are measured with the same fallback a keyvalue.FS would use.
+//
+// For example, compare implementations with 'go test -run NONE -bench Blob -benchmem' and benchstat.
+func BenchmarkBlob(b *testing.B, options BlobOptions) {
+	b.Helper()
+	err := setupBlobOptions(&options)
+	if err != nil {
+		b.Fatal(err)
+		return
+	}
+	b.Run(options.Name, func(b *testing.B) {
+		for _, size := range options.Sizes {
+			size := size
+			b.Run(fmt.Sprint("Grow/size=", size), func(b *testing.B) {
+				benchmarkBlobGrow(b, options, size)
+			})
+			b.Run(fmt.Sprint("Set/size=", size), func(b *testing.B) {
+				benchmarkBlobSet(b, options, size)
+			})
+			b.Run(fmt.Sprint("View/size=", size), func(b *testing.B) {
+				benchmarkBlobView(b, options, size)
+			})
+			b.Run(fmt.Sprint("Truncate/size=", size), func(b *testing.B) {
+				benchmarkBlobTruncate(b, options, size)
+			})
+		}
+	})
+}
+
+// benchmarkBlobGrow grows an empty Blob to 'size' bytes, one chunk at a time, like appending to a file
+func benchmarkBlobGrow(b *testing.B, options BlobOptions, size int) {
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		blb := options.NewBlob(b, nil)
+		for length := 0; length < size; length += blobChunkSize {
+			if err := blob.Grow(blb, blobChunkSize); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// benchmarkBlobSet overwrites a 'size' byte Blob, one chunk at a time, like rewriting a file in place
+func benchmarkBlobSet(b *testing.B, options BlobOptions, size int) {
+	blb := options.NewBlob(b, make([]byte, size))
+	src := blob.NewBytes(make([]byte, blobChunkSize))
+	b.SetBytes(blobChunkSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		offset := int64(i*blobChunkSize) % int64(size-blobChunkSize+1)
+		if _, err := blob.Set(blb, src, offset); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkBlobView views the first half of a 'size' byte Blob, like reading part of a file
+func benchmarkBlobView(b *testing.B, options BlobOptions, size int) {
+	blb := options.NewBlob(b, make([]byte, size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := blob.View(blb, 0, int64(size/2)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkBlobTruncate truncates a new 'size' byte Blob to half its length. Creating each Blob isn't timed.
+func benchmarkBlobTruncate(b *testing.B, options BlobOptions, size int) {
+	data := make([]byte, size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		blb := options.NewBlob(b, data)
+		b.StartTimer()
+		if err := blob.Truncate(blb, int64(size/2)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
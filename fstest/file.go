@@ -139,7 +139,7 @@ func TestFileReadAt(tb testing.TB, o FSOptions) {
 		},
 	} {
 		o.tbRun(tb, tc.description, func(tb testing.TB) {
-			tbParallel(tb)
+			o.tbParallel(tb)
 			file, err := fs.Open("foo")
 			if !assert.NoError(tb, err) {
 				return
@@ -196,6 +196,31 @@ func TestFileSeek(tb testing.TB, o FSOptions) {
 		assert.NoError(tb, file.Close())
 	})
 
+	o.tbRun(tb, "seek current after append", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		file, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			_, err = hackpadfs.WriteFile(file, []byte(fileContents))
+			assert.NoError(tb, err)
+			assert.NoError(tb, file.Close())
+		}
+
+		fs := commit()
+		file, err = hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagAppend, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			return
+		}
+		_, err = hackpadfs.WriteFile(file, []byte(fileContents))
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		offset, err := hackpadfs.SeekFile(file, 0, io.SeekCurrent)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.Equal(tb, int64(2*len(fileContents)), offset)
+		assert.NoError(tb, file.Close())
+	})
+
 	o.tbRun(tb, "seek negative offset", func(tb testing.TB) {
 		setupFS, commit := o.Setup.FS(tb)
 		file, err := hackpadfs.Create(setupFS, "foo")
@@ -523,6 +548,51 @@ func TestFileReadDir(tb testing.TB, o FSOptions) {
 		}, asQuickDirInfos(tb, entriesAll))
 	})
 
+	o.tbRun(tb, "readdir sorted", func(tb testing.TB) {
+		if !o.Constraints.SortedReadDir {
+			tb.Skip("Directory entry order is not checked. Set Constraints.SortedReadDir to check it.")
+		}
+		setupFS, commit := o.Setup.FS(tb)
+		for _, name := range []string{"c", "a", "d"} {
+			file, err := hackpadfs.Create(setupFS, name)
+			if assert.NoError(tb, err) {
+				assert.NoError(tb, file.Close())
+			}
+		}
+		assert.NoError(tb, setupFS.Mkdir("b", 0700))
+
+		fs := commit()
+		file, err := fs.Open(".")
+		assert.NoError(tb, err)
+		entriesAll, err := hackpadfs.ReadDirFile(file, 0)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, file.Close())
+
+		file, err = fs.Open(".")
+		assert.NoError(tb, err)
+		var entries []hackpadfs.DirEntry
+		for {
+			batch, err := hackpadfs.ReadDirFile(file, 3)
+			if err == io.EOF {
+				break
+			}
+			if !assert.NoError(tb, err) {
+				break
+			}
+			entries = append(entries, batch...)
+		}
+		assert.NoError(tb, file.Close())
+
+		for _, entries := range [][]hackpadfs.DirEntry{entriesAll, entries} {
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			assert.Equal(tb, []string{"a", "b", "c", "d"}, names)
+		}
+	})
+
 	o.tbRun(tb, "readdir high N", func(tb testing.TB) {
 		setupFS, commit := o.Setup.FS(tb)
 		assert.NoError(tb, setupFS.Mkdir("bar", 0700))
@@ -612,6 +682,106 @@ func TestFileReadDir(tb testing.TB, o FSOptions) {
 	})
 }
 
+func TestFileReadDirNames(tb testing.TB, o FSOptions) {
+	o.tbRun(tb, "list subdirectory", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		file, err := hackpadfs.Create(setupFS, "foo/bar")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, file.Close())
+		}
+		assert.NoError(tb, setupFS.Mkdir("foo/baz", 0700))
+
+		fs := commit()
+		file, err = fs.Open("foo")
+		assert.NoError(tb, err)
+		names, err := hackpadfs.ReadDirNamesFile(file, 0)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, file.Close())
+		sort.Strings(names)
+		assert.Equal(tb, []string{"bar", "baz"}, names)
+	})
+
+	o.tbRun(tb, "readdirnames batches", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		file, err := hackpadfs.Create(setupFS, "foo/bar")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, file.Close())
+		}
+		assert.NoError(tb, setupFS.Mkdir("foo/baz", 0700))
+
+		fs := commit()
+		file, err = fs.Open("foo")
+		assert.NoError(tb, err)
+		names1, err := hackpadfs.ReadDirNamesFile(file, 1)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		names2, err := hackpadfs.ReadDirNamesFile(file, 1)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, file.Close())
+
+		names := append(names1, names2...)
+		sort.Strings(names)
+		assert.Equal(tb, []string{"bar", "baz"}, names)
+	})
+
+	o.tbRun(tb, "readdirnames sorted", func(tb testing.TB) {
+		if !o.Constraints.SortedReadDir {
+			tb.Skip("Directory entry order is not checked. Set Constraints.SortedReadDir to check it.")
+		}
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		for _, name := range []string{"foo/c", "foo/a", "foo/b"} {
+			file, err := hackpadfs.Create(setupFS, name)
+			if assert.NoError(tb, err) {
+				assert.NoError(tb, file.Close())
+			}
+		}
+
+		fs := commit()
+		file, err := fs.Open("foo")
+		assert.NoError(tb, err)
+		names1, err := hackpadfs.ReadDirNamesFile(file, 2)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		names2, err := hackpadfs.ReadDirNamesFile(file, 2)
+		assert.NoError(tb, err)
+		assert.NoError(tb, file.Close())
+		assert.Equal(tb, []string{"a", "b", "c"}, append(names1, names2...))
+	})
+
+	o.tbRun(tb, "list on file", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		{
+			f, err := hackpadfs.Create(setupFS, "foo")
+			if assert.NoError(tb, err) {
+				assert.NoError(tb, f.Close())
+			}
+		}
+		fs := commit()
+		file, err := fs.Open("foo")
+		assert.NoError(tb, err)
+		tb.Cleanup(func() { assert.NoError(tb, file.Close()) })
+		names, err := hackpadfs.ReadDirNamesFile(file, 0)
+		skipNotImplemented(tb, err)
+		if assert.IsType(tb, &hackpadfs.PathError{}, err) {
+			err := err.(*hackpadfs.PathError)
+			assert.ErrorIs(tb, hackpadfs.ErrNotDir, err)
+			assert.Contains(tb, []string{
+				"fdopendir",    // macOS
+				"readdirent",   // Linux
+				"readdir",      // Windows
+				"readdirnames", // keyvalue
+			}, err.Op)
+			o.assertEqualErrPath(tb, "foo", err.Path)
+		}
+		assert.Equal(tb, 0, len(names))
+	})
+}
+
 func TestFileStat(tb testing.TB, o FSOptions) {
 	testStat(tb, o, func(tb testing.TB, fs hackpadfs.FS, path string) (hackpadfs.FileInfo, error) {
 		tb.Helper()
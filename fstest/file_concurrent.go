@@ -2,6 +2,7 @@ package fstest
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/hack-pad/hackpadfs"
@@ -108,3 +109,60 @@ func TestConcurrentFileStat(tb testing.TB, o FSOptions) {
 		}
 	})
 }
+
+// TestConcurrentFileAppend writes records from several handles opened with FlagAppend.
+// Each Write() of a record must land at the end of the file as a whole, without overwriting or splitting other handles' records.
+func TestConcurrentFileAppend(tb testing.TB, o FSOptions) {
+	const recordsPerTask = 10
+	setupFS, commit := o.Setup.FS(tb)
+	f, err := hackpadfs.Create(setupFS, "foo")
+	if assert.NoError(tb, err) {
+		assert.NoError(tb, f.Close())
+	}
+	fs := commit()
+	// open every handle before writing, so no handle can rely on seeing the file's size as of when it was opened
+	files := make([]hackpadfs.File, defaultConcurrentTasks)
+	for i := range files {
+		f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagAppend, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			return
+		}
+		files[i] = f
+		i := i
+		tb.Cleanup(func() { // closes handles left open by a skip or failure
+			if files[i] != nil {
+				assert.NoError(tb, files[i].Close())
+			}
+		})
+	}
+	concurrentTasks(len(files), func(i int) {
+		for j := 0; j < recordsPerTask; j++ {
+			record := fmt.Sprintf("record-%03d-%03d\n", i, j)
+			n, err := hackpadfs.WriteFile(files[i], []byte(record))
+			skipNotImplemented(tb, err)
+			assert.NoError(tb, err)
+			assert.Equal(tb, len(record), n)
+		}
+	})
+	for i, f := range files {
+		files[i] = nil
+		assert.NoError(tb, f.Close())
+	}
+
+	contents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(tb, err)
+	records := strings.SplitAfter(string(contents), "\n")
+	if records[len(records)-1] == "" {
+		records = records[:len(records)-1]
+	}
+	assert.Equal(tb, defaultConcurrentTasks*recordsPerTask, len(records))
+	seen := make(map[string]bool, len(records))
+	for _, record := range records {
+		var i, j int
+		_, err := fmt.Sscanf(record, "record-%03d-%03d\n", &i, &j)
+		assert.NoError(tb, err)
+		assert.Equal(tb, false, seen[record])
+		seen[record] = true
+	}
+}
@@ -4,6 +4,7 @@ import (
 	// Avoid importing "os" package in fstest if we can, since not all environments may be able to support it.
 	// Not to mention it should compile a little faster. :)
 
+	"errors"
 	"fmt"
 	"io"
 	"testing"
@@ -482,6 +483,36 @@ func TestOpenFile(tb testing.TB, o FSOptions) {
 		}, fs)
 	})
 
+	o.tbRun(tb, "create exclusive on existing file", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		f, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+
+		fs := commit()
+		_, err = hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite|hackpadfs.FlagCreate|hackpadfs.FlagExclusive, 0666)
+		skipNotImplemented(tb, err)
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "open",
+			Path: "foo",
+			Err:  hackpadfs.ErrExist,
+		}, err)
+	})
+
+	o.tbRun(tb, "create exclusive on new file", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite|hackpadfs.FlagCreate|hackpadfs.FlagExclusive, 0666)
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"foo": {Mode: 0666},
+		}, fs)
+	})
+
 	o.tbRun(tb, "truncate on existing file", func(tb testing.TB) {
 		const fileContents = "hello world"
 		setupFS, commit := o.Setup.FS(tb)
@@ -847,6 +878,108 @@ func TestRename(tb testing.TB, o FSOptions) {
 			"baz/bar": {Mode: 0666, Size: int64(len(fileContents))},
 		}, fs)
 	})
+
+	o.tbRun(tb, "newpath is existing file", func(tb testing.TB) {
+		const fileContents = `hello world`
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte(fileContents), 0666))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "bar", []byte("existing file contents"), 0600))
+
+		fs := commit()
+		err := hackpadfs.Rename(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"bar": {Mode: 0666, Size: int64(len(fileContents))},
+		}, fs)
+		contents, err := hackpadfs.ReadFile(fs, "bar")
+		assert.NoError(tb, err)
+		assert.Equal(tb, fileContents, string(contents))
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	o.tbRun(tb, "newpath parent does not exist", func(tb testing.TB) {
+		const fileContents = `hello world`
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte(fileContents), 0666))
+
+		fs := commit()
+		err := hackpadfs.Rename(fs, "foo", "bar/baz")
+		skipNotImplemented(tb, err)
+		o.assertEqualLinkErr(tb, &hackpadfs.LinkError{
+			Op:  "rename",
+			Old: "foo",
+			New: "bar/baz",
+			Err: hackpadfs.ErrNotExist,
+		}, err)
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"foo": {Mode: 0666, Size: int64(len(fileContents))},
+		}, fs)
+		_, err = hackpadfs.Stat(fs, "bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	o.tbRun(tb, "oldpath is symlink", func(tb testing.TB) {
+		const fileContents = `hello world`
+		setupFS, commit := o.Setup.FS(tb)
+		if _, ok := setupFS.(hackpadfs.SymlinkFS); !ok {
+			tb.Skip("FS is not an SymlinkFS")
+		}
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte(fileContents), 0666))
+		err := hackpadfs.Symlink(setupFS, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+
+		fs := commit()
+		err = hackpadfs.Rename(fs, "bar", "baz")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		// the link is renamed, not its target
+		info, err := hackpadfs.Lstat(fs, "baz")
+		if !errors.Is(err, hackpadfs.ErrNotImplemented) {
+			assert.NoError(tb, err)
+			assert.Equal(tb, hackpadfs.ModeSymlink, info.Mode().Type())
+		}
+		contents, err := hackpadfs.ReadFile(fs, "baz")
+		assert.NoError(tb, err)
+		assert.Equal(tb, fileContents, string(contents))
+		contents, err = hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, fileContents, string(contents))
+		_, err = hackpadfs.LstatOrStat(fs, "bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	o.tbRun(tb, "open file", func(tb testing.TB) {
+		const fileContents = `hello world`
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte(fileContents), 0666))
+
+		fs := commit()
+		f, err := fs.Open("foo")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		err = hackpadfs.Rename(fs, "foo", "bar")
+		if errors.Is(err, hackpadfs.ErrNotImplemented) {
+			assert.NoError(tb, f.Close())
+			tb.Skip(err)
+		}
+		assert.NoError(tb, err)
+		// reading an open file still succeeds after it's renamed
+		buf := make([]byte, len(fileContents))
+		n, err := io.ReadFull(f, buf)
+		assert.NoError(tb, err)
+		assert.Equal(tb, fileContents, string(buf[:n]))
+		assert.NoError(tb, f.Close())
+
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"bar": {Mode: 0666, Size: int64(len(fileContents))},
+		}, fs)
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
 }
 
 // Stat returns a FileInfo describing the named file. If there is an error, it will be of type *PathError.
@@ -978,7 +1111,9 @@ func TestChmod(tb testing.TB, o FSOptions) {
 		if assert.NoError(tb, err) {
 			assert.NoError(tb, f.Close())
 		}
-		assert.NoError(tb, hackpadfs.Symlink(setupFS, "foo", "bar"))
+		err = hackpadfs.Symlink(setupFS, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
 
 		fs := commit()
 		err = hackpadfs.Chmod(fs, "foo", 0755)
@@ -1042,6 +1177,111 @@ func TestChtimes(tb testing.TB, o FSOptions) {
 	})
 }
 
+// TestDirModTime asserts that a directory's modified time changes when entries are created in, removed from, or renamed into or out of it.
+// Skipped unless Constraints.DirModTime is set.
+func TestDirModTime(tb testing.TB, o FSOptions) {
+	if !o.Constraints.DirModTime {
+		tb.Skip("Directory modified times are not checked. Set Constraints.DirModTime to check them.")
+	}
+	oldTime := time.Now().Add(-1 * time.Hour)
+	setOldTimes := func(tb testing.TB, setupFS SetupFS, dirs ...string) {
+		tb.Helper()
+		for _, dir := range dirs {
+			assert.NoError(tb, setupFS.Chtimes(dir, oldTime, oldTime))
+		}
+	}
+	assertModified := func(tb testing.TB, fs hackpadfs.FS, dir string) {
+		tb.Helper()
+		info, err := hackpadfs.Stat(fs, dir)
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, true, info.ModTime().After(oldTime.Add(time.Minute)), "Directory was not modified:", dir, info.ModTime())
+		}
+	}
+
+	o.tbRun(tb, "create file", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		setOldTimes(tb, setupFS, "foo")
+
+		fs := commit()
+		f, err := hackpadfs.Create(fs, "foo/bar")
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+		assertModified(tb, fs, "foo")
+	})
+
+	o.tbRun(tb, "mkdir", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		setOldTimes(tb, setupFS, "foo")
+
+		fs := commit()
+		err := hackpadfs.Mkdir(fs, "foo/bar", 0700)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assertModified(tb, fs, "foo")
+	})
+
+	o.tbRun(tb, "remove", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo/bar", nil, 0600))
+		setOldTimes(tb, setupFS, "foo")
+
+		fs := commit()
+		err := hackpadfs.Remove(fs, "foo/bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assertModified(tb, fs, "foo")
+	})
+
+	o.tbRun(tb, "remove all", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		assert.NoError(tb, setupFS.Mkdir("foo/bar", 0700))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo/bar/baz", nil, 0600))
+		setOldTimes(tb, setupFS, "foo")
+
+		fs := commit()
+		err := hackpadfs.RemoveAll(fs, "foo/bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assertModified(tb, fs, "foo")
+	})
+
+	o.tbRun(tb, "rename", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		assert.NoError(tb, setupFS.Mkdir("baz", 0700))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo/bar", nil, 0600))
+		setOldTimes(tb, setupFS, "foo", "baz")
+
+		fs := commit()
+		err := hackpadfs.Rename(fs, "foo/bar", "baz/bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assertModified(tb, fs, "foo")
+		assertModified(tb, fs, "baz")
+	})
+
+	o.tbRun(tb, "rename dir", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		assert.NoError(tb, setupFS.Mkdir("foo/bar", 0700))
+		assert.NoError(tb, setupFS.Mkdir("baz", 0700))
+		setOldTimes(tb, setupFS, "foo", "baz")
+
+		fs := commit()
+		err := hackpadfs.Rename(fs, "foo/bar", "baz/bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assertModified(tb, fs, "foo")
+		assertModified(tb, fs, "baz")
+	})
+}
+
 func TestReadFile(tb testing.TB, o FSOptions) {
 	o.tbRun(tb, "not exists", func(tb testing.TB) {
 		_, commit := o.Setup.FS(tb)
@@ -3,8 +3,12 @@ package fstest
 
 import (
 	"errors"
+	"fmt"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/hack-pad/hackpadfs"
 )
@@ -37,7 +41,15 @@ type FSOptions struct {
 	// NOTE: This MUST NOT be used lightly. Any custom skips severely impairs the quality of a standardized file system.
 	ShouldSkip func(facets Facets) bool
 
+	// OpTimeout panics with a dump of every goroutine's stack if a test runs this long, instead of hanging until 'go test' times out. Defaults to no timeout (0).
+	// Useful for finding deadlocks, like a custom store's transaction waiting on itself. Time spent running subtests or waiting to run in parallel isn't counted.
+	OpTimeout time.Duration
+
+	// LogFSOnFailure logs every file in the FS under test when a test fails, with LogFS. Useful for diagnosing failures of remote stores from CI logs.
+	LogFSOnFailure bool
+
 	skippedTests *sync.Map // type: Facets -> struct{}
+	opTimers     *sync.Map // type: testing.TB -> *time.Timer
 }
 
 // SetupFS is an FS that supports the baseline interfaces for creating files/directories and changing their metadata.
@@ -66,11 +78,17 @@ func (fn TestSetupFunc) FS(tb testing.TB) (SetupFS, func() hackpadfs.FS) {
 }
 
 // Constraints limits tests to a reduced set of assertions due to non-standard behavior. Avoid setting any of these.
+// DirModTime and SortedReadDir are the exceptions, they opt in to checks for behavior which isn't required of every FS.
 type Constraints struct {
 	// FileModeMask disables mode checks on the specified bits. Defaults to checking all bits (0).
 	FileModeMask hackpadfs.FileMode
 	// AllowErrPathPrefix enables more flexible FS path checks on error values by allowing an undefined path prefix.
 	AllowErrPathPrefix bool
+	// DirModTime enables checks that a directory's modified time changes when its entries are created, removed, or renamed, like on POSIX file systems.
+	DirModTime bool
+	// SortedReadDir enables checks that file.ReadDir() and file.ReadDirNames() return entries sorted by name, including across batches.
+	// io/fs.ReadDir() always sorts, but files like os.File return entries in directory order.
+	SortedReadDir bool
 }
 
 // Facets contains details for the current test.
@@ -82,6 +100,7 @@ type Facets struct {
 
 func setupOptions(options *FSOptions) error {
 	options.skippedTests = new(sync.Map)
+	options.opTimers = new(sync.Map)
 	if options.Name == "" {
 		return errors.New("FS test name is required")
 	}
@@ -95,6 +114,21 @@ func setupOptions(options *FSOptions) error {
 			return fs, func() hackpadfs.FS { return fs }
 		})
 	}
+	if options.LogFSOnFailure {
+		setup := options.Setup
+		options.Setup = TestSetupFunc(func(tb testing.TB) (SetupFS, func() hackpadfs.FS) {
+			setupFS, commit := setup.FS(tb)
+			return setupFS, func() hackpadfs.FS {
+				fs := commit()
+				tb.Cleanup(func() { // runs before the FS's own cleanup, so it's still available
+					if tb.Failed() {
+						LogFS(tb, fs)
+					}
+				})
+				return fs
+			}
+		})
+	}
 	if options.ShouldSkip == nil {
 		options.ShouldSkip = func(facets Facets) bool {
 			return false
@@ -105,6 +139,7 @@ func setupOptions(options *FSOptions) error {
 
 func (o FSOptions) tbRun(tb testing.TB, name string, subtest func(tb testing.TB)) {
 	tb.Helper()
+	defer o.pauseOpTimeout(tb)()
 	switch tb := tb.(type) {
 	case *testing.T:
 		tb.Run(name, func(t *testing.T) {
@@ -136,9 +171,69 @@ func (o FSOptions) tbRunInner(tb testing.TB, name string, subtest func(tb testin
 	if o.ShouldSkip(facets) {
 		tb.Skipf("FSOption.ShouldSkip: %#v", facets)
 	}
+	if o.OpTimeout > 0 {
+		name := tb.Name()
+		timer := time.AfterFunc(o.OpTimeout, func() {
+			panic(fmt.Sprintf("fstest: %s did not finish within OpTimeout %s. Goroutines:\n\n%s", name, o.OpTimeout, allStacks()))
+		})
+		o.opTimers.Store(tb, timer)
+		defer func() {
+			timer.Stop()
+			o.opTimers.Delete(tb)
+		}()
+	}
 	subtest(tb)
 }
 
+// pauseOpTimeout stops the OpTimeout timer for 'tb', if it has one. Call the returned func to restart it.
+func (o FSOptions) pauseOpTimeout(tb testing.TB) (resume func()) {
+	value, ok := o.opTimers.Load(tb)
+	if !ok {
+		return func() {}
+	}
+	timer := value.(*time.Timer)
+	timer.Stop()
+	return func() {
+		timer.Reset(o.OpTimeout)
+	}
+}
+
+// allStacks returns the stack traces of all goroutines
+func allStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// LogFS logs the path, mode, size, and modified time of every file in 'fs'
+func LogFS(tb testing.TB, fs hackpadfs.FS) {
+	tb.Helper()
+	var contents strings.Builder
+	contents.WriteString("FS contents:")
+	err := hackpadfs.WalkDir(fs, ".", func(path string, d hackpadfs.DirEntry, err error) error {
+		if err != nil {
+			fmt.Fprintf(&contents, "\n%s: %v", path, err)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			fmt.Fprintf(&contents, "\n%s: Unexpected error getting file info: %v", path, err)
+			return nil
+		}
+		fmt.Fprintf(&contents, "\n%s: %s %d %s", path, info.Mode(), info.Size(), info.ModTime().Format(time.RFC3339Nano))
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(&contents, "\nFailed to walk FS: %v", err)
+	}
+	tb.Log(contents.String())
+}
+
 // TestData reports metadata from test runs.
 type TestData struct {
 	// Skips includes details for every skipped test.
@@ -165,7 +260,7 @@ func FS(tb testing.TB, options FSOptions) TestData {
 		return TestData{}
 	}
 	options.tbRun(tb, options.Name+"_FS", func(tb testing.TB) {
-		tbParallel(tb)
+		options.tbParallel(tb)
 		tb.Helper()
 		runFS(tb, options)
 	})
@@ -182,15 +277,17 @@ func File(tb testing.TB, options FSOptions) TestData {
 		return TestData{}
 	}
 	options.tbRun(tb, options.Name+"_File", func(tb testing.TB) {
-		tbParallel(tb)
+		options.tbParallel(tb)
 		tb.Helper()
 		runFile(tb, options)
 	})
 	return options.generateTestData()
 }
 
-func tbParallel(tb testing.TB) {
+// tbParallel runs 'tb' in parallel, if supported. The OpTimeout timer is paused until it resumes.
+func (o FSOptions) tbParallel(tb testing.TB) {
 	if par, ok := tb.(interface{ Parallel() }); ok {
+		defer o.pauseOpTimeout(tb)()
 		par.Parallel()
 	}
 }
@@ -208,7 +305,7 @@ type subtaskFunc func(tb testing.TB, options FSOptions)
 
 func (r *tbSubtaskRunner) Run(name string, subtask subtaskFunc) {
 	r.options.tbRun(r.tb, name, func(tb testing.TB) {
-		tbParallel(tb)
+		r.options.tbParallel(tb)
 		tb.Helper()
 		subtask(tb, r.options)
 	})
@@ -224,6 +321,7 @@ func runFS(tb testing.TB, options FSOptions) {
 	runner.Run("fs.Chmod", TestChmod)
 	runner.Run("fs.Chtimes", TestChtimes)
 	runner.Run("fs.Create", TestCreate)
+	runner.Run("fs.DirModTime", TestDirModTime)
 	runner.Run("fs.Mkdir", TestMkdir)
 	runner.Run("fs.MkdirAll", TestMkdirAll)
 	runner.Run("fs.Open", TestOpen)
@@ -254,12 +352,14 @@ func runFile(tb testing.TB, options FSOptions) {
 	runner.Run("file.Write", TestFileWrite)
 	runner.Run("file.WriteAt", TestFileWriteAt)
 	runner.Run("file.ReadDir", TestFileReadDir)
+	runner.Run("file.ReadDirNames", TestFileReadDirNames)
 	runner.Run("file.Stat", TestFileStat)
 	runner.Run("file.Sync", TestFileSync)
 	runner.Run("file.Truncate", TestFileTruncate)
 
 	runner.Run("file_concurrent.Read", TestConcurrentFileRead)
 	runner.Run("file_concurrent.Write", TestConcurrentFileWrite)
+	runner.Run("file_concurrent.Append", TestConcurrentFileAppend)
 	runner.Run("file_concurrent.Stat", TestConcurrentFileStat)
 }
 
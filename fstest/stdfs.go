@@ -0,0 +1,88 @@
+package fstest
+
+import (
+	"strings"
+	"testing"
+	gofstest "testing/fstest"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// stdFSFiles are the files created for StdFS and their contents. Paths ending in "/" are directories.
+var stdFSFiles = []struct {
+	path     string
+	contents string
+}{
+	{path: "file", contents: "file"},
+	{path: "empty-file"},
+	{path: "dir/"},
+	{path: "dir/a", contents: "a"},
+	{path: "dir/b", contents: "bb"},
+	{path: "dir/sub/"},
+	{path: "dir/sub/c", contents: "ccc"},
+	{path: "empty-dir/"},
+	{path: "name with spaces", contents: "spaces"},
+}
+
+// StdFS runs the standard library's testing/fstest.TestFS() against the FS under test, checking strict io/fs conformance relied on by packages like html/template and net/http.
+//
+// Files are created with the SetupFS, then every file found in the SetupFS is expected in the committed FS.
+// TestFS() also checks the FS's optional io/fs interfaces, like fs.ReadDirFS and fs.SubFS, return the same results as opening the files directly.
+func StdFS(tb testing.TB, options FSOptions) TestData {
+	tb.Helper()
+
+	err := setupOptions(&options)
+	if err != nil {
+		tb.Fatal(err)
+		return TestData{}
+	}
+	options.tbRun(tb, options.Name+"_StdFS", func(tb testing.TB) {
+		options.tbParallel(tb)
+		tb.Helper()
+		setupFS, commit := options.Setup.FS(tb)
+		for _, file := range stdFSFiles {
+			if !assert.NoError(tb, setupStdFile(setupFS, file.path, file.contents)) {
+				tb.FailNow()
+			}
+		}
+		expected, err := expectedStdFiles(setupFS)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+
+		fs := commit()
+		if err := gofstest.TestFS(fs, expected...); err != nil {
+			tb.Error(err)
+		}
+	})
+	return options.generateTestData()
+}
+
+// setupStdFile creates the directory 'p' if it ends in a "/", otherwise a file holding 'contents'
+func setupStdFile(fs SetupFS, p, contents string) error {
+	if strings.HasSuffix(p, "/") {
+		return fs.Mkdir(strings.TrimSuffix(p, "/"), 0700)
+	}
+	f, err := fs.OpenFile(p, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = hackpadfs.WriteFile(f, []byte(contents))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// expectedStdFiles returns the path of every file and directory in 'fs', excluding the root
+func expectedStdFiles(fs hackpadfs.FS) ([]string, error) {
+	var expected []string
+	err := hackpadfs.WalkDir(fs, ".", func(path string, _ hackpadfs.DirEntry, err error) error {
+		if err == nil && path != "." {
+			expected = append(expected, path)
+		}
+		return err
+	})
+	return expected, err
+}
@@ -0,0 +1,156 @@
+// Package fsutil contains shell-style operations over any FS, like ls, cat, and cp. The hackpadfs command is a small CLI built on them.
+//
+// Each operation accepts a file system and a path inside it. Use OpenLocation() to create both from a location string.
+package fsutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/bufferpool"
+	"github.com/hack-pad/hackpadfs/sync"
+)
+
+// OpenLocation creates a file system with hackpadfs.New() and returns it with a path inside it.
+// A location is a file system URL, optionally followed by '#' and a path, like "os:/tmp#foo/bar". The path defaults to ".".
+func OpenLocation(location string) (fs hackpadfs.FS, name string, err error) {
+	url, name := location, "."
+	if i := strings.LastIndexByte(location, '#'); i != -1 {
+		url, name = location[:i], location[i+1:]
+	}
+	if !hackpadfs.ValidPath(name) {
+		return nil, "", &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	fs, err = hackpadfs.New(url)
+	return fs, name, err
+}
+
+// List writes a line for 'name' to 'w', or for each of its entries if it's a directory, like 'ls -l'
+func List(w io.Writer, fs hackpadfs.FS, name string) error {
+	info, err := hackpadfs.Stat(fs, name)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return listInfo(w, info)
+	}
+	entries, err := hackpadfs.ReadDir(fs, name)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := listInfo(w, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listInfo(w io.Writer, info hackpadfs.FileInfo) error {
+	name := info.Name()
+	if info.IsDir() {
+		name += "/"
+	}
+	_, err := fmt.Fprintf(w, "%s %10d %s %s\n", info.Mode(), info.Size(), info.ModTime().Format("2006-01-02 15:04"), name)
+	return err
+}
+
+// Tree writes the file tree rooted at 'root' to 'w', like 'tree'
+func Tree(w io.Writer, fs hackpadfs.FS, root string) error {
+	info, err := hackpadfs.Stat(fs, root)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, treeName(root, info.IsDir())); err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	return tree(w, fs, root, "")
+}
+
+func tree(w io.Writer, fs hackpadfs.FS, dir, indent string) error {
+	entries, err := hackpadfs.ReadDir(fs, dir)
+	if err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		branch, childIndent := "├── ", "│   "
+		if i == len(entries)-1 {
+			branch, childIndent = "└── ", "    "
+		}
+		if _, err := fmt.Fprintln(w, indent+branch+treeName(entry.Name(), entry.IsDir())); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if err := tree(w, fs, path.Join(dir, entry.Name()), indent+childIndent); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func treeName(name string, isDir bool) string {
+	if isDir && name != "." {
+		return name + "/"
+	}
+	return name
+}
+
+// Cat writes the contents of 'name' to 'w', like 'cat'
+func Cat(w io.Writer, fs hackpadfs.FS, name string) error {
+	f, err := fs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = bufferpool.Copies.Copy(w, f)
+	return err
+}
+
+// Copy copies the file or directory tree at 'srcName' in 'src' to 'destName' in 'dest', like 'cp -R'. See hackpadfs.CopyFS() for details.
+func Copy(dest hackpadfs.FS, destName string, src hackpadfs.FS, srcName string) error {
+	return hackpadfs.CopyFSWithOptions(dest, destName, src, srcName, hackpadfs.CopyOptions{PreserveTimes: true})
+}
+
+// Remove removes 'name', like 'rm'. If 'recursive' is true, directories are removed with everything inside them, like 'rm -r'.
+func Remove(fs hackpadfs.FS, name string, recursive bool) error {
+	if recursive {
+		return hackpadfs.RemoveAll(fs, name)
+	}
+	return hackpadfs.Remove(fs, name)
+}
+
+// DiskUsage writes the total size of the file tree at 'root' to 'w', with its number of files and directories, like 'du -s'
+func DiskUsage(w io.Writer, fs hackpadfs.FS, root string) error {
+	files, dirs, bytes, err := hackpadfs.DiskUsage(fs, root)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%d bytes\t%d files\t%d dirs\t%s\n", bytes, files, dirs, root)
+	return err
+}
+
+// Sync reconciles 'a' and 'b' with sync.Sync() and writes each action taken to 'w'. If 'dryRun' is true, writes the planned actions without making changes.
+func Sync(ctx context.Context, w io.Writer, a, b hackpadfs.FS, dryRun bool) error {
+	actions, err := sync.Sync(ctx, a, b, sync.Options{DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+	for _, action := range actions {
+		if _, err := fmt.Fprintf(w, "%s %s on %s\n", action.Op, action.Path, action.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
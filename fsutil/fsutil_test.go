@@ -0,0 +1,151 @@
+package fsutil
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func makeFS(tb testing.TB) *mem.FS {
+	tb.Helper()
+	fs, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	requireNoError := func(err error) {
+		tb.Helper()
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+	}
+	modTime := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	requireNoError(fs.MkdirAll("root/dir/empty", 0700))
+	requireNoError(hackpadfs.WriteFullFile(fs, "root/dir/foo", []byte("foo"), 0600))
+	requireNoError(hackpadfs.WriteFullFile(fs, "root/bar", []byte("bar!"), 0640))
+	for _, name := range []string{"root/dir/empty", "root/dir/foo", "root/dir", "root/bar"} {
+		requireNoError(fs.Chtimes(name, modTime, modTime))
+	}
+	return fs
+}
+
+func TestOpenLocation(t *testing.T) {
+	t.Parallel()
+	fs, name, err := OpenLocation("mem:")
+	assert.NoError(t, err)
+	assert.IsType(t, &mem.FS{}, fs)
+	assert.Equal(t, ".", name)
+
+	_, name, err = OpenLocation("mem:#foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo/bar", name)
+
+	_, _, err = OpenLocation("mem:#/foo")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	_, _, err = OpenLocation("unknown:")
+	assert.Error(t, err)
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	var buf bytes.Buffer
+	assert.NoError(t, List(&buf, fs, "root"))
+	assert.Equal(t, strings.Join([]string{
+		"-rw-r-----          4 2020-01-02 03:04 bar",
+		"drwx------          0 2020-01-02 03:04 dir/",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	assert.NoError(t, List(&buf, fs, "root/dir/foo"))
+	assert.Equal(t, "-rw-------          3 2020-01-02 03:04 foo\n", buf.String())
+
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, List(&buf, fs, "missing"))
+}
+
+func TestTree(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	var buf bytes.Buffer
+	assert.NoError(t, Tree(&buf, fs, "root"))
+	assert.Equal(t, strings.Join([]string{
+		"root/",
+		"├── bar",
+		"└── dir/",
+		"    ├── empty/",
+		"    └── foo",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	assert.NoError(t, Tree(&buf, fs, "root/bar"))
+	assert.Equal(t, "root/bar\n", buf.String())
+}
+
+func TestCat(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	var buf bytes.Buffer
+	assert.NoError(t, Cat(&buf, fs, "root/dir/foo"))
+	assert.Equal(t, "foo", buf.String())
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, Cat(&buf, fs, "missing"))
+}
+
+func TestCopy(t *testing.T) {
+	t.Parallel()
+	src := makeFS(t)
+	dest, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, Copy(dest, "copy", src, "root"))
+
+	contents, err := hackpadfs.ReadFile(dest, "copy/dir/foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(contents))
+	info, err := dest.Stat("copy/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC), info.ModTime().UTC())
+}
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.Error(t, Remove(fs, "root/dir", false))
+	assert.NoError(t, Remove(fs, "root/bar", false))
+	assert.NoError(t, Remove(fs, "root/dir", true))
+	entries, err := hackpadfs.ReadDir(fs, "root")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
+
+func TestDiskUsage(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	var buf bytes.Buffer
+	assert.NoError(t, DiskUsage(&buf, fs, "root"))
+	assert.Equal(t, "7 bytes\t2 files\t3 dirs\troot\n", buf.String())
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+	a := makeFS(t)
+	b, err := mem.NewFS()
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, Sync(context.Background(), &buf, a, b, true))
+	assert.Equal(t, true, strings.Contains(buf.String(), "copy root/dir/foo on B\n"))
+	_, err = b.Stat("root")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err) // dry run
+
+	buf.Reset()
+	assert.NoError(t, Sync(context.Background(), &buf, a, b, false))
+	contents, err := hackpadfs.ReadFile(b, "root/dir/foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(contents))
+}
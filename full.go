@@ -0,0 +1,195 @@
+package hackpadfs
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	_ interface {
+		SubFS
+		OpenFileFS
+		CreateFS
+		MkdirFS
+		MkdirAllFS
+		RemoveFS
+		RemoveAllFS
+		RenameFS
+		StatFS
+		LstatFS
+		ChmodFS
+		ChownFS
+		ChtimesFS
+		ApplyMetadataFS
+		ReadDirFS
+		ReadDirPagedFS
+		ReadFileFS
+		WriteFileFS
+		SymlinkFS
+		ReadlinkFS
+		SameFileFS
+		QuotaFS
+		PingFS
+		CloserFS
+		UsageFS
+		WatchFS
+		MountFS
+	} = &AllFS{}
+)
+
+// AllFS implements every optional FS interface by calling the matching helper on the wrapped FS.
+// Operations the wrapped FS can't support fail the same way their helpers do, typically with a not implemented error.
+type AllFS struct {
+	FS
+}
+
+// FullFS wraps 'fs' in an AllFS, a single concrete type with every FS operation available as a method.
+// Useful for consumers that detect capabilities with type assertions, like fstest, when 'fs' only supports some operations natively or through a MountFS.
+func FullFS(fs FS) *AllFS {
+	if fs, ok := fs.(*AllFS); ok {
+		return fs
+	}
+	return &AllFS{FS: fs}
+}
+
+// Sub implements SubFS. The returned FS is also an AllFS.
+func (fs *AllFS) Sub(dir string) (FS, error) {
+	subFS, err := Sub(fs.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+	return FullFS(subFS), nil
+}
+
+// OpenFile implements OpenFileFS
+func (fs *AllFS) OpenFile(name string, flag int, perm FileMode) (File, error) {
+	return OpenFile(fs.FS, name, flag, perm)
+}
+
+// Create implements CreateFS
+func (fs *AllFS) Create(name string) (File, error) {
+	return Create(fs.FS, name)
+}
+
+// Mkdir implements MkdirFS
+func (fs *AllFS) Mkdir(name string, perm FileMode) error {
+	return Mkdir(fs.FS, name, perm)
+}
+
+// MkdirAll implements MkdirAllFS
+func (fs *AllFS) MkdirAll(path string, perm FileMode) error {
+	return MkdirAll(fs.FS, path, perm)
+}
+
+// Remove implements RemoveFS
+func (fs *AllFS) Remove(name string) error {
+	return Remove(fs.FS, name)
+}
+
+// RemoveAll implements RemoveAllFS
+func (fs *AllFS) RemoveAll(name string) error {
+	return RemoveAll(fs.FS, name)
+}
+
+// Rename implements RenameFS
+func (fs *AllFS) Rename(oldname, newname string) error {
+	return Rename(fs.FS, oldname, newname)
+}
+
+// Stat implements StatFS
+func (fs *AllFS) Stat(name string) (FileInfo, error) {
+	return Stat(fs.FS, name)
+}
+
+// Lstat implements LstatFS
+func (fs *AllFS) Lstat(name string) (FileInfo, error) {
+	return Lstat(fs.FS, name)
+}
+
+// Chmod implements ChmodFS
+func (fs *AllFS) Chmod(name string, mode FileMode) error {
+	return Chmod(fs.FS, name, mode)
+}
+
+// Chown implements ChownFS
+func (fs *AllFS) Chown(name string, uid, gid int) error {
+	return Chown(fs.FS, name, uid, gid)
+}
+
+// Chtimes implements ChtimesFS
+func (fs *AllFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return Chtimes(fs.FS, name, atime, mtime)
+}
+
+// ApplyMetadata implements ApplyMetadataFS
+func (fs *AllFS) ApplyMetadata(metadata map[string]Metadata) error {
+	return ApplyMetadata(fs.FS, metadata)
+}
+
+// ReadDir implements ReadDirFS
+func (fs *AllFS) ReadDir(name string) ([]DirEntry, error) {
+	return ReadDir(fs.FS, name)
+}
+
+// ReadDirN implements ReadDirPagedFS
+func (fs *AllFS) ReadDirN(name, token string) (entries []DirEntry, nextToken string, err error) {
+	return ReadDirPaged(fs.FS, name, token)
+}
+
+// ReadFile implements ReadFileFS
+func (fs *AllFS) ReadFile(name string) ([]byte, error) {
+	return ReadFile(fs.FS, name)
+}
+
+// WriteFile implements WriteFileFS
+func (fs *AllFS) WriteFile(name string, data []byte, perm FileMode) error {
+	return WriteFullFile(fs.FS, name, data, perm)
+}
+
+// Symlink implements SymlinkFS
+func (fs *AllFS) Symlink(oldname, newname string) error {
+	return Symlink(fs.FS, oldname, newname)
+}
+
+// Readlink implements ReadlinkFS
+func (fs *AllFS) Readlink(name string) (string, error) {
+	return Readlink(fs.FS, name)
+}
+
+// SameFile implements SameFileFS
+func (fs *AllFS) SameFile(fi1, fi2 FileInfo) bool {
+	return SameFile(fs.FS, fi1, fi2)
+}
+
+// Usage implements QuotaFS
+func (fs *AllFS) Usage(ctx context.Context) (StorageUsage, error) {
+	return Usage(ctx, fs.FS)
+}
+
+// Ping implements PingFS
+func (fs *AllFS) Ping(ctx context.Context) error {
+	return Ping(ctx, fs.FS)
+}
+
+// Close implements CloserFS
+func (fs *AllFS) Close() error {
+	return Close(fs.FS)
+}
+
+// DiskUsage implements UsageFS
+func (fs *AllFS) DiskUsage(root string) (files, dirs, bytes int64, err error) {
+	return DiskUsage(fs.FS, root)
+}
+
+// Watch implements WatchFS
+func (fs *AllFS) Watch(ctx context.Context, name string) (<-chan Event, error) {
+	return Watch(ctx, fs.FS, name)
+}
+
+// Mount implements MountFS. If the wrapped FS is not a MountFS, returns it with 'name' unchanged.
+func (fs *AllFS) Mount(name string) (mountFS FS, subPath string) {
+	if mount, ok := fs.FS.(MountFS); ok {
+		return mount.Mount(name)
+	}
+	return fs.FS, name
+}
@@ -0,0 +1,37 @@
+package hackpadfs_test
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestFullFS(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, memFS.MkdirAll("foo/bar", 0700))
+	requireNoError(t, hackpadfs.WriteFullFile(memFS, "foo/bar/baz", []byte("baz"), 0600))
+	fs := hackpadfs.FullFS(openOnlyFS{memFS})
+	assert.Equal(t, fs, hackpadfs.FullFS(fs))
+
+	info, err := fs.Stat("foo/bar/baz")
+	requireNoError(t, err)
+	assert.Equal(t, int64(3), info.Size())
+
+	subFS, err := fs.Sub("foo")
+	requireNoError(t, err)
+	assert.IsType(t, &hackpadfs.AllFS{}, subFS)
+	contents, err := hackpadfs.ReadFile(subFS, "bar/baz")
+	requireNoError(t, err)
+	assert.Equal(t, "baz", string(contents))
+
+	mountFS, subPath := fs.Mount("foo/bar")
+	assert.Equal(t, openOnlyFS{memFS}, mountFS)
+	assert.Equal(t, "foo/bar", subPath)
+
+	err = fs.Mkdir("bar", 0700)
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+}
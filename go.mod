@@ -5,4 +5,5 @@ go 1.18
 require (
 	github.com/hack-pad/go-indexeddb v0.3.2
 	github.com/hack-pad/safejs v0.1.0
+	golang.org/x/text v0.6.0
 )
@@ -0,0 +1,223 @@
+// Package index contains an in-memory index of file metadata, kept up to date by watching the FS for changes.
+// Queries like "files ending in .go modified in the last hour" are answered without walking the FS.
+package index
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// Entry is an indexed file or directory
+type Entry struct {
+	Path    string
+	Size    int64
+	Mode    hackpadfs.FileMode
+	ModTime time.Time
+}
+
+// IsDir returns true if the entry is a directory
+func (e Entry) IsDir() bool {
+	return e.Mode.IsDir()
+}
+
+// Index is an index of the file metadata at and under a root path. Create one with New().
+type Index struct {
+	fs   hackpadfs.FS
+	root string
+
+	mu          sync.RWMutex
+	entries     map[string]Entry
+	byExtension map[string]map[string]bool // extension -> set of paths
+}
+
+// New indexes the files at and under 'root' in 'fs', then keeps the index up to date with hackpadfs.Watch() until 'ctx' is done.
+// Fails with hackpadfs.ErrNotImplemented if 'fs' can't be watched.
+//
+// The index is updated asynchronously, so queries may briefly miss changes as they happen.
+func New(ctx context.Context, fs hackpadfs.FS, root string) (*Index, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	events, err := hackpadfs.Watch(ctx, fs, root) // watch before walking, so changes made during the walk aren't missed
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	index := &Index{
+		fs:          fs,
+		root:        root,
+		entries:     make(map[string]Entry),
+		byExtension: make(map[string]map[string]bool),
+	}
+	if err := index.addTree(root); err != nil {
+		cancel()
+		return nil, err
+	}
+	go func() {
+		defer cancel()
+		for event := range events {
+			index.update(event)
+		}
+	}()
+	return index, nil
+}
+
+// addTree indexes the file tree at 'root'
+func (i *Index) addTree(root string) error {
+	return hackpadfs.WalkDir(i.fs, root, func(name string, dirEntry hackpadfs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+		i.mu.Lock()
+		i.put(name, info)
+		i.mu.Unlock()
+		return nil
+	})
+}
+
+// update re-indexes the file changed by 'event'
+func (i *Index) update(event hackpadfs.Event) {
+	name := event.Name
+	if name == "." && event.Op == hackpadfs.EventRemove {
+		// every file was removed, like a keyvalue store being cleared. The root may already be recreated, so drop everything rather than re-stat it.
+		i.mu.Lock()
+		i.removeTree(i.root)
+		i.mu.Unlock()
+		return
+	}
+	if name != i.root && i.root != "." && !strings.HasPrefix(name, i.root+"/") {
+		name = i.root // changes to a parent directory, like removing everything, affect the whole index
+	}
+	info, err := hackpadfs.LstatOrStat(i.fs, name)
+	i.mu.Lock()
+	if err != nil {
+		i.removeTree(name)
+		i.mu.Unlock()
+		return
+	}
+	entry, indexed := i.entries[name]
+	if indexed && entry.IsDir() != info.IsDir() {
+		i.removeTree(name) // a directory replaced by a file leaves nothing under it, and a file replaced by a directory needs its contents indexed
+		indexed = false
+	}
+	i.put(name, info)
+	i.mu.Unlock()
+	if info.IsDir() && !indexed {
+		_ = i.addTree(name) // a new directory, like one renamed in from elsewhere, may already have contents
+	}
+}
+
+// put indexes 'info' at 'name'. Must be called with i.mu held.
+func (i *Index) put(name string, info hackpadfs.FileInfo) {
+	i.entries[name] = Entry{
+		Path:    name,
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+	}
+	extension := path.Ext(name)
+	if i.byExtension[extension] == nil {
+		i.byExtension[extension] = make(map[string]bool)
+	}
+	i.byExtension[extension][name] = true
+}
+
+// removeTree removes 'name' and everything under it from the index. Must be called with i.mu held.
+func (i *Index) removeTree(name string) {
+	prefix := name + "/"
+	for entryName := range i.entries {
+		if entryName == name || name == "." || strings.HasPrefix(entryName, prefix) {
+			delete(i.entries, entryName)
+			extension := path.Ext(entryName)
+			delete(i.byExtension[extension], entryName)
+			if len(i.byExtension[extension]) == 0 {
+				delete(i.byExtension, extension)
+			}
+		}
+	}
+}
+
+// Len returns the number of indexed files and directories
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.entries)
+}
+
+// Get returns the indexed entry for 'name', if any
+func (i *Index) Get(name string) (Entry, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	entry, ok := i.entries[name]
+	return entry, ok
+}
+
+// Query selects indexed entries. Zero-valued fields match every entry.
+type Query struct {
+	// Extension matches paths ending in this extension, including the leading dot, like ".go". Uses an index, so it's faster than Name.
+	Extension string
+	// Name matches the base name of each path with path.Match(), like "*_test.go"
+	Name string
+	// ModifiedSince matches entries modified at or after this time
+	ModifiedSince time.Time
+	// MinSize and MaxSize match entries with at least or at most this many bytes
+	MinSize, MaxSize int64
+	// IncludeDirs includes directories in the results. By default, only files are returned.
+	IncludeDirs bool
+}
+
+// Query returns the entries matching 'q', sorted by path. Fails with path.ErrBadPattern if q.Name is malformed.
+func (i *Index) Query(q Query) ([]Entry, error) {
+	if _, err := path.Match(q.Name, ""); err != nil {
+		return nil, err
+	}
+	i.mu.RLock()
+	var results []Entry
+	if q.Extension != "" {
+		for name := range i.byExtension[q.Extension] {
+			if entry := i.entries[name]; q.match(entry) {
+				results = append(results, entry)
+			}
+		}
+	} else {
+		for _, entry := range i.entries {
+			if q.match(entry) {
+				results = append(results, entry)
+			}
+		}
+	}
+	i.mu.RUnlock()
+	sort.Slice(results, func(a, b int) bool {
+		return results[a].Path < results[b].Path
+	})
+	return results, nil
+}
+
+func (q Query) match(entry Entry) bool {
+	if entry.IsDir() && !q.IncludeDirs {
+		return false
+	}
+	if q.Name != "" {
+		if matched, _ := path.Match(q.Name, path.Base(entry.Path)); !matched {
+			return false
+		}
+	}
+	switch {
+	case !q.ModifiedSince.IsZero() && entry.ModTime.Before(q.ModifiedSince):
+		return false
+	case q.MinSize > 0 && entry.Size < q.MinSize:
+		return false
+	case q.MaxSize > 0 && entry.Size > q.MaxSize:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,199 @@
+package index
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func makeIndex(tb testing.TB, root string) (*mem.FS, *Index) {
+	tb.Helper()
+	requireNoError := func(err error) {
+		tb.Helper()
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+	}
+	fs, err := mem.NewFS()
+	requireNoError(err)
+	requireNoError(fs.MkdirAll("src/sub", 0700))
+	for name, contents := range map[string]string{
+		"src/a.go":      "package a",
+		"src/a_test.go": "package a_test",
+		"src/sub/b.txt": "b",
+		"other.go":      "package other",
+	} {
+		requireNoError(hackpadfs.WriteFullFile(fs, name, []byte(contents), 0600))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	tb.Cleanup(cancel)
+	index, err := New(ctx, fs, root)
+	requireNoError(err)
+	return fs, index
+}
+
+func queryPaths(tb testing.TB, index *Index, q Query) []string {
+	tb.Helper()
+	entries, err := index.Query(q)
+	assert.NoError(tb, err)
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	return paths
+}
+
+// eventually polls 'index' with 'q' until it returns 'expected', since updates are asynchronous
+func eventually(tb testing.TB, index *Index, q Query, expected []string) {
+	tb.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		paths := queryPaths(tb, index, q)
+		if time.Now().After(deadline) {
+			assert.Equal(tb, expected, paths)
+			return
+		}
+		if len(paths) == len(expected) {
+			equal := true
+			for i := range paths {
+				equal = equal && paths[i] == expected[i]
+			}
+			if equal {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+	_, index := makeIndex(t, ".")
+	assert.Equal(t, 7, index.Len())
+	entry, ok := index.Get("src/a.go")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, int64(len("package a")), entry.Size)
+	assert.Equal(t, false, entry.IsDir())
+
+	for _, tc := range []struct {
+		description string
+		query       Query
+		expected    []string
+	}{
+		{
+			description: "everything",
+			expected:    []string{"other.go", "src/a.go", "src/a_test.go", "src/sub/b.txt"},
+		},
+		{
+			description: "include dirs",
+			query:       Query{IncludeDirs: true},
+			expected:    []string{".", "other.go", "src", "src/a.go", "src/a_test.go", "src/sub", "src/sub/b.txt"},
+		},
+		{
+			description: "extension",
+			query:       Query{Extension: ".go"},
+			expected:    []string{"other.go", "src/a.go", "src/a_test.go"},
+		},
+		{
+			description: "name",
+			query:       Query{Name: "*_test.go"},
+			expected:    []string{"src/a_test.go"},
+		},
+		{
+			description: "size range",
+			query:       Query{MinSize: 2, MaxSize: int64(len("package a"))},
+			expected:    []string{"src/a.go"},
+		},
+		{
+			description: "modified in the future",
+			query:       Query{ModifiedSince: time.Now().Add(time.Hour)},
+		},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, queryPaths(t, index, tc.query))
+		})
+	}
+
+	_, err := index.Query(Query{Name: "["})
+	assert.Error(t, err)
+}
+
+func TestUpdates(t *testing.T) {
+	t.Parallel()
+	fs, index := makeIndex(t, "src")
+	assert.Equal(t, []string{"src/a.go", "src/a_test.go", "src/sub/b.txt"}, queryPaths(t, index, Query{}))
+
+	since := time.Now()
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "src/sub/c.go", []byte("package c"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "other.txt", []byte("outside root"), 0600))
+	eventually(t, index, Query{Extension: ".go", ModifiedSince: since}, []string{"src/sub/c.go"})
+
+	assert.NoError(t, fs.Remove("src/a_test.go"))
+	eventually(t, index, Query{Extension: ".go"}, []string{"src/a.go", "src/sub/c.go"})
+
+	assert.NoError(t, fs.Rename("src/sub", "src/renamed"))
+	eventually(t, index, Query{}, []string{"src/a.go", "src/renamed/b.txt", "src/renamed/c.go"})
+
+	assert.NoError(t, hackpadfs.RemoveAll(fs, "src"))
+	eventually(t, index, Query{IncludeDirs: true}, nil)
+}
+
+// newUnwatchedIndex indexes 'root' in 'fs' without watching it, so tests can call update() directly
+func newUnwatchedIndex(tb testing.TB, fs hackpadfs.FS, root string) *Index {
+	tb.Helper()
+	index := &Index{
+		fs:          fs,
+		root:        root,
+		entries:     make(map[string]Entry),
+		byExtension: make(map[string]map[string]bool),
+	}
+	if !assert.NoError(tb, index.addTree(root)) {
+		tb.FailNow()
+	}
+	return index
+}
+
+func TestUpdateDirReplacedByFile(t *testing.T) {
+	t.Parallel()
+	fs, _ := makeIndex(t, "src")
+	index := newUnwatchedIndex(t, fs, "src")
+	assert.NoError(t, hackpadfs.RemoveAll(fs, "src/sub"))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "src/sub", []byte("sub"), 0600))
+	index.update(hackpadfs.Event{Name: "src/sub", Op: hackpadfs.EventWrite})
+	assert.Equal(t, []string{"src", "src/a.go", "src/a_test.go", "src/sub"}, queryPaths(t, index, Query{IncludeDirs: true}))
+
+	assert.NoError(t, fs.Remove("src/sub"))
+	assert.NoError(t, fs.MkdirAll("src/sub", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "src/sub/b.txt", []byte("b"), 0600))
+	index.update(hackpadfs.Event{Name: "src/sub", Op: hackpadfs.EventWrite})
+	assert.Equal(t, []string{"src", "src/a.go", "src/a_test.go", "src/sub", "src/sub/b.txt"}, queryPaths(t, index, Query{IncludeDirs: true}))
+}
+
+func TestUpdateRemovedEverything(t *testing.T) {
+	t.Parallel()
+	for _, root := range []string{".", "src"} {
+		root := root
+		t.Run(root, func(t *testing.T) {
+			t.Parallel()
+			fs, _ := makeIndex(t, root)
+			index := newUnwatchedIndex(t, fs, root)
+			// the root still exists, like a store which recreates it right after clearing every record
+			index.update(hackpadfs.Event{Name: ".", Op: hackpadfs.EventRemove})
+			assert.Equal(t, 0, index.Len())
+		})
+	}
+}
+
+func TestNotWatchable(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	assert.NoError(t, err)
+	_, err = New(context.Background(), struct{ hackpadfs.FS }{fs}, ".")
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+}
@@ -0,0 +1,357 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/safejs"
+)
+
+// crossTab coordinates writes between browser tabs and workers sharing the same database.
+// Read-write store transactions and Clear() hold an exclusive Web Lock until they finish, and changed paths are broadcast on a BroadcastChannel once committed.
+type crossTab struct {
+	name    string
+	channel safejs.Value
+}
+
+// lockGrant is one acquisition of a Web Lock, shared by every lock() call in this tab until they're all released
+type lockGrant struct {
+	holders int           // holders is guarded by lockGrantsMu
+	done    chan struct{} // done is closed once the Web Lock is acquired or failed
+	release func()
+	err     error
+}
+
+var (
+	lockGrantsMu sync.Mutex
+	lockGrants   = make(map[string]*lockGrant) // lockGrants holds each Web Lock name's current grant in this tab, shared by every FS using the same database
+)
+
+func crossTabName(dbName string) string {
+	return "hackpadfs-indexeddb:" + dbName
+}
+
+func newCrossTab(dbName string) (*crossTab, error) {
+	name := crossTabName(dbName)
+	channel, err := newBroadcastChannel(name)
+	if err != nil {
+		return nil, err
+	}
+	return &crossTab{
+		name:    name,
+		channel: channel,
+	}, nil
+}
+
+func newBroadcastChannel(name string) (safejs.Value, error) {
+	jsBroadcastChannel, err := safejs.Global().Get("BroadcastChannel")
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	if jsBroadcastChannel.IsUndefined() {
+		return safejs.Value{}, hackpadfs.ErrNotImplemented
+	}
+	return jsBroadcastChannel.New(name)
+}
+
+// lock waits to acquire the cross-tab write lock. Call the returned func to release it.
+// The lock is reentrant within a tab: concurrent and nested calls, from any FS on the same database, share one Web Lock, which is released once every caller has released it.
+// Other tabs wait until then. A transaction in this tab can then begin while another is still open, without deadlocking on itself.
+func (c *crossTab) lock(ctx context.Context) (unlock func(), err error) {
+	lockGrantsMu.Lock()
+	grant := lockGrants[c.name]
+	if grant == nil {
+		grant = &lockGrant{done: make(chan struct{})}
+		lockGrants[c.name] = grant
+		go func() {
+			grant.release, grant.err = requestWebLock(context.Background(), c.name) // shared by later callers, so not bound to this caller's ctx
+			close(grant.done)
+		}()
+	}
+	grant.holders++
+	lockGrantsMu.Unlock()
+
+	var once sync.Once
+	unlock = func() {
+		once.Do(func() { c.unlock(grant) })
+	}
+	select {
+	case <-grant.done:
+		if grant.err != nil {
+			unlock()
+			return nil, grant.err
+		}
+		return unlock, nil
+	case <-ctx.Done():
+		unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// unlock releases one holder of 'grant', releasing the Web Lock once it's acquired and has no holders left
+func (c *crossTab) unlock(grant *lockGrant) {
+	lockGrantsMu.Lock()
+	grant.holders--
+	last := grant.holders == 0
+	if last {
+		delete(lockGrants, c.name)
+	}
+	lockGrantsMu.Unlock()
+	if last {
+		go func() {
+			<-grant.done
+			if grant.err == nil {
+				grant.release()
+			}
+		}()
+	}
+}
+
+// requestWebLock waits to acquire the exclusive Web Lock 'name'. Call the returned func to release it.
+func requestWebLock(ctx context.Context, name string) (func(), error) {
+	navigator, err := safejs.Global().Get("navigator")
+	if err != nil {
+		return nil, err
+	}
+	locks, err := navigator.Get("locks")
+	if err != nil {
+		return nil, err
+	}
+	if locks.IsUndefined() {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	jsPromise, err := safejs.Global().Get("Promise")
+	if err != nil {
+		return nil, err
+	}
+
+	type heldLock struct {
+		release safejs.Value // resolves the promise returned to navigator.locks.request(), which releases the lock
+		err     error
+	}
+	held := make(chan heldLock, 2)
+	sendHeld := func(h heldLock) {
+		select {
+		case held <- h:
+		default:
+		}
+	}
+	holdLock, err := safejs.FuncOf(func(_ safejs.Value, args []safejs.Value) interface{} {
+		sendHeld(heldLock{release: args[0]})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	onGranted, err := safejs.FuncOf(func(safejs.Value, []safejs.Value) interface{} {
+		promise, err := jsPromise.New(holdLock)
+		if err != nil {
+			sendHeld(heldLock{err: err})
+			return nil
+		}
+		return safejs.Unsafe(promise)
+	})
+	if err != nil {
+		holdLock.Release()
+		return nil, err
+	}
+	releaseFuncs := func() {
+		holdLock.Release()
+		onGranted.Release()
+	}
+
+	request, err := locks.Call("request", name, onGranted)
+	if err != nil {
+		releaseFuncs()
+		return nil, err
+	}
+	go func() {
+		// the request only settles early if the lock could not be acquired
+		if _, err := awaitPromise(context.Background(), request); err != nil {
+			sendHeld(heldLock{err: err})
+		}
+	}()
+
+	var once sync.Once
+	release := func(h heldLock) func() {
+		return func() {
+			once.Do(func() {
+				if h.err == nil {
+					_, _ = h.release.Invoke()
+				}
+				releaseFuncs()
+			})
+		}
+	}
+	select {
+	case h := <-held:
+		if h.err != nil {
+			release(h)()
+			return nil, h.err
+		}
+		return release(h), nil
+	case <-ctx.Done():
+		go func() {
+			release(<-held)()
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// notify broadcasts 'paths' to other tabs. Best-effort, since the changes are already committed.
+func (c *crossTab) notify(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	jsPaths := make([]interface{}, 0, len(paths))
+	for _, p := range paths {
+		jsPaths = append(jsPaths, p)
+	}
+	message, err := safejs.ValueOf(jsPaths)
+	if err != nil {
+		return
+	}
+	_, _ = c.channel.Call("postMessage", message)
+}
+
+// close stops broadcasting to other tabs
+func (c *crossTab) close() {
+	_, _ = c.channel.Call("close")
+}
+
+// Changes returns a channel receiving the paths of files changed by other tabs or workers sharing this database.
+// Only changes made with Options.CrossTab enabled are reported. A "." path indicates the whole FS was cleared.
+// The channel is closed once 'ctx' is canceled.
+func (fs *FS) Changes(ctx context.Context) (<-chan []string, error) {
+	return listenChanges(ctx, crossTabName(fs.name))
+}
+
+// listenChanges returns a channel receiving the changed paths broadcast on the BroadcastChannel 'name'. The channel is closed once 'ctx' is canceled.
+func listenChanges(ctx context.Context, name string) (<-chan []string, error) {
+	channel, err := newBroadcastChannel(name)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(chan []string)
+	var wg sync.WaitGroup
+	onMessage, err := safejs.FuncOf(func(_ safejs.Value, args []safejs.Value) interface{} {
+		paths, err := parseChangedPaths(args[0])
+		if err != nil || len(paths) == 0 {
+			return nil
+		}
+		wg.Add(1)
+		go func() { // don't block the JS event loop on a slow receiver
+			defer wg.Done()
+			select {
+			case changes <- paths:
+			case <-ctx.Done():
+			}
+		}()
+		return nil
+	})
+	if err != nil {
+		_, _ = channel.Call("close")
+		return nil, err
+	}
+	if _, err := channel.Call("addEventListener", "message", onMessage); err != nil {
+		_, _ = channel.Call("close")
+		onMessage.Release()
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		_, _ = channel.Call("close")
+		onMessage.Release()
+		wg.Wait()
+		close(changes)
+	}()
+	return changes, nil
+}
+
+// Watch implements keyvalue.WatchableStore
+// Reports changes committed by this store, and with Options.CrossTab, changes broadcast by other tabs and workers.
+// Broadcasts only hold the changed paths, so each is read again to find whether it was set or deleted. A broadcast "." path means the database was cleared.
+func (s *store) Watch(ctx context.Context, prefix string) (<-chan keyvalue.Event, error) {
+	localEvents, err := s.watchers.Watch(ctx, prefix)
+	if err != nil || s.crossTab == nil {
+		return localEvents, err
+	}
+	changes, err := listenChanges(ctx, s.crossTab.name)
+	if err != nil {
+		return nil, err
+	}
+	var remote keyvalue.Watchers // filters broadcast changes by 'prefix', separately from other watchers
+	remoteEvents, err := remote.Watch(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for paths := range changes {
+			for _, p := range paths {
+				event := keyvalue.Event{Path: p, Deleted: true}
+				if p != rootPath {
+					_, err := s.Get(ctx, p)
+					event.Deleted = errors.Is(err, hackpadfs.ErrNotExist)
+				}
+				remote.Send(event)
+			}
+		}
+	}()
+
+	events := make(chan keyvalue.Event)
+	go func() {
+		defer close(events)
+		for localEvents != nil || remoteEvents != nil {
+			var event keyvalue.Event
+			var ok bool
+			select {
+			case event, ok = <-localEvents:
+				if !ok {
+					localEvents = nil
+					continue
+				}
+			case event, ok = <-remoteEvents:
+				if !ok {
+					remoteEvents = nil
+					continue
+				}
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func parseChangedPaths(event safejs.Value) ([]string, error) {
+	data, err := event.Get("data")
+	if err != nil {
+		return nil, err
+	}
+	length, err := data.Length()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, length)
+	for i := 0; i < length; i++ {
+		jsPath, err := data.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		p, err := jsPath.String()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
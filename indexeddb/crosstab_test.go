@@ -0,0 +1,72 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestCrossTab(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	name := fmt.Sprintf("%s%s", testDBPrefix, t.Name())
+	t.Cleanup(func() {
+		req, err := idb.Global().DeleteDatabase(name)
+		assert.NoError(t, err)
+		assert.NoError(t, req.Await(context.Background()))
+	})
+
+	writer, err := NewFS(ctx, name, Options{CrossTab: true})
+	assert.NoError(t, err)
+	reader, err := NewFS(ctx, name, Options{CrossTab: true})
+	assert.NoError(t, err)
+	changes, err := reader.Changes(ctx)
+	assert.NoError(t, err)
+
+	assert.NoError(t, hackpadfs.WriteFullFile(writer, "foo", []byte("bar"), 0600))
+	select {
+	case paths := <-changes:
+		assert.Equal(t, []string{"foo"}, paths)
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for change notification")
+	}
+	contents, err := hackpadfs.ReadFile(reader, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(contents))
+
+	cancel()
+	for range changes {
+	}
+	assert.NoError(t, writer.db.Close())
+	assert.NoError(t, reader.db.Close())
+}
+
+func TestCrossTabLockReentrant(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tabs, err := newCrossTab(fmt.Sprintf("%s%s", testDBPrefix, t.Name()))
+	assert.NoError(t, err)
+	defer tabs.close()
+
+	unlock1, err := tabs.lock(ctx)
+	assert.NoError(t, err)
+	unlock2, err := tabs.lock(ctx) // would deadlock if not shared with the first holder
+	assert.NoError(t, err)
+	unlock1()
+	unlock1() // releasing twice is a no-op
+	unlock2()
+
+	unlock3, err := tabs.lock(ctx)
+	assert.NoError(t, err)
+	unlock3()
+}
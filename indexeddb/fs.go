@@ -6,55 +6,63 @@ package indexeddb
 
 import (
 	"context"
+	"io"
+	gofs "io/fs"
+	"path"
 	"time"
 
 	"github.com/hack-pad/go-indexeddb/idb"
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/keyvalue"
-	"github.com/hack-pad/safejs"
+	"github.com/hack-pad/hackpadfs/tar"
 )
 
 const (
-	fsVersion = 1
-
 	contentsStore = "contents"
-	infoStore     = "info"
+	infoStore     = "info-v2"
 	parentKey     = "Parent"
+
+	readDirPageSize = 1000 // readDirPageSize is the number of entries in each page from ReadDirN
 )
 
 // FS is a browser-based file system, storing files and metadata inside IndexedDB.
 type FS struct {
-	kv *keyvalue.FS
-	db *idb.Database
+	kv       *keyvalue.FS
+	name     string
+	store    keyvalue.Store
+	db       *idb.Database // nil if proxying to a worker
+	worker   *workerStore  // nil unless Options.Worker is set
+	crossTab *crossTab
 }
 
 // Options provides configuration options for a new FS.
 type Options struct {
 	Factory               *idb.Factory
 	TransactionDurability idb.TransactionDurability
+	// CrossTab coordinates writes with other tabs and workers using the same database.
+	// Every read-write transaction, including each write to a file and each directory change, holds an exclusive Web Lock until it commits, as does Clear(). Reads don't take it.
+	// The lock is shared by this tab's concurrent writes, so other tabs wait until none are left. Changed paths are broadcast to FS.Changes() in other tabs.
+	// Locks are advisory: only FS's with CrossTab enabled take part.
+	CrossTab bool
+	// Worker proxies all database operations to a Web Worker or MessagePort running ServeWorker(), keeping IndexedDB work off the main thread.
+	// Operations changing several files or directories, like Rename() and RemoveAll(), run whole in the worker, so they keep their transaction. File reads and writes are sent one record at a time.
+	// When set, other options except BufferWrites and NormalizeName are ignored here. Pass them to ServeWorker() instead.
+	Worker *Worker
+	// BufferWrites saves file contents on Sync() or Close() instead of committing a transaction on every write. See keyvalue.Options for details.
+	BufferWrites bool
+	// NormalizeName converts each file name before it's stored, like to Unicode NFC. See keyvalue.Options for details.
+	NormalizeName func(name string) string
 }
 
 // NewFS returns a new FS.
 func NewFS(ctx context.Context, name string, options Options) (*FS, error) {
+	if options.Worker != nil {
+		return newWorkerFS(ctx, name, options)
+	}
 	if options.Factory == nil {
 		options.Factory = idb.Global()
 	}
-	openRequest, err := options.Factory.Open(ctx, name, fsVersion, func(db *idb.Database, oldVersion, newVersion uint) error {
-		_, err := db.CreateObjectStore(contentsStore, idb.ObjectStoreOptions{})
-		if err != nil {
-			return err
-		}
-		infos, err := db.CreateObjectStore(infoStore, idb.ObjectStoreOptions{})
-		if err != nil {
-			return err
-		}
-		jsParentKey, err := safejs.ValueOf(parentKey)
-		if err != nil {
-			return err
-		}
-		_, err = infos.CreateIndex(parentKey, safejs.Unsafe(jsParentKey), idb.IndexOptions{})
-		return err
-	})
+	openRequest, err := options.Factory.Open(ctx, name, fsVersion, upgrade)
 	if err != nil {
 		return nil, err
 	}
@@ -62,15 +70,77 @@ func NewFS(ctx context.Context, name string, options Options) (*FS, error) {
 	if err != nil {
 		return nil, err
 	}
-	kv, err := keyvalue.NewFS(newStore(db, options))
+	if err := moveLegacyInfos(ctx, name, db, options); err != nil {
+		return nil, err
+	}
+	var tabs *crossTab
+	if options.CrossTab {
+		tabs, err = newCrossTab(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	store := newStore(db, options)
+	store.crossTab = tabs
+	kv, err := keyvalue.NewFSWithOptions(store, options.keyvalueOptions())
 	return &FS{
-		kv: kv,
-		db: db,
+		kv:       kv,
+		name:     name,
+		store:    store,
+		db:       db,
+		crossTab: tabs,
+	}, err
+}
+
+func (o Options) keyvalueOptions() keyvalue.Options {
+	return keyvalue.Options{
+		BufferWrites:  o.BufferWrites,
+		NormalizeName: o.NormalizeName,
+	}
+}
+
+func newWorkerFS(ctx context.Context, name string, options Options) (*FS, error) {
+	client, err := newWorkerClient(options.Worker.port)
+	if err != nil {
+		return nil, err
+	}
+	store := &workerStore{client: client, db: name, normalizeName: options.NormalizeName}
+	if err := store.open(ctx); err != nil {
+		return nil, err
+	}
+	kv, err := keyvalue.NewFSWithOptions(store, options.keyvalueOptions())
+	return &FS{
+		kv:     kv,
+		name:   name,
+		store:  store,
+		worker: store,
 	}, err
 }
 
 // Clear dangerously destroys all data inside this FS. Use with caution.
 func (fs *FS) Clear(ctx context.Context) error {
+	if fs.worker != nil {
+		return fs.worker.clear(ctx)
+	}
+	err := fs.clearStores(ctx)
+	if err != nil {
+		return err
+	}
+	fs.store.(*store).watchers.Send(keyvalue.Event{Path: rootPath, Deleted: true})
+	if fs.crossTab != nil {
+		fs.crossTab.notify([]string{rootPath})
+	}
+	return fs.Mkdir(".", 0666)
+}
+
+func (fs *FS) clearStores(ctx context.Context) error {
+	if fs.crossTab != nil {
+		unlock, err := fs.crossTab.lock(ctx)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
 	stores := []string{contentsStore, infoStore}
 	txn, err := fs.db.Transaction(idb.TransactionReadWrite, stores[0], stores[1:]...)
 	if err != nil {
@@ -90,11 +160,55 @@ func (fs *FS) Clear(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	err = txn.Await(ctx)
+	return txn.Await(ctx)
+}
+
+// Export writes every file and directory in this FS to 'w' as a tar archive, for example to back up browser storage.
+func (fs *FS) Export(ctx context.Context, w io.Writer) error {
+	return tar.WriteFS(ctx, w, fs)
+}
+
+// ImportTar unpacks the tar archive 'r' into this FS, such as one created by Export. Blocks until the archive is fully unpacked.
+// This FS must be empty, so call Clear first to replace existing files. Attempts to close 'r' once unpacking completes.
+func (fs *FS) ImportTar(ctx context.Context, r io.Reader) error {
+	return fs.ImportTarWithOptions(ctx, r, tar.ReaderFSOptions{})
+}
+
+// ImportTarWithOptions is like ImportTar, but configures the unpacking with 'options', like a smaller memory budget with MaxMemory and SpillLargeFiles. The UnarchiveFS option is always this FS.
+func (fs *FS) ImportTarWithOptions(ctx context.Context, r io.Reader, options tar.ReaderFSOptions) error {
+	options.UnarchiveFS = fs
+	tarFS, err := tar.NewReaderFS(ctx, r, options)
 	if err != nil {
 		return err
 	}
-	return fs.Mkdir(".", 0666)
+	<-tarFS.Done()
+	return tarFS.UnarchiveErr()
+}
+
+var (
+	_ hackpadfs.CloserFS = &FS{}
+	_ hackpadfs.PingFS   = &FS{}
+)
+
+// Close implements hackpadfs.CloserFS
+// Closes the database connection, or stops receiving responses from the worker if Options.Worker is set. Other tabs can then upgrade or delete the database.
+func (fs *FS) Close() error {
+	if fs.crossTab != nil {
+		fs.crossTab.close()
+	}
+	if fs.worker != nil {
+		fs.worker.client.close()
+	}
+	if fs.db != nil {
+		return fs.db.Close()
+	}
+	return nil
+}
+
+// Ping implements hackpadfs.PingFS
+// Reads the root directory in a read-only transaction, or through the worker if Options.Worker is set.
+func (fs *FS) Ping(ctx context.Context) error {
+	return fs.kv.Ping(ctx)
 }
 
 // Open implements hackpadfs.FS
@@ -109,24 +223,60 @@ func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadf
 
 // Mkdir implements hackpadfs.MkdirFS
 func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	if fs.worker != nil {
+		return fs.worker.mkdir(name, perm, false)
+	}
 	return fs.kv.Mkdir(name, perm)
 }
 
 // MkdirAll implements hackpadfs.MkdirAllFS
 func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	if fs.worker != nil {
+		return fs.worker.mkdir(path, perm, true)
+	}
 	return fs.kv.MkdirAll(path, perm)
 }
 
 // Remove implements hackpadfs.RemoveFS
 func (fs *FS) Remove(name string) error {
+	if fs.worker != nil {
+		return fs.worker.remove(name, false)
+	}
 	return fs.kv.Remove(name)
 }
 
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	if fs.worker != nil {
+		return fs.worker.remove(name, true)
+	}
+	return fs.kv.RemoveAll(name)
+}
+
 // Rename implements hackpadfs.RenameFS
 func (fs *FS) Rename(oldname, newname string) error {
+	if fs.worker != nil {
+		return fs.worker.rename(oldname, newname)
+	}
 	return fs.kv.Rename(oldname, newname)
 }
 
+// DiskUsage implements hackpadfs.UsageFS
+func (fs *FS) DiskUsage(root string) (files, dirs, bytes int64, err error) {
+	return fs.kv.DiskUsage(root)
+}
+
+// SameFile implements hackpadfs.SameFileFS
+func (fs *FS) SameFile(fi1, fi2 hackpadfs.FileInfo) bool {
+	return fs.kv.SameFile(fi1, fi2)
+}
+
+// Watch implements hackpadfs.WatchFS
+// Reports changes made by this FS, and with Options.CrossTab, changes made in other tabs and workers. Not supported with Options.Worker.
+func (fs *FS) Watch(ctx context.Context, name string) (<-chan hackpadfs.Event, error) {
+	return fs.kv.Watch(ctx, name)
+}
+
 // Stat implements hackpadfs.StatFS
 func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
 	return fs.kv.Stat(name)
@@ -141,3 +291,44 @@ func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
 func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
 	return fs.kv.Chtimes(name, atime, mtime)
 }
+
+// ApplyMetadata implements hackpadfs.ApplyMetadataFS
+func (fs *FS) ApplyMetadata(metadata map[string]hackpadfs.Metadata) error {
+	if fs.worker != nil {
+		return fs.worker.applyMetadata(metadata)
+	}
+	return fs.kv.ApplyMetadata(metadata)
+}
+
+// ReadDirN implements hackpadfs.ReadDirPagedFS, reading a page of entries at a time with an IndexedDB cursor.
+// Entries are returned in path order. With Options.Worker set, the whole directory is read for each page instead.
+func (fs *FS) ReadDirN(name, token string) ([]hackpadfs.DirEntry, string, error) {
+	store, ok := fs.store.(*store)
+	if !ok {
+		return hackpadfs.ReadDirPaged(fs.kv, name, token)
+	}
+	info, err := fs.kv.Stat(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if !info.IsDir() {
+		return nil, "", &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrNotDir}
+	}
+	storeName := name
+	if store.options.NormalizeName != nil {
+		storeName = store.options.NormalizeName(name)
+	}
+	names, nextToken, err := store.listDirPage(context.Background(), storeName, token, readDirPageSize)
+	if err != nil {
+		return nil, "", &hackpadfs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	entries := make([]hackpadfs.DirEntry, 0, len(names))
+	for _, entryName := range names {
+		info, err := fs.kv.Stat(path.Join(name, entryName))
+		if err != nil {
+			return nil, "", err
+		}
+		entries = append(entries, gofs.FileInfoToDirEntry(info))
+	}
+	return entries, nextToken, nil
+}
@@ -4,6 +4,8 @@
 package indexeddb
 
 import (
+	archivetar "archive/tar"
+	"bytes"
 	"context"
 	"crypto/rand"
 	"fmt"
@@ -14,6 +16,7 @@ import (
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/tar"
 )
 
 const (
@@ -32,7 +35,6 @@ func makeFS(tb testing.TB) *FS {
 		tb.Fatal(err)
 	}
 	tb.Cleanup(func() {
-		logFS(tb, fs)
 		req, err := factory.DeleteDatabase(name)
 		assert.NoError(tb, err)
 		assert.NoError(tb, req.Await(context.Background()))
@@ -47,33 +49,11 @@ func TestFS(t *testing.T) {
 		TestFS: func(tb testing.TB) fstest.SetupFS {
 			return makeFS(tb)
 		},
+		LogFSOnFailure: true,
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
-}
-
-func logFS(tb testing.TB, fs hackpadfs.FS) {
-	if !tb.Failed() {
-		return
-	}
-	tb.Log("FS contents:")
-	assert.NoError(tb, hackpadfs.WalkDir(fs, ".", func(path string, d hackpadfs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		info, err := d.Info()
-		if err != nil {
-			tb.Log(path+":", "Unexpected error getting file info:", err)
-			return nil
-		}
-		if info.Mode().IsDir() {
-			tb.Log(path+":", info.Mode())
-		} else {
-			tb.Log(path+":", info.Mode(), info.Size())
-		}
-		return nil
-	}))
-
+	fstest.StdFS(t, options)
 }
 
 func TestClear(t *testing.T) {
@@ -103,3 +83,126 @@ func TestClear(t *testing.T) {
 		assert.Equal(t, 0, len(dirEntries))
 	}
 }
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.NoError(t, hackpadfs.Ping(context.Background(), fs))
+
+	assert.NoError(t, fs.Close())
+	assert.Error(t, fs.Ping(context.Background())) // the database connection is closed
+}
+
+func TestExportImportTar(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	src := makeFS(t)
+	assert.NoError(t, src.MkdirAll("foo/bar", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "foo/bar/baz", []byte("baz"), 0600))
+
+	var buf bytes.Buffer
+	assert.NoError(t, src.Export(ctx, &buf))
+
+	dest := makeFS(t)
+	assert.NoError(t, dest.ImportTar(ctx, &buf))
+	contents, err := hackpadfs.ReadFile(dest, "foo/bar/baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(contents))
+	info, err := hackpadfs.Stat(dest, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.ModeDir|0700, info.Mode())
+}
+
+func TestImportTarWithOptions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	src := makeFS(t)
+	contents := bytes.Repeat([]byte("a"), 100)
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "large", contents, 0600))
+
+	var buf bytes.Buffer
+	assert.NoError(t, src.Export(ctx, &buf))
+
+	dest := makeFS(t)
+	assert.NoError(t, dest.ImportTarWithOptions(ctx, &buf, tar.ReaderFSOptions{
+		MaxMemory:       64,
+		SmallBufferSize: 16,
+		SpillLargeFiles: true,
+	}))
+	destContents, err := hackpadfs.ReadFile(dest, "large")
+	assert.NoError(t, err)
+	assert.Equal(t, string(contents), string(destContents))
+}
+
+func TestReadDirN(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, name, nil, 0600))
+	}
+	assert.NoError(t, fs.MkdirAll("d/sub", 0700))
+	assert.NoError(t, fs.Mkdir("e", 0700))
+
+	var names []string
+	token := ""
+	for {
+		pageNames, nextToken, err := fs.store.(*store).listDirPage(context.Background(), ".", token, 2)
+		assert.NoError(t, err)
+		names = append(names, pageNames...)
+		if nextToken == "" {
+			break
+		}
+		token = nextToken
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, names)
+
+	entries, nextToken, err := hackpadfs.ReadDirPaged(fs, "d", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "", nextToken)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, "sub", entries[0].Name())
+		assert.Equal(t, true, entries[0].IsDir())
+	}
+}
+
+// BenchmarkTarUnpack unpacks an archive of small files into IndexedDB with varying numbers of tar workers
+func BenchmarkTarUnpack(b *testing.B) {
+	var buf bytes.Buffer
+	archive := archivetar.NewWriter(&buf)
+	for i := 0; i < 200; i++ {
+		contents := bytes.Repeat([]byte{byte(i)}, 4096)
+		assert.NoError(b, archive.WriteHeader(&archivetar.Header{
+			Name:     fmt.Sprintf("dir/%03d", i),
+			Mode:     0600,
+			Size:     int64(len(contents)),
+			Typeflag: archivetar.TypeReg,
+		}))
+		_, err := archive.Write(contents)
+		assert.NoError(b, err)
+	}
+	assert.NoError(b, archive.Close())
+	archiveBytes := buf.Bytes()
+
+	for _, workers := range []int{1, 4, 16} {
+		workers := workers
+		b.Run(fmt.Sprintf("%d workers", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(archiveBytes)))
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				fs := makeFS(b)
+				b.StartTimer()
+				tarFS, err := tar.NewReaderFS(context.Background(), bytes.NewReader(archiveBytes), tar.ReaderFSOptions{
+					UnarchiveFS: fs,
+					Workers:     workers,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				<-tarFS.Done()
+				if err := tarFS.UnarchiveErr(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -30,6 +30,7 @@ var (
 		blob.Blob
 		blob.ViewBlob
 		blob.SliceBlob
+		blob.CloneBlob
 		blob.SetBlob
 		blob.GrowBlob
 		blob.TruncateBlob
@@ -210,6 +211,11 @@ func (b *Blob) Slice(start, end int64) (blob.Blob, error) {
 	return newBlob, nil
 }
 
+// Clone implements blob.CloneBlob
+func (b *Blob) Clone() (blob.Blob, error) {
+	return b.Slice(0, int64(b.Len()))
+}
+
 // Set implements blob.SetBlob
 func (b *Blob) Set(src blob.Blob, destStart int64) (n int, err error) {
 	if destStart < 0 {
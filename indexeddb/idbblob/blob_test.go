@@ -0,0 +1,21 @@
+//go:build wasm
+// +build wasm
+
+package idbblob_test
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/indexeddb/idbblob"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+func BenchmarkBlob(b *testing.B) {
+	fstest.BenchmarkBlob(b, fstest.BlobOptions{
+		Name: "idbblob",
+		NewBlob: func(tb testing.TB, data []byte) blob.Blob {
+			return idbblob.FromBlob(blob.NewBytes(data)) // copies 'data' into a new Uint8Array
+		},
+	})
+}
@@ -0,0 +1,191 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/hack-pad/safejs"
+)
+
+const (
+	legacyInfoStore = "info" // metadata store for schema version 1, superseded by infoStore in version 2
+
+	sizeKey = "Size"
+	modeKey = "Mode"
+)
+
+// migration upgrades the database schema by one version. 'oldVersion' is the schema version the database was opened with, or 0 for a new database.
+// Migrations run inside the "upgradeneeded" callback, so they may only create or delete object stores and indexes.
+type migration func(db *idb.Database, oldVersion uint) error
+
+// migrations contains every schema change in order, where migrations[i] upgrades the database from version i to i+1.
+// New schema versions must be appended. Existing migrations must never change, since databases in the wild have already run them.
+var migrations = []migration{
+	migrateV1,
+	migrateV2,
+}
+
+// fsVersion is the current schema version
+var fsVersion = uint(len(migrations))
+
+// upgrade runs each migration needed to bring the database from 'oldVersion' to 'newVersion'
+func upgrade(db *idb.Database, oldVersion, newVersion uint) error {
+	if newVersion > uint(len(migrations)) {
+		return fmt.Errorf("indexeddb: unknown schema version %d", newVersion)
+	}
+	for version := oldVersion; version < newVersion; version++ {
+		if err := migrations[version](db, oldVersion); err != nil {
+			return fmt.Errorf("indexeddb: upgrade to schema version %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+// migrateV1 creates stores for file contents and metadata, indexing metadata by parent directory
+func migrateV1(db *idb.Database, oldVersion uint) error {
+	_, err := db.CreateObjectStore(contentsStore, idb.ObjectStoreOptions{})
+	if err != nil {
+		return err
+	}
+	infos, err := db.CreateObjectStore(legacyInfoStore, idb.ObjectStoreOptions{})
+	if err != nil {
+		return err
+	}
+	return createIndexes(infos, parentKey)
+}
+
+// migrateV2 creates a new metadata store which is also indexed by size and mode.
+//
+// The upgrade transaction isn't exposed by idb, so indexes can't be added to the existing store here.
+// Instead, records are moved from the old store by moveLegacyInfos once the database is open.
+func migrateV2(db *idb.Database, oldVersion uint) error {
+	infos, err := db.CreateObjectStore(infoStore, idb.ObjectStoreOptions{})
+	if err != nil {
+		return err
+	}
+	if err := createIndexes(infos, parentKey, sizeKey, modeKey); err != nil {
+		return err
+	}
+	if oldVersion == 0 {
+		// new databases have nothing to move
+		return db.DeleteObjectStore(legacyInfoStore)
+	}
+	return nil
+}
+
+func createIndexes(store *idb.ObjectStore, keys ...string) error {
+	for _, key := range keys {
+		jsKey, err := safejs.ValueOf(key)
+		if err != nil {
+			return err
+		}
+		_, err = store.CreateIndex(key, safejs.Unsafe(jsKey), idb.IndexOptions{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migratedDatabases contains the names of databases with no legacy records left to move, so opening them again skips moveLegacyInfos
+var migratedDatabases sync.Map // type: string -> struct{}
+
+// moveLegacyInfos moves any records in the version 1 metadata store of the database 'name' into infoStore.
+// An interrupted move must be retried, so the legacy store is counted on the first open of each database. The read-write move only runs if records remain, and it happens in a single transaction, so records are never lost or duplicated.
+func moveLegacyInfos(ctx context.Context, name string, db *idb.Database, options Options) error {
+	if _, done := migratedDatabases.Load(name); done {
+		return nil
+	}
+	names, err := db.ObjectStoreNames()
+	if err != nil {
+		return err
+	}
+	if containsString(names, legacyInfoStore) {
+		count, err := countLegacyInfos(ctx, db)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			if err := moveLegacyInfoRecords(ctx, db, options); err != nil {
+				return err
+			}
+		}
+	}
+	migratedDatabases.Store(name, struct{}{})
+	return nil
+}
+
+func countLegacyInfos(ctx context.Context, db *idb.Database) (uint, error) {
+	txn, err := db.Transaction(idb.TransactionReadOnly, legacyInfoStore)
+	if err != nil {
+		return 0, err
+	}
+	legacyInfos, err := txn.ObjectStore(legacyInfoStore)
+	if err != nil {
+		return 0, err
+	}
+	countReq, err := legacyInfos.Count()
+	if err != nil {
+		return 0, err
+	}
+	return countReq.Await(ctx)
+}
+
+func moveLegacyInfoRecords(ctx context.Context, db *idb.Database, options Options) error {
+	txn, err := db.TransactionWithOptions(idb.TransactionOptions{
+		Mode:       idb.TransactionReadWrite,
+		Durability: options.TransactionDurability,
+	}, legacyInfoStore, infoStore)
+	if err != nil {
+		return err
+	}
+	legacyInfos, err := txn.ObjectStore(legacyInfoStore)
+	if err != nil {
+		return err
+	}
+	infos, err := txn.ObjectStore(infoStore)
+	if err != nil {
+		return err
+	}
+	cursorReq, err := legacyInfos.OpenCursor(idb.CursorNext)
+	if err != nil {
+		return err
+	}
+	err = cursorReq.Iter(ctx, func(cursor *idb.CursorWithValue) error {
+		key, err := cursor.Key()
+		if err != nil {
+			return err
+		}
+		value, err := cursor.Value()
+		if err != nil {
+			return err
+		}
+		if _, err := infos.PutKey(key, value); err != nil {
+			return err
+		}
+		_, err = cursor.Delete()
+		return err
+	})
+	if err != nil {
+		_ = txn.Abort()
+		return err
+	}
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+	return txn.Await(ctx)
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
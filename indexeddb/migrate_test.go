@@ -0,0 +1,87 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/safejs"
+)
+
+func TestMigrateV1ToV2(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	name := fmt.Sprintf("%s%s", testDBPrefix, t.Name())
+	factory := idb.Global()
+	t.Cleanup(func() {
+		req, err := factory.DeleteDatabase(name)
+		assert.NoError(t, err)
+		assert.NoError(t, req.Await(ctx))
+	})
+
+	openRequest, err := factory.Open(ctx, name, 1, upgrade)
+	assert.NoError(t, err)
+	db, err := openRequest.Await(ctx)
+	assert.NoError(t, err)
+	txn, err := db.Transaction(idb.TransactionReadWrite, legacyInfoStore)
+	assert.NoError(t, err)
+	infos, err := txn.ObjectStore(legacyInfoStore)
+	assert.NoError(t, err)
+	for _, info := range []struct {
+		name   string
+		record map[string]interface{}
+	}{
+		{".", map[string]interface{}{"ModTime": 0, "Mode": uint32(hackpadfs.ModeDir | 0700), "Size": 0}},
+		{"foo", map[string]interface{}{"ModTime": 0, "Mode": uint32(hackpadfs.ModeDir | 0700), "Size": 0, parentKey: "."}},
+	} {
+		jsName, err := safejs.ValueOf(info.name)
+		assert.NoError(t, err)
+		jsRecord, err := safejs.ValueOf(info.record)
+		assert.NoError(t, err)
+		_, err = infos.PutKey(safejs.Unsafe(jsName), safejs.Unsafe(jsRecord))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, txn.Commit())
+	assert.NoError(t, txn.Await(ctx))
+	assert.NoError(t, db.Close())
+
+	fs, err := NewFS(ctx, name, Options{})
+	assert.NoError(t, err)
+	version, err := fs.db.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, fsVersion, version)
+	_, migrated := migratedDatabases.Load(name)
+	assert.Equal(t, true, migrated) // later opens skip the move
+
+	info, err := hackpadfs.Stat(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, true, info.IsDir())
+	entries, err := hackpadfs.ReadDir(fs, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	txn, err = fs.db.Transaction(idb.TransactionReadOnly, legacyInfoStore)
+	assert.NoError(t, err)
+	infos, err = txn.ObjectStore(legacyInfoStore)
+	assert.NoError(t, err)
+	countReq, err := infos.Count()
+	assert.NoError(t, err)
+	count, err := countReq.Await(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(0), count)
+	assert.NoError(t, fs.db.Close())
+}
+
+func TestNewDatabaseSkipsLegacyStore(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	names, err := fs.db.ObjectStoreNames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{contentsStore, infoStore}, names)
+}
@@ -0,0 +1,33 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// init registers the "idb" scheme for hackpadfs.New(). URLs like "idb:name" open the database 'name' with default Options.
+func init() {
+	hackpadfs.Register("idb", func(rawURL string) (hackpadfs.FS, error) {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		name := u.Opaque
+		if name == "" {
+			name = u.Host + u.Path
+		}
+		if name == "" {
+			return nil, &hackpadfs.PathError{Op: "new", Path: rawURL, Err: hackpadfs.ErrInvalid}
+		}
+		fs, err := NewFS(context.Background(), name, Options{})
+		if err != nil {
+			return nil, err
+		}
+		return fs, nil
+	})
+}
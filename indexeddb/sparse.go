@@ -0,0 +1,90 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpadfs/indexeddb/idbblob"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/hack-pad/safejs"
+)
+
+const (
+	sparseLengthKey  = "Length"
+	sparseExtentsKey = "Extents"
+	extentOffsetKey  = "Offset"
+	extentDataKey    = "Data"
+)
+
+// encodeContents returns the JS value stored for a file's contents.
+// Sparse contents are stored as an object containing each data extent, so the zeros between extents aren't written to IndexedDB.
+// All other contents are stored as a Uint8Array.
+func encodeContents(data blob.Blob) (js.Value, error) {
+	sparse, ok := data.(*blob.Sparse)
+	if !ok {
+		return idbblob.FromBlob(data).JSValue(), nil
+	}
+	extents := sparse.Extents()
+	jsExtents := make([]interface{}, 0, len(extents))
+	for _, extent := range extents {
+		jsExtents = append(jsExtents, map[string]interface{}{
+			extentOffsetKey: extent.Offset,
+			extentDataKey:   idbblob.FromBlob(extent.Data).JSValue(),
+		})
+	}
+	value, err := safejs.ValueOf(map[string]interface{}{
+		sparseLengthKey:  sparse.Len(),
+		sparseExtentsKey: jsExtents,
+	})
+	return safejs.Unsafe(value), err
+}
+
+// decodeContents parses a file's contents stored by encodeContents
+func decodeContents(value safejs.Value) (blob.Blob, error) {
+	jsExtents, err := value.Get(sparseExtentsKey)
+	if err != nil {
+		return nil, err
+	}
+	if jsExtents.IsUndefined() {
+		return idbblob.New(safejs.Unsafe(value))
+	}
+	jsLength, err := value.Get(sparseLengthKey)
+	if err != nil {
+		return nil, err
+	}
+	length, err := jsLength.Int()
+	if err != nil {
+		return nil, err
+	}
+	extentCount, err := jsExtents.Length()
+	if err != nil {
+		return nil, err
+	}
+	extents := make([]blob.Extent, 0, extentCount)
+	for i := 0; i < extentCount; i++ {
+		jsExtent, err := jsExtents.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		jsOffset, err := jsExtent.Get(extentOffsetKey)
+		if err != nil {
+			return nil, err
+		}
+		offset, err := jsOffset.Int()
+		if err != nil {
+			return nil, err
+		}
+		jsData, err := jsExtent.Get(extentDataKey)
+		if err != nil {
+			return nil, err
+		}
+		data, err := idbblob.New(safejs.Unsafe(jsData))
+		if err != nil {
+			return nil, err
+		}
+		extents = append(extents, blob.Extent{Offset: int64(offset), Data: data})
+	}
+	return blob.NewSparse(int64(length), extents...)
+}
@@ -0,0 +1,40 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestSparseContents(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	f, err := fs.OpenFile("foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate, 0600)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("start"))
+	assert.NoError(t, err)
+	const offset = 1 << 32
+	_, err = hackpadfs.WriteAtFile(f, []byte("end"), offset)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	f, err = fs.Open("foo")
+	assert.NoError(t, err)
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(offset+3), info.Size())
+	buf := make([]byte, 5)
+	n, err := hackpadfs.ReadAtFile(f, buf, offset-2)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "\x00\x00end", string(buf))
+	_, err = hackpadfs.ReadAtFile(f, buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "start", string(buf))
+	assert.NoError(t, f.Close())
+}
@@ -0,0 +1,125 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"syscall/js"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/safejs"
+)
+
+const quotaExceededErrorName = "QuotaExceededError"
+
+var _ hackpadfs.QuotaFS = &FS{}
+
+// Usage implements hackpadfs.QuotaFS. Uses navigator.storage.estimate(), so the result covers all storage for this origin, not only this FS.
+func (fs *FS) Usage(ctx context.Context) (hackpadfs.StorageUsage, error) {
+	estimate, err := callStorageManager(ctx, "estimate")
+	if err != nil {
+		return hackpadfs.StorageUsage{}, err
+	}
+	used, err := getInt64(estimate, "usage")
+	if err != nil {
+		return hackpadfs.StorageUsage{}, err
+	}
+	quota, err := getInt64(estimate, "quota")
+	return hackpadfs.StorageUsage{Used: used, Quota: quota}, err
+}
+
+// RequestPersistentStorage asks the browser to exempt this origin's storage from eviction under storage pressure.
+// Returns true if storage is persistent. Browsers may prompt the user or deny the request.
+func RequestPersistentStorage(ctx context.Context) (bool, error) {
+	granted, err := callStorageManager(ctx, "persist")
+	if err != nil {
+		return false, err
+	}
+	return granted.Bool()
+}
+
+// PersistentStorage returns true if this origin's storage has been made persistent. See RequestPersistentStorage().
+func PersistentStorage(ctx context.Context) (bool, error) {
+	persisted, err := callStorageManager(ctx, "persisted")
+	if err != nil {
+		return false, err
+	}
+	return persisted.Bool()
+}
+
+// callStorageManager calls 'method' on navigator.storage and awaits the resulting promise.
+// Returns hackpadfs.ErrNotImplemented if the StorageManager API is not available.
+func callStorageManager(ctx context.Context, method string) (safejs.Value, error) {
+	navigator, err := safejs.Global().Get("navigator")
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	if navigator.IsUndefined() {
+		return safejs.Value{}, hackpadfs.ErrNotImplemented
+	}
+	storage, err := navigator.Get("storage")
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	if storage.IsUndefined() {
+		return safejs.Value{}, hackpadfs.ErrNotImplemented
+	}
+	promise, err := storage.Call(method)
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	return awaitPromise(ctx, promise)
+}
+
+func awaitPromise(ctx context.Context, promise safejs.Value) (safejs.Value, error) {
+	type result struct {
+		value safejs.Value
+		err   error
+	}
+	results := make(chan result, 1)
+	resolve, err := safejs.FuncOf(func(_ safejs.Value, args []safejs.Value) interface{} {
+		results <- result{value: args[0]}
+		return nil
+	})
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	reject, err := safejs.FuncOf(func(_ safejs.Value, args []safejs.Value) interface{} {
+		results <- result{err: js.Error{Value: safejs.Unsafe(args[0])}}
+		return nil
+	})
+	if err != nil {
+		resolve.Release()
+		return safejs.Value{}, err
+	}
+	release := func() {
+		resolve.Release()
+		reject.Release()
+	}
+	if _, err := promise.Call("then", resolve, reject); err != nil {
+		release()
+		return safejs.Value{}, err
+	}
+
+	select {
+	case r := <-results:
+		release()
+		return r.value, r.err
+	case <-ctx.Done():
+		go func() {
+			<-results // the promise will still settle, so release once it does
+			release()
+		}()
+		return safejs.Value{}, ctx.Err()
+	}
+}
+
+func getInt64(value safejs.Value, key string) (int64, error) {
+	jsValue, err := value.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	f, err := jsValue.Float()
+	return int64(f), err
+}
@@ -0,0 +1,36 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestQuotaExceededError(t *testing.T) {
+	t.Parallel()
+	err := withErrorContext(idb.NewDOMException(quotaExceededErrorName))
+	assert.ErrorIs(t, hackpadfs.ErrNoSpace, err)
+	var errContext *hackpadfs.ErrorContext
+	if assert.ErrorAs(t, &errContext, err) {
+		assert.Equal(t, quotaExceededErrorName, errContext.Code)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	usage, err := hackpadfs.Usage(context.Background(), fs)
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		t.Skip("StorageManager API is not available")
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, true, usage.Used >= 0)
+	assert.Equal(t, true, usage.Quota >= usage.Used)
+}
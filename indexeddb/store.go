@@ -5,13 +5,15 @@ package indexeddb
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/hack-pad/go-indexeddb/idb"
 	"github.com/hack-pad/hackpadfs"
-	"github.com/hack-pad/hackpadfs/indexeddb/idbblob"
 	"github.com/hack-pad/hackpadfs/keyvalue"
 	"github.com/hack-pad/hackpadfs/keyvalue/blob"
 	"github.com/hack-pad/safejs"
@@ -21,12 +23,18 @@ var (
 	_ interface {
 		keyvalue.Store
 		keyvalue.TransactionStore
+		keyvalue.VersionedStore
+		keyvalue.UsageStore
+		keyvalue.WatchableStore
+		keyvalue.PingStore
 	} = &store{}
 )
 
 type store struct {
-	db      *idb.Database
-	options Options
+	db       *idb.Database
+	options  Options
+	crossTab *crossTab // nil unless Options.CrossTab is set
+	watchers keyvalue.Watchers
 }
 
 func newStore(db *idb.Database, options Options) *store {
@@ -51,11 +59,24 @@ func getFirstCommitError(ops []keyvalue.OpResult, err error) error {
 	if err == nil || errors.Is(err, errAborted) {
 		for _, op := range ops {
 			if op.Err != nil {
-				return op.Err
+				return withErrorContext(op.Err)
 			}
 		}
 	}
-	return err
+	return withErrorContext(err)
+}
+
+// withErrorContext attaches the DOMException name, if any, to 'err'. Quota errors are converted to hackpadfs.ErrNoSpace.
+func withErrorContext(err error) error {
+	var domErr idb.DOMException
+	if !errors.As(err, &domErr) {
+		return err
+	}
+	name, _, _ := strings.Cut(domErr.Error(), ": ")
+	if name == quotaExceededErrorName {
+		err = hackpadfs.ErrNoSpace
+	}
+	return hackpadfs.WithErrorContext(err, hackpadfs.ErrorContext{Backend: "indexeddb", Code: name})
 }
 
 func (s *store) getFile(files *idb.ObjectStore, path string) (*getFileRequest, error) {
@@ -117,6 +138,14 @@ func (g *getFileRequest) parseResult(result safejs.Value, err error) (keyvalue.F
 	if err != nil {
 		return nil, err
 	}
+	version, err := getVersion(result)
+	if err != nil {
+		return nil, err
+	}
+	id, err := getID(result)
+	if err != nil {
+		return nil, err
+	}
 	var getData func() (blob.Blob, error)
 	var getDirNames func() ([]string, error)
 	if mode.IsDir() {
@@ -124,7 +153,8 @@ func (g *getFileRequest) parseResult(result safejs.Value, err error) (keyvalue.F
 	} else {
 		getData = g.store.getFileData(g.path)
 	}
-	return keyvalue.NewBaseFileRecord(int64(initialSize), modTime, mode, nil, getData, getDirNames), nil
+	record := keyvalue.NewBaseFileRecord(int64(initialSize), modTime, mode, nil, getData, getDirNames)
+	return keyvalue.WithVersion(keyvalue.WithID(record, id), version), nil
 }
 
 func (s *store) getFileData(path string) func() (blob.Blob, error) {
@@ -155,7 +185,7 @@ func (s *store) getFileData(path string) func() (blob.Blob, error) {
 		if err != nil {
 			return nil, err
 		}
-		return idbblob.New(value)
+		return decodeContents(safejs.Safe(value))
 	}
 }
 
@@ -200,6 +230,181 @@ func (s *store) getDirNames(name string) func() ([]string, error) {
 	}
 }
 
+// listDirPage returns up to 'pageSize' entry names in directory 'name' using a cursor on the parent index, starting after the child path 'after'.
+// Returns the path of the last listed entry to continue from, or "" after the last page.
+func (s *store) listDirPage(ctx context.Context, name, after string, pageSize int) (names []string, last string, err error) {
+	txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
+		Mode:       idb.TransactionReadOnly,
+		Durability: s.options.TransactionDurability,
+	}, infoStore)
+	if err != nil {
+		return nil, "", err
+	}
+	files, err := txn.ObjectStore(infoStore)
+	if err != nil {
+		return nil, "", err
+	}
+	parentIndex, err := files.Index(parentKey)
+	if err != nil {
+		return nil, "", err
+	}
+	jsName, err := safejs.ValueOf(name)
+	if err != nil {
+		return nil, "", err
+	}
+	jsAfter, err := safejs.ValueOf(after)
+	if err != nil {
+		return nil, "", err
+	}
+	keyRange, err := idb.NewKeyRangeOnly(safejs.Unsafe(jsName))
+	if err != nil {
+		return nil, "", err
+	}
+	cursorReq, err := parentIndex.OpenKeyCursorRange(keyRange, idb.CursorNext)
+	if err != nil {
+		return nil, "", err
+	}
+	more := false
+	err = cursorReq.Iter(ctx, func(cursor *idb.Cursor) error {
+		jsKey, err := cursor.PrimaryKey()
+		if err != nil {
+			return err
+		}
+		p := jsKey.String()
+		if p <= after {
+			if p == after {
+				return nil
+			}
+			return cursor.ContinuePrimaryKey(safejs.Unsafe(jsName), safejs.Unsafe(jsAfter)) // skip to the first entry from 'after' onward
+		}
+		if len(names) == pageSize {
+			more = true
+			return idb.ErrCursorStopIter
+		}
+		names = append(names, path.Base(p))
+		last = p
+		return nil
+	})
+	if err != nil || !more {
+		return names, "", err
+	}
+	return names, last, nil
+}
+
+// Ping implements keyvalue.PingStore, reading the root directory's record in a read-only transaction
+func (s *store) Ping(ctx context.Context) error {
+	txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
+		Mode:       idb.TransactionReadOnly,
+		Durability: s.options.TransactionDurability,
+	}, infoStore)
+	if err != nil {
+		return withErrorContext(err)
+	}
+	infos, err := txn.ObjectStore(infoStore)
+	if err != nil {
+		return withErrorContext(err)
+	}
+	jsRoot, err := safejs.ValueOf(rootPath)
+	if err != nil {
+		return err
+	}
+	rootReq, err := infos.Get(safejs.Unsafe(jsRoot))
+	if err != nil {
+		return withErrorContext(err)
+	}
+	root, err := rootReq.Await(ctx)
+	if err != nil {
+		return withErrorContext(err)
+	}
+	if root.IsUndefined() {
+		return hackpadfs.ErrNotExist
+	}
+	return nil
+}
+
+// DiskUsage implements keyvalue.UsageStore, totaling the Size of each file record under 'name' with one cursor instead of reading each file.
+func (s *store) DiskUsage(ctx context.Context, name string) (files, dirs, bytes int64, err error) {
+	txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
+		Mode:       idb.TransactionReadOnly,
+		Durability: s.options.TransactionDurability,
+	}, infoStore)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	infos, err := txn.ObjectStore(infoStore)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	jsName, err := safejs.ValueOf(name)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	rootReq, err := infos.Get(safejs.Unsafe(jsName))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var cursorReq *idb.CursorWithValueRequest
+	if name == rootPath {
+		cursorReq, err = infos.OpenCursor(idb.CursorNext)
+	} else {
+		var keyRange *idb.KeyRange
+		keyRange, err = prefixKeyRange(name + "/")
+		if err == nil {
+			cursorReq, err = infos.OpenCursorRange(keyRange, idb.CursorNext)
+		}
+	}
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	count := func(fileRecord safejs.Value) error {
+		mode, err := getMode(fileRecord)
+		if err != nil {
+			return err
+		}
+		if mode.IsDir() {
+			dirs++
+			return nil
+		}
+		files++
+		if !mode.IsRegular() {
+			return nil
+		}
+		jsSize, err := fileRecord.Get("Size")
+		if err != nil {
+			return err
+		}
+		size, err := jsSize.Int()
+		bytes += int64(size)
+		return err
+	}
+	err = cursorReq.Iter(ctx, func(cursor *idb.CursorWithValue) error {
+		key, err := cursor.Key()
+		if err != nil || key.String() == rootPath {
+			return err
+		}
+		value, err := cursor.Value()
+		if err != nil {
+			return err
+		}
+		return count(safejs.Safe(value))
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	root, err := rootReq.Result() // requests finish in order, so the root is ready once the cursor is done
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if root.IsUndefined() {
+		return 0, 0, 0, hackpadfs.ErrNotExist
+	}
+	if err := count(safejs.Safe(root)); err != nil {
+		return 0, 0, 0, err
+	}
+	return files, dirs, bytes, nil
+}
+
 func getMode(fileRecord safejs.Value) (hackpadfs.FileMode, error) {
 	mode, err := fileRecord.Get("Mode")
 	if err != nil {
@@ -209,6 +414,31 @@ func getMode(fileRecord safejs.Value) (hackpadfs.FileMode, error) {
 	return hackpadfs.FileMode(intMode), err
 }
 
+// getVersion returns the version of 'fileRecord', or "" if it was saved before records were versioned
+func getVersion(fileRecord safejs.Value) (string, error) {
+	version, err := fileRecord.Get("Version")
+	if err != nil || version.IsUndefined() {
+		return "", err
+	}
+	return version.String()
+}
+
+// getID returns the ID of 'fileRecord', or "" if it was saved before records kept IDs
+func getID(fileRecord safejs.Value) (string, error) {
+	id, err := fileRecord.Get("ID")
+	if err != nil || id.IsUndefined() {
+		return "", err
+	}
+	return id.String()
+}
+
+// newVersion returns a random version token for a file record
+func newVersion() (string, error) {
+	var buf [16]byte
+	_, err := rand.Read(buf[:])
+	return hex.EncodeToString(buf[:]), err
+}
+
 const rootPath = "."
 
 var errAborted = idb.NewDOMException("AbortError")
@@ -233,6 +463,44 @@ func (s *store) Set(ctx context.Context, name string, record keyvalue.FileRecord
 	return getFirstCommitError(ops, err)
 }
 
+// SetVersion implements keyvalue.VersionedStore
+func (s *store) SetVersion(ctx context.Context, name string, record keyvalue.FileRecord, ifVersion string) (string, error) {
+	var data blob.Blob
+	if record != nil && record.Mode().IsRegular() {
+		var err error
+		data, err = record.Data()
+		if err != nil {
+			return "", err
+		}
+	}
+	version, err := newVersion()
+	if err != nil {
+		return "", err
+	}
+	txn, err := s.Transaction(keyvalue.TransactionOptions{
+		Mode: keyvalue.TransactionReadWrite,
+	})
+	if err != nil {
+		return "", err
+	}
+	txn.GetHandler(name, keyvalue.OpHandlerFunc(func(txn keyvalue.Transaction, result keyvalue.OpResult) error {
+		var current string
+		switch {
+		case result.Err == nil:
+			current = result.Record.(keyvalue.VersionedFileRecord).Version()
+		case !errors.Is(result.Err, hackpadfs.ErrNotExist):
+			return result.Err
+		}
+		if current != ifVersion {
+			return &keyvalue.VersionConflictError{Path: name, Version: ifVersion, Current: current}
+		}
+		txn.(*transaction).setVersion(name, record, data, version)
+		return nil
+	}))
+	ops, err := txn.Commit(ctx)
+	return version, getFirstCommitError(ops, err)
+}
+
 func deleteRecord(infos, contents *idb.ObjectStore, name string) (*idb.AckRequest, error) {
 	jsName, err := safejs.ValueOf(name)
 	if err != nil {
@@ -245,17 +513,59 @@ func deleteRecord(infos, contents *idb.ObjectStore, name string) (*idb.AckReques
 	return contents.Delete(safejs.Unsafe(jsName))
 }
 
+// listPrefix requests the keys in 'infos' beginning with 'prefix'. IndexedDB returns keys in sorted order.
+func listPrefix(infos *idb.ObjectStore, prefix string) (*idb.ArrayRequest, error) {
+	if prefix == "" {
+		return infos.GetAllKeys()
+	}
+	keyRange, err := prefixKeyRange(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return infos.GetAllKeysRange(keyRange, 0)
+}
+
+// prefixKeyRange returns a key range matching every path beginning with 'prefix'
+func prefixKeyRange(prefix string) (*idb.KeyRange, error) {
+	jsLower, err := safejs.ValueOf(prefix)
+	if err != nil {
+		return nil, err
+	}
+	jsUpper, err := safejs.ValueOf(prefix + "\uffff")
+	if err != nil {
+		return nil, err
+	}
+	return idb.NewKeyRangeBound(safejs.Unsafe(jsLower), safejs.Unsafe(jsUpper), false, false)
+}
+
+// listPrefixResult returns the paths found by a listPrefix request
+func listPrefixResult(req *idb.ArrayRequest) ([]string, error) {
+	jsKeys, err := req.Result()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(jsKeys))
+	for _, jsKey := range jsKeys {
+		paths = append(paths, jsKey.String())
+	}
+	return paths, nil
+}
+
 func setFileContents(contents *idb.ObjectStore, name string, data blob.Blob) error {
 	jsName, err := safejs.ValueOf(name)
 	if err != nil {
 		return err
 	}
-	_, err = contents.PutKey(safejs.Unsafe(jsName), idbblob.FromBlob(data).JSValue())
+	jsData, err := encodeContents(data)
+	if err != nil {
+		return err
+	}
+	_, err = contents.PutKey(safejs.Unsafe(jsName), jsData)
 	return err
 }
 
-// validateAndSetFileMeta verifies the file by 'name' has a parent directory, then updates the file metadata. If not nil, 'data' is used to detect size instead of record.Size().
-func validateAndSetFileMeta(ctx context.Context, infos *idb.ObjectStore, name string, record keyvalue.FileRecord, data blob.Blob) (*idb.Request, *parentDirExistsReq, error) {
+// validateAndSetFileMeta verifies the file by 'name' has a parent directory, then updates the file metadata and assigns it 'version'. If not nil, 'data' is used to detect size instead of record.Size().
+func validateAndSetFileMeta(ctx context.Context, infos *idb.ObjectStore, name string, record keyvalue.FileRecord, data blob.Blob, version string) (*idb.Request, *parentDirExistsReq, error) {
 	var size int64
 	if data == nil {
 		size = record.Size()
@@ -266,6 +576,10 @@ func validateAndSetFileMeta(ctx context.Context, infos *idb.ObjectStore, name st
 		"ModTime": record.ModTime().UnixNano(),
 		"Mode":    uint32(record.Mode()),
 		"Size":    size,
+		"Version": version,
+	}
+	if id := keyvalue.RecordID(record); id != "" {
+		fileInfo["ID"] = id
 	}
 	if name != rootPath {
 		fileInfo[parentKey] = path.Dir(name)
@@ -347,13 +661,26 @@ func (s *store) Transaction(options keyvalue.TransactionOptions) (keyvalue.Trans
 		stores = append(stores, contentsStore)
 	}
 	ctx, cancel := context.WithCancel(context.Background())
+	unlock := func() {}
+	if s.crossTab != nil && mode == idb.TransactionReadWrite {
+		var err error
+		unlock, err = s.crossTab.lock(ctx)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
 	txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
 		Mode:       mode,
 		Durability: s.options.TransactionDurability,
 	}, stores[0], stores[1:]...)
+	if err != nil {
+		unlock()
+	}
 	return &transaction{
 		ctx:     ctx,
 		abort:   cancel,
+		unlock:  unlock,
 		store:   s,
 		txn:     txn,
 		results: make(map[keyvalue.OpID]keyvalue.OpResult),
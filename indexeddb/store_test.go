@@ -14,6 +14,7 @@ import (
 	"github.com/hack-pad/hackpadfs/internal/assert"
 	"github.com/hack-pad/hackpadfs/keyvalue"
 	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/hack-pad/hackpadfs/keyvalue/storetest"
 )
 
 func init() {
@@ -39,6 +40,13 @@ func testFile(contents string) (keyvalue.FileRecord, blob.Blob) {
 	), blob.NewBytes(data)
 }
 
+func TestStore(t *testing.T) {
+	t.Parallel()
+	storetest.Store(t, func(tb testing.TB) keyvalue.Store {
+		return newStore(makeFS(tb).db, Options{})
+	})
+}
+
 func TestStoreGetSet(t *testing.T) {
 	t.Parallel()
 	store := newStore(makeFS(t).db, Options{})
@@ -99,3 +107,29 @@ func TestStoreGetParentIsFile(t *testing.T) {
 	err := store.Set(ctx, "foo/bar", barRecord)
 	assert.ErrorIs(t, hackpadfs.ErrNotDir, err)
 }
+
+func TestStoreSetVersion(t *testing.T) {
+	t.Parallel()
+	store := newStore(makeFS(t).db, Options{})
+
+	ctx := context.Background()
+	record, _ := testFile("foo")
+	version, err := store.SetVersion(ctx, "foo", record, "")
+	assert.NoError(t, err)
+	assert.NotEqual(t, "", version)
+	getRecord, err := store.Get(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, version, getRecord.(keyvalue.VersionedFileRecord).Version())
+
+	_, err = store.SetVersion(ctx, "foo", record, "")
+	assert.ErrorIs(t, keyvalue.ErrVersionConflict, err)
+	assert.NoError(t, store.Set(ctx, "foo", record))
+	_, err = store.SetVersion(ctx, "foo", record, version)
+	assert.ErrorIs(t, keyvalue.ErrVersionConflict, err)
+
+	getRecord, err = store.Get(ctx, "foo")
+	assert.NoError(t, err)
+	newVersion, err := store.SetVersion(ctx, "foo", record, getRecord.(keyvalue.VersionedFileRecord).Version())
+	assert.NoError(t, err)
+	assert.NotEqual(t, version, newVersion)
+}
@@ -18,11 +18,13 @@ import (
 type transaction struct {
 	ctx            context.Context
 	abort          context.CancelFunc
+	unlock         func() // releases the cross-tab lock, if held
 	store          *store
 	txn            *idb.Transaction
 	nextOp         keyvalue.OpID
 	results        map[keyvalue.OpID]keyvalue.OpResult
 	pendingResults []func()
+	changes        []keyvalue.Event
 	resultsMu      sync.Mutex
 }
 
@@ -112,16 +114,26 @@ func (t *transaction) GetHandler(path string, handler keyvalue.OpHandler) (op ke
 }
 
 func (t *transaction) Set(name string, record keyvalue.FileRecord, contents blob.Blob) (op keyvalue.OpID) {
-	op = t.newOp()
-	_, err := t.set(op, name, record, contents)
+	version, err := newVersion()
 	if err != nil {
+		op = t.newOp()
 		t.setResult(op, keyvalue.OpResult{Op: op, Err: err})
 		return
 	}
+	return t.setVersion(name, record, contents, version)
+}
+
+// setVersion is like Set, but assigns the given 'version' to the file
+func (t *transaction) setVersion(name string, record keyvalue.FileRecord, contents blob.Blob, version string) (op keyvalue.OpID) {
+	op = t.newOp()
+	_, err := t.set(op, name, record, contents, version)
+	if err != nil {
+		t.setResult(op, keyvalue.OpResult{Op: op, Err: err})
+	}
 	return
 }
 
-func (t *transaction) set(op keyvalue.OpID, name string, record keyvalue.FileRecord, data blob.Blob) (*idb.Request, error) {
+func (t *transaction) set(op keyvalue.OpID, name string, record keyvalue.FileRecord, data blob.Blob, version string) (*idb.Request, error) {
 	infos, err := t.txn.ObjectStore(infoStore)
 	if err != nil {
 		return nil, err
@@ -131,11 +143,14 @@ func (t *transaction) set(op keyvalue.OpID, name string, record keyvalue.FileRec
 		return nil, err
 	}
 	t.setResult(op, keyvalue.OpResult{Op: op}) // Ensure an op is recorded. A later result can overwrite it.
+	if record == nil && name == rootPath {
+		return nil, hackpadfs.ErrNotImplemented // cannot delete root dir
+	}
+	t.resultsMu.Lock()
+	t.changes = append(t.changes, keyvalue.Event{Path: name, Deleted: record == nil})
+	t.resultsMu.Unlock()
 
 	if record == nil {
-		if name == rootPath {
-			return nil, hackpadfs.ErrNotImplemented // cannot delete root dir
-		}
 		req, err := deleteRecord(infos, contents, name)
 		if err != nil {
 			return nil, err
@@ -152,7 +167,7 @@ func (t *transaction) set(op keyvalue.OpID, name string, record keyvalue.FileRec
 	}
 
 	// always set metadata to update size when contents change
-	req, parentExistsReq, err := validateAndSetFileMeta(t.ctx, infos, name, record, data)
+	req, parentExistsReq, err := validateAndSetFileMeta(t.ctx, infos, name, record, data, version)
 	if err != nil {
 		return nil, err
 	}
@@ -163,7 +178,12 @@ func (t *transaction) set(op keyvalue.OpID, name string, record keyvalue.FileRec
 
 func (t *transaction) SetHandler(name string, record keyvalue.FileRecord, data blob.Blob, handler keyvalue.OpHandler) (op keyvalue.OpID) {
 	op = t.newOp()
-	req, err := t.set(op, name, record, data)
+	version, err := newVersion()
+	if err != nil {
+		t.setResult(op, keyvalue.OpResult{Op: op, Err: err})
+		return
+	}
+	req, err := t.set(op, name, record, data, version)
 	if err != nil {
 		t.setResult(op, keyvalue.OpResult{Op: op, Err: err})
 		return
@@ -184,9 +204,76 @@ func (t *transaction) SetHandler(name string, record keyvalue.FileRecord, data b
 	return
 }
 
+func (t *transaction) Delete(name string) keyvalue.OpID {
+	return t.setVersion(name, nil, nil, "")
+}
+
+func (t *transaction) DeleteHandler(name string, handler keyvalue.OpHandler) keyvalue.OpID {
+	return t.SetHandler(name, nil, nil, handler)
+}
+
+func (t *transaction) ListPrefix(prefix string) (op keyvalue.OpID) {
+	op = t.newOp()
+	req, err := t.listPrefix(prefix)
+	if err != nil {
+		t.setResult(op, keyvalue.OpResult{Op: op, Err: err})
+		return
+	}
+	t.resultsMu.Lock()
+	t.pendingResults = append(t.pendingResults, func() {
+		paths, err := listPrefixResult(req)
+		t.setResult(op, keyvalue.OpResult{Op: op, Paths: paths, Err: err})
+	})
+	t.resultsMu.Unlock()
+	return
+}
+
+func (t *transaction) ListPrefixHandler(prefix string, handler keyvalue.OpHandler) (op keyvalue.OpID) {
+	op = t.newOp()
+	req, err := t.listPrefix(prefix)
+	if err != nil {
+		t.setResult(op, keyvalue.OpResult{Op: op, Err: err})
+		return
+	}
+	listenErr := req.Listen(t.ctx, func() {
+		paths, err := listPrefixResult(req)
+		result := keyvalue.OpResult{Op: op, Paths: paths, Err: err}
+		if err := handler.Handle(t, result); err != nil {
+			result.Err = err
+		}
+		t.setResult(op, result)
+	}, func() {
+		t.setResult(op, keyvalue.OpResult{Op: op, Err: req.Err()})
+	})
+	if listenErr != nil {
+		t.setResult(op, keyvalue.OpResult{Op: op, Err: listenErr})
+		return
+	}
+	return
+}
+
+func (t *transaction) listPrefix(prefix string) (*idb.ArrayRequest, error) {
+	infos, err := t.txn.ObjectStore(infoStore)
+	if err != nil {
+		return nil, err
+	}
+	return listPrefix(infos, prefix)
+}
+
 func (t *transaction) Commit(ctx context.Context) ([]keyvalue.OpResult, error) {
 	awaitErr := t.txn.Await(ctx)
 	t.abort()
+	t.unlock()
+	if awaitErr == nil {
+		t.store.watchers.Send(t.changes...)
+		if t.store.crossTab != nil {
+			paths := make([]string, 0, len(t.changes))
+			for _, change := range t.changes {
+				paths = append(paths, change.Path)
+			}
+			t.store.crossTab.notify(paths)
+		}
+	}
 	for _, fn := range t.pendingResults {
 		fn()
 	}
@@ -203,5 +290,7 @@ func (t *transaction) Commit(ctx context.Context) ([]keyvalue.OpResult, error) {
 }
 
 func (t *transaction) Abort() error {
-	return t.txn.Abort()
+	err := t.txn.Abort()
+	t.unlock()
+	return err
 }
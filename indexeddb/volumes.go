@@ -0,0 +1,210 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"io"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/safejs"
+)
+
+// Volume describes an IndexedDB database holding an FS, one per name passed to NewFS
+type Volume struct {
+	Name    string
+	Version uint // Version is the database's schema version. Older versions are upgraded the next time NewFS opens them.
+}
+
+// ListVolumes returns the IndexedDB databases in this origin which hold an FS, skipping databases created by other code.
+// Uses the global indexedDB.databases(), so it fails with hackpadfs.ErrNotImplemented in browsers without it.
+func ListVolumes(ctx context.Context) ([]Volume, error) {
+	databases, err := listDatabases(ctx)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "listvolumes", Path: ".", Err: err}
+	}
+	var volumes []Volume
+	for _, volume := range databases {
+		isFS, err := isVolume(ctx, volume.Name)
+		if err != nil {
+			return nil, &hackpadfs.PathError{Op: "listvolumes", Path: volume.Name, Err: err}
+		}
+		if isFS {
+			volumes = append(volumes, volume)
+		}
+	}
+	return volumes, nil
+}
+
+// listDatabases returns every IndexedDB database in this origin
+func listDatabases(ctx context.Context) ([]Volume, error) {
+	factory, err := safejs.Global().Get("indexedDB")
+	if err != nil {
+		return nil, err
+	}
+	if factory.IsUndefined() {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	listFn, err := factory.Get("databases")
+	if err != nil {
+		return nil, err
+	}
+	if listFn.IsUndefined() {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	promise, err := factory.Call("databases")
+	if err != nil {
+		return nil, err
+	}
+	infos, err := awaitPromise(ctx, promise)
+	if err != nil {
+		return nil, err
+	}
+	length, err := infos.Length()
+	if err != nil {
+		return nil, err
+	}
+	volumes := make([]Volume, 0, length)
+	for i := 0; i < length; i++ {
+		info, err := infos.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		jsName, err := info.Get("name")
+		if err != nil {
+			return nil, err
+		}
+		name, err := jsName.String()
+		if err != nil {
+			return nil, err
+		}
+		version, err := getInt64(info, "version")
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, Volume{Name: name, Version: uint(version)})
+	}
+	return volumes, nil
+}
+
+// isVolume returns true if the database 'name' has an FS's object stores. Opens the database without a version, so it isn't upgraded.
+func isVolume(ctx context.Context, name string) (bool, error) {
+	openRequest, err := idb.Global().Open(ctx, name, 0, func(*idb.Database, uint, uint) error {
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	db, err := openRequest.Await(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = db.Close() }()
+	names, err := db.ObjectStoreNames()
+	return containsString(names, contentsStore), err
+}
+
+// volumeExists returns true if ListVolumes() includes 'name'
+func volumeExists(ctx context.Context, name string) (bool, error) {
+	volumes, err := ListVolumes(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// openVolume opens the existing volume 'name', upgrading it like NewFS. The caller must close the returned FS.
+func openVolume(ctx context.Context, op, name string) (*FS, error) {
+	exists, err := volumeExists(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrNotExist}
+	}
+	return NewFS(ctx, name, Options{})
+}
+
+// VolumeSize returns the total size in bytes of the files in the volume 'name'. Fails with hackpadfs.ErrNotExist if no such volume exists.
+// Browsers don't report the storage used by each database, so this is the size of the files' contents, excluding IndexedDB's own overhead.
+func VolumeSize(ctx context.Context, name string) (int64, error) {
+	fs, err := openVolume(ctx, "volumesize", name)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = fs.Close() }()
+	_, _, bytes, err := fs.store.(*store).DiskUsage(ctx, rootPath)
+	if err != nil {
+		return 0, &hackpadfs.PathError{Op: "volumesize", Path: name, Err: err}
+	}
+	return bytes, nil
+}
+
+// DeleteVolume deletes the database 'name' and everything in it. Deleting a volume which doesn't exist is not an error.
+// The browser waits for other connections to the database to close first, like FSs in other tabs, so 'ctx' should have a deadline.
+func DeleteVolume(ctx context.Context, name string) error {
+	req, err := idb.Global().DeleteDatabase(name)
+	if err == nil {
+		err = req.Await(ctx)
+	}
+	if err != nil {
+		return &hackpadfs.PathError{Op: "deletevolume", Path: name, Err: err}
+	}
+	return nil
+}
+
+// RenameVolume moves every file in the volume 'oldname' to a new volume 'newname', then deletes 'oldname'.
+// IndexedDB can't rename databases, so the files are copied, which needs enough storage quota for both volumes at once.
+// Fails with hackpadfs.ErrExist if 'newname' is already a volume. If copying fails, the partial 'newname' volume is deleted and 'oldname' is left untouched.
+func RenameVolume(ctx context.Context, oldname, newname string) error {
+	const op = "renamevolume"
+	exists, err := volumeExists(ctx, newname)
+	if err != nil {
+		return &hackpadfs.LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+	if exists {
+		return &hackpadfs.LinkError{Op: op, Old: oldname, New: newname, Err: hackpadfs.ErrExist}
+	}
+	src, err := openVolume(ctx, op, oldname)
+	if err != nil {
+		return err
+	}
+	dest, err := NewFS(ctx, newname, Options{})
+	if err != nil {
+		_ = src.Close()
+		return &hackpadfs.LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+
+	err = copyVolume(ctx, src, dest)
+	_ = src.Close()
+	_ = dest.Close()
+	if err != nil {
+		_ = DeleteVolume(ctx, newname)
+		return &hackpadfs.LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+	return DeleteVolume(ctx, oldname)
+}
+
+// copyVolume streams an export of 'src' into 'dest'
+func copyVolume(ctx context.Context, src, dest *FS) error {
+	r, w := io.Pipe()
+	exportErr := make(chan error, 1)
+	go func() {
+		err := src.Export(ctx, w)
+		_ = w.CloseWithError(err)
+		exportErr <- err
+	}()
+	err := dest.ImportTar(ctx, r)
+	_ = r.CloseWithError(err) // unblock the export if the import stopped early
+	if srcErr := <-exportErr; srcErr != nil {
+		return srcErr
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func hasVolume(tb testing.TB, name string) bool {
+	tb.Helper()
+	exists, err := volumeExists(context.Background(), name)
+	assert.NoError(tb, err)
+	return exists
+}
+
+func TestVolumes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	if _, err := ListVolumes(ctx); errors.Is(err, hackpadfs.ErrNotImplemented) {
+		t.Skip("indexedDB.databases() is not available")
+	}
+	fs := makeFS(t)
+	assert.NoError(t, fs.Mkdir("dir", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/file", []byte("hello"), 0600))
+	assert.NoError(t, fs.Close())
+	assert.Equal(t, true, hasVolume(t, fs.name))
+
+	size, err := VolumeSize(ctx, fs.name)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("hello")), size)
+	_, err = VolumeSize(ctx, fs.name+"-missing")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	newName := fs.name + "-renamed"
+	t.Cleanup(func() {
+		assert.NoError(t, DeleteVolume(context.Background(), newName))
+	})
+	assert.NoError(t, RenameVolume(ctx, fs.name, newName))
+	assert.Equal(t, false, hasVolume(t, fs.name))
+	assert.Equal(t, true, hasVolume(t, newName))
+	renamed, err := NewFS(ctx, newName, Options{})
+	assert.NoError(t, err)
+	contents, err := hackpadfs.ReadFile(renamed, "dir/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(contents))
+	assert.NoError(t, renamed.Close())
+
+	assert.NoError(t, DeleteVolume(ctx, newName))
+	assert.Equal(t, false, hasVolume(t, newName))
+}
+
+func TestListVolumesSkipsOtherDatabases(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	if _, err := ListVolumes(ctx); errors.Is(err, hackpadfs.ErrNotImplemented) {
+		t.Skip("indexedDB.databases() is not available")
+	}
+	name := testDBPrefix + t.Name()
+	openRequest, err := idb.Global().Open(ctx, name, 1, func(db *idb.Database, _, _ uint) error {
+		_, err := db.CreateObjectStore("other", idb.ObjectStoreOptions{})
+		return err
+	})
+	assert.NoError(t, err)
+	db, err := openRequest.Await(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+	t.Cleanup(func() {
+		assert.NoError(t, DeleteVolume(context.Background(), name))
+	})
+
+	assert.Equal(t, false, hasVolume(t, name))
+}
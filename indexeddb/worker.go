@@ -0,0 +1,738 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/indexeddb/idbblob"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/hack-pad/safejs"
+)
+
+const (
+	workerOpOpen     = "open"
+	workerOpGet      = "get"
+	workerOpData     = "data"
+	workerOpDirNames = "dirnames"
+	workerOpSet      = "set"
+	workerOpClear    = "clear"
+	// FS operations changing several records run on the worker's FS, so they keep their transaction
+	workerOpMkdir         = "mkdir"
+	workerOpMkdirAll      = "mkdirall"
+	workerOpRemove        = "remove"
+	workerOpRemoveAll     = "removeall"
+	workerOpRename        = "rename"
+	workerOpApplyMetadata = "applymetadata"
+)
+
+// workerErrors are the errors which keep their identity for errors.Is() when passed between a worker and the main thread
+var workerErrors = []struct {
+	kind string
+	err  error
+}{
+	{"invalid", hackpadfs.ErrInvalid},
+	{"permission", hackpadfs.ErrPermission},
+	{"exist", hackpadfs.ErrExist},
+	{"notexist", hackpadfs.ErrNotExist},
+	{"closed", hackpadfs.ErrClosed},
+	{"isdir", hackpadfs.ErrIsDir},
+	{"notdir", hackpadfs.ErrNotDir},
+	{"notempty", hackpadfs.ErrNotEmpty},
+	{"notimplemented", hackpadfs.ErrNotImplemented},
+	{"nospace", hackpadfs.ErrNoSpace},
+}
+
+// workerError is an error received from a worker
+type workerError struct {
+	message string
+	err     error
+}
+
+func (w *workerError) Error() string {
+	return w.message
+}
+
+func (w *workerError) Unwrap() error {
+	return w.err
+}
+
+func encodeWorkerError(err error) (kind, message string) {
+	for _, workerErr := range workerErrors {
+		if errors.Is(err, workerErr.err) {
+			return workerErr.kind, err.Error()
+		}
+	}
+	return "", err.Error()
+}
+
+func decodeWorkerError(kind, message string) error {
+	for _, workerErr := range workerErrors {
+		if kind == workerErr.kind {
+			return &workerError{message: message, err: workerErr.err}
+		}
+	}
+	return &workerError{message: message}
+}
+
+// setWorkerError adds 'err' to 'response'. The Op and paths of a *hackpadfs.PathError or *hackpadfs.LinkError are kept, so the client can rebuild it.
+func setWorkerError(response map[string]interface{}, err error) {
+	var pathErr *hackpadfs.PathError
+	var linkErr *hackpadfs.LinkError
+	switch {
+	case errors.As(err, &pathErr):
+		response["errorOp"], response["errorPath"] = pathErr.Op, pathErr.Path
+		err = pathErr.Err
+	case errors.As(err, &linkErr):
+		response["errorOp"], response["errorOld"], response["errorNew"] = linkErr.Op, linkErr.Old, linkErr.New
+		err = linkErr.Err
+	}
+	response["errorKind"], response["error"] = encodeWorkerError(err)
+}
+
+// getWorkerError returns the error in 'response' set by setWorkerError(), or nil if there isn't one
+func getWorkerError(response safejs.Value) error {
+	jsErr, err := response.Get("error")
+	if err != nil {
+		return err
+	}
+	if jsErr.IsUndefined() {
+		return nil
+	}
+	message, err := jsErr.String()
+	if err != nil {
+		return err
+	}
+	kind, err := getString(response, "errorKind")
+	if err != nil {
+		return err
+	}
+	workerErr := decodeWorkerError(kind, message)
+	jsOp, err := response.Get("errorOp")
+	if err != nil || jsOp.IsUndefined() {
+		return workerErr
+	}
+	op, err := jsOp.String()
+	if err != nil {
+		return err
+	}
+	if jsPath, err := response.Get("errorPath"); err == nil && !jsPath.IsUndefined() {
+		path, err := jsPath.String()
+		if err != nil {
+			return err
+		}
+		return &hackpadfs.PathError{Op: op, Path: path, Err: workerErr}
+	}
+	oldPath, err := getString(response, "errorOld")
+	if err != nil {
+		return err
+	}
+	newPath, err := getString(response, "errorNew")
+	if err != nil {
+		return err
+	}
+	return &hackpadfs.LinkError{Op: op, Old: oldPath, New: newPath, Err: workerErr}
+}
+
+// startPort starts receiving messages if 'port' is a MessagePort. Workers and worker scopes start automatically.
+func startPort(port safejs.Value) error {
+	start, err := port.Get("start")
+	if err != nil || start.Type() != safejs.TypeFunction {
+		return err
+	}
+	_, err = port.Call("start")
+	return err
+}
+
+// ServeWorker serves FS requests from a main-thread NewFS() with Options.Worker set. Run it in the Go program loaded by that Web Worker.
+// Databases are opened inside the worker with 'options', so IndexedDB transactions and blob copies happen off the main thread.
+// Blocks until 'ctx' is canceled.
+func ServeWorker(ctx context.Context, options Options) error {
+	return serveWorker(ctx, safejs.Global(), options)
+}
+
+func serveWorker(ctx context.Context, port safejs.Value, options Options) error {
+	options.Worker = nil
+	server := &workerServer{
+		port:    port,
+		options: options,
+		fss:     make(map[string]*FS),
+	}
+	onMessage, err := safejs.FuncOf(func(_ safejs.Value, args []safejs.Value) interface{} {
+		go server.handle(ctx, args[0])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	defer onMessage.Release()
+	if _, err := port.Call("addEventListener", "message", onMessage); err != nil {
+		return err
+	}
+	if err := startPort(port); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	_, _ = port.Call("removeEventListener", "message", onMessage)
+	return ctx.Err()
+}
+
+type workerServer struct {
+	port    safejs.Value
+	options Options
+
+	mu  sync.Mutex
+	fss map[string]*FS
+}
+
+func (s *workerServer) open(ctx context.Context, name string) (*FS, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fs, ok := s.fss[name]; ok {
+		return fs, nil
+	}
+	fs, err := NewFS(ctx, name, s.options)
+	if err != nil {
+		return nil, err
+	}
+	s.fss[name] = fs
+	return fs, nil
+}
+
+func (s *workerServer) handle(ctx context.Context, event safejs.Value) {
+	request, err := event.Get("data")
+	if err != nil {
+		return
+	}
+	id, err := request.Get("hackpadfsID")
+	if err != nil || id.IsUndefined() {
+		return // not a request for us
+	}
+	response := map[string]interface{}{"hackpadfsID": safejs.Unsafe(id)}
+	var transfer []interface{}
+	err = s.serve(ctx, request, response, &transfer)
+	if err != nil {
+		setWorkerError(response, err)
+	}
+	jsResponse, err := safejs.ValueOf(response)
+	if err != nil {
+		return
+	}
+	_, _ = s.port.Call("postMessage", jsResponse, transfer)
+}
+
+func (s *workerServer) serve(ctx context.Context, request safejs.Value, response map[string]interface{}, transfer *[]interface{}) error {
+	op, err := getString(request, "op")
+	if err != nil {
+		return err
+	}
+	dbName, err := getString(request, "db")
+	if err != nil {
+		return err
+	}
+	fs, err := s.open(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	if op == workerOpOpen {
+		return nil
+	}
+	if op == workerOpClear {
+		return fs.Clear(ctx)
+	}
+
+	path, err := getString(request, "path")
+	if err != nil {
+		return err
+	}
+	switch op {
+	case workerOpGet:
+		record, err := fs.store.Get(ctx, path)
+		if err != nil {
+			return err
+		}
+		response["Mode"] = uint32(record.Mode())
+		response["ModTime"] = strconv.FormatInt(record.ModTime().UnixNano(), 10)
+		response["Size"] = record.Size()
+		return nil
+	case workerOpData:
+		record, err := fs.store.Get(ctx, path)
+		if err != nil {
+			return err
+		}
+		data, err := record.Data()
+		if err != nil {
+			return err
+		}
+		jsData := idbblob.FromBlob(data).JSValue()
+		response["data"] = jsData
+		*transfer = append(*transfer, jsData.Get("buffer"))
+		return nil
+	case workerOpDirNames:
+		record, err := fs.store.Get(ctx, path)
+		if err != nil {
+			return err
+		}
+		names, err := record.ReadDirNames()
+		if err != nil {
+			return err
+		}
+		jsNames := make([]interface{}, 0, len(names))
+		for _, name := range names {
+			jsNames = append(jsNames, name)
+		}
+		response["names"] = jsNames
+		return nil
+	case workerOpSet:
+		record, err := parseWorkerRecord(request)
+		if err != nil {
+			return err
+		}
+		return fs.store.Set(ctx, path, record)
+	case workerOpMkdir, workerOpMkdirAll:
+		perm, err := getMode(request)
+		if err != nil {
+			return err
+		}
+		if op == workerOpMkdir {
+			return fs.Mkdir(path, perm)
+		}
+		return fs.MkdirAll(path, perm)
+	case workerOpRemove:
+		return fs.Remove(path)
+	case workerOpRemoveAll:
+		return fs.RemoveAll(path)
+	case workerOpRename:
+		newPath, err := getString(request, "newPath")
+		if err != nil {
+			return err
+		}
+		return fs.Rename(path, newPath)
+	case workerOpApplyMetadata:
+		metadata, err := parseWorkerMetadata(request)
+		if err != nil {
+			return err
+		}
+		return fs.ApplyMetadata(metadata)
+	default:
+		return hackpadfs.ErrNotImplemented
+	}
+}
+
+// parseWorkerRecord parses a file record sent with workerStore.Set(). Returns a nil record for deletes.
+func parseWorkerRecord(request safejs.Value) (keyvalue.FileRecord, error) {
+	isDelete, err := request.Get("delete")
+	if err != nil {
+		return nil, err
+	}
+	if deleting, _ := isDelete.Truthy(); deleting {
+		return nil, nil
+	}
+	mode, modTime, size, err := parseWorkerFileInfo(request)
+	if err != nil {
+		return nil, err
+	}
+	var getData func() (blob.Blob, error)
+	if mode.IsRegular() {
+		jsData, err := request.Get("data")
+		if err != nil {
+			return nil, err
+		}
+		data, err := idbblob.New(safejs.Unsafe(jsData))
+		if err != nil {
+			return nil, err
+		}
+		size = int64(data.Len())
+		getData = func() (blob.Blob, error) {
+			return data, nil
+		}
+	}
+	return keyvalue.NewBaseFileRecord(size, modTime, mode, nil, getData, nil), nil
+}
+
+func parseWorkerFileInfo(value safejs.Value) (hackpadfs.FileMode, time.Time, int64, error) {
+	mode, err := getMode(value)
+	if err != nil {
+		return 0, time.Time{}, 0, err
+	}
+	modTimeStr, err := getString(value, "ModTime")
+	if err != nil {
+		return 0, time.Time{}, 0, err
+	}
+	modTime, err := strconv.ParseInt(modTimeStr, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, 0, err
+	}
+	jsSize, err := value.Get("Size")
+	if err != nil {
+		return 0, time.Time{}, 0, err
+	}
+	size, err := jsSize.Int()
+	return mode, time.Unix(0, modTime), int64(size), err
+}
+
+// parseWorkerMetadata parses the metadata sent with workerStore.applyMetadata()
+func parseWorkerMetadata(request safejs.Value) (map[string]hackpadfs.Metadata, error) {
+	jsFiles, err := request.Get("metadata")
+	if err != nil {
+		return nil, err
+	}
+	length, err := jsFiles.Length()
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(map[string]hackpadfs.Metadata, length)
+	for i := 0; i < length; i++ {
+		jsFile, err := jsFiles.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		path, err := getString(jsFile, "path")
+		if err != nil {
+			return nil, err
+		}
+		var m hackpadfs.Metadata
+		if m.Mode, err = getMode(jsFile); err != nil {
+			return nil, err
+		}
+		if m.SetMode, err = getBool(jsFile, "SetMode"); err != nil {
+			return nil, err
+		}
+		if m.SetOwner, err = getBool(jsFile, "SetOwner"); err != nil {
+			return nil, err
+		}
+		if m.Atime, err = getTime(jsFile, "Atime"); err != nil {
+			return nil, err
+		}
+		if m.Mtime, err = getTime(jsFile, "Mtime"); err != nil {
+			return nil, err
+		}
+		metadata[path] = m
+	}
+	return metadata, nil
+}
+
+// encodeWorkerTime encodes 't' for getTime(). Times are sent as strings of Unix nanoseconds, since JS numbers can't hold them exactly.
+func encodeWorkerTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func getTime(value safejs.Value, key string) (time.Time, error) {
+	str, err := getString(value, key)
+	if err != nil || str == "" {
+		return time.Time{}, err
+	}
+	nanos, err := strconv.ParseInt(str, 10, 64)
+	return time.Unix(0, nanos), err
+}
+
+func getBool(value safejs.Value, key string) (bool, error) {
+	jsValue, err := value.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return jsValue.Truthy()
+}
+
+func getString(value safejs.Value, key string) (string, error) {
+	jsValue, err := value.Get(key)
+	if err != nil {
+		return "", err
+	}
+	return jsValue.String()
+}
+
+// workerClient sends requests to a worker running ServeWorker() and awaits their responses
+type workerClient struct {
+	port      safejs.Value
+	nextID    uint64
+	onMessage safejs.Func
+
+	mu      sync.Mutex
+	pending map[uint64]chan safejs.Value
+}
+
+func newWorkerClient(port safejs.Value) (*workerClient, error) {
+	client := &workerClient{
+		port:    port,
+		pending: make(map[uint64]chan safejs.Value),
+	}
+	var err error
+	client.onMessage, err = safejs.FuncOf(func(_ safejs.Value, args []safejs.Value) interface{} {
+		client.receive(args[0])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := port.Call("addEventListener", "message", client.onMessage); err != nil {
+		client.onMessage.Release()
+		return nil, err
+	}
+	if err := startPort(port); err != nil {
+		client.onMessage.Release()
+		return nil, err
+	}
+	return client, nil
+}
+
+// close stops receiving responses. The port is left open, since the caller owns it.
+func (c *workerClient) close() {
+	_, _ = c.port.Call("removeEventListener", "message", c.onMessage)
+	c.onMessage.Release()
+}
+
+func (c *workerClient) receive(event safejs.Value) {
+	response, err := event.Get("data")
+	if err != nil {
+		return
+	}
+	jsID, err := response.Get("hackpadfsID")
+	if err != nil || jsID.IsUndefined() {
+		return
+	}
+	id, err := jsID.Int()
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	responses, ok := c.pending[uint64(id)]
+	delete(c.pending, uint64(id))
+	c.mu.Unlock()
+	if ok {
+		responses <- response
+	}
+}
+
+// call posts 'request' to the worker, transferring ownership of any 'transfer' objects, and waits for its response
+func (c *workerClient) call(ctx context.Context, request map[string]interface{}, transfer []interface{}) (safejs.Value, error) {
+	id := atomic.AddUint64(&c.nextID, 1)
+	responses := make(chan safejs.Value, 1)
+	c.mu.Lock()
+	c.pending[id] = responses
+	c.mu.Unlock()
+	cancel := func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}
+
+	request["hackpadfsID"] = id
+	jsRequest, err := safejs.ValueOf(request)
+	if err != nil {
+		cancel()
+		return safejs.Value{}, err
+	}
+	if _, err := c.port.Call("postMessage", jsRequest, transfer); err != nil {
+		cancel()
+		return safejs.Value{}, err
+	}
+
+	select {
+	case response := <-responses:
+		if err := getWorkerError(response); err != nil {
+			return safejs.Value{}, err
+		}
+		return response, nil
+	case <-ctx.Done():
+		cancel()
+		return safejs.Value{}, ctx.Err()
+	}
+}
+
+// Worker is a Web Worker or MessagePort running ServeWorker(), for Options.Worker
+type Worker struct {
+	port safejs.Value
+}
+
+// NewWorker returns a Worker sending requests to 'port', a Web Worker or a MessagePort whose other end is passed to a worker running ServeWorker()
+func NewWorker(port js.Value) *Worker {
+	return &Worker{port: safejs.Safe(port)}
+}
+
+// workerStore is a keyvalue.Store which proxies all operations to a worker running ServeWorker().
+// Multi-record FS operations are proxied whole with its methods like rename(), so they run in one transaction in the worker.
+type workerStore struct {
+	client        *workerClient
+	db            string
+	normalizeName func(name string) string // normalizeName is Options.NormalizeName, applied to paths in the FS operations which skip the keyvalue.FS
+}
+
+var _ keyvalue.Store = &workerStore{}
+
+func (s *workerStore) request(op, path string) map[string]interface{} {
+	return map[string]interface{}{
+		"op":   op,
+		"db":   s.db,
+		"path": path,
+	}
+}
+
+func (s *workerStore) open(ctx context.Context) error {
+	_, err := s.client.call(ctx, s.request(workerOpOpen, ""), nil)
+	return err
+}
+
+func (s *workerStore) clear(ctx context.Context) error {
+	_, err := s.client.call(ctx, s.request(workerOpClear, ""), nil)
+	return err
+}
+
+func (s *workerStore) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
+	response, err := s.client.call(ctx, s.request(workerOpGet, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	mode, modTime, size, err := parseWorkerFileInfo(response)
+	if err != nil {
+		return nil, err
+	}
+	var getData func() (blob.Blob, error)
+	var getDirNames func() ([]string, error)
+	if mode.IsDir() {
+		getDirNames = s.getDirNames(path)
+	} else {
+		getData = s.getData(path)
+	}
+	return keyvalue.NewBaseFileRecord(size, modTime, mode, nil, getData, getDirNames), nil
+}
+
+func (s *workerStore) getData(path string) func() (blob.Blob, error) {
+	return func() (blob.Blob, error) {
+		response, err := s.client.call(context.Background(), s.request(workerOpData, path), nil)
+		if err != nil {
+			return nil, err
+		}
+		jsData, err := response.Get("data")
+		if err != nil {
+			return nil, err
+		}
+		return idbblob.New(safejs.Unsafe(jsData))
+	}
+}
+
+func (s *workerStore) getDirNames(path string) func() ([]string, error) {
+	return func() ([]string, error) {
+		response, err := s.client.call(context.Background(), s.request(workerOpDirNames, path), nil)
+		if err != nil {
+			return nil, err
+		}
+		jsNames, err := response.Get("names")
+		if err != nil {
+			return nil, err
+		}
+		length, err := jsNames.Length()
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, length)
+		for i := 0; i < length; i++ {
+			jsName, err := jsNames.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			name, err := jsName.String()
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+		}
+		return names, nil
+	}
+}
+
+// fsPath returns 'name' as the keyvalue.FS would pass it to the store
+func (s *workerStore) fsPath(name string) string {
+	if s.normalizeName != nil {
+		return s.normalizeName(name)
+	}
+	return name
+}
+
+func (s *workerStore) mkdir(name string, perm hackpadfs.FileMode, all bool) error {
+	op := workerOpMkdir
+	if all {
+		op = workerOpMkdirAll
+	}
+	request := s.request(op, s.fsPath(name))
+	request["Mode"] = uint32(perm)
+	_, err := s.client.call(context.Background(), request, nil)
+	return err
+}
+
+func (s *workerStore) remove(name string, all bool) error {
+	op := workerOpRemove
+	if all {
+		op = workerOpRemoveAll
+	}
+	_, err := s.client.call(context.Background(), s.request(op, s.fsPath(name)), nil)
+	return err
+}
+
+func (s *workerStore) rename(oldname, newname string) error {
+	request := s.request(workerOpRename, s.fsPath(oldname))
+	request["newPath"] = s.fsPath(newname)
+	_, err := s.client.call(context.Background(), request, nil)
+	return err
+}
+
+func (s *workerStore) applyMetadata(metadata map[string]hackpadfs.Metadata) error {
+	jsFiles := make([]interface{}, 0, len(metadata))
+	for name, m := range metadata {
+		jsFiles = append(jsFiles, map[string]interface{}{
+			"path":     s.fsPath(name),
+			"Mode":     uint32(m.Mode),
+			"SetMode":  m.SetMode,
+			"SetOwner": m.SetOwner,
+			"Atime":    encodeWorkerTime(m.Atime),
+			"Mtime":    encodeWorkerTime(m.Mtime),
+		})
+	}
+	request := s.request(workerOpApplyMetadata, "")
+	request["metadata"] = jsFiles
+	_, err := s.client.call(context.Background(), request, nil)
+	return err
+}
+
+func (s *workerStore) Set(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	request := s.request(workerOpSet, path)
+	var transfer []interface{}
+	if src == nil {
+		request["delete"] = true
+	} else {
+		request["Mode"] = uint32(src.Mode())
+		request["ModTime"] = strconv.FormatInt(src.ModTime().UnixNano(), 10)
+		request["Size"] = src.Size()
+		if src.Mode().IsRegular() {
+			data, err := src.Data()
+			if err != nil {
+				return err
+			}
+			// copy into a new buffer, so it can be transferred without detaching one still in use
+			buf, err := idbblob.NewLength(data.Len())
+			if err != nil {
+				return err
+			}
+			if _, err := buf.Set(data, 0); err != nil {
+				return err
+			}
+			jsData := buf.JSValue()
+			request["data"] = jsData
+			transfer = append(transfer, jsData.Get("buffer"))
+		}
+	}
+	_, err := s.client.call(ctx, request, transfer)
+	return err
+}
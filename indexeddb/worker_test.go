@@ -0,0 +1,107 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/safejs"
+)
+
+// makeWorkerFS returns an FS proxying to a ServeWorker() running on the other end of a MessageChannel
+func makeWorkerFS(tb testing.TB) *FS {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000))
+	assert.NoError(tb, err)
+	name := fmt.Sprintf("%s%s/%d", testDBPrefix, tb.Name(), n.Int64())
+
+	jsMessageChannel, err := safejs.Global().Get("MessageChannel")
+	assert.NoError(tb, err)
+	channel, err := jsMessageChannel.New()
+	assert.NoError(tb, err)
+	serverPort, err := channel.Get("port1")
+	assert.NoError(tb, err)
+	clientPort, err := channel.Get("port2")
+	assert.NoError(tb, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serveWorker(ctx, serverPort, Options{})
+	}()
+
+	fs, err := NewFS(context.Background(), name, Options{Worker: NewWorker(safejs.Unsafe(clientPort))})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		cancel()
+		assert.ErrorIs(tb, context.Canceled, <-served)
+		req, err := idb.Global().DeleteDatabase(name)
+		assert.NoError(tb, err)
+		assert.NoError(tb, req.Await(context.Background()))
+	})
+	return fs
+}
+
+func TestWorkerFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "indexeddb worker",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			return makeWorkerFS(tb)
+		},
+		LogFSOnFailure: true,
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestWorkerError(t *testing.T) {
+	t.Parallel()
+	fs := makeWorkerFS(t)
+	_, err := hackpadfs.Stat(fs, "foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestWorkerFSOperations(t *testing.T) {
+	t.Parallel()
+	fs := makeWorkerFS(t)
+	assert.NoError(t, fs.MkdirAll("dir/sub", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/sub/file", []byte("file"), 0600))
+	assert.NoError(t, fs.Rename("dir", "renamed"))
+	contents, err := hackpadfs.ReadFile(fs, "renamed/sub/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "file", string(contents))
+
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, fs.ApplyMetadata(map[string]hackpadfs.Metadata{
+		"renamed/sub/file": {Mode: 0640, SetMode: true, Mtime: modTime},
+	}))
+	info, err := fs.Stat("renamed/sub/file")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0640), info.Mode())
+	assert.Equal(t, modTime, info.ModTime().UTC())
+
+	assert.NoError(t, fs.RemoveAll("renamed"))
+	_, err = fs.Stat("renamed")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	err = fs.Mkdir("missing/dir", 0700)
+	var pathErr *hackpadfs.PathError
+	if assert.Equal(t, true, errors.As(err, &pathErr)) {
+		assert.Equal(t, "mkdir", pathErr.Op)
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, pathErr.Err)
+	}
+}
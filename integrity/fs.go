@@ -0,0 +1,284 @@
+// Package integrity contains a file system wrapper which records and verifies file checksums.
+package integrity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+	} = &FS{}
+)
+
+// ErrCorrupted is matched by errors.Is() when a file's contents do not match its recorded checksum.
+var ErrCorrupted = errors.New("checksum mismatch")
+
+// CorruptionError records a file whose contents do not match its recorded checksum.
+type CorruptionError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *CorruptionError) Error() string {
+	return "integrity: " + e.Path + ": " + ErrCorrupted.Error() + ": expected " + e.Expected + ", got " + e.Actual
+}
+
+// Is supports errors.Is(err, ErrCorrupted).
+func (e *CorruptionError) Is(target error) bool {
+	return target == ErrCorrupted
+}
+
+type writableFS interface {
+	hackpadfs.OpenFileFS
+	hackpadfs.MkdirFS
+}
+
+// FS wraps a source FS, recording a checksum for every file written and verifying it when the file is opened for reading.
+//
+// Checksums are stored in a separate sidecar FS, at the same path as the file they describe.
+type FS struct {
+	sourceFS writableFS
+	sumsFS   writableFS
+	options  Options
+
+	writersMu sync.Mutex
+	writers   map[string]int // number of open writable handles per path. Verification is skipped while a file is being written.
+}
+
+// Options contain options for creating an FS
+type Options struct {
+	// Hash creates the hash used to checksum file contents. Defaults to SHA-256.
+	Hash func() hash.Hash
+}
+
+// NewFS returns a new FS wrapping 'source' and storing checksums in 'sums'.
+func NewFS(source, sums writableFS, options Options) (*FS, error) {
+	if options.Hash == nil {
+		options.Hash = sha256.New
+	}
+	return &FS{
+		sourceFS: source,
+		sumsFS:   sums,
+		options:  options,
+		writers:  make(map[string]int),
+	}, nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if !hackpadfs.ParseFlags(flag).Modifies() {
+		if err := fs.verify(name); err != nil {
+			return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return fs.sourceFS.OpenFile(name, flag, perm)
+	}
+
+	fs.addWriter(name)
+	f, err := fs.sourceFS.OpenFile(name, flag, perm)
+	if err != nil {
+		fs.removeWriter(name)
+		return nil, err
+	}
+	return &file{File: f, fs: fs, name: name}, nil
+}
+
+func (fs *FS) addWriter(name string) {
+	fs.writersMu.Lock()
+	fs.writers[name]++
+	fs.writersMu.Unlock()
+}
+
+// removeWriter decrements the writer count for 'name' and returns true if this was the last writer
+func (fs *FS) removeWriter(name string) bool {
+	fs.writersMu.Lock()
+	defer fs.writersMu.Unlock()
+	fs.writers[name]--
+	if fs.writers[name] > 0 {
+		return false
+	}
+	delete(fs.writers, name)
+	return true
+}
+
+func (fs *FS) isWriting(name string) bool {
+	fs.writersMu.Lock()
+	defer fs.writersMu.Unlock()
+	return fs.writers[name] > 0
+}
+
+// sum calculates the hex-encoded checksum of the file 'name' in the source FS
+func (fs *FS) sum(name string) (string, error) {
+	f, err := fs.sourceFS.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := fs.options.Hash()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// storedSum returns the recorded checksum for 'name'
+func (fs *FS) storedSum(name string) (string, error) {
+	sum, err := hackpadfs.ReadFile(fs.sumsFS, name)
+	return string(sum), err
+}
+
+// record calculates and stores the checksum for 'name'
+func (fs *FS) record(name string) error {
+	sum, err := fs.sum(name)
+	if err != nil {
+		return err
+	}
+	if dir := path.Dir(name); dir != "." {
+		if err := hackpadfs.MkdirAll(fs.sumsFS, dir, 0700); err != nil {
+			return err
+		}
+	}
+	return hackpadfs.WriteFullFile(fs.sumsFS, name, []byte(sum), 0600)
+}
+
+// verify checks the file 'name' against its recorded checksum. Directories, files without a checksum, and files currently open for writing are not verified.
+func (fs *FS) verify(name string) error {
+	if fs.isWriting(name) {
+		return nil
+	}
+	info, err := hackpadfs.Stat(fs.sourceFS, name)
+	if err != nil || info.IsDir() {
+		return nil // let the source FS report any errors
+	}
+	expected, err := fs.storedSum(name)
+	if err != nil {
+		if errors.Is(err, hackpadfs.ErrNotExist) || errors.Is(err, hackpadfs.ErrIsDir) {
+			return nil
+		}
+		return err
+	}
+	actual, err := fs.sum(name)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return &CorruptionError{Path: name, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// Verify scans every file in the FS and returns all files which fail checksum verification.
+// Returns an error if the scan could not complete or 'ctx' is canceled.
+func (fs *FS) Verify(ctx context.Context) ([]*CorruptionError, error) {
+	var corrupted []*CorruptionError
+	err := hackpadfs.WalkDir(fs.sourceFS, ".", func(name string, dirEntry hackpadfs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if dirEntry.IsDir() {
+			return nil
+		}
+		err = fs.verify(name)
+		var corruptErr *CorruptionError
+		if errors.As(err, &corruptErr) {
+			corrupted = append(corrupted, corruptErr)
+			return nil
+		}
+		return err
+	})
+	return corrupted, err
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return fs.sourceFS.Mkdir(name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return hackpadfs.MkdirAll(fs.sourceFS, path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	err := hackpadfs.Remove(fs.sourceFS, name)
+	if err != nil {
+		return err
+	}
+	return ignoreNotExist(hackpadfs.RemoveAll(fs.sumsFS, name))
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	err := hackpadfs.Rename(fs.sourceFS, oldname, newname)
+	if err != nil || oldname == newname {
+		return err
+	}
+	if err := ignoreNotExist(hackpadfs.RemoveAll(fs.sumsFS, newname)); err != nil {
+		return err
+	}
+	if _, err := hackpadfs.Stat(fs.sumsFS, oldname); errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil
+	}
+	if dir := path.Dir(newname); dir != "." {
+		if err := hackpadfs.MkdirAll(fs.sumsFS, dir, 0700); err != nil {
+			return err
+		}
+	}
+	return hackpadfs.Rename(fs.sumsFS, oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Stat(fs.sourceFS, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return hackpadfs.Chmod(fs.sourceFS, name, mode)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return hackpadfs.Chtimes(fs.sourceFS, name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDir(fs.sourceFS, name)
+}
+
+func ignoreNotExist(err error) error {
+	if errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package integrity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newFS(tb testing.TB) (*FS, *mem.FS) {
+	tb.Helper()
+	source, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	sums, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	fs, err := NewFS(source, sums, Options{})
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs, source
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "integrity",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, _ := newFS(tb)
+			return fs
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+	fs, source := newFS(t)
+	assert.NoError(t, hackpadfs.MkdirAll(fs, "foo", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("bar"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "baz", []byte("baz"), 0600))
+
+	corrupted, err := fs.Verify(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(corrupted))
+
+	// corrupt the file behind the wrapper's back
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "foo/bar", []byte("not bar"), 0600))
+
+	_, err = fs.Open("foo/bar")
+	assert.ErrorIs(t, ErrCorrupted, err)
+	var corruptErr *CorruptionError
+	if assert.ErrorAs(t, &corruptErr, err) {
+		assert.Equal(t, "foo/bar", corruptErr.Path)
+	}
+
+	corrupted, err = fs.Verify(context.Background())
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(corrupted)) {
+		assert.Equal(t, "foo/bar", corrupted[0].Path)
+	}
+
+	// rewriting through the wrapper records a new checksum
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("new bar"), 0600))
+	contents, err := hackpadfs.ReadFile(fs, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "new bar", string(contents))
+}
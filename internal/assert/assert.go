@@ -4,6 +4,7 @@ package assert
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -60,12 +61,23 @@ func Equal(tb testing.TB, expected, actual interface{}, args ...interface{}) boo
 		if len(args) > 0 {
 			tb.Error(args...)
 		}
-		tb.Errorf("%+v != %+v\nExpected: %#v\nActual:   %#v", expected, actual, expected, actual)
+		tb.Error(notEqualMessage(expected, actual))
 		return false
 	}
 	return true
 }
 
+// notEqualMessage describes the differences between 'expected' and 'actual'. Values spanning multiple lines are described with a diff.
+func notEqualMessage(expected, actual interface{}) string {
+	expectedStr, actualStr := format(expected), format(actual)
+	if strings.ContainsRune(expectedStr, '\n') || strings.ContainsRune(actualStr, '\n') {
+		if d := diff(expectedStr, actualStr); d != "" && expectedStr != actualStr {
+			return "Values are not equal. Diff (-expected +actual):\n" + d
+		}
+	}
+	return fmt.Sprintf("%+v != %+v\nExpected: %#v\nActual:   %#v", expected, actual, expected, actual)
+}
+
 // NotEqual asserts actual is not equal to expected
 func NotEqual(tb testing.TB, expected, actual interface{}, args ...interface{}) bool {
 	tb.Helper()
@@ -181,7 +193,93 @@ func IsType(tb testing.TB, expected, actual interface{}) bool {
 	tb.Helper()
 	expectedType := reflect.TypeOf(expected)
 	actualType := reflect.TypeOf(actual)
-	return Equal(tb, expectedType, actualType)
+	if expectedType != actualType {
+		tb.Errorf("Types are not equal:\nExpected: %v\nActual:   %v (%+v)", expectedType, actualType, actual)
+		return false
+	}
+	return true
+}
+
+// Len asserts 'collection' has 'length' elements. Collections can be slices, arrays, maps, strings, or channels.
+func Len(tb testing.TB, collection interface{}, length int) bool {
+	tb.Helper()
+	collectionVal := reflect.ValueOf(collection)
+	switch collectionVal.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String, reflect.Chan:
+	default:
+		tb.Errorf("Invalid collection type. Expected slice, array, map, string, or channel, got: %T", collection)
+		return false
+	}
+	if actualLength := collectionVal.Len(); actualLength != length {
+		tb.Errorf("Length should be %d, got %d:\n%s", length, actualLength, format(collection))
+		return false
+	}
+	return true
+}
+
+// Greater asserts 'a' is greater than 'b'. Both must be the same type of number, string, or time.Time.
+func Greater(tb testing.TB, a, b interface{}) bool {
+	tb.Helper()
+	comparison, ok := compare(tb, a, b)
+	if ok && comparison <= 0 {
+		tb.Errorf("%+v should be greater than %+v", a, b)
+		return false
+	}
+	return ok
+}
+
+// Less asserts 'a' is less than 'b'. Both must be the same type of number, string, or time.Time.
+func Less(tb testing.TB, a, b interface{}) bool {
+	tb.Helper()
+	comparison, ok := compare(tb, a, b)
+	if ok && comparison >= 0 {
+		tb.Errorf("%+v should be less than %+v", a, b)
+		return false
+	}
+	return ok
+}
+
+// compare returns -1, 0, or 1 if 'a' is less than, equal to, or greater than 'b'. Returns false if they can't be compared.
+func compare(tb testing.TB, a, b interface{}) (int, bool) {
+	tb.Helper()
+	aVal, bVal := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !aVal.IsValid() || !bVal.IsValid() || aVal.Type() != bVal.Type() {
+		tb.Errorf("Can not compare different types: %T and %T", a, b)
+		return 0, false
+	}
+	if aTime, ok := a.(time.Time); ok {
+		bTime := b.(time.Time)
+		switch {
+		case aTime.Before(bTime):
+			return -1, true
+		case aTime.After(bTime):
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	var less, greater bool
+	switch aVal.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less, greater = aVal.Int() < bVal.Int(), aVal.Int() > bVal.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		less, greater = aVal.Uint() < bVal.Uint(), aVal.Uint() > bVal.Uint()
+	case reflect.Float32, reflect.Float64:
+		less, greater = aVal.Float() < bVal.Float(), aVal.Float() > bVal.Float()
+	case reflect.String:
+		less, greater = aVal.String() < bVal.String(), aVal.String() > bVal.String()
+	default:
+		tb.Errorf("Invalid comparison type. Expected number, string, or time.Time, got: %T", a)
+		return 0, false
+	}
+	switch {
+	case less:
+		return -1, true
+	case greater:
+		return 1, true
+	default:
+		return 0, true
+	}
 }
 
 // Prefix asserts actual starts with expected
@@ -256,3 +354,18 @@ func ErrorIs(tb testing.TB, target, err error) bool {
 	tb.Errorf("Error must match target:\nExpected: %v\nActual:   %v", target, err)
 	return false
 }
+
+// ErrorAs asserts 'err' matches the type pointed to by 'target', then sets 'target' to the matching error. Uses errors.As().
+func ErrorAs(tb testing.TB, target interface{}, err error) bool {
+	tb.Helper()
+	targetType := reflect.TypeOf(target)
+	if targetType == nil || targetType.Kind() != reflect.Ptr {
+		tb.Errorf("Invalid target type. Expected a non-nil pointer, got: %T", target)
+		return false
+	}
+	if errors.As(err, target) {
+		return true
+	}
+	tb.Errorf("Error must match target type:\nExpected: %v\nActual:   %v (%T)", targetType.Elem(), err, err)
+	return false
+}
@@ -0,0 +1,143 @@
+package assert
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTB records errors instead of failing the test
+type fakeTB struct {
+	testing.TB
+	errors []string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Error(args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprint(args...))
+}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestEqualDiff(t *testing.T) {
+	t.Parallel()
+	type file struct {
+		Name string
+		Size int64
+	}
+	var expected, actual []file
+	for i := 0; i < 20; i++ {
+		expected = append(expected, file{Name: fmt.Sprint("file", i), Size: int64(i)})
+		actual = append(actual, file{Name: fmt.Sprint("file", i), Size: int64(i)})
+	}
+	actual[10].Size = 100
+
+	tb := &fakeTB{}
+	Equal(tb, expected, actual)
+	if len(tb.errors) != 1 {
+		t.Fatal("Expected 1 error, got:", tb.errors)
+	}
+	const expectedDiff = "Values are not equal. Diff (-expected +actual):\n" +
+		"  ...\n" +
+		"  \t},\n" +
+		"  \tassert.file{\n" +
+		"  \t\tName: \"file10\",\n" +
+		"- \t\tSize: 10,\n" +
+		"+ \t\tSize: 100,\n" +
+		"  \t},\n" +
+		"  \tassert.file{\n" +
+		"  \t\tName: \"file11\",\n" +
+		"  ..."
+	if tb.errors[0] != expectedDiff {
+		t.Errorf("Unexpected diff:\n%s\nExpected:\n%s", tb.errors[0], expectedDiff)
+	}
+}
+
+func TestEqualSingleLine(t *testing.T) {
+	t.Parallel()
+	tb := &fakeTB{}
+	Equal(tb, 1, 2)
+	if len(tb.errors) != 1 || tb.errors[0] != "1 != 2\nExpected: 1\nActual:   2" {
+		t.Errorf("Unexpected errors: %q", tb.errors)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+	modTime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, "nil"},
+		{[]byte("foo"), `[]uint8("foo")`},
+		{(*int)(nil), "(*int)(nil)"},
+		{[]string(nil), "[]string(nil)"},
+		{map[string]int{"b": 2, "a": 1}, "map[string]int{\n\t\"a\": 1,\n\t\"b\": 2,\n}"},
+		{modTime, "time.Time(2021-01-02 03:04:05 +0000 UTC)"},
+		{fs.ErrNotExist, `*errors.errorString("file does not exist")`},
+	} {
+		if actual := format(tc.value); actual != tc.expected {
+			t.Errorf("format(%#v) = %q, expected %q", tc.value, actual, tc.expected)
+		}
+	}
+}
+
+func TestIsType(t *testing.T) {
+	t.Parallel()
+	tb := &fakeTB{}
+	if IsType(tb, (*fs.PathError)(nil), nil) || len(tb.errors) != 1 || strings.Contains(tb.errors[0], "rtype") {
+		t.Errorf("Unexpected errors: %q", tb.errors)
+	}
+}
+
+func TestLen(t *testing.T) {
+	t.Parallel()
+	tb := &fakeTB{}
+	ok := Len(tb, []int{1, 2}, 2) &&
+		Len(tb, map[string]bool{"a": true}, 1) &&
+		Len(tb, "foo", 3)
+	if !ok || len(tb.errors) != 0 {
+		t.Errorf("Unexpected errors: %q", tb.errors)
+	}
+	if Len(tb, []int{1}, 2) || Len(tb, 1, 2) || len(tb.errors) != 2 {
+		t.Errorf("Expected 2 errors, got: %q", tb.errors)
+	}
+}
+
+func TestGreaterLess(t *testing.T) {
+	t.Parallel()
+	tb := &fakeTB{}
+	now := time.Now()
+	ok := Greater(tb, 2, 1) &&
+		Greater(tb, "b", "a") &&
+		Greater(tb, now, now.Add(-time.Second)) &&
+		Less(tb, uint(1), uint(2)) &&
+		Less(tb, 1.5, 2.5) &&
+		Less(tb, time.Second, time.Minute)
+	if !ok || len(tb.errors) != 0 {
+		t.Errorf("Unexpected errors: %q", tb.errors)
+	}
+	if Greater(tb, 1, 1) || Less(tb, 2, 1) || Less(tb, 1, int64(2)) || Greater(tb, []int{}, []int{}) || len(tb.errors) != 4 {
+		t.Errorf("Expected 4 errors, got: %q", tb.errors)
+	}
+}
+
+func TestErrorAs(t *testing.T) {
+	t.Parallel()
+	tb := &fakeTB{}
+	var pathErr *fs.PathError
+	err := fmt.Errorf("wrapped: %w", &fs.PathError{Op: "open", Path: "foo", Err: fs.ErrNotExist})
+	if !ErrorAs(tb, &pathErr, err) || pathErr.Path != "foo" || len(tb.errors) != 0 {
+		t.Errorf("Unexpected errors: %q", tb.errors)
+	}
+	if ErrorAs(tb, &pathErr, errors.New("other")) || ErrorAs(tb, nil, err) || len(tb.errors) != 2 {
+		t.Errorf("Expected 2 errors, got: %q", tb.errors)
+	}
+}
@@ -0,0 +1,214 @@
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	maxFormatDepth = 10   // maxFormatDepth is the deepest nested value formatted before eliding it
+	maxDiffLines   = 2000 // maxDiffLines is the most lines diffed, since diffs take time proportional to the product of both values' lines
+	diffContext    = 3    // diffContext is the number of unchanged lines printed around each change
+)
+
+// format returns a multi-line representation of 'value', with one struct field, slice element, or map entry per line
+func format(value interface{}) string {
+	var b strings.Builder
+	formatValue(&b, reflect.ValueOf(value), 0)
+	return b.String()
+}
+
+func formatValue(b *strings.Builder, value reflect.Value, depth int) {
+	if !value.IsValid() {
+		b.WriteString("nil")
+		return
+	}
+	if depth > maxFormatDepth {
+		b.WriteString("...")
+		return
+	}
+	if value.CanInterface() {
+		switch v := value.Interface().(type) {
+		case error:
+			if value.Kind() != reflect.Ptr || !value.IsNil() {
+				fmt.Fprintf(b, "%T(%q)", v, v.Error())
+				return
+			}
+		case fmt.Stringer:
+			if value.Kind() == reflect.Struct { // like time.Time, which are unreadable field by field
+				fmt.Fprintf(b, "%T(%s)", v, v.String())
+				return
+			}
+		}
+	}
+
+	indent := strings.Repeat("\t", depth+1)
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			fmt.Fprintf(b, "(%s)(nil)", value.Type())
+			return
+		}
+		b.WriteString("&")
+		formatValue(b, value.Elem(), depth)
+	case reflect.Interface:
+		if value.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		formatValue(b, value.Elem(), depth)
+	case reflect.Struct:
+		if value.NumField() == 0 {
+			fmt.Fprintf(b, "%s{}", value.Type())
+			return
+		}
+		fmt.Fprintf(b, "%s{\n", value.Type())
+		for i := 0; i < value.NumField(); i++ {
+			fmt.Fprintf(b, "%s%s: ", indent, value.Type().Field(i).Name)
+			formatValue(b, value.Field(i), depth+1)
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent[1:] + "}")
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			fmt.Fprintf(b, "%s(nil)", value.Type())
+			return
+		}
+		if value.Type().Elem().Kind() == reflect.Uint8 { // byte slices read better as strings
+			fmt.Fprintf(b, "%s(%q)", value.Type(), bytesOf(value))
+			return
+		}
+		if value.Len() == 0 {
+			fmt.Fprintf(b, "%s{}", value.Type())
+			return
+		}
+		fmt.Fprintf(b, "%s{\n", value.Type())
+		for i := 0; i < value.Len(); i++ {
+			b.WriteString(indent)
+			formatValue(b, value.Index(i), depth+1)
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent[1:] + "}")
+	case reflect.Map:
+		if value.IsNil() {
+			fmt.Fprintf(b, "%s(nil)", value.Type())
+			return
+		}
+		if value.Len() == 0 {
+			fmt.Fprintf(b, "%s{}", value.Type())
+			return
+		}
+		type entry struct {
+			key   string
+			value reflect.Value
+		}
+		entries := make([]entry, 0, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			var key strings.Builder
+			formatValue(&key, iter.Key(), depth+1)
+			entries = append(entries, entry{key: key.String(), value: iter.Value()})
+		}
+		sort.Slice(entries, func(a, b int) bool {
+			return entries[a].key < entries[b].key
+		})
+		fmt.Fprintf(b, "%s{\n", value.Type())
+		for _, e := range entries {
+			fmt.Fprintf(b, "%s%s: ", indent, e.key)
+			formatValue(b, e.value, depth+1)
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent[1:] + "}")
+	default:
+		if value.CanInterface() {
+			fmt.Fprintf(b, "%#v", value.Interface())
+		} else {
+			fmt.Fprintf(b, "%v", value) // unexported fields can't be converted to interfaces, but fmt can still print their basic values
+		}
+	}
+}
+
+func bytesOf(value reflect.Value) []byte {
+	buf := make([]byte, value.Len())
+	for i := range buf {
+		buf[i] = byte(value.Index(i).Uint())
+	}
+	return buf
+}
+
+// diff returns a line diff of 'expected' and 'actual', marking removed lines with "-" and added lines with "+".
+// Unchanged lines far from any change are elided. Returns "" if either has too many lines to diff.
+func diff(expected, actual string) string {
+	a, b := strings.Split(expected, "\n"), strings.Split(actual, "\n")
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		return ""
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		prefix string
+		text   string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{" ", a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{"-", a[i]})
+			i++
+		default:
+			lines = append(lines, line{"+", b[j]})
+			j++
+		}
+	}
+
+	// keep lines within diffContext lines of a change
+	keep := make([]bool, len(lines))
+	for ix, l := range lines {
+		if l.prefix == " " {
+			continue
+		}
+		for k := ix - diffContext; k <= ix+diffContext; k++ {
+			if k >= 0 && k < len(lines) {
+				keep[k] = true
+			}
+		}
+	}
+	var out strings.Builder
+	elided := false
+	for ix, l := range lines {
+		if !keep[ix] {
+			if !elided {
+				out.WriteString("  ...\n")
+				elided = true
+			}
+			continue
+		}
+		elided = false
+		out.WriteString(l.prefix + " " + l.text + "\n")
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
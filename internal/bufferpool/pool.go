@@ -0,0 +1,115 @@
+// Package bufferpool contains Pool, which caps the memory used by byte buffers shared between goroutines.
+package bufferpool
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Copies is shared by file copy loops, like hackpadfs.CopyFile(), to cap their memory use when many copies run concurrently
+var Copies = New(32<<10, 64)
+
+// Pool maintains a collection of byte buffers with a maximum size.
+// Used to control upper-bound memory usage. It's safe for concurrent use.
+type Pool struct {
+	count   int64
+	inUse   int64
+	waits   int64
+	size    uint64
+	buffers chan *Buffer
+}
+
+// Buffer is a byte buffer acquired from a Pool
+type Buffer struct {
+	Data []byte
+	pool *Pool
+}
+
+// Stats contains usage metrics for a Pool
+type Stats struct {
+	Buffers int64 // Buffers is the number of buffers allocated so far
+	InUse   int64 // InUse is the number of buffers acquired and not yet returned to the pool
+	Waits   int64 // Waits is the number of times Wait() blocked because every buffer was in use
+}
+
+// New returns a new Pool of up to 'maxBuffers' buffers, each 'bufferSize' bytes long. Buffers are allocated as needed.
+func New(bufferSize, maxBuffers uint64) *Pool {
+	if maxBuffers == 0 {
+		maxBuffers = 1
+	}
+	p := &Pool{
+		size:    bufferSize,
+		buffers: make(chan *Buffer, maxBuffers),
+	}
+	p.addBuffer() // start with 1 buffer, ready to go
+	return p
+}
+
+func (p *Pool) addBuffer() {
+	for {
+		count := atomic.LoadInt64(&p.count)
+		if int(count) == cap(p.buffers) {
+			return // already at max buffers, no-op
+		}
+		if atomic.CompareAndSwapInt64(&p.count, count, count+1) {
+			break // successfully provisioned slot for new buffer
+		}
+	}
+	buf := &Buffer{
+		Data: make([]byte, p.size),
+		pool: p,
+	}
+	p.buffers <- buf
+}
+
+// Capacity returns the total size of the buffers the pool may hold at once
+func (p *Pool) Capacity() int64 {
+	return int64(p.size) * int64(cap(p.buffers))
+}
+
+// Stats returns the pool's current usage metrics
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Buffers: atomic.LoadInt64(&p.count),
+		InUse:   atomic.LoadInt64(&p.inUse),
+		Waits:   atomic.LoadInt64(&p.waits),
+	}
+}
+
+// Wait acquires and returns a buffer. Be sure to call buffer.Done() to return it to the pool.
+func (p *Pool) Wait() *Buffer {
+	atomic.AddInt64(&p.inUse, 1)
+	select {
+	case buf := <-p.buffers:
+		return buf
+	default:
+		p.addBuffer()
+	}
+	// may not always get the new buffer, but looping could allocate more buffers far too quickly
+	select {
+	case buf := <-p.buffers:
+		return buf
+	default:
+		atomic.AddInt64(&p.waits, 1)
+		return <-p.buffers
+	}
+}
+
+// Done returns this buffer to the pool
+func (b *Buffer) Done() {
+	atomic.AddInt64(&b.pool.inUse, -1)
+	b.pool.buffers <- b
+}
+
+// Copy is like io.Copy(), but copies through one of the pool's buffers.
+// If 'src' is an io.WriterTo or 'dst' is an io.ReaderFrom, they copy without a buffer, so none is acquired.
+func (p *Pool) Copy(dst io.Writer, src io.Reader) (int64, error) {
+	_, isWriterTo := src.(io.WriterTo)
+	_, isReaderFrom := dst.(io.ReaderFrom)
+	if isWriterTo || isReaderFrom {
+		return io.Copy(dst, src)
+	}
+	buf := p.Wait()
+	defer buf.Done()
+	return io.CopyBuffer(dst, src, buf.Data)
+}
@@ -0,0 +1,54 @@
+package bufferpool
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestPoolStats(t *testing.T) {
+	t.Parallel()
+	p := New(10, 2)
+	assert.Equal(t, int64(20), p.Capacity())
+	assert.Equal(t, Stats{Buffers: 1}, p.Stats())
+
+	buf1 := p.Wait()
+	buf2 := p.Wait()
+	assert.Equal(t, 10, len(buf1.Data))
+	assert.Equal(t, Stats{Buffers: 2, InUse: 2}, p.Stats())
+
+	acquired := make(chan *Buffer)
+	go func() {
+		acquired <- p.Wait()
+	}()
+	for p.Stats().Waits == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	buf1.Done()
+	buf3 := <-acquired
+	buf2.Done()
+	buf3.Done()
+	assert.Equal(t, Stats{Buffers: 2, Waits: 1}, p.Stats())
+}
+
+func TestPoolCopy(t *testing.T) {
+	t.Parallel()
+	const contents = "hello world"
+	p := New(4, 1)
+	var dest bytes.Buffer
+	n, err := p.Copy(&dest, strings.NewReader(contents)) // bytes.Buffer is an io.ReaderFrom, so no buffer is needed
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), n)
+	assert.Equal(t, contents, dest.String())
+
+	dest.Reset()
+	n, err = p.Copy(struct{ io.Writer }{&dest}, struct{ io.Reader }{strings.NewReader(contents)}) // hide the optimized interfaces, so a buffer is needed
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), n)
+	assert.Equal(t, contents, dest.String())
+	assert.Equal(t, Stats{Buffers: 1}, p.Stats())
+}
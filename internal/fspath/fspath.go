@@ -0,0 +1,74 @@
+// Package fspath contains helpers for slash-separated paths in a hackpadfs.FS.
+package fspath
+
+import (
+	"path"
+	"strings"
+)
+
+// Rel returns 'name' relative to its ancestor directory 'root'
+func Rel(root, name string) string {
+	switch {
+	case name == root:
+		return "."
+	case root == ".":
+		return name
+	default:
+		return strings.TrimPrefix(name, root+"/")
+	}
+}
+
+// Join returns 'name' inside its ancestor directory 'root', the reverse of Rel
+func Join(root, name string) string {
+	switch {
+	case root == ".":
+		return name
+	case name == ".":
+		return root
+	default:
+		return root + "/" + name
+	}
+}
+
+// Contains returns true if 'name' is 'root' or inside it
+func Contains(root, name string) bool {
+	return root == "." || name == root || strings.HasPrefix(name, root+"/")
+}
+
+// ResolveLink returns the FS path a symlink at 'name' points to, given its slash-separated 'target' relative to the link's directory.
+// Returns false if 'target' is absolute or climbs above the root. Targets are resolved lexically, so ".." after another symlink isn't followed like the OS would.
+func ResolveLink(name, target string) (string, bool) {
+	if target == "" || path.IsAbs(target) {
+		return "", false
+	}
+	resolved := path.Join(path.Dir(name), target)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", false
+	}
+	return resolved, true
+}
+
+// RelLink returns the FS path 'target' relative to the directory of the symlink 'name', the reverse of ResolveLink
+func RelLink(name, target string) string {
+	dir := path.Dir(name)
+	var dirElems, targetElems []string
+	if dir != "." {
+		dirElems = strings.Split(dir, "/")
+	}
+	if target != "." {
+		targetElems = strings.Split(target, "/")
+	}
+	common := 0
+	for common < len(dirElems) && common < len(targetElems) && dirElems[common] == targetElems[common] {
+		common++
+	}
+	elems := make([]string, 0, len(dirElems)-common+len(targetElems)-common)
+	for i := common; i < len(dirElems); i++ {
+		elems = append(elems, "..")
+	}
+	elems = append(elems, targetElems[common:]...)
+	if len(elems) == 0 {
+		return "."
+	}
+	return strings.Join(elems, "/")
+}
@@ -0,0 +1,79 @@
+package fspath
+
+import (
+	"path"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestRel(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		root, name, expect string
+	}{
+		{root: ".", name: ".", expect: "."},
+		{root: ".", name: "a/b", expect: "a/b"},
+		{root: "a", name: "a", expect: "."},
+		{root: "a", name: "a/b/c", expect: "b/c"},
+	} {
+		assert.Equal(t, tc.expect, Rel(tc.root, tc.name))
+		assert.Equal(t, tc.name, Join(tc.root, tc.expect))
+	}
+}
+
+func TestContains(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		root, name string
+		expect     bool
+	}{
+		{root: ".", name: "a", expect: true},
+		{root: "a", name: "a", expect: true},
+		{root: "a", name: "a/b", expect: true},
+		{root: "a", name: "ab", expect: false},
+		{root: "a/b", name: "a", expect: false},
+	} {
+		assert.Equal(t, tc.expect, Contains(tc.root, tc.name), tc.root+" contains "+tc.name)
+	}
+}
+
+func TestResolveLink(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name, target string
+		expect       string
+		expectOK     bool
+	}{
+		{name: "link", target: "file", expect: "file", expectOK: true},
+		{name: "dir/link", target: "a", expect: "dir/a", expectOK: true},
+		{name: "dir/link", target: "..", expect: ".", expectOK: true},
+		{name: "dir/sub/link", target: "../../file", expect: "file", expectOK: true},
+		{name: "dir/link", target: "../..", expectOK: false},
+		{name: "link", target: "/etc/passwd", expectOK: false},
+		{name: "link", target: "", expectOK: false},
+	} {
+		resolved, ok := ResolveLink(tc.name, tc.target)
+		assert.Equal(t, tc.expectOK, ok, tc.name+" -> "+tc.target)
+		assert.Equal(t, tc.expect, resolved, tc.name+" -> "+tc.target)
+		if ok {
+			assert.Equal(t, tc.expect, path.Join(path.Dir(tc.name), RelLink(tc.name, resolved)))
+		}
+	}
+}
+
+func TestRelLink(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name, target, expect string
+	}{
+		{name: "link", target: "file", expect: "file"},
+		{name: "link", target: ".", expect: "."},
+		{name: "dir/link", target: "dir/a", expect: "a"},
+		{name: "dir/link", target: ".", expect: ".."},
+		{name: "a/b/link", target: "a/c/file", expect: "../c/file"},
+		{name: "a/b/link", target: "c", expect: "../../c"},
+	} {
+		assert.Equal(t, tc.expect, RelLink(tc.name, tc.target), tc.name+" -> "+tc.target)
+	}
+}
@@ -0,0 +1,95 @@
+//go:build go1.23
+// +build go1.23
+
+package hackpadfs
+
+import (
+	"errors"
+	"io"
+	gofs "io/fs"
+	"iter"
+	"path"
+)
+
+// readDirBatchSize is the number of entries requested from a directory at a time while streaming
+const readDirBatchSize = 256
+
+// All returns an iterator over every file and directory in 'fs' under 'root', including 'root' itself.
+//
+// Directories are streamed in batches rather than read in full, so entries are visited in the order the FS returns them instead of lexical order.
+// Paths which fail to read are skipped. Use WalkDir() to handle errors.
+func All(fs FS, root string) iter.Seq2[string, DirEntry] {
+	return func(yield func(string, DirEntry) bool) {
+		info, err := Stat(fs, root)
+		if err != nil {
+			return
+		}
+		walkAll(fs, root, gofs.FileInfoToDirEntry(info), yield)
+	}
+}
+
+// walkAll yields 'name' and its descendants, returning false if iteration should stop
+func walkAll(fs FS, name string, dirEntry DirEntry, yield func(string, DirEntry) bool) bool {
+	if !yield(name, dirEntry) {
+		return false
+	}
+	if !dirEntry.IsDir() {
+		return true
+	}
+	for entry, err := range ReadDirSeq(fs, name) {
+		if err != nil {
+			return true
+		}
+		if !walkAll(fs, path.Join(name, entry.Name()), entry, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadDirSeq returns an iterator over the entries of directory 'name'. Entries are read in batches if the directory supports ReadDirFile(), otherwise all entries are read at once using ReadDir().
+//
+// If reading fails, the error is yielded with a nil DirEntry and iteration stops.
+func ReadDirSeq(fs FS, name string) iter.Seq2[DirEntry, error] {
+	return func(yield func(DirEntry, error) bool) {
+		dir, err := fs.Open(name)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer func() { _ = dir.Close() }()
+
+		if _, ok := dir.(DirReaderFile); !ok {
+			entries, err := ReadDir(fs, name)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, entry := range entries {
+				if !yield(entry, nil) {
+					return
+				}
+			}
+			return
+		}
+
+		for {
+			entries, err := ReadDirFile(dir, readDirBatchSize)
+			for _, entry := range entries {
+				if !yield(entry, nil) {
+					return
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(entries) == 0 {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+//go:build go1.23
+// +build go1.23
+
+package hackpadfs_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestAll(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar/baz", nil, 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/biff", nil, 0600))
+
+	var names []string
+	for name, entry := range hackpadfs.All(fs, "foo") {
+		names = append(names, name)
+		assert.Equal(t, name == "foo" || name == "foo/bar", entry.IsDir())
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"foo", "foo/bar", "foo/bar/baz", "foo/biff"}, names)
+
+	t.Run("stop early", func(t *testing.T) {
+		t.Parallel()
+		count := 0
+		for range hackpadfs.All(fs, ".") {
+			count++
+			if count == 2 {
+				break
+			}
+		}
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("missing root", func(t *testing.T) {
+		t.Parallel()
+		for name := range hackpadfs.All(fs, "missing") {
+			t.Error("Unexpected path:", name)
+		}
+	})
+}
+
+func TestReadDirSeq(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Mkdir("foo", 0700))
+	var expected []string
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/"+name, nil, 0600))
+		expected = append(expected, name)
+	}
+
+	var names []string
+	for entry, err := range hackpadfs.ReadDirSeq(fs, "foo") {
+		assert.NoError(t, err)
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	assert.Equal(t, expected, names)
+
+	for entry, err := range hackpadfs.ReadDirSeq(fs, "missing") {
+		assert.Equal(t, nil, entry)
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	}
+}
@@ -1,4 +1,6 @@
 // Package blob defines a common data interchange type for keyvalue FS's.
+//
+// Compare the performance of Blob implementations with fstest.BenchmarkBlob().
 package blob
 
 // Blob is a binary blob of data that can support platform-optimized mutations for better performance.
@@ -23,6 +25,13 @@ type SliceBlob interface {
 	Slice(start, end int64) (Blob, error)
 }
 
+// CloneBlob is a Blob that can return an independent copy of all of its data.
+// Mutating the returned Blob must not mutate the original, and vice versa.
+type CloneBlob interface {
+	Blob
+	Clone() (Blob, error)
+}
+
 // SetBlob is a Blob that can copy 'src' into itself starting at the given offset into this Blob.
 // Use View() on 'src' to control the maximum that is copied into this Blob.
 type SetBlob interface {
@@ -58,6 +67,14 @@ func Slice(b Blob, start, end int64) (Blob, error) {
 	return NewBytes(b.Bytes()).Slice(start, end)
 }
 
+// Clone attempts to call an optimized blob.Clone(), falls back to Slice() over the whole Blob.
+func Clone(b Blob) (Blob, error) {
+	if b, ok := b.(CloneBlob); ok {
+		return b.Clone()
+	}
+	return Slice(b, 0, int64(b.Len()))
+}
+
 // Set attempts to call an optimized blob.Set(), falls back to copying into Bytes and running Bytes.Set().
 func Set(dest Blob, src Blob, offset int64) (n int, err error) {
 	if dest, ok := dest.(SetBlob); ok {
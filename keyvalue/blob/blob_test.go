@@ -0,0 +1,31 @@
+package blob_test
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+func BenchmarkBlob(b *testing.B) {
+	fstest.BenchmarkBlob(b, fstest.BlobOptions{
+		Name: "Bytes",
+		NewBlob: func(tb testing.TB, data []byte) blob.Blob {
+			return blob.NewBytes(append([]byte(nil), data...))
+		},
+	})
+	fstest.BenchmarkBlob(b, fstest.BlobOptions{
+		Name: "Sparse",
+		NewBlob: func(tb testing.TB, data []byte) blob.Blob {
+			var extents []blob.Extent
+			if len(data) > 0 {
+				extents = append(extents, blob.Extent{Data: blob.NewBytes(append([]byte(nil), data...))})
+			}
+			s, err := blob.NewSparse(int64(len(data)), extents...)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			return s
+		},
+	})
+}
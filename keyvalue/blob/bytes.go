@@ -13,6 +13,7 @@ var (
 		Blob
 		ViewBlob
 		SliceBlob
+		CloneBlob
 		SetBlob
 		GrowBlob
 		TruncateBlob
@@ -86,6 +87,11 @@ func (b *Bytes) Slice(start, end int64) (Blob, error) {
 	return NewBytes(buf), nil
 }
 
+// Clone implements Blob.
+func (b *Bytes) Clone() (Blob, error) {
+	return b.Slice(0, int64(b.Len()))
+}
+
 // Set implements Blob.
 func (b *Bytes) Set(src Blob, destStart int64) (n int, err error) {
 	if destStart < 0 {
@@ -101,9 +107,25 @@ func (b *Bytes) Set(src Blob, destStart int64) (n int, err error) {
 }
 
 // Grow implements Blob.
+// Capacity at least doubles when reallocating, so growing one small write at a time copies the data a constant number of times on average.
 func (b *Bytes) Grow(offset int64) error {
 	b.mu.Lock()
-	b.bytes = append(b.bytes, make([]byte, offset)...)
+	length := int64(len(b.bytes)) + offset
+	if length <= int64(cap(b.bytes)) {
+		tail := b.bytes[len(b.bytes):length]
+		for i := range tail {
+			tail[i] = 0 // spare capacity may still hold truncated data
+		}
+		b.bytes = b.bytes[:length]
+	} else {
+		capacity := 2 * int64(cap(b.bytes))
+		if capacity < length {
+			capacity = length
+		}
+		buf := make([]byte, length, capacity)
+		copy(buf, b.bytes)
+		b.bytes = buf
+	}
 	atomic.StoreInt64(&b.length, int64(len(b.bytes)))
 	b.mu.Unlock()
 	return nil
@@ -0,0 +1,30 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestBytesGrowZeroesTruncatedData(t *testing.T) {
+	t.Parallel()
+	b := NewBytes([]byte("foo bar"))
+	assert.NoError(t, b.Truncate(3))
+	assert.NoError(t, b.Grow(4))
+	assert.Equal(t, []byte("foo\x00\x00\x00\x00"), b.Bytes())
+}
+
+func TestBytesGrowAmortized(t *testing.T) {
+	t.Parallel()
+	b := NewBytes(nil)
+	reallocations := 0
+	for i := 0; i < 1<<10; i++ {
+		capacity := cap(b.bytes)
+		assert.NoError(t, b.Grow(512))
+		if cap(b.bytes) != capacity {
+			reallocations++
+		}
+	}
+	assert.Equal(t, 512<<10, b.Len())
+	assert.Equal(t, 11, reallocations) // 512 bytes, doubled 10 times
+}
@@ -22,6 +22,14 @@ type WriterAt interface {
 	WriteBlobAt(src Blob, destOffset int64) (n int, err error)
 }
 
+// Transferer takes ownership of 'src', using it as this writer's contents instead of copying it.
+// The caller must not use or mutate 'src' after a successful transfer. Use Clone() on 'src' to hand over an independent copy.
+//
+// Writers which already contain data may write 'src' as with WriteBlob instead.
+type Transferer interface {
+	TransferBlob(src Blob) (n int, err error)
+}
+
 // Read reads 'src' into a new Blob up to length bytes. Attempts to use an optimized ReadBlob if available.
 func Read(src io.Reader, length int) (blob Blob, n int, err error) {
 	if src, ok := src.(Reader); ok {
@@ -57,3 +65,12 @@ func WriteAt(dest io.WriterAt, src Blob, destOffset int64) (n int, err error) {
 	}
 	return dest.WriteAt(src.Bytes(), destOffset)
 }
+
+// Transfer hands 'src' to 'dest' without copying. Attempts to use an optimized TransferBlob if available, falls back to Write().
+// The caller must not use or mutate 'src' after calling Transfer.
+func Transfer(dest io.Writer, src Blob) (n int, err error) {
+	if dest, ok := dest.(Transferer); ok {
+		return dest.TransferBlob(src)
+	}
+	return Write(dest, src)
+}
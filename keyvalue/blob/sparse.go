@@ -0,0 +1,344 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ensure Sparse conforms to these interfaces:
+	_ interface {
+		Blob
+		ViewBlob
+		SliceBlob
+		CloneBlob
+		SetBlob
+		GrowBlob
+		TruncateBlob
+	} = &Sparse{}
+)
+
+// Extent is a contiguous run of data inside a Sparse blob, starting at Offset.
+type Extent struct {
+	Offset int64
+	Data   Blob
+}
+
+func (e Extent) end() int64 {
+	return e.Offset + int64(e.Data.Len())
+}
+
+// Sparse is a Blob made up of data extents, where the gaps between extents read as zeros.
+// Gaps are not stored, so growing a Sparse blob or writing far past its end doesn't allocate the bytes in between.
+type Sparse struct {
+	mu      sync.Mutex
+	extents []Extent // sorted by Offset and never overlapping
+	length  int64
+}
+
+// NewSparse returns a Sparse blob of 'length' bytes containing the given extents.
+// Extents must be sorted by offset, must not overlap, and must fit within 'length'. The Sparse blob takes ownership of each extent's data.
+func NewSparse(length int64, extents ...Extent) (*Sparse, error) {
+	s := &Sparse{length: length}
+	for _, e := range extents {
+		if e.Data.Len() == 0 {
+			continue
+		}
+		if e.Offset < 0 || e.end() > length {
+			return nil, fmt.Errorf("Extent out of bounds: %d", e.Offset)
+		}
+		if len(s.extents) > 0 && e.Offset < s.extents[len(s.extents)-1].end() {
+			return nil, fmt.Errorf("Extent overlaps or is out of order: %d", e.Offset)
+		}
+		s.extents = append(s.extents, e)
+	}
+	return s, nil
+}
+
+// Extents returns the data extents in this Blob, sorted by offset. Mutating an extent's data also mutates this Blob.
+func (s *Sparse) Extents() []Extent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Extent(nil), s.extents...)
+}
+
+// Bytes implements Blob.
+func (s *Sparse) Bytes() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readRange(0, s.length)
+}
+
+// readRange returns a copy of the bytes between start and end. Must be called with s.mu held.
+func (s *Sparse) readRange(start, end int64) []byte {
+	if end > s.length {
+		end = s.length
+	}
+	if start >= end {
+		return []byte{}
+	}
+	buf := make([]byte, end-start)
+	for _, e := range s.overlapping(start, end) {
+		lo, hi := maxInt64(start, e.Offset), minInt64(end, e.end())
+		view, err := View(e.Data, lo-e.Offset, hi-e.Offset)
+		if err != nil {
+			panic(err)
+		}
+		copy(buf[lo-start:], view.Bytes())
+	}
+	return buf
+}
+
+// overlapping returns the extents containing data between start and end. Must be called with s.mu held.
+func (s *Sparse) overlapping(start, end int64) []Extent {
+	first := sort.Search(len(s.extents), func(i int) bool {
+		return s.extents[i].end() > start
+	})
+	last := first
+	for last < len(s.extents) && s.extents[last].Offset < end {
+		last++
+	}
+	return s.extents[first:last]
+}
+
+// Len implements Blob.
+func (s *Sparse) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int(s.length)
+}
+
+func (s *Sparse) checkBounds(start, end int64) error {
+	length := int64(s.Len())
+	if start < 0 || start > length {
+		return fmt.Errorf("Start index out of bounds: %d", start)
+	}
+	if end < start || end > length {
+		return fmt.Errorf("End index out of bounds: %d", end)
+	}
+	return nil
+}
+
+// View implements Blob.
+func (s *Sparse) View(start, end int64) (Blob, error) {
+	if err := s.checkBounds(start, end); err != nil {
+		return nil, err
+	}
+	return &sparseView{sparse: s, start: start, end: end}, nil
+}
+
+// Slice implements Blob.
+func (s *Sparse) Slice(start, end int64) (Blob, error) {
+	if err := s.checkBounds(start, end); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slice := &Sparse{length: end - start}
+	for _, e := range s.overlapping(start, end) {
+		lo, hi := maxInt64(start, e.Offset), minInt64(end, e.end())
+		view, err := View(e.Data, lo-e.Offset, hi-e.Offset)
+		if err != nil {
+			return nil, err
+		}
+		data, err := Clone(view)
+		if err != nil {
+			return nil, err
+		}
+		slice.extents = append(slice.extents, Extent{Offset: lo - start, Data: data})
+	}
+	return slice, nil
+}
+
+// Clone implements Blob.
+func (s *Sparse) Clone() (Blob, error) {
+	return s.Slice(0, int64(s.Len()))
+}
+
+// Set implements Blob.
+// Writing next to or over existing extents extends them in place, so sequential writes produce a single extent.
+func (s *Sparse) Set(src Blob, destStart int64) (n int, err error) {
+	if destStart < 0 {
+		return 0, errors.New("negative offset")
+	}
+	// copy 'src' before locking, since it may be 's', a view of 's', or another Sparse blob copying from 's'
+	data, err := Clone(src)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if destStart > s.length {
+		return 0, fmt.Errorf("Offset out of bounds: %d", destStart)
+	}
+	n = data.Len()
+	if remaining := int(s.length - destStart); n > remaining {
+		n = remaining
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	data, err = View(data, 0, int64(n))
+	if err != nil {
+		return 0, err
+	}
+	destEnd := destStart + int64(n)
+
+	// find extents which overlap or touch the destination range
+	first := sort.Search(len(s.extents), func(i int) bool {
+		return s.extents[i].end() >= destStart
+	})
+	last := first
+	for last < len(s.extents) && s.extents[last].Offset <= destEnd {
+		last++
+	}
+	if first == last {
+		s.extents = append(s.extents, Extent{})
+		copy(s.extents[first+1:], s.extents[first:])
+		s.extents[first] = Extent{Offset: destStart, Data: data}
+		return n, nil
+	}
+
+	// merge the destination range and all touched extents into the first one
+	base := s.extents[first]
+	if destStart < base.Offset {
+		data := NewBytesLength(int(base.end() - destStart))
+		if _, err := data.Set(base.Data, base.Offset-destStart); err != nil {
+			return 0, err
+		}
+		base = Extent{Offset: destStart, Data: data}
+	} else {
+		base.Data = mutable(base.Data)
+	}
+	if grow := maxInt64(destEnd, s.extents[last-1].end()) - base.end(); grow > 0 {
+		if err := Grow(base.Data, grow); err != nil {
+			return 0, err
+		}
+	}
+	for _, e := range s.extents[first+1 : last] {
+		if _, err := Set(base.Data, e.Data, e.Offset-base.Offset); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := Set(base.Data, data, destStart-base.Offset); err != nil {
+		return 0, err
+	}
+	s.extents[first] = base
+	s.extents = append(s.extents[:first+1], s.extents[last:]...)
+	return n, nil
+}
+
+// Grow implements Blob.
+func (s *Sparse) Grow(offset int64) error {
+	s.mu.Lock()
+	s.length += offset
+	s.mu.Unlock()
+	return nil
+}
+
+// Truncate implements Blob.
+func (s *Sparse) Truncate(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.length < size {
+		return nil
+	}
+	keep := sort.Search(len(s.extents), func(i int) bool {
+		return s.extents[i].end() > size
+	})
+	if keep < len(s.extents) && s.extents[keep].Offset < size {
+		e := s.extents[keep]
+		e.Data = mutable(e.Data)
+		if err := Truncate(e.Data, size-e.Offset); err != nil {
+			return err
+		}
+		s.extents[keep] = e
+		keep++
+	}
+	s.extents = s.extents[:keep]
+	s.length = size
+	return nil
+}
+
+// mutable returns 'b' if it can be grown, truncated, and set in place. Otherwise returns a copy which can.
+func mutable(b Blob) Blob {
+	_, canGrow := b.(GrowBlob)
+	_, canTruncate := b.(TruncateBlob)
+	_, canSet := b.(SetBlob)
+	if canGrow && canTruncate && canSet {
+		return b
+	}
+	return NewBytes(b.Bytes())
+}
+
+// sparseView is a view into a Sparse blob. Mutating the view also mutates the original.
+type sparseView struct {
+	sparse     *Sparse
+	start, end int64
+}
+
+func (v *sparseView) Bytes() []byte {
+	v.sparse.mu.Lock()
+	defer v.sparse.mu.Unlock()
+	return v.sparse.readRange(v.start, v.end)
+}
+
+func (v *sparseView) Len() int {
+	return int(v.end - v.start)
+}
+
+func (v *sparseView) View(start, end int64) (Blob, error) {
+	if start < 0 || start > int64(v.Len()) {
+		return nil, fmt.Errorf("Start index out of bounds: %d", start)
+	}
+	if end < start || end > int64(v.Len()) {
+		return nil, fmt.Errorf("End index out of bounds: %d", end)
+	}
+	return &sparseView{sparse: v.sparse, start: v.start + start, end: v.start + end}, nil
+}
+
+func (v *sparseView) Slice(start, end int64) (Blob, error) {
+	if start < 0 || start > int64(v.Len()) {
+		return nil, fmt.Errorf("Start index out of bounds: %d", start)
+	}
+	if end < start || end > int64(v.Len()) {
+		return nil, fmt.Errorf("End index out of bounds: %d", end)
+	}
+	return v.sparse.Slice(v.start+start, v.start+end)
+}
+
+func (v *sparseView) Clone() (Blob, error) {
+	return v.sparse.Slice(v.start, v.end)
+}
+
+func (v *sparseView) Set(src Blob, destStart int64) (n int, err error) {
+	if destStart < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if remaining := int64(v.Len()) - destStart; int64(src.Len()) > remaining {
+		if remaining < 0 {
+			return 0, fmt.Errorf("Offset out of bounds: %d", destStart)
+		}
+		src, err = View(src, 0, remaining)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return v.sparse.Set(src, v.start+destStart)
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
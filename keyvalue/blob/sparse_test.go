@@ -0,0 +1,156 @@
+package blob
+
+import (
+	"bytes"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestSparseGrowDoesNotAllocate(t *testing.T) {
+	t.Parallel()
+	s, err := NewSparse(0)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Grow(1<<40))
+	n, err := s.Set(NewBytes([]byte("end")), 1<<40-3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = s.Set(NewBytes([]byte("start")), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	assert.Equal(t, 1<<40, s.Len())
+	assert.Equal(t, 2, len(s.Extents()))
+	view, err := s.View(1<<40-5, 1<<40)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\x00\x00end"), view.Bytes())
+}
+
+func TestSparseMergesExtents(t *testing.T) {
+	t.Parallel()
+	s, err := NewSparse(20)
+	assert.NoError(t, err)
+	for _, write := range []struct {
+		data   string
+		offset int64
+	}{
+		{"cc", 10},
+		{"aa", 2},
+		{"bb", 6},
+		{"xxx", 4}, // touches "aa" and overlaps "bb"
+	} {
+		_, err := s.Set(NewBytes([]byte(write.data)), write.offset)
+		assert.NoError(t, err)
+	}
+	extents := s.Extents()
+	assert.Equal(t, 2, len(extents))
+	assert.Equal(t, int64(2), extents[0].Offset)
+	assert.Equal(t, []byte("aaxxxb"), extents[0].Data.Bytes())
+	assert.Equal(t, int64(10), extents[1].Offset)
+}
+
+func TestSparseMatchesBytes(t *testing.T) {
+	t.Parallel()
+	const maxLength = 200
+	random := rand.New(rand.NewSource(1))
+	randomBytes := func() []byte {
+		buf := make([]byte, random.Intn(20))
+		for i := range buf {
+			buf[i] = byte(1 + random.Intn(255))
+		}
+		return buf
+	}
+	sparse, err := NewSparse(0)
+	assert.NoError(t, err)
+	dense := NewBytes(nil)
+	for i := 0; i < 2000; i++ {
+		length := int64(dense.Len())
+		switch op := random.Intn(5); op {
+		case 0:
+			offset := int64(random.Intn(maxLength))
+			assert.NoError(t, sparse.Grow(offset))
+			assert.NoError(t, dense.Grow(offset))
+		case 1:
+			size := int64(random.Intn(int(length) + 1))
+			assert.NoError(t, sparse.Truncate(size))
+			assert.NoError(t, dense.Truncate(size))
+		case 2:
+			if length == 0 {
+				continue
+			}
+			start := int64(random.Intn(int(length)))
+			end := start + int64(random.Intn(int(length-start)+1))
+			src := NewBytes(randomBytes())
+			view, err := sparse.View(start, end)
+			assert.NoError(t, err)
+			sparseN, err := view.(SetBlob).Set(src, 0)
+			assert.NoError(t, err)
+			denseView, err := dense.View(start, end)
+			assert.NoError(t, err)
+			denseN, err := denseView.(SetBlob).Set(src, 0)
+			if end == start && src.Len() > 0 {
+				denseN, err = 0, nil // Bytes rejects setting into an empty blob
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, denseN, sparseN)
+		default:
+			src := randomBytes()
+			offset := int64(random.Intn(int(length) + 1))
+			sparseN, err := sparse.Set(NewBytes(src), offset)
+			assert.NoError(t, err)
+			denseN, err := dense.Set(NewBytes(src), offset)
+			if length == 0 && len(src) > 0 {
+				denseN, err = 0, nil // Bytes rejects setting into an empty blob
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, denseN, sparseN)
+		}
+		if !assert.Equal(t, dense.Len(), sparse.Len()) || !assert.Equal(t, true, bytes.Equal(dense.Bytes(), sparse.Bytes())) {
+			t.FailNow()
+		}
+	}
+
+	clone, err := sparse.Clone()
+	assert.NoError(t, err)
+	assert.Equal(t, sparse.Bytes(), clone.Bytes())
+	if sparse.Len() > 0 {
+		_, err = sparse.Set(NewBytes([]byte{0}), 0)
+		assert.NoError(t, err)
+		assert.Equal(t, dense.Bytes(), clone.Bytes())
+	}
+}
+
+func TestSparseSetFromSparse(t *testing.T) {
+	t.Parallel()
+	newSparse := func(contents string) *Sparse {
+		s, err := NewSparse(int64(len(contents)), Extent{Data: NewBytes([]byte(contents))})
+		assert.NoError(t, err)
+		return s
+	}
+
+	s := newSparse("abcd")
+	n, err := s.Set(s, 2) // copying from itself doesn't deadlock
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []byte("abab"), s.Bytes())
+
+	a, b := newSparse("aaaa"), newSparse("bbbb")
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := a.Set(b, 0)
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := b.Set(a, 0)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait() // copying in opposite directions doesn't deadlock
+	assert.Equal(t, a.Bytes(), b.Bytes())
+}
@@ -2,8 +2,10 @@ package keyvalue
 
 import (
 	"context"
+	"errors"
 	"io"
 	"path"
+	"sort"
 	"time"
 
 	"github.com/hack-pad/hackpadfs"
@@ -15,21 +17,37 @@ var (
 		hackpadfs.File
 		io.ReaderAt
 		io.WriterAt
+		io.ReaderFrom
+		io.WriterTo
 		blob.Reader
 		blob.ReaderAt
 		blob.Writer
 		blob.WriterAt
+		blob.Transferer
 		hackpadfs.DirReaderFile
+		hackpadfs.DirNameReaderFile
 		hackpadfs.ReadWriterFile
 		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
 		hackpadfs.TruncaterFile
 	} = &file{}
 )
 
+const (
+	// copyChunkSize is the maximum number of bytes copied at once by ReadFrom and WriteTo
+	copyChunkSize = 1 << 20
+	// sparseThreshold is the smallest run of zeros written past the end of a file which switches it to a blob.Sparse
+	sparseThreshold = 1 << 20
+)
+
 type file struct {
 	*fileData
-	offset int64
+	offset hackpadfs.FileOffset
 	flag   int
+
+	dirNames   []string // dirNames is the directory's listing as of the first ReadDir() since opening or seeking to the start, if dirListed is set
+	dirListed  bool
+	dirRewound bool // dirRewound is set after seeking to the start, so the next listing is read from the store again
 }
 
 type fileData struct {
@@ -37,8 +55,10 @@ type fileData struct {
 	modeOverride    *hackpadfs.FileMode
 	modTimeOverride time.Time
 
-	path string // path is stored as the "key", keeping it here is for generating hackpadfs.FileInfo's
-	fs   *FS
+	path    string // path is stored as the "key", keeping it here is for generating hackpadfs.FileInfo's
+	fs      *FS
+	dirty   bool   // dirty is true if buffered writes haven't been saved yet
+	version string // version is the file's version in a VersionedStore when last read or saved, or "" if unknown
 }
 
 func (f *fileData) Mode() hackpadfs.FileMode {
@@ -56,8 +76,17 @@ func (f *fileData) ModTime() time.Time {
 	return f.runOnceFileRecord.ModTime()
 }
 
-// getFile returns a file for 'path' if it exists, os.ErrNotExist otherwise
-func (fs *FS) getFile(path string) (*file, error) {
+// Hash returns the checksum of the file's contents from the store, unless they may have changed since the file was read
+func (f *fileData) Hash() (algo string, sum []byte, ok bool) {
+	var zero time.Time
+	if f.dirty || f.modTimeOverride != zero {
+		return "", nil, false
+	}
+	return recordHash(f.runOnceFileRecord.record)
+}
+
+// getFile returns a file for 'path' if it exists for the FS operation 'op', os.ErrNotExist otherwise
+func (fs *FS) getFile(op, path string) (*file, error) {
 	if !hackpadfs.ValidPath(path) {
 		return nil, hackpadfs.ErrInvalid
 	}
@@ -65,7 +94,7 @@ func (fs *FS) getFile(path string) (*file, error) {
 		path: path,
 		fs:   fs,
 	}
-	txn, err := fs.store.Transaction(TransactionOptions{
+	txn, err := fs.store.Transaction(op, TransactionOptions{
 		Mode: TransactionReadOnly,
 	})
 	if err != nil {
@@ -77,11 +106,13 @@ func (fs *FS) getFile(path string) (*file, error) {
 		return nil, err
 	}
 	f.runOnceFileRecord.record, err = results[0].Record, results[0].Err
+	f.version = recordVersion(f.runOnceFileRecord.record)
 	return &file{fileData: &f}, err
 }
 
 // setFile write the 'file' data to the store at 'path'. If 'file' is nil, the file is deleted.
-func (fs *FS) setFile(path string, file FileRecord) error {
+// If 'created' is set, also updates the parent directory's modified time when Options.UpdateDirModTime is set.
+func (fs *FS) setFile(op, path string, file FileRecord, created bool) error {
 	var contents blob.Blob
 	if file != nil && file.Mode().IsRegular() {
 		var err error
@@ -90,18 +121,86 @@ func (fs *FS) setFile(path string, file FileRecord) error {
 			return err
 		}
 	}
-	txn, err := fs.store.Transaction(TransactionOptions{
+	txn, err := fs.store.Transaction(op, TransactionOptions{
 		Mode: TransactionReadWrite,
 	})
 	if err == nil {
 		err = fs.setFileTxn(txn, path, file, contents)
 	}
-	if err == nil {
-		_, err = txn.Commit(context.Background())
+	if err != nil {
+		return err
+	}
+	if created {
+		fs.touchParentsTxn(txn, OpHandlerFunc(noopHandler), path)
+	}
+	return commitErr(txn.Commit(context.Background()))
+}
+
+// commitErr returns the first error from committing a transaction
+func commitErr(results []OpResult, err error) error {
+	for _, result := range results {
+		if err == nil {
+			err = result.Err
+		}
 	}
 	return err
 }
 
+// deleteFile removes the file at 'path' from the store
+func (fs *FS) deleteFile(op, path string) error {
+	if !hackpadfs.ValidPath(path) {
+		return hackpadfs.ErrInvalid
+	}
+	txn, err := fs.store.Transaction(op, TransactionOptions{
+		Mode: TransactionReadWrite,
+	})
+	if err != nil {
+		return err
+	}
+	txn.Delete(path)
+	fs.touchParentsTxn(txn, OpHandlerFunc(noopHandler), path)
+	return commitErr(txn.Commit(context.Background()))
+}
+
+// setFileMetadata writes only the metadata of 'file' to the store at 'path', if supported. Otherwise, the full file is written.
+func (fs *FS) setFileMetadata(op, path string, file FileRecord) error {
+	store, ok := fs.store.store.(MetadataStore)
+	if !ok {
+		return fs.setFile(op, path, file, false)
+	}
+	if !hackpadfs.ValidPath(path) {
+		return hackpadfs.ErrInvalid
+	}
+	hooks := fs.store.hooks
+	file, _, err := hooks.beforeSet(op, path, file, nil)
+	if err == nil {
+		err = store.SetMetadata(context.Background(), fs.store.storePath(path), file)
+	}
+	return hooks.afterSet(op, path, err)
+}
+
+// setFileVersion writes 'file' to the store at 'path' if its stored version is still 'version', returning the new version.
+func (fs *FS) setFileVersion(op string, store VersionedStore, path string, file FileRecord, version string) (string, error) {
+	if !hackpadfs.ValidPath(path) {
+		return "", hackpadfs.ErrInvalid
+	}
+	hooks := fs.store.hooks
+	var contents blob.Blob
+	if hooks != nil && file.Mode().IsRegular() {
+		var err error
+		contents, err = file.Data()
+		if err != nil {
+			return "", err
+		}
+	}
+	file, _, err := hooks.beforeSet(op, path, file, contents)
+	if err != nil {
+		return "", hooks.afterSet(op, path, err)
+	}
+	newVersion, err := store.SetVersion(context.Background(), fs.store.storePath(path), file, version)
+	return newVersion, hooks.afterSet(op, path, err)
+}
+
 func (fs *FS) setFileTxn(txn Transaction, path string, file FileRecord, contents blob.Blob) error {
 	if !hackpadfs.ValidPath(path) {
 		return hackpadfs.ErrInvalid
@@ -114,6 +213,36 @@ func (fs *FS) setFileTxn(txn Transaction, path string, file FileRecord, contents
 	return nil
 }
 
+// touchParentsTxn sets the modified time of each parent directory of 'paths' to now in 'txn', if Options.UpdateDirModTime is set.
+// The directories are read and written in 'txn', so the updates apply along with the changes to their children. Each write reports its result to 'handler'.
+func (fs *FS) touchParentsTxn(txn Transaction, handler OpHandler, paths ...string) {
+	if !fs.options.UpdateDirModTime {
+		return
+	}
+	now := time.Now()
+	touched := make(map[string]bool)
+	for _, p := range paths {
+		dir := path.Dir(p)
+		if p == "." || touched[dir] {
+			continue
+		}
+		touched[dir] = true
+		txn.GetHandler(dir, OpHandlerFunc(func(txn Transaction, result OpResult) error {
+			if result.Err != nil || !result.Record.Mode().IsDir() {
+				return nil // the changes to the children fail on their own
+			}
+			record := result.Record
+			txn.SetHandler(dir, WithID(NewBaseFileRecord(record.Size(), now, record.Mode(), record.Sys(),
+				func() (blob.Blob, error) {
+					return blob.NewBytes(nil), nil
+				},
+				record.ReadDirNames,
+			), RecordID(record)), nil, handler)
+			return nil
+		}))
+	}
+}
+
 type fileInfo struct {
 	Record FileRecord
 	Path   string
@@ -143,26 +272,120 @@ func (f fileInfo) Sys() interface{} {
 	return f.Record.Sys()
 }
 
+func (f fileInfo) Hash() (algo string, sum []byte, ok bool) {
+	return recordHash(f.Record)
+}
+
+func (f fileInfo) ID() string {
+	return RecordID(f.Record)
+}
+
 func (fs *FS) newFile(path string, flag int, mode hackpadfs.FileMode) *file {
 	return &file{
-		flag: flag,
+		flag:   flag,
+		offset: hackpadfs.NewFileOffset(flag),
 		fileData: &fileData{
 			fs:   fs,
 			path: path,
 			runOnceFileRecord: runOnceFileRecord{
-				record: NewBaseFileRecord(0, time.Now(), mode, nil,
+				record: WithID(NewBaseFileRecord(0, time.Now(), mode, nil,
 					func() (blob.Blob, error) {
 						return blob.NewBytes(nil), nil
 					},
 					nil,
-				),
+				), newFileID()),
 			},
 		},
 	}
 }
 
-func (f *fileData) save() error {
-	return f.fs.setFile(f.path, f)
+// save writes the file to the store. If the file was read from a VersionedStore, fails with ErrVersionConflict if another writer changed it since.
+func (f *fileData) save(op string) error {
+	var err error
+	if store, ok := f.fs.store.store.(VersionedStore); ok && f.version != "" {
+		f.version, err = f.fs.setFileVersion(op, store, f.path, f, f.version)
+	} else {
+		err = f.fs.setFile(op, f.path, f, false)
+	}
+	if err == nil {
+		f.dirty = false
+	}
+	return err
+}
+
+// create saves a new file, updating its parent directory's modified time if Options.UpdateDirModTime is set
+func (f *fileData) create(op string) error {
+	return f.fs.setFile(op, f.path, f, true)
+}
+
+// saveContents saves the file after a change to its contents, or defers the save until Sync() or Close() if writes are buffered.
+func (f *file) saveContents(op string) error {
+	if f.fs.options.BufferWrites && f.flag&(hackpadfs.FlagSync|hackpadfs.FlagAppend) == 0 {
+		f.dirty = true
+		return nil
+	}
+	return f.save(op)
+}
+
+// flush saves any buffered writes
+func (f *fileData) flush(op string) error {
+	if !f.dirty {
+		return nil
+	}
+	unlock := f.lockPath()
+	defer unlock()
+	return f.save(op)
+}
+
+// lockPath blocks other handles from writing to this file's path until the returned func is called
+func (f *fileData) lockPath() (unlock func()) {
+	key := f.fs.store.storePath(f.path)
+	f.fs.pathlock.Lock(key)
+	return func() { f.fs.pathlock.Unlock(key) }
+}
+
+// lockWrite is like lockPath, then reloads the file's record if another handle has changed it, so writes apply on top of the latest contents.
+// The returned unlock func must be called after the write is saved.
+func (f *file) lockWrite(op string) (unlock func(), err error) {
+	unlock = f.lockPath()
+	if !f.dirty { // buffered writes aren't in the store yet, reloading would discard them
+		err = f.refresh(op)
+	}
+	return unlock, err
+}
+
+// refresh replaces the file's record with the latest one from the store, if it may have changed since this handle read it.
+// Without a VersionedStore, changes can't be detected, so the record is only refreshed before appends. Otherwise appends from other handles would be overwritten.
+// Keeps the current record if the file was removed.
+func (f *file) refresh(op string) error {
+	_, versioned := f.fs.store.store.(VersionedStore)
+	if !versioned && f.flag&hackpadfs.FlagAppend == 0 {
+		return nil
+	}
+	latest, err := f.fs.getFile(op, f.path)
+	if errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if versioned && f.version != "" && f.version == latest.version {
+		return nil
+	}
+	f.runOnceFileRecord = runOnceFileRecord{record: latest.record}
+	f.version = latest.version
+	return nil
+}
+
+// saveMetadata is like save, but skips rewriting the file's contents when the store supports it.
+// Buffered writes to a VersionedStore are saved along with the metadata instead, since a metadata-only write changes the version they're saved against.
+func (f *fileData) saveMetadata(op string) error {
+	if _, versioned := f.fs.store.store.(VersionedStore); versioned && f.dirty {
+		unlock := f.lockPath()
+		defer unlock()
+		return f.save(op)
+	}
+	return f.fs.setFileMetadata(op, f.path, f)
 }
 
 func (f *fileData) info() hackpadfs.FileInfo {
@@ -173,7 +396,20 @@ func (f *file) Close() error {
 	if f.fileData == nil {
 		return hackpadfs.ErrClosed
 	}
+	err := f.flush("close")
+	path := f.path
 	f.fileData = nil
+	if err != nil {
+		return &hackpadfs.PathError{Op: "close", Path: path, Err: err}
+	}
+	return nil
+}
+
+// Sync implements hackpadfs.SyncerFile. Saves any buffered writes to the store.
+func (f *file) Sync() error {
+	if err := f.flush("sync"); err != nil {
+		return &hackpadfs.PathError{Op: "sync", Path: f.path, Err: err}
+	}
 	return nil
 }
 
@@ -182,14 +418,14 @@ func (f *file) updateModTime() {
 }
 
 func (f *file) Read(p []byte) (n int, err error) {
-	n, err = f.ReadAt(p, f.offset)
-	f.offset += int64(n)
+	n, err = f.ReadAt(p, f.offset.Position())
+	f.offset.Read(n)
 	return
 }
 
 func (f *file) ReadBlob(length int) (blob blob.Blob, n int, err error) {
-	blob, n, err = f.ReadBlobAt(length, f.offset)
-	f.offset += int64(n)
+	blob, n, err = f.ReadBlobAt(length, f.offset.Position())
+	f.offset.Read(n)
 	return
 }
 
@@ -226,21 +462,13 @@ func (f *file) ReadBlobAt(length int, off int64) (b blob.Blob, n int, err error)
 }
 
 func (f *file) Seek(offset int64, whence int) (int64, error) {
-	newOffset := f.offset
-	switch whence {
-	case io.SeekStart:
-		newOffset = offset
-	case io.SeekCurrent:
-		newOffset += offset
-	case io.SeekEnd:
-		newOffset = int64(f.Size()) + offset
-	default:
-		return 0, &hackpadfs.PathError{Op: "seek", Path: f.path, Err: hackpadfs.ErrInvalid}
-	}
-	if newOffset < 0 {
-		return 0, &hackpadfs.PathError{Op: "seek", Path: f.path, Err: hackpadfs.ErrInvalid}
-	}
-	f.offset = newOffset
+	newOffset, err := f.offset.SeekSize(offset, whence, int64(f.Size()))
+	if err != nil {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.path, Err: err}
+	}
+	if newOffset == 0 {
+		f.dirNames, f.dirListed, f.dirRewound = nil, false, true // rewinding the directory cursor lists the directory again
+	}
 	return newOffset, nil
 }
 
@@ -250,8 +478,19 @@ func (f *file) Write(p []byte) (n int, err error) {
 }
 
 func (f *file) WriteBlob(p blob.Blob) (n int, err error) {
-	n, err = f.writeBlobAt("write", p, f.offset)
-	f.offset += int64(n)
+	unlock, err := f.lockWrite("write")
+	defer unlock()
+	if err != nil {
+		return 0, &hackpadfs.PathError{Op: "write", Path: f.path, Err: err}
+	}
+	return f.writeBlob(p)
+}
+
+// writeBlob is WriteBlob, but requires the caller to hold lockWrite()
+func (f *file) writeBlob(p blob.Blob) (n int, err error) {
+	off := f.offset.WritePosition(int64(f.Size()))
+	n, err = f.writeBlobAt("write", p, off)
+	f.offset.Wrote(off, n)
 	return
 }
 
@@ -260,6 +499,11 @@ func (f *file) WriteAt(p []byte, off int64) (n int, err error) {
 }
 
 func (f *file) WriteBlobAt(p blob.Blob, off int64) (n int, err error) {
+	unlock, err := f.lockWrite("writeat")
+	defer unlock()
+	if err != nil {
+		return 0, &hackpadfs.PathError{Op: "writeat", Path: f.path, Err: err}
+	}
 	return f.writeBlobAt("writeat", p, off)
 }
 
@@ -269,12 +513,8 @@ func (f *file) writeBlobAt(op string, p blob.Blob, off int64) (n int, err error)
 	}
 
 	endIndex := off + int64(p.Len())
-	if int64(f.Size()) < endIndex {
-		data, err := f.Data()
-		if err != nil {
-			return 0, &hackpadfs.PathError{Op: op, Path: f.path, Err: err}
-		}
-		err = blob.Grow(data, endIndex-int64(f.Size()))
+	if size := int64(f.Size()); size < endIndex {
+		err := f.grow(endIndex-size, off-size)
 		if err != nil {
 			return 0, &hackpadfs.PathError{Op: op, Path: f.path, Err: err}
 		}
@@ -290,10 +530,168 @@ func (f *file) writeBlobAt(op string, p blob.Blob, off int64) (n int, err error)
 	if n != 0 {
 		f.updateModTime()
 	}
-	err = f.save()
+	err = f.saveContents(op)
+	return
+}
+
+// TransferBlob implements blob.Transferer.
+// Takes ownership of 'src' as the file's contents if the file is empty, otherwise writes 'src' as with WriteBlob.
+func (f *file) TransferBlob(src blob.Blob) (n int, err error) {
+	unlock, err := f.lockWrite("write")
+	defer unlock()
+	if err != nil {
+		return 0, &hackpadfs.PathError{Op: "write", Path: f.path, Err: err}
+	}
+	if f.Size() != 0 || f.offset.WritePosition(0) != 0 {
+		return f.writeBlob(src)
+	}
+	// resolve the record's data first, so it isn't loaded later over the top of 'src'
+	if _, err := f.Data(); err != nil {
+		return 0, &hackpadfs.PathError{Op: "write", Path: f.path, Err: err}
+	}
+	f.data = src
+	n = src.Len()
+	f.offset.Wrote(0, n)
+	if n != 0 {
+		f.updateModTime()
+	}
+	err = f.saveContents("write")
 	return
 }
 
+// grow extends the file's contents by 'n' bytes, where the first 'gap' bytes are zeros.
+// Large gaps switch the contents to a blob.Sparse, so the zeros aren't allocated.
+func (f *file) grow(n, gap int64) error {
+	data, err := f.Data()
+	if err != nil {
+		return err
+	}
+	if _, isSparse := data.(*blob.Sparse); !isSparse && gap >= sparseThreshold {
+		data, err = blob.NewSparse(int64(data.Len()), blob.Extent{Data: data})
+		if err != nil {
+			return err
+		}
+		f.data = data
+	}
+	return blob.Grow(data, n)
+}
+
+// ReadFrom implements io.ReaderFrom.
+// Reads directly into the file's contents in large chunks and saves the file once, instead of once per Write.
+// Other handles' writes to this file are blocked until 'r' is exhausted, so all of 'r' is written at once.
+func (f *file) ReadFrom(r io.Reader) (n int64, err error) {
+	unlock, err := f.lockWrite("write")
+	defer unlock()
+	if err != nil {
+		return 0, &hackpadfs.PathError{Op: "write", Path: f.path, Err: err}
+	}
+	data, err := f.Data()
+	if err != nil {
+		return 0, &hackpadfs.PathError{Op: "write", Path: f.path, Err: err}
+	}
+	off := f.offset.WritePosition(int64(f.Size()))
+	buf := make([]byte, copyChunkSize)
+	for {
+		chunkSize, readErr := r.Read(buf)
+		if chunkSize > 0 {
+			end := off + n + int64(chunkSize)
+			if size := int64(data.Len()); size < end {
+				if err := blob.Grow(data, end-size); err != nil {
+					return n, f.finishReadFrom(off, n, err)
+				}
+			}
+			setN, err := blob.Set(data, blob.NewBytes(buf[:chunkSize]), off+n)
+			n += int64(setN)
+			if err != nil {
+				return n, f.finishReadFrom(off, n, err)
+			}
+		}
+		if readErr == io.EOF {
+			return n, f.finishReadFrom(off, n, nil)
+		}
+		if readErr != nil {
+			return n, f.finishReadFrom(off, n, readErr)
+		}
+	}
+}
+
+// finishReadFrom advances the file offset past the 'n' bytes written at 'off' and saves the file. Returns 'err' if the save succeeds.
+func (f *file) finishReadFrom(off, n int64, err error) error {
+	f.offset.Wrote(off, int(n))
+	if n == 0 {
+		return err
+	}
+	f.updateModTime()
+	if saveErr := f.saveContents("write"); saveErr != nil {
+		return &hackpadfs.PathError{Op: "write", Path: f.path, Err: saveErr}
+	}
+	if err != nil {
+		return &hackpadfs.PathError{Op: "write", Path: f.path, Err: err}
+	}
+	return nil
+}
+
+// WriteTo implements io.WriterTo.
+// Writes views of the file's contents in large chunks, avoiding intermediate buffers. Uses blob.Writer if 'w' supports it.
+// If 'w' is a blob.Transferer, the remaining contents are cloned and handed over in one call instead.
+func (f *file) WriteTo(w io.Writer) (n int64, err error) {
+	data, err := f.Data()
+	if err != nil {
+		return 0, &hackpadfs.PathError{Op: "read", Path: f.path, Err: err}
+	}
+	if w, ok := w.(blob.Transferer); ok {
+		return f.transferTo(w, data)
+	}
+	for {
+		off := f.offset.Position()
+		size := int64(data.Len())
+		if off >= size {
+			return n, nil
+		}
+		end := off + copyChunkSize
+		if end > size {
+			end = size
+		}
+		chunk, err := blob.View(data, off, end)
+		if err != nil {
+			return n, &hackpadfs.PathError{Op: "read", Path: f.path, Err: err}
+		}
+		chunkN, err := blob.Write(w, chunk)
+		f.offset.Read(chunkN)
+		n += int64(chunkN)
+		if err != nil {
+			return n, err
+		}
+		if chunkN < chunk.Len() {
+			return n, io.ErrShortWrite
+		}
+	}
+}
+
+func (f *file) transferTo(w blob.Transferer, data blob.Blob) (int64, error) {
+	off := f.offset.Position()
+	size := int64(data.Len())
+	if off >= size {
+		return 0, nil
+	}
+	remaining, err := blob.View(data, off, size)
+	if err == nil {
+		remaining, err = blob.Clone(remaining)
+	}
+	if err != nil {
+		return 0, &hackpadfs.PathError{Op: "read", Path: f.path, Err: err}
+	}
+	n, err := w.TransferBlob(remaining)
+	f.offset.Read(n)
+	if err != nil {
+		return int64(n), err
+	}
+	if n < remaining.Len() {
+		return int64(n), io.ErrShortWrite
+	}
+	return int64(n), nil
+}
+
 func (f *file) Stat() (hackpadfs.FileInfo, error) {
 	return fileInfo{Record: &f.runOnceFileRecord, Path: f.path}, nil
 }
@@ -302,6 +700,11 @@ func (f *file) Truncate(size int64) error {
 	if f.Mode().IsDir() {
 		return &hackpadfs.PathError{Op: "truncate", Path: f.path, Err: hackpadfs.ErrIsDir}
 	}
+	unlock, err := f.lockWrite("truncate")
+	defer unlock()
+	if err != nil {
+		return &hackpadfs.PathError{Op: "truncate", Path: f.path, Err: err}
+	}
 	length := int64(f.Size())
 	switch {
 	case size < 0:
@@ -309,11 +712,7 @@ func (f *file) Truncate(size int64) error {
 	case size == length:
 		return nil
 	case size > length:
-		data, err := f.Data()
-		if err != nil {
-			return &hackpadfs.PathError{Op: "truncate", Path: f.path, Err: err}
-		}
-		err = blob.Grow(data, size-length)
+		err := f.grow(size-length, size-length)
 		if err != nil {
 			return &hackpadfs.PathError{Op: "truncate", Path: f.path, Err: err}
 		}
@@ -328,32 +727,69 @@ func (f *file) Truncate(size int64) error {
 		}
 	}
 	f.updateModTime()
-	return f.save()
+	return f.saveContents("truncate")
 }
 
+// ReadDir implements hackpadfs.DirReaderFile
+// Batches read with n > 0 come from the same listing, so entries created after the first batch aren't returned, and entries removed since are skipped.
+// Seek to the start of the directory to list it again.
 func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
-	dirNames, err := f.ReadDirNames()
-	if err != nil {
-		return nil, &hackpadfs.PathError{Op: "readdir", Path: f.path, Err: err}
-	}
-	start, end := f.offset, f.offset+int64(n)
-	if n <= 0 {
-		start, end = 0, int64(len(dirNames))
-	} else if end > int64(len(dirNames)) {
-		end = int64(len(dirNames))
+	for {
+		dirNames, err := f.readDirNames("readdir", n)
+		if err != nil {
+			return nil, err
+		}
+		var entries []hackpadfs.DirEntry
+		for _, name := range dirNames {
+			entry, err := newDirEntry(f.fs, f.path, name)
+			if errors.Is(err, hackpadfs.ErrNotExist) {
+				continue // removed since the directory was listed
+			}
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+		if n <= 0 || len(entries) > 0 {
+			return entries, nil
+		}
+		// every entry in this batch was removed, so try the next batch instead of returning an empty one
 	}
-	offsetAdd := end - start
+}
 
-	var entries []hackpadfs.DirEntry
-	for _, name := range dirNames[start:end] {
-		entry, err := newDirEntry(f.fs, f.path, name)
+// ReadDirNames implements hackpadfs.DirNameReaderFile, with the same listing as ReadDir. Names aren't checked for entries removed since the directory was listed.
+func (f *file) ReadDirNames(n int) ([]string, error) {
+	return f.readDirNames("readdirnames", n)
+}
+
+// readDirNames returns the next 'n' directory entry names and advances the directory cursor. Returns all remaining names if n <= 0.
+// The directory is listed on the first call, then later calls continue through the same listing. Names are sorted, unless Options.UnsortedReadDir is set.
+func (f *file) readDirNames(op string, n int) ([]string, error) {
+	if !f.dirListed {
+		dir := f.fileData
+		if f.dirRewound {
+			current, err := f.fs.getFile(op, f.path)
+			if err != nil {
+				return nil, &hackpadfs.PathError{Op: op, Path: f.path, Err: err}
+			}
+			dir = current.fileData
+		}
+		dirNames, err := dir.ReadDirNames()
 		if err != nil {
-			return nil, err
+			return nil, &hackpadfs.PathError{Op: op, Path: f.path, Err: err}
+		}
+		if !f.fs.options.UnsortedReadDir && !sort.StringsAreSorted(dirNames) {
+			dirNames = append([]string(nil), dirNames...) // the store may return a slice it still uses
+			sort.Strings(dirNames)
 		}
-		entries = append(entries, entry)
+		f.dirNames, f.dirListed = dirNames, true
+	}
+	dirNames := f.dirNames
+	start, end := f.offset.NextDirEntries(len(dirNames), n)
+	if n > 0 && start == end {
+		return nil, io.EOF
 	}
-	f.offset += offsetAdd
-	return entries, nil
+	return dirNames[start:end], nil
 }
 
 type dirEntry struct {
@@ -361,8 +797,8 @@ type dirEntry struct {
 	info     hackpadfs.FileInfo
 }
 
-func newDirEntry(fs hackpadfs.FS, basePath, name string) (*dirEntry, error) {
-	info, err := hackpadfs.Stat(fs, path.Join(basePath, name))
+func newDirEntry(fs *FS, basePath, name string) (*dirEntry, error) {
+	info, err := fs.stat("readdir", path.Join(basePath, name))
 	return &dirEntry{
 		baseName: name,
 		info:     info,
@@ -388,5 +824,11 @@ func (d *dirEntry) Info() (hackpadfs.FileInfo, error) {
 func (f *file) Chmod(mode hackpadfs.FileMode) error {
 	newMode := (f.Mode() & ^chmodBits) | (mode & chmodBits)
 	f.modeOverride = &newMode
-	return f.save()
+	return f.saveMetadata("chmod")
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	f.modTimeOverride = mtime
+	return f.saveMetadata("chtimes")
 }
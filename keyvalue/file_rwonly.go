@@ -1,6 +1,9 @@
 package keyvalue
 
 import (
+	"io"
+	"time"
+
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/keyvalue/blob"
 )
@@ -29,6 +32,10 @@ func (r *readOnlyFile) ReadBlobAt(length int, off int64) (b blob.Blob, n int, er
 	return r.file.ReadBlobAt(length, off)
 }
 
+func (r *readOnlyFile) WriteTo(w io.Writer) (n int64, err error) {
+	return r.file.WriteTo(w)
+}
+
 func (r *readOnlyFile) Seek(offset int64, whence int) (int64, error) {
 	return r.file.Seek(offset, whence)
 }
@@ -37,6 +44,10 @@ func (r *readOnlyFile) Stat() (hackpadfs.FileInfo, error) {
 	return r.file.Stat()
 }
 
+func (r *readOnlyFile) Sync() error {
+	return r.file.Sync()
+}
+
 func (r *readOnlyFile) Truncate(size int64) error {
 	return r.file.Truncate(size)
 }
@@ -45,6 +56,10 @@ func (r *readOnlyFile) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
 	return r.file.ReadDir(n)
 }
 
+func (r *readOnlyFile) ReadDirNames(n int) ([]string, error) {
+	return r.file.ReadDirNames(n)
+}
+
 func (r *readOnlyFile) Chmod(mode hackpadfs.FileMode) error {
 	return r.file.Chmod(mode)
 }
@@ -74,6 +89,14 @@ func (w *writeOnlyFile) WriteBlob(p blob.Blob) (n int, err error) {
 	return w.file.WriteBlob(p)
 }
 
+func (w *writeOnlyFile) TransferBlob(p blob.Blob) (n int, err error) {
+	return w.file.TransferBlob(p)
+}
+
+func (w *writeOnlyFile) ReadFrom(r io.Reader) (n int64, err error) {
+	return w.file.ReadFrom(r)
+}
+
 func (w *writeOnlyFile) WriteAt(p []byte, off int64) (n int, err error) {
 	return w.file.WriteAt(p, off)
 }
@@ -86,6 +109,10 @@ func (w *writeOnlyFile) Stat() (hackpadfs.FileInfo, error) {
 	return w.file.Stat()
 }
 
+func (w *writeOnlyFile) Sync() error {
+	return w.file.Sync()
+}
+
 func (w *writeOnlyFile) Truncate(size int64) error {
 	return w.file.Truncate(size)
 }
@@ -93,3 +120,7 @@ func (w *writeOnlyFile) Truncate(size int64) error {
 func (w *writeOnlyFile) Chmod(mode hackpadfs.FileMode) error {
 	return w.file.Chmod(mode)
 }
+
+func (w *writeOnlyFile) Chtimes(atime time.Time, mtime time.Time) error {
+	return w.file.Chtimes(atime, mtime)
+}
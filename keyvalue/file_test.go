@@ -0,0 +1,380 @@
+package keyvalue_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestFileReadFrom(t *testing.T) {
+	t.Parallel()
+	store := &countingStore{Store: mem.NewStore()}
+	fs, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	f, err := fs.OpenFile("foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate, 0600)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("hello "))
+	assert.NoError(t, err)
+	store.sets = 0
+
+	contents := bytes.Repeat([]byte("0123456789"), 300_000) // spans several chunks
+	n, err := f.(io.ReaderFrom).ReadFrom(bytes.NewReader(contents))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), n)
+	assert.Equal(t, 1, store.sets)
+	_, err = hackpadfs.WriteFile(f, []byte("!"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	fileContents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello "+string(contents)+"!", string(fileContents))
+}
+
+func TestFileWriteTo(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	assert.NoError(t, err)
+	contents := bytes.Repeat([]byte("0123456789"), 300_000)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", contents, 0600))
+	f, err := fs.Open("foo")
+	assert.NoError(t, err)
+	_, err = hackpadfs.SeekFile(f, 5, io.SeekStart)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := f.(io.WriterTo).WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(contents)-5), n)
+	assert.Equal(t, string(contents[5:]), buf.String())
+	n, err = f.(io.WriterTo).WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.NoError(t, f.Close())
+}
+
+func TestFileCopy(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("bar"), 0600))
+	src, err := fs.Open("foo")
+	assert.NoError(t, err)
+	dest, err := fs.OpenFile("baz", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagAppend, 0600)
+	assert.NoError(t, err)
+	n, err := io.Copy(dest.(io.Writer), src)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.NoError(t, src.Close())
+	assert.NoError(t, dest.Close())
+
+	contents, err := hackpadfs.ReadFile(fs, "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(contents))
+}
+
+func TestFileTransfer(t *testing.T) {
+	t.Parallel()
+	srcFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	destStore := &countingStore{Store: mem.NewStore()}
+	destFS, err := keyvalue.NewFS(destStore)
+	assert.NoError(t, err)
+	contents := bytes.Repeat([]byte("0123456789"), 300_000)
+	assert.NoError(t, hackpadfs.WriteFullFile(srcFS, "foo", contents, 0600))
+
+	src, err := srcFS.Open("foo")
+	assert.NoError(t, err)
+	_, err = hackpadfs.SeekFile(src, 5, io.SeekStart)
+	assert.NoError(t, err)
+	dest, err := destFS.OpenFile("foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate, 0600)
+	assert.NoError(t, err)
+	destStore.sets = 0
+	n, err := io.Copy(dest.(io.Writer), src)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(contents)-5), n)
+	assert.Equal(t, 1, destStore.sets) // handed over in one call instead of chunks
+	assert.NoError(t, src.Close())
+	assert.NoError(t, dest.Close())
+
+	assert.NoError(t, hackpadfs.WriteFullFile(srcFS, "foo", []byte("changed"), 0600))
+	destContents, err := hackpadfs.ReadFile(destFS, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, string(contents[5:]), string(destContents))
+}
+
+func TestFileBufferWrites(t *testing.T) {
+	t.Parallel()
+	store := &countingStore{Store: mem.NewStore()}
+	fs, err := keyvalue.NewFSWithOptions(store, keyvalue.Options{BufferWrites: true})
+	assert.NoError(t, err)
+	f, err := fs.OpenFile("foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate, 0600)
+	assert.NoError(t, err)
+	store.sets = 0
+
+	for i := 0; i < 100; i++ {
+		_, err := hackpadfs.WriteFile(f, []byte("line\n"))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 0, store.sets)
+
+	assert.NoError(t, hackpadfs.SyncFile(f))
+	assert.Equal(t, 1, store.sets)
+	contents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("line\n", 100), string(contents))
+
+	_, err = hackpadfs.WriteFile(f, []byte("end"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.Equal(t, 2, store.sets)
+	contents, err = hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("line\n", 100)+"end", string(contents))
+}
+
+func TestFileBufferWritesFlagSync(t *testing.T) {
+	t.Parallel()
+	store := &countingStore{Store: mem.NewStore()}
+	fs, err := keyvalue.NewFSWithOptions(store, keyvalue.Options{BufferWrites: true})
+	assert.NoError(t, err)
+	f, err := fs.OpenFile("foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagSync, 0600)
+	assert.NoError(t, err)
+	store.sets = 0
+
+	_, err = hackpadfs.WriteFile(f, []byte("foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, store.sets)
+	assert.NoError(t, f.Close())
+	assert.Equal(t, 1, store.sets)
+}
+
+func TestFileSparseWrite(t *testing.T) {
+	t.Parallel()
+	store := mem.NewStore()
+	fs, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	f, err := fs.OpenFile("foo", hackpadfs.FlagReadWrite|hackpadfs.FlagCreate, 0600)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("start"))
+	assert.NoError(t, err)
+	const offset = 1 << 40 // 1 TiB of zeros, unless stored sparsely
+	_, err = hackpadfs.WriteAtFile(f, []byte("end"), offset)
+	assert.NoError(t, err)
+
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(offset+3), info.Size())
+	buf := make([]byte, 5)
+	n, err := hackpadfs.ReadAtFile(f, buf, offset-2)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "\x00\x00end", string(buf))
+	_, err = hackpadfs.ReadAtFile(f, buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "start", string(buf))
+	assert.NoError(t, f.Close())
+
+	record, err := store.Get(context.Background(), "foo")
+	assert.NoError(t, err)
+	data, err := record.Data()
+	assert.NoError(t, err)
+	assert.IsType(t, &blob.Sparse{}, data)
+}
+
+// composeAccents is a stand-in for norm.NFC.String, composing the only decomposed characters used in these tests
+var composeAccents = strings.NewReplacer("e\u0301", "\u00e9").Replace
+
+func TestFileNormalizeNames(t *testing.T) {
+	t.Parallel()
+	const (
+		nfc = "caf\u00e9"  // composed é
+		nfd = "cafe\u0301" // e followed by a combining acute accent
+	)
+	fs, err := keyvalue.NewFSWithOptions(mem.NewStore(), keyvalue.Options{NormalizeName: composeAccents})
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Mkdir(nfd, 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, nfd+"/"+nfd, []byte("coffee"), 0600))
+
+	contents, err := hackpadfs.ReadFile(fs, nfc+"/"+nfc)
+	assert.NoError(t, err)
+	assert.Equal(t, "coffee", string(contents))
+	entries, err := hackpadfs.ReadDir(fs, nfd)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, nfc, entries[0].Name())
+
+	assert.NoError(t, fs.Rename(nfc+"/"+nfc, nfd+"/"+nfd))
+	_, err = fs.Stat(nfc + "/" + nfc)
+	assert.NoError(t, err)
+
+	_, err = hackpadfs.OpenFile(fs, nfc, hackpadfs.FlagCreate|hackpadfs.FlagExclusive, 0600)
+	assert.ErrorIs(t, hackpadfs.ErrExist, err)
+}
+
+func TestFileWriteAfterOtherHandleReplacedContents(t *testing.T) {
+	t.Parallel()
+	srcFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(srcFS, "foo", []byte("hello world"), 0600))
+	fs, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", nil, 0600))
+
+	f1, err := fs.OpenFile("foo", hackpadfs.FlagWriteOnly, 0)
+	assert.NoError(t, err)
+	f2, err := fs.OpenFile("foo", hackpadfs.FlagWriteOnly, 0)
+	assert.NoError(t, err)
+	src, err := srcFS.Open("foo")
+	assert.NoError(t, err)
+	_, err = io.Copy(f1.(io.Writer), src) // f1 takes over src's contents
+	assert.NoError(t, err)
+	assert.NoError(t, src.Close())
+	_, err = hackpadfs.WriteAtFile(f2, []byte("HELLO"), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, f1.Close())
+	assert.NoError(t, f2.Close())
+
+	contents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO world", string(contents))
+}
+
+func TestFileVersionConflict(t *testing.T) {
+	t.Parallel()
+	store := mem.NewStore()
+	fs1, err := keyvalue.NewFSWithOptions(store, keyvalue.Options{BufferWrites: true})
+	assert.NoError(t, err)
+	fs2, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs1, "foo", []byte("foo"), 0600))
+
+	f, err := fs1.OpenFile("foo", hackpadfs.FlagWriteOnly, 0)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("bar"))
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs2, "foo", []byte("baz"), 0600))
+	err = f.Close()
+	assert.ErrorIs(t, keyvalue.ErrVersionConflict, err)
+
+	contents, err := hackpadfs.ReadFile(fs1, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(contents))
+}
+
+func TestFileBufferWritesThenMetadata(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFSWithOptions(mem.NewStore(), keyvalue.Options{BufferWrites: true})
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	f, err := fs.OpenFile("foo", hackpadfs.FlagWriteOnly, 0)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("bar"))
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.ChmodFile(f, 0640))
+	_, err = hackpadfs.WriteFile(f, []byte("baz"))
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.ChtimesFile(f, modTime, modTime))
+	assert.NoError(t, f.Close())
+
+	contents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "barbaz", string(contents))
+	info, err := fs.Stat("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0640), info.Mode())
+	assert.Equal(t, modTime, info.ModTime().UTC())
+}
+
+// hashStore reports the SHA-256 checksum of each regular file it returns
+type hashStore struct {
+	keyvalue.Store
+}
+
+func (h *hashStore) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
+	record, err := h.Store.Get(ctx, path)
+	if err != nil || !record.Mode().IsRegular() {
+		return record, err
+	}
+	data, err := record.Data()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data.Bytes())
+	return keyvalue.WithHash(record, "sha256", sum[:]), nil
+}
+
+func TestFileHash(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(&hashStore{Store: mem.NewStore()})
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("bar"), 0600))
+	assert.NoError(t, fs.Mkdir("dir", 0700))
+
+	info, err := fs.Stat("foo")
+	assert.NoError(t, err)
+	algo, sum, ok := keyvalue.Hash(info)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "sha256", algo)
+	expectedSum := sha256.Sum256([]byte("bar"))
+	assert.Equal(t, expectedSum[:], sum)
+
+	info, err = fs.Stat("dir")
+	assert.NoError(t, err)
+	_, _, ok = keyvalue.Hash(info)
+	assert.Equal(t, false, ok)
+}
+
+func TestFileReadDirListing(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(mem.NewStore())
+	assert.NoError(t, err)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, name, nil, 0600))
+	}
+	names := func(entries []hackpadfs.DirEntry) []string {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	dir, err := fs.Open(".")
+	assert.NoError(t, err)
+	entries, err := hackpadfs.ReadDirFile(dir, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, names(entries))
+
+	// changes after the first batch don't shift the rest of the listing
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "0", nil, 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "bb", nil, 0600))
+	assert.NoError(t, fs.Remove("b"))
+	entries, err = hackpadfs.ReadDirFile(dir, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, names(entries))
+	entries, err = hackpadfs.ReadDirFile(dir, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d"}, names(entries))
+	_, err = hackpadfs.ReadDirFile(dir, 1)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = hackpadfs.SeekFile(dir, 0, io.SeekStart)
+	assert.NoError(t, err)
+	entryNames, err := hackpadfs.ReadDirNamesFile(dir, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0", "a", "bb", "c", "d"}, entryNames)
+	assert.NoError(t, dir.Close())
+}
@@ -5,22 +5,63 @@ import (
 	"context"
 	"errors"
 	"path"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/pathlock"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
 )
 
 const chmodBits = hackpadfs.ModePerm | hackpadfs.ModeSetuid | hackpadfs.ModeSetgid | hackpadfs.ModeSticky // Only a subset of bits are allowed to be changed. Documented under os.Chmod()
 
 // FS wraps a Store as a file system.
 type FS struct {
-	store *transactionOnly
+	store   *transactionOnly
+	options Options
+
+	pathlock pathlock.Mutex // pathlock serializes writes to each file's contents between handles
+}
+
+// Options contain options for creating an FS
+type Options struct {
+	// BufferWrites holds changes to a file's contents in its open file handle, saving them to the store on Sync() or Close() instead of on every write.
+	// Significantly reduces store round trips for many small writes, but other file handles won't see the changes until they're saved.
+	// Files opened with FlagSync or FlagAppend always save on every write, so appends from other handles aren't overwritten.
+	BufferWrites bool
+	// NormalizeName converts each file path before it reaches the store, if set.
+	// For example, norm.NFC.String from golang.org/x/text/unicode/norm makes names written in either composed or decomposed form, like file names copied from macOS, refer to the same file.
+	// Files stored before setting this option keep their original names.
+	NormalizeName func(name string) string
+	// Hooks run around each of the FS's reads from and writes to the store, like to encrypt file contents before storing them.
+	Hooks Hooks
+	// UpdateDirModTime sets a directory's modified time when an entry is created in, removed from, or renamed into or out of it, like POSIX file systems.
+	// The directory is rewritten in the same transaction as the change, adding a read and a write to each of these operations.
+	UpdateDirModTime bool
+	// UnsortedReadDir returns directory entries from file.ReadDir() and file.ReadDirNames() in the store's order, instead of sorted by name.
+	// Skips sorting each directory listing, which is faster for large directories when the order doesn't matter.
+	UnsortedReadDir bool
+	// Namespace stores every record under this path in the store, like a tenant ID, so independent FSs can share one store. The FS's root is stored at the namespace itself.
+	// Use ListNamespace() and DeleteNamespace() to manage a namespace's records. Don't share a store between FSs with and without a namespace, since the FS without one could list or remove every namespace.
+	Namespace string
 }
 
 // NewFS returns a new FS wrapping the given 'store'.
 func NewFS(store Store) (*FS, error) {
+	return NewFSWithOptions(store, Options{})
+}
+
+// NewFSWithOptions returns a new FS wrapping the given 'store' and configured by 'options'.
+// Fails with hackpadfs.ErrInvalid if options.Namespace isn't a valid path, or is ".".
+func NewFSWithOptions(store Store, options Options) (*FS, error) {
+	if !validNamespace(options.Namespace) {
+		return nil, &hackpadfs.PathError{Op: "newfs", Path: options.Namespace, Err: hackpadfs.ErrInvalid}
+	}
 	fs := &FS{
-		store: newFSTransactioner(store),
+		store:   newFSTransactioner(store, options),
+		options: options,
 	}
 	err := fs.Mkdir(".", 0666)
 	return fs, ignoreErrExist(err)
@@ -43,7 +84,7 @@ func (fs *FS) wrapperErr(op string, path string, err error) error {
 // Mkdir implements hackpadfs.MkdirFS
 func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
 	file := fs.newDir(name, perm)
-	_, err := fs.Stat(name)
+	_, err := fs.stat("mkdir", name)
 	switch {
 	case err == nil:
 		return fs.wrapperErr("mkdir", name, hackpadfs.ErrExist)
@@ -51,12 +92,12 @@ func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
 		return err
 	}
 	if name != "." {
-		_, err := fs.Stat(path.Dir(name))
+		_, err := fs.stat("mkdir", path.Dir(name))
 		if err != nil {
 			return fs.wrapperErr("mkdir", name, err)
 		}
 	}
-	return fs.wrapperErr("mkdir", name, file.save())
+	return fs.wrapperErr("mkdir", name, file.create("mkdir"))
 }
 
 func (fs *FS) newDir(name string, perm hackpadfs.FileMode) *file {
@@ -72,7 +113,7 @@ func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
 	for i := len(missingDirs) - 1; i >= 0; i-- { // missingDirs are in reverse order
 		name := missingDirs[i]
 		file := fs.newDir(name, perm)
-		err := file.save()
+		err := file.create("mkdirall")
 		err = fs.wrapperErr("mkdirall", name, err)
 		err = ignoreErrExist(err)
 		if err != nil {
@@ -82,10 +123,10 @@ func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
 	return nil
 }
 
-func statAll(store *transactionOnly, paths []string) ([]hackpadfs.FileInfo, []error) {
+func statAll(store *transactionOnly, op string, paths []string) ([]hackpadfs.FileInfo, []error) {
 	infos := make([]hackpadfs.FileInfo, len(paths))
 	errs := make([]error, len(paths))
-	results, err := getFileRecords(store, paths)
+	results, err := getFileRecords(store, op, paths)
 	if err != nil {
 		return nil, []error{err}
 	}
@@ -97,7 +138,7 @@ func statAll(store *transactionOnly, paths []string) ([]hackpadfs.FileInfo, []er
 	return infos, errs
 }
 
-func (fs *FS) getFiles(paths ...string) ([]*file, []error) {
+func (fs *FS) getFiles(op string, paths ...string) ([]*file, []error) {
 	files := make([]*file, len(paths))
 	errs := make([]error, len(paths))
 	for _, path := range paths {
@@ -107,7 +148,7 @@ func (fs *FS) getFiles(paths ...string) ([]*file, []error) {
 		}
 	}
 
-	results, err := getFileRecords(fs.store, paths)
+	results, err := getFileRecords(fs.store, op, paths)
 	if err != nil {
 		errs[0] = err
 		return files, errs
@@ -119,14 +160,15 @@ func (fs *FS) getFiles(paths ...string) ([]*file, []error) {
 				runOnceFileRecord: runOnceFileRecord{record: result},
 				path:              paths[i],
 				fs:                fs,
+				version:           recordVersion(result),
 			},
 		}, err
 	}
 	return files, errs
 }
 
-func getFileRecords(store *transactionOnly, paths []string) ([]OpResult, error) {
-	txn, err := store.Transaction(TransactionOptions{
+func getFileRecords(store *transactionOnly, op string, paths []string) ([]OpResult, error) {
+	txn, err := store.Transaction(op, TransactionOptions{
 		Mode: TransactionReadOnly,
 	})
 	if err != nil {
@@ -149,7 +191,7 @@ func (fs *FS) findMissingDirs(name string) ([]string, error) {
 		paths = append(paths, currentPath)
 	}
 	paths = append(paths, fsRootPath)
-	infos, errs := statAll(fs.store, paths)
+	infos, errs := statAll(fs.store, "mkdirall", paths)
 
 	var missingDirs []string
 	for i := range paths {
@@ -190,44 +232,52 @@ func (fs *FS) Open(name string) (hackpadfs.File, error) {
 
 // OpenFile implements hackpadfs.OpenFileFS
 func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (afFile hackpadfs.File, retErr error) {
+	flags := hackpadfs.ParseFlags(flag)
+	if err := flags.Validate(); err != nil {
+		return nil, fs.wrapperErr("open", name, err)
+	}
 	paths := []string{name}
-	if flag&hackpadfs.FlagCreate != 0 {
+	if flags.Create {
 		paths = append(paths, path.Dir(name))
 	}
-	files, errs := fs.getFiles(paths...)
+	files, errs := fs.getFiles("open", paths...)
 	storeFile, err := files[0], errs[0]
 	switch {
 	case err == nil:
-		if storeFile.info().IsDir() && flag&(hackpadfs.FlagCreate|hackpadfs.FlagWriteOnly) != 0 {
+		if flags.Create && flags.Exclusive {
+			return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrExist}
+		}
+		if storeFile.info().IsDir() && (flags.Create || (flags.Write && !flags.Read)) {
 			// write-only or create on a directory isn't allowed on hackpadfs.OpenFile
 			return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrIsDir}
 		}
 		storeFile.flag = flag
-	case errors.Is(err, hackpadfs.ErrNotExist) && flag&hackpadfs.FlagCreate != 0:
+		storeFile.offset = hackpadfs.NewFileOffset(flag)
+	case errors.Is(err, hackpadfs.ErrNotExist) && flags.Create:
 		// require parent directory
 		err := errs[1]
 		if err != nil {
 			return nil, fs.wrapperErr("open", name, err)
 		}
 		storeFile = fs.newFile(name, flag, perm&hackpadfs.ModePerm)
-		if err := storeFile.save(); err != nil {
+		if err := storeFile.create("open"); err != nil {
 			return nil, fs.wrapperErr("open", name, err)
 		}
 	default:
 		return nil, fs.wrapperErr("open", name, err)
 	}
 
+	// every write is saved to the store immediately unless Options.BufferWrites is set, which FlagSync overrides per file
 	var file hackpadfs.File = storeFile
 	switch {
-	case flag&hackpadfs.FlagWriteOnly != 0:
+	case flags.Read && flags.Write:
+	case flags.Write:
 		file = &writeOnlyFile{storeFile}
-	case flag&hackpadfs.FlagReadWrite != 0:
 	default:
-		// hackpadfs.FlagReadOnly = 0
 		file = &readOnlyFile{storeFile}
 	}
 
-	if flag&hackpadfs.FlagTruncate != 0 {
+	if flags.Truncate {
 		return file, fs.wrapperErr("open", name, hackpadfs.TruncateFile(file, 0))
 	}
 	return file, nil
@@ -235,13 +285,13 @@ func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (afFile h
 
 // Remove implements hackpadfs.RemoveFS
 func (fs *FS) Remove(name string) error {
-	file, err := fs.getFile(name)
+	file, err := fs.getFile("remove", name)
 	if err != nil {
 		return fs.wrapperErr("remove", name, err)
 	}
 
 	if file.Mode().IsDir() {
-		dirNames, err := file.ReadDirNames()
+		dirNames, err := file.fileData.ReadDirNames()
 		if err != nil {
 			return err
 		}
@@ -249,12 +299,61 @@ func (fs *FS) Remove(name string) error {
 			return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrNotEmpty}
 		}
 	}
-	return fs.setFile(name, nil)
+	return fs.deleteFile("remove", name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+// Removes 'name' and everything inside it in a single transaction.
+func (fs *FS) RemoveAll(name string) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "removeall", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	txn, err := fs.store.Transaction("removeall", TransactionOptions{Mode: TransactionReadWrite})
+	if err != nil {
+		return fs.wrapperErr("removeall", name, err)
+	}
+	existsOp := OpID(-1)
+	if fs.options.UpdateDirModTime && name != "." {
+		existsOp = txn.GetHandler(name, OpHandlerFunc(func(txn Transaction, result OpResult) error {
+			if result.Err == nil { // only removing an existing file changes its parent
+				fs.touchParentsTxn(txn, OpHandlerFunc(noopHandler), name)
+			}
+			return nil
+		}))
+	}
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	txn.ListPrefixHandler(prefix, OpHandlerFunc(func(txn Transaction, result OpResult) error {
+		if result.Err != nil {
+			return result.Err
+		}
+		for i := len(result.Paths) - 1; i >= 0; i-- { // remove children before their parents
+			if p := result.Paths[i]; p != "." {
+				txn.Delete(p)
+			}
+		}
+		if name != "." {
+			txn.Delete(name)
+		}
+		return nil
+	}))
+	results, err := txn.Commit(context.Background())
+	for _, result := range results {
+		if result.Op == existsOp && errors.Is(result.Err, hackpadfs.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			err = result.Err
+		}
+	}
+	return fs.wrapperErr("removeall", name, err)
 }
 
 // Rename implements hackpadfs.RenameFS
 func (fs *FS) Rename(oldname, newname string) error {
-	oldFile, err := fs.getFile(oldname)
+	oldFile, err := fs.getFile("rename", oldname)
 	if err != nil {
 		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrNotExist}
 	}
@@ -262,20 +361,29 @@ func (fs *FS) Rename(oldname, newname string) error {
 	if err != nil {
 		return err
 	}
+	if !oldInfo.IsDir() && fs.store.storePath(oldname) == fs.store.storePath(newname) {
+		return nil
+	}
+	// require parent directory
+	newParent, err := fs.getFile("rename", path.Dir(newname))
+	switch {
+	case err != nil:
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	case !newParent.Mode().IsDir():
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrNotDir}
+	}
 	if !oldInfo.IsDir() {
-		if oldname == newname {
-			return nil
-		}
 		contents, err := oldFile.fileData.Data()
 		if err != nil {
 			return err
 		}
-		txn, err := fs.store.Transaction(TransactionOptions{Mode: TransactionReadWrite})
+		txn, err := fs.store.Transaction("rename", TransactionOptions{Mode: TransactionReadWrite})
 		if err == nil {
 			err = fs.setFileTxn(txn, newname, oldFile.fileData, contents)
 		}
 		if err == nil {
-			err = fs.setFileTxn(txn, oldname, nil, nil)
+			txn.Delete(oldname)
+			fs.touchParentsTxn(txn, OpHandlerFunc(noopHandler), oldname, newname)
 		}
 		if err != nil {
 			_ = txn.Abort()
@@ -285,56 +393,378 @@ func (fs *FS) Rename(oldname, newname string) error {
 		return err
 	}
 
-	_, err = fs.getFile(newname)
+	_, err = fs.getFile("rename", newname)
 	if !errors.Is(err, hackpadfs.ErrNotExist) {
 		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrExist}
 	}
 
-	files, err := oldFile.ReadDirNames()
+	if oldname == "." || strings.HasPrefix(newname, oldname+"/") {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrInvalid} // can't move a directory inside itself
+	}
+	err = fs.renameDir(oldname, newname, oldFile)
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+// renameDir moves the directory 'oldname' and everything inside it to 'newname' in one transaction.
+// If a change fails, the transaction is rolled back to a savepoint or aborted, so neither tree is left partially moved.
+func (fs *FS) renameDir(oldname, newname string, oldDir *file) error {
+	oldPrefix := oldname + "/"
+	paths, err := fs.listPrefix("rename", oldPrefix)
 	if err != nil {
 		return err
 	}
-	err = fs.setFile(newname, oldFile.fileData)
+	children, errs := fs.getFiles("rename", paths...)
+	contents := make([]blob.Blob, len(paths))
+	for i := range paths {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		if children[i].Mode().IsRegular() {
+			contents[i], err = children[i].fileData.Data()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	txn, hasSavepoints, err := fs.store.savepointTransaction("rename", TransactionOptions{Mode: TransactionReadWrite})
 	if err != nil {
 		return err
 	}
-	for _, name := range files {
-		err := fs.Rename(path.Join(oldname, name), path.Join(newname, name))
+	var savepoint Savepoint
+	if hasSavepoints {
+		savepoint, err = txn.(SavepointTransaction).Savepoint()
 		if err != nil {
-			// TODO don't leave destination in corrupted state (missing file records for dir names)
+			_ = txn.Abort()
 			return err
 		}
 	}
-	return fs.setFile(oldname, nil)
+	var (
+		errMu    sync.Mutex
+		firstErr error
+	)
+	failed := func() error {
+		errMu.Lock()
+		defer errMu.Unlock()
+		return firstErr
+	}
+	undoOnErr := OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		errMu.Lock()
+		defer errMu.Unlock()
+		if result.Err == nil || firstErr != nil {
+			return nil
+		}
+		firstErr = result.Err
+		if hasSavepoints {
+			if err := txn.(SavepointTransaction).RollbackTo(savepoint); err != nil {
+				firstErr = err
+			}
+			return nil
+		}
+		return txn.Abort()
+	})
+
+	txn.SetHandler(newname, oldDir.fileData, nil, undoOnErr)
+	for i, p := range paths {
+		if failed() != nil {
+			break
+		}
+		txn.SetHandler(path.Join(newname, strings.TrimPrefix(p, oldPrefix)), children[i].fileData, contents[i], undoOnErr)
+	}
+	for i := len(paths) - 1; i >= 0 && failed() == nil; i-- { // remove children before their parents
+		txn.DeleteHandler(paths[i], undoOnErr)
+	}
+	if failed() == nil {
+		txn.DeleteHandler(oldname, undoOnErr)
+	}
+	if failed() == nil {
+		fs.touchParentsTxn(txn, undoOnErr, oldname, newname)
+	}
+	_, err = txn.Commit(context.Background())
+	if err := failed(); err != nil {
+		return err
+	}
+	return err
+}
+
+// listPrefix returns the store paths beginning with 'prefix'
+func (fs *FS) listPrefix(op, prefix string) ([]string, error) {
+	txn, err := fs.store.Transaction(op, TransactionOptions{Mode: TransactionReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	txn.ListPrefix(prefix)
+	results, err := txn.Commit(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return results[0].Paths, results[0].Err
+}
+
+// Close implements hackpadfs.CloserFS
+// Closes the store if it has a Close() method, like a database connection. Stores without one are left untouched, so several FSs can share them.
+func (fs *FS) Close() error {
+	closer, ok := fs.store.store.(interface{ Close() error })
+	if !ok {
+		return nil
+	}
+	if err := closer.Close(); err != nil {
+		return &hackpadfs.PathError{Op: "close", Path: ".", Err: err}
+	}
+	return nil
+}
+
+// Ping implements hackpadfs.PingFS
+// Uses the store's Ping() if it's a PingStore, otherwise reads the root directory's record.
+func (fs *FS) Ping(ctx context.Context) error {
+	var err error
+	if store, ok := fs.store.store.(PingStore); ok {
+		err = store.Ping(ctx)
+	} else {
+		err = fs.pingRoot(ctx)
+	}
+	if err != nil {
+		return &hackpadfs.PathError{Op: "ping", Path: ".", Err: err}
+	}
+	return nil
+}
+
+func (fs *FS) pingRoot(ctx context.Context) error {
+	txn, err := fs.store.Transaction("ping", TransactionOptions{Mode: TransactionReadOnly})
+	if err != nil {
+		return err
+	}
+	txn.Get(".")
+	results, err := txn.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	return results[0].Err
+}
+
+// DiskUsage implements hackpadfs.UsageFS
+// Uses the store's DiskUsage() if it's a UsageStore, otherwise reads every record under 'root' in one transaction.
+func (fs *FS) DiskUsage(root string) (files, dirs, bytes int64, err error) {
+	if !hackpadfs.ValidPath(root) {
+		return 0, 0, 0, &hackpadfs.PathError{Op: "diskusage", Path: root, Err: hackpadfs.ErrInvalid}
+	}
+	if store, ok := fs.store.store.(UsageStore); ok && fs.store.hooks == nil { // hooks may transform contents, so stored sizes can differ from the FS's
+		files, dirs, bytes, err = store.DiskUsage(context.Background(), fs.store.storePath(root))
+		return files, dirs, bytes, fs.wrapperErr("diskusage", root, err)
+	}
+
+	rootFile, err := fs.getFile("diskusage", root)
+	if err != nil {
+		return 0, 0, 0, fs.wrapperErr("diskusage", root, err)
+	}
+	infos := []hackpadfs.FileInfo{rootFile.info()}
+	if rootFile.Mode().IsDir() {
+		prefix := root + "/"
+		if root == "." {
+			prefix = ""
+		}
+		paths, err := fs.listPrefix("diskusage", prefix)
+		if err != nil {
+			return 0, 0, 0, fs.wrapperErr("diskusage", root, err)
+		}
+		paths = removeString(paths, ".") // listing everything includes the root
+		if len(paths) > 0 {
+			children, errs := fs.getFiles("diskusage", paths...)
+			for i := range paths {
+				switch {
+				case errors.Is(errs[i], hackpadfs.ErrNotExist): // removed since listing
+				case errs[i] != nil:
+					return 0, 0, 0, fs.wrapperErr("diskusage", root, errs[i])
+				default:
+					infos = append(infos, children[i].info())
+				}
+			}
+		}
+	}
+	for _, info := range infos {
+		switch {
+		case info.IsDir():
+			dirs++
+		case info.Mode().IsRegular():
+			files++
+			bytes += info.Size()
+		default:
+			files++
+		}
+	}
+	return files, dirs, bytes, nil
+}
+
+func removeString(values []string, s string) []string {
+	for i, value := range values {
+		if value == s {
+			return append(values[:i:i], values[i+1:]...)
+		}
+	}
+	return values
 }
 
 // Stat implements hackpadfs.StatFS
 func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
-	file, err := fs.getFile(name)
+	return fs.stat("stat", name)
+}
+
+// stat is Stat for the FS operation 'op'
+func (fs *FS) stat(op, name string) (hackpadfs.FileInfo, error) {
+	file, err := fs.getFile(op, name)
 	if err != nil {
-		return nil, fs.wrapperErr("stat", name, err)
+		return nil, fs.wrapperErr(op, name, err)
 	}
 	return file.info(), nil
 }
 
+// SameFile implements hackpadfs.SameFileFS
+// FileInfos from this FS describe the same file if they have the same ID. If the store doesn't keep IDs, they're compared by path, since an FS has no hard links.
+func (fs *FS) SameFile(fi1, fi2 hackpadfs.FileInfo) bool {
+	info1, ok1 := fi1.(fileInfo)
+	info2, ok2 := fi2.(fileInfo)
+	if !ok1 || !ok2 {
+		return false
+	}
+	id1, id2 := info1.ID(), info2.ID()
+	if id1 != "" || id2 != "" {
+		return id1 == id2
+	}
+	return fs.store.storePath(info1.Path) == fs.store.storePath(info2.Path)
+}
+
 // Chmod implements hackpadfs.ChmodFS
 func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
-	file, err := fs.getFile(name)
+	file, err := fs.getFile("chmod", name)
 	if err != nil {
 		return fs.wrapperErr("chmod", name, err)
 	}
 
 	newMode := (file.Mode() & ^chmodBits) | (mode & chmodBits)
 	file.modeOverride = &newMode
-	return file.save()
+	return file.saveMetadata("chmod")
 }
 
 // Chtimes implements hackpadfs.ChtimesFS
 func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
-	file, err := fs.getFile(name)
+	file, err := fs.getFile("chtimes", name)
 	if err != nil {
 		return fs.wrapperErr("chtimes", name, err)
 	}
 	file.modTimeOverride = mtime
-	return file.save()
+	return file.saveMetadata("chtimes")
+}
+
+// ApplyMetadata implements hackpadfs.ApplyMetadataFS
+// If the store is a TransactionStore, every file is changed in a single transaction, which is aborted if any of them fails.
+// Otherwise, each file's mode and modified time are saved together, in one write per file. Owners aren't supported.
+func (fs *FS) ApplyMetadata(metadata map[string]hackpadfs.Metadata) error {
+	names := make([]string, 0, len(metadata))
+	for name, m := range metadata {
+		if m.SetOwner {
+			return fs.wrapperErr("applymetadata", name, hackpadfs.ErrNotImplemented)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if _, ok := fs.store.store.(TransactionStore); !ok {
+		for _, name := range names {
+			file, err := fs.getFile("applymetadata", name)
+			if err != nil {
+				return fs.wrapperErr("applymetadata", name, err)
+			}
+			mode, modTime := applyMetadata(file.Mode(), file.ModTime(), metadata[name])
+			file.modeOverride = &mode
+			file.modTimeOverride = modTime
+			if err := file.saveMetadata("applymetadata"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	txn, hasSavepoints, err := fs.store.savepointTransaction("applymetadata", TransactionOptions{Mode: TransactionReadWrite})
+	if err != nil {
+		return err
+	}
+	var savepoint Savepoint
+	if hasSavepoints {
+		savepoint, err = txn.(SavepointTransaction).Savepoint()
+		if err != nil {
+			_ = txn.Abort()
+			return err
+		}
+	}
+	var (
+		errMu    sync.Mutex
+		firstErr error
+	)
+	failed := func() error {
+		errMu.Lock()
+		defer errMu.Unlock()
+		return firstErr
+	}
+	undoOnErr := func(name string, err error) error {
+		errMu.Lock()
+		defer errMu.Unlock()
+		if err == nil || firstErr != nil {
+			return nil
+		}
+		firstErr = fs.wrapperErr("applymetadata", name, err)
+		if hasSavepoints {
+			if err := txn.(SavepointTransaction).RollbackTo(savepoint); err != nil {
+				firstErr = err
+			}
+			return nil
+		}
+		return txn.Abort()
+	}
+
+	for _, name := range names {
+		if failed() != nil {
+			break
+		}
+		name := name
+		txn.GetHandler(name, OpHandlerFunc(func(txn Transaction, result OpResult) error {
+			err := result.Err
+			var contents blob.Blob
+			if err == nil && result.Record.Mode().IsRegular() {
+				contents, err = result.Record.Data()
+			}
+			if err != nil {
+				return undoOnErr(name, err)
+			}
+			record := result.Record
+			mode, modTime := applyMetadata(record.Mode(), record.ModTime(), metadata[name])
+			txn.SetHandler(name, WithID(NewBaseFileRecord(record.Size(), modTime, mode, record.Sys(),
+				record.Data,
+				record.ReadDirNames,
+			), RecordID(record)), contents, OpHandlerFunc(func(_ Transaction, result OpResult) error {
+				return undoOnErr(name, result.Err)
+			}))
+			return nil
+		}))
+	}
+	if err := failed(); err != nil && !hasSavepoints {
+		return err // the transaction was aborted
+	}
+	_, err = txn.Commit(context.Background())
+	if err := failed(); err != nil {
+		return err
+	}
+	return err
+}
+
+// applyMetadata returns the mode and modified time of a file with 'mode' and 'modTime' after applying 'm'
+func applyMetadata(mode hackpadfs.FileMode, modTime time.Time, m hackpadfs.Metadata) (hackpadfs.FileMode, time.Time) {
+	if m.SetMode {
+		mode = (mode & ^chmodBits) | (m.Mode & chmodBits)
+	}
+	if !m.Mtime.IsZero() {
+		modTime = m.Mtime
+	}
+	return mode, modTime
 }
@@ -2,8 +2,10 @@ package keyvalue_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/keyvalue"
 	"github.com/hack-pad/hackpadfs/mem"
 )
 
@@ -18,7 +20,114 @@ func TestFS(t *testing.T) {
 			}
 			return fs
 		},
+		Constraints: fstest.Constraints{SortedReadDir: true},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestFSBufferWrites(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "keyvalue buffered",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := keyvalue.NewFSWithOptions(mem.NewStore(), keyvalue.Options{BufferWrites: true})
+			if err != nil {
+				tb.Fatal(err)
+			}
+			return fs
+		},
+		Constraints: fstest.Constraints{SortedReadDir: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestFSNormalizeNames(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "keyvalue normalized",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := keyvalue.NewFSWithOptions(mem.NewStore(), keyvalue.Options{NormalizeName: composeAccents})
+			if err != nil {
+				tb.Fatal(err)
+			}
+			return fs
+		},
+		Constraints: fstest.Constraints{SortedReadDir: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestFSUpdateDirModTime(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "keyvalue dir modtime",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := keyvalue.NewFSWithOptions(mem.NewStore(), keyvalue.Options{UpdateDirModTime: true})
+			if err != nil {
+				tb.Fatal(err)
+			}
+			return fs
+		},
+		Constraints: fstest.Constraints{DirModTime: true, SortedReadDir: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestFSCopyingStore(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "keyvalue copying store",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := keyvalue.NewFS(&copyingStore{Store: mem.NewStore()})
+			if err != nil {
+				tb.Fatal(err)
+			}
+			return fs
+		},
+		Constraints: fstest.Constraints{SortedReadDir: true},
+		OpTimeout:   time.Minute, // copyingStore runs transactions serially, so fail fast if they deadlock
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestFSNamespace(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name     string
+		newStore func() keyvalue.Store
+	}{
+		{name: "keyvalue namespace", newStore: func() keyvalue.Store { return mem.NewStore() }},
+		{name: "keyvalue namespace copying store", newStore: func() keyvalue.Store { return &copyingStore{Store: mem.NewStore()} }},
+	} {
+		tc := tc
+		options := fstest.FSOptions{
+			Name: tc.name,
+			TestFS: func(tb testing.TB) fstest.SetupFS {
+				store := tc.newStore()
+				if _, err := keyvalue.NewFSWithOptions(store, keyvalue.Options{Namespace: "tenants/other"}); err != nil {
+					tb.Fatal(err)
+				}
+				fs, err := keyvalue.NewFSWithOptions(store, keyvalue.Options{Namespace: "tenants/fstest"})
+				if err != nil {
+					tb.Fatal(err)
+				}
+				return fs
+			},
+			Constraints: fstest.Constraints{SortedReadDir: true},
+			OpTimeout:   time.Minute,
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+		fstest.StdFS(t, options)
+	}
 }
@@ -1,13 +1,147 @@
 package keyvalue
 
+import (
+	"context"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
 type transactionOnly struct {
-	store Store
+	store     Store
+	normalize func(name string) string // normalize is nil if names aren't normalized
+	namespace string
+	hooks     *Hooks // hooks is nil if none are set
+}
+
+func newFSTransactioner(store Store, options Options) *transactionOnly {
+	t := &transactionOnly{store: store, normalize: options.NormalizeName, namespace: options.Namespace}
+	if !options.Hooks.isZero() {
+		hooks := options.Hooks
+		t.hooks = &hooks
+	}
+	return t
+}
+
+// Transaction returns a transaction for the FS operation 'op', which runs the FS's hooks
+func (t *transactionOnly) Transaction(op string, options TransactionOptions) (Transaction, error) {
+	txn, err := TransactionOrSerial(t.store, options)
+	if err != nil {
+		return nil, err
+	}
+	return t.hookTxn(t.normalizeTxn(t.namespaceTxn(txn)), op), nil
+}
+
+// savepointTransaction returns a transaction which supports savepoints, emulating them if the store runs transactions serially.
+// Returns false if the store's transactions don't support savepoints, but can still be aborted.
+func (t *transactionOnly) savepointTransaction(op string, options TransactionOptions) (Transaction, bool, error) {
+	txn, err := TransactionOrSerial(t.store, options)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := t.store.(TransactionStore); !ok {
+		txn = WithSavepoints(txn, t.store)
+	}
+	txn = t.hookTxn(t.normalizeTxn(t.namespaceTxn(txn)), op)
+	_, ok := txn.(SavepointTransaction)
+	return txn, ok, nil
+}
+
+func (t *transactionOnly) namespaceTxn(txn Transaction) Transaction {
+	if t.namespace == "" {
+		return txn
+	}
+	namespaced := &namespacedTransaction{txn: txn, namespace: t.namespace}
+	if savepointTxn, ok := txn.(SavepointTransaction); ok {
+		return &namespacedSavepointTransaction{namespaced, savepointTxn}
+	}
+	return namespaced
+}
+
+func (t *transactionOnly) normalizeTxn(txn Transaction) Transaction {
+	if t.normalize == nil {
+		return txn
+	}
+	if savepointTxn, ok := txn.(SavepointTransaction); ok {
+		return &normalizedSavepointTransaction{normalizedTransaction{savepointTxn, t.normalize}, savepointTxn}
+	}
+	return &normalizedTransaction{txn, t.normalize}
+}
+
+func (t *transactionOnly) hookTxn(txn Transaction, op string) Transaction {
+	if t.hooks == nil {
+		return txn
+	}
+	hooked := newHookedTransaction(txn, t.hooks, op)
+	if savepointTxn, ok := txn.(SavepointTransaction); ok {
+		return &hookedSavepointTransaction{hooked, savepointTxn}
+	}
+	return hooked
+}
+
+// storePath returns the path used to store 'p'
+func (t *transactionOnly) storePath(p string) string {
+	if t.normalize != nil {
+		p = t.normalize(p)
+	}
+	return namespacePath(t.namespace, p)
+}
+
+// normalizedTransaction converts all paths with 'normalize' before passing them to the underlying Transaction
+type normalizedTransaction struct {
+	txn       Transaction
+	normalize func(name string) string
+}
+
+func (n *normalizedTransaction) Get(path string) OpID {
+	return n.txn.Get(n.normalize(path))
+}
+
+func (n *normalizedTransaction) GetHandler(path string, handler OpHandler) OpID {
+	return n.txn.GetHandler(n.normalize(path), handler)
+}
+
+func (n *normalizedTransaction) Set(path string, src FileRecord, contents blob.Blob) OpID {
+	return n.txn.Set(n.normalize(path), src, contents)
+}
+
+func (n *normalizedTransaction) SetHandler(path string, src FileRecord, contents blob.Blob, handler OpHandler) OpID {
+	return n.txn.SetHandler(n.normalize(path), src, contents, handler)
+}
+
+func (n *normalizedTransaction) Delete(path string) OpID {
+	return n.txn.Delete(n.normalize(path))
+}
+
+func (n *normalizedTransaction) DeleteHandler(path string, handler OpHandler) OpID {
+	return n.txn.DeleteHandler(n.normalize(path), handler)
+}
+
+func (n *normalizedTransaction) ListPrefix(prefix string) OpID {
+	return n.txn.ListPrefix(n.normalize(prefix))
+}
+
+func (n *normalizedTransaction) ListPrefixHandler(prefix string, handler OpHandler) OpID {
+	return n.txn.ListPrefixHandler(n.normalize(prefix), handler)
+}
+
+func (n *normalizedTransaction) Commit(ctx context.Context) ([]OpResult, error) {
+	return n.txn.Commit(ctx)
+}
+
+func (n *normalizedTransaction) Abort() error {
+	return n.txn.Abort()
+}
+
+// normalizedSavepointTransaction is a normalizedTransaction which supports savepoints
+type normalizedSavepointTransaction struct {
+	normalizedTransaction
+	savepoints SavepointTransaction
 }
 
-func newFSTransactioner(store Store) *transactionOnly {
-	return &transactionOnly{store}
+func (n *normalizedSavepointTransaction) Savepoint() (Savepoint, error) {
+	return n.savepoints.Savepoint()
 }
 
-func (t *transactionOnly) Transaction(options TransactionOptions) (Transaction, error) {
-	return TransactionOrSerial(t.store, options)
+func (n *normalizedSavepointTransaction) RollbackTo(savepoint Savepoint) error {
+	return n.savepoints.RollbackTo(savepoint)
 }
@@ -0,0 +1,315 @@
+package keyvalue
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+// FsckProblem is a kind of inconsistency found by Fsck()
+type FsckProblem string
+
+const (
+	// FsckOrphan is a record whose parent directory is missing or isn't a directory, so it can't be reached from the root. Only found in stores which list their paths directly, like a TransactionStore.
+	FsckOrphan FsckProblem = "orphaned record"
+	// FsckDanglingData is a regular file whose contents can't be read from the store, like a record left pointing to deleted data.
+	FsckDanglingData FsckProblem = "dangling data"
+	// FsckInvalidMode is a record which is neither a regular file nor a directory.
+	FsckInvalidMode FsckProblem = "invalid mode"
+	// FsckBrokenEntry is a directory entry with no record of its own.
+	FsckBrokenEntry FsckProblem = "broken directory entry"
+)
+
+// FsckIssue describes an inconsistency found by Fsck()
+type FsckIssue struct {
+	Path     string // Path is the FS path of the affected file
+	Problem  FsckProblem
+	Err      error // Err is the error reading the file from the store, if any
+	Repaired bool  // Repaired is true if RepairOptions.Repair fixed the issue
+}
+
+func (i FsckIssue) String() string {
+	s := i.Path + ": " + string(i.Problem)
+	if i.Err != nil {
+		s += ": " + i.Err.Error()
+	}
+	if i.Repaired {
+		s += " (repaired)"
+	}
+	return s
+}
+
+// FsckReport summarizes the results of Fsck()
+type FsckReport struct {
+	Records int         // Records is the number of records checked
+	Issues  []FsckIssue // Issues are the inconsistencies found, sorted by path
+}
+
+// RepairOptions contain options for Fsck()
+type RepairOptions struct {
+	// Repair fixes the issues found in a single transaction, otherwise Fsck() only reports them.
+	//
+	// Orphans get their missing parent directories recreated, broken entries are deleted, dangling data is replaced with empty contents,
+	// and invalid modes become a directory if the mode includes hackpadfs.ModeDir, otherwise a regular file.
+	Repair bool
+	// RemoveOrphans deletes orphaned records and everything under them when repairing, instead of recreating their parent directories.
+	// Orphans inside a regular file are only repaired if this is set.
+	RemoveOrphans bool
+	// SkipData skips reading each regular file's contents, which is much faster for remote stores but can't find dangling data.
+	SkipData bool
+}
+
+const fsckDirPerm = 0700 // fsckDirPerm is the permission of directories recreated for orphans
+
+// Fsck checks the records of 'fs' for inconsistencies an interrupted operation can leave behind, like a partial Rename in a store without transactions.
+// Run it at startup, before other clients use the store. Returns an error if the check couldn't complete or 'ctx' is canceled.
+func Fsck(ctx context.Context, fs *FS, options RepairOptions) (FsckReport, error) {
+	txn, err := fs.store.Transaction("fsck", TransactionOptions{Mode: TransactionReadOnly})
+	if err != nil {
+		return FsckReport{}, fsckErr(err)
+	}
+	txn.ListPrefix("")
+	results, err := txn.Commit(ctx)
+	if err == nil {
+		err = results[0].Err
+	}
+	if err != nil {
+		return FsckReport{}, fsckErr(err)
+	}
+	paths := results[0].Paths
+
+	records, missing, err := fsckGetRecords(ctx, fs, paths)
+	if err != nil {
+		return FsckReport{}, fsckErr(err)
+	}
+	var issues []FsckIssue
+	for _, p := range missing {
+		issues = append(issues, FsckIssue{Path: p, Problem: FsckBrokenEntry}) // listed by walking a directory, but has no record
+	}
+
+	// find directory entries missing from the listing, like stale entries in a store's own directory index
+	var unlisted []string
+	for _, p := range paths {
+		record, ok := records[p]
+		if !ok || !record.Mode().IsDir() {
+			continue
+		}
+		names, err := record.ReadDirNames()
+		if err != nil {
+			return FsckReport{}, fsckErr(err)
+		}
+		for _, name := range names {
+			child := path.Join(p, name)
+			if _, ok := records[child]; !ok && !containsPath(missing, child) {
+				unlisted = append(unlisted, child)
+			}
+		}
+	}
+	unlistedRecords, unlistedMissing, err := fsckGetRecords(ctx, fs, unlisted)
+	if err != nil {
+		return FsckReport{}, fsckErr(err)
+	}
+	for p, record := range unlistedRecords {
+		records[p] = record
+		paths = append(paths, p)
+	}
+	for _, p := range unlistedMissing {
+		issues = append(issues, FsckIssue{Path: p, Problem: FsckBrokenEntry})
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return FsckReport{}, fsckErr(err)
+		}
+		record, ok := records[p]
+		if !ok {
+			continue
+		}
+		if p != "." {
+			if parent, ok := records[path.Dir(p)]; !ok || !parent.Mode().IsDir() {
+				issues = append(issues, FsckIssue{Path: p, Problem: FsckOrphan})
+			}
+		}
+		mode := record.Mode()
+		switch {
+		case mode.Type() != 0 && mode.Type() != hackpadfs.ModeDir:
+			issues = append(issues, FsckIssue{Path: p, Problem: FsckInvalidMode})
+		case mode.IsRegular() && !options.SkipData:
+			if _, err := record.Data(); err != nil {
+				issues = append(issues, FsckIssue{Path: p, Problem: FsckDanglingData, Err: err})
+			}
+		}
+	}
+	sort.SliceStable(issues, func(a, b int) bool {
+		return issues[a].Path < issues[b].Path
+	})
+
+	report := FsckReport{Records: len(records), Issues: issues}
+	if options.Repair && len(issues) > 0 {
+		err = fsckRepair(ctx, fs, options, records, paths, report.Issues)
+	}
+	return report, fsckErr(err)
+}
+
+// fsckGetRecords reads the records at 'paths' in one transaction, returning the records found and the paths which don't exist
+func fsckGetRecords(ctx context.Context, fs *FS, paths []string) (map[string]FileRecord, []string, error) {
+	records := make(map[string]FileRecord, len(paths))
+	if len(paths) == 0 {
+		return records, nil, nil
+	}
+	txn, err := fs.store.Transaction("fsck", TransactionOptions{Mode: TransactionReadOnly})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range paths {
+		txn.Get(p)
+	}
+	results, err := txn.Commit(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var missing []string
+	for i, result := range results {
+		switch {
+		case errors.Is(result.Err, hackpadfs.ErrNotExist):
+			missing = append(missing, paths[i])
+		case result.Err != nil:
+			return nil, nil, result.Err
+		default:
+			records[paths[i]] = result.Record
+		}
+	}
+	return records, missing, nil
+}
+
+// fsckRepair fixes 'issues' in one transaction, setting Repaired on each issue whose changes succeed
+func fsckRepair(ctx context.Context, fs *FS, options RepairOptions, records map[string]FileRecord, paths []string, issues []FsckIssue) error {
+	txn, err := fs.store.Transaction("fsck", TransactionOptions{Mode: TransactionReadWrite})
+	if err != nil {
+		return err
+	}
+	issueOps := make([][]OpID, len(issues))
+	created := make(map[string]OpID) // created holds the op creating each missing parent directory
+	removed := make(map[string]bool)
+	remove := func(i int, p string) {
+		if !removed[p] {
+			removed[p] = true
+			issueOps[i] = append(issueOps[i], txn.Delete(p))
+		}
+	}
+	for i, issue := range issues {
+		p := issue.Path
+		record := records[p]
+		switch issue.Problem {
+		case FsckBrokenEntry:
+			remove(i, p)
+		case FsckOrphan:
+			parent, parentExists := records[path.Dir(p)]
+			if options.RemoveOrphans {
+				for j := len(paths) - 1; j >= 0; j-- { // remove children before their parents
+					if strings.HasPrefix(paths[j], p+"/") {
+						remove(i, paths[j])
+					}
+				}
+				remove(i, p)
+				continue
+			}
+			if parentExists && !parent.Mode().IsDir() {
+				continue // can't make a directory without removing the file in its place
+			}
+			var missingDirs []string
+			for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+				if op, isCreated := created[dir]; isCreated {
+					issueOps[i] = append(issueOps[i], op) // an earlier orphan shares this parent
+					break
+				}
+				if _, exists := records[dir]; exists {
+					break
+				}
+				missingDirs = append(missingDirs, dir)
+			}
+			for j := len(missingDirs) - 1; j >= 0; j-- { // create parents first
+				dir := missingDirs[j]
+				created[dir] = txn.Set(dir, fs.newDir(dir, fsckDirPerm).fileData, nil)
+				issueOps[i] = append(issueOps[i], created[dir])
+			}
+		case FsckInvalidMode:
+			mode := record.Mode()
+			if mode&hackpadfs.ModeDir != 0 {
+				issueOps[i] = append(issueOps[i], txn.Set(p, fsckRecord(record, hackpadfs.ModeDir|mode.Perm(), nil), nil))
+				continue
+			}
+			contents, err := record.Data()
+			if err != nil {
+				contents = blob.NewBytes(nil)
+			}
+			issueOps[i] = append(issueOps[i], txn.Set(p, fsckRecord(record, mode.Perm(), contents), contents))
+		case FsckDanglingData:
+			contents := blob.NewBytes(nil)
+			issueOps[i] = append(issueOps[i], txn.Set(p, fsckRecord(record, record.Mode(), contents), contents))
+		}
+	}
+	results, err := txn.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	opErrs := make(map[OpID]error, len(results))
+	for _, result := range results {
+		opErrs[result.Op] = result.Err
+	}
+	for i := range issues {
+		if len(issueOps[i]) == 0 {
+			continue
+		}
+		repaired := true
+		for _, op := range issueOps[i] {
+			if err, ok := opErrs[op]; !ok || err != nil {
+				repaired = false
+			}
+		}
+		issues[i].Repaired = repaired
+	}
+	return nil
+}
+
+// fsckRecord returns a copy of 'record' with 'mode' and 'contents', keeping its ID and modified time
+func fsckRecord(record FileRecord, mode hackpadfs.FileMode, contents blob.Blob) FileRecord {
+	var size int64
+	getData := func() (blob.Blob, error) {
+		return nil, hackpadfs.ErrIsDir
+	}
+	if contents != nil {
+		size = int64(contents.Len())
+		getData = func() (blob.Blob, error) {
+			return contents, nil
+		}
+	}
+	modTime := record.ModTime()
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	return WithID(NewBaseFileRecord(size, modTime, mode, nil, getData, nil), RecordID(record))
+}
+
+func containsPath(paths []string, p string) bool {
+	for _, candidate := range paths {
+		if candidate == p {
+			return true
+		}
+	}
+	return false
+}
+
+func fsckErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &hackpadfs.PathError{Op: "fsck", Path: ".", Err: err}
+}
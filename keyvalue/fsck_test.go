@@ -0,0 +1,174 @@
+package keyvalue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func setRecord(tb testing.TB, store keyvalue.Store, p string, mode hackpadfs.FileMode, contents string) {
+	tb.Helper()
+	record := keyvalue.NewBaseFileRecord(int64(len(contents)), time.Now(), mode, nil, func() (blob.Blob, error) {
+		return blob.NewBytes([]byte(contents)), nil
+	}, nil)
+	assert.NoError(tb, store.Set(context.Background(), p, record))
+}
+
+func fsckProblems(report keyvalue.FsckReport) map[string]keyvalue.FsckProblem {
+	problems := make(map[string]keyvalue.FsckProblem)
+	for _, issue := range report.Issues {
+		problems[issue.Path] = issue.Problem
+	}
+	return problems
+}
+
+func TestFsck(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := mem.NewStore()
+	fs, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "file", []byte("file"), 0600))
+	assert.NoError(t, fs.Mkdir("dir", 0700))
+
+	report, err := keyvalue.Fsck(ctx, fs, keyvalue.RepairOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, keyvalue.FsckReport{Records: 3}, report)
+
+	// leftovers of an interrupted rename
+	setRecord(t, store, "moved/sub/a", 0600, "a")
+	setRecord(t, store, "moved/sub/b", 0600, "b")
+	setRecord(t, store, "file/child", 0600, "child")
+	setRecord(t, store, "dir/pipe", hackpadfs.ModeNamedPipe|0600, "pipe")
+	report, err = keyvalue.Fsck(ctx, fs, keyvalue.RepairOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, report.Records)
+	assert.Equal(t, map[string]keyvalue.FsckProblem{
+		"dir/pipe":    keyvalue.FsckInvalidMode,
+		"file/child":  keyvalue.FsckOrphan,
+		"moved/sub/a": keyvalue.FsckOrphan,
+		"moved/sub/b": keyvalue.FsckOrphan,
+	}, fsckProblems(report))
+
+	report, err = keyvalue.Fsck(ctx, fs, keyvalue.RepairOptions{Repair: true})
+	assert.NoError(t, err)
+	for _, issue := range report.Issues {
+		assert.Equal(t, issue.Path != "file/child", issue.Repaired) // can't create a directory in place of a file
+	}
+	contents, err := hackpadfs.ReadFile(fs, "moved/sub/b")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(contents))
+	info, err := fs.Stat("moved/sub")
+	if assert.NoError(t, err) {
+		assert.Equal(t, hackpadfs.ModeDir|0700, info.Mode())
+	}
+	info, err = fs.Stat("dir/pipe")
+	if assert.NoError(t, err) {
+		assert.Equal(t, hackpadfs.FileMode(0600), info.Mode())
+	}
+	contents, err = hackpadfs.ReadFile(fs, "dir/pipe")
+	assert.NoError(t, err)
+	assert.Equal(t, "pipe", string(contents))
+
+	report, err = keyvalue.Fsck(ctx, fs, keyvalue.RepairOptions{Repair: true, RemoveOrphans: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []keyvalue.FsckIssue{{Path: "file/child", Problem: keyvalue.FsckOrphan, Repaired: true}}, report.Issues)
+	_, err = store.Get(ctx, "file/child")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	report, err = keyvalue.Fsck(ctx, fs, keyvalue.RepairOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(report.Issues))
+}
+
+// damagedStore simulates a store whose file contents and directory listings have been damaged
+type damagedStore struct {
+	keyvalue.Store
+	danglingPath string
+	ghostName    string
+}
+
+func (s *damagedStore) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
+	record, err := s.Store.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	switch path {
+	case s.danglingPath:
+		return &danglingRecord{FileRecord: record, store: s}, nil
+	case ".":
+		return &ghostDirRecord{FileRecord: record, store: s}, nil
+	}
+	return record, err
+}
+
+func (s *damagedStore) Set(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	switch path {
+	case s.danglingPath:
+		s.danglingPath = "" // new contents are readable
+	case s.ghostName:
+		s.ghostName = ""
+	}
+	return s.Store.Set(ctx, path, src)
+}
+
+type danglingRecord struct {
+	keyvalue.FileRecord
+	store *damagedStore
+}
+
+var errDangling = errors.New("contents not found")
+
+func (r *danglingRecord) Data() (blob.Blob, error) {
+	return nil, errDangling
+}
+
+type ghostDirRecord struct {
+	keyvalue.FileRecord
+	store *damagedStore
+}
+
+func (r *ghostDirRecord) ReadDirNames() ([]string, error) {
+	names, err := r.FileRecord.ReadDirNames()
+	if r.store.ghostName != "" {
+		names = append(names, r.store.ghostName)
+	}
+	return names, err
+}
+
+func TestFsckSerial(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := &damagedStore{Store: mem.NewStore()}
+	fs, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dangling", []byte("lost"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "fine", []byte("fine"), 0600))
+	store.danglingPath, store.ghostName = "dangling", "ghost"
+
+	report, err := keyvalue.Fsck(ctx, fs, keyvalue.RepairOptions{SkipData: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []keyvalue.FsckIssue{{Path: "ghost", Problem: keyvalue.FsckBrokenEntry}}, report.Issues)
+
+	report, err = keyvalue.Fsck(ctx, fs, keyvalue.RepairOptions{Repair: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Records)
+	assert.Equal(t, []keyvalue.FsckIssue{
+		{Path: "dangling", Problem: keyvalue.FsckDanglingData, Err: errDangling, Repaired: true},
+		{Path: "ghost", Problem: keyvalue.FsckBrokenEntry, Repaired: true},
+	}, report.Issues)
+	contents, err := hackpadfs.ReadFile(fs, "dangling")
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(contents))
+
+	report, err = keyvalue.Fsck(ctx, fs, keyvalue.RepairOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(report.Issues))
+}
@@ -0,0 +1,257 @@
+package keyvalue
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+// Hooks intercept an FS's reads from and writes to its Store, so wrappers like encryption, compression, or auditing can apply path-aware policies without a custom Store.
+// Any hook may be nil.
+//
+// Each hook receives the FS operation running it, like "open", "write", or "rename", which matches the Op of errors returned by that operation, and the file's path in the FS.
+// Hooks may run concurrently for different operations.
+type Hooks struct {
+	// BeforeGet runs before a file is read from the store. Returning an error fails the read, e.g. to deny access to 'path'.
+	BeforeGet func(op, path string) error
+	// AfterGet runs after a file is read from the store, with the read's result.
+	// Returns the record for the FS to use, which may wrap 'record' to transform its Data(), or an error to fail the read.
+	AfterGet func(op, path string, record FileRecord, err error) (FileRecord, error)
+	// BeforeSet runs before a file is written to the store. 'record' and 'contents' are nil when the file is being deleted, and 'contents' is nil for directories and metadata-only writes.
+	// Returns the record and contents to write, or an error to fail the write. If the contents are replaced, the stored record's Data() and Size() match them.
+	BeforeSet func(op, path string, record FileRecord, contents blob.Blob) (FileRecord, blob.Blob, error)
+	// AfterSet runs after a file is written to or deleted from the store, with the write's error. Returns the error for the FS to report.
+	AfterSet func(op, path string, err error) error
+}
+
+func (h Hooks) isZero() bool {
+	return h.BeforeGet == nil && h.AfterGet == nil && h.BeforeSet == nil && h.AfterSet == nil
+}
+
+func (h *Hooks) beforeGet(op, path string) error {
+	if h == nil || h.BeforeGet == nil {
+		return nil
+	}
+	return h.BeforeGet(op, path)
+}
+
+func (h *Hooks) afterGet(op, path string, record FileRecord, err error) (FileRecord, error) {
+	if h == nil || h.AfterGet == nil {
+		return record, err
+	}
+	newRecord, err := h.AfterGet(op, path, record, err)
+	if versioned, ok := record.(VersionedFileRecord); ok && newRecord != nil {
+		if _, ok := newRecord.(VersionedFileRecord); !ok {
+			newRecord = WithVersion(newRecord, versioned.Version()) // keep detecting changes from other writers
+		}
+	}
+	if id := RecordID(record); id != "" && newRecord != nil && RecordID(newRecord) == "" {
+		newRecord = WithID(newRecord, id) // keep the file's identity
+	}
+	return newRecord, err
+}
+
+func (h *Hooks) beforeSet(op, path string, record FileRecord, contents blob.Blob) (FileRecord, blob.Blob, error) {
+	if h == nil || h.BeforeSet == nil {
+		return record, contents, nil
+	}
+	newRecord, newContents, err := h.BeforeSet(op, path, record, contents)
+	if err != nil || newRecord == nil {
+		return newRecord, newContents, err
+	}
+	if newContents != contents {
+		newRecord = contentsRecord{FileRecord: newRecord, contents: newContents}
+	}
+	return newRecord, newContents, nil
+}
+
+func (h *Hooks) afterSet(op, path string, err error) error {
+	if h == nil || h.AfterSet == nil {
+		return err
+	}
+	return h.AfterSet(op, path, err)
+}
+
+// contentsRecord replaces a record's contents with ones returned by Hooks.BeforeSet
+type contentsRecord struct {
+	FileRecord
+	contents blob.Blob
+}
+
+func (c contentsRecord) Data() (blob.Blob, error) {
+	return c.contents, nil
+}
+
+func (c contentsRecord) ID() string {
+	return RecordID(c.FileRecord)
+}
+
+func (c contentsRecord) Size() int64 {
+	if c.contents == nil {
+		return 0
+	}
+	return int64(c.contents.Len())
+}
+
+// hookedTransaction runs an FS operation's Hooks around each Get, Set, and Delete in 'txn'
+type hookedTransaction struct {
+	txn   Transaction
+	hooks *Hooks
+	op    string
+
+	mu      sync.Mutex
+	ops     map[OpID]hookedOp // ops holds each operation's path, to run its After hook on the result
+	results map[OpID]OpResult // results holds the results already replaced by hooks
+}
+
+type hookedOp struct {
+	path  string
+	isGet bool
+}
+
+func newHookedTransaction(txn Transaction, hooks *Hooks, op string) *hookedTransaction {
+	return &hookedTransaction{
+		txn:     txn,
+		hooks:   hooks,
+		op:      op,
+		ops:     make(map[OpID]hookedOp),
+		results: make(map[OpID]OpResult),
+	}
+}
+
+func noopHandler(txn Transaction, result OpResult) error {
+	return nil
+}
+
+// fail reserves an OpID for an operation on 'path' rejected by a Before hook, then reports 'err' as its result to 'handler' and Commit
+func (h *hookedTransaction) fail(path string, err error, handler OpHandler) OpID {
+	op := h.txn.GetHandler(path, OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		if handlerErr := handler.Handle(h, OpResult{Op: result.Op, Err: err}); handlerErr != nil {
+			return handlerErr
+		}
+		return err
+	}))
+	h.mu.Lock()
+	h.results[op] = OpResult{Op: op, Err: err}
+	h.mu.Unlock()
+	return op
+}
+
+// track records 'op' to run its After hook on its result
+func (h *hookedTransaction) track(op OpID, path string, isGet bool) OpID {
+	h.mu.Lock()
+	h.ops[op] = hookedOp{path: path, isGet: isGet}
+	h.mu.Unlock()
+	return op
+}
+
+// hookResult returns the result of 'result' after its After hook, running the hook at most once per operation
+func (h *hookedTransaction) hookResult(result OpResult, op hookedOp) OpResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hooked, ok := h.results[result.Op]; ok {
+		return hooked
+	}
+	if op.isGet {
+		result.Record, result.Err = h.hooks.afterGet(h.op, op.path, result.Record, result.Err)
+	} else {
+		result.Err = h.hooks.afterSet(h.op, op.path, result.Err)
+	}
+	h.results[result.Op] = result
+	return result
+}
+
+// handler runs the After hook for 'op' before 'handler', then reports the hooked error
+func (h *hookedTransaction) handler(op hookedOp, handler OpHandler) OpHandler {
+	return OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		result = h.hookResult(result, op)
+		if err := handler.Handle(h, result); err != nil {
+			return err
+		}
+		return result.Err
+	})
+}
+
+func (h *hookedTransaction) Get(path string) OpID {
+	return h.GetHandler(path, OpHandlerFunc(noopHandler))
+}
+
+func (h *hookedTransaction) GetHandler(path string, handler OpHandler) OpID {
+	if err := h.hooks.beforeGet(h.op, path); err != nil {
+		return h.fail(path, err, handler)
+	}
+	op := hookedOp{path: path, isGet: true}
+	return h.track(h.txn.GetHandler(path, h.handler(op, handler)), path, true)
+}
+
+func (h *hookedTransaction) Set(path string, src FileRecord, contents blob.Blob) OpID {
+	return h.SetHandler(path, src, contents, OpHandlerFunc(noopHandler))
+}
+
+func (h *hookedTransaction) SetHandler(path string, src FileRecord, contents blob.Blob, handler OpHandler) OpID {
+	src, contents, err := h.hooks.beforeSet(h.op, path, src, contents)
+	if err != nil {
+		return h.fail(path, err, handler)
+	}
+	op := hookedOp{path: path}
+	return h.track(h.txn.SetHandler(path, src, contents, h.handler(op, handler)), path, false)
+}
+
+func (h *hookedTransaction) Delete(path string) OpID {
+	return h.DeleteHandler(path, OpHandlerFunc(noopHandler))
+}
+
+func (h *hookedTransaction) DeleteHandler(path string, handler OpHandler) OpID {
+	if _, _, err := h.hooks.beforeSet(h.op, path, nil, nil); err != nil {
+		return h.fail(path, err, handler)
+	}
+	op := hookedOp{path: path}
+	return h.track(h.txn.DeleteHandler(path, h.handler(op, handler)), path, false)
+}
+
+func (h *hookedTransaction) ListPrefix(prefix string) OpID {
+	return h.txn.ListPrefix(prefix)
+}
+
+func (h *hookedTransaction) ListPrefixHandler(prefix string, handler OpHandler) OpID {
+	return h.txn.ListPrefixHandler(prefix, OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		return handler.Handle(h, result)
+	}))
+}
+
+func (h *hookedTransaction) Commit(ctx context.Context) ([]OpResult, error) {
+	results, err := h.txn.Commit(ctx)
+	for i, result := range results {
+		h.mu.Lock()
+		op, tracked := h.ops[result.Op]
+		hooked, replaced := h.results[result.Op]
+		h.mu.Unlock()
+		switch {
+		case replaced:
+			results[i] = hooked
+		case tracked:
+			results[i] = h.hookResult(result, op)
+		}
+	}
+	return results, err
+}
+
+func (h *hookedTransaction) Abort() error {
+	return h.txn.Abort()
+}
+
+// hookedSavepointTransaction is a hookedTransaction which supports savepoints.
+// Rolling back restores the stored records as they were, so it skips the hooks.
+type hookedSavepointTransaction struct {
+	*hookedTransaction
+	savepoints SavepointTransaction
+}
+
+func (h *hookedSavepointTransaction) Savepoint() (Savepoint, error) {
+	return h.savepoints.Savepoint()
+}
+
+func (h *hookedSavepointTransaction) RollbackTo(savepoint Savepoint) error {
+	return h.savepoints.RollbackTo(savepoint)
+}
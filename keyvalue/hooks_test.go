@@ -0,0 +1,156 @@
+package keyvalue_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func xorBlob(b blob.Blob) blob.Blob {
+	buf := append([]byte(nil), b.Bytes()...)
+	for i := range buf {
+		buf[i] ^= 0x5a
+	}
+	return blob.NewBytes(buf)
+}
+
+func xorHooks() keyvalue.Hooks {
+	return keyvalue.Hooks{
+		BeforeSet: func(op, path string, record keyvalue.FileRecord, contents blob.Blob) (keyvalue.FileRecord, blob.Blob, error) {
+			if contents == nil {
+				return record, contents, nil
+			}
+			return record, xorBlob(contents), nil
+		},
+		AfterGet: func(op, path string, record keyvalue.FileRecord, err error) (keyvalue.FileRecord, error) {
+			if err != nil || !record.Mode().IsRegular() {
+				return record, err
+			}
+			getData := func() (blob.Blob, error) {
+				data, err := record.Data()
+				if err != nil {
+					return nil, err
+				}
+				return xorBlob(data), nil
+			}
+			return keyvalue.NewBaseFileRecord(record.Size(), record.ModTime(), record.Mode(), record.Sys(), getData, nil), nil
+		},
+	}
+}
+
+func TestHooksTransformContents(t *testing.T) {
+	t.Parallel()
+	store := mem.NewStore()
+	fs, err := keyvalue.NewFSWithOptions(store, keyvalue.Options{Hooks: xorHooks()})
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Mkdir("dir", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/foo", []byte("hello"), 0600))
+	assert.NoError(t, fs.Rename("dir", "moved"))
+	assert.NoError(t, fs.Chmod("moved/foo", 0644))
+
+	contents, err := hackpadfs.ReadFile(fs, "moved/foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(contents))
+	record, err := store.Get(context.Background(), "moved/foo")
+	assert.NoError(t, err)
+	data, err := record.Data()
+	assert.NoError(t, err)
+	assert.Equal(t, string(xorBlob(blob.NewBytes([]byte("hello"))).Bytes()), string(data.Bytes()))
+}
+
+func TestHooksDenyRead(t *testing.T) {
+	t.Parallel()
+	store := mem.NewStore()
+	plainFS, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(plainFS, "secret", []byte("shh"), 0600))
+	fs, err := keyvalue.NewFSWithOptions(store, keyvalue.Options{
+		Hooks: keyvalue.Hooks{
+			BeforeGet: func(op, path string) error {
+				if path == "secret" {
+					return hackpadfs.ErrPermission
+				}
+				return nil
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = fs.Open("secret")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	var pathErr *hackpadfs.PathError
+	assert.ErrorAs(t, &pathErr, err)
+	assert.Equal(t, "open", pathErr.Op)
+	_, err = fs.Stat("secret")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+}
+
+func TestHooksAudit(t *testing.T) {
+	t.Parallel()
+	type change struct {
+		op, path string
+	}
+	var (
+		mu      sync.Mutex
+		changes []change
+	)
+	fs, err := keyvalue.NewFSWithOptions(mem.NewStore(), keyvalue.Options{
+		Hooks: keyvalue.Hooks{
+			AfterSet: func(op, path string, err error) error {
+				mu.Lock()
+				defer mu.Unlock()
+				changes = append(changes, change{op, path})
+				return err
+			},
+		},
+	})
+	assert.NoError(t, err)
+	changes = nil // skip creating the root directory
+
+	assert.NoError(t, fs.Mkdir("foo", 0700))
+	assert.NoError(t, fs.Rename("foo", "bar"))
+	assert.NoError(t, fs.Remove("bar"))
+	assert.Equal(t, []change{
+		{"mkdir", "foo"},
+		{"rename", "bar"},
+		{"rename", "foo"},
+		{"remove", "bar"},
+	}, changes)
+}
+
+func TestHooksDenyWriteRollsBackRename(t *testing.T) {
+	t.Parallel()
+	errDenied := errors.New("denied")
+	fs, err := keyvalue.NewFSWithOptions(mem.NewStore(), keyvalue.Options{
+		Hooks: keyvalue.Hooks{
+			BeforeSet: func(op, path string, record keyvalue.FileRecord, contents blob.Blob) (keyvalue.FileRecord, blob.Blob, error) {
+				if path == "new/b" {
+					return nil, nil, errDenied
+				}
+				return record, contents, nil
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Mkdir("old", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "old/a", []byte("a"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "old/b", []byte("b"), 0600))
+
+	err = fs.Rename("old", "new")
+	assert.ErrorIs(t, errDenied, err)
+	_, err = fs.Stat("new")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	contents, err := hackpadfs.ReadFile(fs, "old/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(contents))
+	contents, err = hackpadfs.ReadFile(fs, "old/b")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(contents))
+}
@@ -0,0 +1,217 @@
+package keyvalue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+// namespacePath returns the store path of 'p' in 'namespace'. The FS root is stored at the namespace itself.
+func namespacePath(namespace, p string) string {
+	if namespace == "" {
+		return p
+	}
+	if p == "." {
+		return namespace
+	}
+	return namespace + "/" + p
+}
+
+// fsPath returns the FS path of the store path 'p' in 'namespace'. The inverse of namespacePath().
+func fsPath(namespace, p string) string {
+	if namespace == "" {
+		return p
+	}
+	if p == namespace {
+		return "."
+	}
+	return strings.TrimPrefix(p, namespace+"/")
+}
+
+func validNamespace(namespace string) bool {
+	return namespace == "" || (hackpadfs.ValidPath(namespace) && namespace != ".")
+}
+
+// namespacedTransaction stores all paths under a namespace in the underlying Transaction
+type namespacedTransaction struct {
+	txn       Transaction
+	namespace string
+
+	mu      sync.Mutex
+	listOps map[OpID]string // listOps holds the FS prefix of each ListPrefix op, to convert their results
+}
+
+func (n *namespacedTransaction) path(p string) string {
+	return namespacePath(n.namespace, p)
+}
+
+// result converts the paths listed in 'result' back to FS paths
+func (n *namespacedTransaction) result(result OpResult, prefix string) OpResult {
+	if result.Err != nil {
+		return result
+	}
+	var paths []string
+	if prefix == "" {
+		paths = append(paths, ".") // NewFS creates the namespace's root and the FS never removes it, so list it without looking it up
+	}
+	for _, p := range result.Paths {
+		paths = append(paths, fsPath(n.namespace, p))
+	}
+	result.Paths = paths
+	return result
+}
+
+func (n *namespacedTransaction) handler(handler OpHandler) OpHandler {
+	return OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		return handler.Handle(n, result)
+	})
+}
+
+func (n *namespacedTransaction) Get(path string) OpID {
+	return n.txn.Get(n.path(path))
+}
+
+func (n *namespacedTransaction) GetHandler(path string, handler OpHandler) OpID {
+	return n.txn.GetHandler(n.path(path), n.handler(handler))
+}
+
+func (n *namespacedTransaction) Set(path string, src FileRecord, contents blob.Blob) OpID {
+	return n.txn.Set(n.path(path), src, contents)
+}
+
+func (n *namespacedTransaction) SetHandler(path string, src FileRecord, contents blob.Blob, handler OpHandler) OpID {
+	return n.txn.SetHandler(n.path(path), src, contents, n.handler(handler))
+}
+
+func (n *namespacedTransaction) Delete(path string) OpID {
+	return n.txn.Delete(n.path(path))
+}
+
+func (n *namespacedTransaction) DeleteHandler(path string, handler OpHandler) OpID {
+	return n.txn.DeleteHandler(n.path(path), n.handler(handler))
+}
+
+// listPrefix returns the store prefix for the FS path 'prefix'. Listing everything lists inside the namespace, since its root isn't under a "/" prefix.
+func (n *namespacedTransaction) listPrefix(prefix string) string {
+	if prefix == "" {
+		return n.namespace + "/"
+	}
+	return n.namespace + "/" + prefix
+}
+
+func (n *namespacedTransaction) ListPrefix(prefix string) OpID {
+	return n.ListPrefixHandler(prefix, OpHandlerFunc(noopHandler))
+}
+
+func (n *namespacedTransaction) ListPrefixHandler(prefix string, handler OpHandler) OpID {
+	op := n.txn.ListPrefixHandler(n.listPrefix(prefix), OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		return handler.Handle(n, n.result(result, prefix))
+	}))
+	n.mu.Lock()
+	if n.listOps == nil {
+		n.listOps = make(map[OpID]string)
+	}
+	n.listOps[op] = prefix
+	n.mu.Unlock()
+	return op
+}
+
+func (n *namespacedTransaction) Commit(ctx context.Context) ([]OpResult, error) {
+	results, err := n.txn.Commit(ctx)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i, result := range results {
+		if prefix, isList := n.listOps[result.Op]; isList {
+			results[i] = n.result(result, prefix)
+		}
+	}
+	return results, err
+}
+
+func (n *namespacedTransaction) Abort() error {
+	return n.txn.Abort()
+}
+
+// namespacedSavepointTransaction is a namespacedTransaction which supports savepoints
+type namespacedSavepointTransaction struct {
+	*namespacedTransaction
+	savepoints SavepointTransaction
+}
+
+func (n *namespacedSavepointTransaction) Savepoint() (Savepoint, error) {
+	return n.savepoints.Savepoint()
+}
+
+func (n *namespacedSavepointTransaction) RollbackTo(savepoint Savepoint) error {
+	return n.savepoints.RollbackTo(savepoint)
+}
+
+// ListNamespace returns the sorted store paths of every record in 'namespace', including its root, like the records of an FS created with Options.Namespace.
+func ListNamespace(ctx context.Context, store Store, namespace string) ([]string, error) {
+	if namespace == "" || !validNamespace(namespace) {
+		return nil, &hackpadfs.PathError{Op: "listnamespace", Path: namespace, Err: hackpadfs.ErrInvalid}
+	}
+	txn, err := TransactionOrSerial(store, TransactionOptions{Mode: TransactionReadOnly})
+	if err != nil {
+		return nil, wrapNamespaceErr("listnamespace", namespace, err)
+	}
+	rootOp := txn.Get(namespace)
+	listOp := txn.ListPrefix(namespace + "/")
+	results, err := txn.Commit(ctx)
+	if err != nil {
+		return nil, wrapNamespaceErr("listnamespace", namespace, err)
+	}
+	var paths []string
+	for _, result := range results {
+		switch {
+		case result.Op == rootOp && errors.Is(result.Err, hackpadfs.ErrNotExist):
+		case result.Err != nil:
+			return nil, wrapNamespaceErr("listnamespace", namespace, result.Err)
+		case result.Op == rootOp:
+			paths = append(paths, namespace)
+		case result.Op == listOp:
+			paths = append(paths, result.Paths...)
+		}
+	}
+	return paths, nil
+}
+
+// DeleteNamespace removes every record in 'namespace' from 'store' in a single transaction, if the store is a TransactionStore.
+// FSs using the namespace can't be used afterward.
+func DeleteNamespace(ctx context.Context, store Store, namespace string) error {
+	if namespace == "" || !validNamespace(namespace) {
+		return &hackpadfs.PathError{Op: "deletenamespace", Path: namespace, Err: hackpadfs.ErrInvalid}
+	}
+	txn, err := TransactionOrSerial(store, TransactionOptions{Mode: TransactionReadWrite})
+	if err != nil {
+		return wrapNamespaceErr("deletenamespace", namespace, err)
+	}
+	txn.ListPrefixHandler(namespace+"/", OpHandlerFunc(func(txn Transaction, result OpResult) error {
+		if result.Err != nil {
+			return result.Err
+		}
+		for i := len(result.Paths) - 1; i >= 0; i-- { // remove children before their parents
+			txn.Delete(result.Paths[i])
+		}
+		txn.Delete(namespace)
+		return nil
+	}))
+	results, err := txn.Commit(ctx)
+	for _, result := range results {
+		if err == nil {
+			err = result.Err
+		}
+	}
+	return wrapNamespaceErr("deletenamespace", namespace, err)
+}
+
+func wrapNamespaceErr(op, namespace string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &hackpadfs.PathError{Op: op, Path: namespace, Err: err}
+}
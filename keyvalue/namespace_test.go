@@ -0,0 +1,100 @@
+package keyvalue_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newNamespaceFS(tb testing.TB, store keyvalue.Store, namespace string) *keyvalue.FS {
+	tb.Helper()
+	fs, err := keyvalue.NewFSWithOptions(store, keyvalue.Options{Namespace: namespace})
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func TestNamespace(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name  string
+		store keyvalue.Store
+	}{
+		{name: "transaction store", store: mem.NewStore()},
+		{name: "serial store", store: &copyingStore{Store: mem.NewStore()}},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			alice := newNamespaceFS(t, tc.store, "alice")
+			bob := newNamespaceFS(t, tc.store, "bob")
+			aliceAgain := newNamespaceFS(t, tc.store, "alice")
+
+			assert.NoError(t, hackpadfs.MkdirAll(alice, "dir/sub", 0700))
+			assert.NoError(t, hackpadfs.WriteFullFile(alice, "dir/file", []byte("alice"), 0600))
+			assert.NoError(t, hackpadfs.WriteFullFile(bob, "dir", []byte("bob"), 0600))
+
+			contents, err := hackpadfs.ReadFile(aliceAgain, "dir/file")
+			assert.NoError(t, err)
+			assert.Equal(t, "alice", string(contents))
+			contents, err = hackpadfs.ReadFile(bob, "dir")
+			assert.NoError(t, err)
+			assert.Equal(t, "bob", string(contents))
+			record, err := tc.store.Get(ctx, "alice/dir/file")
+			assert.NoError(t, err)
+			assert.Equal(t, hackpadfs.FileMode(0600), record.Mode())
+
+			paths, err := keyvalue.ListNamespace(ctx, tc.store, "alice")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"alice", "alice/dir", "alice/dir/file", "alice/dir/sub"}, paths)
+
+			// removing everything in one FS leaves the others alone
+			assert.NoError(t, hackpadfs.RemoveAll(bob, "."))
+			entries, err := hackpadfs.ReadDir(bob, ".")
+			assert.NoError(t, err)
+			assert.Equal(t, 0, len(entries))
+			_, err = hackpadfs.Stat(alice, "dir/file")
+			assert.NoError(t, err)
+
+			assert.NoError(t, keyvalue.DeleteNamespace(ctx, tc.store, "alice"))
+			paths, err = keyvalue.ListNamespace(ctx, tc.store, "alice")
+			assert.NoError(t, err)
+			assert.Equal(t, []string(nil), paths)
+			paths, err = keyvalue.ListNamespace(ctx, tc.store, "bob")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"bob"}, paths)
+		})
+	}
+}
+
+func TestNamespaceInvalid(t *testing.T) {
+	t.Parallel()
+	for _, namespace := range []string{".", "/alice", "alice/", "../alice"} {
+		_, err := keyvalue.NewFSWithOptions(mem.NewStore(), keyvalue.Options{Namespace: namespace})
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	}
+	_, err := keyvalue.ListNamespace(context.Background(), mem.NewStore(), "")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, keyvalue.DeleteNamespace(context.Background(), mem.NewStore(), "."))
+}
+
+func TestNamespaceWatch(t *testing.T) {
+	t.Parallel()
+	store := mem.NewStore()
+	fs := newNamespaceFS(t, store, "tenant")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := fs.Watch(ctx, ".")
+	assert.NoError(t, err)
+
+	assert.NoError(t, hackpadfs.WriteFullFile(newNamespaceFS(t, store, "other"), "file", nil, 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "file", nil, 0600))
+	event := <-events
+	assert.Equal(t, hackpadfs.Event{Name: "file", Op: hackpadfs.EventWrite}, event)
+}
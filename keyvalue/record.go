@@ -1,6 +1,8 @@
 package keyvalue
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -29,6 +31,153 @@ type FileRecord interface {
 	Sys() interface{}
 }
 
+// VersionedFileRecord is a FileRecord from a VersionedStore.
+type VersionedFileRecord interface {
+	FileRecord
+	// Version returns the file's version when this record was retrieved. Returns "" if the version is unknown, e.g. for files saved before the store supported versions.
+	Version() string
+}
+
+// WithVersion returns 'record' as a VersionedFileRecord, for Stores which keep a file's version separately from its other metadata.
+func WithVersion(record FileRecord, version string) VersionedFileRecord {
+	return versionedRecord{FileRecord: record, version: version}
+}
+
+type versionedRecord struct {
+	FileRecord
+	version string
+}
+
+func (v versionedRecord) Version() string {
+	return v.version
+}
+
+func (v versionedRecord) Hash() (algo string, sum []byte, ok bool) {
+	return recordHash(v.FileRecord)
+}
+
+func (v versionedRecord) ID() string {
+	return RecordID(v.FileRecord)
+}
+
+// recordVersion returns the version of 'record', or "" if it isn't a VersionedFileRecord
+func recordVersion(record FileRecord) string {
+	if record, ok := record.(VersionedFileRecord); ok {
+		return record.Version()
+	}
+	return ""
+}
+
+// HashedFileRecord is a FileRecord from a Store which already knows a checksum of the file's contents, like an S3 object's ETag.
+// Sync and diff tools can compare checksums to skip reading unchanged files.
+type HashedFileRecord interface {
+	FileRecord
+	// Hash returns the checksum of the file's contents when this record was retrieved and the name of its algorithm, like "md5" or "sha256".
+	// Returns false if the checksum is unknown.
+	Hash() (algo string, sum []byte, ok bool)
+}
+
+// WithHash returns 'record' as a HashedFileRecord, for Stores which keep a file's checksum separately from its other metadata.
+func WithHash(record FileRecord, algo string, sum []byte) HashedFileRecord {
+	return hashedRecord{FileRecord: record, algo: algo, sum: sum}
+}
+
+type hashedRecord struct {
+	FileRecord
+	algo string
+	sum  []byte
+}
+
+func (h hashedRecord) Hash() (algo string, sum []byte, ok bool) {
+	return h.algo, h.sum, true
+}
+
+func (h hashedRecord) Version() string {
+	return recordVersion(h.FileRecord)
+}
+
+func (h hashedRecord) ID() string {
+	return RecordID(h.FileRecord)
+}
+
+// recordHash returns the checksum of 'record', or false if it isn't a HashedFileRecord or its checksum is unknown
+func recordHash(record FileRecord) (algo string, sum []byte, ok bool) {
+	if record, ok := record.(HashedFileRecord); ok {
+		return record.Hash()
+	}
+	return "", nil, false
+}
+
+// IdentifiedFileRecord is a FileRecord from a Store which keeps each file's ID, a stable identity like an inode number.
+// The FS assigns an ID to each file it creates and keeps it when the file is renamed, so it can report the same file under a different name in SameFile().
+type IdentifiedFileRecord interface {
+	FileRecord
+	// ID returns the file's ID, or "" if it is unknown, e.g. for files saved before the store kept IDs.
+	ID() string
+}
+
+// WithID returns 'record' as an IdentifiedFileRecord, for Stores which keep a file's ID separately from its other metadata.
+func WithID(record FileRecord, id string) IdentifiedFileRecord {
+	return identifiedRecord{FileRecord: record, id: id}
+}
+
+type identifiedRecord struct {
+	FileRecord
+	id string
+}
+
+func (i identifiedRecord) ID() string {
+	return i.id
+}
+
+func (i identifiedRecord) Version() string {
+	return recordVersion(i.FileRecord)
+}
+
+func (i identifiedRecord) Hash() (algo string, sum []byte, ok bool) {
+	return recordHash(i.FileRecord)
+}
+
+// RecordID returns the ID of 'record', or "" if it isn't an IdentifiedFileRecord or its ID is unknown.
+// Stores which keep IDs should save RecordID() of each record they're given.
+func RecordID(record FileRecord) string {
+	if record, ok := record.(IdentifiedFileRecord); ok {
+		return record.ID()
+	}
+	return ""
+}
+
+// newFileID returns a random ID for a new file, or "" if one couldn't be generated
+func newFileID() string {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf[:])
+}
+
+// Hash returns the checksum of the file described by 'info' and the name of its algorithm, if 'info' came from an FS whose Store returns a HashedFileRecord.
+// Returns false if the checksum is unknown, including for files with unsaved changes.
+func Hash(info hackpadfs.FileInfo) (algo string, sum []byte, ok bool) {
+	if info, ok := info.(interface {
+		Hash() (string, []byte, bool)
+	}); ok {
+		return info.Hash()
+	}
+	return "", nil, false
+}
+
+// ID returns the ID of the file described by 'info', if 'info' came from an FS whose Store keeps IDs.
+// Returns "" if the ID is unknown.
+func ID(info hackpadfs.FileInfo) string {
+	if info, ok := info.(interface {
+		ID() string
+	}); ok {
+		return info.ID()
+	}
+	return ""
+}
+
 var (
 	_ FileRecord = &BaseFileRecord{}
 )
@@ -175,6 +324,10 @@ func (r *runOnceFileRecord) ModTime() time.Time {
 	return r.modTime
 }
 
+func (r *runOnceFileRecord) ID() string {
+	return RecordID(r.record)
+}
+
 func (r *runOnceFileRecord) Sys() interface{} {
 	r.sysOnce.Do(func() {
 		r.sys = r.record.Sys()
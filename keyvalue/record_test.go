@@ -77,3 +77,16 @@ func TestBaseFileRecordSys(t *testing.T) {
 	const someSys = "some sys"
 	assert.Equal(t, someSys, (&BaseFileRecord{sys: someSys}).Sys())
 }
+
+func TestWithHashKeepsVersion(t *testing.T) {
+	t.Parallel()
+	record := NewBaseFileRecord(0, time.Time{}, 0600, nil, nil, nil)
+	versioned := WithVersion(WithHash(record, "md5", []byte{1}), "v1")
+	algo, sum, ok := versioned.(HashedFileRecord).Hash()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "md5", algo)
+	assert.Equal(t, []byte{1}, sum)
+
+	hashed := WithHash(WithVersion(record, "v2"), "md5", []byte{2})
+	assert.Equal(t, "v2", hashed.(VersionedFileRecord).Version())
+}
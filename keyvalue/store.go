@@ -1,6 +1,10 @@
 package keyvalue
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 // Store holds arbitrary file data at the given 'path' location. Can be wrapped as a file system with keyvalue.NewFS().
 type Store interface {
@@ -11,3 +15,78 @@ type Store interface {
 	// Set assigns 'src' to the given 'path'. Returns an error if the data could not be set.
 	Set(ctx context.Context, path string, src FileRecord) error
 }
+
+// MetadataStore is a Store which can update a file's metadata without rewriting its contents.
+// Stores which re-upload the full contents on every Set, like object stores, should implement MetadataStore so Chmod and Chtimes stay cheap.
+type MetadataStore interface {
+	Store
+	// SetMetadata assigns the mode and modification time of 'src' to the existing file at 'path', leaving its contents untouched.
+	// Implementations must not call src.Data().
+	// If the path was not found, the error must satisfy errors.Is(err, hackpadfs.ErrNotExist).
+	SetMetadata(ctx context.Context, path string, src FileRecord) error
+}
+
+// UsageStore is a Store which can total the files under a directory itself, like summing object sizes from a listing, instead of the FS reading every file's record.
+type UsageStore interface {
+	Store
+	// DiskUsage returns the number of files and directories at and under 'path', including 'path' itself, and the total size of its files in bytes.
+	// If the path was not found, the error must satisfy errors.Is(err, hackpadfs.ErrNotExist).
+	DiskUsage(ctx context.Context, path string) (files, dirs, bytes int64, err error)
+}
+
+// ErrVersionConflict is returned by VersionedStore.SetVersion when the file changed since the given version was read.
+var ErrVersionConflict = errors.New("version conflict")
+
+// VersionConflictError records a rejected VersionedStore.SetVersion call. Satisfies errors.Is(err, ErrVersionConflict).
+//
+// A writer can retry by reading the file again and reapplying its change, or surface the error to the user.
+type VersionConflictError struct {
+	Path    string
+	Version string // Version is the version the writer expected
+	Current string // Current is the file's actual version, if known. Empty if the file doesn't exist.
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: file %q is at version %q, expected %q", ErrVersionConflict, e.Path, e.Current, e.Version)
+}
+
+// Unwrap supports errors.Unwrap().
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
+
+// VersionedStore is a Store which tags each change to a file with a new version, like an ETag, so writers can detect changes made by other writers since they read it.
+// File handles from a keyvalue.FS save their contents with SetVersion, and reload the file if another handle changed it first.
+type VersionedStore interface {
+	Store
+	// SetVersion assigns 'src' to the given 'path' like Set, but only if the file's current version is 'ifVersion', then returns its new version.
+	// If the file has a different version, returns a *VersionConflictError and leaves the file untouched.
+	//
+	// Versions are opaque, non-empty strings, like ETags. A file's version must change whenever its contents change, and should change with its metadata too.
+	// An 'ifVersion' of "" requires the file to not exist.
+	// Records returned by Get must implement VersionedFileRecord.
+	SetVersion(ctx context.Context, path string, src FileRecord, ifVersion string) (string, error)
+}
+
+// Event is a change to the record at Path in a WatchableStore
+type Event struct {
+	Path    string
+	Deleted bool // Deleted is true if the record was removed, false if it was set. A deleted "." path means every record was removed.
+}
+
+// WatchableStore is a Store which reports changes to its records, including changes made by other clients sharing the same storage.
+// A keyvalue.FS implements hackpadfs.WatchFS with its store's events. Stores can use Watchers to deliver them.
+type WatchableStore interface {
+	Store
+	// Watch returns a channel of events for changes to records at or under the path 'prefix', in the order they were applied. A 'prefix' of "." watches every record.
+	// The channel is closed once 'ctx' is done.
+	// Returns an error satisfying errors.Is(err, hackpadfs.ErrNotImplemented) if this store can't watch changes with its current configuration.
+	Watch(ctx context.Context, prefix string) (<-chan Event, error)
+}
+
+// PingStore is a Store which can check its backend is reachable, like a HEAD request to a remote bucket, without reading a file.
+type PingStore interface {
+	Store
+	// Ping returns an error if the store can't currently serve requests.
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,524 @@
+package keyvalue_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+type countingStore struct {
+	keyvalue.Store
+	sets int
+}
+
+func (s *countingStore) Set(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	s.sets++
+	return s.Store.Set(ctx, path, src)
+}
+
+type countingMetadataStore struct {
+	countingStore
+	metadataSets int
+}
+
+func (s *countingMetadataStore) SetMetadata(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	s.metadataSets++
+	return s.Store.(keyvalue.MetadataStore).SetMetadata(ctx, path, src)
+}
+
+func TestMetadataStore(t *testing.T) {
+	t.Parallel()
+	store := &countingMetadataStore{countingStore: countingStore{Store: mem.NewStore()}}
+	fs, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("bar"), 0600))
+	store.sets = 0
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, hackpadfs.Chmod(fs, "foo", 0644))
+	assert.NoError(t, hackpadfs.Chtimes(fs, "foo", modTime, modTime))
+	f, err := fs.OpenFile("foo", hackpadfs.FlagReadWrite, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.ChmodFile(f, 0640))
+	assert.NoError(t, f.Close())
+
+	assert.Equal(t, 0, store.sets)
+	assert.Equal(t, 3, store.metadataSets)
+	info, err := hackpadfs.Stat(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0640), info.Mode())
+	assert.Equal(t, modTime, info.ModTime())
+	contents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(contents))
+}
+
+func TestMetadataStoreFallback(t *testing.T) {
+	t.Parallel()
+	store := &countingStore{Store: mem.NewStore()}
+	fs, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("bar"), 0600))
+	store.sets = 0
+
+	assert.NoError(t, hackpadfs.Chmod(fs, "foo", 0644))
+	assert.Equal(t, 1, store.sets)
+	info, err := hackpadfs.Stat(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0644), info.Mode())
+}
+
+func TestSerialTransactionListPrefix(t *testing.T) {
+	t.Parallel()
+	store := &countingStore{Store: mem.NewStore()} // hides mem's Transaction, so TransactionOrSerial walks the directories
+	fs, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar/baz", []byte("baz"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/biff", []byte("biff"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foobar", []byte("foobar"), 0600))
+
+	txn, err := keyvalue.TransactionOrSerial(store, keyvalue.TransactionOptions{})
+	assert.NoError(t, err)
+	txn.ListPrefix("foo/")
+	txn.ListPrefix("foo/b")
+	txn.ListPrefix("foo")
+	txn.ListPrefix("")
+	txn.ListPrefix("missing/")
+	results, err := txn.Commit(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(results))
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+	assert.Equal(t, []string{"foo/bar", "foo/bar/baz", "foo/biff"}, results[0].Paths)
+	assert.Equal(t, []string{"foo/bar", "foo/bar/baz", "foo/biff"}, results[1].Paths)
+	assert.Equal(t, []string{"foo", "foo/bar", "foo/bar/baz", "foo/biff", "foobar"}, results[2].Paths)
+	assert.Equal(t, []string{".", "foo", "foo/bar", "foo/bar/baz", "foo/biff", "foobar"}, results[3].Paths)
+	assert.Equal(t, 0, len(results[4].Paths))
+}
+
+func TestFSRemoveAllRoot(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(mem.NewStore())
+	assert.NoError(t, err)
+	assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar/baz", []byte("baz"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "biff", []byte("biff"), 0600))
+
+	assert.NoError(t, fs.RemoveAll("."))
+	entries, err := hackpadfs.ReadDir(fs, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
+
+func TestWithSavepoints(t *testing.T) {
+	t.Parallel()
+	store := &countingStore{Store: mem.NewStore()}
+	fs, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+	assert.NoError(t, fs.Mkdir("bar", 0700))
+
+	serialTxn, err := keyvalue.TransactionOrSerial(store, keyvalue.TransactionOptions{Mode: keyvalue.TransactionReadWrite})
+	assert.NoError(t, err)
+	txn := keyvalue.WithSavepoints(serialTxn, store)
+	savepoint, err := txn.Savepoint()
+	assert.NoError(t, err)
+	newContents := blob.NewBytes([]byte("changed"))
+	txn.Set("foo", keyvalue.NewBaseFileRecord(int64(newContents.Len()), time.Now(), 0644, nil, func() (blob.Blob, error) {
+		return newContents, nil
+	}, nil), newContents)
+	txn.Delete("bar")
+	txn.DeleteHandler("foo", keyvalue.OpHandlerFunc(func(txn keyvalue.Transaction, result keyvalue.OpResult) error {
+		return txn.(keyvalue.SavepointTransaction).RollbackTo(savepoint)
+	}))
+	_, err = txn.Commit(context.Background())
+	assert.NoError(t, err)
+
+	contents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(contents))
+	info, err := fs.Stat("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0600), info.Mode())
+	info, err = fs.Stat("bar")
+	assert.NoError(t, err)
+	assert.Equal(t, true, info.IsDir())
+}
+
+// failingStore fails to set the file at 'failPath'
+type failingStore struct {
+	keyvalue.Store
+	failPath string
+}
+
+var errSetFailed = errors.New("set failed")
+
+func (s *failingStore) Set(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	if path == s.failPath {
+		return errSetFailed
+	}
+	return s.Store.Set(ctx, path, src)
+}
+
+func TestFSRenameDirRollback(t *testing.T) {
+	t.Parallel()
+	store := &failingStore{Store: mem.NewStore(), failPath: "bar/b/c"}
+	fs, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, fs.MkdirAll("foo/b", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/a", []byte("a"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/b/c", []byte("c"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/d", []byte("d"), 0600))
+
+	err = fs.Rename("foo", "bar")
+	assert.ErrorIs(t, errSetFailed, err)
+	assert.IsType(t, &hackpadfs.LinkError{}, err)
+
+	_, err = fs.Stat("bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	for name, expected := range map[string]string{"foo/a": "a", "foo/b/c": "c", "foo/d": "d"} {
+		contents, err := hackpadfs.ReadFile(fs, name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(contents))
+	}
+	entries, err := hackpadfs.ReadDir(fs, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestFSRenameDirIntoItself(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(mem.NewStore())
+	assert.NoError(t, err)
+	assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+
+	err = fs.Rename("foo", "foo/bar/baz")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	_, err = fs.Stat("foo/bar")
+	assert.NoError(t, err)
+}
+
+// copyingStore returns a copy of each file's contents from Data(), like stores which don't keep files in memory
+type copyingStore struct {
+	keyvalue.Store
+}
+
+func (s *copyingStore) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
+	record, err := s.Store.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return copyingRecord{record}, nil
+}
+
+type copyingRecord struct {
+	keyvalue.FileRecord
+}
+
+func (r copyingRecord) Data() (blob.Blob, error) {
+	data, err := r.FileRecord.Data()
+	if err != nil || data == nil {
+		return data, err
+	}
+	return blob.Clone(data)
+}
+
+func TestFSDiskUsage(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(mem.NewStore())
+	assert.NoError(t, err)
+	assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar/baz", []byte("baz"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "-foo", []byte("sorts before the root"), 0600))
+
+	files, dirs, bytes, err := fs.DiskUsage(".")
+	assert.NoError(t, err)
+	assert.Equal(t, [3]int64{2, 3, 24}, [3]int64{files, dirs, bytes})
+
+	files, dirs, bytes, err = fs.DiskUsage("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, [3]int64{1, 2, 3}, [3]int64{files, dirs, bytes})
+
+	_, _, _, err = fs.DiskUsage("missing")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	_, _, _, err = fs.DiskUsage("/foo")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
+
+type usageStore struct {
+	keyvalue.Store
+	paths []string
+}
+
+func (s *usageStore) DiskUsage(ctx context.Context, path string) (files, dirs, bytes int64, err error) {
+	s.paths = append(s.paths, path)
+	return 1, 2, 3, nil
+}
+
+func TestFSDiskUsageStore(t *testing.T) {
+	t.Parallel()
+	store := &usageStore{Store: mem.NewStore()}
+	fs, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	files, dirs, bytes, err := fs.DiskUsage(".")
+	assert.NoError(t, err)
+	assert.Equal(t, [3]int64{1, 2, 3}, [3]int64{files, dirs, bytes})
+	assert.Equal(t, []string{"."}, store.paths)
+
+	hookedFS, err := keyvalue.NewFSWithOptions(store, keyvalue.Options{Hooks: xorHooks()})
+	assert.NoError(t, err)
+	files, dirs, bytes, err = hookedFS.DiskUsage(".")
+	assert.NoError(t, err)
+	assert.Equal(t, [3]int64{0, 1, 0}, [3]int64{files, dirs, bytes})
+	assert.Equal(t, []string{"."}, store.paths)
+}
+
+type closingStore struct {
+	keyvalue.Store
+	closes int
+}
+
+func (s *closingStore) Close() error {
+	s.closes++
+	return nil
+}
+
+func TestFSClose(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(mem.NewStore())
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Close())
+
+	store := &closingStore{Store: mem.NewStore()}
+	fs, err = keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Close(fs))
+	assert.Equal(t, 1, store.closes)
+}
+
+type pingStore struct {
+	keyvalue.Store
+	err error
+}
+
+func (s *pingStore) Ping(ctx context.Context) error {
+	return s.err
+}
+
+func TestFSPing(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fs, err := keyvalue.NewFS(mem.NewStore())
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Ping(ctx))
+
+	store := &pingStore{Store: mem.NewStore()}
+	fs, err = keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Ping(ctx))
+	store.err = errors.New("some error")
+	err = fs.Ping(ctx)
+	assert.ErrorIs(t, store.err, err)
+	var pathErr *hackpadfs.PathError
+	if assert.ErrorAs(t, &pathErr, err) {
+		assert.Equal(t, "ping", pathErr.Op)
+	}
+}
+
+func TestFSUpdateDirModTimeSerial(t *testing.T) {
+	t.Parallel()
+	for _, update := range []bool{false, true} {
+		store := &countingStore{Store: mem.NewStore()} // hides mem's Transaction, so changes run in a serial transaction
+		fs, err := keyvalue.NewFSWithOptions(store, keyvalue.Options{UpdateDirModTime: update})
+		assert.NoError(t, err)
+		assert.NoError(t, fs.Mkdir("foo", 0700))
+		modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+		assert.NoError(t, fs.Chtimes("foo", modTime, modTime))
+
+		assert.NoError(t, fs.RemoveAll("foo/missing"))
+		info, err := fs.Stat("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, modTime, info.ModTime()) // removing a missing file changes nothing
+
+		store.sets = 0
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("bar"), 0600))
+		info, err = fs.Stat("foo")
+		assert.NoError(t, err)
+		if update {
+			assert.Equal(t, 3, store.sets) // creates the file, updates its parent, then writes the contents
+			assert.Equal(t, true, info.ModTime().After(modTime))
+		} else {
+			assert.Equal(t, 2, store.sets)
+			assert.Equal(t, modTime, info.ModTime())
+		}
+		dirNames, err := hackpadfs.ReadDir(fs, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(dirNames))
+	}
+}
+
+func TestFSSameFile(t *testing.T) {
+	t.Parallel()
+	for _, options := range []keyvalue.Options{{}, {Hooks: xorHooks()}} {
+		fs, err := keyvalue.NewFSWithOptions(mem.NewStore(), options)
+		assert.NoError(t, err)
+		assert.NoError(t, fs.Mkdir("dir", 0700))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/foo", []byte("foo"), 0600))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/bar", []byte("bar"), 0600))
+		fooInfo, err := fs.Stat("dir/foo")
+		assert.NoError(t, err)
+		assert.NotEqual(t, "", keyvalue.ID(fooInfo))
+
+		assert.NoError(t, fs.Rename("dir/foo", "dir/baz"))
+		assert.NoError(t, fs.Rename("dir", "moved"))
+		bazInfo, err := fs.Stat("moved/baz")
+		assert.NoError(t, err)
+		assert.Equal(t, true, fs.SameFile(fooInfo, bazInfo))
+		f, err := fs.Open("moved/baz")
+		assert.NoError(t, err)
+		fileInfo, err := f.Stat()
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+		assert.Equal(t, true, fs.SameFile(fooInfo, fileInfo))
+
+		barInfo, err := fs.Stat("moved/bar")
+		assert.NoError(t, err)
+		assert.Equal(t, false, fs.SameFile(fooInfo, barInfo))
+	}
+}
+
+func TestFSSameFileNoIDs(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(&copyingStore{Store: mem.NewStore()}) // copyingStore's records hide mem's IDs
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "bar", []byte("bar"), 0600))
+	fooInfo, err := fs.Stat("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "", keyvalue.ID(fooInfo))
+	fooInfo2, err := fs.Stat("foo")
+	assert.NoError(t, err)
+	barInfo, err := fs.Stat("bar")
+	assert.NoError(t, err)
+	assert.Equal(t, true, fs.SameFile(fooInfo, fooInfo2))
+	assert.Equal(t, false, fs.SameFile(fooInfo, barInfo))
+}
+
+func TestFSApplyMetadata(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		store       keyvalue.Store
+	}{
+		{description: "transaction store", store: mem.NewStore()},
+		{description: "serial store", store: &countingStore{Store: mem.NewStore()}},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			fs, err := keyvalue.NewFS(tc.store)
+			assert.NoError(t, err)
+			assert.NoError(t, fs.Mkdir("dir", 0700))
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/file", []byte("contents"), 0600))
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+			err = fs.ApplyMetadata(map[string]hackpadfs.Metadata{
+				"dir":      {Mode: 0755, SetMode: true},
+				"dir/file": {Mode: 0644, SetMode: true, Mtime: modTime},
+			})
+			assert.NoError(t, err)
+			info, err := fs.Stat("dir")
+			assert.NoError(t, err)
+			assert.Equal(t, hackpadfs.ModeDir|0755, info.Mode())
+			info, err = fs.Stat("dir/file")
+			assert.NoError(t, err)
+			assert.Equal(t, hackpadfs.FileMode(0644), info.Mode())
+			assert.Equal(t, modTime, info.ModTime())
+			contents, err := hackpadfs.ReadFile(fs, "dir/file")
+			assert.NoError(t, err)
+			assert.Equal(t, "contents", string(contents))
+
+			err = fs.ApplyMetadata(map[string]hackpadfs.Metadata{
+				"dir": {UID: 1, GID: 1, SetOwner: true},
+			})
+			assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+		})
+	}
+}
+
+func TestFSApplyMetadataAborts(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(mem.NewStore())
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "a", nil, 0600))
+
+	err = fs.ApplyMetadata(map[string]hackpadfs.Metadata{
+		"a":       {Mode: 0644, SetMode: true},
+		"missing": {Mode: 0644, SetMode: true},
+	})
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	info, err := fs.Stat("a")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0600), info.Mode())
+}
+
+// reversedDirStore returns directory names in reverse order
+type reversedDirStore struct {
+	keyvalue.Store
+}
+
+type reversedDirRecord struct {
+	keyvalue.FileRecord
+}
+
+func (r reversedDirRecord) ReadDirNames() ([]string, error) {
+	names, err := r.FileRecord.ReadDirNames()
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, err
+}
+
+func (s *reversedDirStore) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
+	record, err := s.Store.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return reversedDirRecord{record}, nil
+}
+
+func TestFSUnsortedReadDir(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		unsorted    bool
+		expected    []string
+	}{
+		{description: "sorted", expected: []string{"a", "b", "c"}},
+		{description: "unsorted", unsorted: true, expected: []string{"c", "b", "a"}},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			fs, err := keyvalue.NewFSWithOptions(&reversedDirStore{Store: mem.NewStore()}, keyvalue.Options{UnsortedReadDir: tc.unsorted})
+			assert.NoError(t, err)
+			for _, name := range []string{"b", "c", "a"} {
+				assert.NoError(t, hackpadfs.WriteFullFile(fs, name, nil, 0600))
+			}
+			dir, err := fs.Open(".")
+			assert.NoError(t, err)
+			names, err := hackpadfs.ReadDirNamesFile(dir, 0)
+			assert.NoError(t, err)
+			assert.NoError(t, dir.Close())
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+}
@@ -0,0 +1,858 @@
+// Package storetest contains a compliance test suite for keyvalue.Store implementations.
+//
+// Run it in a Store's tests, independent of the keyvalue.FS built on top of it:
+//
+//	func TestStore(t *testing.T) {
+//		storetest.Store(t, func(tb testing.TB) keyvalue.Store {
+//			return newMyStore(tb)
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+// NewStoreFunc returns a new, empty Store for the current test. Cleanup should be run via tb.Cleanup() tasks.
+// Must support running in parallel with other tests.
+type NewStoreFunc func(tb testing.TB) keyvalue.Store
+
+// Store runs Store tests against stores returned by 'newStore'.
+// Optional interfaces, like keyvalue.TransactionStore, keyvalue.MetadataStore, and keyvalue.VersionedStore, are tested if the store implements them.
+// File IDs are tested if the store returns a keyvalue.IdentifiedFileRecord with the ID it was given.
+// Watching is tested if the store implements keyvalue.WatchableStore and its Watch doesn't fail with hackpadfs.ErrNotImplemented.
+//
+// Stores must keep modification times to at least one second of precision.
+// Stores may require a file's parent directory to exist before setting it. The root directory "." is set before any other paths.
+func Store(tb testing.TB, newStore NewStoreFunc) {
+	tb.Helper()
+	tbRun(tb, "Get", func(tb testing.TB) { testGet(tb, newStore) })
+	tbRun(tb, "Set", func(tb testing.TB) { testSet(tb, newStore) })
+	tbRun(tb, "Set nil", func(tb testing.TB) { testSetNil(tb, newStore) })
+	tbRun(tb, "ReadDirNames", func(tb testing.TB) { testReadDirNames(tb, newStore) })
+	tbRun(tb, "concurrent Set", func(tb testing.TB) { testConcurrentSet(tb, newStore) })
+	tbRun(tb, "Transaction", func(tb testing.TB) { testTransaction(tb, newStore) })
+	tbRun(tb, "SetMetadata", func(tb testing.TB) { testSetMetadata(tb, newStore) })
+	tbRun(tb, "SetVersion", func(tb testing.TB) { testSetVersion(tb, newStore) })
+	tbRun(tb, "DiskUsage", func(tb testing.TB) { testDiskUsage(tb, newStore) })
+	tbRun(tb, "ID", func(tb testing.TB) { testID(tb, newStore) })
+	tbRun(tb, "Watch", func(tb testing.TB) { testWatch(tb, newStore) })
+}
+
+func tbRun(tb testing.TB, name string, subtest func(tb testing.TB)) {
+	tb.Helper()
+	switch tb := tb.(type) {
+	case *testing.T:
+		tb.Run(name, func(t *testing.T) {
+			t.Helper()
+			t.Parallel()
+			subtest(t)
+		})
+	case *testing.B:
+		tb.Run(name, func(b *testing.B) {
+			b.Helper()
+			subtest(b)
+		})
+	default:
+		tb.Errorf("Unrecognized testing type: %T", tb)
+	}
+}
+
+// setupStore returns a new store containing an empty root directory
+func setupStore(tb testing.TB, newStore NewStoreFunc) keyvalue.Store {
+	tb.Helper()
+	store := newStore(tb)
+	if !assert.NoError(tb, store.Set(context.Background(), ".", newDir())) {
+		tb.FailNow()
+	}
+	return store
+}
+
+var modTime = time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newFile(contents string, mode hackpadfs.FileMode) keyvalue.FileRecord {
+	data := []byte(contents)
+	return keyvalue.NewBaseFileRecord(int64(len(data)), modTime, mode, nil, func() (blob.Blob, error) {
+		return blob.NewBytes(data), nil
+	}, nil)
+}
+
+// newDir returns a directory record, like keyvalue.FS passes to Set. Its Data() is empty.
+func newDir() keyvalue.FileRecord {
+	return keyvalue.NewBaseFileRecord(0, modTime, hackpadfs.ModeDir|0700, nil, func() (blob.Blob, error) {
+		return blob.NewBytes(nil), nil
+	}, func() ([]string, error) {
+		return nil, nil
+	})
+}
+
+// assertFile asserts 'record' is a regular file with the given contents and mode
+func assertFile(tb testing.TB, contents string, mode hackpadfs.FileMode, record keyvalue.FileRecord) {
+	tb.Helper()
+	assert.Equal(tb, mode, record.Mode())
+	assert.Equal(tb, int64(len(contents)), record.Size())
+	if !record.ModTime().Equal(modTime) {
+		tb.Errorf("ModTime() = %v, want %v", record.ModTime(), modTime)
+	}
+	data, err := record.Data()
+	if assert.NoError(tb, err) {
+		assert.Equal(tb, contents, string(data.Bytes()))
+	}
+}
+
+func testGet(tb testing.TB, newStore NewStoreFunc) {
+	tbRun(tb, "not exist", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		_, err := store.Get(context.Background(), "foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	tbRun(tb, "parent not exist", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		_, err := store.Get(context.Background(), "foo/bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	tbRun(tb, "root", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		record, err := store.Get(context.Background(), ".")
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, true, record.Mode().IsDir())
+		}
+	})
+}
+
+func testSet(tb testing.TB, newStore NewStoreFunc) {
+	tbRun(tb, "file", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("bar", 0640)))
+		record, err := store.Get(ctx, "foo")
+		if assert.NoError(tb, err) {
+			assertFile(tb, "bar", 0640, record)
+			_, err := record.ReadDirNames()
+			assert.Error(tb, err)
+		}
+	})
+
+	tbRun(tb, "empty file", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("", 0600)))
+		record, err := store.Get(ctx, "foo")
+		if assert.NoError(tb, err) {
+			assertFile(tb, "", 0600, record)
+		}
+	})
+
+	tbRun(tb, "directory", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newDir()))
+		record, err := store.Get(ctx, "foo")
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, hackpadfs.ModeDir|0700, record.Mode())
+		}
+	})
+
+	tbRun(tb, "nested file", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newDir()))
+		assert.NoError(tb, store.Set(ctx, "foo/bar", newFile("baz", 0600)))
+		record, err := store.Get(ctx, "foo/bar")
+		if assert.NoError(tb, err) {
+			assertFile(tb, "baz", 0600, record)
+		}
+	})
+
+	tbRun(tb, "overwrite", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("bar", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("hello world", 0644)))
+		record, err := store.Get(ctx, "foo")
+		if assert.NoError(tb, err) {
+			assertFile(tb, "hello world", 0644, record)
+		}
+	})
+
+	tbRun(tb, "record is not changed by later sets", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("bar", 0600)))
+		record, err := store.Get(ctx, "foo")
+		if !assert.NoError(tb, err) {
+			return
+		}
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("hello world", 0644)))
+		assert.Equal(tb, int64(len("bar")), record.Size())
+		assert.Equal(tb, hackpadfs.FileMode(0600), record.Mode())
+	})
+}
+
+func testSetNil(tb testing.TB, newStore NewStoreFunc) {
+	tbRun(tb, "file", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("bar", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foo", nil))
+		_, err := store.Get(ctx, "foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	tbRun(tb, "empty directory", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newDir()))
+		assert.NoError(tb, store.Set(ctx, "foo", nil))
+		_, err := store.Get(ctx, "foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	tbRun(tb, "not exist", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		assert.NoError(tb, store.Set(context.Background(), "foo", nil))
+	})
+}
+
+func testReadDirNames(tb testing.TB, newStore NewStoreFunc) {
+	tbRun(tb, "empty directory", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newDir()))
+		record, err := store.Get(ctx, "foo")
+		if assert.NoError(tb, err) {
+			names, err := record.ReadDirNames()
+			assert.NoError(tb, err)
+			assert.Equal(tb, 0, len(names))
+		}
+	})
+
+	tbRun(tb, "direct children only", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newDir()))
+		assert.NoError(tb, store.Set(ctx, "foo/a", newFile("a", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foo/b", newFile("b", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foo/c", newDir()))
+		assert.NoError(tb, store.Set(ctx, "foo/c/d", newFile("d", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foobar", newFile("foobar", 0600)))
+		assertDirNames(tb, store, "foo", []string{"a", "b", "c"})
+	})
+
+	tbRun(tb, "root", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("foo", 0600)))
+		assert.NoError(tb, store.Set(ctx, "bar", newDir()))
+		assert.NoError(tb, store.Set(ctx, "bar/baz", newFile("baz", 0600)))
+		assertDirNames(tb, store, ".", []string{"bar", "foo"})
+	})
+
+	tbRun(tb, "removed child", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newDir()))
+		assert.NoError(tb, store.Set(ctx, "foo/a", newFile("a", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foo/b", newFile("b", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foo/a", nil))
+		assertDirNames(tb, store, "foo", []string{"b"})
+	})
+}
+
+func assertDirNames(tb testing.TB, store keyvalue.Store, dir string, expected []string) {
+	tb.Helper()
+	record, err := store.Get(context.Background(), dir)
+	if !assert.NoError(tb, err) {
+		return
+	}
+	names, err := record.ReadDirNames()
+	assert.NoError(tb, err)
+	if len(names) == 0 && len(expected) == 0 {
+		return // nil and empty are equivalent
+	}
+	sort.Strings(names)
+	assert.Equal(tb, expected, names)
+}
+
+func testConcurrentSet(tb testing.TB, newStore NewStoreFunc) {
+	store := setupStore(tb, newStore)
+	ctx := context.Background()
+	const fileCount = 10
+	var wg sync.WaitGroup
+	wg.Add(fileCount)
+	for i := 0; i < fileCount; i++ {
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(tb, store.Set(ctx, fmt.Sprintf("foo-%d", i), newFile(fmt.Sprint(i), 0600)))
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < fileCount; i++ {
+		record, err := store.Get(ctx, fmt.Sprintf("foo-%d", i))
+		if assert.NoError(tb, err) {
+			assertFile(tb, fmt.Sprint(i), 0600, record)
+		}
+	}
+}
+
+// newTransaction returns a transaction for 'store', or skips the test if it isn't a keyvalue.TransactionStore
+func newTransaction(tb testing.TB, store keyvalue.Store, mode keyvalue.TransactionMode) keyvalue.Transaction {
+	tb.Helper()
+	txnStore, ok := store.(keyvalue.TransactionStore)
+	if !ok {
+		tb.Skip("Store does not implement keyvalue.TransactionStore")
+	}
+	txn, err := txnStore.Transaction(keyvalue.TransactionOptions{Mode: mode})
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return txn
+}
+
+// setContents returns the 'contents' argument for Transaction.Set
+func setContents(tb testing.TB, record keyvalue.FileRecord) blob.Blob {
+	tb.Helper()
+	data, err := record.Data()
+	assert.NoError(tb, err)
+	return data
+}
+
+func testTransaction(tb testing.TB, newStore NewStoreFunc) {
+	tbRun(tb, "get and set", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		txn := newTransaction(tb, store, keyvalue.TransactionReadWrite)
+		record := newFile("bar", 0600)
+		ops := []keyvalue.OpID{
+			txn.Set("foo", record, setContents(tb, record)),
+			txn.Get("foo"),
+			txn.Get("missing"),
+		}
+		results, err := txn.Commit(context.Background())
+		if !assert.NoError(tb, err) || !assert.Equal(tb, len(ops), len(results)) {
+			return
+		}
+		for i, op := range ops {
+			assert.Equal(tb, op, results[i].Op)
+		}
+		assert.NoError(tb, results[0].Err)
+		if assert.NoError(tb, results[1].Err) {
+			assertFile(tb, "bar", 0600, results[1].Record)
+		}
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, results[2].Err)
+	})
+
+	tbRun(tb, "read only", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("bar", 0600)))
+		txn := newTransaction(tb, store, keyvalue.TransactionReadOnly)
+		txn.Get("foo")
+		results, err := txn.Commit(ctx)
+		if assert.NoError(tb, err) && assert.Equal(tb, 1, len(results)) && assert.NoError(tb, results[0].Err) {
+			assertFile(tb, "bar", 0600, results[0].Record)
+		}
+	})
+
+	tbRun(tb, "commit applies all sets", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		txn := newTransaction(tb, store, keyvalue.TransactionReadWrite)
+		dir := newDir()
+		txn.Set("foo", dir, nil)
+		for _, name := range []string{"foo/a", "foo/b", "foo/c"} {
+			record := newFile(name, 0600)
+			txn.Set(name, record, setContents(tb, record))
+		}
+		txn.Set("foo/b", nil, nil)
+		_, err := txn.Commit(ctx)
+		assert.NoError(tb, err)
+
+		for _, name := range []string{"foo/a", "foo/c"} {
+			record, err := store.Get(ctx, name)
+			if assert.NoError(tb, err) {
+				assertFile(tb, name, 0600, record)
+			}
+		}
+		_, err = store.Get(ctx, "foo/b")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		assertDirNames(tb, store, "foo", []string{"a", "c"})
+	})
+
+	tbRun(tb, "handlers", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("foo", 0600)))
+		txn := newTransaction(tb, store, keyvalue.TransactionReadWrite)
+		var getResult, setResult *keyvalue.OpResult
+		getOp := txn.GetHandler("foo", keyvalue.OpHandlerFunc(func(txn keyvalue.Transaction, result keyvalue.OpResult) error {
+			getResult = &result
+			// queue another operation from inside the handler, like a compare-and-swap
+			record := newFile("bar", 0600)
+			txn.SetHandler("bar", record, setContents(tb, record), keyvalue.OpHandlerFunc(func(txn keyvalue.Transaction, result keyvalue.OpResult) error {
+				setResult = &result
+				return nil
+			}))
+			return nil
+		}))
+		_, err := txn.Commit(ctx)
+		assert.NoError(tb, err)
+
+		if assert.NotEqual(tb, (*keyvalue.OpResult)(nil), getResult) {
+			assert.Equal(tb, getOp, getResult.Op)
+			if assert.NoError(tb, getResult.Err) {
+				assertFile(tb, "foo", 0600, getResult.Record)
+			}
+		}
+		if assert.NotEqual(tb, (*keyvalue.OpResult)(nil), setResult) {
+			assert.NoError(tb, setResult.Err)
+		}
+		record, err := store.Get(ctx, "bar")
+		if assert.NoError(tb, err) {
+			assertFile(tb, "bar", 0600, record)
+		}
+	})
+
+	tbRun(tb, "handler error", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		txn := newTransaction(tb, store, keyvalue.TransactionReadWrite)
+		handlerErr := errors.New("some error")
+		txn.GetHandler("foo", keyvalue.OpHandlerFunc(func(txn keyvalue.Transaction, result keyvalue.OpResult) error {
+			return handlerErr
+		}))
+		results, err := txn.Commit(context.Background())
+		if assert.NoError(tb, err) && assert.Equal(tb, 1, len(results)) {
+			assert.ErrorIs(tb, hackpadfs.ErrNotExist, results[0].Err) // the operation's own error takes precedence
+		}
+	})
+
+	tbRun(tb, "delete", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("foo", 0600)))
+		assert.NoError(tb, store.Set(ctx, "bar", newDir()))
+		txn := newTransaction(tb, store, keyvalue.TransactionReadWrite)
+		txn.Delete("foo")
+		txn.Delete("bar")
+		txn.Delete("missing")
+		results, err := txn.Commit(ctx)
+		if assert.NoError(tb, err) && assert.Equal(tb, 3, len(results)) {
+			for _, result := range results {
+				assert.NoError(tb, result.Err)
+			}
+		}
+		for _, name := range []string{"foo", "bar"} {
+			_, err := store.Get(ctx, name)
+			assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		}
+	})
+
+	tbRun(tb, "delete handler", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("foo", 0600)))
+		txn := newTransaction(tb, store, keyvalue.TransactionReadWrite)
+		var deleteResult *keyvalue.OpResult
+		op := txn.DeleteHandler("foo", keyvalue.OpHandlerFunc(func(txn keyvalue.Transaction, result keyvalue.OpResult) error {
+			deleteResult = &result
+			return nil
+		}))
+		_, err := txn.Commit(ctx)
+		assert.NoError(tb, err)
+		if assert.NotEqual(tb, (*keyvalue.OpResult)(nil), deleteResult) {
+			assert.Equal(tb, op, deleteResult.Op)
+			assert.NoError(tb, deleteResult.Err)
+		}
+		_, err = store.Get(ctx, "foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	tbRun(tb, "list prefix", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "bar", newFile("bar", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foo", newDir()))
+		assert.NoError(tb, store.Set(ctx, "foo/a", newFile("a", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foo/b", newDir()))
+		assert.NoError(tb, store.Set(ctx, "foo/b/c", newFile("c", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foobar", newFile("foobar", 0600)))
+
+		txn := newTransaction(tb, store, keyvalue.TransactionReadOnly)
+		txn.ListPrefix("foo/")
+		txn.ListPrefix("foo")
+		txn.ListPrefix("")
+		txn.ListPrefix("missing/")
+		results, err := txn.Commit(ctx)
+		if !assert.NoError(tb, err) || !assert.Equal(tb, 4, len(results)) {
+			return
+		}
+		for _, result := range results {
+			assert.NoError(tb, result.Err)
+		}
+		assert.Equal(tb, []string{"foo/a", "foo/b", "foo/b/c"}, results[0].Paths)
+		assert.Equal(tb, []string{"foo", "foo/a", "foo/b", "foo/b/c", "foobar"}, results[1].Paths)
+		assert.Equal(tb, []string{".", "bar", "foo", "foo/a", "foo/b", "foo/b/c", "foobar"}, results[2].Paths)
+		assert.Equal(tb, 0, len(results[3].Paths))
+	})
+
+	tbRun(tb, "list prefix handler", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newDir()))
+		assert.NoError(tb, store.Set(ctx, "foo/a", newFile("a", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foo/b", newFile("b", 0600)))
+		txn := newTransaction(tb, store, keyvalue.TransactionReadWrite)
+		txn.ListPrefixHandler("foo/", keyvalue.OpHandlerFunc(func(txn keyvalue.Transaction, result keyvalue.OpResult) error {
+			// remove everything listed, like RemoveAll
+			for _, p := range result.Paths {
+				txn.Delete(p)
+			}
+			return result.Err
+		}))
+		_, err := txn.Commit(ctx)
+		assert.NoError(tb, err)
+		assertDirNames(tb, store, "foo", nil)
+	})
+
+	tbRun(tb, "savepoint", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("old", 0600)))
+		assert.NoError(tb, store.Set(ctx, "baz", newFile("baz", 0600)))
+		txn, ok := newTransaction(tb, store, keyvalue.TransactionReadWrite).(keyvalue.SavepointTransaction)
+		if !ok {
+			tb.Skip("Transaction does not implement keyvalue.SavepointTransaction")
+		}
+		record := newFile("new", 0600)
+		txn.Set("foo", record, setContents(tb, record))
+		savepoint, err := txn.Savepoint()
+		assert.NoError(tb, err)
+		record = newFile("newer", 0644)
+		txn.Set("foo", record, setContents(tb, record))
+		later, err := txn.Savepoint()
+		assert.NoError(tb, err)
+		record = newFile("bar", 0600)
+		txn.Set("bar", record, setContents(tb, record))
+		txn.Delete("baz")
+
+		assert.NoError(tb, txn.RollbackTo(savepoint))
+		assert.ErrorIs(tb, keyvalue.ErrInvalidSavepoint, txn.RollbackTo(later)) // released by rolling back to an earlier savepoint
+		record = newFile("biff", 0600)
+		txn.Set("biff", record, setContents(tb, record))
+		_, err = txn.Commit(ctx)
+		assert.NoError(tb, err)
+
+		for name, contents := range map[string]string{"foo": "new", "baz": "baz", "biff": "biff"} {
+			record, err := store.Get(ctx, name)
+			if assert.NoError(tb, err) {
+				assertFile(tb, contents, 0600, record)
+			}
+		}
+		_, err = store.Get(ctx, "bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	tbRun(tb, "abort", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		txn := newTransaction(tb, store, keyvalue.TransactionReadWrite)
+		assert.NoError(tb, txn.Abort())
+		record := newFile("bar", 0600)
+		txn.Set("foo", record, setContents(tb, record)) // must not apply after Abort()
+
+		_, err := store.Get(ctx, "foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+}
+
+func testSetMetadata(tb testing.TB, newStore NewStoreFunc) {
+	newMetadataStore := func(tb testing.TB) keyvalue.MetadataStore {
+		tb.Helper()
+		store, ok := setupStore(tb, newStore).(keyvalue.MetadataStore)
+		if !ok {
+			tb.Skip("Store does not implement keyvalue.MetadataStore")
+		}
+		return store
+	}
+
+	tbRun(tb, "file", func(tb testing.TB) {
+		store := newMetadataStore(tb)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("bar", 0600)))
+		newModTime := modTime.Add(time.Hour)
+		metadataRecord := keyvalue.NewBaseFileRecord(0, newModTime, 0644, nil, func() (blob.Blob, error) {
+			tb.Error("SetMetadata must not call Data()")
+			return nil, hackpadfs.ErrNotImplemented
+		}, nil)
+		assert.NoError(tb, store.SetMetadata(ctx, "foo", metadataRecord))
+
+		record, err := store.Get(ctx, "foo")
+		if !assert.NoError(tb, err) {
+			return
+		}
+		assert.Equal(tb, hackpadfs.FileMode(0644), record.Mode())
+		if !record.ModTime().Equal(newModTime) {
+			tb.Errorf("ModTime() = %v, want %v", record.ModTime(), newModTime)
+		}
+		data, err := record.Data()
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, "bar", string(data.Bytes()))
+		}
+	})
+
+	tbRun(tb, "not exist", func(tb testing.TB) {
+		store := newMetadataStore(tb)
+		err := store.SetMetadata(context.Background(), "foo", newFile("", 0600))
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+}
+
+func testSetVersion(tb testing.TB, newStore NewStoreFunc) {
+	newVersionedStore := func(tb testing.TB) keyvalue.VersionedStore {
+		tb.Helper()
+		store, ok := setupStore(tb, newStore).(keyvalue.VersionedStore)
+		if !ok {
+			tb.Skip("Store does not implement keyvalue.VersionedStore")
+		}
+		return store
+	}
+	getVersion := func(tb testing.TB, store keyvalue.Store, name string) string {
+		tb.Helper()
+		record, err := store.Get(context.Background(), name)
+		if !assert.NoError(tb, err) {
+			return ""
+		}
+		versioned, ok := record.(keyvalue.VersionedFileRecord)
+		if !ok {
+			tb.Errorf("Get() returned %T, which does not implement keyvalue.VersionedFileRecord", record)
+			return ""
+		}
+		return versioned.Version()
+	}
+
+	tbRun(tb, "create", func(tb testing.TB) {
+		store := newVersionedStore(tb)
+		version, err := store.SetVersion(context.Background(), "foo", newFile("bar", 0600), "")
+		assert.NoError(tb, err)
+		assert.NotEqual(tb, "", version)
+		assert.Equal(tb, version, getVersion(tb, store, "foo"))
+	})
+
+	tbRun(tb, "create exists", func(tb testing.TB) {
+		store := newVersionedStore(tb)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("bar", 0600)))
+		_, err := store.SetVersion(ctx, "foo", newFile("baz", 0600), "")
+		assert.ErrorIs(tb, keyvalue.ErrVersionConflict, err)
+	})
+
+	tbRun(tb, "matching version", func(tb testing.TB) {
+		store := newVersionedStore(tb)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("bar", 0600)))
+		version := getVersion(tb, store, "foo")
+		newVersion, err := store.SetVersion(ctx, "foo", newFile("baz", 0600), version)
+		assert.NoError(tb, err)
+		assert.NotEqual(tb, version, newVersion)
+		assert.Equal(tb, newVersion, getVersion(tb, store, "foo"))
+		record, err := store.Get(ctx, "foo")
+		if assert.NoError(tb, err) {
+			assertFile(tb, "baz", 0600, record)
+		}
+	})
+
+	tbRun(tb, "conflict", func(tb testing.TB) {
+		store := newVersionedStore(tb)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("bar", 0600)))
+		version := getVersion(tb, store, "foo")
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("baz", 0600)))
+
+		_, err := store.SetVersion(ctx, "foo", newFile("lost update", 0600), version)
+		assert.ErrorIs(tb, keyvalue.ErrVersionConflict, err)
+		var conflictErr *keyvalue.VersionConflictError
+		if assert.Equal(tb, true, errors.As(err, &conflictErr)) {
+			assert.Equal(tb, "foo", conflictErr.Path)
+			assert.Equal(tb, version, conflictErr.Version)
+		}
+		record, err := store.Get(ctx, "foo")
+		if assert.NoError(tb, err) {
+			assertFile(tb, "baz", 0600, record)
+		}
+	})
+
+	tbRun(tb, "removed", func(tb testing.TB) {
+		store := newVersionedStore(tb)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("bar", 0600)))
+		version := getVersion(tb, store, "foo")
+		assert.NoError(tb, store.Set(ctx, "foo", nil))
+
+		_, err := store.SetVersion(ctx, "foo", newFile("baz", 0600), version)
+		assert.ErrorIs(tb, keyvalue.ErrVersionConflict, err)
+		_, err = store.Get(ctx, "foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+}
+
+func testDiskUsage(tb testing.TB, newStore NewStoreFunc) {
+	newUsageStore := func(tb testing.TB) keyvalue.UsageStore {
+		tb.Helper()
+		store, ok := setupStore(tb, newStore).(keyvalue.UsageStore)
+		if !ok {
+			tb.Skip("Store does not implement keyvalue.UsageStore")
+		}
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newDir()))
+		assert.NoError(tb, store.Set(ctx, "foo/bar", newFile("bar", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foo/baz", newDir()))
+		assert.NoError(tb, store.Set(ctx, "foo/baz/biff", newFile("biff", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foobar", newFile("foobar", 0600)))
+		return store
+	}
+	assertUsage := func(tb testing.TB, store keyvalue.UsageStore, path string, files, dirs, bytes int64) {
+		tb.Helper()
+		actualFiles, actualDirs, actualBytes, err := store.DiskUsage(context.Background(), path)
+		assert.NoError(tb, err)
+		assert.Equal(tb, [3]int64{files, dirs, bytes}, [3]int64{actualFiles, actualDirs, actualBytes})
+	}
+
+	tbRun(tb, "root", func(tb testing.TB) {
+		store := newUsageStore(tb)
+		assertUsage(tb, store, ".", 3, 3, 13)
+	})
+
+	tbRun(tb, "directory", func(tb testing.TB) {
+		store := newUsageStore(tb)
+		assertUsage(tb, store, "foo", 2, 2, 7)
+		assertUsage(tb, store, "foo/baz", 1, 1, 4)
+	})
+
+	tbRun(tb, "file", func(tb testing.TB) {
+		store := newUsageStore(tb)
+		assertUsage(tb, store, "foobar", 1, 0, 6)
+	})
+
+	tbRun(tb, "not exist", func(tb testing.TB) {
+		store := newUsageStore(tb)
+		_, _, _, err := store.DiskUsage(context.Background(), "missing")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+}
+
+func testID(tb testing.TB, newStore NewStoreFunc) {
+	// getID returns the ID of the record at 'path', skipping the test if the store doesn't keep IDs
+	getID := func(tb testing.TB, store keyvalue.Store, path string) string {
+		tb.Helper()
+		record, err := store.Get(context.Background(), path)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		id := keyvalue.RecordID(record)
+		if id == "" {
+			tb.Skip("Store does not keep file IDs")
+		}
+		return id
+	}
+
+	tbRun(tb, "file", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		assert.NoError(tb, store.Set(context.Background(), "foo", keyvalue.WithID(newFile("bar", 0600), "foo-id")))
+		assert.Equal(tb, "foo-id", getID(tb, store, "foo"))
+		record, err := store.Get(context.Background(), "foo")
+		if assert.NoError(tb, err) {
+			assertFile(tb, "bar", 0600, record)
+		}
+	})
+
+	tbRun(tb, "directory", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		assert.NoError(tb, store.Set(context.Background(), "foo", keyvalue.WithID(newDir(), "foo-id")))
+		assert.Equal(tb, "foo-id", getID(tb, store, "foo"))
+	})
+
+	tbRun(tb, "SetMetadata", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", keyvalue.WithID(newFile("bar", 0600), "foo-id")))
+		getID(tb, store, "foo")
+		metadataStore, ok := store.(keyvalue.MetadataStore)
+		if !ok {
+			tb.Skip("Store does not implement keyvalue.MetadataStore")
+		}
+		assert.NoError(tb, metadataStore.SetMetadata(ctx, "foo", keyvalue.WithID(newFile("", 0644), "foo-id")))
+		assert.Equal(tb, "foo-id", getID(tb, store, "foo"))
+	})
+}
+
+func testWatch(tb testing.TB, newStore NewStoreFunc) {
+	// watch returns a channel of events under 'prefix' until cancel is called or the test ends
+	watch := func(tb testing.TB, store keyvalue.Store, prefix string) (events <-chan keyvalue.Event, cancel func()) {
+		tb.Helper()
+		watchStore, ok := store.(keyvalue.WatchableStore)
+		if !ok {
+			tb.Skip("Store does not implement keyvalue.WatchableStore")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		tb.Cleanup(cancel)
+		events, err := watchStore.Watch(ctx, prefix)
+		if errors.Is(err, hackpadfs.ErrNotImplemented) {
+			tb.Skip("Store can not watch with this configuration")
+		}
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		return events, cancel
+	}
+	receive := func(tb testing.TB, events <-chan keyvalue.Event) keyvalue.Event {
+		tb.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			tb.Fatal("Timed out waiting for event")
+			return keyvalue.Event{}
+		}
+	}
+
+	tbRun(tb, "set and delete", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		events, _ := watch(tb, store, ".")
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foo", newFile("bar", 0600)))
+		assert.Equal(tb, keyvalue.Event{Path: "foo"}, receive(tb, events))
+		assert.NoError(tb, store.Set(ctx, "foo", nil))
+		assert.Equal(tb, keyvalue.Event{Path: "foo", Deleted: true}, receive(tb, events))
+	})
+
+	tbRun(tb, "prefix", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		events, _ := watch(tb, store, "foo")
+		ctx := context.Background()
+		assert.NoError(tb, store.Set(ctx, "foobar", newFile("foobar", 0600)))
+		assert.NoError(tb, store.Set(ctx, "foo", newDir()))
+		assert.NoError(tb, store.Set(ctx, "foo/bar", newFile("bar", 0600)))
+		assert.Equal(tb, keyvalue.Event{Path: "foo"}, receive(tb, events))
+		assert.Equal(tb, keyvalue.Event{Path: "foo/bar"}, receive(tb, events))
+	})
+
+	tbRun(tb, "closes when done", func(tb testing.TB) {
+		store := setupStore(tb, newStore)
+		events, cancel := watch(tb, store, ".")
+		cancel()
+		for range events {
+		}
+	})
+}
@@ -0,0 +1,277 @@
+package keyvalue
+
+import (
+	"context"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+// TxnFS is a view of an FS which stages every change in memory until Commit, so a group of changes to many files is saved all at once or not at all.
+// Reads see the view's own staged changes on top of the FS's current files.
+type TxnFS struct {
+	*FS
+	base    *FS
+	staging *stagingStore
+}
+
+// Begin returns a new TxnFS view of 'fs'. Changes made through the view aren't visible in 'fs' until the view is committed.
+//
+// Commit applies the changes in a single transaction if the FS's store is a TransactionStore.
+// Otherwise, changes are applied one at a time and undone if any of them fails, though a crash during Commit can still leave some of them applied.
+// Changes made to 'fs' after Begin aren't detected as conflicts: the view's changes overwrite them.
+func (fs *FS) Begin() (*TxnFS, error) {
+	staging := &stagingStore{
+		base:   fs.store.store,
+		staged: make(map[string]*stagedRecord),
+	}
+	view, err := NewFSWithOptions(staging, fs.options)
+	if err != nil {
+		return nil, err
+	}
+	return &TxnFS{FS: view, base: fs, staging: staging}, nil
+}
+
+// Commit saves the view's changes to its FS. The view can't be used again afterward.
+func (t *TxnFS) Commit(ctx context.Context) error {
+	changes, err := t.staging.finish()
+	if err != nil {
+		return &hackpadfs.PathError{Op: "commit", Path: ".", Err: err}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	raw := &transactionOnly{store: t.base.store.store} // changes were staged after hooks and name normalization ran, so apply them as-is
+	txn, hasSavepoints, err := raw.savepointTransaction("commit", TransactionOptions{Mode: TransactionReadWrite})
+	if err != nil {
+		return err
+	}
+	var savepoint Savepoint
+	if hasSavepoints {
+		savepoint, err = txn.(SavepointTransaction).Savepoint()
+		if err != nil {
+			_ = txn.Abort()
+			return err
+		}
+	}
+	var (
+		errMu    sync.Mutex
+		firstErr error
+	)
+	failed := func() error {
+		errMu.Lock()
+		defer errMu.Unlock()
+		return firstErr
+	}
+	undoOnErr := OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		errMu.Lock()
+		defer errMu.Unlock()
+		if result.Err == nil || firstErr != nil {
+			return nil
+		}
+		firstErr = result.Err
+		if hasSavepoints {
+			if err := txn.(SavepointTransaction).RollbackTo(savepoint); err != nil {
+				firstErr = err
+			}
+			return nil
+		}
+		return txn.Abort()
+	})
+
+	for _, change := range changes {
+		if failed() != nil {
+			break
+		}
+		if change.record == nil {
+			txn.DeleteHandler(change.path, undoOnErr)
+		} else {
+			txn.SetHandler(change.path, change.record.FileRecord, change.record.contents, undoOnErr)
+		}
+	}
+	_, err = txn.Commit(ctx)
+	if err := failed(); err != nil {
+		return err
+	}
+	return err
+}
+
+// Abort discards the view's changes. The view can't be used again afterward.
+func (t *TxnFS) Abort() error {
+	_, err := t.staging.finish()
+	if err != nil {
+		return &hackpadfs.PathError{Op: "abort", Path: ".", Err: err}
+	}
+	return nil
+}
+
+// stagingStore holds changes to 'base' in memory. A nil staged record marks a deleted path.
+type stagingStore struct {
+	base Store
+
+	mu     sync.Mutex
+	staged map[string]*stagedRecord
+	done   bool
+}
+
+// stagedRecord is a copy of a FileRecord set in a stagingStore, with its contents for regular files
+type stagedRecord struct {
+	FileRecord
+	contents blob.Blob
+}
+
+// stagedChange is a staged Set, or a Delete if 'record' is nil
+type stagedChange struct {
+	path   string
+	record *stagedRecord
+}
+
+func (s *stagingStore) Get(ctx context.Context, p string) (FileRecord, error) {
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return nil, hackpadfs.ErrClosed
+	}
+	staged, isStaged := s.staged[p]
+	s.mu.Unlock()
+
+	var record FileRecord
+	switch {
+	case isStaged && staged == nil:
+		return nil, hackpadfs.ErrNotExist
+	case isStaged:
+		record = staged.FileRecord
+	default:
+		var err error
+		record, err = s.base.Get(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	getData := record.Data
+	var getDirNames func() ([]string, error)
+	switch {
+	case record.Mode().IsDir():
+		var baseRecord FileRecord
+		if !isStaged {
+			baseRecord = record
+		}
+		getDirNames = func() ([]string, error) {
+			return s.readDirNames(p, baseRecord)
+		}
+	case isStaged:
+		return record, nil
+	default:
+		getData = func() (blob.Blob, error) {
+			data, err := record.Data()
+			if err != nil {
+				return nil, err
+			}
+			return blob.Clone(data) // file handles may change their contents in place, which must not reach the base store
+		}
+	}
+	viewRecord := NewBaseFileRecord(record.Size(), record.ModTime(), record.Mode(), record.Sys(), getData, getDirNames)
+	if id := RecordID(record); id != "" {
+		return WithID(viewRecord, id), nil
+	}
+	return viewRecord, nil
+}
+
+// readDirNames returns the names in directory 'dir', combining staged changes with the names in 'baseRecord', if it's from the base store
+func (s *stagingStore) readDirNames(dir string, baseRecord FileRecord) ([]string, error) {
+	names := make(map[string]bool)
+	if baseRecord != nil {
+		baseNames, err := baseRecord.ReadDirNames()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range baseNames {
+			names[name] = true
+		}
+	}
+	s.mu.Lock()
+	for p, staged := range s.staged {
+		if p == "." || path.Dir(p) != dir {
+			continue
+		}
+		name := path.Base(p)
+		if staged == nil {
+			delete(names, name)
+		} else {
+			names[name] = true
+		}
+	}
+	s.mu.Unlock()
+
+	dirNames := make([]string, 0, len(names))
+	for name := range names {
+		dirNames = append(dirNames, name)
+	}
+	sort.Strings(dirNames)
+	return dirNames, nil
+}
+
+func (s *stagingStore) Set(ctx context.Context, p string, src FileRecord) error {
+	var staged *stagedRecord
+	if src != nil {
+		var contents blob.Blob = blob.NewBytes(nil)
+		if src.Mode().IsRegular() {
+			data, err := src.Data()
+			if err == nil {
+				contents, err = blob.Clone(data) // the FS may keep changing data's buffer after Set returns
+			}
+			if err != nil {
+				return err
+			}
+		}
+		getContents := func() (blob.Blob, error) { return contents, nil }
+		var record FileRecord = NewBaseFileRecord(int64(contents.Len()), src.ModTime(), src.Mode(), src.Sys(), getContents, nil)
+		if id := RecordID(src); id != "" {
+			record = WithID(record, id)
+		}
+		staged = &stagedRecord{FileRecord: record}
+		if src.Mode().IsRegular() {
+			staged.contents = contents
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return hackpadfs.ErrClosed
+	}
+	s.staged[p] = staged
+	return nil
+}
+
+// finish closes the store to further changes and returns its staged changes, ordered to remove children before their parents and create parents before their children
+func (s *stagingStore) finish() ([]stagedChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return nil, hackpadfs.ErrClosed
+	}
+	s.done = true
+
+	var deletes, sets []stagedChange
+	for p, record := range s.staged {
+		change := stagedChange{path: p, record: record}
+		if record == nil {
+			deletes = append(deletes, change)
+		} else {
+			sets = append(sets, change)
+		}
+	}
+	sort.Slice(deletes, func(a, b int) bool {
+		return deletes[a].path > deletes[b].path
+	})
+	sort.Slice(sets, func(a, b int) bool {
+		return sets[a].path < sets[b].path
+	})
+	s.staged = nil
+	return append(deletes, sets...), nil
+}
@@ -0,0 +1,125 @@
+package keyvalue_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestFSTxnView(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "keyvalue transaction",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := keyvalue.NewFS(mem.NewStore())
+			if err != nil {
+				tb.Fatal(err)
+			}
+			txn, err := fs.Begin()
+			if err != nil {
+				tb.Fatal(err)
+			}
+			return txn
+		},
+		Constraints: fstest.Constraints{SortedReadDir: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func readDirNames(tb testing.TB, fs hackpadfs.FS, name string) []string {
+	tb.Helper()
+	entries, err := hackpadfs.ReadDir(fs, name)
+	assert.NoError(tb, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestBeginCommit(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		store       keyvalue.Store
+	}{
+		{description: "transaction store", store: mem.NewStore()},
+		{description: "serial store", store: &failingStore{Store: mem.NewStore()}},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			fs, err := keyvalue.NewFS(tc.store)
+			assert.NoError(t, err)
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, "old", []byte("old"), 0600))
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, "keep", []byte("keep"), 0600))
+
+			txn, err := fs.Begin()
+			assert.NoError(t, err)
+			assert.NoError(t, hackpadfs.Mkdir(txn, "dir", 0700))
+			assert.NoError(t, hackpadfs.WriteFullFile(txn, "dir/new", []byte("new"), 0600))
+			assert.NoError(t, hackpadfs.WriteFullFile(txn, "keep", []byte("changed"), 0600))
+			assert.NoError(t, hackpadfs.Remove(txn, "old"))
+
+			assert.Equal(t, []string{"dir", "keep"}, readDirNames(t, txn, "."))
+			assert.Equal(t, []string{"keep", "old"}, readDirNames(t, fs, "."))
+			contents, err := hackpadfs.ReadFile(fs, "keep")
+			assert.NoError(t, err)
+			assert.Equal(t, "keep", string(contents))
+
+			assert.NoError(t, txn.Commit(context.Background()))
+			assert.Equal(t, []string{"dir", "keep"}, readDirNames(t, fs, "."))
+			contents, err = hackpadfs.ReadFile(fs, "dir/new")
+			assert.NoError(t, err)
+			assert.Equal(t, "new", string(contents))
+			contents, err = hackpadfs.ReadFile(fs, "keep")
+			assert.NoError(t, err)
+			assert.Equal(t, "changed", string(contents))
+
+			assert.ErrorIs(t, hackpadfs.ErrClosed, txn.Commit(context.Background()))
+			_, err = hackpadfs.Stat(txn, "keep")
+			assert.ErrorIs(t, hackpadfs.ErrClosed, err)
+		})
+	}
+}
+
+func TestBeginAbort(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(mem.NewStore())
+	assert.NoError(t, err)
+	txn, err := fs.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(txn, "foo", []byte("foo"), 0600))
+
+	assert.NoError(t, txn.Abort())
+	assert.ErrorIs(t, hackpadfs.ErrClosed, txn.Abort())
+	_, err = hackpadfs.Stat(fs, "foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestBeginCommitFailure(t *testing.T) {
+	t.Parallel()
+	store := &failingStore{Store: mem.NewStore(), failPath: "b"}
+	fs, err := keyvalue.NewFS(store)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "a", []byte("a"), 0600))
+
+	txn, err := fs.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(txn, "a", []byte("changed"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(txn, "b", []byte("b"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(txn, "c", []byte("c"), 0600))
+
+	assert.ErrorIs(t, errSetFailed, txn.Commit(context.Background()))
+	assert.Equal(t, []string{"a"}, readDirNames(t, fs, "."))
+	contents, err := hackpadfs.ReadFile(fs, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(contents))
+}
@@ -0,0 +1,160 @@
+package keyvalue
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+// Savepoint marks a point in a SavepointTransaction to roll back to.
+type Savepoint int
+
+// SavepointTransaction is a Transaction which can undo some of its changes without aborting, like SQL's SAVEPOINT and ROLLBACK TO.
+// Compound operations, like renaming a directory tree, use savepoints to undo their partial changes when one step fails.
+type SavepointTransaction interface {
+	Transaction
+	// Savepoint marks the transaction's current state.
+	Savepoint() (Savepoint, error)
+	// RollbackTo undoes every Set and Delete run after 'savepoint' was created, then releases any later savepoints.
+	// The transaction stays open: 'savepoint' may be rolled back to again, and the transaction must still be committed or aborted.
+	RollbackTo(savepoint Savepoint) error
+}
+
+// ErrInvalidSavepoint is returned by SavepointTransaction.RollbackTo when the savepoint was released or belongs to another transaction.
+var ErrInvalidSavepoint = errors.New("invalid savepoint")
+
+// WithSavepoints returns 'txn' as a SavepointTransaction.
+// If 'txn' doesn't support savepoints, they are emulated by reading each file from 'store' before it is changed, then writing the old files back on rollback.
+//
+// The emulation reads 'store' outside of 'txn', so it only suits transactions which apply their changes immediately, like the fallback from TransactionOrSerial.
+// Stores with isolated transactions should implement SavepointTransaction themselves, or rely on Abort.
+func WithSavepoints(txn Transaction, store Store) SavepointTransaction {
+	if txn, ok := txn.(SavepointTransaction); ok {
+		return txn
+	}
+	return &savepointTransaction{txn: txn, store: store}
+}
+
+type savepointTransaction struct {
+	txn   Transaction
+	store Store
+	undo  []undoOp
+	err   error // err is the first failure to record an undoOp, reported by RollbackTo
+}
+
+// undoOp restores the file at 'path' to 'record' and 'contents'. A nil 'record' deletes the file.
+type undoOp struct {
+	path     string
+	record   FileRecord
+	contents blob.Blob
+}
+
+// save records how to undo a change to 'path'
+func (s *savepointTransaction) save(path string) {
+	op := undoOp{path: path}
+	record, err := s.store.Get(context.Background(), path)
+	switch {
+	case errors.Is(err, hackpadfs.ErrNotExist):
+	case err != nil:
+		s.setErr(err)
+		return
+	default:
+		var contents blob.Blob = blob.NewBytes(nil)
+		if record.Mode().IsRegular() {
+			data, err := record.Data()
+			if err == nil {
+				contents, err = blob.Clone(data) // the store may reuse data's buffer for the new contents
+			}
+			if err != nil {
+				s.setErr(err)
+				return
+			}
+			op.contents = contents
+		}
+		getContents := func() (blob.Blob, error) { return contents, nil }
+		op.record = NewBaseFileRecord(record.Size(), record.ModTime(), record.Mode(), record.Sys(), getContents, record.ReadDirNames)
+	}
+	s.undo = append(s.undo, op)
+}
+
+func (s *savepointTransaction) setErr(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// handler passes 's' to 'handler' instead of the wrapped transaction, so changes made by handlers can be rolled back too
+func (s *savepointTransaction) handler(handler OpHandler) OpHandler {
+	return OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		return handler.Handle(s, result)
+	})
+}
+
+func (s *savepointTransaction) Get(path string) OpID {
+	return s.txn.Get(path)
+}
+
+func (s *savepointTransaction) GetHandler(path string, handler OpHandler) OpID {
+	return s.txn.GetHandler(path, s.handler(handler))
+}
+
+func (s *savepointTransaction) Set(path string, src FileRecord, contents blob.Blob) OpID {
+	s.save(path)
+	return s.txn.Set(path, src, contents)
+}
+
+func (s *savepointTransaction) SetHandler(path string, src FileRecord, contents blob.Blob, handler OpHandler) OpID {
+	s.save(path)
+	return s.txn.SetHandler(path, src, contents, s.handler(handler))
+}
+
+func (s *savepointTransaction) Delete(path string) OpID {
+	s.save(path)
+	return s.txn.Delete(path)
+}
+
+func (s *savepointTransaction) DeleteHandler(path string, handler OpHandler) OpID {
+	s.save(path)
+	return s.txn.DeleteHandler(path, s.handler(handler))
+}
+
+func (s *savepointTransaction) ListPrefix(prefix string) OpID {
+	return s.txn.ListPrefix(prefix)
+}
+
+func (s *savepointTransaction) ListPrefixHandler(prefix string, handler OpHandler) OpID {
+	return s.txn.ListPrefixHandler(prefix, s.handler(handler))
+}
+
+func (s *savepointTransaction) Commit(ctx context.Context) ([]OpResult, error) {
+	return s.txn.Commit(ctx)
+}
+
+func (s *savepointTransaction) Abort() error {
+	return s.txn.Abort()
+}
+
+func (s *savepointTransaction) Savepoint() (Savepoint, error) {
+	return Savepoint(len(s.undo)), s.err
+}
+
+func (s *savepointTransaction) RollbackTo(savepoint Savepoint) error {
+	if s.err != nil {
+		return s.err
+	}
+	if savepoint < 0 || int(savepoint) > len(s.undo) {
+		return ErrInvalidSavepoint
+	}
+	for i := len(s.undo) - 1; i >= int(savepoint); i-- {
+		op := s.undo[i]
+		if op.record == nil {
+			s.txn.Delete(op.path)
+		} else {
+			s.txn.Set(op.path, op.record, op.contents)
+		}
+	}
+	s.undo = s.undo[:savepoint]
+	return nil
+}
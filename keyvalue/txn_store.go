@@ -2,9 +2,14 @@ package keyvalue
 
 import (
 	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 
+	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/keyvalue/blob"
 )
 
@@ -28,13 +33,14 @@ type TransactionOptions struct {
 	Mode TransactionMode
 }
 
-// OpID is a unique ID within the transaction that generated it. It's used to correlate which Get/Set operation produced which result.
+// OpID is a unique ID within the transaction that generated it. It's used to correlate which operation produced which result.
 type OpID int64
 
 // OpResult is returned from Transaction.Commit(), representing an operation's result with any data or error it produced.
 type OpResult struct {
 	Op     OpID
 	Record FileRecord
+	Paths  []string // Paths holds the sorted paths found by a ListPrefix operation
 	Err    error
 }
 
@@ -53,13 +59,21 @@ func (o OpHandlerFunc) Handle(txn Transaction, result OpResult) error {
 }
 
 // Transaction behaves like a Store but only returns results after running Commit().
-// GetHandler and SetHandler can be used to interrupt transaction processing and handle the response,
+// The *Handler variants can be used to interrupt transaction processing and handle the response,
 // permitting an opportunity to Abort() or perform more operations.
 type Transaction interface {
 	Get(path string) OpID
 	GetHandler(path string, handler OpHandler) OpID
 	Set(path string, src FileRecord, contents blob.Blob) OpID
 	SetHandler(path string, src FileRecord, contents blob.Blob, handler OpHandler) OpID
+	// Delete removes the file at 'path'. Deleting a path which does not exist is not an error.
+	// Equivalent to Set(path, nil, nil).
+	Delete(path string) OpID
+	DeleteHandler(path string, handler OpHandler) OpID
+	// ListPrefix finds every stored path beginning with 'prefix' and returns them sorted in OpResult.Paths.
+	// Use a prefix of dir+"/" to list everything inside dir, or "" to list every path including the root ".".
+	ListPrefix(prefix string) OpID
+	ListPrefixHandler(prefix string, handler OpHandler) OpID
 	Commit(ctx context.Context) ([]OpResult, error)
 	Abort() error
 }
@@ -162,6 +176,82 @@ func (u *unsafeSerialTransaction) SetHandler(path string, src FileRecord, conten
 	return op
 }
 
+func (u *unsafeSerialTransaction) Delete(path string) OpID {
+	return u.DeleteHandler(path, OpHandlerFunc(func(txn Transaction, result OpResult) error {
+		return nil
+	}))
+}
+
+func (u *unsafeSerialTransaction) DeleteHandler(path string, handler OpHandler) OpID {
+	return u.SetHandler(path, nil, nil, handler)
+}
+
+func (u *unsafeSerialTransaction) ListPrefix(prefix string) OpID {
+	return u.ListPrefixHandler(prefix, OpHandlerFunc(func(txn Transaction, result OpResult) error {
+		return nil
+	}))
+}
+
+func (u *unsafeSerialTransaction) ListPrefixHandler(prefix string, handler OpHandler) OpID {
+	op := u.newOp()
+	if err := abortErr(u.ctx, nil); err != nil {
+		u.setResult(op, OpResult{Op: op, Err: err})
+		return op
+	}
+
+	paths, err := listPrefix(u.ctx, u.store, prefix)
+	result := OpResult{Op: op, Paths: paths, Err: err}
+	err = handler.Handle(u, result)
+	if result.Err == nil && err != nil {
+		result.Err = err
+	}
+	u.setResult(op, result)
+	return op
+}
+
+// listPrefix walks the directories of 'store' to find the paths beginning with 'prefix', for Stores which can't list them directly
+func listPrefix(ctx context.Context, store Store, prefix string) ([]string, error) {
+	dir := "."
+	if i := strings.LastIndexByte(prefix, '/'); i >= 0 {
+		dir = prefix[:i]
+	}
+	var paths []string
+	err := walkPrefix(ctx, store, dir, prefix, &paths)
+	if errors.Is(err, hackpadfs.ErrNotExist) {
+		err = nil // nothing is stored under prefix
+	}
+	sort.Strings(paths)
+	return paths, err
+}
+
+func walkPrefix(ctx context.Context, store Store, p, prefix string, paths *[]string) error {
+	if strings.HasPrefix(p, prefix) {
+		*paths = append(*paths, p)
+	}
+	record, err := store.Get(ctx, p)
+	if err != nil {
+		return err
+	}
+	if !record.Mode().IsDir() {
+		return nil
+	}
+	names, err := record.ReadDirNames()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		child := path.Join(p, name)
+		if !strings.HasPrefix(child, prefix) && !strings.HasPrefix(prefix, child+"/") {
+			continue
+		}
+		err := walkPrefix(ctx, store, child, prefix, paths)
+		if err != nil && !errors.Is(err, hackpadfs.ErrNotExist) { // tolerate concurrent removals
+			return err
+		}
+	}
+	return nil
+}
+
 func (u *unsafeSerialTransaction) Commit(ctx context.Context) ([]OpResult, error) {
 	if err := abortErr(u.ctx, ctx); err != nil {
 		return nil, err
@@ -0,0 +1,145 @@
+package keyvalue
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// Watchers delivers a store's Events to the channels returned by Watch. Useful for implementing WatchableStore.
+// Send never blocks, so stores can call it while holding their own locks. Events are queued for each watcher until it receives them.
+//
+// The zero value is ready to use.
+type Watchers struct {
+	mu       sync.Mutex
+	watchers map[*watcher]bool
+}
+
+type watcher struct {
+	prefix string
+
+	mu    sync.Mutex
+	queue []Event
+	ready chan struct{} // ready holds a value while 'queue' has events to send
+}
+
+// Watch implements WatchableStore
+func (w *Watchers) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	if !hackpadfs.ValidPath(prefix) {
+		return nil, hackpadfs.ErrInvalid
+	}
+	newWatcher := &watcher{
+		prefix: prefix,
+		ready:  make(chan struct{}, 1),
+	}
+	w.mu.Lock()
+	if w.watchers == nil {
+		w.watchers = make(map[*watcher]bool)
+	}
+	w.watchers[newWatcher] = true
+	w.mu.Unlock()
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer func() {
+			w.mu.Lock()
+			delete(w.watchers, newWatcher)
+			w.mu.Unlock()
+		}()
+		newWatcher.run(ctx, events)
+	}()
+	return events, nil
+}
+
+// Send queues 'events' for each watcher with a matching prefix
+func (w *Watchers) Send(events ...Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for watcher := range w.watchers {
+		watcher.send(events)
+	}
+}
+
+func (w *watcher) send(events []Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, event := range events {
+		if watchMatch(w.prefix, event) {
+			w.queue = append(w.queue, event)
+		}
+	}
+	if len(w.queue) > 0 {
+		select {
+		case w.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *watcher) run(ctx context.Context, events chan<- Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.ready:
+		}
+		w.mu.Lock()
+		queue := w.queue
+		w.queue = nil
+		w.mu.Unlock()
+		for _, event := range queue {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// watchMatch returns true if 'event' changes a record at or under 'prefix'
+func watchMatch(prefix string, event Event) bool {
+	switch {
+	case prefix == ".", event.Path == prefix:
+		return true
+	case event.Path == ".":
+		return event.Deleted // removing every record removes those under 'prefix' too
+	default:
+		return strings.HasPrefix(event.Path, prefix+"/")
+	}
+}
+
+// Watch implements hackpadfs.WatchFS
+// Fails with hackpadfs.ErrNotImplemented if the store isn't a WatchableStore. Event names are the store's paths, so they're normalized with Options.NormalizeName.
+func (fs *FS) Watch(ctx context.Context, name string) (<-chan hackpadfs.Event, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "watch", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	store, ok := fs.store.store.(WatchableStore)
+	if !ok {
+		return nil, &hackpadfs.PathError{Op: "watch", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	storeEvents, err := store.Watch(ctx, fs.store.storePath(name))
+	if err != nil {
+		return nil, fs.wrapperErr("watch", name, err)
+	}
+	events := make(chan hackpadfs.Event)
+	go func() {
+		defer close(events)
+		for storeEvent := range storeEvents {
+			event := hackpadfs.Event{Name: fsPath(fs.store.namespace, storeEvent.Path), Op: hackpadfs.EventWrite}
+			if storeEvent.Deleted {
+				event.Op = hackpadfs.EventRemove
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,104 @@
+package keyvalue_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+// receiveUntil returns the events from 'events' up to and including 'last'
+func receiveUntil(tb testing.TB, events <-chan hackpadfs.Event, last hackpadfs.Event) []hackpadfs.Event {
+	tb.Helper()
+	var received []hackpadfs.Event
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				tb.Fatal("Events closed before receiving:", last)
+			}
+			received = append(received, event)
+			if event == last {
+				return received
+			}
+		case <-timeout:
+			tb.Fatal("Timed out waiting for event:", last, "Received:", received)
+		}
+	}
+}
+
+func TestWatchers(t *testing.T) {
+	t.Parallel()
+	var watchers keyvalue.Watchers
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := watchers.Watch(ctx, "foo")
+	assert.NoError(t, err)
+	watchers.Send(
+		keyvalue.Event{Path: "foo"},
+		keyvalue.Event{Path: "foo/bar", Deleted: true},
+		keyvalue.Event{Path: "foobar"},
+		keyvalue.Event{Path: "."},
+		keyvalue.Event{Path: ".", Deleted: true},
+	)
+	var received []keyvalue.Event
+	for len(received) < 3 {
+		received = append(received, <-events)
+	}
+	assert.Equal(t, []keyvalue.Event{
+		{Path: "foo"},
+		{Path: "foo/bar", Deleted: true},
+		{Path: ".", Deleted: true},
+	}, received)
+
+	cancel()
+	for range events {
+	}
+	watchers.Send(keyvalue.Event{Path: "foo"}) // no watchers remain
+
+	_, err = watchers.Watch(context.Background(), "/foo")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
+
+func TestFSWatch(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(mem.NewStore())
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Mkdir("dir", 0700))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := hackpadfs.Watch(ctx, fs, "dir")
+	assert.NoError(t, err)
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/foo", []byte("foo"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "other", nil, 0600))
+	assert.NoError(t, fs.Rename("dir/foo", "dir/bar"))
+	assert.NoError(t, fs.Remove("dir/bar"))
+	assert.NoError(t, fs.Chmod("dir", 0755))
+	assert.Equal(t, []hackpadfs.Event{
+		{Name: "dir/foo", Op: hackpadfs.EventWrite},
+		{Name: "dir/foo", Op: hackpadfs.EventWrite},
+		{Name: "dir/bar", Op: hackpadfs.EventWrite},
+		{Name: "dir/foo", Op: hackpadfs.EventRemove},
+		{Name: "dir/bar", Op: hackpadfs.EventRemove},
+		{Name: "dir", Op: hackpadfs.EventWrite},
+	}, receiveUntil(t, events, hackpadfs.Event{Name: "dir", Op: hackpadfs.EventWrite}))
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestFSWatchNotImplemented(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(&countingStore{Store: mem.NewStore()})
+	assert.NoError(t, err)
+	_, err = fs.Watch(context.Background(), ".")
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+	_, err = fs.Watch(context.Background(), "/")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
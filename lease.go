@@ -0,0 +1,219 @@
+package hackpadfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrLeaseLost is returned when a Lease's file was removed or taken over by another holder
+var ErrLeaseLost = errors.New("lease lost")
+
+var (
+	leaseRandMu sync.Mutex
+	leaseRand   = rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec // tokens only need to be unique, not secret
+)
+
+// Lease is a single-writer lock on a path, held by a lease file.
+// Leases are advisory: they only coordinate processes which acquire a lease before writing.
+type Lease struct {
+	fs    FS
+	name  string
+	token []byte
+	ttl   time.Duration
+
+	release  chan struct{}
+	stopped  chan struct{}
+	lost     chan struct{}
+	mu       sync.Mutex
+	err      error // err is the reason the lease was lost, if any
+	released bool
+}
+
+// AcquireLease creates the lease file 'name' and keeps it alive until Release, so only one holder at a time can acquire it.
+// Works on any FS which supports exclusive creates with OpenFile, using a lock-file protocol:
+//   - The lease file is created with FlagExclusive, and holds a unique token identifying its holder
+//   - The holder renews the lease by updating the file's modified time every third of 'ttl', with Chtimes if possible or by rewriting the file
+//   - A lease file which hasn't been renewed in 'ttl' is expired, so another caller may remove it and acquire the lease
+//
+// Fails with ErrExist if the lease is held and hasn't expired.
+// Expiry compares the file's modified time with the local clock, so 'ttl' should be much longer than any clock skew between holders and the FS's time precision.
+//
+// If the lease expires or is taken by another caller anyway, like after a long pause, the holder's renewals notice it and close Lost().
+func AcquireLease(fs FS, name string, ttl time.Duration) (*Lease, error) {
+	if ttl <= 0 {
+		return nil, &PathError{Op: "acquirelease", Path: name, Err: ErrInvalid}
+	}
+	lease := &Lease{
+		fs:      fs,
+		name:    name,
+		token:   newLeaseToken(),
+		ttl:     ttl,
+		release: make(chan struct{}),
+		stopped: make(chan struct{}),
+		lost:    make(chan struct{}),
+	}
+	err := lease.create()
+	if errors.Is(err, ErrExist) && lease.removeExpired() {
+		err = lease.create()
+	}
+	if err != nil {
+		return nil, err
+	}
+	go lease.renewLoop()
+	return lease, nil
+}
+
+// newLeaseToken returns a unique token for a new Lease
+func newLeaseToken() []byte {
+	leaseRandMu.Lock()
+	random := leaseRand.Int63()
+	leaseRandMu.Unlock()
+	return []byte(strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatInt(random, 36))
+}
+
+// create exclusively creates the lease file, holding the lease's token
+func (l *Lease) create() error {
+	file, err := OpenFile(l.fs, l.name, FlagWriteOnly|FlagCreate|FlagExclusive, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = WriteFile(file, l.token)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = Remove(l.fs, l.name)
+	}
+	return err
+}
+
+// removeExpired removes the lease file if it has expired, returning true if it was removed.
+// The holder's token is read again before removing, so a lease taken over since it was found expired is left alone.
+func (l *Lease) removeExpired() bool {
+	token, err := ReadFile(l.fs, l.name)
+	if err != nil {
+		return false
+	}
+	info, err := Stat(l.fs, l.name)
+	if err != nil || time.Since(info.ModTime()) < l.ttl {
+		return false
+	}
+	current, err := ReadFile(l.fs, l.name)
+	if err != nil || !bytes.Equal(token, current) {
+		return false
+	}
+	return Remove(l.fs, l.name) == nil
+}
+
+// Lost returns a channel which is closed if the lease is lost before Release, because it couldn't be renewed or another caller took it over
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err returns the reason the lease was lost, or nil if it's still held
+func (l *Lease) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Release stops renewing the lease and removes its lease file, unless another caller has taken it over.
+// Returns the reason the lease was lost, if it was.
+func (l *Lease) Release() error {
+	l.mu.Lock()
+	if l.released {
+		l.mu.Unlock()
+		return &PathError{Op: "releaselease", Path: l.name, Err: ErrClosed}
+	}
+	l.released = true
+	l.mu.Unlock()
+	close(l.release)
+	<-l.stopped
+
+	if err := l.Err(); err != nil {
+		return err
+	}
+	if err := l.checkToken(); err != nil {
+		return err
+	}
+	return Remove(l.fs, l.name)
+}
+
+// renewLoop renews the lease until it's released. Failed renewals are retried until the lease expires.
+func (l *Lease) renewLoop() {
+	defer close(l.stopped)
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-l.release:
+			return
+		case <-ticker.C:
+			err := l.renew()
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+			if errors.Is(err, ErrLeaseLost) || time.Since(renewed) >= l.ttl {
+				l.mu.Lock()
+				l.err = err
+				l.mu.Unlock()
+				close(l.lost)
+				return
+			}
+		}
+	}
+}
+
+// renew updates the lease file's modified time, after checking the lease is still held
+func (l *Lease) renew() error {
+	if err := l.checkToken(); err != nil {
+		return err
+	}
+	now := time.Now()
+	err := Chtimes(l.fs, l.name, now, now)
+	if errors.Is(err, ErrNotImplemented) {
+		err = l.rewrite()
+	}
+	return err
+}
+
+// rewrite writes the lease's token to the lease file again, updating its modified time on FSs without Chtimes
+func (l *Lease) rewrite() error {
+	file, err := OpenFile(l.fs, l.name, FlagWriteOnly|FlagTruncate, 0)
+	if err != nil {
+		return err
+	}
+	_, err = WriteFile(file, l.token)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// checkToken returns an error satisfying errors.Is(err, ErrLeaseLost) if the lease file was removed or no longer holds this lease's token
+func (l *Lease) checkToken() error {
+	file, err := l.fs.Open(l.name)
+	if errors.Is(err, ErrNotExist) {
+		return &PathError{Op: "lease", Path: l.name, Err: ErrLeaseLost}
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	token := make([]byte, len(l.token)+1)
+	n, err := io.ReadFull(file, token)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if !bytes.Equal(token[:n], l.token) {
+		return &PathError{Op: "lease", Path: l.name, Err: ErrLeaseLost}
+	}
+	return nil
+}
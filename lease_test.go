@@ -0,0 +1,75 @@
+package hackpadfs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestAcquireLease(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	requireNoError(t, err)
+	const ttl = 300 * time.Millisecond
+
+	lease, err := hackpadfs.AcquireLease(fs, "lock", ttl)
+	requireNoError(t, err)
+	_, err = hackpadfs.AcquireLease(fs, "lock", ttl)
+	assert.ErrorIs(t, hackpadfs.ErrExist, err)
+
+	time.Sleep(2 * ttl) // the lease is renewed, so it doesn't expire
+	_, err = hackpadfs.AcquireLease(fs, "lock", ttl)
+	assert.ErrorIs(t, hackpadfs.ErrExist, err)
+	assert.NoError(t, lease.Err())
+
+	assert.NoError(t, lease.Release())
+	assert.ErrorIs(t, hackpadfs.ErrClosed, lease.Release())
+	_, err = hackpadfs.Stat(fs, "lock")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	lease, err = hackpadfs.AcquireLease(fs, "lock", ttl)
+	assert.NoError(t, err)
+	assert.NoError(t, lease.Release())
+
+	_, err = hackpadfs.AcquireLease(fs, "lock", 0)
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
+
+func TestAcquireLeaseExpired(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "lock", []byte("abandoned"), 0644))
+	expired := time.Now().Add(-time.Hour)
+	requireNoError(t, hackpadfs.Chtimes(fs, "lock", expired, expired))
+
+	lease, err := hackpadfs.AcquireLease(fs, "lock", time.Minute)
+	requireNoError(t, err)
+	contents, err := hackpadfs.ReadFile(fs, "lock")
+	assert.NoError(t, err)
+	assert.Equal(t, false, string(contents) == "abandoned")
+	assert.NoError(t, lease.Release())
+}
+
+func TestLeaseLost(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	requireNoError(t, err)
+	lease, err := hackpadfs.AcquireLease(fs, "lock", 30*time.Millisecond)
+	requireNoError(t, err)
+
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "lock", []byte("new holder"), 0644))
+	select {
+	case <-lease.Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for lease to be lost")
+	}
+	assert.ErrorIs(t, hackpadfs.ErrLeaseLost, lease.Err())
+	assert.ErrorIs(t, hackpadfs.ErrLeaseLost, lease.Release())
+	contents, err := hackpadfs.ReadFile(fs, "lock")
+	assert.NoError(t, err)
+	assert.Equal(t, "new holder", string(contents))
+}
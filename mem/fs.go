@@ -2,64 +2,225 @@
 package mem
 
 import (
+	"context"
+	"strings"
 	"time"
 
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/keyvalue"
 )
 
+// Default path limits, matching common os limits
+const (
+	DefaultMaxPathLength = 4096
+	DefaultMaxNameLength = 255
+	DefaultMaxDepth      = 1024
+)
+
 // FS is an in-memory file system.
 type FS struct {
-	kv *keyvalue.FS
+	kv      *keyvalue.FS
+	options Options
+}
+
+// Options contain options for creating an FS.
+// Zero value limits are set to their defaults. Set a limit to a negative number to disable it.
+type Options struct {
+	// MaxPathLength is the maximum number of bytes in a path. Defaults to DefaultMaxPathLength.
+	MaxPathLength int
+	// MaxNameLength is the maximum number of bytes in each path element. Defaults to DefaultMaxNameLength.
+	MaxNameLength int
+	// MaxDepth is the maximum number of elements in a path. Defaults to DefaultMaxDepth.
+	MaxDepth int
+	// NormalizeName converts each file name before it's stored, like to Unicode NFC. See keyvalue.Options for details.
+	NormalizeName func(name string) string
+	// UpdateDirModTime sets a directory's modified time when its entries are created, removed, or renamed. See keyvalue.Options for details.
+	UpdateDirModTime bool
+	// UnsortedReadDir returns directory entries from file.ReadDir() in no particular order, instead of sorted by name. See keyvalue.Options for details.
+	UnsortedReadDir bool
 }
 
 // NewFS returns a new FS.
 func NewFS() (*FS, error) {
-	kv, err := keyvalue.NewFS(newStore())
-	return &FS{kv}, err
+	return NewFSWithOptions(Options{})
+}
+
+// NewFSWithOptions returns a new FS configured by 'options'.
+// Paths exceeding the configured limits fail with hackpadfs.ErrNameTooLong.
+func NewFSWithOptions(options Options) (*FS, error) {
+	if options.MaxPathLength == 0 {
+		options.MaxPathLength = DefaultMaxPathLength
+	}
+	if options.MaxNameLength == 0 {
+		options.MaxNameLength = DefaultMaxNameLength
+	}
+	if options.MaxDepth == 0 {
+		options.MaxDepth = DefaultMaxDepth
+	}
+	kv, err := keyvalue.NewFSWithOptions(newStore(), keyvalue.Options{
+		NormalizeName:    options.NormalizeName,
+		UpdateDirModTime: options.UpdateDirModTime,
+		UnsortedReadDir:  options.UnsortedReadDir,
+	})
+	return &FS{
+		kv:      kv,
+		options: options,
+	}, err
+}
+
+// checkPath returns hackpadfs.ErrNameTooLong if 'name' exceeds any of the path limits
+func (fs *FS) checkPath(name string) error {
+	if max := fs.options.MaxPathLength; max >= 0 && len(name) > max {
+		return hackpadfs.ErrNameTooLong
+	}
+	if max := fs.options.MaxDepth; max >= 0 && strings.Count(name, "/")+1 > max {
+		return hackpadfs.ErrNameTooLong
+	}
+	if max := fs.options.MaxNameLength; max >= 0 {
+		for remaining := name; remaining != ""; {
+			var elem string
+			elem, remaining, _ = strings.Cut(remaining, "/")
+			if len(elem) > max {
+				return hackpadfs.ErrNameTooLong
+			}
+		}
+	}
+	return nil
+}
+
+func (fs *FS) checkPathErr(op, name string) error {
+	if err := fs.checkPath(name); err != nil {
+		return &hackpadfs.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
 }
 
 // Open implements hackpadfs.FS
 func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	if err := fs.checkPathErr("open", name); err != nil {
+		return nil, err
+	}
 	return fs.kv.Open(name)
 }
 
 // OpenFile implements hackpadfs.OpenFileFS
 func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if err := fs.checkPathErr("open", name); err != nil {
+		return nil, err
+	}
 	return fs.kv.OpenFile(name, flag, perm)
 }
 
 // Mkdir implements hackpadfs.MkdirFS
 func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	if err := fs.checkPathErr("mkdir", name); err != nil {
+		return err
+	}
 	return fs.kv.Mkdir(name, perm)
 }
 
 // MkdirAll implements hackpadfs.MkdirAllFS
 func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	if err := fs.checkPathErr("mkdirall", path); err != nil {
+		return err
+	}
 	return fs.kv.MkdirAll(path, perm)
 }
 
 // Remove implements hackpadfs.RemoveFS
 func (fs *FS) Remove(name string) error {
+	if err := fs.checkPathErr("remove", name); err != nil {
+		return err
+	}
 	return fs.kv.Remove(name)
 }
 
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	if err := fs.checkPathErr("removeall", name); err != nil {
+		return err
+	}
+	return fs.kv.RemoveAll(name)
+}
+
 // Rename implements hackpadfs.RenameFS
 func (fs *FS) Rename(oldname, newname string) error {
+	for _, name := range []string{oldname, newname} {
+		if err := fs.checkPath(name); err != nil {
+			return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+		}
+	}
+	if err := fs.checkRenamedTree(oldname, newname); err != nil {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
 	return fs.kv.Rename(oldname, newname)
 }
 
+// checkRenamedTree returns hackpadfs.ErrNameTooLong if moving the tree at 'oldname' to 'newname' would push any file inside it past the depth or path length limits.
+// Element names don't change, so the tree is only walked if it moves deeper or to a longer path.
+func (fs *FS) checkRenamedTree(oldname, newname string) error {
+	if oldname == "." || (len(newname) <= len(oldname) && strings.Count(newname, "/") <= strings.Count(oldname, "/")) {
+		return nil
+	}
+	return hackpadfs.WalkDir(fs.kv, oldname, func(name string, _ hackpadfs.DirEntry, err error) error {
+		if err != nil {
+			return nil // let Rename report on missing files
+		}
+		return fs.checkPath(newname + strings.TrimPrefix(name, oldname))
+	})
+}
+
+// DiskUsage implements hackpadfs.UsageFS
+func (fs *FS) DiskUsage(root string) (files, dirs, bytes int64, err error) {
+	if err := fs.checkPathErr("diskusage", root); err != nil {
+		return 0, 0, 0, err
+	}
+	return fs.kv.DiskUsage(root)
+}
+
+// SameFile implements hackpadfs.SameFileFS
+func (fs *FS) SameFile(fi1, fi2 hackpadfs.FileInfo) bool {
+	return fs.kv.SameFile(fi1, fi2)
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(ctx context.Context, name string) (<-chan hackpadfs.Event, error) {
+	if err := fs.checkPathErr("watch", name); err != nil {
+		return nil, err
+	}
+	return fs.kv.Watch(ctx, name)
+}
+
 // Stat implements hackpadfs.StatFS
 func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if err := fs.checkPathErr("stat", name); err != nil {
+		return nil, err
+	}
 	return fs.kv.Stat(name)
 }
 
 // Chmod implements hackpadfs.ChmodFS
 func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	if err := fs.checkPathErr("chmod", name); err != nil {
+		return err
+	}
 	return fs.kv.Chmod(name, mode)
 }
 
 // Chtimes implements hackpadfs.ChtimesFS
 func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := fs.checkPathErr("chtimes", name); err != nil {
+		return err
+	}
 	return fs.kv.Chtimes(name, atime, mtime)
 }
+
+// ApplyMetadata implements hackpadfs.ApplyMetadataFS
+func (fs *FS) ApplyMetadata(metadata map[string]hackpadfs.Metadata) error {
+	for name := range metadata {
+		if err := fs.checkPathErr("applymetadata", name); err != nil {
+			return err
+		}
+	}
+	return fs.kv.ApplyMetadata(metadata)
+}
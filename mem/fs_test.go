@@ -1,10 +1,17 @@
 package mem
 
 import (
+	"context"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/hack-pad/hackpadfs/keyvalue/storetest"
 )
 
 func TestFS(t *testing.T) {
@@ -18,7 +25,168 @@ func TestFS(t *testing.T) {
 			}
 			return fs
 		},
+		Constraints: fstest.Constraints{SortedReadDir: true},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestPathLimits(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		options     Options
+		path        string
+		expectErr   bool
+	}{
+		{
+			description: "default limits",
+			path:        strings.Repeat("a/", 100) + "b",
+		},
+		{
+			description: "name too long",
+			path:        strings.Repeat("a", DefaultMaxNameLength+1),
+			expectErr:   true,
+		},
+		{
+			description: "path too long",
+			options:     Options{MaxPathLength: 10},
+			path:        "aaaaa/bbbbb",
+			expectErr:   true,
+		},
+		{
+			description: "too deep",
+			options:     Options{MaxDepth: 3},
+			path:        "a/b/c/d",
+			expectErr:   true,
+		},
+		{
+			description: "at max depth",
+			options:     Options{MaxDepth: 3},
+			path:        "a/b/c",
+		},
+		{
+			description: "limits disabled",
+			options:     Options{MaxPathLength: -1, MaxNameLength: -1, MaxDepth: -1},
+			path:        strings.Repeat("a", 5000),
+		},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			fs, err := NewFSWithOptions(tc.options)
+			assert.NoError(t, err)
+			err = fs.MkdirAll(tc.path, 0700)
+			if tc.expectErr {
+				assert.ErrorIs(t, hackpadfs.ErrNameTooLong, err)
+				_, err = fs.Stat(tc.path)
+				assert.ErrorIs(t, hackpadfs.ErrNameTooLong, err)
+				err = fs.Rename(".", tc.path)
+				assert.ErrorIs(t, hackpadfs.ErrNameTooLong, err)
+			} else {
+				assert.NoError(t, err)
+				info, err := fs.Stat(tc.path)
+				assert.NoError(t, err)
+				assert.Equal(t, true, info.IsDir())
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+	storetest.Store(t, func(tb testing.TB) keyvalue.Store {
+		return NewStore()
+	})
+}
+
+func TestStoreSetVersion(t *testing.T) {
+	t.Parallel()
+	store := newStore()
+	ctx := context.Background()
+	record := newBytesRecord(t, "foo")
+
+	version, err := store.SetVersion(ctx, "foo", record, "")
+	assert.NoError(t, err)
+	assert.NotEqual(t, "", version)
+	_, err = store.SetVersion(ctx, "foo", record, "")
+	assert.ErrorIs(t, keyvalue.ErrVersionConflict, err)
+
+	assert.NoError(t, store.Set(ctx, "foo", record))
+	_, err = store.SetVersion(ctx, "foo", record, version)
+	assert.ErrorIs(t, keyvalue.ErrVersionConflict, err)
+	var conflictErr *keyvalue.VersionConflictError
+	if assert.ErrorAs(t, &conflictErr, err) {
+		assert.Equal(t, version, conflictErr.Version)
+		assert.NotEqual(t, version, conflictErr.Current)
+	}
+
+	assert.NoError(t, store.SetMetadata(ctx, "foo", record))
+	stored, err := store.Get(ctx, "foo")
+	assert.NoError(t, err)
+	current := stored.(keyvalue.VersionedFileRecord).Version()
+	assert.NotEqual(t, conflictErr.Current, current)
+	newVersion, err := store.SetVersion(ctx, "foo", record, current)
+	assert.NoError(t, err)
+	assert.NotEqual(t, current, newVersion)
+}
+
+func TestStoreWatchRollback(t *testing.T) {
+	t.Parallel()
+	store := newStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, store.Set(ctx, "foo", newBytesRecord(t, "foo")))
+	events, err := store.Watch(ctx, ".")
+	assert.NoError(t, err)
+
+	txn, err := store.Transaction(keyvalue.TransactionOptions{Mode: keyvalue.TransactionReadWrite})
+	assert.NoError(t, err)
+	savepointTxn := txn.(keyvalue.SavepointTransaction)
+	savepoint, err := savepointTxn.Savepoint()
+	assert.NoError(t, err)
+	txn.Delete("foo")
+	txn.Set("bar", newBytesRecord(t, "bar"), nil)
+	assert.NoError(t, savepointTxn.RollbackTo(savepoint))
+	_, err = txn.Commit(ctx)
+	assert.NoError(t, err)
+
+	var received []keyvalue.Event
+	for len(received) < 4 {
+		received = append(received, <-events)
+	}
+	assert.Equal(t, []keyvalue.Event{
+		{Path: "foo", Deleted: true},
+		{Path: "bar"},
+		{Path: "bar", Deleted: true},
+		{Path: "foo"},
+	}, received)
+}
+
+func newBytesRecord(tb testing.TB, contents string) keyvalue.FileRecord {
+	tb.Helper()
+	return keyvalue.NewBaseFileRecord(int64(len(contents)), time.Now(), 0600, nil, func() (blob.Blob, error) {
+		return blob.NewBytes([]byte(contents)), nil
+	}, nil)
+}
+
+func TestRenamePathLimits(t *testing.T) {
+	t.Parallel()
+	fs, err := NewFSWithOptions(Options{MaxDepth: 3, MaxPathLength: 12})
+	assert.NoError(t, err)
+	assert.NoError(t, fs.MkdirAll("a/b/c", 0700))
+	assert.NoError(t, fs.Mkdir("x", 0700))
+
+	err = fs.Rename("a", "x/a") // "x/a/b/c" is too deep
+	assert.ErrorIs(t, hackpadfs.ErrNameTooLong, err)
+	_, err = fs.Stat("a/b/c")
+	assert.NoError(t, err)
+
+	err = fs.Rename("a", "aaaaaaaaaa") // "aaaaaaaaaa/b/c" is too long
+	assert.ErrorIs(t, hackpadfs.ErrNameTooLong, err)
+
+	assert.NoError(t, fs.Rename("a/b", "x/b"))
+	_, err = fs.Stat("x/b/c")
+	assert.NoError(t, err)
 }
@@ -0,0 +1,106 @@
+package mem
+
+import (
+	"path"
+	"sort"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+const (
+	mapFilePerm = 0644 // mapFilePerm is the permission of a MapFile with none set
+	mapDirPerm  = 0755 // mapDirPerm is the permission of a MapFile directory with none set, and of implied parent directories
+)
+
+// MapFile describes a file for FromMap(), like testing/fstest.MapFile
+type MapFile struct {
+	Data    []byte             // Data is the file's contents
+	Mode    hackpadfs.FileMode // Mode is the file's type and permission. A zero permission defaults to 0644, or 0755 for directories.
+	ModTime time.Time
+}
+
+// FromMap returns a new FS holding the files in 'files', keyed by path like a testing/fstest.MapFS. Useful for declaring fixtures in table-driven tests.
+//
+// Parent directories are created as needed with permission 0755. A zero ModTime is left as the time the file was created.
+// Fails with hackpadfs.ErrInvalid if a path is invalid, has a nil MapFile, or isn't a regular file or directory, or "." isn't a directory.
+// An FS can't hold symlinks, so symlink modes fail with hackpadfs.ErrNotImplemented before any file is created.
+func FromMap(files map[string]*MapFile) (*FS, error) {
+	const op = "frommap"
+	names := make([]string, 0, len(files))
+	for name, file := range files {
+		if err := checkMapFile(name, file); err != nil {
+			return nil, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fs, err := NewFS()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := addMapFile(fs, name, files[name]); err != nil {
+			return nil, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+		}
+	}
+	// set directory permissions and modified times last, after their contents are created
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		file := files[name]
+		if file.Mode.IsDir() {
+			if err := fs.Chmod(name, mapPerm(file.Mode)); err != nil {
+				return nil, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+			}
+		}
+		if !file.ModTime.IsZero() {
+			if err := fs.Chtimes(name, file.ModTime, file.ModTime); err != nil {
+				return nil, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+			}
+		}
+	}
+	return fs, nil
+}
+
+// checkMapFile returns an error if 'file' can't be created at 'name'
+func checkMapFile(name string, file *MapFile) error {
+	switch {
+	case !hackpadfs.ValidPath(name) || file == nil:
+		return hackpadfs.ErrInvalid
+	case file.Mode.Type() == hackpadfs.ModeSymlink:
+		return hackpadfs.ErrNotImplemented
+	case file.Mode.Type() != 0 && !file.Mode.IsDir():
+		return hackpadfs.ErrInvalid
+	case name == "." && !file.Mode.IsDir():
+		return hackpadfs.ErrInvalid
+	default:
+		return nil
+	}
+}
+
+// mapPerm returns the permission of 'mode', or the default for its type if none is set
+func mapPerm(mode hackpadfs.FileMode) hackpadfs.FileMode {
+	switch {
+	case mode.Perm() != 0:
+		return mode.Perm()
+	case mode.IsDir():
+		return mapDirPerm
+	default:
+		return mapFilePerm
+	}
+}
+
+// addMapFile creates 'file' at 'name' in 'fs', creating its parent directories if needed
+func addMapFile(fs *FS, name string, file *MapFile) error {
+	if name == "." {
+		return nil
+	}
+	if err := fs.MkdirAll(path.Dir(name), mapDirPerm); err != nil {
+		return err
+	}
+	if file.Mode.IsDir() {
+		return fs.MkdirAll(name, mapDirPerm) // permission is set after creating the directory's contents
+	}
+	return hackpadfs.WriteFullFile(fs, name, file.Data, mapPerm(file.Mode))
+}
@@ -0,0 +1,72 @@
+package mem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestFromMap(t *testing.T) {
+	t.Parallel()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	fs, err := FromMap(map[string]*MapFile{
+		"file":          {Data: []byte("file"), ModTime: modTime},
+		"dir":           {Mode: hackpadfs.ModeDir | 0700, ModTime: modTime},
+		"dir/exec":      {Data: []byte("#!/bin/sh"), Mode: 0755},
+		"implied/a/b":   {Data: []byte("b")},
+		"implied/empty": {Mode: hackpadfs.ModeDir},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	for _, tc := range []struct {
+		name    string
+		mode    hackpadfs.FileMode
+		modTime time.Time
+	}{
+		{name: "file", mode: 0644, modTime: modTime},
+		{name: "dir", mode: hackpadfs.ModeDir | 0700, modTime: modTime},
+		{name: "dir/exec", mode: 0755},
+		{name: "implied", mode: hackpadfs.ModeDir | 0755},
+		{name: "implied/a", mode: hackpadfs.ModeDir | 0755},
+		{name: "implied/a/b", mode: 0644},
+		{name: "implied/empty", mode: hackpadfs.ModeDir | 0755},
+	} {
+		info, err := fs.Stat(tc.name)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tc.mode, info.Mode())
+			if !tc.modTime.IsZero() {
+				assert.Equal(t, tc.modTime, info.ModTime())
+			}
+		}
+	}
+	contents, err := hackpadfs.ReadFile(fs, "implied/a/b")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(contents))
+}
+
+func TestFromMapErrors(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		files       map[string]*MapFile
+		expectErr   error
+	}{
+		{description: "invalid path", files: map[string]*MapFile{"/file": {}}, expectErr: hackpadfs.ErrInvalid},
+		{description: "nil file", files: map[string]*MapFile{"file": nil}, expectErr: hackpadfs.ErrInvalid},
+		{description: "root file", files: map[string]*MapFile{".": {}}, expectErr: hackpadfs.ErrInvalid},
+		{description: "device", files: map[string]*MapFile{"dev": {Mode: hackpadfs.ModeDevice}}, expectErr: hackpadfs.ErrInvalid},
+		{description: "symlink", files: map[string]*MapFile{"link": {Data: []byte("file"), Mode: hackpadfs.ModeSymlink}}, expectErr: hackpadfs.ErrNotImplemented},
+		{description: "not a dir", files: map[string]*MapFile{"file": {}, "file/child": {}}, expectErr: hackpadfs.ErrNotDir},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			_, err := FromMap(tc.files)
+			assert.ErrorIs(t, tc.expectErr, err)
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package mem
+
+import "github.com/hack-pad/hackpadfs"
+
+// init registers the "mem" scheme for hackpadfs.New(). Each "mem:" URL creates a new, empty FS.
+func init() {
+	hackpadfs.Register("mem", func(string) (hackpadfs.FS, error) {
+		fs, err := NewFS()
+		if err != nil {
+			return nil, err
+		}
+		return fs, nil
+	})
+}
@@ -2,6 +2,8 @@ package mem
 
 import (
 	"context"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -11,23 +13,37 @@ import (
 	"github.com/hack-pad/hackpadfs/keyvalue/blob"
 )
 
-var _ keyvalue.TransactionStore = &store{}
+var (
+	_ keyvalue.TransactionStore     = &store{}
+	_ keyvalue.MetadataStore        = &store{}
+	_ keyvalue.VersionedStore       = &store{}
+	_ keyvalue.WatchableStore       = &store{}
+	_ keyvalue.SavepointTransaction = &transaction{}
+)
 
 type store struct {
-	mu      sync.Mutex
-	records sync.Map
+	mu       sync.Mutex
+	records  sync.Map
+	watchers keyvalue.Watchers
 }
 
 func newStore() *store {
 	return &store{}
 }
 
+// NewStore returns a new in-memory store. Useful for building custom file systems on top of keyvalue.FS.
+func NewStore() keyvalue.TransactionStore {
+	return newStore()
+}
+
 type fileRecord struct {
 	store   *store
 	path    string
 	data    blob.Blob
 	mode    hackpadfs.FileMode
 	modTime time.Time
+	version uint64
+	id      string
 }
 
 func (f fileRecord) Data() (blob.Blob, error) {
@@ -38,6 +54,8 @@ func (f fileRecord) Size() int64              { return int64(f.data.Len()) }
 func (f fileRecord) Mode() hackpadfs.FileMode { return f.mode }
 func (f fileRecord) ModTime() time.Time       { return f.modTime }
 func (f fileRecord) Sys() interface{}         { return nil }
+func (f fileRecord) Version() string          { return formatVersion(f.version) }
+func (f fileRecord) ID() string               { return f.id }
 
 func (f fileRecord) ReadDirNames() ([]string, error) {
 	if !f.mode.IsDir() {
@@ -81,12 +99,46 @@ func (s *store) Set(ctx context.Context, path string, src keyvalue.FileRecord) e
 			return err
 		}
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.set(path, src, contents)
 }
 
+func (s *store) SetVersion(ctx context.Context, path string, src keyvalue.FileRecord, ifVersion string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current := formatVersion(s.version(path)); current != ifVersion {
+		return "", &keyvalue.VersionConflictError{Path: path, Version: ifVersion, Current: current}
+	}
+	if err := s.set(path, src, nil); err != nil {
+		return "", err
+	}
+	return formatVersion(s.version(path)), nil
+}
+
+// formatVersion returns the version token for the 'version'th change to a record, or "" if it doesn't exist
+func formatVersion(version uint64) string {
+	if version == 0 {
+		return ""
+	}
+	return strconv.FormatUint(version, 10)
+}
+
+// version returns the current version of the record at 'path', or 0 if it doesn't exist. Requires holding s.mu.
+func (s *store) version(path string) uint64 {
+	value, ok := s.records.Load(path)
+	if !ok {
+		return 0
+	}
+	return value.(fileRecord).version
+}
+
+// set stores 'src' at 'path' and increments its version. Requires holding s.mu.
 func (s *store) set(path string, src keyvalue.FileRecord, contents blob.Blob) error {
 	if src == nil {
-		s.records.Delete(path)
+		if _, loaded := s.records.LoadAndDelete(path); loaded {
+			s.watchers.Send(keyvalue.Event{Path: path, Deleted: true})
+		}
 	} else {
 		data, err := src.Data()
 		if err != nil {
@@ -98,18 +150,52 @@ func (s *store) set(path string, src keyvalue.FileRecord, contents blob.Blob) er
 			data:    data,
 			mode:    src.Mode(),
 			modTime: src.ModTime(),
+			version: s.version(path) + 1,
+			id:      keyvalue.RecordID(src),
 		}
 		s.records.Store(path, record)
+		s.watchers.Send(keyvalue.Event{Path: path})
 	}
 	return nil
 }
 
+func (s *store) SetMetadata(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.records.Load(path)
+	if !ok {
+		return hackpadfs.ErrNotExist
+	}
+	record := value.(fileRecord)
+	record.mode = src.Mode()
+	record.modTime = src.ModTime()
+	if id := keyvalue.RecordID(src); id != "" {
+		record.id = id
+	}
+	record.version++
+	s.records.Store(path, record)
+	s.watchers.Send(keyvalue.Event{Path: path})
+	return nil
+}
+
+// Watch implements keyvalue.WatchableStore
+func (s *store) Watch(ctx context.Context, prefix string) (<-chan keyvalue.Event, error) {
+	return s.watchers.Watch(ctx, prefix)
+}
+
 type transaction struct {
 	ctx     context.Context
 	abort   context.CancelFunc
 	store   *store
 	op      keyvalue.OpID
 	results []keyvalue.OpResult
+	undo    []undoRecord
+}
+
+// undoRecord is the record at 'path' before a Set or Delete. A nil 'record' means the path did not exist.
+type undoRecord struct {
+	path   string
+	record *fileRecord
 }
 
 func (s *store) Transaction(options keyvalue.TransactionOptions) (keyvalue.Transaction, error) {
@@ -169,6 +255,7 @@ func (t *transaction) SetHandler(path string, src keyvalue.FileRecord, contents
 		t.results = append(t.results, keyvalue.OpResult{Op: op, Err: err})
 		return op
 	}
+	t.saveUndo(path)
 	err = t.store.set(path, src, contents)
 	result := keyvalue.OpResult{Op: op, Err: err}
 	err = handler.Handle(t, result)
@@ -179,6 +266,93 @@ func (t *transaction) SetHandler(path string, src keyvalue.FileRecord, contents
 	return op
 }
 
+func (t *transaction) Delete(path string) keyvalue.OpID {
+	return t.DeleteHandler(path, keyvalue.OpHandlerFunc(func(txn keyvalue.Transaction, result keyvalue.OpResult) error {
+		return nil
+	}))
+}
+
+func (t *transaction) DeleteHandler(path string, handler keyvalue.OpHandler) keyvalue.OpID {
+	return t.SetHandler(path, nil, nil, handler)
+}
+
+func (t *transaction) ListPrefix(prefix string) keyvalue.OpID {
+	return t.ListPrefixHandler(prefix, keyvalue.OpHandlerFunc(func(txn keyvalue.Transaction, result keyvalue.OpResult) error {
+		return nil
+	}))
+}
+
+func (t *transaction) ListPrefixHandler(prefix string, handler keyvalue.OpHandler) keyvalue.OpID {
+	op, err := t.prepOp()
+	if err != nil {
+		t.results = append(t.results, keyvalue.OpResult{Op: op, Err: err})
+		return op
+	}
+	result := keyvalue.OpResult{Op: op, Paths: t.store.listPrefix(prefix)}
+	err = handler.Handle(t, result)
+	if result.Err == nil && err != nil {
+		result.Err = err
+	}
+	t.results = append(t.results, result)
+	return op
+}
+
+// listPrefix returns the sorted paths beginning with 'prefix'
+func (s *store) listPrefix(prefix string) []string {
+	var paths []string
+	s.records.Range(func(key, _ interface{}) bool {
+		if p := key.(string); strings.HasPrefix(p, prefix) {
+			paths = append(paths, p)
+		}
+		return true
+	})
+	sort.Strings(paths)
+	return paths
+}
+
+func (t *transaction) saveUndo(path string) {
+	undo := undoRecord{path: path}
+	if value, ok := t.store.records.Load(path); ok {
+		record := value.(fileRecord)
+		undo.record = &record
+	}
+	t.undo = append(t.undo, undo)
+}
+
+func (t *transaction) Savepoint() (keyvalue.Savepoint, error) {
+	if err := t.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return keyvalue.Savepoint(len(t.undo)), nil
+}
+
+func (t *transaction) RollbackTo(savepoint keyvalue.Savepoint) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	if savepoint < 0 || int(savepoint) > len(t.undo) {
+		return keyvalue.ErrInvalidSavepoint
+	}
+	for i := len(t.undo) - 1; i >= int(savepoint); i-- {
+		undo := t.undo[i]
+		if undo.record == nil {
+			if _, loaded := t.store.records.LoadAndDelete(undo.path); loaded {
+				t.store.watchers.Send(keyvalue.Event{Path: undo.path, Deleted: true})
+			}
+			continue
+		}
+		record := *undo.record
+		if current := t.store.version(undo.path); current > record.version {
+			record.version = current
+		}
+		record.version++ // other readers may have seen the rolled back version, so the restored record needs a new one
+		t.store.records.Store(undo.path, record)
+		t.store.watchers.Send(keyvalue.Event{Path: undo.path})
+	}
+	t.undo = t.undo[:savepoint]
+	return nil
+}
+
 func (t *transaction) Commit(ctx context.Context) ([]keyvalue.OpResult, error) {
 	t.abort()
 	t.store.mu.Unlock()
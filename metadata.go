@@ -0,0 +1,64 @@
+package hackpadfs
+
+import (
+	"sort"
+	"time"
+)
+
+// Metadata is a file's metadata to set with ApplyMetadata()
+type Metadata struct {
+	// Mode holds the permission bits to set with Chmod, if SetMode is true
+	Mode    FileMode
+	SetMode bool
+	// UID and GID are set with Chown, if SetOwner is true
+	UID, GID int
+	SetOwner bool
+	// Atime and Mtime are set with Chtimes, unless Mtime is zero. A zero Atime is set to Mtime.
+	Atime time.Time
+	Mtime time.Time
+}
+
+// ApplyMetadata sets the metadata of many files at once, like when restoring an archive or syncing a file tree.
+// Attempts to call an optimized fs.ApplyMetadata(), falls back to running Chown(), Chmod(), and Chtimes() for each file.
+//
+// The fallback changes every file's owner and mode before any times, then sets times for the files inside each directory before the directory itself.
+// Stops at the first failure, which may leave earlier files changed.
+func ApplyMetadata(fs FS, metadata map[string]Metadata) error {
+	if fs, ok := fs.(ApplyMetadataFS); ok {
+		return fs.ApplyMetadata(metadata)
+	}
+	names := make([]string, 0, len(metadata))
+	for name := range metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		m := metadata[name]
+		if m.SetOwner {
+			if err := Chown(fs, name, m.UID, m.GID); err != nil {
+				return err
+			}
+		}
+		if m.SetMode {
+			if err := Chmod(fs, name, m.Mode); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(names) - 1; i >= 0; i-- { // a directory sorts before the files inside it
+		name := names[i]
+		m := metadata[name]
+		if m.Mtime.IsZero() {
+			continue
+		}
+		atime := m.Atime
+		if atime.IsZero() {
+			atime = m.Mtime
+		}
+		if err := Chtimes(fs, name, atime, m.Mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package hackpadfs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+// metadataCallsFS records the Chmod and Chtimes calls from ApplyMetadata's fallback
+type metadataCallsFS struct {
+	hackpadfs.FS
+	calls []string
+}
+
+func (fs *metadataCallsFS) Chmod(name string, mode hackpadfs.FileMode) error {
+	fs.calls = append(fs.calls, "chmod "+name)
+	return hackpadfs.Chmod(fs.FS, name, mode)
+}
+
+func (fs *metadataCallsFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	fs.calls = append(fs.calls, "chtimes "+name)
+	return hackpadfs.Chtimes(fs.FS, name, atime, mtime)
+}
+
+func TestApplyMetadata(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.MkdirAll(memFS, "dir/sub", 0700))
+	requireNoError(t, hackpadfs.WriteFullFile(memFS, "dir/sub/file", nil, 0600))
+	fs := &metadataCallsFS{FS: memFS}
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	err = hackpadfs.ApplyMetadata(fs, map[string]hackpadfs.Metadata{
+		"dir":          {Mode: 0755, SetMode: true, Mtime: modTime},
+		"dir/sub":      {Mtime: modTime},
+		"dir/sub/file": {Mode: 0644, SetMode: true, Mtime: modTime},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"chmod dir",
+		"chmod dir/sub/file",
+		"chtimes dir/sub/file",
+		"chtimes dir/sub",
+		"chtimes dir",
+	}, fs.calls)
+
+	info, err := hackpadfs.Stat(fs, "dir")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.ModeDir|0755, info.Mode())
+	assert.Equal(t, modTime, info.ModTime())
+	info, err = hackpadfs.Stat(fs, "dir/sub/file")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0644), info.Mode())
+	assert.Equal(t, modTime, info.ModTime())
+
+	err = hackpadfs.ApplyMetadata(fs, map[string]hackpadfs.Metadata{
+		"missing": {Mode: 0600, SetMode: true},
+	})
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestApplyMetadataFS(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "file", nil, 0600))
+
+	err = hackpadfs.ApplyMetadata(fs, map[string]hackpadfs.Metadata{
+		"file": {Mode: 0644, SetMode: true},
+	})
+	assert.NoError(t, err)
+	info, err := hackpadfs.Stat(fs, "file")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0644), info.Mode())
+}
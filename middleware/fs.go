@@ -0,0 +1,269 @@
+// Package middleware contains a file system wrapper built from a chain of Middleware, so cross-cutting behavior like logging, metrics, retries, or quotas can be layered without writing a full FS wrapper for each.
+package middleware
+
+import (
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.WriteFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.ReadlinkFS
+	} = &FS{}
+)
+
+// Ops contains one function for each FS operation. Each function should match the behavior of the Go io/fs or hackpadfs interface method with the same name.
+// Opening a file for reading goes through OpenFile with hackpadfs.FlagReadOnly, so one function sees every opened file.
+type Ops struct {
+	OpenFile  func(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error)
+	Mkdir     func(name string, perm hackpadfs.FileMode) error
+	MkdirAll  func(path string, perm hackpadfs.FileMode) error
+	Remove    func(name string) error
+	RemoveAll func(name string) error
+	Rename    func(oldname, newname string) error
+	Stat      func(name string) (hackpadfs.FileInfo, error)
+	Lstat     func(name string) (hackpadfs.FileInfo, error)
+	Chmod     func(name string, mode hackpadfs.FileMode) error
+	Chown     func(name string, uid, gid int) error
+	Chtimes   func(name string, atime time.Time, mtime time.Time) error
+	ReadDir   func(name string) ([]hackpadfs.DirEntry, error)
+	ReadFile  func(name string) ([]byte, error)
+	WriteFile func(name string, data []byte, perm hackpadfs.FileMode) error
+	Symlink   func(oldname, newname string) error
+	Readlink  func(name string) (string, error)
+}
+
+// Middleware returns new Ops which wrap 'next'. Typically a Middleware copies 'next' and replaces the operations it intercepts, calling the originals to continue the chain.
+// Any operations left nil in the returned Ops fall back to those in 'next'.
+type Middleware func(next Ops) Ops
+
+// OpsOf returns Ops which run each operation on 'fs' with the matching hackpadfs helper, like hackpadfs.OpenFile() or hackpadfs.Stat().
+func OpsOf(fs hackpadfs.FS) Ops {
+	return Ops{
+		OpenFile: func(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+			return hackpadfs.OpenFile(fs, name, flag, perm)
+		},
+		Mkdir: func(name string, perm hackpadfs.FileMode) error {
+			return hackpadfs.Mkdir(fs, name, perm)
+		},
+		MkdirAll: func(path string, perm hackpadfs.FileMode) error {
+			return hackpadfs.MkdirAll(fs, path, perm)
+		},
+		Remove: func(name string) error {
+			return hackpadfs.Remove(fs, name)
+		},
+		RemoveAll: func(name string) error {
+			return hackpadfs.RemoveAll(fs, name)
+		},
+		Rename: func(oldname, newname string) error {
+			return hackpadfs.Rename(fs, oldname, newname)
+		},
+		Stat: func(name string) (hackpadfs.FileInfo, error) {
+			return hackpadfs.Stat(fs, name)
+		},
+		Lstat: func(name string) (hackpadfs.FileInfo, error) {
+			return hackpadfs.Lstat(fs, name)
+		},
+		Chmod: func(name string, mode hackpadfs.FileMode) error {
+			return hackpadfs.Chmod(fs, name, mode)
+		},
+		Chown: func(name string, uid, gid int) error {
+			return hackpadfs.Chown(fs, name, uid, gid)
+		},
+		Chtimes: func(name string, atime time.Time, mtime time.Time) error {
+			return hackpadfs.Chtimes(fs, name, atime, mtime)
+		},
+		ReadDir: func(name string) ([]hackpadfs.DirEntry, error) {
+			return hackpadfs.ReadDir(fs, name)
+		},
+		ReadFile: func(name string) ([]byte, error) {
+			return hackpadfs.ReadFile(fs, name)
+		},
+		WriteFile: func(name string, data []byte, perm hackpadfs.FileMode) error {
+			return hackpadfs.WriteFullFile(fs, name, data, perm)
+		},
+		Symlink: func(oldname, newname string) error {
+			return hackpadfs.Symlink(fs, oldname, newname)
+		},
+		Readlink: func(name string) (string, error) {
+			return hackpadfs.Readlink(fs, name)
+		},
+	}
+}
+
+// Chain returns a Middleware which runs each of 'middlewares' in order. The first middleware is the outermost, so it sees each call first.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next Ops) Ops {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next).withDefaults(next)
+		}
+		return next
+	}
+}
+
+// withDefaults returns a copy of 'ops' with any nil operations replaced by those in 'defaults'
+func (ops Ops) withDefaults(defaults Ops) Ops {
+	if ops.OpenFile == nil {
+		ops.OpenFile = defaults.OpenFile
+	}
+	if ops.Mkdir == nil {
+		ops.Mkdir = defaults.Mkdir
+	}
+	if ops.MkdirAll == nil {
+		ops.MkdirAll = defaults.MkdirAll
+	}
+	if ops.Remove == nil {
+		ops.Remove = defaults.Remove
+	}
+	if ops.RemoveAll == nil {
+		ops.RemoveAll = defaults.RemoveAll
+	}
+	if ops.Rename == nil {
+		ops.Rename = defaults.Rename
+	}
+	if ops.Stat == nil {
+		ops.Stat = defaults.Stat
+	}
+	if ops.Lstat == nil {
+		ops.Lstat = defaults.Lstat
+	}
+	if ops.Chmod == nil {
+		ops.Chmod = defaults.Chmod
+	}
+	if ops.Chown == nil {
+		ops.Chown = defaults.Chown
+	}
+	if ops.Chtimes == nil {
+		ops.Chtimes = defaults.Chtimes
+	}
+	if ops.ReadDir == nil {
+		ops.ReadDir = defaults.ReadDir
+	}
+	if ops.ReadFile == nil {
+		ops.ReadFile = defaults.ReadFile
+	}
+	if ops.WriteFile == nil {
+		ops.WriteFile = defaults.WriteFile
+	}
+	if ops.Symlink == nil {
+		ops.Symlink = defaults.Symlink
+	}
+	if ops.Readlink == nil {
+		ops.Readlink = defaults.Readlink
+	}
+	return ops
+}
+
+// FS runs each operation through a chain of Middleware before reaching a source FS
+type FS struct {
+	ops Ops
+}
+
+// NewFS returns a new FS wrapping 'source' with 'middlewares'. The first middleware is the outermost, so it sees each call first.
+func NewFS(source hackpadfs.FS, middlewares ...Middleware) (*FS, error) {
+	return &FS{
+		ops: Chain(middlewares...)(OpsOf(source)),
+	}, nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.ops.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	return fs.ops.OpenFile(name, flag, perm)
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return fs.ops.Mkdir(name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return fs.ops.MkdirAll(path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	return fs.ops.Remove(name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	return fs.ops.RemoveAll(name)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	return fs.ops.Rename(oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return fs.ops.Stat(name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return fs.ops.Lstat(name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return fs.ops.Chmod(name, mode)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return fs.ops.Chown(name, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.ops.Chtimes(name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return fs.ops.ReadDir(name)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	return fs.ops.ReadFile(name)
+}
+
+// WriteFile implements hackpadfs.WriteFileFS
+func (fs *FS) WriteFile(name string, data []byte, perm hackpadfs.FileMode) error {
+	return fs.ops.WriteFile(name, data, perm)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	return fs.ops.Symlink(oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	return fs.ops.Readlink(name)
+}
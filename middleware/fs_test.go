@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newFS(tb testing.TB, middlewares ...Middleware) *FS {
+	tb.Helper()
+	source, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	fs, err := NewFS(source, middlewares...)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "middleware",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			return newFS(tb, Observe(func(op, name string, err error) {}))
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+// recordMkdir returns a Middleware which only intercepts Mkdir, appending 'id' to 'calls'
+func recordMkdir(calls *[]string, id string) Middleware {
+	return func(next Ops) Ops {
+		return Ops{
+			Mkdir: func(name string, perm hackpadfs.FileMode) error {
+				*calls = append(*calls, id)
+				return next.Mkdir(name, perm)
+			},
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+	var calls []string
+	fs := newFS(t, recordMkdir(&calls, "outer"), Chain(recordMkdir(&calls, "middle"), recordMkdir(&calls, "inner")))
+	assert.NoError(t, fs.Mkdir("foo", 0700))
+	assert.Equal(t, []string{"outer", "middle", "inner"}, calls)
+
+	// operations the middleware left nil still reach the source
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("bar"), 0600))
+	contents, err := fs.ReadFile("foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(contents))
+	assert.Equal(t, []string{"outer", "middle", "inner"}, calls)
+}
+
+func TestObserve(t *testing.T) {
+	t.Parallel()
+	type call struct {
+		Op   string
+		Name string
+		Err  bool
+	}
+	var (
+		callsMu sync.Mutex
+		calls   []call
+	)
+	fs := newFS(t, Observe(func(op, name string, err error) {
+		callsMu.Lock()
+		calls = append(calls, call{Op: op, Name: name, Err: err != nil})
+		callsMu.Unlock()
+	}))
+
+	assert.NoError(t, fs.Mkdir("foo", 0700))
+	assert.NoError(t, fs.WriteFile("foo/bar", nil, 0600))
+	f, err := fs.Open("foo/bar")
+	if assert.NoError(t, err) {
+		assert.NoError(t, f.Close())
+	}
+	assert.NoError(t, fs.Rename("foo/bar", "foo/baz"))
+	_, err = fs.Stat("foo/bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	callsMu.Lock()
+	defer callsMu.Unlock()
+	assert.Equal(t, []call{
+		{Op: "mkdir", Name: "foo"},
+		{Op: "writefile", Name: "foo/bar"},
+		{Op: "open", Name: "foo/bar"},
+		{Op: "rename", Name: "foo/bar"},
+		{Op: "stat", Name: "foo/bar", Err: true},
+	}, calls)
+}
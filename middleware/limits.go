@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"errors"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// ErrLimitExceeded is matched by errors.Is() when an operation is rejected by a Limits middleware
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limit names the limit a LimitError exceeded
+type Limit string
+
+// Limits enforced by the Limits middleware
+const (
+	LimitMutationRate Limit = "mutation rate"     // LimitMutationRate is LimitOptions.MaxMutationsPerSecond
+	LimitDirEntries   Limit = "directory entries" // LimitDirEntries is LimitOptions.MaxDirEntries
+)
+
+// LimitError records an operation rejected by a Limits middleware, before it reached the next Ops
+type LimitError struct {
+	Op    string
+	Path  string
+	Limit Limit
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return e.Op + " " + e.Path + ": " + ErrLimitExceeded.Error() + ": " + string(e.Limit) + " is limited to " + strconv.Itoa(e.Max)
+}
+
+// Is supports errors.Is(err, ErrLimitExceeded).
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// LimitOptions contain options for the Limits middleware. Zero values disable their limit.
+type LimitOptions struct {
+	// MaxMutationsPerSecond is the sustained rate of operations which change the file system, like Mkdir, Remove, or opening a file for writing.
+	// Up to a full second's worth of mutations may run in a burst after a quiet period.
+	MaxMutationsPerSecond int
+	// MaxDirEntries is the most entries an operation may leave in a directory when creating a new one
+	MaxDirEntries int
+	// Now returns the current time for the mutation rate. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// limits tracks the state of one Limits middleware
+type limits struct {
+	options LimitOptions
+	next    Ops
+
+	rateMu   sync.Mutex
+	tokens   float64 // mutations available now, refilled at options.MaxMutationsPerSecond
+	refilled time.Time
+
+	createMu sync.Mutex // serializes creating entries, so concurrent creates can't exceed options.MaxDirEntries
+}
+
+// Limits returns a Middleware which rejects operations with a *LimitError when they exceed the mutation rate or directory fan-out in 'options', protecting a shared backend from runaway programs.
+//
+// Writes to an already open file aren't counted as mutations. Directory fan-out is counted by listing the parent directory with the next Ops before each create, and only covers entries created through this middleware.
+func Limits(options LimitOptions) Middleware {
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+	return func(next Ops) Ops {
+		l := &limits{
+			options:  options,
+			next:     next,
+			tokens:   float64(options.MaxMutationsPerSecond),
+			refilled: options.Now(),
+		}
+		return l.ops()
+	}
+}
+
+// allowMutation takes one mutation from the rate limit, or fails with a *LimitError if none are available
+func (l *limits) allowMutation(op, name string) error {
+	max := l.options.MaxMutationsPerSecond
+	if max <= 0 {
+		return nil
+	}
+	l.rateMu.Lock()
+	defer l.rateMu.Unlock()
+	now := l.options.Now()
+	if elapsed := now.Sub(l.refilled); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * float64(max)
+		if l.tokens > float64(max) {
+			l.tokens = float64(max)
+		}
+	}
+	l.refilled = now
+	if l.tokens < 1 {
+		return &LimitError{Op: op, Path: name, Limit: LimitMutationRate, Max: max}
+	}
+	l.tokens--
+	return nil
+}
+
+func (l *limits) exists(name string) bool {
+	_, err := l.next.Lstat(name)
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		_, err = l.next.Stat(name)
+	}
+	return err == nil
+}
+
+// allowEntry fails with a *LimitError if creating 'name' would put too many entries in its parent directory.
+// Paths which already exist and parents which can't be listed are allowed, so the operation reports its own errors.
+func (l *limits) allowEntry(op, name string) error {
+	max := l.options.MaxDirEntries
+	if max <= 0 || !hackpadfs.ValidPath(name) || name == "." || l.exists(name) {
+		return nil
+	}
+	entries, err := l.next.ReadDir(path.Dir(name))
+	if err == nil && len(entries) >= max {
+		return &LimitError{Op: op, Path: name, Limit: LimitDirEntries, Max: max}
+	}
+	return nil
+}
+
+// create runs 'fn' if a mutation is available and creating 'name' stays within the fan-out limit
+func (l *limits) create(op, name string, fn func() error) error {
+	if err := l.allowMutation(op, name); err != nil {
+		return err
+	}
+	if l.options.MaxDirEntries <= 0 {
+		return fn()
+	}
+	l.createMu.Lock()
+	defer l.createMu.Unlock()
+	if err := l.allowEntry(op, name); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// mutate runs 'fn' if a mutation is available
+func (l *limits) mutate(op, name string, fn func() error) error {
+	if err := l.allowMutation(op, name); err != nil {
+		return err
+	}
+	return fn()
+}
+
+func (l *limits) ops() Ops {
+	next := l.next
+	return Ops{
+		OpenFile: func(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+			if flag == hackpadfs.FlagReadOnly {
+				return next.OpenFile(name, flag, perm)
+			}
+			var file hackpadfs.File
+			openFile := func() error {
+				var err error
+				file, err = next.OpenFile(name, flag, perm)
+				return err
+			}
+			var err error
+			if flag&hackpadfs.FlagCreate != 0 {
+				err = l.create("open", name, openFile)
+			} else {
+				err = l.mutate("open", name, openFile)
+			}
+			return file, err
+		},
+		Mkdir: func(name string, perm hackpadfs.FileMode) error {
+			return l.create("mkdir", name, func() error {
+				return next.Mkdir(name, perm)
+			})
+		},
+		MkdirAll: func(name string, perm hackpadfs.FileMode) error {
+			// only the first missing directory adds an entry to an existing directory
+			firstMissing := name
+			for dir := path.Dir(firstMissing); dir != firstMissing && !l.exists(dir); dir = path.Dir(firstMissing) {
+				firstMissing = dir
+			}
+			return l.create("mkdirall", firstMissing, func() error {
+				return next.MkdirAll(name, perm)
+			})
+		},
+		Remove: func(name string) error {
+			return l.mutate("remove", name, func() error {
+				return next.Remove(name)
+			})
+		},
+		RemoveAll: func(name string) error {
+			return l.mutate("removeall", name, func() error {
+				return next.RemoveAll(name)
+			})
+		},
+		Rename: func(oldname, newname string) error {
+			rename := func() error {
+				return next.Rename(oldname, newname)
+			}
+			if path.Dir(oldname) == path.Dir(newname) {
+				return l.mutate("rename", newname, rename)
+			}
+			return l.create("rename", newname, rename)
+		},
+		Chmod: func(name string, mode hackpadfs.FileMode) error {
+			return l.mutate("chmod", name, func() error {
+				return next.Chmod(name, mode)
+			})
+		},
+		Chown: func(name string, uid, gid int) error {
+			return l.mutate("chown", name, func() error {
+				return next.Chown(name, uid, gid)
+			})
+		},
+		Chtimes: func(name string, atime time.Time, mtime time.Time) error {
+			return l.mutate("chtimes", name, func() error {
+				return next.Chtimes(name, atime, mtime)
+			})
+		},
+		WriteFile: func(name string, data []byte, perm hackpadfs.FileMode) error {
+			return l.create("open", name, func() error {
+				return next.WriteFile(name, data, perm)
+			})
+		},
+		Symlink: func(oldname, newname string) error {
+			return l.create("symlink", newname, func() error {
+				return next.Symlink(oldname, newname)
+			})
+		},
+	}
+}
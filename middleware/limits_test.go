@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestLimitsFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "middleware limits",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			return newFS(tb, Limits(LimitOptions{MaxMutationsPerSecond: 1000000, MaxDirEntries: 1000}))
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func assertLimitError(tb testing.TB, limit Limit, err error) {
+	tb.Helper()
+	assert.ErrorIs(tb, ErrLimitExceeded, err)
+	var limitErr *LimitError
+	if assert.Equal(tb, true, errors.As(err, &limitErr)) {
+		assert.Equal(tb, limit, limitErr.Limit)
+	}
+}
+
+func TestLimitsMutationRate(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	fs := newFS(t, Limits(LimitOptions{
+		MaxMutationsPerSecond: 2,
+		Now:                   func() time.Time { return now },
+	}))
+
+	assert.NoError(t, fs.Mkdir("a", 0700))
+	assert.NoError(t, fs.Mkdir("b", 0700))
+	assertLimitError(t, LimitMutationRate, fs.Mkdir("c", 0700))
+	_, err := fs.Stat("c")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	// reads aren't limited
+	_, err = fs.Stat("a")
+	assert.NoError(t, err)
+	file, err := fs.Open("a")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	now = now.Add(500 * time.Millisecond)
+	assert.NoError(t, fs.Remove("a"))
+	assertLimitError(t, LimitMutationRate, fs.Remove("b"))
+
+	// a quiet period only refills one second's worth
+	now = now.Add(time.Minute)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "file", nil, 0600))
+	assert.NoError(t, fs.Chmod("file", 0644))
+	assertLimitError(t, LimitMutationRate, fs.Chmod("file", 0600))
+}
+
+func TestLimitsDirEntries(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t, Limits(LimitOptions{MaxDirEntries: 2}))
+
+	assert.NoError(t, fs.Mkdir("dir", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/a", nil, 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/b", nil, 0600))
+	assertLimitError(t, LimitDirEntries, hackpadfs.WriteFullFile(fs, "dir/c", nil, 0600))
+	assertLimitError(t, LimitDirEntries, fs.Mkdir("dir/c", 0700))
+	assertLimitError(t, LimitDirEntries, fs.MkdirAll("dir/c/d", 0700))
+	_, err := fs.OpenFile("dir/c", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate, 0600)
+	assertLimitError(t, LimitDirEntries, err)
+
+	// existing entries can still change
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/a", []byte("a"), 0600))
+	assert.NoError(t, fs.Rename("dir/a", "dir/c"))
+
+	assert.NoError(t, fs.Mkdir("other", 0700))
+	assertLimitError(t, LimitDirEntries, fs.Rename("other", "dir/other"))
+	assert.NoError(t, fs.Remove("dir/b"))
+	assert.NoError(t, fs.Rename("other", "dir/other"))
+	assert.NoError(t, fs.MkdirAll("dir/other/x/y", 0700))
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// Observe returns a Middleware which calls 'fn' after every operation, useful for logging or metrics.
+// 'op' is the lowercase operation name, like "open" or "mkdirall", and 'name' is the path it operated on. Rename passes its old name and Symlink passes its new one.
+func Observe(fn func(op, name string, err error)) Middleware {
+	return func(next Ops) Ops {
+		return Ops{
+			OpenFile: func(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+				f, err := next.OpenFile(name, flag, perm)
+				fn("open", name, err)
+				return f, err
+			},
+			Mkdir: func(name string, perm hackpadfs.FileMode) error {
+				err := next.Mkdir(name, perm)
+				fn("mkdir", name, err)
+				return err
+			},
+			MkdirAll: func(path string, perm hackpadfs.FileMode) error {
+				err := next.MkdirAll(path, perm)
+				fn("mkdirall", path, err)
+				return err
+			},
+			Remove: func(name string) error {
+				err := next.Remove(name)
+				fn("remove", name, err)
+				return err
+			},
+			RemoveAll: func(name string) error {
+				err := next.RemoveAll(name)
+				fn("removeall", name, err)
+				return err
+			},
+			Rename: func(oldname, newname string) error {
+				err := next.Rename(oldname, newname)
+				fn("rename", oldname, err)
+				return err
+			},
+			Stat: func(name string) (hackpadfs.FileInfo, error) {
+				info, err := next.Stat(name)
+				fn("stat", name, err)
+				return info, err
+			},
+			Lstat: func(name string) (hackpadfs.FileInfo, error) {
+				info, err := next.Lstat(name)
+				fn("lstat", name, err)
+				return info, err
+			},
+			Chmod: func(name string, mode hackpadfs.FileMode) error {
+				err := next.Chmod(name, mode)
+				fn("chmod", name, err)
+				return err
+			},
+			Chown: func(name string, uid, gid int) error {
+				err := next.Chown(name, uid, gid)
+				fn("chown", name, err)
+				return err
+			},
+			Chtimes: func(name string, atime time.Time, mtime time.Time) error {
+				err := next.Chtimes(name, atime, mtime)
+				fn("chtimes", name, err)
+				return err
+			},
+			ReadDir: func(name string) ([]hackpadfs.DirEntry, error) {
+				entries, err := next.ReadDir(name)
+				fn("readdir", name, err)
+				return entries, err
+			},
+			ReadFile: func(name string) ([]byte, error) {
+				data, err := next.ReadFile(name)
+				fn("readfile", name, err)
+				return data, err
+			},
+			WriteFile: func(name string, data []byte, perm hackpadfs.FileMode) error {
+				err := next.WriteFile(name, data, perm)
+				fn("writefile", name, err)
+				return err
+			},
+			Symlink: func(oldname, newname string) error {
+				err := next.Symlink(oldname, newname)
+				fn("symlink", newname, err)
+				return err
+			},
+			Readlink: func(name string) (string, error) {
+				target, err := next.Readlink(name)
+				fn("readlink", name, err)
+				return target, err
+			},
+		}
+	}
+}
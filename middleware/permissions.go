@@ -0,0 +1,334 @@
+package middleware
+
+import (
+	"errors"
+	"path"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fsmatch"
+)
+
+// Access is the level of access a PermissionRule grants
+type Access int
+
+// Access levels, from least to most access
+const (
+	AccessNone  Access = iota // AccessNone denies every operation
+	AccessRead                // AccessRead allows operations which don't change anything, like opening files read-only, Stat, and ReadDir
+	AccessWrite               // AccessWrite allows every operation
+)
+
+// PermissionRule grants 'Access' to the paths matching 'Pattern'
+type PermissionRule struct {
+	// Pattern is an fsmatch pattern, like "plugins/*/config.json". End a pattern with "/**" to match a directory and everything inside it.
+	Pattern string
+	Access  Access
+	// UIDs limits the rule to these user IDs. If empty, the rule applies to every user.
+	UIDs []int
+}
+
+// permissionRule is a PermissionRule with its compiled pattern
+type permissionRule struct {
+	matcher *fsmatch.Matcher
+	access  Access
+}
+
+// permissions checks operations against the rules which apply to one user
+type permissions struct {
+	rules []permissionRule
+	next  Ops
+	fs    hackpadfs.FS // fs runs operations on 'next', to resolve symlinks
+}
+
+// Permissions returns a Middleware which checks every operation against 'rules' for the user 'uid', failing with hackpadfs.ErrPermission before reaching the next Ops if the operation isn't allowed.
+// The first rule matching a path decides its access. Paths matching no rule are writable, so end 'rules' with {Pattern: "**", Access: AccessNone} to deny everything else.
+//
+// Symlinks are resolved with the next Ops before checking, so a link can't reach a path its target's rules deny. Operations on a link itself, like Lstat and Remove, only resolve its parent directory.
+// RemoveAll and Rename also need write access to everything inside a directory. Names of denied files are still visible when listing a readable parent directory.
+//
+// Fails with path.ErrBadPattern if a rule's pattern is malformed.
+func Permissions(uid int, rules ...PermissionRule) (Middleware, error) {
+	var userRules []permissionRule
+	for _, rule := range rules {
+		if !appliesTo(rule, uid) {
+			continue
+		}
+		matcher, err := fsmatch.Compile(rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		userRules = append(userRules, permissionRule{matcher: matcher, access: rule.Access})
+	}
+	return func(next Ops) Ops {
+		p := &permissions{rules: userRules, next: next, fs: &FS{ops: next}}
+		return p.ops()
+	}, nil
+}
+
+// appliesTo returns true if 'rule' applies to the user 'uid'
+func appliesTo(rule PermissionRule, uid int) bool {
+	if len(rule.UIDs) == 0 {
+		return true
+	}
+	for _, ruleUID := range rule.UIDs {
+		if ruleUID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// access returns the access granted to 'name', without resolving symlinks
+func (p *permissions) access(name string) Access {
+	for _, rule := range p.rules {
+		if rule.matcher.Match(name) {
+			return rule.access
+		}
+	}
+	return AccessWrite
+}
+
+// resolve returns 'name' with its symlinks resolved, including the last element if 'followLast' is set.
+// Paths which don't exist yet resolve their parent directory instead.
+func (p *permissions) resolve(name string, followLast bool) string {
+	if followLast {
+		if resolved, err := hackpadfs.EvalSymlinks(p.fs, name); err == nil {
+			return resolved
+		}
+	}
+	dir := path.Dir(name)
+	if dir == name {
+		return name
+	}
+	return path.Join(p.resolve(dir, true), path.Base(name))
+}
+
+// check returns the access to 'name' if it's at least 'need', checking both 'name' and the path it resolves to. Otherwise, fails with hackpadfs.ErrPermission.
+func (p *permissions) check(op, name string, need Access, followLast bool) (Access, error) {
+	access := p.access(name)
+	if access >= need && hackpadfs.ValidPath(name) {
+		if resolvedAccess := p.access(p.resolve(name, followLast)); resolvedAccess < access {
+			access = resolvedAccess
+		}
+	}
+	if access < need {
+		return access, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrPermission}
+	}
+	return access, nil
+}
+
+// checkTree checks 'name' for write access like check(), then checks everything inside it if 'name' is a directory which may contain denied paths
+func (p *permissions) checkTree(op, name string) error {
+	if _, err := p.check(op, name, AccessWrite, false); err != nil {
+		return err
+	}
+	return p.checkTreeContents(op, name)
+}
+
+func (p *permissions) checkTreeContents(op, dir string) error {
+	if !p.mayDenyInside(dir) {
+		return nil
+	}
+	info, err := p.next.Lstat(dir)
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		info, err = p.next.Stat(dir)
+	}
+	if err != nil || !info.IsDir() {
+		return nil // the operation itself reports missing paths
+	}
+	entries, err := p.next.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := path.Join(dir, entry.Name())
+		if p.access(name) < AccessWrite {
+			return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrPermission}
+		}
+		if entry.IsDir() {
+			if err := p.checkTreeContents(op, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mayDenyInside returns true if a rule without write access could match a path inside 'dir'
+func (p *permissions) mayDenyInside(dir string) bool {
+	for _, rule := range p.rules {
+		if rule.access < AccessWrite && !rule.matcher.SkipDir(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *permissions) ops() Ops {
+	next := p.next
+	return Ops{
+		OpenFile: func(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+			need := AccessWrite
+			if flag == hackpadfs.FlagReadOnly {
+				need = AccessRead
+			}
+			access, err := p.check("open", name, need, true)
+			if err != nil {
+				return nil, err
+			}
+			file, err := next.OpenFile(name, flag, perm)
+			if err != nil || access >= AccessWrite {
+				return file, err
+			}
+			return &readOnlyFile{File: file, name: name}, nil
+		},
+		Mkdir: func(name string, perm hackpadfs.FileMode) error {
+			if _, err := p.check("mkdir", name, AccessWrite, true); err != nil {
+				return err
+			}
+			return next.Mkdir(name, perm)
+		},
+		MkdirAll: func(name string, perm hackpadfs.FileMode) error {
+			if _, err := p.check("mkdirall", name, AccessWrite, true); err != nil {
+				return err
+			}
+			return next.MkdirAll(name, perm)
+		},
+		Remove: func(name string) error {
+			if _, err := p.check("remove", name, AccessWrite, false); err != nil {
+				return err
+			}
+			return next.Remove(name)
+		},
+		RemoveAll: func(name string) error {
+			if err := p.checkTree("removeall", name); err != nil {
+				return err
+			}
+			return next.RemoveAll(name)
+		},
+		Rename: func(oldname, newname string) error {
+			err := p.checkTree("rename", oldname)
+			if err == nil {
+				_, err = p.check("rename", newname, AccessWrite, false)
+			}
+			if errors.Is(err, hackpadfs.ErrPermission) {
+				return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrPermission}
+			}
+			if err != nil {
+				return err
+			}
+			return next.Rename(oldname, newname)
+		},
+		Stat: func(name string) (hackpadfs.FileInfo, error) {
+			if _, err := p.check("stat", name, AccessRead, true); err != nil {
+				return nil, err
+			}
+			return next.Stat(name)
+		},
+		Lstat: func(name string) (hackpadfs.FileInfo, error) {
+			if _, err := p.check("lstat", name, AccessRead, false); err != nil {
+				return nil, err
+			}
+			return next.Lstat(name)
+		},
+		Chmod: func(name string, mode hackpadfs.FileMode) error {
+			if _, err := p.check("chmod", name, AccessWrite, true); err != nil {
+				return err
+			}
+			return next.Chmod(name, mode)
+		},
+		Chown: func(name string, uid, gid int) error {
+			if _, err := p.check("chown", name, AccessWrite, true); err != nil {
+				return err
+			}
+			return next.Chown(name, uid, gid)
+		},
+		Chtimes: func(name string, atime time.Time, mtime time.Time) error {
+			if _, err := p.check("chtimes", name, AccessWrite, true); err != nil {
+				return err
+			}
+			return next.Chtimes(name, atime, mtime)
+		},
+		ReadDir: func(name string) ([]hackpadfs.DirEntry, error) {
+			if _, err := p.check("readdir", name, AccessRead, true); err != nil {
+				return nil, err
+			}
+			return next.ReadDir(name)
+		},
+		ReadFile: func(name string) ([]byte, error) {
+			if _, err := p.check("readfile", name, AccessRead, true); err != nil {
+				return nil, err
+			}
+			return next.ReadFile(name)
+		},
+		WriteFile: func(name string, data []byte, perm hackpadfs.FileMode) error {
+			if _, err := p.check("open", name, AccessWrite, true); err != nil {
+				return err
+			}
+			return next.WriteFile(name, data, perm)
+		},
+		Symlink: func(oldname, newname string) error {
+			if _, err := p.check("symlink", newname, AccessWrite, false); err != nil {
+				return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: hackpadfs.ErrPermission}
+			}
+			return next.Symlink(oldname, newname)
+		},
+		Readlink: func(name string) (string, error) {
+			if _, err := p.check("readlink", name, AccessRead, false); err != nil {
+				return "", err
+			}
+			return next.Readlink(name)
+		},
+	}
+}
+
+// readOnlyFile is an open file without write access. Reads pass through, but changes fail with hackpadfs.ErrPermission, even if the file could change them.
+type readOnlyFile struct {
+	hackpadfs.File
+	name string
+}
+
+func (f *readOnlyFile) permissionErr(op string) error {
+	return &hackpadfs.PathError{Op: op, Path: f.name, Err: hackpadfs.ErrPermission}
+}
+
+func (f *readOnlyFile) ReadAt(p []byte, off int64) (int, error) {
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+func (f *readOnlyFile) Seek(offset int64, whence int) (int64, error) {
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+func (f *readOnlyFile) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDirFile(f.File, n)
+}
+
+func (f *readOnlyFile) ReadDirNames(n int) ([]string, error) {
+	return hackpadfs.ReadDirNamesFile(f.File, n)
+}
+
+func (f *readOnlyFile) Write([]byte) (int, error) {
+	return 0, f.permissionErr("write")
+}
+
+func (f *readOnlyFile) WriteAt([]byte, int64) (int, error) {
+	return 0, f.permissionErr("write")
+}
+
+func (f *readOnlyFile) Truncate(int64) error {
+	return f.permissionErr("truncate")
+}
+
+func (f *readOnlyFile) Chmod(hackpadfs.FileMode) error {
+	return f.permissionErr("chmod")
+}
+
+func (f *readOnlyFile) Chown(int, int) error {
+	return f.permissionErr("chown")
+}
+
+func (f *readOnlyFile) Chtimes(time.Time, time.Time) error {
+	return f.permissionErr("chtimes")
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	osfs "github.com/hack-pad/hackpadfs/os"
+)
+
+func TestPermissionsFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "middleware permissions",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			permissions, err := Permissions(0, PermissionRule{Pattern: "denied/**", Access: AccessNone})
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			return newFS(tb, permissions)
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func newPermissionsFS(tb testing.TB, source hackpadfs.FS, uid int) *FS {
+	tb.Helper()
+	permissions, err := Permissions(uid,
+		PermissionRule{Pattern: "secret/**", Access: AccessNone},
+		PermissionRule{Pattern: "docs/**", Access: AccessRead},
+		PermissionRule{Pattern: "admin/**", Access: AccessWrite, UIDs: []int{0}},
+		PermissionRule{Pattern: "admin/**", Access: AccessRead},
+	)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	fs, err := NewFS(source, permissions)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func TestPermissions(t *testing.T) {
+	t.Parallel()
+	source, err := mem.NewFS()
+	assert.NoError(t, err)
+	for _, name := range []string{"secret/key", "docs/readme", "admin/config"} {
+		assert.NoError(t, hackpadfs.MkdirAll(source, name, 0700))
+		assert.NoError(t, hackpadfs.Remove(source, name))
+		assert.NoError(t, hackpadfs.WriteFullFile(source, name, []byte(name), 0600))
+	}
+	user := newPermissionsFS(t, source, 1)
+	admin := newPermissionsFS(t, source, 0)
+
+	contents, err := hackpadfs.ReadFile(user, "docs/readme")
+	assert.NoError(t, err)
+	assert.Equal(t, "docs/readme", string(contents))
+	assert.ErrorIs(t, hackpadfs.ErrPermission, hackpadfs.WriteFullFile(user, "docs/readme", nil, 0600))
+	assert.ErrorIs(t, hackpadfs.ErrPermission, hackpadfs.Chmod(user, "docs/readme", 0644))
+	file, err := user.Open("docs/readme")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, hackpadfs.ChmodFile(file, 0644))
+	assert.NoError(t, file.Close())
+
+	_, err = hackpadfs.ReadFile(user, "secret/key")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	_, err = hackpadfs.Stat(user, "secret")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, hackpadfs.WriteFullFile(user, "admin/config", nil, 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(admin, "admin/config", []byte("changed"), 0600))
+
+	assert.NoError(t, hackpadfs.WriteFullFile(user, "other", []byte("other"), 0600))
+	// moving or removing a directory needs write access to its contents too
+	assert.NoError(t, hackpadfs.Mkdir(user, "dir", 0700))
+	assert.ErrorIs(t, hackpadfs.ErrPermission, hackpadfs.Rename(user, "docs", "dir/docs"))
+	assert.ErrorIs(t, hackpadfs.ErrPermission, hackpadfs.RemoveAll(user, "."))
+	assert.NoError(t, hackpadfs.RemoveAll(user, "dir"))
+	_, err = hackpadfs.Stat(source, "docs/readme")
+	assert.NoError(t, err)
+
+	_, err = Permissions(0, PermissionRule{Pattern: "[", Access: AccessNone})
+	assert.Error(t, err)
+}
+
+func TestPermissionsSymlinks(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("Symlinks need extra privileges on Windows")
+	}
+	// os.FS writes symlink targets as host paths, so run from the host's root
+	dir := strings.TrimPrefix(filepath.ToSlash(t.TempDir()), "/")
+	source := osfs.NewFS()
+	assert.NoError(t, hackpadfs.Mkdir(source, dir+"/secret", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(source, dir+"/secret/key", []byte("key"), 0600))
+	permissions, err := Permissions(0, PermissionRule{Pattern: dir + "/secret/**", Access: AccessNone})
+	assert.NoError(t, err)
+	fs, err := NewFS(source, permissions)
+	assert.NoError(t, err)
+
+	assert.NoError(t, hackpadfs.Symlink(fs, dir+"/secret/key", dir+"/link"))
+	assert.NoError(t, hackpadfs.Symlink(fs, dir+"/secret", dir+"/dir-link"))
+	_, err = hackpadfs.ReadFile(fs, dir+"/link")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	_, err = hackpadfs.ReadFile(fs, dir+"/dir-link/key")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, hackpadfs.WriteFullFile(fs, dir+"/dir-link/new", nil, 0600))
+
+	// the links themselves aren't denied
+	_, err = hackpadfs.Readlink(fs, dir+"/link")
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Remove(fs, dir+"/link"))
+}
@@ -0,0 +1,66 @@
+package mirrorfs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// file wraps a writable file, replicating its final contents when closed
+type file struct {
+	hackpadfs.File
+	fs        *FS
+	name      string
+	closeOnce sync.Once
+}
+
+func (f *file) Close() error {
+	err := f.File.Close()
+	f.closeOnce.Do(func() {
+		if err == nil {
+			err = f.fs.mirrorFile(f.name)
+		}
+	})
+	return err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (n int, err error) {
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+func (f *file) Write(p []byte) (n int, err error) {
+	return hackpadfs.WriteFile(f.File, p)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (n int, err error) {
+	return hackpadfs.WriteAtFile(f.File, p, off)
+}
+
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDirFile(f.File, n)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+func (f *file) Sync() error {
+	return hackpadfs.SyncFile(f.File)
+}
+
+func (f *file) Truncate(size int64) error {
+	return hackpadfs.TruncateFile(f.File, size)
+}
+
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	return hackpadfs.ChmodFile(f.File, mode)
+}
+
+func (f *file) Chown(uid, gid int) error {
+	return hackpadfs.ChownFile(f.File, uid, gid)
+}
+
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	return hackpadfs.ChtimesFile(f.File, atime, mtime)
+}
@@ -0,0 +1,340 @@
+// Package mirrorfs contains a file system wrapper which replicates every mutation to one or more secondary file systems.
+package mirrorfs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.WriteFileFS
+		hackpadfs.CloserFS
+		hackpadfs.PingFS
+	} = &FS{}
+)
+
+// ErrClosed is returned when mirroring a mutation after the FS has been closed
+var ErrClosed = errors.New("mirror closed")
+
+// MirrorError records a mutation which succeeded on the primary FS but failed on a secondary
+type MirrorError struct {
+	Index int // Index of the secondary FS which failed
+	Err   error
+}
+
+func (e *MirrorError) Error() string {
+	return "mirror " + strconv.Itoa(e.Index) + ": " + e.Err.Error()
+}
+
+func (e *MirrorError) Unwrap() error {
+	return e.Err
+}
+
+// FS wraps a primary FS, serving all reads from the primary and replicating mutations to each secondary FS.
+//
+// Data written through an open file is replicated when the file is closed.
+type FS struct {
+	primaryFS   hackpadfs.FS
+	secondaries []hackpadfs.FS
+	options     Options
+
+	queueMu sync.Mutex
+	queue   chan queuedOp
+	closed  bool
+	done    chan struct{}
+
+	errMu    sync.Mutex
+	asyncErr error
+}
+
+// Options contain options for creating an FS
+type Options struct {
+	// Async replicates mutations in the background instead of waiting for each secondary to complete.
+	// Call Flush() to wait for queued mutations and Close() to stop replication.
+	Async bool
+	// QueueSize is the number of mutations which may be waiting for replication when Async is set. Mutations block while the queue is full. Defaults to 64.
+	QueueSize int
+	// OnError is called with a *MirrorError each time replicating a mutation fails.
+	OnError func(err error)
+}
+
+type queuedOp struct {
+	apply func(hackpadfs.FS) error
+	done  chan struct{}
+}
+
+// NewFS returns a new FS wrapping 'primary' and replicating mutations to 'secondaries'
+func NewFS(primary hackpadfs.FS, secondaries []hackpadfs.FS, options Options) (*FS, error) {
+	if options.QueueSize <= 0 {
+		options.QueueSize = 64
+	}
+	fs := &FS{
+		primaryFS:   primary,
+		secondaries: secondaries,
+		options:     options,
+	}
+	if options.Async {
+		fs.queue = make(chan queuedOp, options.QueueSize)
+		fs.done = make(chan struct{})
+		go fs.run()
+	}
+	return fs, nil
+}
+
+func (fs *FS) run() {
+	defer close(fs.done)
+	for op := range fs.queue {
+		if op.done != nil {
+			close(op.done)
+			continue
+		}
+		if err := fs.apply(op.apply); err != nil {
+			fs.errMu.Lock()
+			if fs.asyncErr == nil {
+				fs.asyncErr = err
+			}
+			fs.errMu.Unlock()
+		}
+	}
+}
+
+// apply runs 'apply' against every secondary FS and returns the first failure
+func (fs *FS) apply(apply func(hackpadfs.FS) error) error {
+	var firstErr error
+	for i, secondary := range fs.secondaries {
+		if err := apply(secondary); err != nil {
+			err = &MirrorError{Index: i, Err: err}
+			if fs.options.OnError != nil {
+				fs.options.OnError(err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// mirror replicates a mutation which succeeded on the primary FS.
+// In async mode, returns nil once the mutation is queued.
+func (fs *FS) mirror(apply func(hackpadfs.FS) error) error {
+	if !fs.options.Async {
+		return fs.apply(apply)
+	}
+	return fs.enqueue(queuedOp{apply: apply})
+}
+
+func (fs *FS) enqueue(op queuedOp) error {
+	fs.queueMu.Lock()
+	defer fs.queueMu.Unlock()
+	if fs.closed {
+		return ErrClosed
+	}
+	fs.queue <- op
+	return nil
+}
+
+// Flush waits for all queued mutations to replicate, then returns the first replication error since the last Flush, if any.
+func (fs *FS) Flush() error {
+	if fs.options.Async {
+		done := make(chan struct{})
+		if err := fs.enqueue(queuedOp{done: done}); err != nil {
+			return err
+		}
+		<-done
+	}
+	return fs.takeErr()
+}
+
+func (fs *FS) takeErr() error {
+	fs.errMu.Lock()
+	defer fs.errMu.Unlock()
+	err := fs.asyncErr
+	fs.asyncErr = nil
+	return err
+}
+
+// Close waits for all queued mutations to replicate and stops replication.
+// Returns the first replication error since the last Flush, if any.
+func (fs *FS) Close() error {
+	if fs.options.Async {
+		fs.queueMu.Lock()
+		if !fs.closed {
+			fs.closed = true
+			close(fs.queue)
+		}
+		fs.queueMu.Unlock()
+		<-fs.done
+	}
+	return fs.takeErr()
+}
+
+// Ping implements hackpadfs.PingFS, checking the primary FS and then each secondary. A failing secondary is reported as a *MirrorError.
+func (fs *FS) Ping(ctx context.Context) error {
+	if err := hackpadfs.Ping(ctx, fs.primaryFS); err != nil {
+		return err
+	}
+	for i, secondary := range fs.secondaries {
+		if err := hackpadfs.Ping(ctx, secondary); err != nil {
+			return &MirrorError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	f, err := hackpadfs.OpenFile(fs.primaryFS, name, flag, perm)
+	if err != nil || !hackpadfs.ParseFlags(flag).Modifies() {
+		return f, err
+	}
+	return &file{File: f, fs: fs, name: name}, nil
+}
+
+// mirrorFile replicates the contents, mode, and modified time of the file 'name'
+func (fs *FS) mirrorFile(name string) error {
+	info, err := hackpadfs.Stat(fs.primaryFS, name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+	data, err := hackpadfs.ReadFile(fs.primaryFS, name)
+	if err != nil {
+		return err
+	}
+	mode, modTime := info.Mode(), info.ModTime()
+	return fs.mirror(func(secondary hackpadfs.FS) error {
+		if err := hackpadfs.WriteFullFile(secondary, name, data, mode); err != nil {
+			return err
+		}
+		if err := ignoreNotImplemented(hackpadfs.Chmod(secondary, name, mode)); err != nil {
+			return err
+		}
+		return ignoreNotImplemented(hackpadfs.Chtimes(secondary, name, modTime, modTime))
+	})
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	if err := hackpadfs.Mkdir(fs.primaryFS, name, perm); err != nil {
+		return err
+	}
+	return fs.mirror(func(secondary hackpadfs.FS) error {
+		return hackpadfs.Mkdir(secondary, name, perm)
+	})
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	if err := hackpadfs.MkdirAll(fs.primaryFS, path, perm); err != nil {
+		return err
+	}
+	return fs.mirror(func(secondary hackpadfs.FS) error {
+		return hackpadfs.MkdirAll(secondary, path, perm)
+	})
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	if err := hackpadfs.Remove(fs.primaryFS, name); err != nil {
+		return err
+	}
+	return fs.mirror(func(secondary hackpadfs.FS) error {
+		return hackpadfs.Remove(secondary, name)
+	})
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	if err := hackpadfs.RemoveAll(fs.primaryFS, name); err != nil {
+		return err
+	}
+	return fs.mirror(func(secondary hackpadfs.FS) error {
+		return hackpadfs.RemoveAll(secondary, name)
+	})
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	if err := hackpadfs.Rename(fs.primaryFS, oldname, newname); err != nil {
+		return err
+	}
+	return fs.mirror(func(secondary hackpadfs.FS) error {
+		return hackpadfs.Rename(secondary, oldname, newname)
+	})
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Stat(fs.primaryFS, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	if err := hackpadfs.Chmod(fs.primaryFS, name, mode); err != nil {
+		return err
+	}
+	return fs.mirror(func(secondary hackpadfs.FS) error {
+		return hackpadfs.Chmod(secondary, name, mode)
+	})
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := hackpadfs.Chtimes(fs.primaryFS, name, atime, mtime); err != nil {
+		return err
+	}
+	return fs.mirror(func(secondary hackpadfs.FS) error {
+		return hackpadfs.Chtimes(secondary, name, atime, mtime)
+	})
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDir(fs.primaryFS, name)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	return hackpadfs.ReadFile(fs.primaryFS, name)
+}
+
+// WriteFile implements hackpadfs.WriteFileFS
+func (fs *FS) WriteFile(name string, data []byte, perm hackpadfs.FileMode) error {
+	if err := hackpadfs.WriteFullFile(fs.primaryFS, name, data, perm); err != nil {
+		return err
+	}
+	return fs.mirrorFile(name)
+}
+
+func ignoreNotImplemented(err error) error {
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package mirrorfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newMemFS(tb testing.TB) *mem.FS {
+	tb.Helper()
+	fs, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func newFS(tb testing.TB, options Options) (*FS, *mem.FS) {
+	tb.Helper()
+	secondary := newMemFS(tb)
+	fs, err := NewFS(newMemFS(tb), []hackpadfs.FS{secondary}, options)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	tb.Cleanup(func() {
+		assert.NoError(tb, fs.Close())
+	})
+	return fs, secondary
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	for _, async := range []bool{false, true} {
+		async := async
+		name := "mirrorfs sync"
+		if async {
+			name = "mirrorfs async"
+		}
+		options := fstest.FSOptions{
+			Name: name,
+			TestFS: func(tb testing.TB) fstest.SetupFS {
+				fs, _ := newFS(tb, Options{Async: async})
+				return fs
+			},
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+		fstest.StdFS(t, options)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	t.Parallel()
+	for _, async := range []bool{false, true} {
+		async := async
+		name := "sync"
+		if async {
+			name = "async"
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			fs, secondary := newFS(t, Options{Async: async, QueueSize: 1})
+			assert.NoError(t, hackpadfs.MkdirAll(fs, "foo/bar", 0700))
+			f, err := hackpadfs.Create(fs, "foo/bar/baz")
+			if assert.NoError(t, err) {
+				_, err = hackpadfs.WriteFile(f, []byte("baz"))
+				assert.NoError(t, err)
+				assert.NoError(t, f.Close())
+			}
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/biff", []byte("biff"), 0600))
+			assert.NoError(t, fs.Rename("foo/biff", "foo/boo"))
+			assert.NoError(t, fs.Chmod("foo/boo", 0400))
+			assert.NoError(t, fs.Flush())
+
+			contents, err := hackpadfs.ReadFile(secondary, "foo/bar/baz")
+			assert.NoError(t, err)
+			assert.Equal(t, "baz", string(contents))
+			info, err := hackpadfs.Stat(secondary, "foo/boo")
+			if assert.NoError(t, err) {
+				assert.Equal(t, hackpadfs.FileMode(0400), info.Mode())
+			}
+			_, err = hackpadfs.Stat(secondary, "foo/biff")
+			assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		})
+	}
+}
+
+func TestMirrorError(t *testing.T) {
+	t.Parallel()
+	var reported []error
+	fs, secondary := newFS(t, Options{
+		Async: true,
+		OnError: func(err error) {
+			reported = append(reported, err)
+		},
+	})
+	assert.NoError(t, secondary.Mkdir("foo", 0700)) // diverge from the primary
+	assert.NoError(t, fs.Mkdir("foo", 0700))
+
+	err := fs.Flush()
+	var mirrorErr *MirrorError
+	if assert.ErrorAs(t, &mirrorErr, err) {
+		assert.Equal(t, 0, mirrorErr.Index)
+	}
+	assert.ErrorIs(t, hackpadfs.ErrExist, err)
+	assert.Equal(t, 1, len(reported))
+	assert.NoError(t, fs.Flush())
+}
+
+type pingFS struct {
+	*mem.FS
+	err error
+}
+
+func (fs *pingFS) Ping(ctx context.Context) error {
+	return fs.err
+}
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	someErr := errors.New("some error")
+	fs, err := NewFS(newMemFS(t), []hackpadfs.FS{newMemFS(t), &pingFS{FS: newMemFS(t), err: someErr}}, Options{})
+	assert.NoError(t, err)
+	err = fs.Ping(ctx)
+	var mirrorErr *MirrorError
+	if assert.ErrorAs(t, &mirrorErr, err) {
+		assert.Equal(t, 1, mirrorErr.Index)
+	}
+	assert.ErrorIs(t, someErr, err)
+
+	fs, err = NewFS(&pingFS{FS: newMemFS(t), err: someErr}, nil, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, someErr, fs.Ping(ctx))
+}
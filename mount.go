@@ -2,6 +2,18 @@ package hackpadfs
 
 import "strings"
 
+// mountPointOf returns the mount point of 'name', given its path 'mountSubPath' inside the mount
+func mountPointOf(name, mountSubPath string) string {
+	switch {
+	case mountSubPath == name:
+		return "."
+	case mountSubPath == ".":
+		return name
+	default:
+		return strings.TrimSuffix(name, "/"+mountSubPath)
+	}
+}
+
 func stripErrPathPrefix(err error, name, mountSubPath string) error {
 	if err == nil {
 		return err
@@ -0,0 +1,58 @@
+package mount
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// Close implements hackpadfs.CloserFS
+//
+// Closes every mounted file system, deepest mount points first, then the root file system. Lazy mounts which haven't been initialized are skipped.
+// All file systems are closed even if some fail. Returns the first error.
+func (fs *FS) Close() error {
+	paths, mounts := fs.initializedMounts()
+	var firstErr error
+	for i, mountFS := range mounts {
+		if err := hackpadfs.Close(mountFS); err != nil && firstErr == nil {
+			firstErr = &hackpadfs.PathError{Op: "close", Path: paths[i], Err: err}
+		}
+	}
+	if err := hackpadfs.Close(fs.rootFS); err != nil && firstErr == nil {
+		firstErr = &hackpadfs.PathError{Op: "close", Path: ".", Err: err}
+	}
+	return firstErr
+}
+
+// initializedMounts returns the mount points and their file systems, deepest mount points first. Skips lazy mounts which haven't been initialized.
+func (fs *FS) initializedMounts() (paths []string, mounts []hackpadfs.FS) {
+	var allPaths []string
+	fs.mounts.Range(func(key, _ interface{}) bool {
+		allPaths = append(allPaths, key.(string))
+		return true
+	})
+	sort.Slice(allPaths, func(a, b int) bool {
+		depthA, depthB := strings.Count(allPaths[a], "/"), strings.Count(allPaths[b], "/")
+		if depthA != depthB {
+			return depthA > depthB
+		}
+		return allPaths[a] < allPaths[b]
+	})
+
+	for _, p := range allPaths {
+		value, ok := fs.mounts.Load(p)
+		if !ok {
+			continue
+		}
+		mountFS, isFS := value.(hackpadfs.FS)
+		if lazy, isLazy := value.(*lazyMount); isLazy {
+			mountFS, isFS = lazy.initialized()
+		}
+		if isFS {
+			paths = append(paths, p)
+			mounts = append(mounts, mountFS)
+		}
+	}
+	return paths, mounts
+}
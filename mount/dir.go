@@ -0,0 +1,101 @@
+package mount
+
+import (
+	gofs "io/fs"
+	"path"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// mountRootInfo is the info of a mounted file system's root, named after its mount point instead of "."
+type mountRootInfo struct {
+	hackpadfs.FileInfo
+	name string
+}
+
+func (i mountRootInfo) Name() string {
+	return i.name
+}
+
+// hasChildMounts returns true if a file system is mounted directly inside 'dir'
+func (fs *FS) hasChildMounts(dir string) bool {
+	found := false
+	fs.mounts.Range(func(key, _ interface{}) bool {
+		found = path.Dir(key.(string)) == dir
+		return !found
+	})
+	return found
+}
+
+// withMounts replaces the entries in 'dir' which are mount points with their mounted roots, like a mount point on a Unix system
+func (fs *FS) withMounts(dir string, entries []hackpadfs.DirEntry) ([]hackpadfs.DirEntry, error) {
+	for i, entry := range entries {
+		name := path.Join(dir, entry.Name())
+		if _, isMount := fs.mounts.Load(name); !isMount {
+			continue
+		}
+		info, err := fs.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = gofs.FileInfoToDirEntry(info)
+	}
+	return entries, nil
+}
+
+// mountDir is a directory which is a mount point's root or contains mount points, so its info and entries match those from this FS's Stat() and ReadDir()
+type mountDir struct {
+	hackpadfs.File
+	fs        *FS
+	name      string
+	mountRoot bool
+}
+
+// wrapDir returns 'file', opened at 'name', wrapped in a mountDir if needed
+func (fs *FS) wrapDir(file hackpadfs.File, name string, mountRoot bool) hackpadfs.File {
+	if !mountRoot && !fs.hasChildMounts(name) {
+		return file
+	}
+	return &mountDir{File: file, fs: fs, name: name, mountRoot: mountRoot}
+}
+
+func (d *mountDir) Stat() (hackpadfs.FileInfo, error) {
+	info, err := d.File.Stat()
+	if err != nil || !d.mountRoot {
+		return info, err
+	}
+	return mountRootInfo{FileInfo: info, name: path.Base(d.name)}, nil
+}
+
+func (d *mountDir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	entries, err := hackpadfs.ReadDirFile(d.File, n)
+	if len(entries) > 0 {
+		var mountErr error
+		entries, mountErr = d.fs.withMounts(d.name, entries)
+		if mountErr != nil {
+			return nil, mountErr
+		}
+	}
+	return entries, err
+}
+
+func (d *mountDir) ReadDirNames(n int) ([]string, error) {
+	return hackpadfs.ReadDirNamesFile(d.File, n)
+}
+
+func (d *mountDir) Chmod(mode hackpadfs.FileMode) error {
+	return hackpadfs.ChmodFile(d.File, mode)
+}
+
+func (d *mountDir) Chown(uid, gid int) error {
+	return hackpadfs.ChownFile(d.File, uid, gid)
+}
+
+func (d *mountDir) Chtimes(atime, mtime time.Time) error {
+	return hackpadfs.ChtimesFile(d.File, atime, mtime)
+}
+
+func (d *mountDir) Sync() error {
+	return hackpadfs.SyncFile(d.File)
+}
@@ -2,10 +2,14 @@
 package mount
 
 import (
-	"io"
+	"context"
+	"errors"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hack-pad/hackpadfs"
 )
@@ -13,8 +17,14 @@ import (
 var (
 	_ interface {
 		hackpadfs.FS
+		hackpadfs.CloserFS
 		hackpadfs.MountFS
+		hackpadfs.PingFS
+		hackpadfs.OpenFileFS
+		hackpadfs.ReadDirFS
 		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.SubFS
 	} = &FS{}
 )
 
@@ -24,15 +34,41 @@ var (
 // For ease of use, call the standard operations via hackpadfs.OpenFile(fs, ...), hackpadfs.Mkdir(fs, ...), etc.
 type FS struct {
 	rootFS  hackpadfs.FS
+	options Options
 	mountMu sync.Mutex
-	mounts  sync.Map // map[string]hackpadfs.FS
+	mounts  sync.Map // map[string]hackpadfs.FS or map[string]*lazyMount
+
+	statCache *statCache // nil if Options.StatCacheTTL is not set
+}
+
+// Options contain options for creating an FS
+type Options struct {
+	// NoCrossMountRename fails renames between different mounts with hackpadfs.ErrCrossDevice, like renames across devices with the os package.
+	// By default, these renames copy the file or directory to the new mount, then remove the original. Copies don't keep the original's owner, so set this if ownership must survive a rename.
+	NoCrossMountRename bool
+	// LazyMountRetryDelay is the minimum time between calls to a lazy mount's init func after it fails. Until then, operations inside the mount fail with the last error.
+	// By default, init is retried on the next access.
+	LazyMountRetryDelay time.Duration
+	// StatCacheTTL enables caching successful Stat() results for this long, to avoid repeatedly calling slow mounted file systems. Disabled by default.
+	// Cached results may be stale until they expire, unless invalidated with InvalidateStat().
+	StatCacheTTL time.Duration
 }
 
 // NewFS returns a new FS.
 func NewFS(rootFS hackpadfs.FS) (*FS, error) {
-	return &FS{
-		rootFS: rootFS,
-	}, nil
+	return NewFSWithOptions(rootFS, Options{})
+}
+
+// NewFSWithOptions returns a new FS configured by 'options'.
+func NewFSWithOptions(rootFS hackpadfs.FS, options Options) (*FS, error) {
+	fs := &FS{
+		rootFS:  rootFS,
+		options: options,
+	}
+	if options.StatCacheTTL > 0 {
+		fs.statCache = newStatCache(options.StatCacheTTL)
+	}
+	return fs, nil
 }
 
 // AddMount mounts 'mount' at 'path'. The mount point must already exist as a directory.
@@ -44,7 +80,8 @@ func (fs *FS) AddMount(path string, mount hackpadfs.FS) error {
 	return nil
 }
 
-func (fs *FS) addMount(p string, mountFS hackpadfs.FS) error {
+// addMount stores 'mountFS', a hackpadfs.FS or *lazyMount, at 'p'
+func (fs *FS) addMount(p string, mountFS interface{}) error {
 	if !hackpadfs.ValidPath(p) || p == "." {
 		return hackpadfs.ErrInvalid
 	}
@@ -56,14 +93,7 @@ func (fs *FS) addMount(p string, mountFS hackpadfs.FS) error {
 	fs.mountMu.Lock()
 	defer fs.mountMu.Unlock()
 
-	dir, base := path.Split(p)
-	parentFS, subPath := fs.Mount(dir) // get this mount point's parent mount, verify dir exists
-	f, err := parentFS.Open(path.Join(subPath, base))
-	if err != nil {
-		return err
-	}
-	defer func() { _ = f.Close() }()
-	info, err := f.Stat()
+	info, err := fs.Stat(p) // verify mount point exists in its parent mount
 	if err != nil {
 		return err
 	}
@@ -77,6 +107,7 @@ func (fs *FS) addMount(p string, mountFS hackpadfs.FS) error {
 		// cannot mount at same point as existing mount
 		return hackpadfs.ErrExist
 	}
+	fs.InvalidateStat(p)
 	return nil
 }
 
@@ -90,10 +121,13 @@ func (fs *FS) Mount(path string) (mount hackpadfs.FS, subPath string) {
 }
 
 func (fs *FS) mountPoint(path string) (_ hackpadfs.FS, mountPoint, subPath string) {
+	if !hackpadfs.ValidPath(path) {
+		return fs.rootFS, ".", path // let the root file system reject invalid paths, instead of resolving them inside a mount
+	}
 	var resultPath string
-	resultFS := fs.rootFS
-	fs.mounts.Range(func(key, value interface{}) bool {
-		mountPath, mountFS := key.(string), value.(hackpadfs.FS)
+	var resultFS interface{} = fs.rootFS
+	fs.mounts.Range(func(key, mountFS interface{}) bool {
+		mountPath := key.(string)
 		switch {
 		case strings.HasPrefix(path, mountPath+"/"):
 			if len(mountPath) > len(resultPath) {
@@ -118,13 +152,59 @@ func (fs *FS) mountPoint(path string) (_ hackpadfs.FS, mountPoint, subPath strin
 	if subPath == "" {
 		subPath = "."
 	}
-	return resultFS, resultPath, subPath
+	if lazy, ok := resultFS.(*lazyMount); ok {
+		return lazy.get(), resultPath, subPath
+	}
+	return resultFS.(hackpadfs.FS), resultPath, subPath
 }
 
 // Open implements hackpadfs.FS
 func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	mountFS, mountPath, subPath := fs.mountPoint(name)
+	if mountPath == "." {
+		subPath = name
+	}
+	file, err := mountFS.Open(subPath)
+	if err != nil {
+		return nil, err
+	}
+	return fs.wrapDir(file, name, isMountRoot(mountPath, subPath)), nil
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	mountFS, mountPath, subPath := fs.mountPoint(name)
+	if mountPath == "." {
+		subPath = name
+	}
+	file, err := hackpadfs.OpenFile(mountFS, subPath, flag, perm)
+	if err != nil {
+		return nil, errWithPath(err, name)
+	}
+	return fs.wrapDir(file, name, isMountRoot(mountPath, subPath)), nil
+}
+
+// isMountRoot returns true if 'subPath' is the root of a file system mounted at 'mountPath'
+func isMountRoot(mountPath, subPath string) bool {
+	return mountPath != "." && subPath == "."
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
 	mountFS, subPath := fs.Mount(name)
-	return mountFS.Open(subPath)
+	entries, err := hackpadfs.ReadDir(mountFS, subPath)
+	if err != nil {
+		return nil, errWithPath(err, name)
+	}
+	return fs.withMounts(name, entries)
+}
+
+// errWithPath replaces the path in a mounted file system's PathError with 'name', the path used in this FS
+func errWithPath(err error, name string) error {
+	if pathErr, ok := err.(*hackpadfs.PathError); ok {
+		return &hackpadfs.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+	}
+	return err
 }
 
 // Point represents a mount point, including any relevant metadata
@@ -143,8 +223,32 @@ func (fs *FS) MountPoints() []Point {
 	return points
 }
 
+// Ping implements hackpadfs.PingFS
+//
+// Checks the root file system and every mounted file system, returning the first error with the failing mount point in its hackpadfs.ErrorContext.
+// Lazy mounts which haven't been initialized are skipped, so a health check doesn't create them.
+func (fs *FS) Ping(ctx context.Context) error {
+	if err := hackpadfs.Ping(ctx, fs.rootFS); err != nil {
+		return err
+	}
+	paths, mounts := fs.initializedMounts()
+	for i, mountFS := range mounts {
+		if err := hackpadfs.Ping(ctx, mountFS); err != nil {
+			return hackpadfs.WithErrorContext(err, hackpadfs.ErrorContext{MountPoint: paths[i]})
+		}
+	}
+	return nil
+}
+
 // Rename implements hackpadfs.RenameFS
+//
+// Renaming between mounts copies the file or directory tree with hackpadfs.CopyFS(), keeping permissions and modified times where supported, then removes the original.
+// An existing file at 'newname' is only replaced once the copy is complete, by renaming the copy over it within its mount. If the copy fails, the partial copy is removed. Trees containing files other than directories and regular files, like symlinks, fail with hackpadfs.ErrCrossDevice.
+// Ownership is not preserved, since file infos have no portable owner to Chown() the copies to. Copies are owned by whoever the destination FS creates files as, like the current user for an os.FS.
+// Set Options.NoCrossMountRename to always fail with hackpadfs.ErrCrossDevice instead.
 func (fs *FS) Rename(oldname, newname string) error {
+	defer fs.InvalidateStat(newname)
+	defer fs.InvalidateStat(oldname)
 	oldMount, oldPoint, oldSubPath := fs.mountPoint(oldname)
 	newMount, newPoint, newSubPath := fs.mountPoint(newname)
 	oldInfo, err := hackpadfs.Stat(oldMount, oldSubPath)
@@ -161,29 +265,73 @@ func (fs *FS) Rename(oldname, newname string) error {
 	if oldPoint == newPoint {
 		return hackpadfs.Rename(oldMount, oldSubPath, newSubPath)
 	}
-	if oldInfo.IsDir() {
-		// TODO support renaming directories
-		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrNotImplemented}
+	crossDeviceErr := hackpadfs.WithErrorContext(
+		&hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrCrossDevice},
+		hackpadfs.ErrorContext{MountPoint: newPoint},
+	)
+	if fs.options.NoCrossMountRename || oldSubPath == "." {
+		return crossDeviceErr // can't remove a mount's root
+	}
+	if copyable, err := isCopyable(oldMount, oldSubPath); err != nil || !copyable {
+		if err != nil {
+			return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+		}
+		return crossDeviceErr
 	}
 
-	oldFile, err := oldMount.Open(oldSubPath)
-	if err != nil {
-		return err
+	_, err = hackpadfs.Stat(newMount, newSubPath)
+	newExists := err == nil
+	if newExists && oldInfo.IsDir() {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrExist}
 	}
-	defer func() { _ = oldFile.Close() }()
-	newFile, err := hackpadfs.OpenFile(newMount, newSubPath, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, oldInfo.Mode())
-	if err != nil {
-		return err
+	copyPath := newSubPath
+	if newExists {
+		copyPath = renameTempPath(newSubPath) // keep the existing file until the copy is complete
+	}
+	if oldInfo.IsDir() {
+		err = hackpadfs.Mkdir(newMount, copyPath, oldInfo.Mode().Perm())
+		if err == nil {
+			err = hackpadfs.CopyFSWithOptions(newMount, copyPath, oldMount, oldSubPath, hackpadfs.CopyOptions{PreserveTimes: true})
+		}
+	} else {
+		err = hackpadfs.CopyFile(newMount, copyPath, oldMount, oldSubPath)
+		if err == nil {
+			err = hackpadfs.Chtimes(newMount, copyPath, oldInfo.ModTime(), oldInfo.ModTime())
+			if errors.Is(err, hackpadfs.ErrNotImplemented) {
+				err = nil
+			}
+		}
 	}
-	newFileWriter, ok := newFile.(io.Writer)
-	if !ok {
-		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrPermission}
+	if err == nil && newExists {
+		err = hackpadfs.Rename(newMount, copyPath, newSubPath)
 	}
-	defer func() { _ = newFile.Close() }()
-	_, err = io.Copy(newFileWriter, oldFile)
 	if err != nil {
-		_ = hackpadfs.Remove(newMount, newSubPath)
-		return err
+		_ = hackpadfs.RemoveAll(newMount, copyPath)
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
 	}
-	return hackpadfs.Remove(oldMount, oldSubPath)
+	return hackpadfs.RemoveAll(oldMount, oldSubPath)
+}
+
+var renameTempCount uint64
+
+// renameTempPath returns a new path next to 'name' to copy a file to before renaming it over 'name'
+func renameTempPath(name string) string {
+	count := atomic.AddUint64(&renameTempCount, 1)
+	return path.Join(path.Dir(name), "."+path.Base(name)+".rename-"+strconv.FormatInt(time.Now().UnixNano(), 36)+"-"+strconv.FormatUint(count, 36))
+}
+
+// isCopyable returns true if the tree at 'name' only contains directories and regular files, which CopyFS() copies completely
+func isCopyable(fs hackpadfs.FS, name string) (bool, error) {
+	copyable := true
+	err := hackpadfs.WalkDir(fs, name, func(_ string, dirEntry hackpadfs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !dirEntry.IsDir() && !dirEntry.Type().IsRegular() {
+			copyable = false
+			return hackpadfs.SkipDir
+		}
+		return nil
+	})
+	return copyable, err
 }
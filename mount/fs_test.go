@@ -1,16 +1,21 @@
 package mount_test
 
 import (
+	"context"
 	"errors"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
-	"github.com/hack-pad/hackpadfs/internal/mounttest"
 	"github.com/hack-pad/hackpadfs/mem"
 	"github.com/hack-pad/hackpadfs/mount"
+	_ "github.com/hack-pad/hackpadfs/os" // registers the "os" mount backend
 )
 
 func TestFS(t *testing.T) {
@@ -29,11 +34,12 @@ func TestFS(t *testing.T) {
 			requireNoError(tb, err)
 			fs, err := mount.NewFS(mem)
 			requireNoError(tb, err)
-			return mounttest.NewFS(fs)
+			return hackpadfs.FullFS(fs)
 		},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
+	fstest.StdFS(t, options)
 
 	options = fstest.FSOptions{
 		Name: "mount unused",
@@ -46,11 +52,80 @@ func TestFS(t *testing.T) {
 			fs, err := mount.NewFS(memRoot)
 			requireNoError(tb, err)
 			requireNoError(tb, fs.AddMount("unused", memUnused))
-			return mounttest.NewFS(fs)
+			return hackpadfs.FullFS(fs)
 		},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
+	fstest.StdFS(t, options)
+
+	options = fstest.FSOptions{
+		Name: "mount sub",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			memRoot, err := mem.NewFS()
+			requireNoError(tb, err)
+			requireNoError(tb, memRoot.Mkdir("sub", 0700))
+			memSub, err := mem.NewFS()
+			requireNoError(tb, err)
+			fs, err := mount.NewFS(memRoot)
+			requireNoError(tb, err)
+			requireNoError(tb, fs.AddMount("sub", memSub))
+			subFS, err := fs.Sub("sub")
+			requireNoError(tb, err)
+			return hackpadfs.FullFS(subFS)
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestSub(t *testing.T) {
+	t.Parallel()
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.MkdirAll(memRoot, "a/b", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(memRoot, "a/root", []byte("root"), 0600))
+	memB, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(memB, "b-file", []byte("b"), 0600))
+	fs, err := mount.NewFS(memRoot)
+	assert.NoError(t, err)
+	assert.NoError(t, fs.AddMount("a/b", memB))
+
+	subFS, err := hackpadfs.Sub(fs, "a")
+	assert.NoError(t, err)
+	contents, err := hackpadfs.ReadFile(subFS, "b/b-file")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(contents))
+	entries, err := hackpadfs.ReadDir(subFS, "b")
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, "b-file", entries[0].Name())
+	}
+
+	assert.NoError(t, hackpadfs.WriteFullFile(subFS, "b/new", []byte("new"), 0600))
+	_, err = hackpadfs.Stat(memB, "new")
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Rename(subFS, "root", "b/moved"))
+	contents, err = hackpadfs.ReadFile(memB, "moved")
+	assert.NoError(t, err)
+	assert.Equal(t, "root", string(contents))
+
+	_, err = hackpadfs.Stat(subFS, "b/missing")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	var pathErr *hackpadfs.PathError
+	if assert.ErrorAs(t, &pathErr, err) {
+		assert.Equal(t, "b/missing", pathErr.Path)
+	}
+	_, err = hackpadfs.Sub(fs, "../a")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+
+	nestedFS, err := hackpadfs.Sub(subFS, "b")
+	assert.NoError(t, err)
+	contents, err = hackpadfs.ReadFile(nestedFS, "b-file")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(contents))
 }
 
 func TestAddMount(t *testing.T) {
@@ -170,3 +245,571 @@ func TestMount(t *testing.T) {
 		assert.Equal(t, hackpadfs.FileMode(hackpadfs.ModeDir|0700), info.Mode())
 	}
 }
+
+func TestMountPointInfo(t *testing.T) {
+	t.Parallel()
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := mount.NewFS(memRoot)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0755))
+	memFoo, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, memFoo.Chmod(".", 0700))
+	assert.NoError(t, fs.AddMount("foo", memFoo))
+
+	info, err := fs.Stat("foo")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "foo", info.Name())
+		assert.Equal(t, hackpadfs.ModeDir|0700, info.Mode())
+	}
+	file, err := fs.Open("foo")
+	if assert.NoError(t, err) {
+		info, err := file.Stat()
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", info.Name())
+		assert.NoError(t, file.Close())
+	}
+	entries, err := fs.ReadDir(".")
+	if assert.NoError(t, err) && assert.Equal(t, 1, len(entries)) {
+		info, err := entries[0].Info()
+		assert.NoError(t, err)
+		assert.Equal(t, hackpadfs.ModeDir|0700, info.Mode())
+	}
+	root, err := fs.Open(".")
+	if assert.NoError(t, err) {
+		entries, err := hackpadfs.ReadDirFile(root, -1)
+		if assert.NoError(t, err) && assert.Equal(t, 1, len(entries)) {
+			info, err := entries[0].Info()
+			assert.NoError(t, err)
+			assert.Equal(t, hackpadfs.ModeDir|0700, info.Mode())
+		}
+		assert.NoError(t, root.Close())
+	}
+}
+
+func TestMountInvalidPaths(t *testing.T) {
+	t.Parallel()
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := mount.NewFS(memRoot)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+	memFoo, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, fs.AddMount("foo", memFoo))
+
+	for _, name := range []string{"foo/.", "foo/../foo", "foo/"} {
+		_, err = fs.Open(name)
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+		_, err = fs.Stat(name)
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+		_, err = fs.ReadDir(name)
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+		err = hackpadfs.Mkdir(fs, name+"/bar", 0700)
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	}
+}
+
+func TestRenameAcrossMounts(t *testing.T) {
+	t.Parallel()
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := mount.NewFS(memRoot)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+	memFoo, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, fs.AddMount("foo", memFoo))
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "bar", []byte("bar"), 0600))
+	assert.NoError(t, hackpadfs.Rename(fs, "bar", "foo/bar"))
+	contents, err := hackpadfs.ReadFile(memFoo, "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(contents))
+
+	_, err = hackpadfs.Stat(memRoot, "bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	modTime := time.Now().Add(-time.Hour).Round(time.Second)
+	assert.NoError(t, hackpadfs.MkdirAll(fs, "baz/biff", 0750))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "baz/biff/boo", []byte("boo"), 0640))
+	assert.NoError(t, hackpadfs.Chtimes(fs, "baz/biff/boo", modTime, modTime))
+	assert.NoError(t, hackpadfs.Chtimes(fs, "baz/biff", modTime, modTime))
+	assert.NoError(t, hackpadfs.Rename(fs, "baz", "foo/baz"))
+	contents, err = hackpadfs.ReadFile(memFoo, "baz/biff/boo")
+	assert.NoError(t, err)
+	assert.Equal(t, "boo", string(contents))
+	info, err := hackpadfs.Stat(memFoo, "baz/biff/boo")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0640), info.Mode())
+	assert.Equal(t, modTime, info.ModTime())
+	info, err = hackpadfs.Stat(memFoo, "baz/biff")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.ModeDir|0750, info.Mode())
+	assert.Equal(t, modTime, info.ModTime())
+	_, err = hackpadfs.Stat(memRoot, "baz")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	assert.NoError(t, hackpadfs.Mkdir(fs, "foo/existing", 0700))
+	assert.NoError(t, hackpadfs.Mkdir(fs, "existing", 0700))
+	err = hackpadfs.Rename(fs, "existing", "foo/existing")
+	assert.ErrorIs(t, hackpadfs.ErrExist, err)
+}
+
+func TestRenameAcrossMountsCleansUp(t *testing.T) {
+	t.Parallel()
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := mount.NewFS(memRoot)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+	memFoo, err := mem.NewFSWithOptions(mem.Options{MaxDepth: 2})
+	assert.NoError(t, err)
+	assert.NoError(t, fs.AddMount("foo", memFoo))
+
+	assert.NoError(t, hackpadfs.MkdirAll(fs, "bar/baz", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "bar/baz/biff", []byte("biff"), 0600)) // too deep for memFoo
+	err = hackpadfs.Rename(fs, "bar", "foo/bar")
+	assert.ErrorIs(t, hackpadfs.ErrNameTooLong, err)
+	_, err = hackpadfs.Stat(memFoo, "bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	contents, err := hackpadfs.ReadFile(memRoot, "bar/baz/biff")
+	assert.NoError(t, err)
+	assert.Equal(t, "biff", string(contents))
+}
+
+// failWriteFS fails all writes to opened files
+type failWriteFS struct {
+	*mem.FS
+}
+
+func (fs *failWriteFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	file, err := fs.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &failWriteFile{File: file}, nil
+}
+
+type failWriteFile struct {
+	hackpadfs.File
+}
+
+func (f *failWriteFile) Write(p []byte) (int, error) {
+	return 0, hackpadfs.ErrPermission
+}
+
+func TestRenameAcrossMountsKeepsDestinationOnFailure(t *testing.T) {
+	t.Parallel()
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := mount.NewFS(memRoot)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+	memFoo, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(memFoo, "bar", []byte("old bar"), 0600))
+	assert.NoError(t, fs.AddMount("foo", &failWriteFS{FS: memFoo}))
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "bar", []byte("new bar"), 0600))
+	err = hackpadfs.Rename(fs, "bar", "foo/bar")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	contents, err := hackpadfs.ReadFile(memFoo, "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "old bar", string(contents))
+	contents, err = hackpadfs.ReadFile(memRoot, "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "new bar", string(contents))
+	entries, err := hackpadfs.ReadDir(memFoo, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestNoCrossMountRename(t *testing.T) {
+	t.Parallel()
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := mount.NewFSWithOptions(memRoot, mount.Options{NoCrossMountRename: true})
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+	memFoo, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, fs.AddMount("foo", memFoo))
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "bar", []byte("bar"), 0600))
+	err = hackpadfs.Rename(fs, "bar", "foo/bar")
+	assert.ErrorIs(t, hackpadfs.ErrCrossDevice, err)
+	var errContext *hackpadfs.ErrorContext
+	if assert.ErrorAs(t, &errContext, err) {
+		assert.Equal(t, "foo", errContext.MountPoint)
+	}
+	_, err = hackpadfs.Stat(memRoot, "bar")
+	assert.NoError(t, err)
+}
+
+// countingFS counts calls to Stat()
+type countingFS struct {
+	*mem.FS
+	mu    sync.Mutex
+	stats int
+}
+
+func (fs *countingFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	fs.mu.Lock()
+	fs.stats++
+	fs.mu.Unlock()
+	return fs.FS.Stat(name)
+}
+
+func (fs *countingFS) Stats() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.stats
+}
+
+func TestStatCache(t *testing.T) {
+	t.Parallel()
+	newFS := func(t *testing.T, ttl time.Duration) (*mount.FS, *countingFS) {
+		t.Helper()
+		memRoot, err := mem.NewFS()
+		assert.NoError(t, err)
+		root := &countingFS{FS: memRoot}
+		fs, err := mount.NewFSWithOptions(root, mount.Options{StatCacheTTL: ttl})
+		assert.NoError(t, err)
+		assert.NoError(t, hackpadfs.Mkdir(root, "foo", 0700))
+		return fs, root
+	}
+
+	t.Run("hit", func(t *testing.T) {
+		t.Parallel()
+		fs, root := newFS(t, time.Minute)
+		for i := 0; i < 3; i++ {
+			info, err := hackpadfs.Stat(fs, "foo")
+			assert.NoError(t, err)
+			assert.Equal(t, true, info.IsDir())
+		}
+		assert.Equal(t, 1, root.Stats())
+		assert.Equal(t, mount.StatCacheStats{Hits: 2, Misses: 1}, fs.StatCacheStats())
+	})
+
+	t.Run("errors not cached", func(t *testing.T) {
+		t.Parallel()
+		fs, root := newFS(t, time.Minute)
+		_, err := hackpadfs.Stat(fs, "bar")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		var pathErr *hackpadfs.PathError
+		if assert.ErrorAs(t, &pathErr, err) {
+			assert.Equal(t, "bar", pathErr.Path)
+		}
+		assert.NoError(t, hackpadfs.Mkdir(fs, "bar", 0700))
+		_, err = hackpadfs.Stat(fs, "bar")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, root.Stats())
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		t.Parallel()
+		fs, root := newFS(t, 10*time.Millisecond)
+		_, err := hackpadfs.Stat(fs, "foo")
+		assert.NoError(t, err)
+		time.Sleep(20 * time.Millisecond)
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, root.Stats())
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		t.Parallel()
+		fs, root := newFS(t, time.Minute)
+		assert.NoError(t, hackpadfs.WriteFullFile(root, "foo/bar", nil, 0600))
+		_, err := hackpadfs.Stat(fs, "foo/bar")
+		assert.NoError(t, err)
+		assert.NoError(t, hackpadfs.Remove(fs, "foo/bar"))
+		_, err = hackpadfs.Stat(fs, "foo/bar")
+		assert.NoError(t, err) // stale
+
+		fs.InvalidateStat("foo")
+		_, err = hackpadfs.Stat(fs, "foo/bar")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+
+	t.Run("add mount and rename invalidate", func(t *testing.T) {
+		t.Parallel()
+		fs, root := newFS(t, time.Minute)
+		assert.NoError(t, hackpadfs.WriteFullFile(root, "baz", nil, 0600))
+		info, err := hackpadfs.Stat(fs, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, hackpadfs.FileMode(0700), info.Mode().Perm())
+		_, err = hackpadfs.Stat(fs, "baz")
+		assert.NoError(t, err)
+
+		memFoo, err := mem.NewFS()
+		assert.NoError(t, err)
+		assert.NoError(t, fs.AddMount("foo", memFoo))
+		info, err = hackpadfs.Stat(fs, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, hackpadfs.FileMode(0666), info.Mode().Perm()) // memFoo's root directory
+
+		assert.NoError(t, hackpadfs.Rename(fs, "baz", "biff"))
+		_, err = hackpadfs.Stat(fs, "baz")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+		fs, root := newFS(t, 0)
+		for i := 0; i < 3; i++ {
+			_, err := hackpadfs.Stat(fs, "foo")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 3, root.Stats())
+		assert.Equal(t, mount.StatCacheStats{}, fs.StatCacheStats())
+	})
+}
+
+func TestAddLazyMount(t *testing.T) {
+	t.Parallel()
+	newFS := func(t *testing.T, options mount.Options) *mount.FS {
+		t.Helper()
+		memRoot, err := mem.NewFS()
+		assert.NoError(t, err)
+		assert.NoError(t, memRoot.Mkdir("foo", 0700))
+		fs, err := mount.NewFSWithOptions(memRoot, options)
+		assert.NoError(t, err)
+		return fs
+	}
+
+	t.Run("init on first access", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{})
+		inits := 0
+		assert.NoError(t, fs.AddLazyMount("foo", func() (hackpadfs.FS, error) {
+			inits++
+			return mem.NewFS()
+		}))
+		assert.Equal(t, 0, inits)
+		assert.Equal(t, []mount.Point{{Path: "foo"}}, fs.MountPoints())
+		_, err := hackpadfs.Stat(fs, "bar")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		assert.Equal(t, 0, inits) // outside the mount
+
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("bar"), 0600))
+		contents, err := hackpadfs.ReadFile(fs, "foo/bar")
+		assert.NoError(t, err)
+		assert.Equal(t, "bar", string(contents))
+		assert.Equal(t, 1, inits)
+	})
+
+	t.Run("mount point must exist", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{})
+		err := fs.AddLazyMount("bar", func() (hackpadfs.FS, error) {
+			t.Error("Should not be called")
+			return nil, errors.New("unreachable")
+		})
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		assert.Equal(t, 0, len(fs.MountPoints()))
+	})
+
+	t.Run("init error", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{})
+		initErr := errors.New("some error")
+		inits := 0
+		assert.NoError(t, fs.AddLazyMount("foo", func() (hackpadfs.FS, error) {
+			inits++
+			if inits == 1 {
+				return nil, initErr
+			}
+			return mem.NewFS()
+		}))
+
+		err := hackpadfs.Mkdir(fs, "foo/bar", 0700)
+		assert.ErrorIs(t, initErr, err)
+		var pathErr *hackpadfs.PathError
+		if assert.ErrorAs(t, &pathErr, err) {
+			assert.Equal(t, "mkdir", pathErr.Op)
+		}
+		var errContext *hackpadfs.ErrorContext
+		if assert.ErrorAs(t, &errContext, err) {
+			assert.Equal(t, "foo", errContext.MountPoint)
+		}
+
+		assert.NoError(t, hackpadfs.Mkdir(fs, "foo/bar", 0700)) // retried
+		assert.Equal(t, 2, inits)
+	})
+
+	t.Run("retry delay", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{LazyMountRetryDelay: time.Minute})
+		initErr := errors.New("some error")
+		inits := 0
+		assert.NoError(t, fs.AddLazyMount("foo", func() (hackpadfs.FS, error) {
+			inits++
+			return nil, initErr
+		}))
+		_, err := hackpadfs.Stat(fs, "foo")
+		assert.ErrorIs(t, initErr, err)
+		_, err = hackpadfs.ReadDir(fs, "foo")
+		assert.ErrorIs(t, initErr, err)
+		assert.Equal(t, 1, inits)
+	})
+
+	t.Run("concurrent access inits once", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{})
+		var mu sync.Mutex
+		inits := 0
+		assert.NoError(t, fs.AddLazyMount("foo", func() (hackpadfs.FS, error) {
+			mu.Lock()
+			inits++
+			mu.Unlock()
+			return mem.NewFS()
+		}))
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := hackpadfs.Stat(fs, "foo")
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, inits)
+	})
+}
+
+func TestNewFSFromTable(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	fs, err := mount.NewFSFromTable(map[string]string{
+		".":            "mem:",
+		"tmp":          "mem:",
+		"var/data":     "os:" + dir,
+		"var/data/mem": "mem:",
+	}, mount.Options{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	points := fs.MountPoints()
+	sort.Slice(points, func(a, b int) bool {
+		return points[a].Path < points[b].Path
+	})
+	assert.Equal(t, []mount.Point{{Path: "tmp"}, {Path: "var/data"}, {Path: "var/data/mem"}}, points)
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "var/data/foo", []byte("foo"), 0600))
+	contents, err := os.ReadFile(filepath.Join(dir, "foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(contents))
+	_, err = os.Stat(filepath.Join(dir, "mem")) // mount point created
+	assert.NoError(t, err)
+
+	tmpFS, subPath := fs.Mount("tmp/bar")
+	assert.Equal(t, "bar", subPath)
+	rootFS, _ := fs.Mount("bar")
+	assert.Equal(t, false, tmpFS == rootFS) // each URL creates a new FS
+}
+
+func TestNewFSFromTableErrors(t *testing.T) {
+	t.Parallel()
+	_, err := mount.NewFSFromTable(map[string]string{"tmp": "mem:"}, mount.Options{})
+	assert.Error(t, err)
+	_, err = mount.NewFSFromTable(map[string]string{".": "unknown:foo"}, mount.Options{})
+	assert.Error(t, err)
+	_, err = mount.NewFSFromTable(map[string]string{".": "mem:", "/tmp": "mem:"}, mount.Options{})
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	_, err = mount.NewFSFromTable(map[string]string{".": "mem:", "tmp": "os:" + filepath.Join(t.TempDir(), "missing")}, mount.Options{})
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+// closerFS records the order file systems are closed in
+type closerFS struct {
+	*mem.FS
+	name   string
+	closed *[]string
+	err    error
+}
+
+func (fs *closerFS) Close() error {
+	*fs.closed = append(*fs.closed, fs.name)
+	return fs.err
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+	var closed []string
+	newCloser := func(name string, err error) *closerFS {
+		memFS, err2 := mem.NewFS()
+		assert.NoError(t, err2)
+		return &closerFS{FS: memFS, name: name, closed: &closed, err: err}
+	}
+	root := newCloser("root", nil)
+	assert.NoError(t, root.MkdirAll("a/b", 0700))
+	assert.NoError(t, root.Mkdir("c", 0700))
+	assert.NoError(t, root.Mkdir("lazy", 0700))
+	fs, err := mount.NewFS(root)
+	assert.NoError(t, err)
+
+	a := newCloser("a", nil)
+	assert.NoError(t, a.Mkdir("b", 0700))
+	assert.NoError(t, fs.AddMount("a", a))
+	someErr := errors.New("some error")
+	assert.NoError(t, fs.AddMount("a/b", newCloser("a/b", someErr)))
+	assert.NoError(t, fs.AddMount("c", newCloser("c", nil)))
+	assert.NoError(t, fs.AddLazyMount("lazy", func() (hackpadfs.FS, error) {
+		t.Error("Should not initialize a lazy mount to close it")
+		return nil, errors.New("unreachable")
+	}))
+
+	err = hackpadfs.Close(fs)
+	assert.ErrorIs(t, someErr, err)
+	var pathErr *hackpadfs.PathError
+	if assert.ErrorAs(t, &pathErr, err) {
+		assert.Equal(t, "a/b", pathErr.Path)
+	}
+	assert.Equal(t, []string{"a/b", "a", "c", "root"}, closed)
+}
+
+type pingFS struct {
+	*mem.FS
+	err error
+}
+
+func (fs *pingFS) Ping(ctx context.Context) error {
+	return fs.err
+}
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	root, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, root.Mkdir("healthy", 0700))
+	assert.NoError(t, root.Mkdir("broken", 0700))
+	assert.NoError(t, root.Mkdir("lazy", 0700))
+	fs, err := mount.NewFS(root)
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Ping(ctx))
+
+	healthy, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, fs.AddMount("healthy", &pingFS{FS: healthy}))
+	assert.NoError(t, fs.AddLazyMount("lazy", func() (hackpadfs.FS, error) {
+		t.Error("Should not initialize a lazy mount to ping it")
+		return nil, errors.New("unreachable")
+	}))
+	assert.NoError(t, fs.Ping(ctx))
+
+	broken, err := mem.NewFS()
+	assert.NoError(t, err)
+	someErr := errors.New("some error")
+	assert.NoError(t, fs.AddMount("broken", &pingFS{FS: broken, err: someErr}))
+	err = hackpadfs.Ping(ctx, fs)
+	assert.ErrorIs(t, someErr, err)
+	var errContext *hackpadfs.ErrorContext
+	if assert.ErrorAs(t, &errContext, err) {
+		assert.Equal(t, "broken", errContext.MountPoint)
+	}
+}
@@ -0,0 +1,166 @@
+package mount
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// AddLazyMount mounts the file system returned by 'init' at 'path', calling 'init' the first time a path inside the mount is accessed. The mount point must already exist as a directory.
+//
+// Use lazy mounts for file systems which are expensive to create, like those dialing a remote store, so they're only created if they're used.
+// If 'init' fails, operations inside the mount fail with its error until it's retried. See Options.LazyMountRetryDelay.
+func (fs *FS) AddLazyMount(path string, init func() (hackpadfs.FS, error)) error {
+	err := fs.addMount(path, &lazyMount{
+		path:       path,
+		init:       init,
+		retryDelay: fs.options.LazyMountRetryDelay,
+	})
+	if err != nil {
+		return &hackpadfs.PathError{Op: "mount", Path: path, Err: err}
+	}
+	return nil
+}
+
+// lazyMount creates its file system on first use
+type lazyMount struct {
+	path       string
+	retryDelay time.Duration
+
+	mu       sync.Mutex
+	init     func() (hackpadfs.FS, error)
+	fs       hackpadfs.FS
+	err      error
+	failedAt time.Time
+}
+
+// get returns the mounted file system, calling init if it hasn't succeeded yet. If init fails, returns a file system which fails every operation.
+func (m *lazyMount) get() hackpadfs.FS {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fs != nil {
+		return m.fs
+	}
+	if m.err != nil && time.Since(m.failedAt) < m.retryDelay {
+		return &errFS{mountPoint: m.path, err: m.err}
+	}
+	fs, err := m.init()
+	if err != nil {
+		m.err, m.failedAt = err, time.Now()
+		return &errFS{mountPoint: m.path, err: err}
+	}
+	m.fs, m.init, m.err = fs, nil, nil
+	return fs
+}
+
+// initialized returns the mounted file system if init has succeeded, without calling init
+func (m *lazyMount) initialized() (hackpadfs.FS, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.fs, m.fs != nil
+}
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.ReadlinkFS
+	} = &errFS{}
+)
+
+// errFS fails every operation with a lazy mount's initialization error
+type errFS struct {
+	mountPoint string
+	err        error
+}
+
+func (fs *errFS) pathErr(op, name string) error {
+	return hackpadfs.WithErrorContext(
+		&hackpadfs.PathError{Op: op, Path: name, Err: fs.err},
+		hackpadfs.ErrorContext{MountPoint: fs.mountPoint},
+	)
+}
+
+func (fs *errFS) Open(name string) (hackpadfs.File, error) {
+	return nil, fs.pathErr("open", name)
+}
+
+func (fs *errFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	return nil, fs.pathErr("open", name)
+}
+
+func (fs *errFS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return fs.pathErr("mkdir", name)
+}
+
+func (fs *errFS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return fs.pathErr("mkdir", path)
+}
+
+func (fs *errFS) Remove(name string) error {
+	return fs.pathErr("remove", name)
+}
+
+func (fs *errFS) RemoveAll(name string) error {
+	return fs.pathErr("remove", name)
+}
+
+func (fs *errFS) Rename(oldname, newname string) error {
+	return hackpadfs.WithErrorContext(
+		&hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.err},
+		hackpadfs.ErrorContext{MountPoint: fs.mountPoint},
+	)
+}
+
+func (fs *errFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return nil, fs.pathErr("stat", name)
+}
+
+func (fs *errFS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return nil, fs.pathErr("lstat", name)
+}
+
+func (fs *errFS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return fs.pathErr("chmod", name)
+}
+
+func (fs *errFS) Chown(name string, uid, gid int) error {
+	return fs.pathErr("chown", name)
+}
+
+func (fs *errFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.pathErr("chtimes", name)
+}
+
+func (fs *errFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return nil, fs.pathErr("readdir", name)
+}
+
+func (fs *errFS) ReadFile(name string) ([]byte, error) {
+	return nil, fs.pathErr("open", name)
+}
+
+func (fs *errFS) Symlink(oldname, newname string) error {
+	return hackpadfs.WithErrorContext(
+		&hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.err},
+		hackpadfs.ErrorContext{MountPoint: fs.mountPoint},
+	)
+}
+
+func (fs *errFS) Readlink(name string) (string, error) {
+	return "", fs.pathErr("readlink", name)
+}
@@ -0,0 +1,137 @@
+package mount
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// StatCacheStats contains usage metrics for an FS's Stat cache
+type StatCacheStats struct {
+	Hits   uint64 // Hits is the number of Stat() calls answered from the cache
+	Misses uint64 // Misses is the number of Stat() calls passed through to a mounted file system
+}
+
+// statCache holds recent successful Stat() results for Options.StatCacheTTL
+type statCache struct {
+	ttl time.Duration
+
+	mu         sync.Mutex
+	generation uint64 // generation increments on every invalidation, so in-flight Stats started before it don't store stale results
+	entries    map[string]statEntry
+	stats      StatCacheStats
+}
+
+type statEntry struct {
+	info    hackpadfs.FileInfo
+	expires time.Time
+}
+
+func newStatCache(ttl time.Duration) *statCache {
+	return &statCache{
+		ttl:     ttl,
+		entries: make(map[string]statEntry),
+	}
+}
+
+// get returns the cached info for 'name' if it hasn't expired. Otherwise, returns the current generation to pass to put().
+func (c *statCache) get(name string) (_ hackpadfs.FileInfo, generation uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[name]
+	if ok && time.Now().Before(entry.expires) {
+		c.stats.Hits++
+		return entry.info, c.generation, true
+	}
+	if ok {
+		delete(c.entries, name)
+	}
+	c.stats.Misses++
+	return nil, c.generation, false
+}
+
+// put caches 'info' for 'name', unless the cache was invalidated since 'generation' was returned by get()
+func (c *statCache) put(name string, info hackpadfs.FileInfo, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	c.entries[name] = statEntry{info: info, expires: time.Now().Add(c.ttl)}
+}
+
+// invalidate drops cached info for 'name' and everything inside it
+func (c *statCache) invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if name == "." {
+		c.entries = make(map[string]statEntry)
+		return
+	}
+	prefix := name + "/"
+	for entryName := range c.entries {
+		if entryName == name || strings.HasPrefix(entryName, prefix) {
+			delete(c.entries, entryName)
+		}
+	}
+}
+
+func (c *statCache) Stats() StatCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Stat implements hackpadfs.StatFS
+//
+// If Options.StatCacheTTL is set, successful results are cached and reused until they expire or InvalidateStat() is called.
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if fs.statCache == nil {
+		return fs.stat(name)
+	}
+	info, generation, ok := fs.statCache.get(name)
+	if ok {
+		return info, nil
+	}
+	info, err := fs.stat(name)
+	if err == nil {
+		fs.statCache.put(name, info, generation)
+	}
+	return info, err
+}
+
+func (fs *FS) stat(name string) (hackpadfs.FileInfo, error) {
+	mountFS, mountPath, subPath := fs.mountPoint(name)
+	if mountPath == "." {
+		subPath = name
+	}
+	info, err := hackpadfs.Stat(mountFS, subPath)
+	if err != nil {
+		return nil, errWithPath(err, name)
+	}
+	if isMountRoot(mountPath, subPath) {
+		info = mountRootInfo{FileInfo: info, name: path.Base(name)}
+	}
+	return info, nil
+}
+
+// InvalidateStat drops cached Stat() results for 'name' and everything inside it. Use "." to drop all results.
+//
+// Changes made through this FS's AddMount() and Rename() invalidate automatically. Call InvalidateStat() after other changes, like those made with hackpadfs.Mkdir(fs, ...), need to be seen before the cached results expire.
+func (fs *FS) InvalidateStat(name string) {
+	if fs.statCache != nil {
+		fs.statCache.invalidate(name)
+	}
+}
+
+// StatCacheStats returns the Stat cache's hit and miss counts. Always zero if Options.StatCacheTTL is not set.
+func (fs *FS) StatCacheStats() StatCacheStats {
+	if fs.statCache == nil {
+		return StatCacheStats{}
+	}
+	return fs.statCache.Stats()
+}
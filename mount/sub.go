@@ -0,0 +1,100 @@
+package mount
+
+import (
+	"path"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.MountFS
+		hackpadfs.OpenFileFS
+		hackpadfs.ReadDirFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.SubFS
+	} = &subFS{}
+)
+
+// subFS is a view of the directory 'dir' in an FS, including any file systems mounted inside it
+type subFS struct {
+	fs  *FS
+	dir string
+}
+
+// Sub implements hackpadfs.SubFS
+//
+// The returned view includes the file systems mounted inside 'dir', and mounts added later. Renames in the view between mounts behave like Rename().
+func (fs *FS) Sub(dir string) (hackpadfs.FS, error) {
+	if !hackpadfs.ValidPath(dir) {
+		return nil, &hackpadfs.PathError{Op: "sub", Path: dir, Err: hackpadfs.ErrInvalid}
+	}
+	if dir == "." {
+		return fs, nil
+	}
+	return &subFS{fs: fs, dir: dir}, nil
+}
+
+// fullPath returns the path of 'name' in the parent FS
+func (fs *subFS) fullPath(name string) string {
+	return path.Join(fs.dir, name)
+}
+
+func (fs *subFS) Open(name string) (hackpadfs.File, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	file, err := fs.fs.Open(fs.fullPath(name))
+	return file, errWithPath(err, name)
+}
+
+func (fs *subFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	file, err := fs.fs.OpenFile(fs.fullPath(name), flag, perm)
+	return file, errWithPath(err, name)
+}
+
+func (fs *subFS) Mount(name string) (mount hackpadfs.FS, subPath string) {
+	if !hackpadfs.ValidPath(name) {
+		return fs.fs.rootFS, name // fails in the root file system's own path validation
+	}
+	return fs.fs.Mount(fs.fullPath(name))
+}
+
+func (fs *subFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	entries, err := fs.fs.ReadDir(fs.fullPath(name))
+	return entries, errWithPath(err, name)
+}
+
+func (fs *subFS) Rename(oldname, newname string) error {
+	if !hackpadfs.ValidPath(oldname) || !hackpadfs.ValidPath(newname) {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrInvalid}
+	}
+	err := fs.fs.Rename(fs.fullPath(oldname), fs.fullPath(newname))
+	if linkErr, ok := err.(*hackpadfs.LinkError); ok {
+		return &hackpadfs.LinkError{Op: linkErr.Op, Old: oldname, New: newname, Err: linkErr.Err}
+	}
+	return err
+}
+
+func (fs *subFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.fs.Stat(fs.fullPath(name))
+	return info, errWithPath(err, name)
+}
+
+func (fs *subFS) Sub(dir string) (hackpadfs.FS, error) {
+	if !hackpadfs.ValidPath(dir) {
+		return nil, &hackpadfs.PathError{Op: "sub", Path: dir, Err: hackpadfs.ErrInvalid}
+	}
+	return fs.fs.Sub(fs.fullPath(dir))
+}
@@ -0,0 +1,57 @@
+package mount
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// NewFSFromTable returns a new FS built from a mount table, mapping mount paths to backend URLs. e.g.
+//
+//	mount.NewFSFromTable(map[string]string{
+//		".":        "mem:",
+//		"var/data": "os:/var/data",
+//	}, mount.Options{})
+//
+// The table must contain the root path ".". Each URL creates a file system with hackpadfs.New(), so import the packages registering the schemes you need.
+// Missing mount points are created, with parent mounts created before their children.
+func NewFSFromTable(table map[string]string, options Options) (*FS, error) {
+	const op = "mount"
+	rootURL, ok := table["."]
+	if !ok {
+		return nil, &hackpadfs.PathError{Op: op, Path: ".", Err: errors.New("mount table requires a root \".\" entry")}
+	}
+	rootFS, err := hackpadfs.New(rootURL)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: op, Path: ".", Err: err}
+	}
+	fs, err := NewFSWithOptions(rootFS, options)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(table))
+	for p := range table {
+		if p != "." {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths) // parents sort before their children
+	for _, p := range paths {
+		if !hackpadfs.ValidPath(p) {
+			return nil, &hackpadfs.PathError{Op: op, Path: p, Err: hackpadfs.ErrInvalid}
+		}
+		mountFS, err := hackpadfs.New(table[p])
+		if err != nil {
+			return nil, &hackpadfs.PathError{Op: op, Path: p, Err: err}
+		}
+		if err := hackpadfs.MkdirAll(fs, p, 0755); err != nil {
+			return nil, err
+		}
+		if err := fs.AddMount(p, mountFS); err != nil {
+			return nil, err
+		}
+	}
+	return fs, nil
+}
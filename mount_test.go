@@ -3,6 +3,7 @@ package hackpadfs
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/hack-pad/hackpadfs/internal/assert"
@@ -78,3 +79,56 @@ func TestStripErrPathPrefix(t *testing.T) {
 		})
 	}
 }
+
+// linkFS records symlinks without creating files
+type linkFS struct {
+	links map[string]string
+}
+
+func (fs *linkFS) Open(name string) (File, error) {
+	return nil, &PathError{Op: "open", Path: name, Err: ErrNotExist}
+}
+
+func (fs *linkFS) Symlink(oldname, newname string) error {
+	fs.links[newname] = oldname
+	return nil
+}
+
+func (fs *linkFS) Readlink(name string) (string, error) {
+	return fs.links[name], nil
+}
+
+// subMountFS mounts 'fs' at "mnt", over an empty root
+type subMountFS struct {
+	fs *linkFS
+}
+
+func (fs *subMountFS) Open(name string) (File, error) {
+	return nil, &PathError{Op: "open", Path: name, Err: ErrNotExist}
+}
+
+func (fs *subMountFS) Mount(name string) (FS, string) {
+	if name == "mnt" {
+		return fs.fs, "."
+	}
+	return fs.fs, strings.TrimPrefix(name, "mnt/")
+}
+
+func TestMountSymlinkTargets(t *testing.T) {
+	t.Parallel()
+	mounted := &linkFS{links: make(map[string]string)}
+	fs := &subMountFS{fs: mounted}
+	assert.NoError(t, Symlink(fs, "mnt/dir/file", "mnt/dir/link"))
+	assert.NoError(t, Symlink(fs, "mnt", "mnt/root"))
+	assert.Equal(t, map[string]string{"dir/link": "dir/file", "root": "."}, mounted.links)
+
+	target, err := Readlink(fs, "mnt/dir/link")
+	assert.NoError(t, err)
+	assert.Equal(t, "mnt/dir/file", target)
+	target, err = Readlink(fs, "mnt/root")
+	assert.NoError(t, err)
+	assert.Equal(t, "mnt", target)
+
+	err = Symlink(fs, "outside", "mnt/link")
+	assert.ErrorIs(t, ErrInvalid, err)
+}
@@ -0,0 +1,83 @@
+package hackpadfs
+
+import "io"
+
+// FileOffset tracks an open file's read/write position and its directory entry cursor, for use in File implementations.
+//
+// The byte position and directory cursor are independent, so reading directory entries does not disturb Read() or Seek() positions.
+// The zero value is positioned at the start of a file opened without FlagAppend. A FileOffset is not safe for concurrent use.
+type FileOffset struct {
+	position  int64
+	dirCursor int
+	append    bool
+}
+
+// NewFileOffset returns a FileOffset at the start of the file. If FlagAppend is set in 'flag', writes always occur at the end of the file.
+func NewFileOffset(flag int) FileOffset {
+	return FileOffset{append: flag&FlagAppend != 0}
+}
+
+// Position returns the current byte position, used by the next Read() or Write()
+func (o *FileOffset) Position() int64 {
+	return o.position
+}
+
+// Read advances the byte position after reading 'n' bytes
+func (o *FileOffset) Read(n int) {
+	o.position += int64(n)
+}
+
+// WritePosition returns where the next Write() should begin, given the file's current 'size'
+func (o *FileOffset) WritePosition(size int64) int64 {
+	if o.append {
+		return size
+	}
+	return o.position
+}
+
+// Wrote sets the byte position after writing 'n' bytes starting at 'off'. 'off' should come from WritePosition().
+func (o *FileOffset) Wrote(off int64, n int) {
+	o.position = off + int64(n)
+}
+
+// SeekSize implements io.Seeker's position math for a file of length 'size'. Returns ErrInvalid for an unknown 'whence' or a negative result.
+//
+// Seeking to the start of the file also rewinds the directory cursor.
+func (o *FileOffset) SeekSize(offset int64, whence int, size int64) (int64, error) {
+	newPosition := o.position
+	switch whence {
+	case io.SeekStart:
+		newPosition = offset
+	case io.SeekCurrent:
+		newPosition += offset
+	case io.SeekEnd:
+		newPosition = size + offset
+	default:
+		return 0, ErrInvalid
+	}
+	if newPosition < 0 {
+		return 0, ErrInvalid
+	}
+	o.position = newPosition
+	if newPosition == 0 {
+		o.dirCursor = 0
+	}
+	return newPosition, nil
+}
+
+// NextDirEntries advances the directory cursor by up to 'n' entries in a directory of 'total' entries, returning the range of entries to return from ReadDir(n).
+// If n <= 0, returns all remaining entries.
+//
+// Per io/fs.ReadDirFile, ReadDir(n) with n > 0 should return io.EOF when start == end.
+func (o *FileOffset) NextDirEntries(total, n int) (start, end int) {
+	start = o.dirCursor
+	if start > total {
+		start = total
+	}
+	end = total
+	if n > 0 && start+n < total {
+		end = start + n
+	}
+	o.dirCursor = end
+	return start, end
+}
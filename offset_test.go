@@ -0,0 +1,78 @@
+package hackpadfs
+
+import (
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestFileOffsetSeek(t *testing.T) {
+	t.Parallel()
+	const size = 10
+	for _, tc := range []struct {
+		description string
+		start       int64
+		offset      int64
+		whence      int
+		expect      int64
+		expectErr   error
+	}{
+		{description: "start", start: 5, offset: 2, whence: io.SeekStart, expect: 2},
+		{description: "current", start: 5, offset: 2, whence: io.SeekCurrent, expect: 7},
+		{description: "end", start: 5, offset: -2, whence: io.SeekEnd, expect: 8},
+		{description: "past end", start: 5, offset: 2, whence: io.SeekEnd, expect: 12},
+		{description: "negative", start: 5, offset: -6, whence: io.SeekCurrent, expect: 5, expectErr: ErrInvalid},
+		{description: "bad whence", start: 5, offset: 0, whence: 3, expect: 5, expectErr: ErrInvalid},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			offset := NewFileOffset(FlagReadOnly)
+			offset.Read(int(tc.start))
+			_, err := offset.SeekSize(tc.offset, tc.whence, size)
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, tc.expectErr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expect, offset.Position())
+		})
+	}
+}
+
+func TestFileOffsetWrite(t *testing.T) {
+	t.Parallel()
+	offset := NewFileOffset(FlagWriteOnly)
+	off := offset.WritePosition(10)
+	assert.Equal(t, int64(0), off)
+	offset.Wrote(off, 4)
+	assert.Equal(t, int64(4), offset.Position())
+
+	appendOffset := NewFileOffset(FlagWriteOnly | FlagAppend)
+	off = appendOffset.WritePosition(10)
+	assert.Equal(t, int64(10), off)
+	appendOffset.Wrote(off, 4)
+	assert.Equal(t, int64(14), appendOffset.Position())
+}
+
+func TestFileOffsetDirEntries(t *testing.T) {
+	t.Parallel()
+	offset := NewFileOffset(FlagReadOnly)
+	offset.Read(100) // byte position does not affect the dir cursor
+
+	start, end := offset.NextDirEntries(3, 2)
+	assert.Equal(t, [2]int{0, 2}, [2]int{start, end})
+	start, end = offset.NextDirEntries(3, 2)
+	assert.Equal(t, [2]int{2, 3}, [2]int{start, end})
+	start, end = offset.NextDirEntries(3, 2)
+	assert.Equal(t, [2]int{3, 3}, [2]int{start, end})
+	assert.Equal(t, int64(100), offset.Position())
+
+	_, err := offset.SeekSize(0, io.SeekStart, 0)
+	assert.NoError(t, err)
+	start, end = offset.NextDirEntries(3, 0)
+	assert.Equal(t, [2]int{0, 3}, [2]int{start, end})
+	start, end = offset.NextDirEntries(3, 0)
+	assert.Equal(t, [2]int{3, 3}, [2]int{start, end})
+}
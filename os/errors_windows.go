@@ -36,14 +36,26 @@ func (fs *FS) mapNonStandardError(err error) error {
 	}
 	// Values from https://docs.microsoft.com/en-us/windows/win32/debug/system-error-codes--0-499-
 	const (
-		ERROR_NEGATIVE_SEEK = syscall.Errno(0x83)
-		ERROR_DIR_NOT_EMPTY = syscall.Errno(0x91)
+		ERROR_NOT_SAME_DEVICE      = syscall.Errno(0x11)
+		ERROR_WRITE_PROTECT        = syscall.Errno(0x13)
+		ERROR_DISK_FULL            = syscall.Errno(0x70)
+		ERROR_NEGATIVE_SEEK        = syscall.Errno(0x83)
+		ERROR_DIR_NOT_EMPTY        = syscall.Errno(0x91)
+		ERROR_FILENAME_EXCED_RANGE = syscall.Errno(0xCE)
 	)
 	switch errno {
 	case ERROR_NEGATIVE_SEEK:
 		return &mappedErr{hackpadfs.ErrInvalid, errno}
 	case ERROR_DIR_NOT_EMPTY:
 		return &mappedErr{hackpadfs.ErrNotEmpty, errno}
+	case ERROR_NOT_SAME_DEVICE:
+		return &mappedErr{hackpadfs.ErrCrossDevice, errno}
+	case ERROR_WRITE_PROTECT:
+		return &mappedErr{hackpadfs.ErrReadOnly, errno}
+	case ERROR_DISK_FULL:
+		return &mappedErr{hackpadfs.ErrNoSpace, errno}
+	case ERROR_FILENAME_EXCED_RANGE:
+		return &mappedErr{hackpadfs.ErrNameTooLong, errno}
 	default:
 		return err
 	}
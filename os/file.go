@@ -3,6 +3,7 @@ package os
 import (
 	"io"
 	"os"
+	"syscall"
 	"time"
 
 	"github.com/hack-pad/hackpadfs"
@@ -27,6 +28,11 @@ func (f *file) Chown(uid, gid int) error {
 	return f.fs.wrapErr(f.osFile.Chown(uid, gid))
 }
 
+// Fd implements hackpadfs.FdFile
+func (f *file) Fd() uintptr {
+	return f.osFile.Fd()
+}
+
 func (f *file) Close() error {
 	return f.fs.wrapErr(f.osFile.Close())
 }
@@ -51,7 +57,25 @@ func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
 	return entries, f.fs.wrapErr(err)
 }
 
+// ReadDirNames implements hackpadfs.DirNameReaderFile
+func (f *file) ReadDirNames(n int) ([]string, error) {
+	names, err := f.osFile.Readdirnames(n)
+	return names, f.fs.wrapErr(err)
+}
+
+// ReadFrom implements io.ReaderFrom
+// If 'r' is another os.FS file, the os package can copy without reading into userspace buffers, e.g. with copy_file_range or sendfile on Linux.
 func (f *file) ReadFrom(r io.Reader) (n int64, err error) {
+	switch src := r.(type) {
+	case *file:
+		r = src.osFile
+	case *io.LimitedReader:
+		if srcFile, ok := src.R.(*file); ok {
+			limited := &io.LimitedReader{R: srcFile.osFile, N: src.N}
+			defer func() { src.N = limited.N }()
+			r = limited
+		}
+	}
 	n, err = f.osFile.ReadFrom(r)
 	return n, f.fs.wrapErr(err)
 }
@@ -80,6 +104,12 @@ func (f *file) Stat() (hackpadfs.FileInfo, error) {
 	return info, f.fs.wrapErr(err)
 }
 
+// SyscallConn implements hackpadfs.SyscallConnFile
+func (f *file) SyscallConn() (syscall.RawConn, error) {
+	conn, err := f.osFile.SyscallConn()
+	return conn, f.fs.wrapErr(err)
+}
+
 // Sync implements hackpadfs.SycnerFile
 func (f *file) Sync() error {
 	return f.fs.wrapErr(f.osFile.Sync())
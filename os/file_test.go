@@ -0,0 +1,76 @@
+//go:build !wasm
+// +build !wasm
+
+package os
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestFileReadFromOSFile(t *testing.T) {
+	t.Parallel()
+	fs, err := NewDirFS(t.TempDir())
+	assert.NoError(t, err)
+	contents := bytes.Repeat([]byte("hello world\n"), 1000)
+	assert.NoError(t, fs.WriteFile("src", contents, 0600))
+
+	t.Run("whole file", func(t *testing.T) {
+		t.Parallel()
+		assert.NoError(t, hackpadfs.CopyFile(fs, "whole", fs, "src"))
+		copied, err := fs.ReadFile("whole")
+		assert.NoError(t, err)
+		assert.Equal(t, true, bytes.Equal(contents, copied))
+	})
+
+	t.Run("limited reader", func(t *testing.T) {
+		t.Parallel()
+		src, err := fs.Open("src")
+		assert.NoError(t, err)
+		defer func() { assert.NoError(t, src.Close()) }()
+		dest, err := fs.Create("limited")
+		assert.NoError(t, err)
+		limited := &io.LimitedReader{R: src, N: 100}
+		n, err := dest.(io.ReaderFrom).ReadFrom(limited)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(100), n)
+		assert.Equal(t, int64(0), limited.N)
+		assert.NoError(t, dest.Close())
+
+		copied, err := fs.ReadFile("limited")
+		assert.NoError(t, err)
+		assert.Equal(t, string(contents[:100]), string(copied))
+	})
+}
+
+func BenchmarkCopyFile(b *testing.B) {
+	fs, err := NewDirFS(b.TempDir())
+	assert.NoError(b, err)
+	const size = 64 << 20
+	assert.NoError(b, fs.WriteFile("src", make([]byte, size), 0600))
+
+	b.Run("os files", func(b *testing.B) {
+		b.SetBytes(size)
+		for i := 0; i < b.N; i++ {
+			assert.NoError(b, hackpadfs.CopyFile(fs, "dest", fs, "src"))
+		}
+	})
+
+	b.Run("userspace buffer", func(b *testing.B) {
+		b.SetBytes(size)
+		for i := 0; i < b.N; i++ {
+			src, err := fs.Open("src")
+			assert.NoError(b, err)
+			dest, err := fs.Create("dest")
+			assert.NoError(b, err)
+			_, err = io.Copy(struct{ io.Writer }{dest.(io.Writer)}, struct{ io.Reader }{src}) // hide io.ReaderFrom and io.WriterTo
+			assert.NoError(b, err)
+			assert.NoError(b, src.Close())
+			assert.NoError(b, dest.Close())
+		}
+	})
+}
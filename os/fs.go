@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/fspath"
 )
 
 const (
@@ -21,16 +22,71 @@ const (
 type FS struct {
 	root       string
 	volumeName string
+	options    Options
+}
+
+// Options contain options for creating an FS
+type Options struct {
+	// CheckWindowsNames rejects paths which are invalid on Windows with a hackpadfs.WindowsNameError, even when running on other operating systems.
+	// Helps catch names like "CON" or "file." during development and tests, instead of only failing on Windows in production.
+	// See hackpadfs.SanitizeWindowsPath() to produce valid names.
+	CheckWindowsNames bool
 }
 
 // NewFS returns a new FS. All file paths are relative to the root path.
 // Root is '/' on Unix and 'C:\' on Windows.
 // Use fs.Sub() to select a different root path. SubVolume on Windows can set the volume name.
 func NewFS() *FS {
-	return &FS{}
+	return NewFSWithOptions(Options{})
+}
+
+// NewFSWithOptions returns a new FS configured by 'options'. See NewFS() for details.
+func NewFSWithOptions(options Options) *FS {
+	return &FS{options: options}
+}
+
+// NewDirFS returns a new FS rooted at the OS directory 'dir', like io/fs.DirFS. Relative paths are resolved from the current working directory.
+// On Windows, the FS uses the directory's volume name, e.g. 'D:' or '\\host\share'.
+//
+// Equivalent to calling NewFS(), then SubVolume() and Sub() with 'dir' converted to an FS path.
+func NewDirFS(dir string) (*FS, error) {
+	const op = "dirfs"
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: op, Path: dir, Err: err}
+	}
+	info, err := os.Stat(absDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &hackpadfs.PathError{Op: op, Path: dir, Err: hackpadfs.ErrNotDir}
+	}
+
+	fs := NewFS()
+	if volumeName := filepath.VolumeName(absDir); volumeName != "" {
+		volumeFS, err := fs.SubVolume(volumeName)
+		if err != nil {
+			return nil, err
+		}
+		fs = volumeFS.(*FS)
+	}
+	fsPath, err := fs.FromOSPath(absDir)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: op, Path: dir, Err: hackpadfs.ErrInvalid}
+	}
+	if fsPath == "." {
+		return fs, nil
+	}
+	subFS, err := fs.Sub(fsPath)
+	if err != nil {
+		return nil, err
+	}
+	return subFS.(*FS), nil
 }
 
 // SubVolume is like Sub, but only sets the volume name (i.e. for Windows).
+// The returned FS is an *FS, so it also implements PathMapper.
 // Calling SubVolume again on the returned FS results in an error.
 func (fs *FS) SubVolume(volumeName string) (hackpadfs.FS, error) {
 	if fs.root != "" {
@@ -44,10 +100,12 @@ func (fs *FS) SubVolume(volumeName string) (hackpadfs.FS, error) {
 	}
 	return &FS{
 		volumeName: volumeName,
+		options:    fs.options,
 	}, nil
 }
 
 // Sub implements hackpadfs.SubFS
+// The returned FS is an *FS, so it also implements PathMapper relative to 'dir'.
 func (fs *FS) Sub(dir string) (hackpadfs.FS, error) {
 	if !hackpadfs.ValidPath(dir) {
 		return nil, &hackpadfs.PathError{Op: "sub", Path: dir, Err: hackpadfs.ErrInvalid}
@@ -55,6 +113,7 @@ func (fs *FS) Sub(dir string) (hackpadfs.FS, error) {
 	return &FS{
 		root:       path.Join(fs.root, dir),
 		volumeName: fs.volumeName,
+		options:    fs.options,
 	}, nil
 }
 
@@ -111,7 +170,7 @@ func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadf
 	if pathErr != nil {
 		return nil, pathErr
 	}
-	file, err := os.OpenFile(name, flag, perm)
+	file, err := openFile(osOpener{}, name, flag, perm)
 	return fs.wrapFile(file), fs.wrapErr(err)
 }
 
@@ -263,3 +322,40 @@ func (fs *FS) Symlink(oldname, newname string) error {
 	}
 	return fs.wrapErr(os.Symlink(oldname, newname))
 }
+
+// Readlink implements hackpadfs.ReadlinkFS
+// Returns the target as a path in this FS, like the 'oldname' passed to Symlink. Fails with hackpadfs.ErrInvalid if the target is outside this FS's root.
+func (fs *FS) Readlink(name string) (string, error) {
+	osName, pathErr := fs.rootedPath("readlink", name)
+	if pathErr != nil {
+		return "", pathErr
+	}
+	target, err := os.Readlink(osName)
+	if err != nil {
+		return "", fs.wrapErr(err)
+	}
+	return fs.linkTarget(name, target)
+}
+
+// linkTarget converts the OS symlink target 'osTarget' of the symlink 'name' to a path in this FS.
+// Absolute targets must be inside this FS's root, and relative targets are resolved from the link's directory.
+func (fs *FS) linkTarget(name, osTarget string) (string, error) {
+	var target string
+	var err error
+	if filepath.IsAbs(osTarget) {
+		target, err = fs.FromOSPath(osTarget)
+	} else if resolved, ok := fspath.ResolveLink(name, filepath.ToSlash(osTarget)); ok {
+		target = resolved
+	} else {
+		err = hackpadfs.ErrInvalid
+	}
+	if err != nil {
+		return "", &hackpadfs.PathError{Op: "readlink", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	return target, nil
+}
+
+// SameFile implements hackpadfs.SameFileFS
+func (fs *FS) SameFile(fi1, fi2 hackpadfs.FileInfo) bool {
+	return os.SameFile(fi1, fi2)
+}
@@ -4,13 +4,16 @@
 package os
 
 import (
+	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
 )
 
 func TestFSTest(t *testing.T) {
@@ -23,33 +26,22 @@ func TestFSTest(t *testing.T) {
 	options := fstest.FSOptions{
 		Name: "osfs.FS",
 		TestFS: func(tb testing.TB) fstest.SetupFS {
-			fs := NewFS()
-			dir := tb.TempDir()
-			volumeName := filepath.VolumeName(dir)
-			if volumeName != "" {
-				subvFS, err := fs.SubVolume(volumeName)
-				if !assert.NoError(tb, err) {
-					tb.FailNow()
-				}
-				fs = subvFS.(*FS)
-				dir = dir[len(volumeName)+1:]
-			} else {
-				dir = strings.TrimPrefix(dir, "/")
-			}
-			subFS, err := fs.Sub(dir)
+			fs, err := NewDirFS(tb.TempDir())
 			if !assert.NoError(tb, err) {
 				tb.FailNow()
 			}
-			return subFS.(*FS)
+			return fs
 		},
 	}
 	var skipFacets []fstest.Facets
+	options.Constraints.DirModTime = runtime.GOOS != goosWindows
 	if runtime.GOOS == goosWindows {
 		options.Constraints.FileModeMask = 0200 // Windows does not support the typical file permission bits. Only the "owner writable" bit is supported.
 		skipFacets = []fstest.Facets{
 			{Name: "TestFSTest/osfs.FS_File/file.Seek/seek_unknown_start"},                 // Windows ignores invalid 'whence' values in Seek() calls.
 			{Name: "TestFSTest/osfs.FS_FS/fs.Rename/same_directory"},                       // Windows does not return an error for renaming a directory to itself.
 			{Name: "TestFSTest/osfs.FS_FS/fs.Rename/newpath_is_directory"},                 // Windows returns an access denied error when renaming a file to an existing directory.
+			{Name: "TestFSTest/osfs.FS_FS/fs.Rename/open_file"},                            // Windows returns a sharing violation when renaming a file which is open.
 			{Name: "TestFSTest/osfs.FS_FS/fs.Chmod/change_symlink_target_permission_bits"}, // Windows requires elevated permissions to create symlinks (sometimes).
 		}
 	}
@@ -66,4 +58,117 @@ func TestFSTest(t *testing.T) {
 	assert.Subset(t, data.Skips, skipFacets)
 	data = fstest.File(t, options)
 	assert.Subset(t, data.Skips, skipFacets)
+	fstest.StdFS(t, options)
+
+	rootOptions := options
+	rootOptions.Name = "osfs.RootFS"
+	rootOptions.TestFS = func(tb testing.TB) fstest.SetupFS {
+		fs, err := NewRootFS(tb.TempDir())
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		tb.Cleanup(func() {
+			assert.NoError(tb, fs.Close())
+		})
+		return fs
+	}
+	rootOptions.ShouldSkip = func(facets fstest.Facets) bool {
+		facets.Name = strings.Replace(facets.Name, "osfs.RootFS_", "osfs.FS_", 1) // RootFS shares the same OS quirks
+		return options.ShouldSkip(facets)
+	}
+	fstest.FS(t, rootOptions)
+	fstest.File(t, rootOptions)
+}
+
+func TestNewDirFS(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "file"), []byte("hello"), 0600))
+
+	fs, err := NewDirFS(dir)
+	assert.NoError(t, err)
+	contents, err := hackpadfs.ReadFile(fs, "sub/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(contents))
+	osPath, err := fs.ToOSPath("sub/file")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "sub", "file"), osPath)
+
+	_, err = hackpadfs.ReadFile(fs, "missing")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	assert.Equal(t, "open missing: no such file or directory", err.Error())
+
+	_, err = NewDirFS(filepath.Join(dir, "sub", "file"))
+	assert.ErrorIs(t, hackpadfs.ErrNotDir, err)
+	_, err = NewDirFS(filepath.Join(dir, "missing"))
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	rootPath, err := filepath.Abs(string(filepath.Separator))
+	assert.NoError(t, err)
+	rootFS, err := NewDirFS(rootPath)
+	assert.NoError(t, err)
+	_, err = hackpadfs.Stat(rootFS, strings.TrimPrefix(filepath.ToSlash(dir[len(filepath.VolumeName(dir)):]), "/"))
+	assert.NoError(t, err)
+}
+
+func TestCheckWindowsNames(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	fs := NewFSWithOptions(Options{CheckWindowsNames: true})
+	if volumeName := filepath.VolumeName(dir); volumeName != "" {
+		subvFS, err := fs.SubVolume(volumeName)
+		assert.NoError(t, err)
+		fs = subvFS.(*FS)
+		dir = dir[len(volumeName)+1:]
+	}
+	subFS, err := fs.Sub(strings.TrimPrefix(filepath.ToSlash(dir), "/"))
+	assert.NoError(t, err)
+	fs = subFS.(*FS)
+
+	assert.NoError(t, fs.Mkdir("dir", 0700))
+	f, err := fs.Create("dir/aux.txt")
+	assert.Equal(t, nil, f)
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	var nameErr *hackpadfs.WindowsNameError
+	assert.ErrorAs(t, &nameErr, err)
+	assert.Equal(t, "aux.txt", nameErr.Name)
+	assert.Equal(t, "create dir/aux.txt: invalid name on Windows \"aux.txt\": reserved device name", err.Error())
+
+	err = fs.Rename("dir", "dir.")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+
+	f, err = fs.Create(hackpadfs.SanitizeWindowsPath("dir/aux.txt"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+}
+
+func TestFileFd(t *testing.T) {
+	t.Parallel()
+	fs, err := NewDirFS(t.TempDir())
+	assert.NoError(t, err)
+	f, err := fs.Create("file")
+	assert.NoError(t, err)
+	defer func() { assert.NoError(t, f.Close()) }()
+
+	fd, err := hackpadfs.FileFd(f)
+	assert.NoError(t, err)
+	assert.Equal(t, f.(*file).osFile.Fd(), fd)
+
+	conn, err := hackpadfs.FileSyscallConn(f)
+	assert.NoError(t, err)
+	var controlFd uintptr
+	assert.NoError(t, conn.Control(func(fd uintptr) {
+		controlFd = fd
+	}))
+	assert.Equal(t, fd, controlFd)
+
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	memFile, err := hackpadfs.Create(memFS, "file")
+	assert.NoError(t, err)
+	_, err = hackpadfs.FileFd(memFile)
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+	_, err = hackpadfs.FileSyscallConn(memFile)
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
 }
@@ -0,0 +1,88 @@
+package os
+
+import (
+	"os"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// fileOpener opens OS paths, like the os package or an os.Root
+type fileOpener interface {
+	OpenFile(name string, flag int, perm os.FileMode) (*os.File, error)
+	Lstat(name string) (os.FileInfo, error)
+}
+
+// osOpener is a fileOpener for the os package
+type osOpener struct{}
+
+func (osOpener) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osOpener) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+// openNoFollow emulates opening 'name' with O_NOFOLLOW. Checks 'name' isn't a symlink with Lstat before and after opening it, then checks the opened file is the same one.
+// FlagTruncate is applied to the opened file once it's verified, so a symlink swapped in while opening never truncates its target.
+func openNoFollow(opener fileOpener, name string, flag int, perm os.FileMode) (*os.File, error) {
+	before, err := opener.Lstat(name)
+	existed := err == nil
+	if existed && before.Mode()&os.ModeSymlink != 0 {
+		err = hackpadfs.ErrTooManyLinks
+		if flag&hackpadfs.FlagCreate != 0 && flag&hackpadfs.FlagExclusive != 0 {
+			err = hackpadfs.ErrExist // matches O_EXCL, which refuses to follow the last element
+		}
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	file, err := opener.OpenFile(name, flag&^hackpadfs.FlagTruncate, perm)
+	if err != nil {
+		return nil, err
+	}
+	after, err := opener.Lstat(name)
+	if err == nil && after.Mode()&os.ModeSymlink == 0 {
+		var opened os.FileInfo
+		opened, err = file.Stat()
+		if err == nil && (!existed || os.SameFile(before, opened)) && os.SameFile(after, opened) {
+			return truncateOpened(file, flag)
+		}
+	}
+	_ = file.Close()
+	if err == nil {
+		err = hackpadfs.ErrTooManyLinks // replaced by a symlink while opening
+	}
+	return nil, &os.PathError{Op: "open", Path: name, Err: err}
+}
+
+// truncateOpened truncates 'file' if 'flag' has FlagTruncate, closing it on failure
+func truncateOpened(file *os.File, flag int) (*os.File, error) {
+	if flag&hackpadfs.FlagTruncate == 0 {
+		return file, nil
+	}
+	if err := file.Truncate(0); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// OpenNoFollow is like OpenFile, but fails with hackpadfs.ErrTooManyLinks if the last element of 'name' is a symlink. Symlinks in parent directories are still followed.
+// Equivalent to OpenFile with FlagNoFollow.
+func (fs *FS) OpenNoFollow(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	return fs.OpenFile(name, flag|FlagNoFollow, perm)
+}
+
+// CreateNew creates and opens a new file for reading and writing. Fails with hackpadfs.ErrExist if anything exists at 'name', including a symlink, so it never writes through a link planted by someone else.
+func (fs *FS) CreateNew(name string, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	return fs.OpenNoFollow(name, hackpadfs.FlagReadWrite|hackpadfs.FlagCreate|hackpadfs.FlagExclusive, perm)
+}
+
+// OpenNoFollow is like OpenFile, but fails with hackpadfs.ErrTooManyLinks if the last element of 'name' is a symlink. See FS.OpenNoFollow().
+func (fs *RootFS) OpenNoFollow(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	return fs.OpenFile(name, flag|FlagNoFollow, perm)
+}
+
+// CreateNew creates and opens a new file for reading and writing. Fails with hackpadfs.ErrExist if anything exists at 'name'. See FS.CreateNew().
+func (fs *RootFS) CreateNew(name string, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	return fs.OpenNoFollow(name, hackpadfs.FlagReadWrite|hackpadfs.FlagCreate|hackpadfs.FlagExclusive, perm)
+}
@@ -0,0 +1,20 @@
+//go:build js
+// +build js
+
+package os
+
+import (
+	"os"
+)
+
+// FlagNoFollow is an OpenFile() flag which refuses to open a symlink as the last path element, failing with hackpadfs.ErrTooManyLinks instead.
+// This platform has no O_NOFOLLOW, so the FS checks the path with Lstat before and after opening it. Other file systems ignore it.
+const FlagNoFollow int = 0x40000000
+
+// openFile opens 'name' with 'opener'. If 'flag' has FlagNoFollow, emulates O_NOFOLLOW with openNoFollow().
+func openFile(opener fileOpener, name string, flag int, perm os.FileMode) (*os.File, error) {
+	if flag&FlagNoFollow == 0 {
+		return opener.OpenFile(name, flag, perm)
+	}
+	return openNoFollow(opener, name, flag&^FlagNoFollow, perm)
+}
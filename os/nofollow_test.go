@@ -0,0 +1,141 @@
+//go:build !wasm
+// +build !wasm
+
+package os
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestOpenNoFollow(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == goosWindows {
+		t.Skip("Windows requires elevated permissions to create symlinks")
+	}
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "dir"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "dir", "file"), []byte("hello"), 0600))
+	assert.NoError(t, os.Symlink("dir/file", filepath.Join(dir, "link")))
+	assert.NoError(t, os.Symlink("dir", filepath.Join(dir, "dirlink")))
+	assert.NoError(t, os.Symlink("dir/missing", filepath.Join(dir, "dangling")))
+	fsys, err := NewFS().Sub(strings.TrimPrefix(filepath.ToSlash(dir), "/"))
+	assert.NoError(t, err)
+	fs := fsys.(*FS)
+
+	for _, openFS := range []struct {
+		name string
+		fs   interface {
+			OpenNoFollow(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error)
+			CreateNew(name string, perm hackpadfs.FileMode) (hackpadfs.File, error)
+		}
+	}{
+		{name: "fs", fs: fs},
+		{name: "rootfs", fs: newRootFS(t, dir)},
+	} {
+		openFS := openFS
+		t.Run(openFS.name, func(t *testing.T) {
+			file, err := openFS.fs.OpenNoFollow("dir/file", hackpadfs.FlagReadOnly, 0)
+			if assert.NoError(t, err) {
+				assert.NoError(t, file.Close())
+			}
+			// only the last element is checked
+			file, err = openFS.fs.OpenNoFollow("dirlink/file", hackpadfs.FlagReadOnly, 0)
+			if assert.NoError(t, err) {
+				assert.NoError(t, file.Close())
+			}
+			_, err = openFS.fs.OpenNoFollow("link", hackpadfs.FlagReadOnly, 0)
+			assert.ErrorIs(t, hackpadfs.ErrTooManyLinks, err)
+			_, err = openFS.fs.OpenNoFollow("dangling", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate, 0600)
+			assert.ErrorIs(t, hackpadfs.ErrTooManyLinks, err)
+			_, err = os.Stat(filepath.Join(dir, "dir", "missing"))
+			assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+			_, err = openFS.fs.CreateNew("dangling", 0600)
+			assert.ErrorIs(t, hackpadfs.ErrExist, err)
+			_, err = openFS.fs.CreateNew("dir/file", 0600)
+			assert.ErrorIs(t, hackpadfs.ErrExist, err)
+			file, err = openFS.fs.CreateNew("new-"+openFS.name, 0600)
+			if assert.NoError(t, err) {
+				assert.NoError(t, file.Close())
+			}
+		})
+	}
+
+	// other file systems ignore the flag
+	file, err := fs.OpenFile("link", hackpadfs.FlagReadOnly, 0)
+	if assert.NoError(t, err) {
+		assert.NoError(t, file.Close())
+	}
+	assert.Equal(t, hackpadfs.Flags{Read: true}, hackpadfs.ParseFlags(FlagNoFollow))
+}
+
+// swappingOpener replaces the file at 'name' with a symlink to 'target' right after the first Lstat, like an attacker racing openNoFollow
+type swappingOpener struct {
+	osOpener
+	target  string
+	swapped bool
+}
+
+func (o *swappingOpener) Lstat(name string) (os.FileInfo, error) {
+	info, err := o.osOpener.Lstat(name)
+	if !o.swapped {
+		o.swapped = true
+		if err := os.Remove(name); err != nil {
+			return nil, err
+		}
+		if err := os.Symlink(o.target, name); err != nil {
+			return nil, err
+		}
+	}
+	return info, err
+}
+
+func TestOpenNoFollowTruncateSymlink(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == goosWindows {
+		t.Skip("Windows requires elevated permissions to create symlinks")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	assert.NoError(t, os.WriteFile(target, []byte("keep me"), 0600))
+	assert.NoError(t, os.Symlink("target", filepath.Join(dir, "link")))
+	fsys, err := NewFS().Sub(strings.TrimPrefix(filepath.ToSlash(dir), "/"))
+	assert.NoError(t, err)
+
+	for _, openFS := range []struct {
+		name string
+		fs   hackpadfs.OpenFileFS
+	}{
+		{name: "fs", fs: fsys.(*FS)},
+		{name: "rootfs", fs: newRootFS(t, dir)},
+	} {
+		_, err := openFS.fs.OpenFile("link", hackpadfs.FlagWriteOnly|hackpadfs.FlagTruncate|FlagNoFollow, 0)
+		assert.ErrorIs(t, hackpadfs.ErrTooManyLinks, err)
+	}
+
+	// a symlink swapped in between the checks must not truncate its target
+	victim := filepath.Join(dir, "victim")
+	assert.NoError(t, os.WriteFile(victim, []byte("regular"), 0600))
+	_, err = openNoFollow(&swappingOpener{target: target}, victim, hackpadfs.FlagWriteOnly|hackpadfs.FlagTruncate, 0)
+	assert.ErrorIs(t, hackpadfs.ErrTooManyLinks, err)
+
+	contents, err := os.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "keep me", string(contents))
+
+	// verified files are still truncated
+	file, err := newRootFS(t, dir).OpenFile("target", hackpadfs.FlagWriteOnly|hackpadfs.FlagTruncate|FlagNoFollow, 0)
+	if assert.NoError(t, err) {
+		assert.NoError(t, file.Close())
+	}
+	contents, err = os.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(contents))
+}
@@ -0,0 +1,24 @@
+//go:build !windows && !js
+// +build !windows,!js
+
+package os
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// FlagNoFollow is an OpenFile() flag which refuses to open a symlink as the last path element, failing with hackpadfs.ErrTooManyLinks instead.
+// Uses O_NOFOLLOW on this platform, except in a RootFS, which checks the path with Lstat before and after opening it. Other file systems ignore it.
+const FlagNoFollow int = syscall.O_NOFOLLOW
+
+// openFile opens 'name' with 'opener', mapping each platform's O_NOFOLLOW error to ELOOP
+func openFile(opener fileOpener, name string, flag int, perm os.FileMode) (*os.File, error) {
+	file, err := opener.OpenFile(name, flag, perm)
+	var pathErr *os.PathError
+	if flag&FlagNoFollow != 0 && errors.As(err, &pathErr) && pathErr.Err == syscall.EMLINK { // FreeBSD and DragonFly BSD return EMLINK for symlinks
+		err = &os.PathError{Op: pathErr.Op, Path: pathErr.Path, Err: syscall.ELOOP}
+	}
+	return file, err
+}
@@ -0,0 +1,96 @@
+//go:build windows
+// +build windows
+
+package os
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// FlagNoFollow is an OpenFile() flag which refuses to open a symlink as the last path element, failing with hackpadfs.ErrTooManyLinks instead.
+// On this platform, the FS opens the last element with FILE_FLAG_OPEN_REPARSE_POINT and refuses any reparse point, like a symlink or junction.
+// A RootFS checks the path with Lstat before and after opening it instead. Other file systems ignore it.
+const FlagNoFollow int = 0x40000000
+
+const (
+	fileWriteEA         = 0x00000010 // FILE_WRITE_EA
+	standardRightsWrite = 0x00020000 // STANDARD_RIGHTS_WRITE
+)
+
+// openFile opens 'name' with 'opener'. If 'flag' has FlagNoFollow, opens it with openReparsePoint() instead.
+func openFile(opener fileOpener, name string, flag int, perm os.FileMode) (*os.File, error) {
+	if flag&FlagNoFollow == 0 {
+		return opener.OpenFile(name, flag, perm)
+	}
+	if _, isOS := opener.(osOpener); !isOS {
+		return openNoFollow(opener, name, flag&^FlagNoFollow, perm)
+	}
+	return openReparsePoint(name, flag&^FlagNoFollow, perm)
+}
+
+// openReparsePoint opens 'name' like os.OpenFile, but without following a reparse point in the last element. Fails with hackpadfs.ErrTooManyLinks if it is one.
+// FlagTruncate is applied once the opened file is verified, since truncating while opening would truncate the reparse point itself.
+func openReparsePoint(name string, flag int, perm os.FileMode) (*os.File, error) {
+	pathErr := func(err error) error {
+		return &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	namep, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, pathErr(err)
+	}
+	var access uint32
+	switch flag & (syscall.O_RDONLY | syscall.O_WRONLY | syscall.O_RDWR) {
+	case syscall.O_RDONLY:
+		access = syscall.GENERIC_READ
+	case syscall.O_WRONLY:
+		access = syscall.GENERIC_WRITE
+	case syscall.O_RDWR:
+		access = syscall.GENERIC_READ | syscall.GENERIC_WRITE
+	}
+	if flag&syscall.O_CREAT != 0 {
+		access |= syscall.GENERIC_WRITE
+	}
+	if flag&syscall.O_APPEND != 0 {
+		if flag&syscall.O_TRUNC == 0 { // truncating needs GENERIC_WRITE
+			access &^= syscall.GENERIC_WRITE
+		}
+		access |= syscall.FILE_APPEND_DATA | syscall.FILE_WRITE_ATTRIBUTES | fileWriteEA | standardRightsWrite | syscall.SYNCHRONIZE
+	}
+	var attrs uint32 = syscall.FILE_ATTRIBUTE_NORMAL | syscall.FILE_FLAG_OPEN_REPARSE_POINT
+	if perm&0200 == 0 {
+		attrs = syscall.FILE_ATTRIBUTE_READONLY | syscall.FILE_FLAG_OPEN_REPARSE_POINT
+	}
+	if flag&(syscall.O_WRONLY|syscall.O_RDWR) == 0 {
+		attrs |= syscall.FILE_FLAG_BACKUP_SEMANTICS // required to open directories
+	}
+	var createMode uint32
+	switch {
+	case flag&(syscall.O_CREAT|syscall.O_EXCL) == (syscall.O_CREAT | syscall.O_EXCL):
+		createMode = syscall.CREATE_NEW
+	case flag&syscall.O_CREAT != 0:
+		createMode = syscall.OPEN_ALWAYS
+	default:
+		createMode = syscall.OPEN_EXISTING
+	}
+	handle, err := syscall.CreateFile(namep, access, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE, nil, createMode, attrs, 0)
+	if err != nil {
+		return nil, pathErr(err)
+	}
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(handle, &info); err != nil {
+		_ = syscall.CloseHandle(handle)
+		return nil, pathErr(err)
+	}
+	if info.FileAttributes&syscall.FILE_ATTRIBUTE_REPARSE_POINT != 0 {
+		_ = syscall.CloseHandle(handle)
+		return nil, pathErr(hackpadfs.ErrTooManyLinks)
+	}
+	file, err := truncateOpened(os.NewFile(uintptr(handle), name), flag)
+	if err != nil {
+		return nil, pathErr(err)
+	}
+	return file, nil
+}
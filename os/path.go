@@ -11,7 +11,25 @@ import (
 
 const osPathOp = "ospath"
 
-// ToOSPath converts a valid 'io/fs' package path to the equivalent 'os' package path for this FS
+const (
+	// windowsExtendedPrefix marks an extended-length path on Windows, like \\?\C:\foo
+	windowsExtendedPrefix = `\\?\`
+	// windowsExtendedUNCPrefix marks an extended-length UNC path on Windows, like \\?\UNC\host\share\foo
+	windowsExtendedUNCPrefix = windowsExtendedPrefix + `UNC\`
+)
+
+var _ PathMapper = &FS{}
+
+// PathMapper converts paths between an FS and the 'os' package.
+// Implemented by FS, including the FS's returned from Sub() and SubVolume(), so integrations like editors and file watchers can translate paths without knowing the FS's root or volume.
+type PathMapper interface {
+	// ToOSPath converts a valid 'io/fs' package path to the equivalent absolute 'os' package path
+	ToOSPath(fsPath string) (string, error)
+	// FromOSPath converts an absolute 'os' package path to the equivalent 'io/fs' package path
+	FromOSPath(osPath string) (string, error)
+}
+
+// ToOSPath converts a valid 'io/fs' package path to the equivalent 'os' package path for this FS.
 func (fs *FS) ToOSPath(fsPath string) (string, error) {
 	osPath, err := fs.rootedPath(osPathOp, fsPath)
 	if err != nil { // handle typed err
@@ -21,6 +39,11 @@ func (fs *FS) ToOSPath(fsPath string) (string, error) {
 }
 
 func (fs *FS) rootedPath(op, name string) (string, *hackpadfs.PathError) {
+	if fs.options.CheckWindowsNames && hackpadfs.ValidPath(name) {
+		if err := hackpadfs.CheckWindowsPath(name); err != nil {
+			return "", &hackpadfs.PathError{Op: op, Path: name, Err: err}
+		}
+	}
 	return fs.toOSPath(runtime.GOOS, filepath.Separator, op, name)
 }
 
@@ -33,6 +56,24 @@ func (fs *FS) toOSPath(goos string, separator rune, op, fsPath string) (string,
 	return filePath, nil
 }
 
+// shortPath removes the extended-length prefix from a Windows path, unless this FS's volume name is already an extended-length path
+func (fs *FS) shortPath(goos, filePath string) string {
+	if goos != goosWindows || strings.HasPrefix(fs.volumeName, windowsExtendedPrefix) {
+		return filePath
+	}
+	return fromExtendedLengthPath(filePath)
+}
+
+// fromExtendedLengthPath removes the extended-length prefix from a Windows path, like \\?\C:\foo or \\?\UNC\host\share\foo, if present
+func fromExtendedLengthPath(filePath string) string {
+	switch {
+	case strings.HasPrefix(filePath, windowsExtendedUNCPrefix):
+		return `\\` + strings.TrimPrefix(filePath, windowsExtendedUNCPrefix)
+	default:
+		return strings.TrimPrefix(filePath, windowsExtendedPrefix)
+	}
+}
+
 func joinSepPath(separator, elem1, elem2 string) string {
 	elem1 = strings.TrimRight(elem1, separator)
 	elem2 = strings.TrimLeft(elem2, separator)
@@ -70,7 +111,54 @@ func (fs *FS) FromOSPath(osPath string) (string, error) {
 	if !filepath.IsAbs(osPath) {
 		return "", &hackpadfs.PathError{Op: osPathOp, Path: osPath, Err: hackpadfs.ErrInvalid}
 	}
-	return fs.fromOSPath(runtime.GOOS, filepath.Separator, filepath.VolumeName, osPathOp, osPath)
+	fsPath, err := fs.fromOSPath(runtime.GOOS, filepath.Separator, filepath.VolumeName, osPathOp, filepath.Clean(osPath))
+	if err == nil && !hackpadfs.ValidPath(fsPath) {
+		err = &hackpadfs.PathError{Op: osPathOp, Path: osPath, Err: hackpadfs.ErrInvalid}
+	}
+	return fsPath, err
+}
+
+// MappedDirEntry is a DirEntry with its full FS and OS paths. Returned from MapDirEntry().
+type MappedDirEntry struct {
+	hackpadfs.DirEntry
+	FSPath string
+	OSPath string
+}
+
+// MapDirEntry returns 'entry' with its FS and OS paths, where 'entry' was read from the FS directory 'dir', e.g. with hackpadfs.ReadDir(fs, dir).
+func (fs *FS) MapDirEntry(dir string, entry hackpadfs.DirEntry) (MappedDirEntry, error) {
+	fsPath, osPath, err := fs.mapName(dir, entry.Name())
+	return MappedDirEntry{
+		DirEntry: entry,
+		FSPath:   fsPath,
+		OSPath:   osPath,
+	}, err
+}
+
+// MappedFileInfo is a FileInfo with its full FS and OS paths. Returned from MapFileInfo().
+type MappedFileInfo struct {
+	hackpadfs.FileInfo
+	FSPath string
+	OSPath string
+}
+
+// MapFileInfo returns 'info' with its FS and OS paths, where 'info' describes a file in the FS directory 'dir', e.g. from hackpadfs.Stat(fs, path.Join(dir, name)).
+func (fs *FS) MapFileInfo(dir string, info hackpadfs.FileInfo) (MappedFileInfo, error) {
+	fsPath, osPath, err := fs.mapName(dir, info.Name())
+	return MappedFileInfo{
+		FileInfo: info,
+		FSPath:   fsPath,
+		OSPath:   osPath,
+	}, err
+}
+
+func (fs *FS) mapName(dir, name string) (fsPath, osPath string, err error) {
+	if !hackpadfs.ValidPath(dir) {
+		return "", "", &hackpadfs.PathError{Op: osPathOp, Path: dir, Err: hackpadfs.ErrInvalid}
+	}
+	fsPath = path.Join(dir, name)
+	osPath, err = fs.ToOSPath(fsPath)
+	return fsPath, osPath, err
 }
 
 func (fs *FS) fromOSPath(
@@ -78,6 +166,7 @@ func (fs *FS) fromOSPath(
 	op, osPath string,
 ) (string, error) {
 	errInvalid := &hackpadfs.PathError{Op: op, Path: osPath, Err: hackpadfs.ErrInvalid}
+	osPath = fs.shortPath(goos, osPath)
 	fsVolumeName := fs.getVolumeName(goos)
 	if getVolumeName(osPath) != fsVolumeName {
 		return "", errInvalid
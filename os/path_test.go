@@ -4,8 +4,11 @@
 package os
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/internal/assert"
 )
 
@@ -13,6 +16,11 @@ const (
 	goosLinux = "linux"
 )
 
+var (
+	longName        = strings.Repeat("long-directory/", 20) + "file" // longer than Windows' MAX_PATH
+	longWindowsName = strings.ReplaceAll(longName, "/", `\`)
+)
+
 func TestToOSPath(t *testing.T) {
 	t.Parallel()
 	for _, tc := range []struct {
@@ -92,6 +100,39 @@ func TestToOSPath(t *testing.T) {
 			name:        "bar",
 			expectPath:  `\\some-host\share\foo\bar`,
 		},
+		{
+			description: "long path unix",
+			goos:        goosLinux,
+			name:        longName,
+			expectPath:  "/" + longName,
+		},
+		{
+			description: "long path windows", // the os package adds extended-length prefixes itself
+			goos:        goosWindows,
+			name:        longName,
+			expectPath:  `C:\` + longWindowsName,
+		},
+		{
+			description: "long UNC volume path windows",
+			volumeName:  `\\some-host\share`,
+			goos:        goosWindows,
+			name:        longName,
+			expectPath:  `\\some-host\share\` + longWindowsName,
+		},
+		{
+			description: "long extended-length volume path windows",
+			volumeName:  `\\?\D:`,
+			goos:        goosWindows,
+			name:        longName,
+			expectPath:  `\\?\D:\` + longWindowsName,
+		},
+		{
+			description: "extended-length volume short path windows",
+			volumeName:  `\\?\D:`,
+			goos:        goosWindows,
+			name:        "foo",
+			expectPath:  `\\?\D:\foo`,
+		},
 	} {
 		tc := tc // enable parallel sub-tests
 		t.Run(tc.description, func(t *testing.T) {
@@ -226,6 +267,29 @@ func TestFromOSPath(t *testing.T) {
 			osPathVolumeName: `\\some-host\share`,
 			expectPath:       "bar",
 		},
+		{
+			description:      "long path windows",
+			goos:             goosWindows,
+			osPath:           `\\?\C:\` + longWindowsName,
+			osPathVolumeName: `C:`,
+			expectPath:       longName,
+		},
+		{
+			description:      "long UNC volume path windows",
+			volumeName:       `\\some-host\share`,
+			goos:             goosWindows,
+			osPath:           `\\?\UNC\some-host\share\` + longWindowsName,
+			osPathVolumeName: `\\some-host\share`,
+			expectPath:       longName,
+		},
+		{
+			description:      "extended-length volume path windows",
+			volumeName:       `\\?\D:`,
+			goos:             goosWindows,
+			osPath:           `\\?\D:\foo`,
+			osPathVolumeName: `\\?\D:`,
+			expectPath:       "foo",
+		},
 	} {
 		tc := tc // enable parallel sub-tests
 		t.Run(tc.description, func(t *testing.T) {
@@ -251,3 +315,62 @@ func TestFromOSPath(t *testing.T) {
 		})
 	}
 }
+
+func TestPathMapperSub(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	fs, err := NewDirFS(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Mkdir("sub", 0700))
+	subFS, err := fs.Sub("sub")
+	assert.NoError(t, err)
+	mapper, ok := subFS.(PathMapper)
+	if !assert.Equal(t, true, ok) {
+		t.FailNow()
+	}
+
+	osPath, err := mapper.ToOSPath("file")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "sub", "file"), osPath)
+
+	fsPath, err := mapper.FromOSPath(filepath.Join(dir, "sub", "other", "..", "file"))
+	assert.NoError(t, err)
+	assert.Equal(t, "file", fsPath)
+
+	_, err = mapper.FromOSPath(dir)
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	_, err = mapper.FromOSPath(filepath.Join("sub", "file"))
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
+
+func TestMapDirEntry(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	fs, err := NewDirFS(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+
+	entries, err := hackpadfs.ReadDir(fs, "foo")
+	assert.NoError(t, err)
+	if !assert.Equal(t, 1, len(entries)) {
+		t.FailNow()
+	}
+	entry, err := fs.MapDirEntry("foo", entries[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "foo/bar", entry.FSPath)
+	assert.Equal(t, filepath.Join(dir, "foo", "bar"), entry.OSPath)
+	assert.Equal(t, true, entry.IsDir())
+
+	info, err := hackpadfs.Stat(fs, "foo/bar")
+	assert.NoError(t, err)
+	mappedInfo, err := fs.MapFileInfo("foo", info)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo/bar", mappedInfo.FSPath)
+	assert.Equal(t, true, mappedInfo.IsDir())
+	fsPath, err := fs.FromOSPath(mappedInfo.OSPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo/bar", fsPath)
+
+	_, err = fs.MapFileInfo("../foo", info)
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
@@ -0,0 +1,29 @@
+package os
+
+import (
+	"net/url"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// init registers the "os" scheme for hackpadfs.New(). URLs like "os:/var/data" or "os:data" create an FS with NewDirFS(). A bare "os:" URL creates an FS with NewFS().
+func init() {
+	hackpadfs.Register("os", func(rawURL string) (hackpadfs.FS, error) {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		dir := u.Opaque
+		if dir == "" {
+			dir = u.Path
+		}
+		if dir == "" {
+			return NewFS(), nil
+		}
+		fs, err := NewDirFS(dir)
+		if err != nil {
+			return nil, err
+		}
+		return fs, nil
+	})
+}
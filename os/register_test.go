@@ -0,0 +1,32 @@
+//go:build !wasm
+// +build !wasm
+
+package os
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "foo"), []byte("foo"), 0600))
+
+	fs, err := hackpadfs.New("os:" + filepath.ToSlash(dir))
+	assert.NoError(t, err)
+	contents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(contents))
+
+	fs, err = hackpadfs.New("os:")
+	assert.NoError(t, err)
+	assert.IsType(t, &FS{}, fs)
+
+	_, err = hackpadfs.New("os:" + filepath.ToSlash(filepath.Join(dir, "missing")))
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
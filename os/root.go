@@ -0,0 +1,316 @@
+package os
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ PathMapper         = &RootFS{}
+	_ hackpadfs.CloserFS = &RootFS{}
+)
+
+// RootFS is like an FS from NewDirFS, but resolves every path with an os.Root, so no path can escape its directory.
+// An FS joins paths to its root directory before the os package walks them, so a directory swapped for a symlink between the two could escape. An os.Root opens each path element relative to the last, closing that race.
+// Symlinks are followed only if they stay inside the directory, and paths which escape fail with an error.
+// New files and directories only receive permission bits, since an os.Root rejects other mode bits.
+//
+// Requires Go 1.25 or later. Close the RootFS when done to release its directory handle.
+type RootFS struct {
+	root rootHandle
+	fs   *FS // fs maps paths and errors for the same directory
+}
+
+// rootHandle is the subset of *os.Root used by RootFS, which only exists in newer Go versions
+type rootHandle interface {
+	Close() error
+	OpenFile(name string, flag int, perm os.FileMode) (*os.File, error)
+	Mkdir(name string, perm os.FileMode) error
+	MkdirAll(name string, perm os.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Chmod(name string, mode os.FileMode) error
+	Chown(name string, uid, gid int) error
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+}
+
+// NewRootFS returns a new RootFS for the OS directory 'dir'. Relative paths are resolved from the current working directory.
+// Fails with hackpadfs.ErrNotImplemented before Go 1.25.
+func NewRootFS(dir string) (*RootFS, error) {
+	fs, err := NewDirFS(dir)
+	if err != nil {
+		return nil, err
+	}
+	osDir, err := fs.ToOSPath(".")
+	if err != nil {
+		return nil, err
+	}
+	root, err := openRoot(osDir)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "rootfs", Path: dir, Err: err}
+	}
+	return &RootFS{root: root, fs: fs}, nil
+}
+
+// Close releases the RootFS's directory handle. Files opened from it remain usable.
+func (fs *RootFS) Close() error {
+	return fs.root.Close()
+}
+
+// ToOSPath implements PathMapper
+func (fs *RootFS) ToOSPath(fsPath string) (string, error) {
+	return fs.fs.ToOSPath(fsPath)
+}
+
+// FromOSPath implements PathMapper
+func (fs *RootFS) FromOSPath(osPath string) (string, error) {
+	return fs.fs.FromOSPath(osPath)
+}
+
+// osPath returns the path to 'name' relative to the os.Root
+func (fs *RootFS) osPath(op, name string) (string, error) {
+	if _, err := fs.fs.rootedPath(op, name); err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(name), nil
+}
+
+// wrapErr wraps 'err' like FS, then replaces the os.Root operation name, like "openat", with 'op'
+func (fs *RootFS) wrapErr(op string, err error) error {
+	err = fs.fs.wrapErr(err)
+	switch e := err.(type) {
+	case *hackpadfs.PathError:
+		e.Op = op
+	case *hackpadfs.LinkError:
+		e.Op = op
+	}
+	return err
+}
+
+// Sub implements hackpadfs.SubFS
+// The returned FS is a *RootFS with its own directory handle, which must also be closed.
+func (fs *RootFS) Sub(dir string) (hackpadfs.FS, error) {
+	osDir, err := fs.osPath("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	subFS, err := fs.fs.Sub(dir)
+	if err != nil {
+		return nil, err
+	}
+	root, err := openSubRoot(fs.root, osDir)
+	if err != nil {
+		return nil, fs.wrapErr("sub", err)
+	}
+	return &RootFS{root: root, fs: subFS.(*FS)}, nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *RootFS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *RootFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	osName, err := fs.osPath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	var file *os.File
+	if flag&FlagNoFollow != 0 {
+		file, err = openNoFollow(fs.root, osName, flag&^FlagNoFollow, perm&hackpadfs.ModePerm) // an os.Root ignores O_NOFOLLOW
+	} else {
+		file, err = fs.root.OpenFile(osName, flag, perm&hackpadfs.ModePerm)
+	}
+	if err != nil {
+		return nil, fs.wrapErr("open", err)
+	}
+	return fs.fs.wrapFile(file), nil
+}
+
+// Create implements hackpadfs.CreateFS
+func (fs *RootFS) Create(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadWrite|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0666)
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *RootFS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	osName, err := fs.osPath("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return fs.wrapErr("mkdir", fs.root.Mkdir(osName, perm&hackpadfs.ModePerm))
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *RootFS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	osPath, err := fs.osPath("mkdirall", path)
+	if err != nil {
+		return err
+	}
+	return fs.wrapErr("mkdir", fs.root.MkdirAll(osPath, perm&hackpadfs.ModePerm))
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *RootFS) Remove(name string) error {
+	osName, err := fs.osPath("remove", name)
+	if err != nil {
+		return err
+	}
+	return fs.wrapErr("remove", fs.root.Remove(osName))
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *RootFS) RemoveAll(name string) error {
+	osName, err := fs.osPath("removeall", name)
+	if err != nil {
+		return err
+	}
+	return fs.wrapErr("removeall", fs.root.RemoveAll(osName))
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *RootFS) Rename(oldname, newname string) error {
+	osOld, err := fs.osPath("rename", oldname)
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: errors.Unwrap(err)}
+	}
+	osNew, err := fs.osPath("rename", newname)
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: errors.Unwrap(err)}
+	}
+	return fs.wrapErr("rename", fs.root.Rename(osOld, osNew))
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *RootFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	osName, err := fs.osPath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.root.Stat(osName)
+	return info, fs.wrapErr("stat", err)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *RootFS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	osName, err := fs.osPath("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.root.Lstat(osName)
+	return info, fs.wrapErr("lstat", err)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *RootFS) Chmod(name string, mode hackpadfs.FileMode) error {
+	osName, err := fs.osPath("chmod", name)
+	if err != nil {
+		return err
+	}
+	return fs.wrapErr("chmod", fs.root.Chmod(osName, mode))
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *RootFS) Chown(name string, uid, gid int) error {
+	osName, err := fs.osPath("chown", name)
+	if err != nil {
+		return err
+	}
+	return fs.wrapErr("chown", fs.root.Chown(osName, uid, gid))
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *RootFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	osName, err := fs.osPath("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return fs.wrapErr("chtimes", fs.root.Chtimes(osName, atime, mtime))
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *RootFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	osName, err := fs.osPath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := fs.root.OpenFile(osName, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, fs.wrapErr("open", err)
+	}
+	defer dir.Close()
+	entries, err := dir.ReadDir(-1)
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Name() < entries[b].Name()
+	})
+	return entries, fs.wrapErr("readdir", err)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *RootFS) ReadFile(name string) ([]byte, error) {
+	osName, err := fs.osPath("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := fs.root.ReadFile(osName)
+	return contents, fs.wrapErr("open", err)
+}
+
+// WriteFile implements hackpadfs.WriteFileFS
+func (fs *RootFS) WriteFile(name string, data []byte, perm hackpadfs.FileMode) error {
+	osName, err := fs.osPath("writefile", name)
+	if err != nil {
+		return err
+	}
+	return fs.wrapErr("open", fs.root.WriteFile(osName, data, perm&hackpadfs.ModePerm))
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+// The symlink's target is stored relative to its directory, so the os.Root can follow it.
+func (fs *RootFS) Symlink(oldname, newname string) error {
+	if _, err := fs.osPath("symlink", oldname); err != nil {
+		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errors.Unwrap(err)}
+	}
+	osNew, err := fs.osPath("symlink", newname)
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errors.Unwrap(err)}
+	}
+	target, err := filepath.Rel(filepath.FromSlash(path.Dir(newname)), filepath.FromSlash(oldname))
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	return fs.wrapErr("symlink", fs.root.Symlink(target, osNew))
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+// Returns the target as a path in this FS, like the 'oldname' passed to Symlink.
+func (fs *RootFS) Readlink(name string) (string, error) {
+	osName, err := fs.osPath("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	target, err := fs.root.Readlink(osName)
+	if err != nil {
+		return "", fs.wrapErr("readlink", err)
+	}
+	return fs.fs.linkTarget(name, target)
+}
+
+// SameFile implements hackpadfs.SameFileFS
+func (fs *RootFS) SameFile(fi1, fi2 hackpadfs.FileInfo) bool {
+	return os.SameFile(fi1, fi2)
+}
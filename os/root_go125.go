@@ -0,0 +1,16 @@
+//go:build go1.25
+// +build go1.25
+
+package os
+
+import "os"
+
+var _ rootHandle = &os.Root{}
+
+func openRoot(dir string) (rootHandle, error) {
+	return os.OpenRoot(dir)
+}
+
+func openSubRoot(root rootHandle, dir string) (rootHandle, error) {
+	return root.(*os.Root).OpenRoot(dir)
+}
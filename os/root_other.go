@@ -0,0 +1,15 @@
+//go:build !go1.25
+// +build !go1.25
+
+package os
+
+import "github.com/hack-pad/hackpadfs"
+
+// openRoot fails, since os.Root doesn't support every operation of RootFS before Go 1.25
+func openRoot(dir string) (rootHandle, error) {
+	return nil, hackpadfs.ErrNotImplemented
+}
+
+func openSubRoot(root rootHandle, dir string) (rootHandle, error) {
+	return nil, hackpadfs.ErrNotImplemented
+}
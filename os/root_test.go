@@ -0,0 +1,88 @@
+//go:build !wasm
+// +build !wasm
+
+package os
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func newRootFS(tb testing.TB, dir string) *RootFS {
+	tb.Helper()
+	fs, err := NewRootFS(dir)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	tb.Cleanup(func() {
+		assert.NoError(tb, fs.Close())
+	})
+	return fs
+}
+
+func TestRootFSEscape(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == goosWindows {
+		t.Skip("Windows requires elevated permissions to create symlinks")
+	}
+	outside := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0600))
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "file"), []byte("hello"), 0600))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(dir, "escape")))
+	fs := newRootFS(t, dir)
+
+	_, err := fs.ReadFile("escape/secret")
+	assert.Error(t, err)
+	_, err = fs.Stat("escape/secret")
+	assert.Error(t, err)
+	err = fs.WriteFile("escape/new", nil, 0600)
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(outside, "new"))
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	_, err = fs.Open("../" + filepath.Base(outside) + "/secret")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+
+	assert.NoError(t, fs.Symlink("sub/file", "sub/link"))
+	target, err := fs.Readlink("sub/link")
+	assert.NoError(t, err)
+	assert.Equal(t, "sub/file", target)
+	_, err = fs.Readlink("escape")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	contents, err := fs.ReadFile("sub/link")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(contents))
+}
+
+func TestRootFSSub(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "file"), []byte("hello"), 0600))
+	fs := newRootFS(t, dir)
+
+	subFS, err := fs.Sub("sub")
+	assert.NoError(t, err)
+	sub := subFS.(*RootFS)
+	t.Cleanup(func() {
+		assert.NoError(t, sub.Close())
+	})
+	contents, err := hackpadfs.ReadFile(sub, "file")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(contents))
+	osPath, err := sub.ToOSPath("file")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "sub", "file"), osPath)
+
+	_, err = hackpadfs.ReadFile(sub, "missing")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	assert.Equal(t, "open missing: no such file or directory", err.Error())
+	_, err = fs.Sub("missing")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
@@ -0,0 +1,123 @@
+//go:build !wasm
+// +build !wasm
+
+package os
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestEvalSymlinks(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == goosWindows {
+		t.Skip("Windows requires elevated permissions to create symlinks")
+	}
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a", "b", "file"), nil, 0600))
+	for link, target := range map[string]string{
+		"relative":     "a/b",
+		"a/up":         "../a",
+		"absolute":     filepath.Join(dir, "a", "b", "file"),
+		"outside":      filepath.Dir(dir),
+		"escape":       "../../../../a",
+		"chain":        "relative/filelink",
+		"loop1":        "loop2",
+		"loop2":        "loop1",
+		"a/b/filelink": "file",
+		"a/b/dangling": "missing",
+	} {
+		assert.NoError(t, os.Symlink(target, filepath.Join(dir, link)))
+	}
+	fs, err := NewFS().Sub(strings.TrimPrefix(filepath.ToSlash(dir), "/"))
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		path      string
+		expect    string
+		expectErr error
+	}{
+		{path: "a/b/file", expect: "a/b/file"},
+		{path: "relative/file", expect: "a/b/file"},
+		{path: "a/up/b", expect: "a/b"},
+		{path: "absolute", expect: "a/b/file"},
+		{path: "outside", expectErr: hackpadfs.ErrInvalid},
+		{path: "escape/b", expectErr: hackpadfs.ErrInvalid},
+		{path: "relative/filelink", expect: "a/b/file"},
+		{path: "chain", expect: "a/b/file"},
+		{path: "loop1", expectErr: hackpadfs.ErrTooManyLinks},
+		{path: "a/b/dangling", expectErr: hackpadfs.ErrNotExist},
+	} {
+		resolved, err := hackpadfs.EvalSymlinks(fs, tc.path)
+		if tc.expectErr != nil {
+			assert.ErrorIs(t, tc.expectErr, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expect, resolved, tc.path)
+	}
+}
+
+func TestReadlink(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == goosWindows {
+		t.Skip("Windows requires elevated permissions to create symlinks")
+	}
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "dir"), 0700))
+	assert.NoError(t, os.Symlink("../dir", filepath.Join(dir, "dir", "relative")))
+	assert.NoError(t, os.Symlink(filepath.Dir(dir), filepath.Join(dir, "outside")))
+	fs, err := NewFS().Sub(strings.TrimPrefix(filepath.ToSlash(dir), "/"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, hackpadfs.Symlink(fs, "dir/a", "dir/link"))
+	target, err := hackpadfs.Readlink(fs, "dir/link")
+	assert.NoError(t, err)
+	assert.Equal(t, "dir/a", target)
+
+	target, err = hackpadfs.Readlink(fs, "dir/relative")
+	assert.NoError(t, err)
+	assert.Equal(t, "dir", target)
+
+	_, err = hackpadfs.Readlink(fs, "outside")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
+
+func TestEvalSymlinksCreatedLinks(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == goosWindows {
+		t.Skip("Windows requires elevated permissions to create symlinks")
+	}
+	dir := t.TempDir()
+	subFS, err := NewFS().Sub(strings.TrimPrefix(filepath.ToSlash(dir), "/"))
+	assert.NoError(t, err)
+	for _, tc := range []struct {
+		description string
+		fs          hackpadfs.FS
+	}{
+		{description: "os.FS", fs: subFS},                 // stores absolute OS targets
+		{description: "os.RootFS", fs: newRootFS(t, dir)}, // stores targets relative to the link
+	} {
+		fs := tc.fs
+		prefix := strings.ReplaceAll(tc.description, ".", "-")
+		assert.NoError(t, hackpadfs.MkdirAll(fs, prefix+"/a/b", 0700))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, prefix+"/a/b/file", nil, 0600))
+		assert.NoError(t, hackpadfs.Mkdir(fs, prefix+"/dir", 0700))
+		assert.NoError(t, hackpadfs.Symlink(fs, prefix+"/a/b", prefix+"/dir/link"))
+		assert.NoError(t, hackpadfs.Symlink(fs, prefix+"/dir/link/file", prefix+"/chain"))
+
+		resolved, err := hackpadfs.EvalSymlinks(fs, prefix+"/dir/link/file")
+		assert.NoError(t, err, tc.description)
+		assert.Equal(t, prefix+"/a/b/file", resolved, tc.description)
+		resolved, err = hackpadfs.EvalSymlinks(fs, prefix+"/chain")
+		assert.NoError(t, err, tc.description)
+		assert.Equal(t, prefix+"/a/b/file", resolved, tc.description)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build !wasm
+// +build !wasm
+
+package os
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestWalkDirFollow(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == goosWindows {
+		t.Skip("Windows requires elevated permissions to create symlinks")
+	}
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a"), nil, 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "b"), nil, 0600))
+	for link, target := range map[string]string{
+		"linkfile": "a",
+		"linkdir":  "sub",
+		"sub/loop": "..",
+		"dangling": "missing",
+	} {
+		assert.NoError(t, os.Symlink(target, filepath.Join(dir, link)))
+	}
+	fs, err := NewFS().Sub(strings.TrimPrefix(filepath.ToSlash(dir), "/"))
+	assert.NoError(t, err)
+
+	type visit struct {
+		Target string
+		IsDir  bool
+		Err    bool
+	}
+	visits := make(map[string]visit)
+	err = hackpadfs.WalkDirFollow(fs, ".", func(path, target string, d hackpadfs.DirEntry, err error) error {
+		visits[path] = visit{Target: target, IsDir: d.IsDir(), Err: err != nil}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]visit{
+		".":            {IsDir: true},
+		"a":            {},
+		"dangling":     {Target: "missing", Err: true},
+		"linkdir":      {Target: "sub", IsDir: true},
+		"linkdir/b":    {},
+		"linkdir/loop": {Target: ".", IsDir: true}, // the root, so it is not walked again
+		"linkfile":     {Target: "a"},
+		"sub":          {IsDir: true},
+		"sub/b":        {},
+		"sub/loop":     {Target: ".", IsDir: true},
+	}, visits)
+}
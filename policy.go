@@ -0,0 +1,41 @@
+package hackpadfs
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// Policy controls which package helpers emulate an operation when a file system doesn't implement it directly.
+// The zero Policy emulates everything it can, which is the default.
+// Package helpers like Chmod() use CurrentPolicy(). Call the Policy's methods of the same name, like Policy{NoChmodFallback: true}.Chmod(), to choose a Policy for a single call.
+//
+// Emulation can behave differently than a native implementation. For example, Chmod() falls back to opening the file read-only and calling file.Chmod(), which some file systems allow even though the FS has no Chmod.
+// Fail-fast helpers return an error satisfying IsNotImplemented() instead, so callers can decide what to do.
+type Policy struct {
+	NoChmodFallback     bool // NoChmodFallback makes Chmod() fail instead of opening the file and calling file.Chmod()
+	NoChownFallback     bool // NoChownFallback makes Chown() fail instead of opening the file and calling file.Chown()
+	NoChtimesFallback   bool // NoChtimesFallback makes Chtimes() fail instead of opening the file and calling file.Chtimes()
+	NoMkdirAllFallback  bool // NoMkdirAllFallback makes MkdirAll() fail instead of calling Mkdir() for each missing directory
+	NoRemoveAllFallback bool // NoRemoveAllFallback makes RemoveAll() fail instead of removing each file and directory in the tree
+}
+
+var policy atomic.Value // Policy
+
+// SetPolicy replaces the Policy used by all package helpers and returns the previous one, so it can be restored later.
+// It's safe for concurrent use, though helpers already running may finish with the previous Policy.
+// SetPolicy is meant for applications. Libraries must not call it, since it changes the behavior of every caller in the program. Use the Policy's methods instead.
+func SetPolicy(p Policy) Policy {
+	previous, _ := policy.Swap(p).(Policy)
+	return previous
+}
+
+// CurrentPolicy returns the Policy used by all package helpers
+func CurrentPolicy() Policy {
+	p, _ := policy.Load().(Policy)
+	return p
+}
+
+// IsNotImplemented returns true if 'err' is or wraps ErrNotImplemented, like when a file system doesn't support an operation and no fallback was attempted
+func IsNotImplemented(err error) bool {
+	return errors.Is(err, ErrNotImplemented)
+}
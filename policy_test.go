@@ -0,0 +1,104 @@
+package hackpadfs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+// mkdirOnlyFS hides every optional interface except MkdirFS and RemoveFS, so helpers use their fallbacks
+type mkdirOnlyFS struct {
+	fs *mem.FS
+}
+
+func (fs *mkdirOnlyFS) Open(name string) (hackpadfs.File, error) {
+	return fs.fs.Open(name)
+}
+
+func (fs *mkdirOnlyFS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return fs.fs.Mkdir(name, perm)
+}
+
+func (fs *mkdirOnlyFS) Remove(name string) error {
+	return fs.fs.Remove(name)
+}
+
+func TestIsNotImplemented(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(hackpadfs.ErrNotImplemented))
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(&hackpadfs.PathError{Op: "chmod", Path: "foo", Err: hackpadfs.ErrNotImplemented}))
+	assert.Equal(t, false, hackpadfs.IsNotImplemented(hackpadfs.ErrNotExist))
+	assert.Equal(t, false, hackpadfs.IsNotImplemented(nil))
+	assert.Equal(t, false, hackpadfs.IsNotImplemented(errors.New("not implemented")))
+}
+
+func TestPolicyMethods(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.WriteFullFile(memFS, "file", nil, 0600))
+	fs := &mkdirOnlyFS{fs: memFS}
+	p := hackpadfs.Policy{
+		NoChmodFallback:     true,
+		NoChownFallback:     true,
+		NoChtimesFallback:   true,
+		NoMkdirAllFallback:  true,
+		NoRemoveAllFallback: true,
+	}
+
+	err = p.Chmod(fs, "file", 0644)
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(err))
+	info, err := hackpadfs.Stat(fs, "file")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0600), info.Mode())
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(p.Chown(fs, "file", 0, 0)))
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(p.Chtimes(fs, "file", info.ModTime(), info.ModTime())))
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(p.MkdirAll(fs, "a/b", 0700)))
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(p.RemoveAll(fs, "file")))
+
+	// the zero Policy emulates, regardless of CurrentPolicy()
+	assert.NoError(t, hackpadfs.Policy{}.Chmod(fs, "file", 0644))
+	assert.NoError(t, hackpadfs.Policy{}.MkdirAll(fs, "a/b", 0700))
+	assert.NoError(t, hackpadfs.Policy{}.RemoveAll(fs, "a"))
+}
+
+// TestPolicy isn't parallel, since it changes the global Policy. Parallel tests resume after it returns.
+func TestPolicy(t *testing.T) {
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.WriteFullFile(memFS, "file", nil, 0600))
+	fs := &mkdirOnlyFS{fs: memFS}
+
+	assert.Equal(t, hackpadfs.Policy{}, hackpadfs.CurrentPolicy())
+	assert.NoError(t, hackpadfs.Chmod(fs, "file", 0644))
+	assert.NoError(t, hackpadfs.MkdirAll(fs, "a/b", 0700))
+	assert.NoError(t, hackpadfs.RemoveAll(fs, "a"))
+
+	previous := hackpadfs.SetPolicy(hackpadfs.Policy{
+		NoChmodFallback:     true,
+		NoChownFallback:     true,
+		NoChtimesFallback:   true,
+		NoMkdirAllFallback:  true,
+		NoRemoveAllFallback: true,
+	})
+	defer hackpadfs.SetPolicy(previous)
+	assert.Equal(t, hackpadfs.Policy{}, previous)
+
+	err = hackpadfs.Chmod(fs, "file", 0600)
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(err))
+	info, err := hackpadfs.Stat(fs, "file")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0644), info.Mode())
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(hackpadfs.Chown(fs, "file", 0, 0)))
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(hackpadfs.Chtimes(fs, "file", info.ModTime(), info.ModTime())))
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(hackpadfs.MkdirAll(fs, "a/b", 0700)))
+	assert.Equal(t, true, hackpadfs.IsNotImplemented(hackpadfs.RemoveAll(fs, "file")))
+
+	// file systems implementing an operation directly are unaffected
+	assert.NoError(t, hackpadfs.Chmod(memFS, "file", 0600))
+	assert.NoError(t, hackpadfs.MkdirAll(memFS, "a/b", 0700))
+	assert.NoError(t, hackpadfs.RemoveAll(memFS, "a"))
+}
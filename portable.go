@@ -0,0 +1,94 @@
+package hackpadfs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// WindowsNameError is returned when a path element is invalid on Windows. Unwraps to ErrInvalid.
+type WindowsNameError struct {
+	Name   string // Name is the invalid path element
+	Reason string
+}
+
+func (e *WindowsNameError) Error() string {
+	return "invalid name on Windows " + strconv.Quote(e.Name) + ": " + e.Reason
+}
+
+// Unwrap supports errors.Unwrap().
+func (e *WindowsNameError) Unwrap() error {
+	return ErrInvalid
+}
+
+const windowsInvalidChars = `<>:"|?*\`
+
+// CheckWindowsPath returns a *WindowsNameError if any element of 'name' is invalid on Windows.
+// Elements must not be a reserved device name like CON or NUL, even with an extension, must not end in a dot or space, and must not contain control characters or any of <>:"|?*\
+//
+// Paths which are valid on Windows may still be rejected by ValidPath(), which is checked separately.
+func CheckWindowsPath(name string) error {
+	if name == "." {
+		return nil
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if reason := invalidWindowsName(elem); reason != "" {
+			return &WindowsNameError{Name: elem, Reason: reason}
+		}
+	}
+	return nil
+}
+
+func invalidWindowsName(elem string) string {
+	if i := strings.IndexFunc(elem, isInvalidWindowsChar); i != -1 {
+		return "contains invalid character " + strconv.QuoteRune(rune(elem[i]))
+	}
+	if strings.HasSuffix(elem, ".") || strings.HasSuffix(elem, " ") {
+		return "ends with a dot or space"
+	}
+	if isReservedWindowsName(elem) {
+		return "reserved device name"
+	}
+	return ""
+}
+
+func isInvalidWindowsChar(r rune) bool {
+	return r < ' ' || strings.ContainsRune(windowsInvalidChars, r)
+}
+
+// isReservedWindowsName returns true if 'elem' refers to a device, like "CON", "nul.txt", or "COM1 .log"
+func isReservedWindowsName(elem string) bool {
+	base, _, _ := strings.Cut(elem, ".")
+	base = strings.ToUpper(strings.TrimRight(base, " "))
+	switch base {
+	case "CON", "PRN", "AUX", "NUL", "CONIN$", "CONOUT$":
+		return true
+	}
+	if len(base) == 4 && (strings.HasPrefix(base, "COM") || strings.HasPrefix(base, "LPT")) {
+		return base[3] >= '1' && base[3] <= '9'
+	}
+	return false
+}
+
+// SanitizeWindowsPath returns 'name' with each element changed to be valid on Windows, for use with CheckWindowsPath().
+// Invalid characters are replaced with '_', trailing dots and spaces are removed, and reserved device names are prefixed with '_'.
+// Sanitizing a valid path returns it unchanged. Sanitizing a path which passes ValidPath() returns a path which still passes ValidPath().
+func SanitizeWindowsPath(name string) string {
+	if name == "." {
+		return name
+	}
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		elem = strings.Map(func(r rune) rune {
+			if isInvalidWindowsChar(r) {
+				return '_'
+			}
+			return r
+		}, elem)
+		elem = strings.TrimRight(elem, ". ")
+		if elem == "" || isReservedWindowsName(elem) {
+			elem = "_" + elem
+		}
+		elems[i] = elem
+	}
+	return strings.Join(elems, "/")
+}
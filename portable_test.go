@@ -0,0 +1,67 @@
+package hackpadfs
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestCheckWindowsPath(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name      string
+		expectErr string
+	}{
+		{name: "."},
+		{name: "foo/bar.txt"},
+		{name: "console/nul_file/.hidden"},
+		{name: "COM0"},
+		{name: "CON", expectErr: `invalid name on Windows "CON": reserved device name`},
+		{name: "foo/nul.txt", expectErr: `invalid name on Windows "nul.txt": reserved device name`},
+		{name: "Lpt1 .log", expectErr: `invalid name on Windows "Lpt1 .log": reserved device name`},
+		{name: "foo./bar", expectErr: `invalid name on Windows "foo.": ends with a dot or space`},
+		{name: "foo ", expectErr: `invalid name on Windows "foo ": ends with a dot or space`},
+		{name: "a:b", expectErr: `invalid name on Windows "a:b": contains invalid character ':'`},
+		{name: `a\b`, expectErr: `invalid name on Windows "a\\b": contains invalid character '\\'`},
+		{name: "a\tb", expectErr: `invalid name on Windows "a\tb": contains invalid character '\t'`},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := CheckWindowsPath(tc.name)
+			if tc.expectErr == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.name, SanitizeWindowsPath(tc.name))
+				return
+			}
+			assert.Error(t, err)
+			assert.Equal(t, tc.expectErr, err.Error())
+			assert.ErrorIs(t, ErrInvalid, err)
+		})
+	}
+}
+
+func TestSanitizeWindowsPath(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name   string
+		expect string
+	}{
+		{name: ".", expect: "."},
+		{name: "foo/bar", expect: "foo/bar"},
+		{name: "CON", expect: "_CON"},
+		{name: "aux.tar.gz/com9", expect: "_aux.tar.gz/_com9"},
+		{name: "what?/file. .", expect: "what_/file"},
+		{name: "...", expect: "_"},
+		{name: `<a|b>*"c"`, expect: "_a_b___c_"},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			sanitized := SanitizeWindowsPath(tc.name)
+			assert.Equal(t, tc.expect, sanitized)
+			assert.NoError(t, CheckWindowsPath(sanitized))
+			assert.Equal(t, true, ValidPath(sanitized))
+		})
+	}
+}
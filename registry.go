@@ -0,0 +1,81 @@
+package hackpadfs
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Factory creates a file system from a URL, like "mem:" or "os:/var/data". See Register().
+type Factory func(url string) (FS, error)
+
+var (
+	factoriesMu sync.Mutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes 'factory' available to New() for URLs with 'scheme', like "mem" for "mem:" URLs.
+// File system packages call Register from an init func, so importing them makes their scheme available. e.g. mem registers "mem" and os registers "os".
+// Panics if 'scheme' is already registered or 'factory' is nil.
+func Register(scheme string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("hackpadfs: Register factory is nil")
+	}
+	scheme = strings.ToLower(scheme) // schemes are case-insensitive
+	if _, exists := factories[scheme]; exists {
+		panic("hackpadfs: Register called twice for scheme " + scheme)
+	}
+	factories[scheme] = factory
+}
+
+// Schemes returns the sorted list of registered URL schemes
+func Schemes() []string {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	schemes := make([]string, 0, len(factories))
+	for scheme := range factories {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// New returns a new file system for 'url', using the Factory registered for its scheme. Useful for accepting any supported file system location from a flag or config file.
+//
+// The whole URL is passed to the Factory, which parses the rest. Fails with ErrInvalid if 'url' has no scheme.
+func New(url string) (FS, error) {
+	const op = "new"
+	scheme, ok := urlScheme(url)
+	if !ok {
+		return nil, &PathError{Op: op, Path: url, Err: ErrInvalid}
+	}
+	factoriesMu.Lock()
+	factory, ok := factories[scheme]
+	factoriesMu.Unlock()
+	if !ok {
+		return nil, &PathError{Op: op, Path: url, Err: errors.New("no file system registered for URL scheme " + strconv.Quote(scheme))}
+	}
+	return factory(url)
+}
+
+// urlScheme returns the scheme of 'url', the letters, digits, '+', '-', and '.' before the first ':', starting with a letter. See RFC 3986.
+func urlScheme(url string) (string, bool) {
+	end := strings.IndexByte(url, ':')
+	if end < 1 {
+		return "", false
+	}
+	scheme := url[:end]
+	for i, r := range scheme {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && ('0' <= r && r <= '9' || r == '+' || r == '-' || r == '.'):
+		default:
+			return "", false
+		}
+	}
+	return strings.ToLower(scheme), true
+}
@@ -0,0 +1,53 @@
+package hackpadfs_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	fs, err := hackpadfs.New("mem:")
+	requireNoError(t, err)
+	assert.IsType(t, &mem.FS{}, fs)
+	fs, err = hackpadfs.New("MEM:")
+	requireNoError(t, err)
+	assert.IsType(t, &mem.FS{}, fs)
+
+	for _, url := range []string{"", "mem", ":mem", "1mem:", "m em:"} {
+		_, err := hackpadfs.New(url)
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	}
+	_, err = hackpadfs.New("unknown:foo")
+	var pathErr *hackpadfs.PathError
+	if assert.ErrorAs(t, &pathErr, err) {
+		assert.Equal(t, "new", pathErr.Op)
+		assert.Equal(t, "unknown:foo", pathErr.Path)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+	var gotURL string
+	hackpadfs.Register("test-scheme+v1", func(url string) (hackpadfs.FS, error) {
+		gotURL = url
+		return mem.NewFS()
+	})
+	schemes := hackpadfs.Schemes()
+	assert.Equal(t, true, sort.StringsAreSorted(schemes))
+	i := sort.SearchStrings(schemes, "test-scheme+v1")
+	assert.Equal(t, true, i < len(schemes) && schemes[i] == "test-scheme+v1")
+
+	_, err := hackpadfs.New("test-scheme+v1://host/path?query=1")
+	requireNoError(t, err)
+	assert.Equal(t, "test-scheme+v1://host/path?query=1", gotURL)
+
+	defer func() {
+		assert.Equal(t, "hackpadfs: Register called twice for scheme mem", recover())
+	}()
+	hackpadfs.Register("Mem", func(string) (hackpadfs.FS, error) { return nil, nil })
+}
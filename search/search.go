@@ -0,0 +1,206 @@
+// Package search finds text in the files of any FS, reading files in parallel.
+package search
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// Defaults for Options
+const (
+	DefaultWorkers     = 4
+	DefaultMaxFileSize = 10 << 20
+)
+
+// binaryCheckSize is the number of bytes checked for a NUL byte to detect binary files, the same as git
+const binaryCheckSize = 8000
+
+// Options contain options for Search()
+type Options struct {
+	// Literal is the text to search for. Exactly one of Literal or Regexp must be set.
+	Literal string
+	// Regexp is the regular expression to search for. Matches can't span lines.
+	Regexp *regexp.Regexp
+	// Workers is the number of files read and searched concurrently. Defaults to DefaultWorkers.
+	Workers int
+	// MaxFileSize skips files larger than this many bytes. Defaults to DefaultMaxFileSize. Set to a negative number to search files of any size.
+	MaxFileSize int64
+	// IncludeBinary searches binary files too. By default, files with a NUL byte near the beginning are skipped.
+	IncludeBinary bool
+	// Filter skips searching the paths it returns false for. See hackpadfs.WalkDirOptions.
+	Filter hackpadfs.Filter
+}
+
+// Match is a match found in a file
+type Match struct {
+	Path   string
+	Line   int    // Line is the match's 1-based line number
+	Column int    // Column is the match's 1-based byte offset in its line
+	Text   string // Text is the full line containing the match, without the line ending
+}
+
+// Search searches the regular files at and under 'root' in 'fs'. Returns matches sorted by path, line, and column.
+// Files are read with hackpadfs.ReadFile(), which uses an FS's ReadFileFS implementation if available.
+// Files removed during the search or that can't be read for lack of permission are skipped. Other errors stop the search.
+func Search(ctx context.Context, fs hackpadfs.FS, root string, options Options) ([]Match, error) {
+	if (options.Literal == "") == (options.Regexp == nil) {
+		return nil, errors.New("search: exactly one of Literal or Regexp is required")
+	}
+	if options.Workers <= 0 {
+		options.Workers = DefaultWorkers
+	}
+	if options.MaxFileSize == 0 {
+		options.MaxFileSize = DefaultMaxFileSize
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		matches  []Match
+		firstErr error
+	)
+	setErr := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+		cancel()
+	}
+
+	names := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				fileMatches, err := searchFile(fs, name, options)
+				if isSkippable(err) {
+					continue
+				}
+				if err != nil {
+					setErr(err)
+					continue
+				}
+				mu.Lock()
+				matches = append(matches, fileMatches...)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	walkErr := hackpadfs.WalkDirWithOptions(fs, root, func(name string, dirEntry hackpadfs.DirEntry, err error) error {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if !dirEntry.Type().IsRegular() {
+			return nil
+		}
+		if options.MaxFileSize > 0 {
+			info, err := dirEntry.Info()
+			if isSkippable(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if info.Size() > options.MaxFileSize {
+				return nil
+			}
+		}
+		select {
+		case names <- name:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, hackpadfs.WalkDirOptions{Filter: options.Filter})
+	close(names)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	sort.Slice(matches, func(a, b int) bool {
+		matchA, matchB := matches[a], matches[b]
+		switch {
+		case matchA.Path != matchB.Path:
+			return matchA.Path < matchB.Path
+		case matchA.Line != matchB.Line:
+			return matchA.Line < matchB.Line
+		default:
+			return matchA.Column < matchB.Column
+		}
+	})
+	return matches, nil
+}
+
+// isSkippable returns true if 'err' only prevents searching a single file
+func isSkippable(err error) bool {
+	return errors.Is(err, hackpadfs.ErrNotExist) || errors.Is(err, hackpadfs.ErrPermission)
+}
+
+// searchFile returns the matches in the file 'name'
+func searchFile(fs hackpadfs.FS, name string, options Options) ([]Match, error) {
+	contents, err := hackpadfs.ReadFile(fs, name)
+	if err != nil {
+		return nil, err
+	}
+	if !options.IncludeBinary && isBinary(contents) {
+		return nil, nil
+	}
+	var matches []Match
+	for lineIndex, line := range bytes.Split(contents, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		for _, column := range findAll(line, options) {
+			matches = append(matches, Match{
+				Path:   name,
+				Line:   lineIndex + 1,
+				Column: column + 1,
+				Text:   string(line),
+			})
+		}
+	}
+	return matches, nil
+}
+
+// findAll returns the 0-based offsets of each non-overlapping match in 'line'
+func findAll(line []byte, options Options) []int {
+	var offsets []int
+	if options.Regexp != nil {
+		for _, loc := range options.Regexp.FindAllIndex(line, -1) {
+			offsets = append(offsets, loc[0])
+		}
+		return offsets
+	}
+	literal := []byte(options.Literal)
+	for offset := 0; ; {
+		i := bytes.Index(line[offset:], literal)
+		if i == -1 {
+			return offsets
+		}
+		offsets = append(offsets, offset+i)
+		offset += i + len(literal)
+	}
+}
+
+func isBinary(contents []byte) bool {
+	if len(contents) > binaryCheckSize {
+		contents = contents[:binaryCheckSize]
+	}
+	return bytes.IndexByte(contents, 0) != -1
+}
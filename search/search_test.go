@@ -0,0 +1,130 @@
+package search
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func makeFS(tb testing.TB) *mem.FS {
+	tb.Helper()
+	fs, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	requireNoError := func(err error) {
+		tb.Helper()
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+	}
+	requireNoError(fs.MkdirAll("src/sub", 0700))
+	for name, contents := range map[string]string{
+		"src/a.txt":     "foo bar\r\nbaz foo foo\n",
+		"src/sub/b.txt": "nothing here\nfood\n",
+		"src/binary":    "foo\x00",
+		"src/large":     strings.Repeat("foo ", 100),
+		"other.txt":     "foo",
+	} {
+		requireNoError(hackpadfs.WriteFullFile(fs, name, []byte(contents), 0600))
+	}
+	return fs
+}
+
+func TestSearchLiteral(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	matches, err := Search(context.Background(), fs, "src", Options{
+		Literal:     "foo",
+		MaxFileSize: 100,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{
+		{Path: "src/a.txt", Line: 1, Column: 1, Text: "foo bar"},
+		{Path: "src/a.txt", Line: 2, Column: 5, Text: "baz foo foo"},
+		{Path: "src/a.txt", Line: 2, Column: 9, Text: "baz foo foo"},
+		{Path: "src/sub/b.txt", Line: 2, Column: 1, Text: "food"},
+	}, matches)
+}
+
+func TestSearchRegexp(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	matches, err := Search(context.Background(), fs, ".", Options{
+		Regexp:  regexp.MustCompile(`\bfoo\b`),
+		Workers: 1,
+		Filter: func(name string, _ hackpadfs.DirEntry) (bool, error) {
+			return name != "src/large", nil
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{
+		{Path: "other.txt", Line: 1, Column: 1, Text: "foo"},
+		{Path: "src/a.txt", Line: 1, Column: 1, Text: "foo bar"},
+		{Path: "src/a.txt", Line: 2, Column: 5, Text: "baz foo foo"},
+		{Path: "src/a.txt", Line: 2, Column: 9, Text: "baz foo foo"},
+	}, matches)
+}
+
+func TestSearchBinaryAndSize(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	matches, err := Search(context.Background(), fs, "src", Options{
+		Literal:       "foo",
+		IncludeBinary: true,
+		MaxFileSize:   -1,
+	})
+	assert.NoError(t, err)
+	counts := make(map[string]int)
+	for _, match := range matches {
+		counts[match.Path]++
+	}
+	assert.Equal(t, map[string]int{"src/a.txt": 3, "src/binary": 1, "src/large": 100, "src/sub/b.txt": 1}, counts)
+}
+
+func TestSearchErrors(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	_, err := Search(context.Background(), fs, ".", Options{})
+	assert.Error(t, err)
+	_, err = Search(context.Background(), fs, ".", Options{Literal: "foo", Regexp: regexp.MustCompile("foo")})
+	assert.Error(t, err)
+	_, err = Search(context.Background(), fs, "missing", Options{Literal: "foo"})
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Search(ctx, fs, ".", Options{Literal: "foo"})
+	assert.ErrorIs(t, context.Canceled, err)
+}
+
+// removingFS removes the file 'name' just before it's opened, like a file deleted mid-search
+type removingFS struct {
+	*mem.FS
+	name string
+}
+
+func (fs *removingFS) Open(name string) (hackpadfs.File, error) {
+	if name == fs.name {
+		_ = fs.FS.Remove(name)
+	}
+	return fs.FS.Open(name)
+}
+
+func TestSearchSkipsRemovedFiles(t *testing.T) {
+	t.Parallel()
+	fs := &removingFS{FS: makeFS(t), name: "src/a.txt"}
+	matches, err := Search(context.Background(), fs, "src", Options{
+		Literal:     "foo",
+		MaxFileSize: 100,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{
+		{Path: "src/sub/b.txt", Line: 2, Column: 1, Text: "food"},
+	}, matches)
+}
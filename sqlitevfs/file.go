@@ -0,0 +1,95 @@
+package sqlitevfs
+
+import (
+	"errors"
+	"io"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/blockdev"
+)
+
+// File is an open SQLite file, like a database or journal
+type File struct {
+	vfs           *VFS
+	name          string
+	device        *blockdev.Device
+	readOnly      bool
+	deleteOnClose bool
+	closed        bool
+
+	shared *sharedFile
+	lock   LockType // lock is protected by shared.mu
+}
+
+// ReadAt reads len(p) bytes at 'off'. Reads past the end of the file zero the rest of 'p' and return io.EOF, which bindings should report as SQLITE_IOERR_SHORT_READ.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.device.ReadAt(p, off)
+	if err == io.EOF {
+		for i := n; i < len(p); i++ {
+			p[i] = 0
+		}
+	}
+	return n, err
+}
+
+// WriteAt writes 'p' at 'off', growing the file if needed
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	if f.readOnly {
+		return 0, &hackpadfs.PathError{Op: "writeat", Path: f.name, Err: hackpadfs.ErrPermission}
+	}
+	if end := off + int64(len(p)); end > f.device.Size() {
+		if err := f.device.Truncate(end); err != nil {
+			return 0, err
+		}
+	}
+	return f.device.WriteAt(p, off)
+}
+
+// Truncate resizes the file to 'size' bytes
+func (f *File) Truncate(size int64) error {
+	if f.readOnly {
+		return &hackpadfs.PathError{Op: "truncate", Path: f.name, Err: hackpadfs.ErrPermission}
+	}
+	return f.device.Truncate(size)
+}
+
+// Sync writes buffered changes to the FS. SQLite's SQLITE_SYNC_* flags are ignored.
+func (f *File) Sync(flags int) error {
+	return f.device.Sync()
+}
+
+// FileSize returns the size of the file in bytes
+func (f *File) FileSize() (int64, error) {
+	return f.device.Size(), nil
+}
+
+// SectorSize returns the sector size configured in Options.SectorSize
+func (f *File) SectorSize() int64 {
+	return f.vfs.options.SectorSize
+}
+
+// DeviceCharacteristics returns SQLite's SQLITE_IOCAP_* flags for the file. None are claimed, so SQLite uses its safest defaults.
+func (f *File) DeviceCharacteristics() int {
+	return 0
+}
+
+// Close releases any locks and closes the file, deleting it if it was opened with OpenDeleteOnClose.
+// The path's device is synced and closed once its last File is closed.
+func (f *File) Close() error {
+	if f.closed {
+		return &hackpadfs.PathError{Op: "close", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	f.closed = true
+	unlockErr := f.Unlock(LockNone)
+	err := f.vfs.release(f.shared)
+	if err == nil && f.deleteOnClose {
+		err = hackpadfs.Remove(f.vfs.fs, f.name)
+		if errors.Is(err, hackpadfs.ErrNotExist) {
+			err = nil
+		}
+	}
+	if unlockErr != nil {
+		return unlockErr
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package sqlitevfs
+
+import (
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/blockdev"
+)
+
+// LockType is an SQLITE_LOCK_* level
+type LockType int
+
+// Lock levels, with the same values as SQLite's SQLITE_LOCK_* constants
+const (
+	LockNone      LockType = 0
+	LockShared    LockType = 1
+	LockReserved  LockType = 2
+	LockPending   LockType = 3
+	LockExclusive LockType = 4
+)
+
+// sharedFile is the device and lock state of a path, shared by every File open on it
+type sharedFile struct {
+	name     string
+	device   *blockdev.Device
+	readOnly bool
+	refs     int // refs is the number of open Files, protected by VFS.mu
+
+	mu          sync.Mutex
+	sharedLocks int   // sharedLocks is the number of Files holding a shared lock or higher
+	reserved    *File // reserved holds the Files with each lock above shared, if any
+	pending     *File
+	exclusive   *File
+}
+
+// Lock raises the file's lock to 'level', following SQLite's locking protocol:
+//   - Shared locks allow reading, and fail with ErrBusy while another file holds a pending or exclusive lock
+//   - A single reserved lock signals the intent to write, while others keep reading
+//   - Requesting an exclusive lock first takes a pending lock, blocking new shared locks. The exclusive lock is granted once all other shared locks are released.
+//
+// Pending locks can't be requested directly.
+func (f *File) Lock(level LockType) error {
+	state := f.shared
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if f.lock >= level {
+		return nil
+	}
+	switch level {
+	case LockShared:
+		if state.pending != nil || state.exclusive != nil {
+			return f.busy()
+		}
+		state.sharedLocks++
+	case LockReserved:
+		if f.lock != LockShared {
+			return &hackpadfs.PathError{Op: "lock", Path: f.name, Err: hackpadfs.ErrInvalid}
+		}
+		if state.reserved != nil || state.exclusive != nil {
+			return f.busy()
+		}
+		state.reserved = f
+	case LockExclusive:
+		if f.lock < LockShared {
+			return &hackpadfs.PathError{Op: "lock", Path: f.name, Err: hackpadfs.ErrInvalid}
+		}
+		if (state.reserved != nil && state.reserved != f) || (state.pending != nil && state.pending != f) {
+			return f.busy()
+		}
+		state.pending = f
+		if state.sharedLocks > 1 {
+			f.lock = LockPending
+			return f.busy()
+		}
+		state.exclusive = f
+	default:
+		return &hackpadfs.PathError{Op: "lock", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	f.lock = level
+	return nil
+}
+
+// Unlock lowers the file's lock to 'level', which must be LockShared or LockNone
+func (f *File) Unlock(level LockType) error {
+	if level != LockShared && level != LockNone {
+		return &hackpadfs.PathError{Op: "unlock", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	state := f.shared
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if f.lock <= level {
+		return nil
+	}
+	if state.reserved == f {
+		state.reserved = nil
+	}
+	if state.pending == f {
+		state.pending = nil
+	}
+	if state.exclusive == f {
+		state.exclusive = nil
+	}
+	if level == LockNone {
+		state.sharedLocks--
+	}
+	f.lock = level
+	return nil
+}
+
+// CheckReservedLock returns true if any file holds a reserved, pending, or exclusive lock on this path
+func (f *File) CheckReservedLock() (bool, error) {
+	state := f.shared
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.reserved != nil || state.pending != nil || state.exclusive != nil, nil
+}
+
+// busy returns an ErrBusy error
+func (f *File) busy() error {
+	return &hackpadfs.PathError{Op: "lock", Path: f.name, Err: ErrBusy}
+}
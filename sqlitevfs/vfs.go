@@ -0,0 +1,211 @@
+// Package sqlitevfs adapts any FS to SQLite's virtual file system (VFS) interface, so SQLite databases can be persisted in backends like indexeddb.FS.
+//
+// Go's SQLite bindings each declare their own VFS interfaces, so this package doesn't depend on any of them.
+// Instead, VFS and File mirror the methods of SQLite's sqlite3_vfs and sqlite3_io_methods, and the flag constants match SQLite's C values.
+// Registering a VFS with a binding, like github.com/psanford/sqlite3vfs for wasm builds of SQLite, only needs a thin wrapper converting between the binding's types.
+//
+// Files are accessed through blockdev Devices, optionally with a page cache. Every File open on the same path shares its Device, so connections see each other's writes.
+// Locks follow SQLite's locking protocol, but only between connections using the same VFS. They don't coordinate separate processes or browser tabs.
+package sqlitevfs
+
+import (
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/blockdev"
+)
+
+// OpenFlag is a set of SQLITE_OPEN_* flags
+type OpenFlag int
+
+// Open flags, with the same values as SQLite's SQLITE_OPEN_* constants
+const (
+	OpenReadOnly      OpenFlag = 0x00000001
+	OpenReadWrite     OpenFlag = 0x00000002
+	OpenCreate        OpenFlag = 0x00000004
+	OpenDeleteOnClose OpenFlag = 0x00000008
+	OpenExclusive     OpenFlag = 0x00000010
+	OpenMainDB        OpenFlag = 0x00000100
+	OpenTempDB        OpenFlag = 0x00000200
+	OpenTransientDB   OpenFlag = 0x00000400
+	OpenMainJournal   OpenFlag = 0x00000800
+	OpenTempJournal   OpenFlag = 0x00001000
+	OpenSubJournal    OpenFlag = 0x00002000
+	OpenSuperJournal  OpenFlag = 0x00004000
+	OpenWAL           OpenFlag = 0x00080000
+)
+
+// AccessFlag is an SQLITE_ACCESS_* check
+type AccessFlag int
+
+// Access checks, with the same values as SQLite's SQLITE_ACCESS_* constants
+const (
+	AccessExists    AccessFlag = 0
+	AccessReadWrite AccessFlag = 1
+	AccessRead      AccessFlag = 2
+)
+
+// ErrBusy is returned when a lock is held by another connection. Bindings should report it as SQLITE_BUSY.
+var ErrBusy = errors.New("database is locked")
+
+// Options contain options for creating a VFS
+type Options struct {
+	// PageSize is the size of cached pages, ideally matching the database's page size. Defaults to blockdev.DefaultPageSize.
+	PageSize int
+	// CachePages is the number of pages cached in memory per open file. Defaults to 0, which disables the cache.
+	CachePages int
+	// TempDir is the directory for temporary files SQLite opens without a name. Defaults to ".".
+	TempDir string
+	// SectorSize is reported to SQLite as the storage's sector size. Defaults to 4096.
+	SectorSize int64
+}
+
+// VFS stores SQLite files in an FS
+type VFS struct {
+	fs      hackpadfs.FS
+	options Options
+
+	mu        sync.Mutex
+	files     map[string]*sharedFile // files holds the state of each open path
+	tempCount int
+}
+
+// NewVFS returns a new VFS storing files in 'fs'
+func NewVFS(fs hackpadfs.FS, options Options) (*VFS, error) {
+	if options.TempDir == "" {
+		options.TempDir = "."
+	}
+	if options.SectorSize <= 0 {
+		options.SectorSize = 4096
+	}
+	if !hackpadfs.ValidPath(options.TempDir) {
+		return nil, &hackpadfs.PathError{Op: "vfs", Path: options.TempDir, Err: hackpadfs.ErrInvalid}
+	}
+	return &VFS{
+		fs:      fs,
+		options: options,
+		files:   make(map[string]*sharedFile),
+	}, nil
+}
+
+// FullPathname returns the canonical FS path for 'name', used as the name of every other operation.
+// Absolute paths are made relative to the FS root, since FS paths are unrooted.
+func (v *VFS) FullPathname(name string) string {
+	name = strings.TrimLeft(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// Open opens the file 'name' with 'flags', returning the file and the flags it was actually opened with.
+// An empty name opens a new temporary file, which is deleted on close.
+func (v *VFS) Open(name string, flags OpenFlag) (*File, OpenFlag, error) {
+	if name == "" {
+		name = v.tempName()
+		flags |= OpenDeleteOnClose | OpenCreate
+	}
+	readOnly := flags&OpenReadOnly != 0 || flags&OpenReadWrite == 0
+	if flags&OpenExclusive != 0 && flags&OpenCreate != 0 {
+		if _, err := hackpadfs.Stat(v.fs, name); err == nil {
+			return nil, 0, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrExist}
+		}
+	}
+	if flags&OpenCreate == 0 && !readOnly {
+		if _, err := hackpadfs.Stat(v.fs, name); err != nil { // blockdev creates missing files opened for writing
+			return nil, 0, err
+		}
+	}
+
+	shared, err := v.acquire(name, readOnly)
+	if err != nil {
+		return nil, 0, err
+	}
+	outFlags := flags &^ (OpenReadOnly | OpenReadWrite)
+	if readOnly {
+		outFlags |= OpenReadOnly
+	} else {
+		outFlags |= OpenReadWrite
+	}
+	return &File{
+		vfs:           v,
+		name:          name,
+		device:        shared.device,
+		readOnly:      readOnly,
+		deleteOnClose: flags&OpenDeleteOnClose != 0,
+		shared:        shared,
+	}, outFlags, nil
+}
+
+// tempName returns a new, unique name for a temporary file
+func (v *VFS) tempName() string {
+	v.mu.Lock()
+	v.tempCount++
+	count := v.tempCount
+	v.mu.Unlock()
+	return path.Join(v.options.TempDir, ".sqlite-temp-"+strconv.Itoa(count))
+}
+
+// Delete removes the file 'name'. SQLite's syncDir flag is ignored, since FS operations complete when they return.
+func (v *VFS) Delete(name string, syncDir bool) error {
+	return hackpadfs.Remove(v.fs, name)
+}
+
+// Access returns true if the file 'name' exists and allows the access checked by 'flag'
+func (v *VFS) Access(name string, flag AccessFlag) (bool, error) {
+	info, err := hackpadfs.Stat(v.fs, name)
+	if errors.Is(err, hackpadfs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	switch flag {
+	case AccessReadWrite:
+		return info.Mode().Perm()&0600 == 0600, nil
+	case AccessRead:
+		return info.Mode().Perm()&0400 != 0, nil
+	default:
+		return true, nil
+	}
+}
+
+// acquire returns the shared state for 'name', opening its device if no other File has it open.
+// The device is only writable if the first File to open it is, so a later read-write open fails with hackpadfs.ErrPermission.
+func (v *VFS) acquire(name string, readOnly bool) (*sharedFile, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	shared, ok := v.files[name]
+	if !ok {
+		device, err := blockdev.Open(v.fs, name, blockdev.Options{
+			PageSize:   v.options.PageSize,
+			CachePages: v.options.CachePages,
+			ReadOnly:   readOnly,
+		})
+		if err != nil {
+			return nil, err
+		}
+		shared = &sharedFile{name: name, device: device, readOnly: readOnly}
+		v.files[name] = shared
+	} else if shared.readOnly && !readOnly {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrPermission}
+	}
+	shared.refs++
+	return shared, nil
+}
+
+// release drops a reference to 'shared', closing its device once its last File is closed
+func (v *VFS) release(shared *sharedFile) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	shared.refs--
+	if shared.refs > 0 {
+		return nil
+	}
+	delete(v.files, shared.name)
+	return shared.device.Close()
+}
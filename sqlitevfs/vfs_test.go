@@ -0,0 +1,177 @@
+package sqlitevfs
+
+import (
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newVFS(tb testing.TB, options Options) (*VFS, *mem.FS) {
+	tb.Helper()
+	fs, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	vfs, err := NewVFS(fs, options)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return vfs, fs
+}
+
+func openFile(tb testing.TB, vfs *VFS, name string, flags OpenFlag) *File {
+	tb.Helper()
+	f, _, err := vfs.Open(name, flags)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	tb.Cleanup(func() {
+		_ = f.Close()
+	})
+	return f
+}
+
+func TestFullPathname(t *testing.T) {
+	t.Parallel()
+	vfs, _ := newVFS(t, Options{})
+	for _, tc := range []struct {
+		name     string
+		expected string
+	}{
+		{name: "test.db", expected: "test.db"},
+		{name: "/data/test.db", expected: "data/test.db"},
+		{name: "data//../test.db", expected: "test.db"},
+		{name: "/", expected: "."},
+	} {
+		assert.Equal(t, tc.expected, vfs.FullPathname(tc.name))
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+	vfs, fs := newVFS(t, Options{})
+
+	_, _, err := vfs.Open("missing.db", OpenReadWrite|OpenMainDB)
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	_, _, err = vfs.Open("missing.db", OpenReadOnly|OpenMainDB)
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	f, outFlags, err := vfs.Open("test.db", OpenReadWrite|OpenCreate|OpenMainDB)
+	assert.NoError(t, err)
+	assert.Equal(t, OpenReadWrite|OpenCreate|OpenMainDB, outFlags)
+	assert.NoError(t, f.Close())
+
+	_, _, err = vfs.Open("test.db", OpenReadWrite|OpenCreate|OpenExclusive)
+	assert.ErrorIs(t, hackpadfs.ErrExist, err)
+
+	exists, err := vfs.Access("test.db", AccessExists)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exists)
+	writable, err := vfs.Access("test.db", AccessReadWrite)
+	assert.NoError(t, err)
+	assert.Equal(t, true, writable)
+	assert.NoError(t, vfs.Delete("test.db", true))
+	exists, err = vfs.Access("test.db", AccessExists)
+	assert.NoError(t, err)
+	assert.Equal(t, false, exists)
+
+	temp, _, err := vfs.Open("", OpenReadWrite|OpenTempJournal)
+	assert.NoError(t, err)
+	_, err = temp.WriteAt([]byte("temp"), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, temp.Close())
+	entries, err := hackpadfs.ReadDir(fs, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
+
+func TestReadWrite(t *testing.T) {
+	t.Parallel()
+	vfs, fs := newVFS(t, Options{PageSize: 16, CachePages: 2})
+	f := openFile(t, vfs, "test.db", OpenReadWrite|OpenCreate|OpenMainDB)
+
+	_, err := f.WriteAt([]byte("page 2"), 32)
+	assert.NoError(t, err)
+	size, err := f.FileSize()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(38), size)
+
+	buf := make([]byte, 8)
+	n, err := f.ReadAt(buf, 32)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, "page 2\x00\x00", string(buf))
+
+	assert.NoError(t, f.Sync(0))
+	contents, err := hackpadfs.ReadFile(fs, "test.db")
+	assert.NoError(t, err)
+	assert.Equal(t, "page 2", string(contents[32:]))
+
+	assert.NoError(t, f.Truncate(16))
+	size, err = f.FileSize()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(16), size)
+	assert.Equal(t, int64(4096), f.SectorSize())
+}
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+	vfs, _ := newVFS(t, Options{})
+	writer := openFile(t, vfs, "test.db", OpenReadWrite|OpenCreate|OpenMainDB)
+	reader := openFile(t, vfs, "test.db", OpenReadWrite|OpenMainDB)
+
+	assert.NoError(t, writer.Lock(LockShared))
+	assert.NoError(t, reader.Lock(LockShared))
+	assert.NoError(t, writer.Lock(LockReserved))
+	assert.ErrorIs(t, ErrBusy, reader.Lock(LockReserved))
+	reserved, err := reader.CheckReservedLock()
+	assert.NoError(t, err)
+	assert.Equal(t, true, reserved)
+
+	// the writer waits in a pending lock for the reader to finish, which blocks new readers
+	assert.ErrorIs(t, ErrBusy, writer.Lock(LockExclusive))
+	assert.Equal(t, LockPending, writer.lock)
+	assert.NoError(t, reader.Unlock(LockNone))
+	assert.ErrorIs(t, ErrBusy, reader.Lock(LockShared))
+	assert.NoError(t, writer.Lock(LockExclusive))
+	assert.Equal(t, LockExclusive, writer.lock)
+
+	assert.NoError(t, writer.Unlock(LockShared))
+	assert.NoError(t, reader.Lock(LockShared))
+	reserved, err = reader.CheckReservedLock()
+	assert.NoError(t, err)
+	assert.Equal(t, false, reserved)
+
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, reader.Lock(LockPending))
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, reader.Unlock(LockReserved))
+	assert.NoError(t, writer.Close())
+	assert.NoError(t, reader.Lock(LockReserved))
+}
+
+func TestLockSeesOtherConnectionsWrites(t *testing.T) {
+	t.Parallel()
+	vfs, _ := newVFS(t, Options{PageSize: 16, CachePages: 4})
+	a := openFile(t, vfs, "test.db", OpenReadWrite|OpenCreate|OpenMainDB)
+	b := openFile(t, vfs, "test.db", OpenReadWrite|OpenMainDB)
+
+	assert.NoError(t, b.Lock(LockShared))
+	buf := make([]byte, 5)
+	_, err := b.ReadAt(buf, 0)
+	assert.Equal(t, io.EOF, err)
+	assert.NoError(t, b.Unlock(LockNone))
+
+	assert.NoError(t, a.Lock(LockShared))
+	assert.NoError(t, a.Lock(LockExclusive))
+	_, err = a.WriteAt([]byte("hello"), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, a.Sync(0))
+	assert.NoError(t, a.Unlock(LockNone))
+
+	assert.NoError(t, b.Lock(LockShared))
+	_, err = b.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+}
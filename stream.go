@@ -0,0 +1,64 @@
+package hackpadfs
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/hack-pad/hackpadfs/internal/bufferpool"
+)
+
+// ReadFileLimit reads the whole file 'name', like ReadFile, but fails with ErrFileTooLarge if it's larger than 'max' bytes.
+// Reads stop after 'max' bytes even if the file's size was misreported or it grew, so untrusted files can't exhaust memory.
+func ReadFileLimit(fs FS, name string, max int64) ([]byte, error) {
+	if max < 0 {
+		return nil, &PathError{Op: "read", Path: name, Err: ErrInvalid}
+	}
+	file, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &PathError{Op: "read", Path: name, Err: ErrIsDir}
+	}
+	size := info.Size()
+	if size > max {
+		return nil, &PathError{Op: "read", Path: name, Err: ErrFileTooLarge}
+	}
+
+	var buf bytes.Buffer
+	if size > 0 {
+		buf.Grow(int(size))
+	}
+	_, err = bufferpool.Copies.Copy(&buf, io.LimitReader(file, max+1)) // read 1 extra byte to detect files longer than 'max'
+	if err != nil {
+		return nil, err
+	}
+	if int64(buf.Len()) > max {
+		return nil, &PathError{Op: "read", Path: name, Err: ErrFileTooLarge}
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFileFrom writes the contents of 'r' to the file 'name', like WriteFullFile, creating it with 'perm' if needed and replacing any existing contents.
+// Contents are streamed through a pooled buffer, or copied directly if the file implements io.ReaderFrom or 'r' implements io.WriterTo, so they never need to fit in memory at once.
+func WriteFileFrom(fs FS, name string, r io.Reader, perm FileMode) error {
+	file, err := OpenFile(fs, name, FlagWriteOnly|FlagCreate|FlagTruncate, perm)
+	if err != nil {
+		return err
+	}
+	if writer, ok := file.(io.Writer); ok {
+		_, err = bufferpool.Copies.Copy(writer, r)
+	} else {
+		err = &PathError{Op: "write", Path: name, Err: ErrNotImplemented}
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package hackpadfs_test
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestReadFileLimit(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "file", []byte("hello"), 0600))
+	requireNoError(t, hackpadfs.Mkdir(fs, "dir", 0700))
+
+	contents, err := hackpadfs.ReadFileLimit(fs, "file", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(contents))
+
+	_, err = hackpadfs.ReadFileLimit(fs, "file", 4)
+	assert.ErrorIs(t, hackpadfs.ErrFileTooLarge, err)
+	_, err = hackpadfs.ReadFileLimit(fs, "file", -1)
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	_, err = hackpadfs.ReadFileLimit(fs, "dir", 5)
+	assert.ErrorIs(t, hackpadfs.ErrIsDir, err)
+	_, err = hackpadfs.ReadFileLimit(fs, "missing", 5)
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestWriteFileFrom(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "file", []byte("old contents"), 0600))
+
+	// OneByteReader hides any io.WriterTo, so contents stream through a pooled buffer
+	err = hackpadfs.WriteFileFrom(fs, "file", iotest.OneByteReader(strings.NewReader("new")), 0600)
+	assert.NoError(t, err)
+	contents, err := hackpadfs.ReadFile(fs, "file")
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(contents))
+
+	err = hackpadfs.WriteFileFrom(fs, "created", strings.NewReader("created"), 0640)
+	assert.NoError(t, err)
+	info, err := hackpadfs.Stat(fs, "created")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0640), info.Mode())
+	assert.Equal(t, int64(len("created")), info.Size())
+
+	err = hackpadfs.WriteFileFrom(fs, "missing/file", strings.NewReader(""), 0600)
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
@@ -0,0 +1,53 @@
+package hackpadfs
+
+import (
+	"path"
+	"strings"
+)
+
+// EvalSymlinks returns 'name' after resolving all symlinks in it, like filepath.EvalSymlinks(). The result is a cleaned, valid path in 'fs'.
+// Symlinks are detected with LstatOrStat() and read with Readlink(), so an FS without symlinks only checks that 'name' exists.
+//
+// Resolution never leaves 'fs': Readlink() returns destinations as paths in 'fs', so each symlink resolves from the root of 'fs'. Fails with ErrInvalid if a destination isn't a valid path.
+func EvalSymlinks(fs FS, name string) (string, error) {
+	if !ValidPath(name) {
+		return "", &PathError{Op: "evalsymlinks", Path: name, Err: ErrInvalid}
+	}
+	resolved := "."
+	remaining := name // slash-separated path elements left to resolve
+	links := 0
+	for remaining != "" {
+		var elem string
+		elem, remaining, _ = strings.Cut(remaining, "/")
+		if elem == "." {
+			continue
+		}
+
+		candidate := path.Join(resolved, elem)
+		info, err := LstatOrStat(fs, candidate)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&ModeSymlink == 0 {
+			resolved = candidate
+			continue
+		}
+		links++
+		if links > maxFollowDepth {
+			return "", &PathError{Op: "evalsymlinks", Path: name, Err: ErrTooManyLinks}
+		}
+		target, err := Readlink(fs, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !ValidPath(target) {
+			return "", &PathError{Op: "evalsymlinks", Path: candidate, Err: ErrInvalid}
+		}
+		resolved = "."
+		if remaining != "" {
+			target += "/" + remaining
+		}
+		remaining = target
+	}
+	return resolved, nil
+}
@@ -0,0 +1,28 @@
+package hackpadfs_test
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestEvalSymlinksWithoutSymlinks(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+
+	resolved, err := hackpadfs.EvalSymlinks(fs, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo/bar", resolved)
+	resolved, err = hackpadfs.EvalSymlinks(fs, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, ".", resolved)
+
+	_, err = hackpadfs.EvalSymlinks(fs, "foo/baz")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	_, err = hackpadfs.EvalSymlinks(fs, "/foo")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
@@ -0,0 +1,120 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// State records the files on each side as of the last sync. Sync() uses it to tell which side changed a file, enabling deletes to propagate and interrupted syncs to resume.
+//
+// State is safe to encode as JSON for storage between syncs.
+type State struct {
+	Entries map[string]StateEntry `json:"entries"`
+}
+
+// StateEntry records a path's last synced entry on each side
+type StateEntry struct {
+	A *Entry `json:"a,omitempty"`
+	B *Entry `json:"b,omitempty"`
+}
+
+// Entry describes a file or directory found while scanning a file system
+type Entry struct {
+	IsDir   bool               `json:"isDir,omitempty"`
+	Size    int64              `json:"size"`
+	Mode    hackpadfs.FileMode `json:"mode"`
+	ModTime time.Time          `json:"modTime"`
+	Hash    string             `json:"hash,omitempty"` // Hash is the hex-encoded SHA-256 of the file's contents, if Options.Checksum is set
+}
+
+func (s *State) get(name string) StateEntry {
+	if s.Entries == nil {
+		return StateEntry{}
+	}
+	return s.Entries[name]
+}
+
+func (s *State) set(name string, a, b *Entry) {
+	if a == nil && b == nil {
+		delete(s.Entries, name)
+		return
+	}
+	if s.Entries == nil {
+		s.Entries = make(map[string]StateEntry)
+	}
+	s.Entries[name] = StateEntry{A: a, B: b}
+}
+
+// equal returns true if 'e' and 'other' describe the same contents
+func (e *Entry) equal(other *Entry) bool {
+	switch {
+	case e == nil || other == nil:
+		return e == other
+	case e.IsDir || other.IsDir:
+		return e.IsDir == other.IsDir
+	case e.Size != other.Size || e.Mode.Perm() != other.Mode.Perm():
+		return false
+	case e.Hash != "" || other.Hash != "":
+		return e.Hash == other.Hash
+	default:
+		return e.ModTime.Equal(other.ModTime)
+	}
+}
+
+func newEntry(fs hackpadfs.FS, name string, info hackpadfs.FileInfo, checksum bool) (*Entry, error) {
+	entry := &Entry{
+		IsDir:   info.IsDir(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+	}
+	if entry.IsDir {
+		return entry, nil
+	}
+	entry.Size = info.Size()
+	if checksum {
+		hash, err := hashFile(fs, name)
+		if err != nil {
+			return nil, err
+		}
+		entry.Hash = hash
+	}
+	return entry, nil
+}
+
+func hashFile(fs hackpadfs.FS, name string) (string, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// scan walks 'fs' and returns an entry for every path, excluding the root
+func scan(fs hackpadfs.FS, checksum bool) (map[string]*Entry, error) {
+	entries := make(map[string]*Entry)
+	err := hackpadfs.WalkDir(fs, ".", func(name string, dirEntry hackpadfs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+		entry, err := newEntry(fs, name, info, checksum)
+		if err != nil {
+			return err
+		}
+		entries[name] = entry
+		return nil
+	})
+	return entries, err
+}
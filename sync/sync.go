@@ -0,0 +1,331 @@
+// Package sync contains a two-way sync engine, reconciling the contents of any two file systems.
+package sync
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// Side identifies one of the two file systems being synced
+type Side int
+
+// Sides of a sync
+const (
+	SideA Side = iota + 1
+	SideB
+)
+
+func (s Side) String() string {
+	switch s {
+	case SideA:
+		return "A"
+	case SideB:
+		return "B"
+	default:
+		return "unknown"
+	}
+}
+
+func (s Side) other() Side {
+	if s == SideA {
+		return SideB
+	}
+	return SideA
+}
+
+// Op is a kind of sync action
+type Op int
+
+// Sync actions
+const (
+	OpCopy   Op = iota + 1 // copy a file or directory onto the destination side
+	OpRemove               // remove a file or directory from the destination side
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpCopy:
+		return "copy"
+	case OpRemove:
+		return "remove"
+	default:
+		return "unknown"
+	}
+}
+
+// Action is a change made to one side of a sync, or planned in dry-run mode
+type Action struct {
+	Op       Op
+	Path     string
+	To       Side // To is the side modified by this action
+	Conflict bool // Conflict is true if both sides changed and the action was chosen by Options.Resolve
+}
+
+// Conflict describes a path which changed on both sides since the last sync. A nil entry indicates the path was removed.
+type Conflict struct {
+	Path string
+	A, B *Entry
+}
+
+// Resolution is the outcome of resolving a Conflict
+type Resolution int
+
+// Conflict resolutions
+const (
+	Skip  Resolution = iota // leave both sides as they are, reporting the conflict again on the next sync
+	KeepA                   // overwrite B with A
+	KeepB                   // overwrite A with B
+)
+
+// Resolver decides how to resolve a Conflict
+type Resolver func(Conflict) Resolution
+
+// PreferA resolves every conflict by keeping side A
+func PreferA(Conflict) Resolution {
+	return KeepA
+}
+
+// PreferB resolves every conflict by keeping side B
+func PreferB(Conflict) Resolution {
+	return KeepB
+}
+
+// PreferNewer resolves conflicts by keeping the most recently modified side.
+// Modifications win over removals, and conflicts with identical modified times are skipped.
+func PreferNewer(c Conflict) Resolution {
+	switch {
+	case c.A == nil:
+		return KeepB
+	case c.B == nil:
+		return KeepA
+	case c.A.ModTime.After(c.B.ModTime):
+		return KeepA
+	case c.B.ModTime.After(c.A.ModTime):
+		return KeepB
+	default:
+		return Skip
+	}
+}
+
+// Options contain options for a Sync
+type Options struct {
+	// State is the result of the previous sync, and is updated as each action completes. If nil, every path present on only one side is copied and no removals are made.
+	// Persist State between syncs to propagate removals and resume interrupted syncs.
+	State *State
+	// Resolve decides how to resolve paths changed on both sides. Defaults to PreferNewer.
+	Resolve Resolver
+	// Checksum compares file contents by SHA-256 hash instead of modified time
+	Checksum bool
+	// DryRun returns the planned actions without modifying either side or State
+	DryRun bool
+}
+
+// Sync reconciles the contents of 'a' and 'b', copying new and changed files in both directions and propagating removals.
+// Returns the actions taken, or the planned actions if Options.DryRun is set.
+func Sync(ctx context.Context, a, b hackpadfs.FS, options Options) ([]Action, error) {
+	if options.State == nil {
+		options.State = &State{}
+	}
+	if options.Resolve == nil {
+		options.Resolve = PreferNewer
+	}
+	s := &syncer{
+		fs:      map[Side]hackpadfs.FS{SideA: a, SideB: b},
+		options: options,
+	}
+	var err error
+	s.entries = make(map[Side]map[string]*Entry)
+	s.entries[SideA], err = scan(a, options.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	s.entries[SideB], err = scan(b, options.Checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := s.plan()
+	if options.DryRun {
+		return actions, nil
+	}
+	return actions, s.apply(ctx, actions)
+}
+
+type syncer struct {
+	fs      map[Side]hackpadfs.FS
+	entries map[Side]map[string]*Entry
+	options Options
+}
+
+func (s *syncer) plan() []Action {
+	nameSet := make(map[string]bool)
+	for _, entries := range s.entries {
+		for name := range entries {
+			nameSet[name] = true
+		}
+	}
+	for name := range s.options.State.Entries {
+		nameSet[name] = true
+	}
+	names := make([]string, 0, len(nameSet))
+	for name := range nameSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var actions []Action
+	removals := map[Side]map[string]bool{SideA: {}, SideB: {}}
+	for _, name := range names {
+		aEntry, bEntry := s.entries[SideA][name], s.entries[SideB][name]
+		base := s.options.State.get(name)
+		aChanged, bChanged := !aEntry.equal(base.A), !bEntry.equal(base.B)
+		var from Side
+		conflict := false
+		switch {
+		case aEntry.equal(bEntry):
+			if !s.options.DryRun {
+				s.options.State.set(name, aEntry, bEntry)
+			}
+			continue
+		case aChanged && !bChanged:
+			from = SideA
+		case bChanged && !aChanged:
+			from = SideB
+		case !aChanged && !bChanged:
+			continue
+		default:
+			conflict = true
+			switch s.options.Resolve(Conflict{Path: name, A: aEntry, B: bEntry}) {
+			case KeepA:
+				from = SideA
+			case KeepB:
+				from = SideB
+			default:
+				continue
+			}
+		}
+
+		action := Action{Op: OpCopy, Path: name, To: from.other(), Conflict: conflict}
+		if s.entries[from][name] == nil {
+			action.Op = OpRemove
+			removals[action.To][name] = true
+		}
+		actions = append(actions, action)
+	}
+	return s.keepNonEmptyDirs(actions, removals)
+}
+
+// keepNonEmptyDirs drops directory removals which would remove a path not also being removed
+func (s *syncer) keepNonEmptyDirs(actions []Action, removals map[Side]map[string]bool) []Action {
+	kept := actions[:0]
+	for _, action := range actions {
+		if action.Op == OpRemove && s.entries[action.To][action.Path].IsDir && !s.removingAll(action.To, action.Path, removals[action.To]) {
+			continue
+		}
+		kept = append(kept, action)
+	}
+	return kept
+}
+
+func (s *syncer) removingAll(side Side, dir string, removals map[string]bool) bool {
+	prefix := dir + "/"
+	for name := range s.entries[side] {
+		if strings.HasPrefix(name, prefix) && !removals[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// apply runs copies in order, then removals in reverse order so directories are emptied before they're removed
+func (s *syncer) apply(ctx context.Context, actions []Action) error {
+	for _, action := range actions {
+		if action.Op != OpCopy {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.copy(action); err != nil {
+			return err
+		}
+	}
+	for i := len(actions) - 1; i >= 0; i-- {
+		action := actions[i]
+		if action.Op != OpRemove {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := hackpadfs.Remove(s.fs[action.To], action.Path)
+		if err != nil && !errors.Is(err, hackpadfs.ErrNotExist) {
+			return err
+		}
+		s.options.State.set(action.Path, nil, nil)
+	}
+	return nil
+}
+
+func (s *syncer) copy(action Action) error {
+	from := action.To.other()
+	src, dest := s.fs[from], s.fs[action.To]
+	entry := s.entries[from][action.Path]
+	if err := copyEntry(src, dest, action.Path, entry); err != nil {
+		return err
+	}
+	info, err := hackpadfs.Stat(dest, action.Path)
+	if err != nil {
+		return err
+	}
+	destEntry, err := newEntry(dest, action.Path, info, false)
+	if err != nil {
+		return err
+	}
+	destEntry.Hash = entry.Hash
+	if from == SideA {
+		s.options.State.set(action.Path, entry, destEntry)
+	} else {
+		s.options.State.set(action.Path, destEntry, entry)
+	}
+	return nil
+}
+
+func copyEntry(src, dest hackpadfs.FS, name string, entry *Entry) error {
+	if info, err := hackpadfs.Stat(dest, name); err == nil && info.IsDir() != entry.IsDir {
+		if err := hackpadfs.RemoveAll(dest, name); err != nil {
+			return err
+		}
+	}
+	if entry.IsDir {
+		return hackpadfs.MkdirAll(dest, name, entry.Mode.Perm())
+	}
+	if dir := path.Dir(name); dir != "." {
+		if err := hackpadfs.MkdirAll(dest, dir, 0700); err != nil {
+			return err
+		}
+	}
+	data, err := hackpadfs.ReadFile(src, name)
+	if err != nil {
+		return err
+	}
+	if err := hackpadfs.WriteFullFile(dest, name, data, entry.Mode.Perm()); err != nil {
+		return err
+	}
+	if err := ignoreNotImplemented(hackpadfs.Chmod(dest, name, entry.Mode.Perm())); err != nil {
+		return err
+	}
+	return ignoreNotImplemented(hackpadfs.Chtimes(dest, name, entry.ModTime, entry.ModTime))
+}
+
+func ignoreNotImplemented(err error) error {
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newFS(tb testing.TB) *mem.FS {
+	tb.Helper()
+	fs, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func writeFile(tb testing.TB, fs hackpadfs.FS, name, contents string, modTime time.Time) {
+	tb.Helper()
+	assert.NoError(tb, hackpadfs.WriteFullFile(fs, name, []byte(contents), 0600))
+	assert.NoError(tb, hackpadfs.Chtimes(fs, name, modTime, modTime))
+}
+
+func readFile(tb testing.TB, fs hackpadfs.FS, name string) string {
+	tb.Helper()
+	contents, err := hackpadfs.ReadFile(fs, name)
+	assert.NoError(tb, err)
+	return string(contents)
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	now := time.Now()
+	a, b := newFS(t), newFS(t)
+	assert.NoError(t, a.MkdirAll("foo/bar", 0700))
+	writeFile(t, a, "foo/bar/baz", "baz", now)
+	writeFile(t, b, "biff", "biff", now)
+
+	var state State
+	actions, err := Sync(ctx, a, b, Options{State: &state})
+	assert.NoError(t, err)
+	assert.Equal(t, []Action{
+		{Op: OpCopy, Path: "biff", To: SideA},
+		{Op: OpCopy, Path: "foo", To: SideB},
+		{Op: OpCopy, Path: "foo/bar", To: SideB},
+		{Op: OpCopy, Path: "foo/bar/baz", To: SideB},
+	}, actions)
+	assert.Equal(t, "baz", readFile(t, b, "foo/bar/baz"))
+	assert.Equal(t, "biff", readFile(t, a, "biff"))
+
+	actions, err = Sync(ctx, a, b, Options{State: &state})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(actions))
+
+	// removals propagate, directories are removed once empty
+	assert.NoError(t, hackpadfs.RemoveAll(b, "foo"))
+	writeFile(t, b, "biff", "new biff", now.Add(time.Second))
+	actions, err = Sync(ctx, a, b, Options{State: &state})
+	assert.NoError(t, err)
+	assert.Equal(t, []Action{
+		{Op: OpCopy, Path: "biff", To: SideA},
+		{Op: OpRemove, Path: "foo", To: SideA},
+		{Op: OpRemove, Path: "foo/bar", To: SideA},
+		{Op: OpRemove, Path: "foo/bar/baz", To: SideA},
+	}, actions)
+	assert.Equal(t, "new biff", readFile(t, a, "biff"))
+	_, err = hackpadfs.Stat(a, "foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestSyncKeepsNonEmptyDir(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	now := time.Now()
+	a, b := newFS(t), newFS(t)
+	assert.NoError(t, a.Mkdir("foo", 0700))
+	writeFile(t, a, "foo/bar", "bar", now)
+	var state State
+	_, err := Sync(ctx, a, b, Options{State: &state})
+	assert.NoError(t, err)
+
+	assert.NoError(t, hackpadfs.RemoveAll(a, "foo"))
+	writeFile(t, b, "foo/baz", "baz", now)
+	actions, err := Sync(ctx, a, b, Options{State: &state})
+	assert.NoError(t, err)
+	assert.Equal(t, []Action{
+		{Op: OpRemove, Path: "foo/bar", To: SideB},
+		{Op: OpCopy, Path: "foo/baz", To: SideA},
+	}, actions)
+	assert.Equal(t, "baz", readFile(t, a, "foo/baz"))
+	_, err = hackpadfs.Stat(b, "foo/bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestSyncConflict(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	now := time.Now()
+	for _, tc := range []struct {
+		description string
+		resolve     Resolver
+		expectA     string
+		expectB     string
+	}{
+		{description: "prefer newer", resolve: PreferNewer, expectA: "new", expectB: "new"},
+		{description: "prefer A", resolve: PreferA, expectA: "old", expectB: "old"},
+		{description: "skip", resolve: func(Conflict) Resolution { return Skip }, expectA: "old", expectB: "new"},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			a, b := newFS(t), newFS(t)
+			writeFile(t, a, "foo", "old", now)
+			writeFile(t, b, "foo", "new", now.Add(time.Second))
+			_, err := Sync(ctx, a, b, Options{Resolve: tc.resolve})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectA, readFile(t, a, "foo"))
+			assert.Equal(t, tc.expectB, readFile(t, b, "foo"))
+		})
+	}
+}
+
+func TestSyncChecksum(t *testing.T) {
+	t.Parallel()
+	a, b := newFS(t), newFS(t)
+	now := time.Now()
+	writeFile(t, a, "foo", "foo", now)
+	writeFile(t, b, "foo", "foo", now.Add(time.Second))
+	actions, err := Sync(context.Background(), a, b, Options{Checksum: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(actions))
+}
+
+func TestSyncDryRun(t *testing.T) {
+	t.Parallel()
+	a, b := newFS(t), newFS(t)
+	writeFile(t, a, "foo", "foo", time.Now())
+	var state State
+	actions, err := Sync(context.Background(), a, b, Options{State: &state, DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []Action{
+		{Op: OpCopy, Path: "foo", To: SideB},
+	}, actions)
+	_, err = hackpadfs.Stat(b, "foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	assert.Equal(t, 0, len(state.Entries))
+}
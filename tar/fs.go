@@ -9,10 +9,10 @@ import (
 	"io"
 	"path"
 	"strings"
-	"sync"
 	"sync/atomic"
 
 	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/bufferpool"
 	"github.com/hack-pad/hackpadfs/internal/fserrors"
 	"github.com/hack-pad/hackpadfs/mem"
 )
@@ -23,8 +23,16 @@ import (
 // This means the files are concurrently unpacked and accessible.
 // If a file has not yet been unpacked, that file's operation will block until it is unpacked.
 // If a directory's dir entries are accessed, that operation will block until the entire archive has been unpacked. (Tar ordering can't be guaranteed.)
+//
+// Entries which could escape the archive's root or create devices fail the unarchive with an UnsafeEntryError, unless allowed in ReaderFSOptions.
+// Symlinks are created after every other entry is unpacked, if UnarchiveFS supports them, otherwise they're skipped. Their targets are converted to paths in UnarchiveFS, like the 'oldname' passed to hackpadfs.Symlink().
 type ReaderFS struct {
+	// filesDone and bytesDone are updated atomically, so they're first to stay 64-bit aligned on 32-bit platforms
+	filesDone int64
+	bytesDone int64
+
 	unarchiveFS baseFS
+	options     ReaderFSOptions
 	ps          *pubsub
 	// callerCtx is passed in through the constructor, controlling when we should stop reading
 	callerCtx context.Context
@@ -37,12 +45,56 @@ type ReaderFS struct {
 	unarchiveErr atomic.Value
 }
 
-var _ hackpadfs.FS = &ReaderFS{}
+var (
+	_ hackpadfs.FS       = &ReaderFS{}
+	_ hackpadfs.CloserFS = &ReaderFS{}
+)
 
 // ReaderFSOptions provides configuration options for a new ReaderFS.
 type ReaderFSOptions struct {
 	// UnarchiveFS is the destination FS to unarchive the reader into. Defaults to mem.FS.
 	UnarchiveFS baseFS
+	// Workers is the maximum number of files and directories written to UnarchiveFS at once. The archive itself is always read serially. Defaults to 16.
+	Workers int
+	// MaxMemory is the approximate number of bytes used to buffer file contents waiting to be written. Reading pauses while every buffer is in use. Defaults to 20 MiB.
+	MaxMemory int
+	// SmallBufferSize is the size of the buffer for each file's first read. Defaults to 150 KiB.
+	SmallBufferSize int
+	// BigBufferSize is the size of the buffers for the rest of larger files. Up to half of MaxMemory is split into big buffers.
+	// Files which fit in the big buffers are written in the background like small files, but larger files are written while reading the archive. Defaults to 4 MiB.
+	BigBufferSize int
+	// SpillLargeFiles streams files larger than SmallBufferSize straight into UnarchiveFS while reading the archive, reusing their small buffer instead of waiting for big buffers.
+	// No big buffers are allocated, so all of MaxMemory goes to small buffers and BigBufferSize is ignored.
+	// Useful in memory constrained environments like wasm, at the cost of reading pausing while each large file is written.
+	SpillLargeFiles bool
+
+	// AllowParentPaths unpacks entries with ".." in their names or hard link targets, like "../../etc/passwd", by resolving them as if the archive's root were "/". The entry's path can't climb above the root.
+	// By default, these entries fail with an UnsafeEntryError.
+	AllowParentPaths bool
+	// AllowUnsafeLinks creates symlinks with absolute targets, or relative targets which climb above the archive's root, by resolving them as if the archive's root were "/". By default, these entries fail with an UnsafeEntryError.
+	AllowUnsafeLinks bool
+	// AllowDevices unpacks character and block devices as files with their device mode, if UnarchiveFS supports it. By default, these entries fail with an UnsafeEntryError.
+	AllowDevices bool
+}
+
+const (
+	mebibyte = 1 << 20
+	kibibyte = 1 << 10
+)
+
+func (o *ReaderFSOptions) setDefaults() {
+	if o.Workers <= 0 {
+		o.Workers = 16
+	}
+	if o.MaxMemory <= 0 {
+		o.MaxMemory = 20 * mebibyte
+	}
+	if o.SmallBufferSize <= 0 {
+		o.SmallBufferSize = 150 * kibibyte
+	}
+	if o.BigBufferSize <= 0 {
+		o.BigBufferSize = 4 * mebibyte
+	}
 }
 
 type baseFS interface {
@@ -58,6 +110,7 @@ type baseFS interface {
 func NewReaderFS(ctx context.Context, r io.Reader, options ReaderFSOptions) (_ *ReaderFS, retErr error) {
 	defer func() { retErr = fserrors.WithMessage(retErr, "tar") }()
 
+	options.setDefaults()
 	if options.UnarchiveFS == nil {
 		var err error
 		options.UnarchiveFS, err = mem.NewFS()
@@ -78,6 +131,7 @@ func NewReaderFS(ctx context.Context, r io.Reader, options ReaderFSOptions) (_ *
 	readerCtx, readerDone := context.WithCancel(context.Background())
 	fs := &ReaderFS{
 		unarchiveFS:  options.UnarchiveFS,
+		options:      options,
 		ps:           newPubsub(ctx),
 		callerCtx:    ctx,
 		callerCancel: cancel,
@@ -103,21 +157,40 @@ func (fs *ReaderFS) read(r io.Reader) {
 
 func (fs *ReaderFS) readErr(r io.Reader) error {
 	archive := tar.NewReader(r)
-	const (
-		mebibyte       = 1 << 20
-		kibibyte       = 1 << 10
-		maxMemory      = 20 * mebibyte
-		bigBufMemory   = 4 * mebibyte
-		smallBufMemory = 150 * kibibyte
-
-		// at least a couple big and small buffers, then a large quantity of small ones make up the remainder
-		bigBufCount   = 2
-		smallBufCount = (maxMemory - bigBufCount*bigBufMemory) / smallBufMemory
-	)
-	// set up some buffer pools to reduce maximum memory usage. small buffers are for every file's first read, big buffers for secondary reads.
-	smallPool := newBufferPool(smallBufMemory, smallBufCount)
-	bigPool := newBufferPool(bigBufMemory, bigBufCount)
+	u := newUnpacker(fs.options)
+	var links []symlink
+	err := fs.readArchive(archive, u, &links)
+	if waitErr := u.Wait(); err == nil {
+		err = waitErr
+	}
+	if err == nil {
+		// create symlinks last, so other entries are never written through them
+		err = fs.createSymlinks(links)
+	}
+	return err
+}
+
+// symlink is an unpacked symlink entry at 'path', pointing to the FS path 'target'
+type symlink struct {
+	path, target string
+}
 
+func (fs *ReaderFS) createSymlinks(links []symlink) error {
+	for _, link := range links {
+		err := hackpadfs.Symlink(fs.unarchiveFS, link.target, link.path)
+		if errors.Is(err, hackpadfs.ErrNotImplemented) {
+			return nil // UnarchiveFS can't hold symlinks, so skip them
+		}
+		if err != nil {
+			return fserrors.WithMessage(err, "creating symlink")
+		}
+		atomic.AddInt64(&fs.filesDone, 1)
+		fs.ps.Emit(link.path)
+	}
+	return nil
+}
+
+func (fs *ReaderFS) readArchive(archive *tar.Reader, u *unpacker, links *[]symlink) error {
 	mkdirCache := make(map[string]bool) // avoid calling Mkdir more than once on the same path
 	cachedMkdirAll := func(path string, perm hackpadfs.FileMode) error {
 		if _, ok := mkdirCache[path]; ok {
@@ -130,43 +203,27 @@ func (fs *ReaderFS) readErr(r io.Reader) error {
 		return err
 	}
 
-	var wg sync.WaitGroup
-	errs := make(chan error, 1)
 	for {
-		select {
-		case err := <-errs:
+		if err := u.Err(); err != nil {
 			return err
-		default:
 		}
 		header, err := archive.Next()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
 			return fserrors.WithMessage(err, "next tar file")
 		}
-		err = fs.readProcessFile(header, archive, &wg, errs, cachedMkdirAll, smallPool, bigPool)
+		err = fs.readProcessFile(header, archive, u, cachedMkdirAll, links)
 		if err != nil {
 			return err
 		}
 	}
-
-	done := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
-	select {
-	case err := <-errs:
-		return err
-	case <-done:
-		return nil
-	}
 }
 
-// resolvePath converts a tar based path to a rooted FS path
+// resolvePath converts a tar based path to a rooted FS path. Leading ".." elements are dropped, so the path can't climb above the root.
 func resolvePath(p string) string {
-	p = path.Clean(p)
+	p = path.Clean("/" + p)
 	p = strings.TrimPrefix(p, "/")
 	if p == "" {
 		p = "."
@@ -174,11 +231,20 @@ func resolvePath(p string) string {
 	return p
 }
 
+// resolveLink converts the tar symlink target 'linkname' of the symlink 'name' to a rooted FS path, resolving relative targets from the link's directory.
+// Like resolvePath, absolute targets resolve from the root and leading ".." elements are dropped.
+func resolveLink(name, linkname string) string {
+	if path.IsAbs(linkname) {
+		return resolvePath(linkname)
+	}
+	return resolvePath(path.Join(path.Dir(name), linkname))
+}
+
 func (fs *ReaderFS) readProcessFile(
 	header *tar.Header, r io.Reader,
-	wg *sync.WaitGroup, errs chan error,
+	u *unpacker,
 	mkdirAll func(string, hackpadfs.FileMode) error,
-	smallPool, bigPool *bufferPool,
+	links *[]symlink,
 ) error {
 	select {
 	case <-fs.callerCtx.Done():
@@ -186,6 +252,9 @@ func (fs *ReaderFS) readProcessFile(
 	default:
 	}
 
+	if err := checkEntry(header, fs.options); err != nil {
+		return err
+	}
 	originalName := header.Name
 	p := resolvePath(originalName)
 	info := header.FileInfo()
@@ -196,63 +265,98 @@ func (fs *ReaderFS) readProcessFile(
 		return fserrors.WithMessage(err, "prepping base dir")
 	}
 
+	if header.Typeflag == tar.TypeSymlink {
+		*links = append(*links, symlink{path: p, target: resolveLink(p, header.Linkname)})
+		return nil
+	}
+
 	if info.IsDir() {
 		// assume dir does not exist yet, then chmod if it does exist
-		wg.Add(1)
-		go func() { // continue prepping dir in the background
-			defer wg.Done()
+		u.Go(func() error { // continue prepping dir in the background
 			err := fs.unarchiveFS.Mkdir(p, info.Mode())
 			if err != nil {
 				if !errors.Is(err, hackpadfs.ErrExist) {
-					errs <- fserrors.WithMessage(err, "copying dir")
-					return
+					return fserrors.WithMessage(err, "copying dir")
 				}
 				err = fs.unarchiveFS.Chmod(p, info.Mode())
 				if err != nil {
-					errs <- fserrors.WithMessage(err, "copying dir")
-					return
+					return fserrors.WithMessage(err, "copying dir")
 				}
 			}
-		}()
+			atomic.AddInt64(&fs.filesDone, 1)
+			return nil
+		})
 		return nil
 	}
 
 	reader := fullReader{r} // fullReader: call f.Write as few times as possible, since large files can be expensive to write in many batches (Hackpad JS Blobs)
 	// read once. if we reached EOF, then write it to fs asynchronously
-	smallBuf := smallPool.Wait()
+	smallBuf := u.smallPool.Wait()
 	n, err := reader.Read(smallBuf.Data)
 	switch err {
 	case io.EOF:
-		wg.Add(1)
-		go func() { // continue prepping small file in the background
-			err := fs.writeFile(p, info, smallBuf, n, nil, nil)
-			smallBuf.Done()
-			if err != nil {
-				errs <- err
-			}
-			wg.Done()
-		}()
+		u.Go(func() error { // continue prepping small file in the background
+			return fs.writeFile(p, info, [][]byte{smallBuf.Data[:n]}, nil, nil)
+		}, smallBuf)
 		return nil
 	case nil:
-		// prep large file in the foreground to finish reading the file (going to next file in tar invalidates the file reader)
-		bigBuf := bigPool.Wait()
-		err := fs.writeFile(p, info, smallBuf, n, reader, bigBuf)
-		bigBuf.Done()
+	default:
 		smallBuf.Done()
 		return err
-	default:
+	}
+
+	if u.bigPool == nil {
+		// spill the rest of the file in the foreground, copying through the small buffer once its first chunk is written
+		err = fs.writeFile(p, info, [][]byte{smallBuf.Data[:n]}, reader, smallBuf)
+		smallBuf.Done()
 		return err
 	}
+
+	remaining := header.Size - int64(n)
+	if remaining <= u.bigPool.Capacity() {
+		// buffer the rest of the file, then write it in the background while reading the next file
+		buffers := []*bufferpool.Buffer{smallBuf}
+		chunks := [][]byte{smallBuf.Data[:n]}
+		for remaining > 0 {
+			bigBuf := u.bigPool.Wait()
+			buffers = append(buffers, bigBuf)
+			chunk := bigBuf.Data
+			if int64(len(chunk)) > remaining {
+				chunk = chunk[:remaining]
+			}
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				releaseBuffers(buffers)
+				return fserrors.WithMessage(err, "reading file")
+			}
+			chunks = append(chunks, chunk)
+			remaining -= int64(len(chunk))
+		}
+		u.Go(func() error {
+			return fs.writeFile(p, info, chunks, nil, nil)
+		}, buffers...)
+		return nil
+	}
+
+	// file is larger than the memory budget, so prep it in the foreground to finish reading the file (going to next file in tar invalidates the file reader)
+	bigBuf := u.bigPool.Wait()
+	err = fs.writeFile(p, info, [][]byte{smallBuf.Data[:n]}, reader, bigBuf)
+	bigBuf.Done()
+	smallBuf.Done()
+	return err
 }
 
-func (fs *ReaderFS) writeFile(path string, info hackpadfs.FileInfo, initialBuf *buffer, n int, r io.Reader, copyBuf *buffer) (returnedErr error) {
+// writeFile writes 'chunks' to a new file at 'path', then copies the rest of its contents from 'r' if not nil
+func (fs *ReaderFS) writeFile(path string, info hackpadfs.FileInfo, chunks [][]byte, r io.Reader, copyBuf *bufferpool.Buffer) (returnedErr error) {
 	f, err := fs.unarchiveFS.OpenFile(path, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, info.Mode())
 	if err != nil {
 		return fserrors.WithMessage(err, "opening destination file")
 	}
+	var written int64
 	defer func() {
 		_ = f.Close()
 		if returnedErr == nil {
+			atomic.AddInt64(&fs.bytesDone, written)
+			atomic.AddInt64(&fs.filesDone, 1)
 			fs.ps.Emit(path) // only emit for non-dirs, dirs will wait until the total tar read completes to ensure correctness
 		}
 	}()
@@ -262,18 +366,21 @@ func (fs *ReaderFS) writeFile(path string, info hackpadfs.FileInfo, initialBuf *
 		return hackpadfs.ErrNotImplemented
 	}
 
-	_, err = fWriter.Write(initialBuf.Data[:n])
-	if err != nil {
-		return fserrors.WithMessage(err, "write: copying file")
+	for _, chunk := range chunks {
+		n, err := fWriter.Write(chunk)
+		written += int64(n)
+		if err != nil {
+			return fserrors.WithMessage(err, "write: copying file")
+		}
 	}
 
 	if r == nil {
-		// a nil reader signals we already did a read of N bytes and hit EOF,
-		// so the above copy is sufficient, return now
+		// a nil reader signals the chunks hold the whole file, so the above copy is sufficient, return now
 		return nil
 	}
 
-	_, err = io.CopyBuffer(fWriter, r, copyBuf.Data)
+	n, err := io.CopyBuffer(fWriter, r, copyBuf.Data)
+	written += n
 	return fserrors.WithMessage(err, "copybuf: copying file")
 }
 
@@ -301,12 +408,37 @@ func (fs *ReaderFS) Open(name string) (hackpadfs.File, error) {
 	return fs.unarchiveFS.Open(name)
 }
 
+// Ready returns a channel that's closed once the file 'name' has been unpacked, so operations on it won't block.
+// Directories and paths missing from the archive are ready once the archive is completely unpacked, or the unarchive fails. Invalid paths are always ready.
+//
+// Operations on 'name' may still fail after it's ready, like if the unarchive failed. Check UnarchiveErr() or the operation's error.
+func (fs *ReaderFS) Ready(name string) <-chan struct{} {
+	if !hackpadfs.ValidPath(name) {
+		return closedChan
+	}
+	return fs.ps.Ready(name)
+}
+
+// Progress returns the number of files and directories unpacked so far, and the total size of the files' contents.
+// Directories created only to hold other files aren't counted. Useful for status messages during long unpacks, like "1432/5000 files".
+func (fs *ReaderFS) Progress() (filesDone, bytesDone int64) {
+	return atomic.LoadInt64(&fs.filesDone), atomic.LoadInt64(&fs.bytesDone)
+}
+
 // Done returns a channel that's closed when either the tar has been completely unpacked or the unarchive fails.
 // The channel may close some time after the initial context is closed.
 func (fs *ReaderFS) Done() <-chan struct{} {
 	return fs.readerCtx.Done()
 }
 
+// Close stops unpacking the archive, if it's still unpacking, and waits for the unpacking goroutines to stop.
+// Files already unpacked remain readable. If the archive's reader is blocked in a Read call, Close waits for it to return.
+func (fs *ReaderFS) Close() error {
+	fs.callerCancel()
+	<-fs.readerCtx.Done()
+	return nil
+}
+
 // UnarchiveErr returns the error, if any, that occurred during unpacking. Behavior is undefined if Done() has not completed.
 func (fs *ReaderFS) UnarchiveErr() error {
 	err, _ := fs.unarchiveErr.Load().(error)
@@ -4,15 +4,16 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"runtime"
 	"testing"
 	"time"
 
 	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/archive"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
-	"github.com/hack-pad/hackpadfs/internal/fserrors"
 	"github.com/hack-pad/hackpadfs/mem"
 )
 
@@ -33,60 +34,22 @@ func TestFS(t *testing.T) {
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
+	fstest.StdFS(t, options)
 }
 
 func newTarFromFS(tb testing.TB, src hackpadfs.FS) *ReaderFS {
-	r, err := buildTarFromFS(tb, src)
-	if !assert.NoError(tb, err) {
+	var buf bytes.Buffer
+	if !assert.NoError(tb, archive.Write(&buf, src, ".", archive.Tar)) {
 		tb.FailNow()
 	}
 
-	fs, err := NewReaderFS(context.Background(), r, ReaderFSOptions{})
+	fs, err := NewReaderFS(context.Background(), &buf, ReaderFSOptions{})
 	if !assert.NoError(tb, err) {
 		tb.FailNow()
 	}
 	return fs
 }
 
-func buildTarFromFS(tb testing.TB, src hackpadfs.FS) (io.Reader, error) {
-	var buf bytes.Buffer
-	archive := tar.NewWriter(&buf)
-	defer func() { assert.NoError(tb, archive.Close()) }()
-
-	err := hackpadfs.WalkDir(src, ".", copyTarWalk(src, archive))
-	return &buf, fserrors.WithMessage(err, "Failed building tar from FS walk")
-}
-
-func copyTarWalk(src hackpadfs.FS, archive *tar.Writer) hackpadfs.WalkDirFunc {
-	return func(path string, dir hackpadfs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		info, err := dir.Info()
-		if err != nil {
-			return err
-		}
-		header, err := tar.FileInfoHeader(info, "")
-		if err != nil {
-			return err
-		}
-		header.Name = path
-		if info.IsDir() {
-			header.Name += "/"
-		}
-		err = archive.WriteHeader(header)
-		if err != nil {
-			return err
-		}
-		fileBytes, err := hackpadfs.ReadFile(src, path)
-		if err != nil {
-			return err
-		}
-		_, err = archive.Write(fileBytes)
-		return err
-	}
-}
-
 // TestNewTarFromFS is a sanity check on the constructor we use in fstest. Just make sure it behaves normally for simple cases.
 func TestNewTarFromFS(t *testing.T) {
 	t.Parallel()
@@ -159,3 +122,194 @@ func TestNewTarFromFS(t *testing.T) {
 		})
 	}
 }
+
+type tarFile struct {
+	name     string
+	contents []byte
+}
+
+func buildTar(tb testing.TB, files []tarFile) []byte {
+	tb.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, file := range files {
+		assert.NoError(tb, w.WriteHeader(&tar.Header{
+			Name:     file.name,
+			Mode:     0600,
+			Size:     int64(len(file.contents)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := w.Write(file.contents)
+		assert.NoError(tb, err)
+	}
+	assert.NoError(tb, w.Close())
+	return buf.Bytes()
+}
+
+func TestReaderFSBuffers(t *testing.T) {
+	t.Parallel()
+	files := []tarFile{
+		{name: "small", contents: []byte("abc")},
+		{name: "dir/buffered", contents: bytes.Repeat([]byte("b"), 20)},
+		{name: "dir/streamed", contents: bytes.Repeat([]byte("s"), 100)},
+		{name: "dir/sub/another-small", contents: []byte("d")},
+	}
+	for _, workers := range []int{1, 3} {
+		fs, err := NewReaderFS(context.Background(), bytes.NewReader(buildTar(t, files)), ReaderFSOptions{
+			Workers:         workers,
+			MaxMemory:       32,
+			SmallBufferSize: 4,
+			BigBufferSize:   8,
+		})
+		assert.NoError(t, err)
+		<-fs.Done()
+		assert.NoError(t, fs.UnarchiveErr())
+		for _, file := range files {
+			contents, err := hackpadfs.ReadFile(fs, file.name)
+			assert.NoError(t, err)
+			assert.Equal(t, string(file.contents), string(contents))
+		}
+	}
+}
+
+func TestReaderFSSpillLargeFiles(t *testing.T) {
+	t.Parallel()
+	files := []tarFile{
+		{name: "small", contents: []byte("abc")},
+		{name: "dir/spilled", contents: bytes.Repeat([]byte("s"), 100)},
+		{name: "dir/another-small", contents: []byte("d")},
+		{name: "exact", contents: []byte("abcd")},
+	}
+	options := ReaderFSOptions{
+		Workers:         2,
+		MaxMemory:       8,
+		SmallBufferSize: 4,
+		SpillLargeFiles: true,
+	}
+	options.setDefaults()
+	u := newUnpacker(options)
+	assert.Equal(t, true, u.bigPool == nil)
+	assert.Equal(t, int64(8), u.smallPool.Capacity())
+	assert.NoError(t, u.Wait())
+
+	fs, err := NewReaderFS(context.Background(), bytes.NewReader(buildTar(t, files)), options)
+	assert.NoError(t, err)
+	<-fs.Done()
+	assert.NoError(t, fs.UnarchiveErr())
+	for _, file := range files {
+		contents, err := hackpadfs.ReadFile(fs, file.name)
+		assert.NoError(t, err)
+		assert.Equal(t, string(file.contents), string(contents))
+	}
+}
+
+func TestReaderFSReadyProgress(t *testing.T) {
+	t.Parallel()
+	archiveReader, archiveWriter := io.Pipe()
+	fs, err := NewReaderFS(context.Background(), archiveReader, ReaderFSOptions{})
+	assert.NoError(t, err)
+	ready := fs.Ready("a")
+	select {
+	case <-ready:
+		t.Fatal("ready before unpacking")
+	default:
+	}
+	<-fs.Ready("../invalid")
+	filesDone, bytesDone := fs.Progress()
+	assert.Equal(t, int64(0), filesDone)
+	assert.Equal(t, int64(0), bytesDone)
+
+	go func() {
+		_, err := archiveWriter.Write(buildTar(t, []tarFile{
+			{name: "a", contents: []byte("abc")},
+			{name: "dir/b", contents: []byte("de")},
+		}))
+		assert.NoError(t, err)
+		assert.NoError(t, archiveWriter.Close())
+	}()
+	<-ready
+	contents, err := hackpadfs.ReadFile(fs.unarchiveFS, "a") // already unpacked, so don't wait in ReaderFS
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(contents))
+
+	<-fs.Ready("dir") // directories are ready once the whole archive is unpacked
+	<-fs.Done()
+	assert.NoError(t, fs.UnarchiveErr())
+	filesDone, bytesDone = fs.Progress()
+	assert.Equal(t, int64(2), filesDone)
+	assert.Equal(t, int64(5), bytesDone)
+}
+
+func TestReaderFSClose(t *testing.T) {
+	t.Parallel()
+	var files []tarFile
+	for i := 0; i < 100; i++ {
+		files = append(files, tarFile{name: fmt.Sprintf("file%03d", i), contents: bytes.Repeat([]byte{byte(i)}, kibibyte)})
+	}
+	fs, err := NewReaderFS(context.Background(), bytes.NewReader(buildTar(t, files)), ReaderFSOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Close(fs))
+	select {
+	case <-fs.Done():
+	default:
+		t.Error("Close returned before unpacking stopped")
+	}
+	assert.NoError(t, fs.Close()) // closing again is a no-op
+}
+
+type failingFS struct {
+	*mem.FS
+}
+
+func (fs *failingFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if name == "fail" {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrPermission}
+	}
+	return fs.FS.OpenFile(name, flag, perm)
+}
+
+func TestReaderFSWriteError(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	archive := buildTar(t, []tarFile{
+		{name: "foo", contents: []byte("foo")},
+		{name: "fail", contents: []byte("fail")},
+		{name: "bar", contents: []byte("bar")},
+	})
+	fs, err := NewReaderFS(context.Background(), bytes.NewReader(archive), ReaderFSOptions{
+		UnarchiveFS: &failingFS{memFS},
+	})
+	assert.NoError(t, err)
+	<-fs.Done()
+	assert.ErrorIs(t, hackpadfs.ErrPermission, fs.UnarchiveErr())
+}
+
+// BenchmarkReaderFS unpacks an archive of many small files and a few large ones with varying numbers of workers.
+func BenchmarkReaderFS(b *testing.B) {
+	var files []tarFile
+	for i := 0; i < 500; i++ {
+		files = append(files, tarFile{name: fmt.Sprintf("small/%03d", i), contents: bytes.Repeat([]byte{byte(i)}, 4*kibibyte)})
+	}
+	for i := 0; i < 4; i++ {
+		files = append(files, tarFile{name: fmt.Sprintf("big/%d", i), contents: bytes.Repeat([]byte{byte(i)}, 3*mebibyte)})
+	}
+	archive := buildTar(b, files)
+
+	for _, workers := range []int{1, 4, 16} {
+		workers := workers
+		b.Run(fmt.Sprintf("mem %d workers", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(archive)))
+			for i := 0; i < b.N; i++ {
+				fs, err := NewReaderFS(context.Background(), bytes.NewReader(archive), ReaderFSOptions{Workers: workers})
+				if err != nil {
+					b.Fatal(err)
+				}
+				<-fs.Done()
+				if err := fs.UnarchiveErr(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
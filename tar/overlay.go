@@ -0,0 +1,475 @@
+package tar
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.ChmodFS
+		hackpadfs.ChtimesFS
+		hackpadfs.CloserFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.MkdirFS
+		hackpadfs.OpenFileFS
+		hackpadfs.ReadDirFS
+		hackpadfs.RemoveFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+	} = &OverlayFS{}
+)
+
+// OverlayFS is a writable file system made of a ReaderFS unpacking a tar archive, with an upper FS on top which holds every change.
+//
+// Writes are allowed immediately, while the archive is still unpacking:
+//   - Reads, and writes which keep a file's existing contents, wait for the archive's copy of the path like ReaderFS does, then copy it up to the upper FS before changing it.
+//   - Writes which replace a path entirely, like creating a file with FlagTruncate or Mkdir, don't wait. The new file or directory hides the archive's copy of the path, even if it's unpacked later.
+//   - Removing a path hides the archive's copy of it, and everything inside it.
+//
+// Renaming directories which came from the archive fails with hackpadfs.ErrNotImplemented.
+type OverlayFS struct {
+	lower *ReaderFS
+	upper hackpadfs.FS
+
+	mu     sync.Mutex
+	hidden map[string]bool // hidden paths and everything inside them are no longer visible in the archive
+}
+
+// NewOverlayFS returns a new OverlayFS unpacking the tar archive 'r' beneath 'upper'. 'upper' should be empty, or only contain changes from a previous OverlayFS of the same archive.
+// See NewReaderFS() for details on reading the archive.
+func NewOverlayFS(ctx context.Context, r io.Reader, upper hackpadfs.FS) (*OverlayFS, error) {
+	return NewOverlayFSWithOptions(ctx, r, upper, ReaderFSOptions{})
+}
+
+// NewOverlayFSWithOptions returns a new OverlayFS, unpacking the tar archive 'r' with 'options'. See NewOverlayFS().
+func NewOverlayFSWithOptions(ctx context.Context, r io.Reader, upper hackpadfs.FS, options ReaderFSOptions) (*OverlayFS, error) {
+	lower, err := NewReaderFS(ctx, r, options)
+	if err != nil {
+		return nil, err
+	}
+	return &OverlayFS{
+		lower:  lower,
+		upper:  upper,
+		hidden: make(map[string]bool),
+	}, nil
+}
+
+// Done returns a channel that's closed when the archive has been completely unpacked or the unarchive fails. See ReaderFS.Done().
+func (fs *OverlayFS) Done() <-chan struct{} {
+	return fs.lower.Done()
+}
+
+// Close stops unpacking the archive. See ReaderFS.Close().
+func (fs *OverlayFS) Close() error {
+	return fs.lower.Close()
+}
+
+// UnarchiveErr returns the error, if any, that occurred during unpacking. See ReaderFS.UnarchiveErr().
+func (fs *OverlayFS) UnarchiveErr() error {
+	return fs.lower.UnarchiveErr()
+}
+
+// hide stops the archive's copy of 'name' and everything inside it from being visible
+func (fs *OverlayFS) hide(name string) {
+	fs.mu.Lock()
+	fs.hidden[name] = true
+	fs.mu.Unlock()
+}
+
+// isHidden returns true if 'name' or one of its parent directories is hidden
+func (fs *OverlayFS) isHidden(name string) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for p := name; ; p = path.Dir(p) {
+		if fs.hidden[p] {
+			return true
+		}
+		if p == "." {
+			return false
+		}
+	}
+}
+
+// lowerStat returns info for the archive's copy of 'name', waiting for it to unpack if needed.
+// Directories already created while unpacking are returned right away, since ReaderFS waits for the whole archive before returning them.
+func (fs *OverlayFS) lowerStat(name string) (hackpadfs.FileInfo, error) {
+	if fs.isHidden(name) {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: hackpadfs.ErrNotExist}
+	}
+	if info, err := hackpadfs.Stat(fs.lower.unarchiveFS, name); err == nil && info.IsDir() {
+		return info, nil
+	}
+	return hackpadfs.Stat(fs.lower, name)
+}
+
+// stat returns info for 'name' and whether it's in the upper FS
+func (fs *OverlayFS) stat(name string) (_ hackpadfs.FileInfo, inUpper bool, _ error) {
+	info, err := hackpadfs.Stat(fs.upper, name)
+	if err == nil || !errors.Is(err, hackpadfs.ErrNotExist) {
+		return info, err == nil, err
+	}
+	info, err = fs.lowerStat(name)
+	return info, false, err
+}
+
+// copyUp copies the archive's copy of 'name' to the upper FS, along with its parent directories, so it can be changed
+func (fs *OverlayFS) copyUp(name string) error {
+	info, inUpper, err := fs.stat(name)
+	if err != nil || inUpper {
+		return err
+	}
+	if err := fs.copyUpParents(name); err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = hackpadfs.Mkdir(fs.upper, name, info.Mode().Perm())
+		if errors.Is(err, hackpadfs.ErrExist) {
+			err = nil
+		}
+	} else {
+		err = hackpadfs.CopyFile(fs.upper, name, fs.lower, name)
+	}
+	if err != nil {
+		return err
+	}
+	modTime := info.ModTime()
+	err = hackpadfs.Chtimes(fs.upper, name, modTime, modTime)
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		err = nil
+	}
+	return err
+}
+
+// copyUpParents creates the parent directories of 'name' in the upper FS, if they aren't there already
+func (fs *OverlayFS) copyUpParents(name string) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+	info, inUpper, err := fs.stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &hackpadfs.PathError{Op: "stat", Path: dir, Err: hackpadfs.ErrNotDir}
+	}
+	if inUpper {
+		return nil
+	}
+	return fs.copyUp(dir)
+}
+
+// Open implements hackpadfs.FS
+func (fs *OverlayFS) Open(name string) (hackpadfs.File, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, inUpper, err := fs.stat(name)
+	if err != nil {
+		return nil, overlayPathErr("open", name, err)
+	}
+	if info.IsDir() {
+		return &overlayDir{fs: fs, name: name, info: info}, nil
+	}
+	if inUpper {
+		return fs.upper.Open(name)
+	}
+	return fs.lower.Open(name)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *OverlayFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if flag == hackpadfs.FlagReadOnly {
+		return fs.Open(name)
+	}
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	replaces := flag&hackpadfs.FlagCreate != 0 && flag&hackpadfs.FlagTruncate != 0 && flag&hackpadfs.FlagExclusive == 0
+	var err error
+	if replaces {
+		if info, statErr := hackpadfs.Stat(fs.lower.unarchiveFS, name); statErr == nil && info.IsDir() && !fs.isHidden(name) {
+			return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrIsDir}
+		}
+		err = fs.copyUpParents(name)
+	} else {
+		err = fs.copyUp(name)
+		if errors.Is(err, hackpadfs.ErrNotExist) && flag&hackpadfs.FlagCreate != 0 {
+			err = fs.copyUpParents(name)
+		}
+	}
+	if err != nil {
+		return nil, overlayPathErr("open", name, err)
+	}
+	file, err := hackpadfs.OpenFile(fs.upper, name, flag, perm)
+	if err == nil && flag&hackpadfs.FlagCreate != 0 {
+		fs.hide(name)
+	}
+	return file, err
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *OverlayFS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	if _, err := hackpadfs.Stat(fs.lower.unarchiveFS, name); err == nil && !fs.isHidden(name) {
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: hackpadfs.ErrExist}
+	}
+	if err := fs.copyUpParents(name); err != nil {
+		return overlayPathErr("mkdir", name, err)
+	}
+	err := hackpadfs.Mkdir(fs.upper, name, perm)
+	if err == nil {
+		fs.hide(name)
+	}
+	return err
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *OverlayFS) MkdirAll(name string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "mkdirall", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	if name == "." {
+		return nil
+	}
+	if err := fs.MkdirAll(path.Dir(name), perm); err != nil {
+		return err
+	}
+	err := fs.Mkdir(name, perm)
+	if errors.Is(err, hackpadfs.ErrExist) {
+		info, statErr := fs.Stat(name)
+		if statErr != nil {
+			return statErr
+		}
+		if !info.IsDir() {
+			return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: hackpadfs.ErrNotDir}
+		}
+		return nil
+	}
+	return err
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *OverlayFS) Remove(name string) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, inUpper, err := fs.stat(name)
+	if err != nil {
+		return overlayPathErr("remove", name, err)
+	}
+	if info.IsDir() {
+		entries, err := fs.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrNotEmpty}
+		}
+	}
+	if inUpper {
+		if err := hackpadfs.Remove(fs.upper, name); err != nil {
+			return err
+		}
+	}
+	fs.hide(name)
+	return nil
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *OverlayFS) Rename(oldname, newname string) error {
+	if !hackpadfs.ValidPath(oldname) || !hackpadfs.ValidPath(newname) {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrInvalid}
+	}
+	info, inUpper, err := fs.stat(oldname)
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: underlyingErr(err)}
+	}
+	if info.IsDir() && !fs.isHidden(oldname) {
+		if _, err := fs.lowerStat(oldname); err == nil {
+			return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrNotImplemented}
+		}
+	}
+	if !inUpper {
+		err = fs.copyUp(oldname)
+	}
+	if err == nil {
+		err = fs.copyUpParents(newname)
+	}
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: underlyingErr(err)}
+	}
+	if err := hackpadfs.Rename(fs.upper, oldname, newname); err != nil {
+		return err
+	}
+	if oldname != newname {
+		fs.hide(oldname)
+		fs.hide(newname)
+	}
+	return nil
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *OverlayFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, _, err := fs.stat(name)
+	if err != nil {
+		return nil, overlayPathErr("stat", name, err)
+	}
+	return info, nil
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+//
+// Directories from the archive wait until the archive is completely unpacked, like ReaderFS.
+func (fs *OverlayFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, inUpper, err := fs.stat(name)
+	if err != nil {
+		return nil, overlayPathErr("open", name, err)
+	}
+	if !info.IsDir() {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrNotDir}
+	}
+
+	entries := make(map[string]hackpadfs.DirEntry)
+	if !fs.isHidden(name) {
+		lowerEntries, err := hackpadfs.ReadDir(fs.lower, name)
+		if err != nil && !errors.Is(err, hackpadfs.ErrNotExist) {
+			return nil, err
+		}
+		for _, entry := range lowerEntries {
+			if !fs.isHidden(path.Join(name, entry.Name())) {
+				entries[entry.Name()] = entry
+			}
+		}
+	}
+	if inUpper {
+		upperEntries, err := hackpadfs.ReadDir(fs.upper, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range upperEntries {
+			entries[entry.Name()] = entry
+		}
+	}
+
+	sortedEntries := make([]hackpadfs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		sortedEntries = append(sortedEntries, entry)
+	}
+	sort.Slice(sortedEntries, func(a, b int) bool {
+		return sortedEntries[a].Name() < sortedEntries[b].Name()
+	})
+	return sortedEntries, nil
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *OverlayFS) Chmod(name string, mode hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "chmod", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	if err := fs.copyUp(name); err != nil {
+		return overlayPathErr("chmod", name, err)
+	}
+	return hackpadfs.Chmod(fs.upper, name, mode)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *OverlayFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "chtimes", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	if err := fs.copyUp(name); err != nil {
+		return overlayPathErr("chtimes", name, err)
+	}
+	return hackpadfs.Chtimes(fs.upper, name, atime, mtime)
+}
+
+// overlayPathErr returns a PathError for 'op' on 'name', replacing the PathError for a related path or different operation in 'err'
+func overlayPathErr(op, name string, err error) error {
+	return &hackpadfs.PathError{Op: op, Path: name, Err: underlyingErr(err)}
+}
+
+// underlyingErr returns the error wrapped by a PathError, or 'err' itself
+func underlyingErr(err error) error {
+	if pathErr, ok := err.(*hackpadfs.PathError); ok {
+		return pathErr.Err
+	}
+	return err
+}
+
+// overlayDir is an open directory in an OverlayFS, listing its combined entries from both layers
+type overlayDir struct {
+	fs   *OverlayFS
+	name string
+	info hackpadfs.FileInfo
+
+	mu      sync.Mutex
+	entries []hackpadfs.DirEntry
+	listed  bool
+	closed  bool
+}
+
+func (d *overlayDir) Read([]byte) (int, error) {
+	return 0, &hackpadfs.PathError{Op: "read", Path: d.name, Err: hackpadfs.ErrIsDir}
+}
+
+func (d *overlayDir) Stat() (hackpadfs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *overlayDir) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return &hackpadfs.PathError{Op: "close", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	d.closed = true
+	return nil
+}
+
+// ReadDir implements hackpadfs.DirReaderFile, listing the directory on the first call
+func (d *overlayDir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	if !d.listed {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.listed = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,104 @@
+package tar
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/archive"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestOverlayFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "tar overlay",
+		Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+			setupFS, err := mem.NewFS()
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+
+			return setupFS, func() hackpadfs.FS {
+				var buf bytes.Buffer
+				if !assert.NoError(tb, archive.Write(&buf, setupFS, ".", archive.Tar)) {
+					tb.FailNow()
+				}
+				upper, err := mem.NewFS()
+				if !assert.NoError(tb, err) {
+					tb.FailNow()
+				}
+				fs, err := NewOverlayFS(context.Background(), &buf, upper)
+				if !assert.NoError(tb, err) {
+					tb.FailNow()
+				}
+				<-fs.Done() // check the combined file system, once the archive can't race with the test's changes
+				return fs
+			}
+		}),
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestOverlayFSWritesWhileUnpacking(t *testing.T) {
+	t.Parallel()
+	archiveReader, archiveWriter := io.Pipe()
+	upper, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := NewOverlayFS(context.Background(), archiveReader, upper)
+	assert.NoError(t, err)
+
+	// nothing is unpacked yet, but replacing writes don't wait for the archive
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "new", []byte("new"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "replaced", []byte("upper"), 0600))
+
+	go func() {
+		_, err := archiveWriter.Write(buildTar(t, []tarFile{
+			{name: "archived", contents: []byte("archived")},
+			{name: "removed", contents: []byte("removed")},
+			{name: "replaced", contents: []byte("lower")},
+			{name: "dir/file", contents: []byte("file")},
+		}))
+		assert.NoError(t, err)
+		assert.NoError(t, archiveWriter.Close())
+	}()
+
+	contents, err := hackpadfs.ReadFile(fs, "archived")
+	assert.NoError(t, err)
+	assert.Equal(t, "archived", string(contents))
+	assert.NoError(t, hackpadfs.Remove(fs, "removed"))
+	_, err = hackpadfs.Stat(fs, "removed")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	file, err := hackpadfs.OpenFile(fs, "dir/file", hackpadfs.FlagWriteOnly|hackpadfs.FlagAppend, 0)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(file, []byte(" appended"))
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	<-fs.Done()
+	assert.NoError(t, fs.UnarchiveErr())
+	contents, err = hackpadfs.ReadFile(fs, "replaced")
+	assert.NoError(t, err)
+	assert.Equal(t, "upper", string(contents))
+	contents, err = hackpadfs.ReadFile(fs, "dir/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "file appended", string(contents))
+	contents, err = hackpadfs.ReadFile(fs.lower, "dir/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "file", string(contents))
+
+	entries, err := hackpadfs.ReadDir(fs, ".")
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"archived", "dir", "new", "replaced"}, names)
+}
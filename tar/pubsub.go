@@ -5,6 +5,13 @@ import (
 	"sync"
 )
 
+// closedChan is returned by Ready for keys which were already emitted
+var closedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
 type pubsub struct {
 	mu          sync.RWMutex
 	subscribers map[string][]context.CancelFunc
@@ -38,24 +45,25 @@ func (ps *pubsub) Emit(key string) {
 	}
 }
 
-func (ps *pubsub) Wait(key string) {
+// Ready returns a channel which is closed once 'key' is emitted or the pubsub's context is canceled
+func (ps *pubsub) Ready(key string) <-chan struct{} {
 	select {
 	case <-ps.ctx.Done():
-		return
+		return ps.ctx.Done()
 	default:
 	}
 
 	ps.mu.Lock()
+	defer ps.mu.Unlock()
 	if ps.visited[key] {
-		ps.mu.Unlock()
-		return
+		return closedChan
 	}
 	ctx, cancel := context.WithCancel(ps.ctx)
 	ps.subscribers[key] = append(ps.subscribers[key], cancel)
-	ps.mu.Unlock()
+	return ctx.Done()
+}
 
-	select {
-	case <-ps.ctx.Done():
-	case <-ctx.Done():
-	}
+// Wait blocks until 'key' is emitted or the pubsub's context is canceled
+func (ps *pubsub) Wait(key string) {
+	<-ps.Ready(key)
 }
@@ -30,4 +30,24 @@ func TestPubSub(t *testing.T) {
 		}
 		<-completed
 	})
+
+	t.Run("ready", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		ps := newPubsub(ctx)
+		ready := ps.Ready("hi")
+		canceled := ps.Ready("never emitted")
+		select {
+		case <-ready:
+			t.Fatal("ready before emit")
+		default:
+		}
+		ps.Emit("hi")
+		<-ready
+		<-ps.Ready("hi")
+
+		cancel()
+		<-canceled
+		<-ps.Ready("after cancel")
+	})
 }
@@ -0,0 +1,86 @@
+package tar
+
+import (
+	"archive/tar"
+	"errors"
+	"strings"
+
+	"github.com/hack-pad/hackpadfs/internal/fspath"
+)
+
+// ErrUnsafeEntry is matched by errors.Is() when a ReaderFS rejects an archive entry which could escape the archive's root or create a device
+var ErrUnsafeEntry = errors.New("unsafe archive entry")
+
+// UnsafeReason names why an UnsafeEntryError's entry was rejected
+type UnsafeReason string
+
+// Reasons an entry is rejected. Each can be allowed with its ReaderFSOptions field.
+const (
+	UnsafeParentPath UnsafeReason = "path contains '..'"                   // UnsafeParentPath is allowed by ReaderFSOptions.AllowParentPaths
+	UnsafeLink       UnsafeReason = "link target escapes the archive root" // UnsafeLink is allowed by ReaderFSOptions.AllowUnsafeLinks
+	UnsafeDevice     UnsafeReason = "character or block device"            // UnsafeDevice is allowed by ReaderFSOptions.AllowDevices
+)
+
+// UnsafeEntryError records an archive entry rejected by a ReaderFS. Unpacking stops at the first unsafe entry, returning the error from UnarchiveErr().
+type UnsafeEntryError struct {
+	Name     string // Name is the entry's name in the archive
+	Linkname string // Linkname is the entry's link target, if it's a symlink or hard link
+	Reason   UnsafeReason
+}
+
+func (e *UnsafeEntryError) Error() string {
+	name := e.Name
+	if e.Linkname != "" {
+		name += " -> " + e.Linkname
+	}
+	return name + ": " + ErrUnsafeEntry.Error() + ": " + string(e.Reason)
+}
+
+// Is supports errors.Is(err, ErrUnsafeEntry).
+func (e *UnsafeEntryError) Is(target error) bool {
+	return target == ErrUnsafeEntry
+}
+
+// checkEntry returns an UnsafeEntryError if 'header' is unsafe to unpack with 'options'
+func checkEntry(header *tar.Header, options ReaderFSOptions) error {
+	if !options.AllowParentPaths && hasParentComponent(header.Name) {
+		return &UnsafeEntryError{Name: header.Name, Reason: UnsafeParentPath}
+	}
+	switch header.Typeflag {
+	case tar.TypeSymlink:
+		if !options.AllowUnsafeLinks && !isLocalLink(resolvePath(header.Name), header.Linkname) {
+			return &UnsafeEntryError{Name: header.Name, Linkname: header.Linkname, Reason: UnsafeLink}
+		}
+	case tar.TypeLink:
+		// hard link targets are archive paths, not relative to the link
+		if !options.AllowParentPaths && hasParentComponent(header.Linkname) {
+			return &UnsafeEntryError{Name: header.Name, Linkname: header.Linkname, Reason: UnsafeParentPath}
+		}
+	case tar.TypeChar, tar.TypeBlock:
+		if !options.AllowDevices {
+			return &UnsafeEntryError{Name: header.Name, Reason: UnsafeDevice}
+		}
+	}
+	return nil
+}
+
+// hasParentComponent returns true if any element of 'p' is "..". Backslashes separate elements too, since some OSes treat them as path separators.
+func hasParentComponent(p string) bool {
+	for _, elem := range strings.Split(toSlash(p), "/") {
+		if elem == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalLink returns true if the symlink at 'name' pointing to 'target' stays inside the archive root, treating backslashes as separators like hasParentComponent.
+// Targets are resolved lexically, so a relative target is local if it doesn't climb above the root from the link's directory. Absolute targets are never local.
+func isLocalLink(name, target string) bool {
+	_, ok := fspath.ResolveLink(name, toSlash(target))
+	return ok
+}
+
+func toSlash(p string) string {
+	return strings.ReplaceAll(p, `\`, "/")
+}
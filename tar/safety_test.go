@@ -0,0 +1,226 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	osfs "github.com/hack-pad/hackpadfs/os"
+)
+
+func buildTarHeaders(tb testing.TB, headers ...*tar.Header) []byte {
+	tb.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, header := range headers {
+		if header.Mode == 0 {
+			header.Mode = 0600
+		}
+		assert.NoError(tb, w.WriteHeader(header))
+	}
+	assert.NoError(tb, w.Close())
+	return buf.Bytes()
+}
+
+// symlinkFS records the symlinks created in a mem.FS, which doesn't support them
+type symlinkFS struct {
+	*mem.FS
+	mu    sync.Mutex
+	links map[string]string
+}
+
+func (fs *symlinkFS) Symlink(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.links[newname] = oldname
+	return nil
+}
+
+func newSymlinkFS(tb testing.TB) *symlinkFS {
+	tb.Helper()
+	memFS, err := mem.NewFS()
+	assert.NoError(tb, err)
+	return &symlinkFS{FS: memFS, links: make(map[string]string)}
+}
+
+func TestReaderFSUnsafeEntries(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		header      *tar.Header
+		options     ReaderFSOptions
+		expectErr   *UnsafeEntryError
+	}{
+		{
+			description: "parent path",
+			header:      &tar.Header{Name: "../../etc/passwd", Typeflag: tar.TypeReg},
+			expectErr:   &UnsafeEntryError{Name: "../../etc/passwd", Reason: UnsafeParentPath},
+		},
+		{
+			description: "parent path in the middle",
+			header:      &tar.Header{Name: "dir/../../passwd", Typeflag: tar.TypeReg},
+			expectErr:   &UnsafeEntryError{Name: "dir/../../passwd", Reason: UnsafeParentPath},
+		},
+		{
+			description: "parent path with backslashes",
+			header:      &tar.Header{Name: `..\..\etc\passwd`, Typeflag: tar.TypeReg},
+			expectErr:   &UnsafeEntryError{Name: `..\..\etc\passwd`, Reason: UnsafeParentPath},
+		},
+		{
+			description: "parent path allowed",
+			header:      &tar.Header{Name: "../../etc/passwd", Typeflag: tar.TypeReg},
+			options:     ReaderFSOptions{AllowParentPaths: true},
+		},
+		{
+			description: "hard link to parent path",
+			header:      &tar.Header{Name: "link", Linkname: "../secret", Typeflag: tar.TypeLink},
+			expectErr:   &UnsafeEntryError{Name: "link", Linkname: "../secret", Reason: UnsafeParentPath},
+		},
+		{
+			description: "absolute symlink",
+			header:      &tar.Header{Name: "link", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink},
+			expectErr:   &UnsafeEntryError{Name: "link", Linkname: "/etc/passwd", Reason: UnsafeLink},
+		},
+		{
+			description: "relative symlink escaping root",
+			header:      &tar.Header{Name: "dir/link", Linkname: "../../etc", Typeflag: tar.TypeSymlink},
+			expectErr:   &UnsafeEntryError{Name: "dir/link", Linkname: "../../etc", Reason: UnsafeLink},
+		},
+		{
+			description: "relative symlink escaping root with backslashes",
+			header:      &tar.Header{Name: "dir/link", Linkname: `..\..\etc`, Typeflag: tar.TypeSymlink},
+			expectErr:   &UnsafeEntryError{Name: "dir/link", Linkname: `..\..\etc`, Reason: UnsafeLink},
+		},
+		{
+			description: "relative symlink inside root",
+			header:      &tar.Header{Name: "dir/link", Linkname: "../file", Typeflag: tar.TypeSymlink},
+		},
+		{
+			description: "unsafe symlink allowed",
+			header:      &tar.Header{Name: "link", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink},
+			options:     ReaderFSOptions{AllowUnsafeLinks: true},
+		},
+		{
+			description: "char device",
+			header:      &tar.Header{Name: "dev/null", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3},
+			expectErr:   &UnsafeEntryError{Name: "dev/null", Reason: UnsafeDevice},
+		},
+		{
+			description: "block device",
+			header:      &tar.Header{Name: "dev/sda", Typeflag: tar.TypeBlock, Devmajor: 8},
+			expectErr:   &UnsafeEntryError{Name: "dev/sda", Reason: UnsafeDevice},
+		},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			unarchiveFS := newSymlinkFS(t)
+			tc.options.UnarchiveFS = unarchiveFS
+			archive := buildTarHeaders(t, tc.header)
+			fs, err := NewReaderFS(context.Background(), bytes.NewReader(archive), tc.options)
+			assert.NoError(t, err)
+			<-fs.Done()
+			err = fs.UnarchiveErr()
+			if tc.expectErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, ErrUnsafeEntry, err)
+			var unsafeErr *UnsafeEntryError
+			if assert.Equal(t, true, errors.As(err, &unsafeErr)) {
+				assert.Equal(t, tc.expectErr, unsafeErr)
+			}
+		})
+	}
+}
+
+func TestReaderFSParentPathsStayInRoot(t *testing.T) {
+	t.Parallel()
+	archive := buildTar(t, []tarFile{
+		{name: "../../etc/passwd", contents: []byte("root")},
+		{name: "/abs/file", contents: []byte("abs")},
+	})
+	fs, err := NewReaderFS(context.Background(), bytes.NewReader(archive), ReaderFSOptions{AllowParentPaths: true})
+	assert.NoError(t, err)
+	<-fs.Done()
+	assert.NoError(t, fs.UnarchiveErr())
+	contents, err := hackpadfs.ReadFile(fs, "etc/passwd")
+	assert.NoError(t, err)
+	assert.Equal(t, "root", string(contents))
+	contents, err = hackpadfs.ReadFile(fs, "abs/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "abs", string(contents))
+}
+
+func TestReaderFSSymlinks(t *testing.T) {
+	t.Parallel()
+	archive := buildTarHeaders(t,
+		&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0700},
+		&tar.Header{Name: "dir/link", Linkname: "../file", Typeflag: tar.TypeSymlink},
+		&tar.Header{Name: "file", Typeflag: tar.TypeReg},
+	)
+
+	unarchiveFS := newSymlinkFS(t)
+	fs, err := NewReaderFS(context.Background(), bytes.NewReader(archive), ReaderFSOptions{UnarchiveFS: unarchiveFS})
+	assert.NoError(t, err)
+	<-fs.Done()
+	assert.NoError(t, fs.UnarchiveErr())
+	assert.Equal(t, map[string]string{"dir/link": "file"}, unarchiveFS.links)
+
+	unsafeArchive := buildTarHeaders(t,
+		&tar.Header{Name: "abs", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink},
+		&tar.Header{Name: "dir/up", Linkname: "../../etc", Typeflag: tar.TypeSymlink},
+	)
+	unarchiveFS = newSymlinkFS(t)
+	fs, err = NewReaderFS(context.Background(), bytes.NewReader(unsafeArchive), ReaderFSOptions{UnarchiveFS: unarchiveFS, AllowUnsafeLinks: true})
+	assert.NoError(t, err)
+	<-fs.Done()
+	assert.NoError(t, fs.UnarchiveErr())
+	assert.Equal(t, map[string]string{"abs": "etc/passwd", "dir/up": "etc"}, unarchiveFS.links) // resolved from the root
+
+	fs, err = NewReaderFS(context.Background(), bytes.NewReader(archive), ReaderFSOptions{}) // mem.FS doesn't support symlinks
+	assert.NoError(t, err)
+	<-fs.Done()
+	assert.NoError(t, fs.UnarchiveErr())
+	_, err = hackpadfs.Stat(fs, "dir/link")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestReaderFSSymlinksOS(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows requires elevated permissions to create symlinks")
+	}
+	archive := buildTarHeaders(t,
+		&tar.Header{Name: "dir/link", Linkname: "a", Typeflag: tar.TypeSymlink},
+		&tar.Header{Name: "dir/up", Linkname: "..", Typeflag: tar.TypeSymlink},
+		&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0700},
+		&tar.Header{Name: "dir/a", Typeflag: tar.TypeReg},
+		&tar.Header{Name: "file", Typeflag: tar.TypeReg},
+	)
+	unarchiveFS, err := osfs.NewDirFS(t.TempDir())
+	assert.NoError(t, err)
+	fs, err := NewReaderFS(context.Background(), bytes.NewReader(archive), ReaderFSOptions{UnarchiveFS: unarchiveFS})
+	assert.NoError(t, err)
+	<-fs.Done()
+	assert.NoError(t, fs.UnarchiveErr())
+
+	target, err := hackpadfs.Readlink(unarchiveFS, "dir/link")
+	assert.NoError(t, err)
+	assert.Equal(t, "dir/a", target)
+	_, err = hackpadfs.Stat(unarchiveFS, "dir/link")
+	assert.NoError(t, err)
+
+	target, err = hackpadfs.Readlink(unarchiveFS, "dir/up")
+	assert.NoError(t, err)
+	assert.Equal(t, ".", target)
+	_, err = hackpadfs.Stat(unarchiveFS, "dir/up/file")
+	assert.NoError(t, err)
+}
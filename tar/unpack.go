@@ -0,0 +1,97 @@
+package tar
+
+import (
+	"sync"
+
+	"github.com/hack-pad/hackpadfs/internal/bufferpool"
+)
+
+// unpacker writes files to a ReaderFS's UnarchiveFS with a pool of workers.
+// Only the archive reader may acquire buffers and start jobs, so reading pauses once all buffers are in use or all workers are busy.
+type unpacker struct {
+	smallPool, bigPool *bufferpool.Pool // bigPool is nil if ReaderFSOptions.SpillLargeFiles is set
+
+	jobs chan unpackJob
+	wg   sync.WaitGroup
+
+	errMu sync.Mutex
+	err   error
+}
+
+type unpackJob struct {
+	run     func() error
+	buffers []*bufferpool.Buffer // buffers are returned to their pools once the job is done
+}
+
+func newUnpacker(options ReaderFSOptions) *unpacker {
+	// split up to half the memory into big buffers, then a large quantity of small ones make up the remainder
+	bigBufCount := options.MaxMemory / 2 / options.BigBufferSize
+	if bigBufCount < 1 {
+		bigBufCount = 1
+	}
+	if options.SpillLargeFiles {
+		bigBufCount = 0 // large files reuse their small buffer
+	}
+	smallBufCount := (options.MaxMemory - bigBufCount*options.BigBufferSize) / options.SmallBufferSize
+	if smallBufCount < 1 {
+		smallBufCount = 1
+	}
+	// set up some buffer pools to reduce maximum memory usage. small buffers are for every file's first read, big buffers for secondary reads.
+	u := &unpacker{
+		smallPool: bufferpool.New(uint64(options.SmallBufferSize), uint64(smallBufCount)),
+		jobs:      make(chan unpackJob),
+	}
+	if bigBufCount > 0 {
+		u.bigPool = bufferpool.New(uint64(options.BigBufferSize), uint64(bigBufCount))
+	}
+	u.wg.Add(options.Workers)
+	for i := 0; i < options.Workers; i++ {
+		go u.work()
+	}
+	return u
+}
+
+func (u *unpacker) work() {
+	defer u.wg.Done()
+	for job := range u.jobs {
+		if u.Err() == nil {
+			if err := job.run(); err != nil {
+				u.setErr(err)
+			}
+		}
+		releaseBuffers(job.buffers)
+	}
+}
+
+// Go runs 'run' on the next available worker, then releases 'buffers'
+func (u *unpacker) Go(run func() error, buffers ...*bufferpool.Buffer) {
+	u.jobs <- unpackJob{run: run, buffers: buffers}
+}
+
+// Wait stops accepting jobs, waits for the running jobs to finish, then returns the first error from a job
+func (u *unpacker) Wait() error {
+	close(u.jobs)
+	u.wg.Wait()
+	return u.Err()
+}
+
+// Err returns the first error from a job, if any
+func (u *unpacker) Err() error {
+	u.errMu.Lock()
+	defer u.errMu.Unlock()
+	return u.err
+}
+
+func (u *unpacker) setErr(err error) {
+	u.errMu.Lock()
+	if u.err == nil {
+		u.err = err
+	}
+	u.errMu.Unlock()
+}
+
+func releaseBuffers(buffers []*bufferpool.Buffer) {
+	for _, buf := range buffers {
+		buf.Done()
+	}
+}
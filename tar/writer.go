@@ -0,0 +1,63 @@
+package tar
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/fserrors"
+)
+
+// WriteFS writes all directories and regular files in 'src' to 'w' as a tar archive. Other file types are skipped.
+// The resulting archive can be read back with NewReaderFS(). Does not close 'w'.
+func WriteFS(ctx context.Context, w io.Writer, src hackpadfs.FS) (retErr error) {
+	defer func() { retErr = fserrors.WithMessage(retErr, "tar") }()
+
+	archive := tar.NewWriter(w)
+	err := hackpadfs.WalkDir(src, ".", func(name string, dirEntry hackpadfs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+			return archive.WriteHeader(header)
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		return writeFileContents(archive, src, name)
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func writeFileContents(archive *tar.Writer, src hackpadfs.FS, name string) error {
+	f, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(archive, f)
+	return fserrors.WithMessage(err, "copying file")
+}
@@ -0,0 +1,55 @@
+package tar
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestWriteFS(t *testing.T) {
+	t.Parallel()
+	src, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.MkdirAll(src, "foo/bar", 0750))
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "foo/bar/baz", []byte("baz"), 0640))
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "biff", nil, 0600))
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteFS(context.Background(), &buf, src))
+
+	fs, err := NewReaderFS(context.Background(), &buf, ReaderFSOptions{})
+	assert.NoError(t, err)
+	<-fs.Done()
+	assert.NoError(t, fs.UnarchiveErr())
+
+	contents, err := hackpadfs.ReadFile(fs, "foo/bar/baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(contents))
+	for name, mode := range map[string]hackpadfs.FileMode{
+		"foo":         hackpadfs.ModeDir | 0750,
+		"foo/bar":     hackpadfs.ModeDir | 0750,
+		"foo/bar/baz": 0640,
+		"biff":        0600,
+	} {
+		info, err := hackpadfs.Stat(fs, name)
+		assert.NoError(t, err)
+		assert.Equal(t, mode, info.Mode())
+	}
+}
+
+func TestWriteFSCanceled(t *testing.T) {
+	t.Parallel()
+	src, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(src, "foo", []byte("foo"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	err = WriteFS(ctx, &buf, src)
+	assert.ErrorIs(t, context.Canceled, err)
+}
@@ -0,0 +1,249 @@
+package textfs
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChtimeserFile
+	} = &file{}
+)
+
+var errNegativeOffset = errors.New("negative offset")
+
+// buffer is the converted contents of an open text file, shared by all of its open handles
+type buffer struct {
+	name string
+	perm hackpadfs.FileMode
+	refs int // refs is the number of open handles, protected by FS.buffersMu
+
+	mu       sync.Mutex
+	contents []byte
+	dirty    bool
+}
+
+// flush converts the changed contents and writes them to the source FS. Must be called with b.mu held.
+func (b *buffer) flush(fs *FS) error {
+	if !b.dirty {
+		return nil
+	}
+	stored := b.contents
+	if !isBinary(stored) {
+		var err error
+		stored, err = fs.encode(stored)
+		if err != nil {
+			return &hackpadfs.PathError{Op: "write", Path: b.name, Err: err}
+		}
+	}
+	if err := hackpadfs.WriteFullFile(fs.sourceFS, b.name, stored, b.perm); err != nil {
+		return err
+	}
+	b.dirty = false
+	return nil
+}
+
+// file is an open handle to a text file's buffer, which is written to the source FS on Sync() or Close()
+type file struct {
+	hackpadfs.File // File is the source file, used for metadata and to report errors for disallowed operations
+	fs             *FS
+	name           string
+	flags          hackpadfs.Flags
+	buf            *buffer
+
+	// offset and closed are protected by buf.mu
+	offset int64
+	closed bool
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	f.buf.mu.Lock()
+	defer f.buf.mu.Unlock()
+	if f.closed || !f.flags.Read {
+		return f.File.Read(p)
+	}
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	f.buf.mu.Lock()
+	defer f.buf.mu.Unlock()
+	if f.closed || !f.flags.Read {
+		return hackpadfs.ReadAtFile(f.File, p, off)
+	}
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: "readat", Path: f.name, Err: errNegativeOffset}
+	}
+	return f.readAt(p, off)
+}
+
+func (f *file) readAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(f.buf.contents)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.buf.contents[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	f.buf.mu.Lock()
+	defer f.buf.mu.Unlock()
+	if f.closed || !f.flags.Write {
+		return hackpadfs.WriteFile(f.File, p)
+	}
+	if f.flags.Append {
+		f.offset = int64(len(f.buf.contents))
+	}
+	n := f.writeAt(p, f.offset)
+	f.offset += int64(n)
+	return n, f.syncIfRequested()
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	f.buf.mu.Lock()
+	defer f.buf.mu.Unlock()
+	if f.closed || !f.flags.Write {
+		return hackpadfs.WriteAtFile(f.File, p, off)
+	}
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: "writeat", Path: f.name, Err: errNegativeOffset}
+	}
+	if f.flags.Append {
+		return 0, &hackpadfs.PathError{Op: "writeat", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	n := f.writeAt(p, off)
+	return n, f.syncIfRequested()
+}
+
+func (f *file) writeAt(p []byte, off int64) int {
+	if end := off + int64(len(p)); end > int64(len(f.buf.contents)) {
+		contents := make([]byte, end)
+		copy(contents, f.buf.contents)
+		f.buf.contents = contents
+	}
+	f.buf.dirty = true
+	return copy(f.buf.contents[off:], p)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.buf.mu.Lock()
+	defer f.buf.mu.Unlock()
+	if f.closed {
+		return hackpadfs.SeekFile(f.File, offset, whence)
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.buf.contents))
+	default:
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *file) Truncate(size int64) error {
+	f.buf.mu.Lock()
+	defer f.buf.mu.Unlock()
+	if f.closed || !f.flags.Write {
+		return hackpadfs.TruncateFile(f.File, size)
+	}
+	if size < 0 {
+		return &hackpadfs.PathError{Op: "truncate", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	contents := make([]byte, size)
+	copy(contents, f.buf.contents)
+	f.buf.contents = contents
+	f.buf.dirty = true
+	return f.syncIfRequested()
+}
+
+func (f *file) Sync() error {
+	f.buf.mu.Lock()
+	defer f.buf.mu.Unlock()
+	if f.closed {
+		return hackpadfs.SyncFile(f.File)
+	}
+	return f.buf.flush(f.fs)
+}
+
+// syncIfRequested flushes after every change if the file was opened with hackpadfs.FlagSync. Must be called with f.buf.mu held.
+func (f *file) syncIfRequested() error {
+	if f.flags.Sync {
+		return f.buf.flush(f.fs)
+	}
+	return nil
+}
+
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	f.buf.mu.Lock()
+	defer f.buf.mu.Unlock()
+	return &fileInfo{FileInfo: info, size: int64(len(f.buf.contents))}, nil
+}
+
+func (f *file) Close() error {
+	f.buf.mu.Lock()
+	defer f.buf.mu.Unlock()
+	if f.closed {
+		return f.File.Close()
+	}
+	f.closed = true
+	flushErr := f.buf.flush(f.fs)
+	f.fs.releaseBuffer(f.buf)
+	err := f.File.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return err
+}
+
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	return hackpadfs.ChmodFile(f.File, mode)
+}
+
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	f.buf.mu.Lock()
+	err := f.buf.flush(f.fs) // flush first, so later flushes don't overwrite the new times
+	f.buf.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return hackpadfs.ChtimesFile(f.File, atime, mtime)
+}
+
+// fileInfo reports the size of an open file's converted contents
+type fileInfo struct {
+	hackpadfs.FileInfo
+	size int64
+}
+
+func (f *fileInfo) Size() int64 {
+	return f.size
+}
@@ -0,0 +1,251 @@
+// Package textfs contains a file system wrapper which normalizes text files' line endings and encodings, similar to git's autocrlf setting.
+package textfs
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fsmatch"
+	"golang.org/x/text/encoding"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+	} = &FS{}
+)
+
+// binaryCheckSize is the number of bytes checked for a NUL byte to detect binary files, the same as git
+const binaryCheckSize = 8000
+
+// Newline is a line ending
+type Newline string
+
+// Line endings for Options.Newline
+const (
+	LF   Newline = "\n"
+	CRLF Newline = "\r\n"
+)
+
+// Options contain options for creating an FS
+type Options struct {
+	// Patterns selects the files to convert, using fsmatch syntax like "**/*.{txt,md}". Defaults to every file.
+	Patterns []string
+	// Newline is the line ending written to the source FS. Defaults to LF, so files are normalized as they're rewritten.
+	// Set to CRLF to keep Windows line endings in the source FS. Either way, reads always return LF line endings.
+	Newline Newline
+	// Encoding is the text encoding of files in the source FS, like charmap.Windows1252.
+	// Reads decode files to UTF-8 and writes encode UTF-8 back to Encoding. Defaults to UTF-8, which is not transcoded.
+	Encoding encoding.Encoding
+}
+
+// FS wraps a source FS, converting the contents of text files matching the configured patterns as they're read and written.
+//
+// Matching files are read fully into memory and converted when opened, then converted back and written to the source FS on Sync() or Close().
+// Files which look binary, having a NUL byte near the beginning after decoding, are read and written unchanged.
+//
+// Open handles to the same path share their converted contents, so they see each other's writes.
+// Sizes reported by Stat() and ReadDir() are of the files in the source FS, while sizes from an open file's Stat() are of its converted contents.
+type FS struct {
+	sourceFS hackpadfs.FS
+	matcher  *fsmatch.Matcher
+	options  Options
+
+	buffersMu sync.Mutex
+	buffers   map[string]*buffer // buffers holds the contents of open text files by path
+}
+
+// NewFS returns a new FS wrapping 'source'. Fails if a pattern is malformed.
+func NewFS(source hackpadfs.FS, options Options) (*FS, error) {
+	if options.Newline == "" {
+		options.Newline = LF
+	}
+	if options.Newline != LF && options.Newline != CRLF {
+		return nil, errors.New("textfs: newline must be LF or CRLF")
+	}
+	matcher, err := fsmatch.Compile(options.Patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return &FS{
+		sourceFS: source,
+		matcher:  matcher,
+		options:  options,
+		buffers:  make(map[string]*buffer),
+	}, nil
+}
+
+// decode converts 'stored' contents to UTF-8 with LF line endings
+func (fs *FS) decode(stored []byte) ([]byte, error) {
+	contents := stored
+	if fs.options.Encoding != nil {
+		var err error
+		contents, err = fs.options.Encoding.NewDecoder().Bytes(stored)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bytes.ReplaceAll(contents, []byte(CRLF), []byte(LF)), nil
+}
+
+// encode converts 'contents' to the source FS's line endings and encoding
+func (fs *FS) encode(contents []byte) ([]byte, error) {
+	contents = bytes.ReplaceAll(contents, []byte(CRLF), []byte(LF))
+	if fs.options.Newline == CRLF {
+		contents = bytes.ReplaceAll(contents, []byte(LF), []byte(CRLF))
+	}
+	if fs.options.Encoding != nil {
+		return fs.options.Encoding.NewEncoder().Bytes(contents)
+	}
+	return contents, nil
+}
+
+func isBinary(contents []byte) bool {
+	if len(contents) > binaryCheckSize {
+		contents = contents[:binaryCheckSize]
+	}
+	return bytes.IndexByte(contents, 0) != -1
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	sourceFile, err := hackpadfs.OpenFile(fs.sourceFS, name, flag, perm)
+	if err != nil || !fs.matcher.Match(name) {
+		return sourceFile, err
+	}
+	info, err := sourceFile.Stat()
+	if err != nil {
+		_ = sourceFile.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return sourceFile, nil
+	}
+
+	flags := hackpadfs.ParseFlags(flag)
+	buf, err := fs.acquireBuffer(name, info.Mode().Perm(), flags.Truncate)
+	if err != nil {
+		_ = sourceFile.Close()
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if buf == nil {
+		return sourceFile, nil // binary files are passed through
+	}
+	f := &file{
+		File:  sourceFile,
+		fs:    fs,
+		name:  name,
+		flags: flags,
+		buf:   buf,
+	}
+	if flags.Append {
+		f.offset = int64(len(buf.contents))
+	}
+	return f, nil
+}
+
+// acquireBuffer returns the shared buffer for 'name', reading and converting it from the source FS if it isn't already open. Returns nil if the file is binary.
+func (fs *FS) acquireBuffer(name string, perm hackpadfs.FileMode, truncate bool) (*buffer, error) {
+	fs.buffersMu.Lock()
+	defer fs.buffersMu.Unlock()
+	buf, ok := fs.buffers[name]
+	if ok {
+		if truncate {
+			buf.mu.Lock()
+			buf.contents = nil
+			buf.dirty = true
+			buf.mu.Unlock()
+		}
+	} else {
+		var contents []byte
+		if !truncate {
+			stored, err := hackpadfs.ReadFile(fs.sourceFS, name)
+			if err == nil {
+				contents, err = fs.decode(stored)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if isBinary(contents) {
+				return nil, nil
+			}
+		}
+		buf = &buffer{name: name, perm: perm, contents: contents}
+		fs.buffers[name] = buf
+	}
+	buf.refs++
+	return buf, nil
+}
+
+// releaseBuffer drops a reference to 'buf', forgetting it once its last handle is closed
+func (fs *FS) releaseBuffer(buf *buffer) {
+	fs.buffersMu.Lock()
+	defer fs.buffersMu.Unlock()
+	buf.refs--
+	if buf.refs == 0 {
+		delete(fs.buffers, buf.name)
+	}
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return hackpadfs.Mkdir(fs.sourceFS, name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return hackpadfs.MkdirAll(fs.sourceFS, path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	return hackpadfs.Remove(fs.sourceFS, name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	return hackpadfs.RemoveAll(fs.sourceFS, path)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	return hackpadfs.Rename(fs.sourceFS, oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Stat(fs.sourceFS, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return hackpadfs.Chmod(fs.sourceFS, name, mode)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return hackpadfs.Chtimes(fs.sourceFS, name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDir(fs.sourceFS, name)
+}
@@ -0,0 +1,147 @@
+package textfs
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func newFS(tb testing.TB, options Options) (*FS, *mem.FS) {
+	tb.Helper()
+	source, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	fs, err := NewFS(source, options)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs, source
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "textfs",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, _ := newFS(tb, Options{})
+			return fs
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description    string
+		options        Options
+		name           string
+		stored         string
+		expectRead     string
+		write          string
+		expectedStored string
+	}{
+		{
+			description:    "normalize to LF",
+			name:           "file.txt",
+			stored:         "a\r\nb\r\n",
+			expectRead:     "a\nb\n",
+			write:          "c\nd\r\n",
+			expectedStored: "c\nd\n",
+		},
+		{
+			description:    "keep CRLF",
+			options:        Options{Newline: CRLF},
+			name:           "file.txt",
+			stored:         "a\r\nb\n",
+			expectRead:     "a\nb\n",
+			write:          "c\nd\r\n",
+			expectedStored: "c\r\nd\r\n",
+		},
+		{
+			description:    "not matched",
+			options:        Options{Patterns: []string{"**/*.md"}},
+			name:           "file.txt",
+			stored:         "a\r\n",
+			expectRead:     "a\r\n",
+			write:          "b\r\n",
+			expectedStored: "b\r\n",
+		},
+		{
+			description:    "binary",
+			name:           "file.bin",
+			stored:         "a\r\n\x00",
+			expectRead:     "a\r\n\x00",
+			write:          "b\r\n\x00",
+			expectedStored: "b\r\n\x00",
+		},
+		{
+			description:    "transcode",
+			options:        Options{Encoding: charmap.Windows1252},
+			name:           "file.txt",
+			stored:         "caf\xe9\r\n",
+			expectRead:     "café\n",
+			write:          "€5\n",
+			expectedStored: "\x805\n",
+		},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			fs, source := newFS(t, tc.options)
+			assert.NoError(t, hackpadfs.WriteFullFile(source, tc.name, []byte(tc.stored), 0600))
+
+			contents, err := hackpadfs.ReadFile(fs, tc.name)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectRead, string(contents))
+
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, tc.name, []byte(tc.write), 0600))
+			stored, err := hackpadfs.ReadFile(source, tc.name)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStored, string(stored))
+		})
+	}
+}
+
+func TestOpenFileBuffers(t *testing.T) {
+	t.Parallel()
+	fs, source := newFS(t, Options{Newline: CRLF})
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "file.txt", []byte("a\r\nb\r\n"), 0600))
+
+	f, err := fs.OpenFile("file.txt", hackpadfs.FlagReadWrite|hackpadfs.FlagAppend, 0)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("a\nb\n")), info.Size())
+
+	_, err = hackpadfs.WriteFile(f, []byte("c\n"))
+	assert.NoError(t, err)
+	stored, err := hackpadfs.ReadFile(source, "file.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "a\r\nb\r\n", string(stored))
+
+	assert.NoError(t, hackpadfs.SyncFile(f))
+	stored, err = hackpadfs.ReadFile(source, "file.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "a\r\nb\r\nc\r\n", string(stored))
+	assert.NoError(t, f.Close())
+}
+
+func TestNewFSErrors(t *testing.T) {
+	t.Parallel()
+	source, err := mem.NewFS()
+	assert.NoError(t, err)
+	_, err = NewFS(source, Options{Newline: "\r"})
+	assert.Error(t, err)
+	_, err = NewFS(source, Options{Patterns: []string{"["}})
+	assert.Error(t, err)
+}
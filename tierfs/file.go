@@ -0,0 +1,96 @@
+package tierfs
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// file wraps a file in one tier, tracking it as open until closed. Directories list their entries from every tier.
+type file struct {
+	hackpadfs.File
+	fs        *FS
+	name      string // name is guarded by fs.openMu, since renames update it
+	isDir     bool
+	closeOnce sync.Once
+
+	entries    []hackpadfs.DirEntry // entries holds a directory's remaining entries, once listed
+	entriesErr error
+	listed     bool
+}
+
+func (f *file) Close() error {
+	err := f.File.Close()
+	f.closeOnce.Do(func() {
+		f.fs.removeOpen(f)
+	})
+	return err
+}
+
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	if !f.isDir {
+		return hackpadfs.ReadDirFile(f.File, n)
+	}
+	if !f.listed {
+		f.listed = true
+		f.fs.openMu.Lock()
+		name := f.name
+		f.fs.openMu.Unlock()
+		f.entries, f.entriesErr = f.fs.ReadDir(name)
+	}
+	if f.entriesErr != nil {
+		return nil, f.entriesErr
+	}
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	entries := f.entries[:n:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+func (f *file) ReadAt(p []byte, off int64) (n int, err error) {
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+func (f *file) Write(p []byte) (n int, err error) {
+	return hackpadfs.WriteFile(f.File, p)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (n int, err error) {
+	return hackpadfs.WriteAtFile(f.File, p, off)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+func (f *file) Sync() error {
+	return hackpadfs.SyncFile(f.File)
+}
+
+func (f *file) Truncate(size int64) error {
+	return hackpadfs.TruncateFile(f.File, size)
+}
+
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	return hackpadfs.ChmodFile(f.File, mode)
+}
+
+func (f *file) Chown(uid, gid int) error {
+	return hackpadfs.ChownFile(f.File, uid, gid)
+}
+
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	return hackpadfs.ChtimesFile(f.File, atime, mtime)
+}
@@ -0,0 +1,636 @@
+// Package tierfs contains a file system which spreads files across tiers of storage, like a small, fast memory FS in front of a large, slow persistent FS.
+package tierfs
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.WriteFileFS
+		hackpadfs.CloserFS
+		hackpadfs.PingFS
+	} = &FS{}
+)
+
+// FS stores each new file in the first tier with space available, then reads and writes it in whichever tier holds it.
+// Demotion moves files selected by a DemotePolicy down to the next tier, freeing space in the faster tiers.
+//
+// Each file is kept in exactly one tier. Directories are created in each tier as needed to hold its files, and listing a directory merges its entries from every tier.
+// Writes to an existing file stay in its tier, even once the tier is full. Open files are not demoted until closed.
+type FS struct {
+	tiers   []hackpadfs.FS
+	options Options
+
+	moveMu sync.RWMutex // moveMu is locked for writing while demoting a file, so other operations never see it in two tiers or none
+
+	openMu    sync.Mutex
+	openFiles map[*file]struct{}
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// DemotePolicy reports whether the regular file 'name' in tier number 'tier' should move to the next tier
+type DemotePolicy func(tier int, name string, info hackpadfs.FileInfo) bool
+
+// DemoteOlderThan returns a DemotePolicy which demotes files not modified in the last 'age'
+func DemoteOlderThan(age time.Duration) DemotePolicy {
+	return func(tier int, name string, info hackpadfs.FileInfo) bool {
+		return time.Since(info.ModTime()) > age
+	}
+}
+
+// Options contain options for creating an FS
+type Options struct {
+	// HasSpace reports whether 'tier' can store a new file of 'size' bytes. 'size' is 0 when unknown, like when creating a file with OpenFile.
+	// Defaults to checking hackpadfs.Usage(): a tier has space if its usage is under its quota, or if it doesn't report a quota.
+	HasSpace func(tier hackpadfs.FS, size int64) bool
+	// Demote selects files to move down a tier during Demote(). Demotion is disabled if nil.
+	Demote DemotePolicy
+	// DemoteInterval runs Demote() in the background this often. Demotion only runs when called if 0.
+	DemoteInterval time.Duration
+	// OnError is called with each error from background demotion.
+	OnError func(err error)
+}
+
+// NewFS returns a new FS storing files in 'tiers', fastest first
+func NewFS(tiers []hackpadfs.FS, options Options) (*FS, error) {
+	if len(tiers) == 0 {
+		return nil, errors.New("tierfs: at least one tier is required")
+	}
+	if options.HasSpace == nil {
+		options.HasSpace = hasQuotaSpace
+	}
+	fs := &FS{
+		tiers:     tiers,
+		options:   options,
+		openFiles: make(map[*file]struct{}),
+	}
+	if options.Demote != nil && options.DemoteInterval > 0 {
+		fs.stop = make(chan struct{})
+		fs.done = make(chan struct{})
+		go fs.run()
+	}
+	return fs, nil
+}
+
+func hasQuotaSpace(tier hackpadfs.FS, size int64) bool {
+	usage, err := hackpadfs.Usage(context.Background(), tier)
+	if err != nil || usage.Quota == 0 {
+		return true
+	}
+	return usage.Used+size < usage.Quota
+}
+
+func (fs *FS) run() {
+	defer close(fs.done)
+	ticker := time.NewTicker(fs.options.DemoteInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fs.stop:
+			return
+		case <-ticker.C:
+			if err := fs.Demote(); err != nil && fs.options.OnError != nil {
+				fs.options.OnError(err)
+			}
+		}
+	}
+}
+
+// Close stops background demotion
+func (fs *FS) Close() error {
+	if fs.stop != nil {
+		fs.closeOnce.Do(func() {
+			close(fs.stop)
+		})
+		<-fs.done
+	}
+	return nil
+}
+
+// Ping implements hackpadfs.PingFS, checking every tier
+func (fs *FS) Ping(ctx context.Context) error {
+	for _, tier := range fs.tiers {
+		if err := hackpadfs.Ping(ctx, tier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// find returns the first tier holding 'name' and its FileInfo.
+// If no tier holds 'name', returns tier -1. Operations on missing files should run on the first tier to report its error.
+func (fs *FS) find(name string) (int, hackpadfs.FileInfo, error) {
+	for i, tier := range fs.tiers {
+		info, err := hackpadfs.Stat(tier, name)
+		switch {
+		case err == nil:
+			return i, info, nil
+		case !errors.Is(err, hackpadfs.ErrNotExist):
+			return -1, nil, pathErrCause(err)
+		}
+	}
+	return -1, nil, nil
+}
+
+// holding returns every tier holding 'name'
+func (fs *FS) holding(name string) ([]int, error) {
+	var tiers []int
+	for i, tier := range fs.tiers {
+		_, err := hackpadfs.Stat(tier, name)
+		switch {
+		case err == nil:
+			tiers = append(tiers, i)
+		case !errors.Is(err, hackpadfs.ErrNotExist):
+			return nil, pathErrCause(err)
+		}
+	}
+	return tiers, nil
+}
+
+// pathErrCause returns the cause of a tier's *hackpadfs.PathError, to report it under this FS's operation instead
+func pathErrCause(err error) error {
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+// placement returns the tiers to try storing a new file of 'size' bytes, fastest first. The last tier is always included.
+func (fs *FS) placement(size int64) []int {
+	var tiers []int
+	for i, tier := range fs.tiers[:len(fs.tiers)-1] {
+		if fs.options.HasSpace(tier, size) {
+			tiers = append(tiers, i)
+		}
+	}
+	return append(tiers, len(fs.tiers)-1)
+}
+
+// ensureParents creates the missing parent directories of 'name' in 'tier', copying their permissions from the tiers which hold them.
+// Parents which don't exist in any tier are left missing, for the following operation to report.
+func (fs *FS) ensureParents(tier int, name string) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+	if _, err := hackpadfs.Stat(fs.tiers[tier], dir); err == nil || !errors.Is(err, hackpadfs.ErrNotExist) {
+		return pathErrCause(err)
+	}
+	_, info, err := fs.find(dir)
+	if err != nil || info == nil || !info.IsDir() {
+		return err
+	}
+	if err := fs.ensureParents(tier, dir); err != nil {
+		return err
+	}
+	err = hackpadfs.Mkdir(fs.tiers[tier], dir, info.Mode().Perm())
+	if errors.Is(err, hackpadfs.ErrExist) {
+		err = nil
+	}
+	return err
+}
+
+func (fs *FS) addOpen(f *file) {
+	fs.openMu.Lock()
+	fs.openFiles[f] = struct{}{}
+	fs.openMu.Unlock()
+}
+
+func (fs *FS) removeOpen(f *file) {
+	fs.openMu.Lock()
+	delete(fs.openFiles, f)
+	fs.openMu.Unlock()
+}
+
+func (fs *FS) isOpen(name string) bool {
+	fs.openMu.Lock()
+	defer fs.openMu.Unlock()
+	for f := range fs.openFiles {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// renameOpen updates the names of open files at or under 'oldname' after a rename
+func (fs *FS) renameOpen(oldname, newname string) {
+	fs.openMu.Lock()
+	defer fs.openMu.Unlock()
+	for f := range fs.openFiles {
+		switch {
+		case f.name == oldname:
+			f.name = newname
+		case strings.HasPrefix(f.name, oldname+"/"):
+			f.name = newname + strings.TrimPrefix(f.name, oldname)
+		}
+	}
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	fs.moveMu.RLock()
+	defer fs.moveMu.RUnlock()
+	tier, info, err := fs.find(name)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+	}
+	isDir := info != nil && info.IsDir()
+	switch {
+	case tier >= 0:
+	case flag&hackpadfs.FlagCreate != 0:
+		tier = fs.placement(0)[0]
+		if err := fs.ensureParents(tier, name); err != nil {
+			return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+		}
+	default:
+		tier = 0
+	}
+	f, err := hackpadfs.OpenFile(fs.tiers[tier], name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	tierFile := &file{File: f, fs: fs, name: name, isDir: isDir}
+	fs.addOpen(tierFile)
+	return tierFile, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	fs.moveMu.RLock()
+	defer fs.moveMu.RUnlock()
+	tier, _, err := fs.find(name)
+	switch {
+	case err != nil:
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: err}
+	case tier >= 0:
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: hackpadfs.ErrExist}
+	}
+	if err := fs.ensureParents(0, name); err != nil {
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return hackpadfs.Mkdir(fs.tiers[0], name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(name string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "mkdirall", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	fs.moveMu.RLock()
+	defer fs.moveMu.RUnlock()
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		// a file in any tier blocks creating its path in the first tier
+		tier, info, err := fs.find(dir)
+		switch {
+		case err != nil:
+			return &hackpadfs.PathError{Op: "mkdir", Path: dir, Err: err}
+		case tier < 0:
+			continue
+		case !info.IsDir():
+			return &hackpadfs.PathError{Op: "mkdir", Path: dir, Err: hackpadfs.ErrNotDir}
+		case dir == name:
+			return nil
+		}
+		break
+	}
+	if err := fs.ensureParents(0, name); err != nil {
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return hackpadfs.MkdirAll(fs.tiers[0], name, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	fs.moveMu.RLock()
+	defer fs.moveMu.RUnlock()
+	tiers, err := fs.holding(name)
+	if err != nil {
+		return &hackpadfs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	if len(tiers) == 0 {
+		return hackpadfs.Remove(fs.tiers[0], name)
+	}
+	if len(tiers) > 1 { // only directories are held by several tiers
+		entries, err := fs.readDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrNotEmpty}
+		}
+	}
+	for _, tier := range tiers {
+		if err := hackpadfs.Remove(fs.tiers[tier], name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	fs.moveMu.RLock()
+	defer fs.moveMu.RUnlock()
+	oldTiers, err := fs.holding(oldname)
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	if len(oldTiers) == 0 {
+		return hackpadfs.Rename(fs.tiers[0], oldname, newname)
+	}
+	newTiers, err := fs.holding(newname)
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	if len(newTiers) > 0 && oldname != newname {
+		// Replace 'newname' in the tiers which don't hold 'oldname', leaving the rest for each tier's Rename.
+		// Only empty directories and files may be replaced, so check the directory across all tiers first.
+		if len(newTiers) > 1 {
+			entries, err := fs.readDir(newname)
+			if err != nil {
+				return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+			}
+			if len(entries) > 0 {
+				return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrExist}
+			}
+		}
+		for _, tier := range newTiers {
+			if !containsInt(oldTiers, tier) {
+				if err := hackpadfs.Remove(fs.tiers[tier], newname); err != nil {
+					return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+				}
+			}
+		}
+	}
+	for _, tier := range oldTiers {
+		if err := fs.ensureParents(tier, newname); err != nil {
+			return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+		}
+		if err := hackpadfs.Rename(fs.tiers[tier], oldname, newname); err != nil {
+			return err
+		}
+	}
+	fs.renameOpen(oldname, newname)
+	return nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	fs.moveMu.RLock()
+	defer fs.moveMu.RUnlock()
+	tier, info, err := fs.find(name)
+	switch {
+	case err != nil:
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: err}
+	case tier < 0:
+		return hackpadfs.Stat(fs.tiers[0], name)
+	}
+	return info, nil
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return fs.each("chmod", name, func(tier hackpadfs.FS) error {
+		return hackpadfs.Chmod(tier, name, mode)
+	})
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.each("chtimes", name, func(tier hackpadfs.FS) error {
+		return hackpadfs.Chtimes(tier, name, atime, mtime)
+	})
+}
+
+// each runs 'apply' on every tier holding 'name', or the first tier if none do
+func (fs *FS) each(op, name string, apply func(tier hackpadfs.FS) error) error {
+	fs.moveMu.RLock()
+	defer fs.moveMu.RUnlock()
+	tiers, err := fs.holding(name)
+	if err != nil {
+		return &hackpadfs.PathError{Op: op, Path: name, Err: err}
+	}
+	if len(tiers) == 0 {
+		return apply(fs.tiers[0])
+	}
+	for _, tier := range tiers {
+		if err := apply(fs.tiers[tier]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	fs.moveMu.RLock()
+	defer fs.moveMu.RUnlock()
+	return fs.readDir(name)
+}
+
+// readDir returns the entries of 'name' from every tier, sorted by name
+func (fs *FS) readDir(name string) ([]hackpadfs.DirEntry, error) {
+	var (
+		entries []hackpadfs.DirEntry
+		seen    = make(map[string]bool)
+		found   bool
+	)
+	for _, tier := range fs.tiers {
+		tierEntries, err := hackpadfs.ReadDir(tier, name)
+		switch {
+		case errors.Is(err, hackpadfs.ErrNotExist):
+			continue
+		case err != nil:
+			return nil, err
+		}
+		found = true
+		for _, entry := range tierEntries {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if !found {
+		return hackpadfs.ReadDir(fs.tiers[0], name)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Name() < entries[b].Name()
+	})
+	return entries, nil
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	fs.moveMu.RLock()
+	defer fs.moveMu.RUnlock()
+	tier, _, err := fs.find(name)
+	switch {
+	case err != nil:
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+	case tier < 0:
+		tier = 0
+	}
+	return hackpadfs.ReadFile(fs.tiers[tier], name)
+}
+
+// WriteFile implements hackpadfs.WriteFileFS
+// New files go to the first tier with space for 'data', moving on to the next tier if a write fails with hackpadfs.ErrNoSpace.
+func (fs *FS) WriteFile(name string, data []byte, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	fs.moveMu.RLock()
+	defer fs.moveMu.RUnlock()
+	tier, _, err := fs.find(name)
+	switch {
+	case err != nil:
+		return &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+	case tier >= 0:
+		return hackpadfs.WriteFullFile(fs.tiers[tier], name, data, perm)
+	}
+	for _, tier := range fs.placement(int64(len(data))) {
+		if err = fs.ensureParents(tier, name); err != nil {
+			return &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+		}
+		err = hackpadfs.WriteFullFile(fs.tiers[tier], name, data, perm)
+		if !errors.Is(err, hackpadfs.ErrNoSpace) {
+			return err
+		}
+		_ = hackpadfs.Remove(fs.tiers[tier], name) // remove the partial file before trying the next tier
+	}
+	return err
+}
+
+// Demote runs one demotion pass immediately, moving each closed file selected by Options.Demote down one tier.
+// Returns the first error encountered, after attempting to move every selected file.
+func (fs *FS) Demote() error {
+	if fs.options.Demote == nil {
+		return nil
+	}
+	var firstErr error
+	for tier := len(fs.tiers) - 2; tier >= 0; tier-- { // start from the slowest tiers, so each file moves at most once per pass
+		names, err := fs.demotable(tier)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		for _, name := range names {
+			if err := fs.demoteFile(tier, name); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// demotable returns the regular files in 'tier' selected by Options.Demote
+func (fs *FS) demotable(tier int) ([]string, error) {
+	fs.moveMu.RLock()
+	defer fs.moveMu.RUnlock()
+	var names []string
+	err := hackpadfs.WalkDir(fs.tiers[tier], ".", func(name string, dirEntry hackpadfs.DirEntry, err error) error {
+		if err != nil || !dirEntry.Type().IsRegular() {
+			return err
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+		if fs.options.Demote(tier, name, info) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// demoteFile moves the file 'name' from 'tier' to the next tier, unless it's open or was removed
+func (fs *FS) demoteFile(tier int, name string) error {
+	fs.moveMu.Lock()
+	defer fs.moveMu.Unlock()
+	if fs.isOpen(name) {
+		return nil
+	}
+	src, dest := fs.tiers[tier], fs.tiers[tier+1]
+	info, err := hackpadfs.Stat(src, name)
+	switch {
+	case errors.Is(err, hackpadfs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case !info.Mode().IsRegular():
+		return nil
+	}
+	data, err := hackpadfs.ReadFile(src, name)
+	if err != nil {
+		return err
+	}
+	if err := fs.ensureParents(tier+1, name); err != nil {
+		return err
+	}
+	if err := copyFile(dest, name, data, info); err != nil {
+		_ = hackpadfs.Remove(dest, name) // keep the file only in 'src'
+		return err
+	}
+	return hackpadfs.Remove(src, name)
+}
+
+// copyFile writes 'data' to 'name' in 'fs', with the mode and modified time from 'info'
+func copyFile(fs hackpadfs.FS, name string, data []byte, info hackpadfs.FileInfo) error {
+	if err := hackpadfs.WriteFullFile(fs, name, data, info.Mode()); err != nil {
+		return err
+	}
+	if err := ignoreNotImplemented(hackpadfs.Chmod(fs, name, info.Mode())); err != nil {
+		return err
+	}
+	return ignoreNotImplemented(hackpadfs.Chtimes(fs, name, info.ModTime(), info.ModTime()))
+}
+
+func ignoreNotImplemented(err error) error {
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,231 @@
+package tierfs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newMemFS(tb testing.TB) *mem.FS {
+	tb.Helper()
+	fs, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func newFS(tb testing.TB, options Options) (*FS, *mem.FS, *mem.FS) {
+	tb.Helper()
+	fast, slow := newMemFS(tb), newMemFS(tb)
+	fs, err := NewFS([]hackpadfs.FS{fast, slow}, options)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	tb.Cleanup(func() {
+		assert.NoError(tb, fs.Close())
+	})
+	return fs, fast, slow
+}
+
+func noSpace(hackpadfs.FS, int64) bool {
+	return false
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	for _, full := range []bool{false, true} {
+		full := full
+		name := "tierfs fast tier"
+		var hasSpace func(hackpadfs.FS, int64) bool
+		if full {
+			name = "tierfs slow tier"
+			hasSpace = noSpace // places directories in the fast tier, but files in the slow tier
+		}
+		options := fstest.FSOptions{
+			Name: name,
+			TestFS: func(tb testing.TB) fstest.SetupFS {
+				fs, _, _ := newFS(tb, Options{HasSpace: hasSpace})
+				return fs
+			},
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+		fstest.StdFS(t, options)
+	}
+}
+
+type quotaFS struct {
+	*mem.FS
+	usage hackpadfs.StorageUsage
+}
+
+func (fs *quotaFS) Usage(ctx context.Context) (hackpadfs.StorageUsage, error) {
+	return fs.usage, nil
+}
+
+func TestPlacement(t *testing.T) {
+	t.Parallel()
+	fast := &quotaFS{FS: newMemFS(t), usage: hackpadfs.StorageUsage{Used: 5, Quota: 10}}
+	slow := newMemFS(t)
+	fs, err := NewFS([]hackpadfs.FS{fast, slow}, Options{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, fs.Mkdir("foo", 0700))
+	assert.NoError(t, fs.WriteFile("foo/small", []byte("1234"), 0600))
+	assert.NoError(t, fs.WriteFile("foo/large", []byte("12345"), 0600))
+	_, err = hackpadfs.Stat(fast, "foo/small")
+	assert.NoError(t, err)
+	_, err = hackpadfs.Stat(slow, "foo/large")
+	assert.NoError(t, err)
+	info, err := hackpadfs.Stat(slow, "foo")
+	if assert.NoError(t, err) {
+		assert.Equal(t, hackpadfs.ModeDir|0700, info.Mode())
+	}
+
+	entries, err := fs.ReadDir("foo")
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"large", "small"}, names)
+	contents, err := fs.ReadFile("foo/large")
+	assert.NoError(t, err)
+	assert.Equal(t, "12345", string(contents))
+
+	assert.NoError(t, fs.WriteFile("foo/large", []byte("1"), 0600))
+	_, err = hackpadfs.Stat(fast, "foo/large")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err) // existing files stay in their tier
+}
+
+type noSpaceFS struct {
+	*mem.FS
+}
+
+func (fs *noSpaceFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	f, err := fs.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &noSpaceFile{File: f}, nil
+}
+
+type noSpaceFile struct {
+	hackpadfs.File
+}
+
+func (f *noSpaceFile) Write(p []byte) (int, error) {
+	return 0, hackpadfs.ErrNoSpace
+}
+
+func TestWriteFileNoSpace(t *testing.T) {
+	t.Parallel()
+	fast, slow := &noSpaceFS{FS: newMemFS(t)}, newMemFS(t)
+	fs, err := NewFS([]hackpadfs.FS{fast, slow}, Options{})
+	assert.NoError(t, err)
+	assert.NoError(t, fs.WriteFile("foo", []byte("foo"), 0600))
+	_, err = hackpadfs.Stat(fast, "foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	contents, err := hackpadfs.ReadFile(slow, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(contents))
+}
+
+func TestRenameAcrossTiers(t *testing.T) {
+	t.Parallel()
+	fs, fast, slow := newFS(t, Options{})
+	assert.NoError(t, fs.Mkdir("dir", 0700))
+	assert.NoError(t, fs.WriteFile("dir/fast", []byte("fast"), 0600))
+	assert.NoError(t, hackpadfs.MkdirAll(slow, "dir", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(slow, "dir/slow", []byte("slow"), 0600))
+
+	assert.NoError(t, fs.Rename("dir/fast", "dir/slow"))
+	contents, err := fs.ReadFile("dir/slow")
+	assert.NoError(t, err)
+	assert.Equal(t, "fast", string(contents))
+	_, err = hackpadfs.Stat(slow, "dir/slow")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	assert.NoError(t, fs.Rename("dir", "moved"))
+	_, err = hackpadfs.Stat(fast, "moved/slow")
+	assert.NoError(t, err)
+	_, err = hackpadfs.Stat(slow, "moved")
+	assert.NoError(t, err)
+	_, err = fs.Stat("dir")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	assert.NoError(t, hackpadfs.WriteFullFile(slow, "moved/other", nil, 0600))
+	err = fs.Remove("moved")
+	assert.ErrorIs(t, hackpadfs.ErrNotEmpty, err)
+}
+
+func TestDemote(t *testing.T) {
+	t.Parallel()
+	var demoted []string
+	fs, fast, slow := newFS(t, Options{
+		Demote: func(tier int, name string, info hackpadfs.FileInfo) bool {
+			demoted = append(demoted, name)
+			return strings.HasPrefix(name, "foo/old")
+		},
+	})
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, fs.Mkdir("foo", 0750))
+	assert.NoError(t, fs.WriteFile("foo/old", []byte("old"), 0640))
+	assert.NoError(t, fs.Chtimes("foo/old", modTime, modTime))
+	assert.NoError(t, fs.WriteFile("foo/new", []byte("new"), 0600))
+	assert.NoError(t, fs.WriteFile("foo/old-open", []byte("open"), 0600))
+	f, err := fs.Open("foo/old-open")
+	assert.NoError(t, err)
+
+	assert.NoError(t, fs.Demote())
+	assert.Equal(t, []string{"foo/new", "foo/old", "foo/old-open"}, demoted)
+	_, err = hackpadfs.Stat(fast, "foo/old")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	info, err := hackpadfs.Stat(slow, "foo/old")
+	if assert.NoError(t, err) {
+		assert.Equal(t, hackpadfs.FileMode(0640), info.Mode())
+		assert.Equal(t, modTime, info.ModTime())
+	}
+	info, err = hackpadfs.Stat(slow, "foo")
+	if assert.NoError(t, err) {
+		assert.Equal(t, hackpadfs.ModeDir|0750, info.Mode())
+	}
+	contents, err := fs.ReadFile("foo/old")
+	assert.NoError(t, err)
+	assert.Equal(t, "old", string(contents))
+	_, err = hackpadfs.Stat(fast, "foo/new")
+	assert.NoError(t, err)
+	_, err = hackpadfs.Stat(fast, "foo/old-open")
+	assert.NoError(t, err)
+
+	assert.NoError(t, f.Close())
+	assert.NoError(t, fs.Demote())
+	_, err = hackpadfs.Stat(slow, "foo/old-open")
+	assert.NoError(t, err)
+}
+
+func TestDemoteBackground(t *testing.T) {
+	t.Parallel()
+	fs, _, slow := newFS(t, Options{
+		Demote:         DemoteOlderThan(0),
+		DemoteInterval: time.Millisecond,
+	})
+	assert.NoError(t, fs.WriteFile("foo", []byte("foo"), 0600))
+	for {
+		if _, err := hackpadfs.Stat(slow, "foo"); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.NoError(t, fs.Close())
+	contents, err := fs.ReadFile("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(contents))
+}
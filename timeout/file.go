@@ -0,0 +1,155 @@
+package timeout
+
+import (
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// file wraps a source file, failing each operation which doesn't return in time.
+// Operations may still run after timing out, so reads and writes use a private copy of the caller's buffer, and results are only read once an operation returns in time.
+type file struct {
+	hackpadfs.File
+	fs   *FS
+	name string
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	var n int
+	buf := make([]byte, len(p))
+	err := f.fs.run("read", f.name, func() error {
+		var err error
+		n, err = f.File.Read(buf)
+		return err
+	}, nil)
+	if isTimeout(err) {
+		return 0, err
+	}
+	copy(p, buf[:n])
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	buf := make([]byte, len(p))
+	err := f.fs.run("read", f.name, func() error {
+		var err error
+		n, err = hackpadfs.ReadAtFile(f.File, buf, off)
+		return err
+	}, nil)
+	if isTimeout(err) {
+		return 0, err
+	}
+	copy(p, buf[:n])
+	return n, err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	var n int
+	buf := append([]byte(nil), p...)
+	err := f.fs.run("write", f.name, func() error {
+		var err error
+		n, err = hackpadfs.WriteFile(f.File, buf)
+		return err
+	}, nil)
+	if isTimeout(err) {
+		return 0, err
+	}
+	return n, err
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	var n int
+	buf := append([]byte(nil), p...)
+	err := f.fs.run("write", f.name, func() error {
+		var err error
+		n, err = hackpadfs.WriteAtFile(f.File, buf, off)
+		return err
+	}, nil)
+	if isTimeout(err) {
+		return 0, err
+	}
+	return n, err
+}
+
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	var entries []hackpadfs.DirEntry
+	err := f.fs.run("readdir", f.name, func() error {
+		var err error
+		entries, err = hackpadfs.ReadDirFile(f.File, n)
+		return err
+	}, nil)
+	if isTimeout(err) {
+		return nil, err
+	}
+	return entries, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	var newOffset int64
+	err := f.fs.run("seek", f.name, func() error {
+		var err error
+		newOffset, err = hackpadfs.SeekFile(f.File, offset, whence)
+		return err
+	}, nil)
+	if isTimeout(err) {
+		return 0, err
+	}
+	return newOffset, err
+}
+
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	var info hackpadfs.FileInfo
+	err := f.fs.run("stat", f.name, func() error {
+		var err error
+		info, err = f.File.Stat()
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (f *file) Sync() error {
+	return f.fs.run("sync", f.name, func() error {
+		return hackpadfs.SyncFile(f.File)
+	}, nil)
+}
+
+func (f *file) Truncate(size int64) error {
+	return f.fs.run("truncate", f.name, func() error {
+		return hackpadfs.TruncateFile(f.File, size)
+	}, nil)
+}
+
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	return f.fs.run("chmod", f.name, func() error {
+		return hackpadfs.ChmodFile(f.File, mode)
+	}, nil)
+}
+
+func (f *file) Chown(uid, gid int) error {
+	return f.fs.run("chown", f.name, func() error {
+		return hackpadfs.ChownFile(f.File, uid, gid)
+	}, nil)
+}
+
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	return f.fs.run("chtimes", f.name, func() error {
+		return hackpadfs.ChtimesFile(f.File, atime, mtime)
+	}, nil)
+}
+
+func (f *file) Close() error {
+	return f.fs.run("close", f.name, f.File.Close, nil)
+}
+
+// isTimeout returns true if 'err' came from a timed out run(). The operation's results must not be read, since it may still be running.
+func isTimeout(err error) bool {
+	if pathErr, ok := err.(*hackpadfs.PathError); ok {
+		_, ok := pathErr.Err.(*Error)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,310 @@
+// Package timeout contains a file system wrapper which fails operations that run too long, so a hung remote store can't block its callers forever.
+package timeout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.WriteFileFS
+		hackpadfs.QuotaFS
+		hackpadfs.PingFS
+	} = &FS{}
+)
+
+// Error is returned inside a PathError when an operation times out. Matches context.DeadlineExceeded with errors.Is().
+type Error struct {
+	Duration time.Duration // Duration is how long the operation was given to run
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("operation timed out after %s", e.Duration)
+}
+
+// Timeout returns true, like other timeout errors in the standard library
+func (e *Error) Timeout() bool {
+	return true
+}
+
+// Is supports errors.Is()
+func (e *Error) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// FS wraps a source FS, failing each operation which doesn't return in time.
+//
+// An operation which times out keeps running in the background until the source FS returns, so a hung store still holds onto a goroutine.
+// Files opened by operations which already timed out are closed once they open.
+type FS struct {
+	sourceFS hackpadfs.FS
+	options  Options
+	deadline time.Time
+}
+
+// Options contain options for creating an FS
+type Options struct {
+	// OpTimeout is the longest each operation, including each file operation like Read or Write, may run before failing with an Error. Defaults to no limit (0).
+	OpTimeout time.Duration
+	// Budget is the total time from NewFS() in which operations may run. Once it runs out, every operation fails with an Error. Defaults to no limit (0).
+	Budget time.Duration
+}
+
+// NewFS returns a new FS wrapping 'source'
+func NewFS(source hackpadfs.FS, options Options) (*FS, error) {
+	if options.OpTimeout < 0 || options.Budget < 0 {
+		return nil, errors.New("timeout: durations must not be negative")
+	}
+	fs := &FS{
+		sourceFS: source,
+		options:  options,
+	}
+	if options.Budget > 0 {
+		fs.deadline = time.Now().Add(options.Budget)
+	}
+	return fs, nil
+}
+
+// timeout returns how long the next operation may run, or 0 for no limit. Returns false if the budget ran out.
+func (fs *FS) timeout() (time.Duration, bool) {
+	timeout := fs.options.OpTimeout
+	if fs.deadline.IsZero() {
+		return timeout, true
+	}
+	remaining := time.Until(fs.deadline)
+	if remaining <= 0 {
+		return 0, false
+	}
+	if timeout == 0 || remaining < timeout {
+		timeout = remaining
+	}
+	return timeout, true
+}
+
+// run calls 'fn' in a new goroutine and returns its error, or an Error if it doesn't return in time.
+// If it times out, 'abandoned' is called once 'fn' finally returns, if set. Useful for cleaning up fn's results, like closing a file.
+func (fs *FS) run(op, name string, fn func() error, abandoned func()) error {
+	timeout, ok := fs.timeout()
+	if !ok {
+		return &hackpadfs.PathError{Op: op, Path: name, Err: &Error{Duration: fs.options.Budget}}
+	}
+	if timeout == 0 {
+		return fn()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		if abandoned != nil {
+			go func() {
+				<-done
+				abandoned()
+			}()
+		}
+		return &hackpadfs.PathError{Op: op, Path: name, Err: &Error{Duration: timeout}}
+	}
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	var f hackpadfs.File
+	err := fs.run("open", name, func() error {
+		var err error
+		f, err = hackpadfs.OpenFile(fs.sourceFS, name, flag, perm)
+		return err
+	}, func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, fs: fs, name: name}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return fs.run("mkdir", name, func() error {
+		return hackpadfs.Mkdir(fs.sourceFS, name, perm)
+	}, nil)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return fs.run("mkdir", path, func() error {
+		return hackpadfs.MkdirAll(fs.sourceFS, path, perm)
+	}, nil)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	return fs.run("remove", name, func() error {
+		return hackpadfs.Remove(fs.sourceFS, name)
+	}, nil)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	return fs.run("removeall", name, func() error {
+		return hackpadfs.RemoveAll(fs.sourceFS, name)
+	}, nil)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	err := fs.run("rename", oldname, func() error {
+		return hackpadfs.Rename(fs.sourceFS, oldname, newname)
+	}, nil)
+	if isTimeout(err) { // renames fail with a LinkError, so replace the PathError from run()
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err.(*hackpadfs.PathError).Err}
+	}
+	return err
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	var info hackpadfs.FileInfo
+	err := fs.run("stat", name, func() error {
+		var err error
+		info, err = hackpadfs.Stat(fs.sourceFS, name)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	var info hackpadfs.FileInfo
+	err := fs.run("lstat", name, func() error {
+		var err error
+		info, err = hackpadfs.Lstat(fs.sourceFS, name)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return fs.run("chmod", name, func() error {
+		return hackpadfs.Chmod(fs.sourceFS, name, mode)
+	}, nil)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return fs.run("chown", name, func() error {
+		return hackpadfs.Chown(fs.sourceFS, name, uid, gid)
+	}, nil)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.run("chtimes", name, func() error {
+		return hackpadfs.Chtimes(fs.sourceFS, name, atime, mtime)
+	}, nil)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	var entries []hackpadfs.DirEntry
+	err := fs.run("readdir", name, func() error {
+		var err error
+		entries, err = hackpadfs.ReadDir(fs.sourceFS, name)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	var data []byte
+	err := fs.run("open", name, func() error {
+		var err error
+		data, err = hackpadfs.ReadFile(fs.sourceFS, name)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// WriteFile implements hackpadfs.WriteFileFS. 'data' is copied first, since the write may still run after timing out.
+func (fs *FS) WriteFile(name string, data []byte, perm hackpadfs.FileMode) error {
+	data = append([]byte(nil), data...)
+	return fs.run("open", name, func() error {
+		return hackpadfs.WriteFullFile(fs.sourceFS, name, data, perm)
+	}, nil)
+}
+
+// Usage implements hackpadfs.QuotaFS. 'ctx' is given the same timeout, so the source FS can also stop early.
+func (fs *FS) Usage(ctx context.Context) (hackpadfs.StorageUsage, error) {
+	if timeout, ok := fs.timeout(); ok && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var usage hackpadfs.StorageUsage
+	err := fs.run("usage", ".", func() error {
+		var err error
+		usage, err = hackpadfs.Usage(ctx, fs.sourceFS)
+		return err
+	}, nil)
+	if err != nil {
+		return hackpadfs.StorageUsage{}, err
+	}
+	return usage, nil
+}
+
+// Ping implements hackpadfs.PingFS. 'ctx' is given the same timeout, so a hung backend fails the check instead of blocking it.
+func (fs *FS) Ping(ctx context.Context) error {
+	if timeout, ok := fs.timeout(); ok && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return fs.run("ping", ".", func() error {
+		return hackpadfs.Ping(ctx, fs.sourceFS)
+	}, nil)
+}
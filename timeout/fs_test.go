@@ -0,0 +1,158 @@
+package timeout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "timeout",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			source, err := mem.NewFS()
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			fs, err := NewFS(source, Options{OpTimeout: time.Minute})
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			return fs
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+// hangingFS blocks operations until 'release' is closed
+type hangingFS struct {
+	*mem.FS
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func newHangingFS(tb testing.TB) *hangingFS {
+	tb.Helper()
+	source, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	fs := &hangingFS{
+		FS:      source,
+		release: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	tb.Cleanup(func() {
+		select {
+		case <-fs.release:
+		default:
+			close(fs.release)
+		}
+	})
+	return fs
+}
+
+func (fs *hangingFS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	<-fs.release
+	return fs.FS.Mkdir(name, perm)
+}
+
+func (fs *hangingFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	<-fs.release
+	f, err := fs.FS.OpenFile(name, flag, perm)
+	return &closeNotifyFile{File: f, closed: fs.closed}, err
+}
+
+func (fs *hangingFS) Rename(oldname, newname string) error {
+	<-fs.release
+	return fs.FS.Rename(oldname, newname)
+}
+
+type closeNotifyFile struct {
+	hackpadfs.File
+	closed chan struct{}
+}
+
+func (f *closeNotifyFile) Close() error {
+	close(f.closed)
+	return f.File.Close()
+}
+
+func TestOpTimeout(t *testing.T) {
+	t.Parallel()
+	source := newHangingFS(t)
+	fs, err := NewFS(source, Options{OpTimeout: 10 * time.Millisecond})
+	assert.NoError(t, err)
+
+	err = fs.Mkdir("foo", 0700)
+	assert.ErrorIs(t, context.DeadlineExceeded, err)
+	var pathErr *hackpadfs.PathError
+	if assert.ErrorAs(t, &pathErr, err) {
+		assert.Equal(t, "mkdir", pathErr.Op)
+		assert.Equal(t, "foo", pathErr.Path)
+	}
+	var timeoutErr *Error
+	if assert.ErrorAs(t, &timeoutErr, err) {
+		assert.Equal(t, 10*time.Millisecond, timeoutErr.Duration)
+		assert.Equal(t, true, timeoutErr.Timeout())
+	}
+
+	err = fs.Rename("foo", "bar")
+	var linkErr *hackpadfs.LinkError
+	assert.ErrorAs(t, &linkErr, err)
+	assert.ErrorIs(t, context.DeadlineExceeded, err)
+
+	// operations which don't hang still succeed
+	assert.NoError(t, hackpadfs.WriteFullFile(source.FS, "baz", []byte("baz"), 0600))
+	contents, err := fs.ReadFile("baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(contents))
+}
+
+func TestOpenFileTimeoutClosesFile(t *testing.T) {
+	t.Parallel()
+	source := newHangingFS(t)
+	assert.NoError(t, hackpadfs.WriteFullFile(source.FS, "foo", nil, 0600))
+	fs, err := NewFS(source, Options{OpTimeout: 10 * time.Millisecond})
+	assert.NoError(t, err)
+
+	_, err = fs.OpenFile("foo", hackpadfs.FlagReadWrite, 0)
+	assert.ErrorIs(t, context.DeadlineExceeded, err)
+	close(source.release)
+	select {
+	case <-source.closed:
+	case <-time.After(5 * time.Second):
+		t.Error("Timed out waiting for abandoned file to close")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	t.Parallel()
+	source, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := NewFS(source, Options{Budget: 10 * time.Millisecond})
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Mkdir("foo", 0700))
+
+	time.Sleep(20 * time.Millisecond)
+	err = fs.Mkdir("bar", 0700)
+	assert.ErrorIs(t, context.DeadlineExceeded, err)
+	_, err = source.Stat("bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err) // out of budget, so it doesn't run at all
+}
+
+func TestNewFSInvalidOptions(t *testing.T) {
+	t.Parallel()
+	source, err := mem.NewFS()
+	assert.NoError(t, err)
+	_, err = NewFS(source, Options{OpTimeout: -1})
+	assert.Error(t, err)
+}
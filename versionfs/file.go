@@ -0,0 +1,42 @@
+package versionfs
+
+import (
+	"bytes"
+	"path"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// versionFile is a read-only file containing a previous version's contents
+type versionFile struct {
+	*bytes.Reader
+	info versionInfo
+}
+
+func newVersionFile(name string, version Version, contents []byte) *versionFile {
+	return &versionFile{
+		Reader: bytes.NewReader(contents),
+		info:   versionInfo{name: path.Base(name), version: version},
+	}
+}
+
+func (f *versionFile) Stat() (hackpadfs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *versionFile) Close() error {
+	return nil
+}
+
+type versionInfo struct {
+	name    string
+	version Version
+}
+
+func (v versionInfo) Name() string             { return v.name }
+func (v versionInfo) Size() int64              { return v.version.Size }
+func (v versionInfo) Mode() hackpadfs.FileMode { return v.version.Mode }
+func (v versionInfo) ModTime() time.Time       { return v.version.ModTime }
+func (v versionInfo) IsDir() bool              { return false }
+func (v versionInfo) Sys() interface{}         { return v.version }
@@ -0,0 +1,200 @@
+// Package versionfs contains a keyvalue-based FS which keeps a history of previous file versions.
+package versionfs
+
+import (
+	"context"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ApplyMetadataFS
+	} = &FS{}
+)
+
+const defaultMaxVersions = 10
+
+// VersionID identifies a version of a file. IDs increase with each new version of the same file.
+type VersionID uint64
+
+// Version describes a previous version of a file.
+type Version struct {
+	ID      VersionID
+	Size    int64
+	Mode    hackpadfs.FileMode
+	ModTime time.Time // ModTime is the file's modified time when this version was its current contents
+	Created time.Time // Created is when this version was replaced, becoming part of the file's history
+}
+
+// PrunePolicy decides which versions to retain after a new version is recorded.
+// 'versions' are ordered from oldest to newest and the returned versions must be a subset of them.
+type PrunePolicy func(versions []Version) (keep []Version)
+
+// KeepLast returns a PrunePolicy which retains the newest 'n' versions.
+func KeepLast(n int) PrunePolicy {
+	return func(versions []Version) []Version {
+		if len(versions) <= n {
+			return versions
+		}
+		return versions[len(versions)-n:]
+	}
+}
+
+// KeepWithin returns a PrunePolicy which retains versions replaced within the last duration 'd'.
+func KeepWithin(d time.Duration) PrunePolicy {
+	return func(versions []Version) []Version {
+		cutoff := time.Now().Add(-d)
+		for i, v := range versions {
+			if v.Created.After(cutoff) {
+				return versions[i:]
+			}
+		}
+		return nil
+	}
+}
+
+// FS is a keyvalue-based file system which records previous versions of files as they are overwritten.
+//
+// A version is recorded each time a non-empty file's contents are replaced, including on every Write() to an open file.
+// Metadata changes, like Chmod() and Chtimes(), don't record a version.
+// Versions are kept by path. Removing or renaming a file does not remove its history.
+type FS struct {
+	kv    *keyvalue.FS
+	store *store
+}
+
+// Options contain options for creating an FS
+type Options struct {
+	// Prune decides which versions to retain for a file. Defaults to KeepLast(10).
+	Prune PrunePolicy
+	// MinInterval is the minimum time between recorded versions of a file.
+	// Replacing a file's contents again within this interval does not record a new version, which coalesces bursts of small writes.
+	// Defaults to 0, recording a version for every change.
+	MinInterval time.Duration
+}
+
+// NewFS returns a new FS storing files in 'store' and previous file versions in 'history'.
+// Both stores must be independent, i.e. 'history' must not share keys with 'store'.
+func NewFS(store, history keyvalue.Store, options Options) (*FS, error) {
+	if options.Prune == nil {
+		options.Prune = KeepLast(defaultMaxVersions)
+	}
+	s := newStore(store, history, options)
+	kv, err := keyvalue.NewFS(s)
+	return &FS{
+		kv:    kv,
+		store: s,
+	}, err
+}
+
+// Versions returns the previous versions of the file 'name', ordered from oldest to newest.
+func (fs *FS) Versions(name string) ([]Version, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "versions", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	fs.store.mu.Lock()
+	defer fs.store.mu.Unlock()
+	versions, err := fs.store.versions(context.Background(), name)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "versions", Path: name, Err: err}
+	}
+	return versions, nil
+}
+
+// OpenVersion opens a read-only copy of version 'id' of the file 'name'.
+func (fs *FS) OpenVersion(name string, id VersionID) (hackpadfs.File, error) {
+	version, contents, err := fs.version(name, id)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "openversion", Path: name, Err: err}
+	}
+	return newVersionFile(name, version, contents), nil
+}
+
+// RestoreVersion replaces the contents and mode of 'name' with version 'id'. The replaced contents are recorded as a new version.
+func (fs *FS) RestoreVersion(name string, id VersionID) error {
+	version, contents, err := fs.version(name, id)
+	if err != nil {
+		return &hackpadfs.PathError{Op: "restoreversion", Path: name, Err: err}
+	}
+	err = hackpadfs.WriteFullFile(fs, name, contents, version.Mode)
+	if err != nil {
+		return err
+	}
+	return fs.Chmod(name, version.Mode)
+}
+
+func (fs *FS) version(name string, id VersionID) (Version, []byte, error) {
+	versions, err := fs.Versions(name)
+	if err != nil {
+		return Version{}, nil, err
+	}
+	for _, v := range versions {
+		if v.ID == id {
+			contents, err := fs.store.versionData(context.Background(), name, id)
+			return v, contents, err
+		}
+	}
+	return Version{}, nil, hackpadfs.ErrNotExist
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.kv.Open(name)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	return fs.kv.OpenFile(name, flag, perm)
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return fs.kv.Mkdir(name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return fs.kv.MkdirAll(path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	return fs.kv.Remove(name)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	return fs.kv.Rename(oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return fs.kv.Stat(name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return fs.kv.Chmod(name, mode)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.kv.Chtimes(name, atime, mtime)
+}
+
+// ApplyMetadata implements hackpadfs.ApplyMetadataFS
+func (fs *FS) ApplyMetadata(metadata map[string]hackpadfs.Metadata) error {
+	return fs.kv.ApplyMetadata(metadata)
+}
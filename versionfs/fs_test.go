@@ -0,0 +1,145 @@
+package versionfs
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newFS(tb testing.TB, options Options) *FS {
+	tb.Helper()
+	fs, err := NewFS(mem.NewStore(), mem.NewStore(), options)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "versionfs",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			return newFS(tb, Options{})
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.StdFS(t, options)
+}
+
+func TestVersions(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t, Options{Prune: KeepLast(2)})
+
+	for _, contents := range []string{"a", "b", "c", "d"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte(contents), 0600))
+	}
+	assert.NoError(t, fs.Chmod("foo", 0700)) // metadata-only changes don't add versions
+
+	versions, err := fs.Versions("foo")
+	assert.NoError(t, err)
+	if !assert.Equal(t, 2, len(versions)) {
+		t.FailNow()
+	}
+	assert.Equal(t, VersionID(2), versions[0].ID)
+	assert.Equal(t, VersionID(3), versions[1].ID)
+	assert.Equal(t, int64(1), versions[1].Size)
+
+	f, err := fs.OpenVersion("foo", versions[1].ID)
+	if assert.NoError(t, err) {
+		contents, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, "c", string(contents))
+		assert.NoError(t, f.Close())
+	}
+
+	_, err = fs.OpenVersion("foo", 1)
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	assert.NoError(t, fs.RestoreVersion("foo", versions[0].ID))
+	contents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(contents))
+
+	versions, err = fs.Versions("foo")
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(versions)) {
+		assert.Equal(t, VersionID(4), versions[1].ID) // "d" was recorded before restoring
+	}
+}
+
+func TestVersionsMinInterval(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t, Options{MinInterval: time.Hour})
+
+	for _, contents := range []string{"a", "b", "c"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte(contents), 0600))
+	}
+	versions, err := fs.Versions("foo")
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(versions)) {
+		f, err := fs.OpenVersion("foo", versions[0].ID)
+		if assert.NoError(t, err) {
+			contents, err := io.ReadAll(f)
+			assert.NoError(t, err)
+			assert.Equal(t, "a", string(contents))
+		}
+	}
+}
+
+func TestVersionsMetadataChanges(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t, Options{})
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("a"), 0600))
+	assert.NoError(t, fs.Chmod("foo", 0700))
+	assert.NoError(t, fs.Chtimes("foo", time.Now(), time.Now().Add(-time.Hour)))
+	versions, err := fs.Versions("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(versions))
+
+	info, err := fs.Stat("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0700), info.Mode())
+	contents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(contents))
+}
+
+func TestVersionsInPlaceWrites(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t, Options{})
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("abc"), 0600))
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, b := range []string{"x", "y"} {
+		_, err = hackpadfs.WriteFile(f, []byte(b))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, f.Close())
+
+	versions, err := fs.Versions("foo")
+	assert.NoError(t, err)
+	var history []string
+	for _, v := range versions {
+		f, err := fs.OpenVersion("foo", v.ID)
+		if assert.NoError(t, err) {
+			contents, err := io.ReadAll(f)
+			assert.NoError(t, err)
+			history = append(history, string(contents))
+		}
+	}
+	assert.Equal(t, []string{"abc", "xbc"}, history)
+	contents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "xyc", string(contents))
+}
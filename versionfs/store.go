@@ -0,0 +1,312 @@
+package versionfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+var _ keyvalue.MetadataStore = &store{}
+
+// store wraps a keyvalue.Store, copying the previous contents of regular files into a history store every time they are replaced
+type store struct {
+	keyvalue.Store
+	history keyvalue.Store
+	options Options
+
+	mu sync.Mutex // guards read-modify-write of history indexes
+}
+
+func newStore(source, history keyvalue.Store, options Options) *store {
+	return &store{
+		Store:   source,
+		history: history,
+		options: options,
+	}
+}
+
+func indexKey(name string) string {
+	return "i." + url.PathEscape(name)
+}
+
+func versionKey(name string, id VersionID) string {
+	return "v." + url.PathEscape(name) + "@" + strconv.FormatUint(uint64(id), 10)
+}
+
+func (s *store) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
+	record, err := s.Store.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return copyOnWriteRecord{record}, nil
+}
+
+// copyOnWriteRecord wraps the file's contents in a copyOnWriteBlob, so in-place writes don't alter the stored record before it can be snapshotted
+type copyOnWriteRecord struct {
+	keyvalue.FileRecord
+}
+
+func (c copyOnWriteRecord) Data() (blob.Blob, error) {
+	data, err := c.FileRecord.Data()
+	if err != nil {
+		return nil, err
+	}
+	return &copyOnWriteBlob{blob: data}, nil
+}
+
+// copyOnWriteBlob reads directly from a stored blob, but copies it before the first change
+type copyOnWriteBlob struct {
+	mu     sync.Mutex
+	blob   blob.Blob
+	copied bool
+}
+
+func (c *copyOnWriteBlob) current() blob.Blob {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.blob
+}
+
+// writable returns a private copy of the blob to write to
+func (c *copyOnWriteBlob) writable() (blob.Blob, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.copied {
+		clone, err := blob.Clone(c.blob)
+		if err != nil {
+			return nil, err
+		}
+		c.blob = clone
+		c.copied = true
+	}
+	return c.blob, nil
+}
+
+// share returns the current blob to be stored, copying it again before the next change
+func (c *copyOnWriteBlob) share() blob.Blob {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.copied = false
+	return c.blob
+}
+
+func (c *copyOnWriteBlob) Bytes() []byte {
+	return c.current().Bytes()
+}
+
+func (c *copyOnWriteBlob) Len() int {
+	return c.current().Len()
+}
+
+func (c *copyOnWriteBlob) View(start, end int64) (blob.Blob, error) {
+	return blob.View(c.current(), start, end)
+}
+
+func (c *copyOnWriteBlob) Slice(start, end int64) (blob.Blob, error) {
+	return blob.Slice(c.current(), start, end)
+}
+
+func (c *copyOnWriteBlob) Set(src blob.Blob, offset int64) (int, error) {
+	b, err := c.writable()
+	if err != nil {
+		return 0, err
+	}
+	return blob.Set(b, src, offset)
+}
+
+func (c *copyOnWriteBlob) Grow(offset int64) error {
+	b, err := c.writable()
+	if err != nil {
+		return err
+	}
+	return blob.Grow(b, offset)
+}
+
+func (c *copyOnWriteBlob) Truncate(size int64) error {
+	b, err := c.writable()
+	if err != nil {
+		return err
+	}
+	return blob.Truncate(b, size)
+}
+
+func (s *store) Set(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	if src != nil && src.Mode().IsRegular() {
+		data, err := src.Data()
+		if err != nil {
+			return err
+		}
+		if err := s.snapshot(ctx, path, data); err != nil {
+			return err
+		}
+		stored, err := storedBlob(data)
+		if err != nil {
+			return err
+		}
+		src = storedRecord{FileRecord: src, data: stored}
+	}
+	return s.Store.Set(ctx, path, src)
+}
+
+// storedBlob returns the blob to store for 'data', which must not change with later writes to 'data'
+func storedBlob(data blob.Blob) (blob.Blob, error) {
+	if c, ok := data.(*copyOnWriteBlob); ok {
+		return c.share(), nil
+	}
+	return blob.Clone(data)
+}
+
+// storedRecord replaces a record's contents with 'data'
+type storedRecord struct {
+	keyvalue.FileRecord
+	data blob.Blob
+}
+
+func (s storedRecord) Data() (blob.Blob, error) {
+	return s.data, nil
+}
+
+// SetMetadata implements keyvalue.MetadataStore. Metadata changes don't replace a file's contents, so they never record a version.
+func (s *store) SetMetadata(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	if metadataStore, ok := s.Store.(keyvalue.MetadataStore); ok {
+		return metadataStore.SetMetadata(ctx, path, src)
+	}
+	record, err := s.Store.Get(ctx, path)
+	if err != nil {
+		return err
+	}
+	return s.Store.Set(ctx, path, metadataRecord{FileRecord: record, metadata: src})
+}
+
+// metadataRecord is a stored record with the mode and modified time of 'metadata'
+type metadataRecord struct {
+	keyvalue.FileRecord
+	metadata keyvalue.FileRecord
+}
+
+func (m metadataRecord) Mode() hackpadfs.FileMode {
+	return m.metadata.Mode()
+}
+
+func (m metadataRecord) ModTime() time.Time {
+	return m.metadata.ModTime()
+}
+
+// snapshot records the current contents of 'path' as a new version, if it is a non-empty regular file and its contents differ from 'next'
+func (s *store) snapshot(ctx context.Context, path string, next blob.Blob) error {
+	prev, err := s.Store.Get(ctx, path)
+	if errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !prev.Mode().IsRegular() {
+		return nil
+	}
+	data, err := prev.Data()
+	if err != nil {
+		return err
+	}
+	contents := data.Bytes()
+	if len(contents) == 0 || bytes.Equal(contents, next.Bytes()) {
+		return nil // empty files and metadata-only changes don't produce a new version
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions, err := s.versions(ctx, path)
+	if err != nil {
+		return err
+	}
+	var id VersionID = 1
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		if time.Since(latest.Created) < s.options.MinInterval {
+			return nil
+		}
+		id = latest.ID + 1
+	}
+	version := Version{
+		ID:      id,
+		Size:    int64(len(contents)),
+		Mode:    prev.Mode(),
+		ModTime: prev.ModTime(),
+		Created: time.Now(),
+	}
+	err = s.history.Set(ctx, versionKey(path, id), newBytesRecord(contents, version.Mode, version.ModTime))
+	if err != nil {
+		return err
+	}
+	versions = append(versions, version)
+
+	keep := s.options.Prune(versions)
+	kept := make(map[VersionID]bool, len(keep))
+	for _, v := range keep {
+		kept[v.ID] = true
+	}
+	for _, v := range versions {
+		if !kept[v.ID] {
+			if err := s.history.Set(ctx, versionKey(path, v.ID), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return s.setVersions(ctx, path, keep)
+}
+
+// versions returns the recorded versions for 'path', oldest first
+func (s *store) versions(ctx context.Context, path string) ([]Version, error) {
+	record, err := s.history.Get(ctx, indexKey(path))
+	if errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := record.Data()
+	if err != nil {
+		return nil, err
+	}
+	var versions []Version
+	err = json.Unmarshal(data.Bytes(), &versions)
+	return versions, err
+}
+
+func (s *store) setVersions(ctx context.Context, path string, versions []Version) error {
+	if len(versions) == 0 {
+		return s.history.Set(ctx, indexKey(path), nil)
+	}
+	buf, err := json.Marshal(versions)
+	if err != nil {
+		return err
+	}
+	return s.history.Set(ctx, indexKey(path), newBytesRecord(buf, 0600, time.Now()))
+}
+
+func (s *store) versionData(ctx context.Context, path string, id VersionID) ([]byte, error) {
+	record, err := s.history.Get(ctx, versionKey(path, id))
+	if err != nil {
+		return nil, err
+	}
+	data, err := record.Data()
+	if err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+func newBytesRecord(contents []byte, mode hackpadfs.FileMode, modTime time.Time) keyvalue.FileRecord {
+	return keyvalue.NewBaseFileRecord(int64(len(contents)), modTime, mode, nil, func() (blob.Blob, error) {
+		return blob.NewBytes(contents), nil
+	}, nil)
+}
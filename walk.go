@@ -0,0 +1,113 @@
+package hackpadfs
+
+import (
+	gofs "io/fs"
+	"path"
+)
+
+// maxFollowDepth is the maximum number of symlinks followed while resolving a path or nested into by WalkDirFollow(), matching the limit on Linux
+const maxFollowDepth = 40
+
+// WalkDirFollowFunc is the type of function called in WalkDirFollow().
+// If 'path' is a symlink, 'target' is the symlink's destination and 'd' describes the file it resolves to. Otherwise, 'target' is empty.
+// Errors and return values are handled the same as a WalkDirFunc.
+type WalkDirFollowFunc func(path, target string, d DirEntry, err error) error
+
+// WalkDirFollow is like WalkDir(), but follows symlinks to files and directories.
+// Symlinks are resolved with Readlink() and Stat(). If a symlink can't be resolved, 'fn' is called with the symlink's DirEntry and the error.
+//
+// A directory which is the same file as one of its ancestors, according to SameFile(), is passed to 'fn' but not walked again. This breaks cycles on FS's like os.FS.
+// Walking fails with ErrTooManyLinks after nesting into too many symlinked directories, which also breaks cycles on FS's that can't identify files.
+func WalkDirFollow(fs FS, root string, fn WalkDirFollowFunc) error {
+	info, err := Stat(fs, root)
+	if err != nil {
+		err = fn(root, "", nil, err)
+	} else {
+		w := &followWalker{fs: fs, fn: fn}
+		err = w.walk(root, "", gofs.FileInfoToDirEntry(info))
+	}
+	if err == SkipDir {
+		return nil
+	}
+	return err
+}
+
+type followWalker struct {
+	fs        FS
+	fn        WalkDirFollowFunc
+	ancestors []FileInfo // resolved directories currently being walked, used to detect cycles
+	links     int        // number of symlinked directories currently being walked
+}
+
+func (w *followWalker) walk(name, target string, dirEntry DirEntry) error {
+	if err := w.fn(name, target, dirEntry, nil); err != nil || !dirEntry.IsDir() {
+		if err == SkipDir && dirEntry.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	info, err := dirEntry.Info()
+	if err == nil {
+		if w.isAncestor(info) {
+			return nil
+		}
+		if target != "" {
+			if w.links >= maxFollowDepth {
+				err = &PathError{Op: "walk", Path: name, Err: ErrTooManyLinks}
+			} else {
+				w.links++
+				defer func() { w.links-- }()
+			}
+		}
+	}
+	var entries []DirEntry
+	if err == nil {
+		entries, err = ReadDir(w.fs, name)
+	}
+	if err != nil {
+		err = w.fn(name, target, dirEntry, err)
+		if err == SkipDir {
+			err = nil
+		}
+		return err
+	}
+
+	w.ancestors = append(w.ancestors, info)
+	defer func() { w.ancestors = w.ancestors[:len(w.ancestors)-1] }()
+	for _, entry := range entries {
+		err := w.walkEntry(path.Join(name, entry.Name()), entry)
+		if err != nil {
+			if err == SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// walkEntry resolves 'dirEntry' if it's a symlink, then walks it
+func (w *followWalker) walkEntry(name string, dirEntry DirEntry) error {
+	if dirEntry.Type()&ModeSymlink == 0 {
+		return w.walk(name, "", dirEntry)
+	}
+	target, err := Readlink(w.fs, name)
+	if err != nil {
+		return w.fn(name, target, dirEntry, err)
+	}
+	info, err := Stat(w.fs, name)
+	if err != nil {
+		return w.fn(name, target, dirEntry, err)
+	}
+	return w.walk(name, target, gofs.FileInfoToDirEntry(info))
+}
+
+func (w *followWalker) isAncestor(info FileInfo) bool {
+	for _, ancestor := range w.ancestors {
+		if SameFile(w.fs, ancestor, info) {
+			return true
+		}
+	}
+	return false
+}
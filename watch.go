@@ -0,0 +1,73 @@
+package hackpadfs
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+// EventOp describes the kind of change in an Event
+type EventOp int
+
+// Event operations
+const (
+	EventWrite  EventOp = iota + 1 // EventWrite means the file was created, or its contents or metadata changed
+	EventRemove                    // EventRemove means the file was removed
+)
+
+func (o EventOp) String() string {
+	switch o {
+	case EventWrite:
+		return "write"
+	case EventRemove:
+		return "remove"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a change to the file at Name
+type Event struct {
+	Name string
+	Op   EventOp
+}
+
+// Watch returns a channel of changes to the file 'name' and every file under it, if it's a directory. The channel is closed once 'ctx' is done.
+// Fails with a not implemented error if 'fs' is not a WatchFS.
+func Watch(ctx context.Context, fs FS, name string) (<-chan Event, error) {
+	if fs, ok := fs.(WatchFS); ok {
+		return fs.Watch(ctx, name)
+	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(name)
+		mountEvents, err := Watch(ctx, mountFS, subPath)
+		if err != nil {
+			return nil, stripErrPathPrefix(err, name, subPath)
+		}
+		events := make(chan Event)
+		go func() {
+			defer close(events)
+			for event := range mountEvents {
+				event.Name = unmountPath(event.Name, name, subPath)
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return events, nil
+	}
+	return nil, &PathError{Op: "watch", Path: name, Err: ErrNotImplemented}
+}
+
+// unmountPath converts 'p', a path at or under the mount's 'subPath', to the matching path under 'name'
+func unmountPath(p, name, subPath string) string {
+	if p == subPath {
+		return name
+	}
+	if subPath != "." {
+		p = strings.TrimPrefix(p, subPath+"/")
+	}
+	return path.Join(name, p)
+}
@@ -0,0 +1,43 @@
+package hackpadfs_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.MkdirAll(memFS, "sub/foo", 0700))
+	subFS, err := hackpadfs.Sub(memFS, "sub")
+	requireNoError(t, err)
+
+	for _, name := range []string{".", "foo"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events, err := hackpadfs.Watch(ctx, subFS, name)
+			requireNoError(t, err)
+			requireNoError(t, hackpadfs.WriteFullFile(memFS, "sub/foo/bar-"+name, nil, 0600))
+			assert.Equal(t, hackpadfs.Event{Name: "foo/bar-" + name, Op: hackpadfs.EventWrite}, <-events)
+		})
+	}
+}
+
+type openOnlyFS struct {
+	hackpadfs.FS
+}
+
+func TestWatchNotImplemented(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	_, err = hackpadfs.Watch(context.Background(), openOnlyFS{memFS}, ".")
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+}